@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// anyEncoder builds a Field for values of a single concrete type.
+type anyEncoder func(key string, value interface{}) Field
+
+var (
+	// _anyEncoders caches, by reflect.Type, how Any handles values that its
+	// type switch doesn't recognize. Programs log a bounded set of types, so
+	// entries are never evicted.
+	_anyEncoders sync.Map
+
+	_jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	_textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// anyByType handles the values that Any's type switch falls through on. It
+// works out how to encode each concrete type once, so that values of named
+// basic types, like
+//
+//	type UserID int64
+//
+// are logged as numbers, strings, and booleans rather than through
+// encoding/json on every call.
+func anyByType(key string, value interface{}) Field {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return Reflect(key, value)
+	}
+	if enc, ok := _anyEncoders.Load(t); ok {
+		return enc.(anyEncoder)(key, value)
+	}
+	enc, _ := _anyEncoders.LoadOrStore(t, newAnyEncoder(t))
+	return enc.(anyEncoder)(key, value)
+}
+
+func newAnyEncoder(t reflect.Type) anyEncoder {
+	// encoding/json defers to these interfaces, so types implementing them
+	// must keep going through Reflect to be encoded the same way.
+	if t.Implements(_jsonMarshalerType) || t.Implements(_textMarshalerType) {
+		return Reflect
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(key string, value interface{}) Field {
+			return Bool(key, reflect.ValueOf(value).Bool())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(key string, value interface{}) Field {
+			return Int64(key, reflect.ValueOf(value).Int())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(key string, value interface{}) Field {
+			return Uint64(key, reflect.ValueOf(value).Uint())
+		}
+	case reflect.String:
+		return func(key string, value interface{}) Field {
+			return String(key, reflect.ValueOf(value).String())
+		}
+	default:
+		return Reflect
+	}
+}
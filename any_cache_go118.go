@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RegisterAny teaches Any to log values of type T with fn, instead of
+// falling back to Reflect. It's meant for hot types that don't implement
+// zapcore.ObjectMarshaler, such as types from other packages; those that
+// do, and the types Any already handles, aren't affected. Registering a
+// type again replaces its function.
+//
+// Any looks functions up by the dynamic type of the value it's given, so T
+// must be a concrete type; RegisterAny panics if it's an interface type.
+//
+// For example:
+//
+//	zap.RegisterAny(func(key string, u url.URL) zap.Field {
+//		return zap.String(key, u.Redacted())
+//	})
+func RegisterAny[T any](fn func(key string, value T) Field) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("zap: can't register Any function for interface type %v", t))
+	}
+	_anyEncoders.Store(t, anyEncoder(func(key string, value interface{}) Field {
+		return fn(key, value.(T))
+	}))
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type point struct{ x, y int }
+
+func TestRegisterAny(t *testing.T) {
+	assert.Equal(t, Reflect("k", point{1, 2}), Any("k", point{1, 2}), "Expected unregistered types to fall back to Reflect.")
+
+	RegisterAny(func(key string, p point) Field {
+		return Ints(key, []int{p.x, p.y})
+	})
+	defer _anyEncoders.Delete(reflect.TypeOf(point{}))
+	assert.Equal(t, Ints("k", []int{1, 2}), Any("k", point{1, 2}), "Expected the registered function to be used.")
+	assert.Equal(t, Reflect("k", &point{1, 2}), Any("k", &point{1, 2}), "Expected pointers to be a different type.")
+}
+
+func TestRegisterAnyInterface(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterAny(func(key string, s fmt.Stringer) Field {
+			return String(key, s.String())
+		})
+	}, "Expected registering an interface type to panic.")
+}
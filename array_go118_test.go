@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type emptyObject struct{}
+
+func (emptyObject) MarshalLogObject(zapcore.ObjectEncoder) error { return nil }
+
+type fakeObject struct {
+	value string
+	err   error
+}
+
+func (o *fakeObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("value", o.value)
+	return o.err
+}
+
+func TestObjectsAndObjectValues(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    Field
+		want    []interface{}
+		wantErr string
+	}{
+		{
+			desc: "Objects/nil slice",
+			give: Objects[*fakeObject]("", nil),
+			want: []interface{}{},
+		},
+		{
+			desc: "Objects/values",
+			give: Objects("", []emptyObject{{}, {}}),
+			want: []interface{}{map[string]interface{}{}, map[string]interface{}{}},
+		},
+		{
+			desc: "Objects/pointers",
+			give: Objects("", []*fakeObject{{value: "foo"}, {value: "bar"}}),
+			want: []interface{}{
+				map[string]interface{}{"value": "foo"},
+				map[string]interface{}{"value": "bar"},
+			},
+		},
+		{
+			desc:    "Objects/error",
+			give:    Objects("", []*fakeObject{{value: "foo"}, {value: "bar", err: errors.New("great sadness")}, {value: "baz"}}),
+			want:    []interface{}{map[string]interface{}{"value": "foo"}, map[string]interface{}{"value": "bar"}},
+			wantErr: "great sadness",
+		},
+		{
+			desc: "ObjectValues/nil slice",
+			give: ObjectValues[fakeObject]("", nil),
+			want: []interface{}{},
+		},
+		{
+			desc: "ObjectValues/values",
+			give: ObjectValues("", []fakeObject{{value: "foo"}, {value: "bar"}}),
+			want: []interface{}{
+				map[string]interface{}{"value": "foo"},
+				map[string]interface{}{"value": "bar"},
+			},
+		},
+		{
+			desc:    "ObjectValues/error",
+			give:    ObjectValues("", []fakeObject{{value: "foo"}, {value: "bar", err: errors.New("great sadness")}}),
+			want:    []interface{}{map[string]interface{}{"value": "foo"}, map[string]interface{}{"value": "bar"}},
+			wantErr: "great sadness",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.give.Key = "k"
+
+			enc := zapcore.NewMapObjectEncoder()
+			tt.give.AddTo(enc)
+
+			if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, enc.Fields["kError"], "Expected the marshaling error to be logged.")
+			}
+			assert.Equal(t, tt.want, enc.Fields["k"], "Unexpected output.")
+		})
+	}
+}
+
+func TestStringersAndErrorValues(t *testing.T) {
+	tests := []struct {
+		desc string
+		give Field
+		want []interface{}
+	}{
+		{"Stringers/nil", Stringers[time.Duration]("", nil), []interface{}{}},
+		{"Stringers", Stringers("", []time.Duration{time.Second, time.Minute}), []interface{}{"1s", "1m0s"}},
+		{"Stringers/interfaces", Stringers("", []fmt.Stringer{time.Second}), []interface{}{"1s"}},
+		{
+			"ErrorValues",
+			ErrorValues("", []*codeError{{code: 1}}),
+			[]interface{}{map[string]interface{}{"error": "code 1"}},
+		},
+		{
+			"ErrorValues/skips nil interfaces",
+			ErrorValues("", []error{errors.New("a"), nil}),
+			[]interface{}{map[string]interface{}{"error": "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := zapcore.NewMapObjectEncoder()
+			tt.give.Key = "k"
+			tt.give.AddTo(enc)
+			assert.Equal(t, tt.want, enc.Fields["k"], "Unexpected output.")
+		})
+	}
+}
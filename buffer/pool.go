@@ -27,12 +27,23 @@ type Pool struct {
 	p *sync.Pool
 }
 
-// NewPool constructs a new Pool.
+// NewPool constructs a new Pool of 1 KiB buffers.
 func NewPool() Pool {
+	return NewPoolSized(_size)
+}
+
+// NewPoolSized constructs a new Pool whose buffers start with the given
+// capacity, in bytes. Buffers that outgrow it are reallocated by append, so
+// workloads that routinely encode entries larger than 1 KiB can save the
+// copying by sizing their pool to fit. A non-positive size uses the default.
+func NewPoolSized(size int) Pool {
+	if size <= 0 {
+		size = _size
+	}
 	return Pool{
 		p: &sync.Pool{
 			New: func() interface{} {
-				return &Buffer{bs: make([]byte, 0, _size)} // 1024 Bytes
+				return &Buffer{bs: make([]byte, 0, size)}
 			},
 		},
 	}
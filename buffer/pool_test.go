@@ -50,3 +50,20 @@ func TestBuffers(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestNewPoolSized(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{0, _size},
+		{-1, _size},
+		{64, 64},
+		{16 << 10, 16 << 10},
+	}
+	for _, tt := range tests {
+		buf := NewPoolSized(tt.size).Get()
+		assert.Equal(t, tt.want, buf.Cap(), "Unexpected capacity for pool of size %v.", tt.size)
+		buf.Free()
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// callerPathRewriter replaces prefixes of caller file paths, trying longer
+// prefixes first.
+type callerPathRewriter []callerPathPrefix
+
+type callerPathPrefix struct {
+	prefix      string
+	replacement string
+	// segments is set if the prefix has wildcards, which match whole path
+	// segments.
+	segments []string
+}
+
+// newCallerPathRewriter builds a rewriter from a map of prefixes to their
+// replacements (see RewriteCallerPaths). Prefixes with invalid wildcards are
+// left out of the rewriter and reported in the error.
+func newCallerPathRewriter(prefixes map[string]string) (callerPathRewriter, error) {
+	var err error
+	r := make(callerPathRewriter, 0, len(prefixes))
+	for prefix, replacement := range prefixes {
+		p := callerPathPrefix{prefix: prefix, replacement: replacement}
+		if strings.ContainsAny(prefix, "*?[") {
+			p.segments = strings.Split(prefix, "/")
+			if bad := validSegments(p.segments); bad != nil {
+				err = multierr.Append(err, fmt.Errorf("invalid caller path prefix %q: %v", prefix, bad))
+				continue
+			}
+		}
+		r = append(r, p)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if len(r[i].prefix) != len(r[j].prefix) {
+			return len(r[i].prefix) > len(r[j].prefix)
+		}
+		return r[i].prefix < r[j].prefix
+	})
+	return r, err
+}
+
+func validSegments(segments []string) error {
+	for _, seg := range segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r callerPathRewriter) rewrite(file string) string {
+	for _, p := range r {
+		if n, ok := p.match(file); ok {
+			return p.replacement + file[n:]
+		}
+	}
+	return file
+}
+
+// match reports whether file starts with the prefix, and if so, how many
+// bytes of it the prefix covers.
+func (p callerPathPrefix) match(file string) (int, bool) {
+	if p.segments == nil {
+		return len(p.prefix), strings.HasPrefix(file, p.prefix)
+	}
+	n := 0
+	for i, seg := range p.segments {
+		last := i == len(p.segments)-1
+		if last && seg == "" {
+			// The prefix ends with a slash, which was consumed along with
+			// the previous segment.
+			return n, true
+		}
+		end := strings.IndexByte(file[n:], '/')
+		if end < 0 {
+			if !last {
+				return 0, false
+			}
+			end = len(file) - n
+		}
+		if ok, _ := path.Match(seg, file[n:n+end]); !ok {
+			return 0, false
+		}
+		n += end
+		if !last {
+			n++ // the slash
+		}
+	}
+	return n, true
+}
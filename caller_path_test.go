@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallerPathRewriter(t *testing.T) {
+	r, err := newCallerPathRewriter(map[string]string{
+		"/home/*/.cache/bazel/_bazel_*/*/sandbox/*/*/execroot/__main__/": "",
+		"/src/github.com/acme/":                                          "acme/",
+		"/src/github.com/acme/vendor/":                                   "vendor/",
+		"/opt/build-?":                                                   "build",
+	})
+	require.NoError(t, err, "Unexpected error building rewriter.")
+
+	tests := []struct {
+		file, want string
+	}{
+		{
+			"/home/ci/.cache/bazel/_bazel_ci/3f2a/sandbox/linux-sandbox/12/execroot/__main__/svc/main.go",
+			"svc/main.go",
+		},
+		{
+			// Wildcards don't cross path segments.
+			"/home/ci/.cache/bazel/_bazel_ci/3f2a/extra/sandbox/linux-sandbox/12/execroot/__main__/svc/main.go",
+			"/home/ci/.cache/bazel/_bazel_ci/3f2a/extra/sandbox/linux-sandbox/12/execroot/__main__/svc/main.go",
+		},
+		{"/src/github.com/acme/svc/main.go", "acme/svc/main.go"},
+		{"/src/github.com/acme/vendor/lib/lib.go", "vendor/lib/lib.go"},
+		{"/opt/build-1/main.go", "build/main.go"},
+		{"/opt/build-12/main.go", "/opt/build-12/main.go"},
+		{"/usr/local/go/src/runtime/proc.go", "/usr/local/go/src/runtime/proc.go"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.rewrite(tt.file), "Unexpected rewrite of %v.", tt.file)
+	}
+
+	r, err = newCallerPathRewriter(map[string]string{"/src/[/": "", "/src/": "src/"})
+	assert.Error(t, err, "Expected an error for an invalid wildcard.")
+	assert.Equal(t, "src/main.go", r.rewrite("/src/main.go"), "Expected valid prefixes to be kept.")
+}
+
+func TestLoggerRewriteCallerPaths(t *testing.T) {
+	_, file, _, ok := runtime.Caller(0)
+	require.True(t, ok, "Failed to get caller.")
+	dir := filepath.ToSlash(filepath.Dir(file)) + "/"
+
+	withLogger(t, DebugLevel, opts(AddCaller(), RewriteCallerPaths(map[string]string{dir: "zap/"})), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("")
+		output := logs.AllUntimed()
+		require.Equal(t, 1, len(output), "Unexpected number of logs written out.")
+		assert.Equal(t, "zap/caller_path_test.go", output[0].Entry.Caller.File, "Expected caller path to be rewritten.")
+	})
+}
+
+func TestConfigCallerPathPrefixes(t *testing.T) {
+	cfg := NewDevelopmentConfig()
+	cfg.CallerPathPrefixes = map[string]string{"/src/[/": ""}
+	_, err := cfg.Build()
+	assert.Error(t, err, "Expected an error building a logger with an invalid caller path prefix.")
+}
@@ -21,17 +21,25 @@
 package zap
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/blastbao/zap/zapcore"
+	"go.uber.org/multierr"
 )
 
 // SamplingConfig sets a sampling strategy for the logger. Sampling caps the
 // global CPU and I/O load that logging puts on your process while attempting
 // to preserve a representative subset of your logs.
 //
-// Values configured here are per-second. See zapcore.NewSampler for details.
+// Values configured here are per Tick, which defaults to one second. See
+// zapcore.NewSampler for details.
 //
 // Sampling 是对日志输出的保护功能，实现的效果是在 1s 的时间单位内，
 // 如果某个日志级别下同样内容的日志输出数量超过了 Initial 的数量，
@@ -39,6 +47,55 @@ import (
 type SamplingConfig struct {
 	Initial    int `json:"initial" yaml:"initial"`
 	Thereafter int `json:"thereafter" yaml:"thereafter"`
+
+	// Tick is the sampling interval. It defaults to one second.
+	Tick time.Duration `json:"tick" yaml:"tick"`
+
+	// Levels restricts sampling to the listed levels, such as "debug" and
+	// "info"; entries at other levels are never dropped. By default, every
+	// level is sampled.
+	Levels []string `json:"levels" yaml:"levels"`
+
+	// Exclude lists regular expressions for messages that are never
+	// sampled, such as audit records.
+	Exclude []string `json:"exclude" yaml:"exclude"`
+}
+
+// tick resolves the Tick default.
+func (s SamplingConfig) tick() time.Duration {
+	if s.Tick <= 0 {
+		return time.Second
+	}
+	return s.Tick
+}
+
+// wrapper builds the sampling core wrapper described by the config.
+func (s SamplingConfig) wrapper() (func(zapcore.Core) zapcore.Core, error) {
+	var opts []zapcore.SamplerOption
+	if len(s.Levels) > 0 {
+		levels := make([]zapcore.Level, len(s.Levels))
+		for i, name := range s.Levels {
+			if err := levels[i].UnmarshalText([]byte(name)); err != nil {
+				return nil, fmt.Errorf("invalid sampling level %q: %v", name, err)
+			}
+		}
+		opts = append(opts, zapcore.SampleLevels(levels...))
+	}
+	if len(s.Exclude) > 0 {
+		patterns := make([]*regexp.Regexp, len(s.Exclude))
+		for i, expr := range s.Exclude {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sampling exclude pattern %q: %v", expr, err)
+			}
+			patterns[i] = re
+		}
+		opts = append(opts, zapcore.SampleExclude(patterns...))
+	}
+	tick := s.tick()
+	return func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, tick, s.Initial, s.Thereafter, opts...)
+	}, nil
 }
 
 // Config offers a declarative way to construct a logger. It doesn't do
@@ -69,6 +126,12 @@ type Config struct {
 	// 可以支持配置成字符串“DEBUG”，“INFO”等，这一点很方便，实现也有一点小技巧，后面说。
 	Level AtomicLevel `json:"level" yaml:"level"`
 
+	// NameLevels raises the level of named loggers, mapping glob patterns
+	// for logger names to level names, as in {"net.*": "warn"}. Entries from
+	// loggers whose names match a pattern are dropped unless they're at or
+	// above its level. See zapcore.NewNameFilterCore for details.
+	NameLevels map[string]string `json:"nameLevels" yaml:"nameLevels"`
+
 	// Development puts the logger in development mode, which changes the
 	// behavior of DPanicLevel and takes stacktraces more liberally.
 	//
@@ -81,6 +144,15 @@ type Config struct {
 	// 用来标记是否开启行号和文件名显示功能。
 	DisableCaller bool `json:"disableCaller" yaml:"disableCaller"`
 
+	// CallerSkip is the number of extra stack frames to skip when annotating
+	// logs with their caller, for loggers wrapped by helper libraries. See
+	// AddCallerSkip.
+	CallerSkip int `json:"callerSkip" yaml:"callerSkip"`
+
+	// CallerPathPrefixes rewrites the file paths of callers, replacing each
+	// prefix in the map with its value. See RewriteCallerPaths.
+	CallerPathPrefixes map[string]string `json:"callerPathPrefixes" yaml:"callerPathPrefixes"`
+
 	// DisableStacktrace completely disables automatic stacktrace capturing. By
 	// default, stacktraces are captured for WarnLevel and above logs in
 	// development and ErrorLevel and above in production.
@@ -95,6 +167,15 @@ type Config struct {
 	// 那么超过之后，每隔 Thereafter 的数量，才会再输出一次。是一个对日志输出的保护功能。
 	Sampling *SamplingConfig `json:"sampling" yaml:"sampling"`
 
+	// CoreWrappers lists core middlewares, by the names they were registered
+	// under with RegisterCoreWrapper, to apply to the logger's Core. The first
+	// entry is outermost, so entries pass through the wrappers in order. An
+	// entry may carry an argument after a colon, as in "redact:password".
+	//
+	// If "sampling" is listed, Sampling only configures it and isn't applied
+	// separately.
+	CoreWrappers []string `json:"coreWrappers" yaml:"coreWrappers"`
+
 	// Encoding sets the logger's encoding. Valid values are "json" and
 	// "console", as well as any third-party encodings registered via RegisterEncoder.
 	//
@@ -117,6 +198,13 @@ type Config struct {
 	OutputPaths []string `json:"outputPaths" yaml:"outputPaths"`
 
 
+	// Outputs lists further groups of outputs, each of which may have its
+	// own encoding. Entries are written to OutputPaths with Encoding and
+	// EncoderConfig, and to every group in Outputs with its own settings.
+	// See OutputConfig for an example.
+	Outputs []OutputConfig `json:"outputs" yaml:"outputs"`
+
+
 	// ErrorOutputPaths is a list of URLs to write internal logger errors to.
 	// The default is standard error.
 	//
@@ -127,6 +215,19 @@ type Config struct {
 	// 与 OutputPaths 类似，不过指定的是系统内错误日志的输出地址，不是业务的错误（ERROR）日志。
 	ErrorOutputPaths []string `json:"errorOutputPaths" yaml:"errorOutputPaths"`
 
+	// ErrorOutputRate limits how many internal errors are written to
+	// ErrorOutputPaths each second, so that a persistently failing sink
+	// doesn't flood them; errors over the limit are counted and summarized
+	// instead (see ThrottleErrorOutput and Logger.InternalErrors). Zero or a
+	// negative rate, the default, writes every internal error.
+	ErrorOutputRate int `json:"errorOutputRate" yaml:"errorOutputRate"`
+
+	// Transport configures TLS and proxying for network sinks in OutputPaths
+	// and ErrorOutputPaths. It only applies to the built-in network schemes
+	// and those registered with RegisterTransportSink; see TransportConfig
+	// for details.
+	Transport TransportConfig `json:"transport" yaml:"transport"`
+
 
 	// InitialFields is a collection of fields to add to the root logger.
 	//
@@ -221,31 +322,62 @@ func NewDevelopmentConfig() Config {
 // Build constructs a logger from the Config and Options.
 func (cfg Config) Build(opts ...Option) (*Logger, error) {
 
-	// 构造日志的编码器，cfg.buildEncoder() 实现中会用到 cfg.Encoding, cfg.EncoderConfig 这两个配置。
-	enc, err := cfg.buildEncoder()
+	// 构造每个输出路由的编码器，cfg.buildRoutes() 实现中会用到 cfg.Encoding, cfg.EncoderConfig 以及 cfg.Outputs 中的配置。
+	routes, err := cfg.buildRoutes()
 	if err != nil {
 		return nil, err
 	}
 
+	// 解析 cfg.CoreWrappers 中声明的 Core 中间件
+	wrappers, err := cfg.buildCoreWrappers()
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查调用者路径的改写规则
+	if _, err := newCallerPathRewriter(cfg.CallerPathPrefixes); err != nil {
+		return nil, err
+	}
+
+	// 检查按名称过滤的级别配置
+	if _, err := cfg.nameFilter(); err != nil {
+		return nil, err
+	}
 
-	// 构造日志的输出对象，在 cfg.openSinks 的实现中，使用配置的输出路径 cfg.OutputPaths ，生成了两个 WriteSyncer 接口，用作 `日志输出` 和 `内部错误输出` 。
-	sink, errSink, err := cfg.openSinks()
+	// 检查采样配置
+	if cfg.Sampling != nil {
+		if _, err := cfg.Sampling.wrapper(); err != nil {
+			return nil, err
+		}
+	}
+
+
+	// 构造日志的输出对象，在 cfg.openSinks 的实现中，使用各路由的输出路径，为每个路由生成一个 WriteSyncer 用作 `日志输出` ，另外生成一个用作 `内部错误输出` 。
+	sinks, errSink, closeSinks, err := cfg.openSinks(routes)
 	if err != nil {
 		return nil, err
 	}
 
+	// 每个路由对应一个 ioCore ，多个路由时用 Tee 组合起来。
+	cores := make([]zapcore.Core, len(routes))
+	for i, r := range routes {
+		cores[i] = zapcore.NewCore(r.enc, sinks[i], cfg.Level)
+	}
 
 	// 将 Core 结构体 和 Option 作为参数调用 New 方法，这个方法会返回一个Logger。
 	log := New(
 
-		// 调用 NewCore 方法创建一个 ioCore 结构体，该结构体实现了 Core 接口
-		zapcore.NewCore(enc, sink, cfg.Level),
+		// 调用 NewTee 方法组合各路由的 Core ，只有一个路由时返回它本身
+		zapcore.NewTee(cores...),
 
 		// 调用 buildOptions 方法，将 Config 结构体转化成了 Option 接口数组
-		cfg.buildOptions(errSink)...,
+		cfg.buildOptions(errSink, wrappers)...,
 
 	)
 
+	// Logger.Shutdown 时关闭打开的输出
+	log = log.WithOptions(closeOnShutdown(closeSinks))
+
 	// 如果调用 Build 时还带有其他的 Option 参数，就调用 WithOptions 方法使这些Option生效
 	if len(opts) > 0 {
 		log = log.WithOptions(opts...)
@@ -255,13 +387,40 @@ func (cfg Config) Build(opts ...Option) (*Logger, error) {
 	return log, nil
 }
 
+// nameFilter builds the core wrapper described by NameLevels, or returns nil
+// if there's none.
+func (cfg Config) nameFilter() (func(zapcore.Core) zapcore.Core, error) {
+	if len(cfg.NameLevels) == 0 {
+		return nil, nil
+	}
+	levels := make(map[string]zapcore.Level, len(cfg.NameLevels))
+	for pattern, name := range cfg.NameLevels {
+		var lvl zapcore.Level
+		if err := lvl.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("invalid level %q for logger name pattern %q: %v", name, pattern, err)
+		}
+		levels[pattern] = lvl
+	}
+	// Check the patterns now, so that wrapping the Core can't fail.
+	if _, err := zapcore.NewNameFilterCore(zapcore.NewNopCore(), levels); err != nil {
+		return nil, err
+	}
+	return func(core zapcore.Core) zapcore.Core {
+		filtered, _ := zapcore.NewNameFilterCore(core, levels)
+		return filtered
+	}, nil
+}
+
 //
-func (cfg Config) buildOptions(errSink zapcore.WriteSyncer) []Option {
+func (cfg Config) buildOptions(errSink zapcore.WriteSyncer, wrappers []func(zapcore.Core) zapcore.Core) []Option {
 
 
 	opts := []Option{
 		ErrorOutput(errSink),
 	}
+	if rate := cfg.ErrorOutputRate; rate > 0 {
+		opts = append(opts, ThrottleErrorOutput(rate, time.Second))
+	}
 
 	// 开发者模式
 	if cfg.Development {
@@ -272,6 +431,12 @@ func (cfg Config) buildOptions(errSink zapcore.WriteSyncer) []Option {
 	if !cfg.DisableCaller {
 		opts = append(opts, AddCaller())
 	}
+	if cfg.CallerSkip != 0 {
+		opts = append(opts, AddCallerSkip(cfg.CallerSkip))
+	}
+	if len(cfg.CallerPathPrefixes) > 0 {
+		opts = append(opts, RewriteCallerPaths(cfg.CallerPathPrefixes))
+	}
 
 	// 日志级别
 	stackLevel := ErrorLevel
@@ -284,18 +449,25 @@ func (cfg Config) buildOptions(errSink zapcore.WriteSyncer) []Option {
 		opts = append(opts, AddStacktrace(stackLevel)) // AddStacktrace(level) 用来对指定的日志等级增加调用栈输出能力。
 	}
 
-	// 采样功能
-	if cfg.Sampling != nil {
-		opts = append(opts,
-			WrapCore(
-				//
-				func(core zapcore.Core) zapcore.Core {
-					return zapcore.NewSampler(core, time.Second, int(cfg.Sampling.Initial), int(cfg.Sampling.Thereafter))
-				},
-			),
-		)
+	// 采样功能，若 CoreWrappers 中声明了 "sampling" 则由其负责；配置错误已在 Build 中检查过
+	if cfg.Sampling != nil && !cfg.hasCoreWrapper("sampling") {
+		if sample, err := cfg.Sampling.wrapper(); err == nil {
+			opts = append(opts, WrapCore(sample))
+		}
+	}
+
+
+	// Core 中间件
+	if len(wrappers) > 0 {
+		opts = append(opts, WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.Wrap(core, wrappers...)
+		}))
 	}
 
+	// 按 logger 名称过滤，放在最外层，被过滤的日志不会进入采样等中间件；配置错误已在 Build 中检查过
+	if filter, err := cfg.nameFilter(); err == nil && filter != nil {
+		opts = append(opts, WrapCore(filter))
+	}
 
 	// 初始字段
 	if len(cfg.InitialFields) > 0 {
@@ -323,24 +495,261 @@ func (cfg Config) buildOptions(errSink zapcore.WriteSyncer) []Option {
 	return opts
 }
 
-func (cfg Config) openSinks() (zapcore.WriteSyncer, zapcore.WriteSyncer, error) {
+// outputRoute is a set of output paths sharing an encoder.
+type outputRoute struct {
+	enc   zapcore.Encoder
+	paths []string
+}
 
-	// 调用 Open 方法，打开日志输出路径，返回 sink
-	sink, closeOut, err := Open(cfg.OutputPaths...)
-	if err != nil {
-		return nil, nil, err
+// buildRoutes builds an encoder for OutputPaths and for each of Outputs.
+// OutputPaths gets a route unless it's empty and Outputs isn't, so that a
+// Config without outputs still validates its encoding.
+func (cfg Config) buildRoutes() ([]outputRoute, error) {
+	var routes []outputRoute
+	if len(cfg.OutputPaths) > 0 || len(cfg.Outputs) == 0 {
+		enc, err := cfg.buildEncoder()
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, outputRoute{enc, cfg.OutputPaths})
 	}
+	for i, out := range cfg.Outputs {
+		enc, err := out.buildEncoder(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("outputs[%d]: %v", i, err)
+		}
+		routes = append(routes, outputRoute{enc, out.Paths})
+	}
+	return routes, nil
+}
 
-	// 调用 Open 方法，打开错误输出路径，返回 errSink
-	errSink, _, err := Open(cfg.ErrorOutputPaths...)
+func (cfg Config) openSinks(routes []outputRoute) ([]zapcore.WriteSyncer, zapcore.WriteSyncer, func() error, error) {
+
+	var (
+		sinks    = make([]zapcore.WriteSyncer, len(routes))
+		closers  []func() error
+		closeAll = func() error {
+			var err error
+			for _, c := range closers {
+				err = multierr.Append(err, c())
+			}
+			return err
+		}
+	)
+
+	// 调用 open 方法，依次打开每个路由的日志输出路径，返回 sink
+	for i, r := range routes {
+		ws, closeOut, err := open(cfg.Transport.applyToPaths(r.paths))
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, err
+		}
+		sinks[i] = CombineWriteSyncers(ws...)
+		closers = append(closers, closeOut)
+	}
+
+	// 调用 open 方法，打开错误输出路径，返回 errSink
+	errSinks, closeErr, err := open(cfg.Transport.applyToPaths(cfg.ErrorOutputPaths))
 	if err != nil {
-		closeOut()
-		return nil, nil, err
+		closeAll()
+		return nil, nil, nil, err
 	}
 
-	return sink, errSink, nil
+	// 关闭时先关闭日志输出，最后再关闭错误输出，以便前者的错误仍能被记录。
+	closers = append(closers, closeErr)
+	return sinks, CombineWriteSyncers(errSinks...), closeAll, nil
 }
 
 func (cfg Config) buildEncoder() (zapcore.Encoder, error) {
 	return newEncoder(cfg.Encoding, cfg.EncoderConfig)
 }
+
+// OutputConfig describes a group of outputs with their own encoding, so that
+// one logger can, for example, write colored console output to standard
+// error and JSON to a file:
+//
+//	cfg := zap.NewProductionConfig()
+//	cfg.OutputPaths = nil
+//	cfg.Outputs = []zap.OutputConfig{
+//		{Paths: []string{"stderr"}, Encoding: "console"},
+//		{Paths: []string{"/var/log/app.json"}, Encoding: "json"},
+//	}
+//
+// Every group sees the same entries; levels, sampling, and core wrappers
+// apply to the logger as a whole.
+type OutputConfig struct {
+	// Paths is a list of URLs or file paths, as in Config.OutputPaths.
+	Paths []string `json:"paths" yaml:"paths"`
+	// Encoding names the encoder for these paths. If empty, Config.Encoding
+	// is used.
+	Encoding string `json:"encoding" yaml:"encoding"`
+	// EncoderConfig, if set, replaces Config.EncoderConfig for these paths.
+	EncoderConfig *zapcore.EncoderConfig `json:"encoderConfig" yaml:"encoderConfig"`
+}
+
+// resolve fills in the encoding and encoder configuration inherited from
+// cfg.
+func (out OutputConfig) resolve(cfg Config) (string, zapcore.EncoderConfig) {
+	encoding, ec := out.Encoding, cfg.EncoderConfig
+	if encoding == "" {
+		encoding = cfg.Encoding
+	}
+	if out.EncoderConfig != nil {
+		ec = *out.EncoderConfig
+	}
+	return encoding, ec
+}
+
+func (out OutputConfig) buildEncoder(cfg Config) (zapcore.Encoder, error) {
+	return newEncoder(out.resolve(cfg))
+}
+
+// Explain writes a human-readable description of the pipeline that Build
+// would construct from the Config: the encoder and its keys, the level,
+// caller and stacktrace settings, core wrappers such as sampling, and every
+// output and error output. It's meant for deployment tooling that validates
+// logging configuration before rolling it out.
+//
+// Each sink is opened and immediately closed to check that it's usable (for
+// network sinks, this usually means that the server is reachable), but no log
+// entries are written. Problems are reported inline, and the returned error
+// combines all of them.
+func (cfg Config) Explain(w io.Writer) error {
+	var (
+		buf    bytes.Buffer
+		errs   error
+		report = func(what string, err error) {
+			if err == nil {
+				fmt.Fprintf(&buf, "%s: ok\n", what)
+				return
+			}
+			fmt.Fprintf(&buf, "%s: error: %v\n", what, err)
+			errs = multierr.Append(errs, fmt.Errorf("%s: %v", strings.TrimSpace(what), err))
+		}
+	)
+
+	// As in Build, the top-level encoding is only needed for OutputPaths.
+	if len(cfg.OutputPaths) > 0 || len(cfg.Outputs) == 0 {
+		_, err := cfg.buildEncoder()
+		report("encoding "+cfg.Encoding, err)
+		fmt.Fprintf(&buf, "  keys: %s\n", explainKeys(cfg.EncoderConfig))
+	}
+
+	if cfg.Level.l == nil {
+		report("level", errors.New("no level configured"))
+	} else {
+		fmt.Fprintf(&buf, "level: %v\n", cfg.Level.Level())
+	}
+	if len(cfg.NameLevels) > 0 {
+		if _, err := cfg.nameFilter(); err != nil {
+			report("nameLevels", err)
+		} else {
+			patterns := make([]string, 0, len(cfg.NameLevels))
+			for pattern, lvl := range cfg.NameLevels {
+				patterns = append(patterns, pattern+"="+lvl)
+			}
+			sort.Strings(patterns)
+			fmt.Fprintf(&buf, "nameLevels: %s\n", strings.Join(patterns, ", "))
+		}
+	}
+	fmt.Fprintf(&buf, "development: %v\n", cfg.Development)
+	fmt.Fprintf(&buf, "caller: %v\n", !cfg.DisableCaller)
+	if !cfg.DisableCaller && cfg.CallerSkip != 0 {
+		fmt.Fprintf(&buf, "  skip: %d\n", cfg.CallerSkip)
+	}
+	if _, err := newCallerPathRewriter(cfg.CallerPathPrefixes); err != nil {
+		report("callerPathPrefixes", err)
+	}
+
+	if cfg.DisableStacktrace {
+		fmt.Fprintf(&buf, "stacktrace: disabled\n")
+	} else if cfg.Development {
+		fmt.Fprintf(&buf, "stacktrace: %v and above\n", WarnLevel)
+	} else {
+		fmt.Fprintf(&buf, "stacktrace: %v and above\n", ErrorLevel)
+	}
+
+	var wrappers []string
+	if cfg.Sampling != nil && !cfg.hasCoreWrapper("sampling") {
+		desc := fmt.Sprintf("sampler(tick=%v, initial=%d, thereafter=%d",
+			cfg.Sampling.tick(), cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+		if len(cfg.Sampling.Levels) > 0 {
+			desc += ", levels=" + strings.Join(cfg.Sampling.Levels, "|")
+		}
+		if len(cfg.Sampling.Exclude) > 0 {
+			desc += fmt.Sprintf(", exclude=%q", cfg.Sampling.Exclude)
+		}
+		wrappers = append(wrappers, desc+")")
+		if _, err := cfg.Sampling.wrapper(); err != nil {
+			report("sampling", err)
+		}
+	}
+	wrappers = append(wrappers, cfg.CoreWrappers...)
+	if len(wrappers) == 0 {
+		fmt.Fprintf(&buf, "wrappers: none\n")
+	} else if _, err := cfg.buildCoreWrappers(); err != nil {
+		report("wrappers "+strings.Join(wrappers, ", "), err)
+	} else {
+		fmt.Fprintf(&buf, "wrappers: %s\n", strings.Join(wrappers, ", "))
+	}
+
+	if len(cfg.InitialFields) > 0 {
+		keys := make([]string, 0, len(cfg.InitialFields))
+		for k := range cfg.InitialFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&buf, "initial fields: %s\n", strings.Join(keys, ", "))
+	}
+
+	explainSinks := func(title string, paths []string) {
+		fmt.Fprintf(&buf, "%s:\n", title)
+		if len(paths) == 0 {
+			fmt.Fprintf(&buf, "  (none)\n")
+			return
+		}
+		resolved := cfg.Transport.applyToPaths(paths)
+		for i, path := range paths {
+			sink, err := newSink(resolved[i])
+			if err == nil {
+				err = sink.Close()
+			}
+			report("  "+path, err)
+		}
+	}
+	explainSinks("outputs", cfg.OutputPaths)
+	for i, out := range cfg.Outputs {
+		encoding, ec := out.resolve(cfg)
+		_, err := out.buildEncoder(cfg)
+		report(fmt.Sprintf("outputs[%d] encoding %s", i, encoding), err)
+		fmt.Fprintf(&buf, "  keys: %s\n", explainKeys(ec))
+		explainSinks(fmt.Sprintf("outputs[%d]", i), out.Paths)
+	}
+	explainSinks("errorOutputs", cfg.ErrorOutputPaths)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	return errs
+}
+
+// explainKeys lists the non-empty keys of an EncoderConfig.
+func explainKeys(ec zapcore.EncoderConfig) string {
+	var parts []string
+	for _, k := range []struct{ name, key string }{
+		{"message", ec.MessageKey},
+		{"level", ec.LevelKey},
+		{"time", ec.TimeKey},
+		{"name", ec.NameKey},
+		{"caller", ec.CallerKey},
+		{"stacktrace", ec.StacktraceKey},
+	} {
+		if k.key != "" {
+			parts = append(parts, k.name+"="+k.key)
+		}
+	}
+	if len(parts) == 0 {
+		return "(none)"
+	}
+	return strings.Join(parts, " ")
+}
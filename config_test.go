@@ -21,12 +21,20 @@
 package zap
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
 )
 
 func TestConfig(t *testing.T) {
@@ -85,6 +93,59 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestConfigOutputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-outputs-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	jsonPath := filepath.Join(dir, "app.json")
+	consolePath := filepath.Join(dir, "app.log")
+	defaultPath := filepath.Join(dir, "default.json")
+
+	consoleCfg := NewDevelopmentEncoderConfig()
+	consoleCfg.TimeKey = ""
+	cfg := NewProductionConfig()
+	cfg.EncoderConfig.TimeKey = ""
+	cfg.DisableCaller = true
+	cfg.OutputPaths = []string{defaultPath}
+	cfg.Outputs = []OutputConfig{
+		{Paths: []string{consolePath}, Encoding: "console", EncoderConfig: &consoleCfg},
+		{Paths: []string{jsonPath}},
+	}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error constructing logger.")
+	logger.Info("hello", String("k", "v"))
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	read := func(path string) string {
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err, "Failed to read %v.", path)
+		return string(contents)
+	}
+	assert.Equal(t, `{"level":"info","msg":"hello","k":"v"}`+"\n", read(defaultPath), "Unexpected output from OutputPaths.")
+	assert.Equal(t, "INFO\thello\t{\"k\": \"v\"}\n", read(consolePath), "Unexpected console output.")
+	assert.Equal(t, read(defaultPath), read(jsonPath), "Expected outputs without an encoding to inherit it.")
+}
+
+func TestConfigOutputsErrors(t *testing.T) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.Encoding = ""
+	cfg.Outputs = []OutputConfig{{Paths: []string{"stderr"}, Encoding: "json"}}
+	_, err := cfg.Build()
+	assert.NoError(t, err, "Expected the top-level encoding to be optional without OutputPaths.")
+
+	cfg.Outputs = append(cfg.Outputs, OutputConfig{Paths: []string{"stderr"}, Encoding: "bogus"})
+	_, err = cfg.Build()
+	if assert.Error(t, err, "Expected an error for an unknown encoding.") {
+		assert.Contains(t, err.Error(), "outputs[1]", "Expected the error to name the output.")
+	}
+
+	cfg.Outputs = []OutputConfig{{Paths: []string{"/tmp/not-there/foo.log"}}}
+	cfg.Encoding = "json"
+	_, err = cfg.Build()
+	assert.Error(t, err, "Expected an error opening a non-existent directory.")
+}
+
 func TestConfigWithInvalidPaths(t *testing.T) {
 	tests := []struct {
 		desc      string
@@ -106,3 +167,202 @@ func TestConfigWithInvalidPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigExplain(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-explain-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{"stdout", temp.Name()}
+	cfg.InitialFields = map[string]interface{}{"z": 1, "a": 2}
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Explain(&out), "Unexpected error explaining config.")
+	assert.Equal(t, strings.Join([]string{
+		"encoding json: ok",
+		"  keys: message=msg level=level time=ts name=logger caller=caller stacktrace=stacktrace",
+		"level: info",
+		"development: false",
+		"caller: true",
+		"stacktrace: error and above",
+		"wrappers: sampler(tick=1s, initial=100, thereafter=100)",
+		"initial fields: a, z",
+		"outputs:",
+		"  stdout: ok",
+		"  " + temp.Name() + ": ok",
+		"errorOutputs:",
+		"  stderr: ok",
+		"",
+	}, "\n"), out.String(), "Unexpected explanation.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read temp file.")
+	assert.Empty(t, contents, "Expected Explain not to write any log entries.")
+}
+
+func TestConfigExplainOutputs(t *testing.T) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.Outputs = []OutputConfig{
+		{Paths: []string{"stderr"}, Encoding: "console"},
+		{Paths: []string{"stdout"}, Encoding: "bogus"},
+	}
+
+	var out bytes.Buffer
+	err := cfg.Explain(&out)
+	assert.Error(t, err, "Expected an error for the unknown encoding.")
+	assert.NotContains(t, out.String(), "encoding json", "Expected the unused top-level encoding to be skipped.")
+	assert.Contains(t, out.String(), "outputs[0] encoding console: ok\n", "Expected the first output's encoding.")
+	assert.Contains(t, out.String(), "outputs[0]:\n  stderr: ok\n", "Expected the first output's paths.")
+	assert.Contains(t, out.String(), `outputs[1] encoding bogus: error: no encoder registered for name "bogus"`, "Expected the second output's error.")
+}
+
+func TestConfigExplainErrors(t *testing.T) {
+	cfg := Config{
+		Encoding:          "bogus",
+		DisableStacktrace: true,
+		CoreWrappers:      []string{"bogus"},
+		OutputPaths:       []string{"/foo/bar/baz"},
+		ErrorOutputPaths:  []string{"unknown://sink"},
+	}
+
+	var out bytes.Buffer
+	err := cfg.Explain(&out)
+	require.Error(t, err, "Expected an error explaining an invalid config.")
+	assert.Len(t, multierr.Errors(err), 5, "Expected an error for the encoder, level, wrappers, and each sink.")
+
+	for _, want := range []string{
+		`encoding bogus: error: no encoder registered for name "bogus"`,
+		"level: error: no level configured",
+		"stacktrace: disabled",
+		`wrappers bogus: error: no core wrapper registered for name "bogus"`,
+		"  /foo/bar/baz: error: ",
+		"  unknown://sink: error: no sink found for scheme",
+	} {
+		assert.Contains(t, out.String(), want, "Missing line in explanation.")
+	}
+}
+
+func TestConfigSampling(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-sampling-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{temp.Name()}
+	cfg.Sampling = &SamplingConfig{
+		Initial:    1,
+		Thereafter: 100,
+		Tick:       time.Hour,
+		Levels:     []string{"info"},
+		Exclude:    []string{"^audit"},
+	}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+	for i := 0; i < 5; i++ {
+		logger.Info("sampled")
+		logger.Info("audit record")
+		logger.Warn("never sampled")
+	}
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Equal(t, 1, strings.Count(string(contents), `"msg":"sampled"`), "Expected info entries to be sampled.")
+	assert.Equal(t, 5, strings.Count(string(contents), `"msg":"audit record"`), "Expected excluded messages not to be sampled.")
+	assert.Equal(t, 5, strings.Count(string(contents), `"msg":"never sampled"`), "Expected other levels not to be sampled.")
+
+	var out bytes.Buffer
+	cfg.OutputPaths = nil
+	cfg.Explain(&out)
+	assert.Contains(t, out.String(), `wrappers: sampler(tick=1h0m0s, initial=1, thereafter=100, levels=info, exclude=["^audit"])`, "Unexpected explanation.")
+
+	for _, bad := range []SamplingConfig{
+		{Levels: []string{"loud"}},
+		{Exclude: []string{"("}},
+	} {
+		cfg.Sampling = &bad
+		_, err := cfg.Build()
+		assert.Error(t, err, "Expected an error building with sampling config %+v.", bad)
+	}
+}
+
+func TestConfigNameLevels(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-name-levels-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{temp.Name()}
+	cfg.NameLevels = map[string]string{"net.*": "warn", "db": "error"}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+	logger.Info("root info")
+	logger.Named("net").Named("http").Info("net info")
+	logger.Named("net").Named("http").Warn("net warn")
+	logger.Named("db").Warn("db warn")
+	logger.Named("db").Error("db error")
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	for _, msg := range []string{"root info", "net warn", "db error"} {
+		assert.Contains(t, string(contents), `"msg":"`+msg+`"`, "Expected %q to be logged.", msg)
+	}
+	for _, msg := range []string{"net info", "db warn"} {
+		assert.NotContains(t, string(contents), `"msg":"`+msg+`"`, "Expected %q to be filtered out.", msg)
+	}
+
+	var out bytes.Buffer
+	cfg.OutputPaths = nil
+	cfg.Explain(&out)
+	assert.Contains(t, out.String(), "nameLevels: db=error, net.*=warn\n", "Unexpected explanation.")
+
+	for _, bad := range []map[string]string{
+		{"net.*": "loud"},
+		{"net.[": "warn"},
+	} {
+		cfg.NameLevels = bad
+		_, err := cfg.Build()
+		assert.Error(t, err, "Expected an error building with name levels %v.", bad)
+	}
+}
+
+func TestConfigCallerSkip(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	cfg := NewDevelopmentConfig()
+	cfg.CallerSkip = 1
+	logger, err := cfg.Build(WrapCore(func(zapcore.Core) zapcore.Core { return core }))
+	require.NoError(t, err, "Unexpected error building logger.")
+
+	logger.Info("")
+	require.Equal(t, 1, logs.Len(), "Expected an entry.")
+	assert.Regexp(t, `.+/src/testing/.*:[\d]+$`, logs.AllUntimed()[0].Entry.Caller, "Expected the test's caller.")
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Explain(&out), "Unexpected error explaining config.")
+	assert.Contains(t, out.String(), "caller: true\n  skip: 1\n", "Expected the caller skip to be explained.")
+}
+
+func TestConfigErrorOutputRate(t *testing.T) {
+	tests := []struct {
+		rate      int
+		throttled bool
+	}{
+		{0, false},
+		{5, true},
+		{-1, false},
+	}
+	for _, tt := range tests {
+		cfg := NewProductionConfig()
+		cfg.ErrorOutputRate = tt.rate
+		logger, err := cfg.Build()
+		require.NoError(t, err, "Unexpected error building logger.")
+		_, ok := logger.rawErrorOutput().(*zapcore.ThrottledWriteSyncer)
+		assert.Equal(t, tt.throttled, ok, "Unexpected throttling with rate %d.", tt.rate)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "context"
+
+// loggerContextKey is the context key for the Logger stored by IntoContext.
+type loggerContextKey struct{}
+
+// fieldsContextKey is the context key for the fields added by
+// WithContextFields, which Loggers bound to the context pick up.
+type fieldsContextKey struct{}
+
+// IntoContext returns a copy of ctx that carries log. Code handling the
+// request can retrieve it with FromContext instead of passing the Logger
+// along explicitly, much like a mapped diagnostic context (MDC) in other
+// ecosystems.
+func IntoContext(ctx context.Context, log *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, log)
+}
+
+// FromContext returns the Logger stored in ctx by IntoContext or
+// WithContextFields. If there's none, it returns the global Logger (see L),
+// so it's always safe to use.
+func FromContext(ctx context.Context) *Logger {
+	if log, ok := ctx.Value(loggerContextKey{}).(*Logger); ok && log != nil {
+		return log
+	}
+	return L()
+}
+
+// WithContext returns a copy of the Logger bound to ctx. Entries it logs
+// carry ctx (see zapcore.Entry.Context), so that network sinks delivering
+// them synchronously give up once ctx's deadline passes instead of holding
+// up the request that's logging. Binding a context adds the fields stored in
+// ctx by WithContextFields and those the Logger's context extractors (see
+// ContextExtractor) return for ctx, and nothing else. Fields the Logger
+// already has, for example from binding a parent of ctx or from
+// FromContext(ctx), aren't added again.
+func (log *Logger) WithContext(ctx context.Context) *Logger {
+	return log.with(log.contextFields(ctx)).bind(ctx)
+}
+
+// Ctx returns a copy of the SugaredLogger bound to ctx, with the fields from
+// WithContextFields and the Logger's context extractors added, so that
+// request-scoped fields reach sugared call sites too:
+//
+//	sugar.Ctx(ctx).Infow("handled request", "status", 200)
+//
+// See Logger.WithContext for details.
+func (s *SugaredLogger) Ctx(ctx context.Context) *SugaredLogger {
+	return &SugaredLogger{base: s.base.with(s.base.contextFields(ctx)).bind(ctx)}
+}
+
+// bind returns a copy of the Logger bound to ctx.
+func (log *Logger) bind(ctx context.Context) *Logger {
+	l := log.clone()
+	l.ctx = ctx
+	return l
+}
+
+// contextFields returns the fields stored in ctx by WithContextFields and
+// those the Logger's context extractors return, minus any the Logger
+// already has.
+func (log *Logger) contextFields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsContextKey{}).([]Field)
+	for _, extract := range log.contextExtractors {
+		fields = appendFields(fields, extract(ctx))
+	}
+	if log.fields.Len() == 0 {
+		return fields
+	}
+	out := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		if !log.fields.has(f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func hasField(fields []Field, f Field) bool {
+	for _, existing := range fields {
+		if existing.Equals(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithContextFields returns a copy of ctx carrying the Logger from
+// FromContext(ctx) with the given fields added, so that every entry logged
+// through FromContext further down the call chain includes them. Loggers
+// bound to the context with Logger.WithContext or SugaredLogger.Ctx include
+// them too:
+//
+//	func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+//		ctx := zap.WithContextFields(r.Context(), zap.String("request_id", requestID(r)))
+//		s.handle(ctx, w, r)
+//	}
+//
+//	func (s *server) handle(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+//		zap.FromContext(ctx).Info("handling request") // includes request_id
+//	}
+func WithContextFields(ctx context.Context, fields ...Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	prev, _ := ctx.Value(fieldsContextKey{}).([]Field)
+	log := FromContext(ctx).With(fields...)
+	ctx = context.WithValue(ctx, fieldsContextKey{}, appendFields(prev, fields))
+	return IntoContext(ctx, log)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextLogger(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		ctx := IntoContext(context.Background(), logger)
+		assert.Equal(t, logger, FromContext(ctx), "Expected to retrieve the stored logger.")
+
+		ctx = WithContextFields(ctx, String("request_id", "abc"))
+		ctx = WithContextFields(ctx, Int("attempt", 2))
+		assert.Equal(t, ctx, WithContextFields(ctx), "Expected no fields to leave the context unchanged.")
+
+		FromContext(ctx).Info("handled", Bool("ok", true))
+		FromContext(context.Background()).Info("global")
+		require.Equal(t, 1, logs.Len(), "Expected only the context logger to write to the observer.")
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc",
+			"attempt":    int64(2),
+			"ok":         true,
+		}, logs.All()[0].ContextMap(), "Expected request-scoped fields on every entry.")
+	})
+}
+
+func TestFromContextFallsBackToGlobal(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		defer ReplaceGlobals(logger)()
+
+		FromContext(context.Background()).Info("global")
+		FromContext(IntoContext(context.Background(), nil)).Info("nil logger")
+		WithContextFields(context.Background(), String("k", "v")) // mustn't panic
+		assert.Equal(t, 2, logs.Len(), "Expected the global logger without a context logger.")
+	})
+}
+
+func TestLoggerWithContext(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		logger.WithContext(ctx).Info("bound")
+		logger.Info("unbound")
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, ctx, entries[0].Entry.Context, "Expected the bound context on the entry.")
+		assert.Nil(t, entries[1].Entry.Context, "Expected the parent logger to stay unbound.")
+		assert.Empty(t, entries[0].ContextMap(), "Expected binding a context not to add fields.")
+	})
+}
+
+type requestIDKey struct{}
+
+func TestContextExtractor(t *testing.T) {
+	requestID := ContextExtractor(func(ctx context.Context) []Field {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return []Field{String("request_id", id)}
+		}
+		return nil
+	})
+	deadline := ContextExtractor(func(ctx context.Context) []Field {
+		if _, ok := ctx.Deadline(); ok {
+			return []Field{Bool("deadline", true)}
+		}
+		return nil
+	})
+
+	withLogger(t, DebugLevel, opts(AddCaller(), requestID, deadline), func(logger *Logger, logs *observer.ObservedLogs) {
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), requestIDKey{}, "abc"), time.Minute)
+		defer cancel()
+
+		logger.WithContext(ctx).Info("structured")
+		logger.Sugar().Ctx(ctx).Infow("sugared", "k", 1)
+		logger.Sugar().Ctx(context.Background()).Info("empty context")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 3, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc",
+			"deadline":   true,
+		}, entries[0].ContextMap(), "Expected extracted fields on structured entries.")
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc",
+			"deadline":   true,
+			"k":          int64(1),
+		}, entries[1].ContextMap(), "Expected extracted fields on sugared entries.")
+		assert.Equal(t, ctx, entries[1].Entry.Context, "Expected the sugared logger to be bound to the context.")
+		assert.Regexp(t, `/context_test.go:\d+$`, entries[1].Entry.Caller.String(), "Expected the sugared call site as the caller.")
+		assert.Empty(t, entries[2].ContextMap(), "Expected no fields from a context without values.")
+		assert.Empty(t, logger.Fields(), "Expected the parent logger to be unchanged.")
+	})
+}
+
+func TestContextFieldsReachBoundLoggers(t *testing.T) {
+	requestID := ContextExtractor(func(ctx context.Context) []Field {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return []Field{String("request_id", id)}
+		}
+		return nil
+	})
+
+	withLogger(t, DebugLevel, opts(requestID), func(logger *Logger, logs *observer.ObservedLogs) {
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+		ctx = WithContextFields(ctx, String("user", "alice"))
+		ctx = WithContextFields(ctx, Int("attempt", 2))
+
+		logger.Sugar().Ctx(ctx).Infow("sugared")
+		logger.WithContext(ctx).WithContext(ctx).Info("rebound")
+		child, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		logger.Sugar().Ctx(ctx).Ctx(child).Info("rebound to a child")
+
+		want := []Field{String("request_id", "abc"), String("user", "alice"), Int("attempt", 2)}
+		entries := logs.AllUntimed()
+		require.Equal(t, 3, len(entries), "Unexpected number of entries.")
+		for _, ent := range entries {
+			assert.ElementsMatch(t, want, ent.Context, "Expected each context field exactly once in %q.", ent.Message)
+		}
+	})
+}
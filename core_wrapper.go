@@ -0,0 +1,194 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// A CoreWrapperFactory builds a core middleware from a Config. arg is
+// whatever follows the first colon in the wrapper's entry in
+// Config.CoreWrappers, or the empty string; for example, the entry
+// "redact:password,token" calls the "redact" factory with the argument
+// "password,token".
+type CoreWrapperFactory func(cfg Config, arg string) (func(zapcore.Core) zapcore.Core, error)
+
+var (
+	errNoCoreWrapperNameSpecified = errors.New("no core wrapper name specified")
+
+	_coreWrapperMetrics zapcore.CoreMetrics
+
+	_coreWrapperFactories = map[string]CoreWrapperFactory{
+		"sampling":  newSamplingWrapper,
+		"redact":    newRedactWrapper,
+		"metrics":   newMetricsWrapper,
+		"maxFields": newMaxFieldsWrapper,
+		"partition": newPartitionWrapper,
+		"profiling": newProfilingWrapper,
+	}
+	_coreWrapperMutex sync.RWMutex
+)
+
+// RegisterCoreWrapper registers a core middleware factory, which the
+// CoreWrappers section of a Config can then reference by name. By default,
+// the following wrappers are registered:
+//
+//   - "sampling" samples entries according to Config.Sampling, or keeps the
+//     first 100 entries with the same level and message each second and
+//     every 100th after that if it's nil.
+//   - "redact:key1,key2,..." replaces the values of the listed fields with
+//     zapcore.RedactedValue.
+//   - "metrics" counts entries in CoreWrapperMetrics.
+//   - "maxFields:N" caps the number of fields in each entry at N, dropping
+//     the rest. See zapcore.NewFieldLimitCore.
+//   - "partition:key=layout" adds a field holding each entry's partition
+//     key, its time formatted with layout, as in "partition:dt=2006-01-02".
+//     See zapcore.NewPartitionKeyCore.
+//   - "profiling[:level]" annotates entries at or above level (by default,
+//     all of them) with their context's pprof labels and records them in
+//     runtime/trace execution traces. See zapcore.CorrelateProfiles.
+//
+// Attempting to register a wrapper whose name is already taken returns an
+// error.
+func RegisterCoreWrapper(name string, factory CoreWrapperFactory) error {
+	_coreWrapperMutex.Lock()
+	defer _coreWrapperMutex.Unlock()
+	if name == "" {
+		return errNoCoreWrapperNameSpecified
+	}
+	if strings.Contains(name, ":") {
+		return fmt.Errorf("core wrapper names may not contain colons: got %q", name)
+	}
+	if _, ok := _coreWrapperFactories[name]; ok {
+		return fmt.Errorf("core wrapper already registered for name %q", name)
+	}
+	_coreWrapperFactories[name] = factory
+	return nil
+}
+
+// CoreWrapperMetrics returns the counters maintained by the "metrics" core
+// wrapper. They're shared by every logger built with it.
+func CoreWrapperMetrics() *zapcore.CoreMetrics {
+	return &_coreWrapperMetrics
+}
+
+// buildCoreWrappers resolves the CoreWrappers section of a Config, in order.
+func (cfg Config) buildCoreWrappers() ([]func(zapcore.Core) zapcore.Core, error) {
+	wrappers := make([]func(zapcore.Core) zapcore.Core, 0, len(cfg.CoreWrappers))
+	for _, spec := range cfg.CoreWrappers {
+		name, arg := spec, ""
+		if i := strings.IndexByte(spec, ':'); i >= 0 {
+			name, arg = spec[:i], spec[i+1:]
+		}
+
+		_coreWrapperMutex.RLock()
+		factory, ok := _coreWrapperFactories[name]
+		_coreWrapperMutex.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("no core wrapper registered for name %q", name)
+		}
+
+		w, err := factory(cfg, arg)
+		if err != nil {
+			return nil, fmt.Errorf("can't build core wrapper %q: %v", spec, err)
+		}
+		wrappers = append(wrappers, w)
+	}
+	return wrappers, nil
+}
+
+// hasCoreWrapper reports whether the CoreWrappers section mentions name.
+func (cfg Config) hasCoreWrapper(name string) bool {
+	for _, spec := range cfg.CoreWrappers {
+		if spec == name || strings.HasPrefix(spec, name+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func newSamplingWrapper(cfg Config, arg string) (func(zapcore.Core) zapcore.Core, error) {
+	if arg != "" {
+		return nil, errors.New("sampling takes no arguments; configure it with the sampling section")
+	}
+	sampling := SamplingConfig{Initial: 100, Thereafter: 100}
+	if cfg.Sampling != nil {
+		sampling = *cfg.Sampling
+	}
+	return sampling.wrapper()
+}
+
+func newRedactWrapper(_ Config, arg string) (func(zapcore.Core) zapcore.Core, error) {
+	var keys []string
+	for _, k := range strings.Split(arg, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("redact needs a comma-separated list of keys, as in redact:password,token")
+	}
+	return zapcore.Redact(keys...), nil
+}
+
+func newMetricsWrapper(_ Config, arg string) (func(zapcore.Core) zapcore.Core, error) {
+	if arg != "" {
+		return nil, errors.New("metrics takes no arguments")
+	}
+	return zapcore.Metrics(&_coreWrapperMetrics), nil
+}
+
+func newMaxFieldsWrapper(_ Config, arg string) (func(zapcore.Core) zapcore.Core, error) {
+	max, err := strconv.Atoi(arg)
+	if err != nil || max <= 0 {
+		return nil, errors.New("maxFields needs a positive limit, as in maxFields:64")
+	}
+	return func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewFieldLimitCore(core, max)
+	}, nil
+}
+
+func newPartitionWrapper(_ Config, arg string) (func(zapcore.Core) zapcore.Core, error) {
+	i := strings.IndexByte(arg, '=')
+	if i <= 0 || i == len(arg)-1 {
+		return nil, errors.New("partition needs a key and a layout, as in partition:dt=2006-01-02")
+	}
+	key, layout := arg[:i], arg[i+1:]
+	return func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewPartitionKeyCore(core, key, layout)
+	}, nil
+}
+
+func newProfilingWrapper(_ Config, arg string) (func(zapcore.Core) zapcore.Core, error) {
+	lvl := DebugLevel
+	if arg != "" {
+		if err := lvl.UnmarshalText([]byte(arg)); err != nil {
+			return nil, fmt.Errorf("profiling takes an optional minimum level: %v", err)
+		}
+	}
+	return zapcore.CorrelateProfiles(lvl), nil
+}
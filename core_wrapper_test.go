@@ -0,0 +1,173 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterCoreWrapper(t *testing.T) {
+	defer func(saved map[string]CoreWrapperFactory) { _coreWrapperFactories = saved }(_coreWrapperFactories)
+	_coreWrapperFactories = map[string]CoreWrapperFactory{}
+
+	factory := func(Config, string) (func(zapcore.Core) zapcore.Core, error) {
+		return func(c zapcore.Core) zapcore.Core { return c }, nil
+	}
+	assert.NoError(t, RegisterCoreWrapper("nop", factory), "Unexpected error registering a wrapper.")
+	assert.Error(t, RegisterCoreWrapper("nop", factory), "Expected an error registering a duplicate name.")
+	assert.Equal(t, errNoCoreWrapperNameSpecified, RegisterCoreWrapper("", factory), "Expected an error registering an empty name.")
+	assert.Error(t, RegisterCoreWrapper("a:b", factory), "Expected an error registering a name with a colon.")
+}
+
+func TestConfigCoreWrappers(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-wrappers-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	before := CoreWrapperMetrics().Written(InfoLevel)
+	cfg := NewProductionConfig()
+	cfg.CoreWrappers = []string{"sampling", "metrics", "redact:password, token"}
+	cfg.OutputPaths = []string{temp.Name()}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+
+	logger.With(String("token", "abc")).Info("login", String("password", "hunter2"), String("user", "alice"))
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+	assert.Equal(t, uint64(1), CoreWrapperMetrics().Written(InfoLevel)-before, "Expected the metrics wrapper to count the entry.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Contains(t, string(contents), `"token":"[REDACTED]"`, "Expected fields added with With to be redacted.")
+	assert.Contains(t, string(contents), `"password":"[REDACTED]"`, "Expected log-site fields to be redacted.")
+	assert.Contains(t, string(contents), `"user":"alice"`, "Expected other fields to be kept.")
+
+	// Listing sampling replaces the implicit sampler rather than adding one.
+	cfg.CoreWrappers = []string{"sampling"}
+	assert.True(t, cfg.hasCoreWrapper("sampling"), "Expected sampling to be found.")
+	assert.Equal(t, 3, len(cfg.buildOptions(nil, nil)), "Expected no implicit sampler when sampling is listed.")
+}
+
+func TestConfigMaxFieldsWrapper(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-wrappers-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewProductionConfig()
+	cfg.CoreWrappers = []string{"maxFields:2"}
+	cfg.OutputPaths = []string{temp.Name()}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+
+	logger.With(Int("a", 1)).Info("hello", Int("b", 2), Int("c", 3))
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Contains(t, string(contents), `"a":1,"b":2,"droppedFields":1}`, "Expected the extra field to be dropped.")
+	assert.Contains(t, string(contents), `"msg":"dropped fields over the per-entry limit","a":1,"limit":2}`, "Expected a warning.")
+}
+
+func TestConfigPartitionWrapper(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-wrappers-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewProductionConfig()
+	cfg.CoreWrappers = []string{"partition:hour=2006-01-02T15:00"}
+	cfg.OutputPaths = []string{temp.Name()}
+	logger, err := cfg.Build(WithClock(ztest.NewMockClock(time.Date(2024, 5, 17, 9, 30, 0, 0, time.UTC))))
+	require.NoError(t, err, "Unexpected error building logger.")
+
+	logger.Info("hello")
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Contains(t, string(contents), `"hour":"2024-05-17T09:00"}`, "Expected a partition key.")
+}
+
+func TestConfigProfilingWrapper(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-wrappers-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewProductionConfig()
+	cfg.CoreWrappers = []string{"profiling:warn"}
+	cfg.OutputPaths = []string{temp.Name()}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("worker", "3"))
+	logger.WithContext(ctx).Info("unlabeled")
+	logger.WithContext(ctx).Warn("labeled")
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2, "Expected two entries.")
+	assert.NotContains(t, lines[0], "pprofLabels", "Expected no labels below the level.")
+	assert.Contains(t, lines[1], `"pprofLabels":{"worker":"3"}`, "Expected pprof labels.")
+}
+
+func TestConfigCoreWrappersErrors(t *testing.T) {
+	tests := []struct {
+		wrappers []string
+		err      string
+	}{
+		{[]string{"bogus"}, `no core wrapper registered for name "bogus"`},
+		{[]string{"redact"}, "redact needs a comma-separated list of keys"},
+		{[]string{"redact: , "}, "redact needs a comma-separated list of keys"},
+		{[]string{"metrics:fast"}, "metrics takes no arguments"},
+		{[]string{"sampling:10"}, "sampling takes no arguments"},
+		{[]string{"maxFields"}, "maxFields needs a positive limit"},
+		{[]string{"maxFields:0"}, "maxFields needs a positive limit"},
+		{[]string{"partition"}, "partition needs a key and a layout"},
+		{[]string{"partition:dt"}, "partition needs a key and a layout"},
+		{[]string{"partition:=2006"}, "partition needs a key and a layout"},
+		{[]string{"partition:dt="}, "partition needs a key and a layout"},
+		{[]string{"profiling:loud"}, "profiling takes an optional minimum level"},
+	}
+	for _, tt := range tests {
+		cfg := NewProductionConfig()
+		cfg.CoreWrappers = tt.wrappers
+		_, err := cfg.Build()
+		if assert.Error(t, err, "Expected an error building with wrappers %v.", tt.wrappers) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error message.")
+		}
+	}
+}
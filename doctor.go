@@ -0,0 +1,412 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	// DoctorProbeMessage is the message of the probe entries that Doctor
+	// writes to outputs.
+	DoctorProbeMessage = "zap doctor probe"
+
+	_doctorMinFreeSpace = 100 << 20 // 100 MiB
+	// _doctorTailBytes is how much of the end of a file output Doctor reads
+	// back to find its probe.
+	_doctorTailBytes = 64 << 10
+)
+
+// A DoctorOption configures Doctor.
+type DoctorOption interface {
+	apply(*doctor)
+}
+
+type doctorOptionFunc func(*doctor)
+
+func (f doctorOptionFunc) apply(d *doctor) {
+	f(d)
+}
+
+// DoctorWriteProbes makes Doctor write a probe entry, with
+// DoctorProbeMessage as its message, through every output and error output,
+// sync and close it, and for files read the probe back. Since the outputs
+// may be in use by running applications and watched by alerting, probes are
+// only written when asked for.
+func DoctorWriteProbes() DoctorOption {
+	return doctorOptionFunc(func(d *doctor) {
+		d.writeProbes = true
+	})
+}
+
+// DoctorMinFreeSpace sets how much free space, in bytes, the file system
+// holding a file output should have; Doctor warns about outputs with less.
+// The default is 100 MiB.
+func DoctorMinFreeSpace(bytes uint64) DoctorOption {
+	return doctorOptionFunc(func(d *doctor) {
+		d.minFree = bytes
+	})
+}
+
+// A DoctorCheck is the outcome of one of the checks that Doctor makes.
+type DoctorCheck struct {
+	// Name describes what was checked, such as "config" or
+	// "output /var/log/app.log".
+	Name string
+	// Err is the problem that failed the check, or nil if it passed.
+	Err error
+	// Warnings describe problems that don't stop logging yet, such as low
+	// disk space.
+	Warnings []string
+	// Details describe what was found, such as how long a probe took to
+	// write.
+	Details []string
+}
+
+// A DoctorReport is the result of Doctor.
+type DoctorReport struct {
+	Checks []DoctorCheck
+}
+
+// OK reports whether every check passed. Warnings don't fail checks.
+func (r DoctorReport) OK() bool {
+	return r.Err() == nil
+}
+
+// Err combines the errors of all the failed checks.
+func (r DoctorReport) Err() error {
+	var err error
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			err = multierr.Append(err, fmt.Errorf("%s: %v", c.Name, c.Err))
+		}
+	}
+	return err
+}
+
+// String formats the report for people, one check per line, followed by
+// its warnings and details.
+func (r DoctorReport) String() string {
+	var buf bytes.Buffer
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			fmt.Fprintf(&buf, "%s: error: %v\n", c.Name, c.Err)
+		} else {
+			fmt.Fprintf(&buf, "%s: ok\n", c.Name)
+		}
+		for _, w := range c.Warnings {
+			fmt.Fprintf(&buf, "  warning: %s\n", w)
+		}
+		for _, d := range c.Details {
+			fmt.Fprintf(&buf, "  %s\n", d)
+		}
+	}
+	return buf.String()
+}
+
+// Doctor diagnoses a logging configuration, answering the question "why
+// aren't my logs showing up?" in one call. It checks that Build would
+// accept the Config, then checks every output and error output without
+// touching it: file outputs are checked for permissions and free disk space
+// without being created, and other outputs are only checked for a
+// registered sink, since opening one may connect to a server or, for
+// outputs like walqueue, take over state that a running application is
+// using. Use DoctorWriteProbes to open every output and write a probe entry
+// through it.
+//
+// Doctor doesn't build a Logger, so it has no effect on the outputs other
+// than the probes it's asked to write.
+func Doctor(cfg Config, opts ...DoctorOption) DoctorReport {
+	d := &doctor{cfg: cfg, minFree: _doctorMinFreeSpace}
+	for _, opt := range opts {
+		opt.apply(d)
+	}
+	return d.run()
+}
+
+type doctor struct {
+	cfg         Config
+	writeProbes bool
+	minFree     uint64
+	report      DoctorReport
+}
+
+func (d *doctor) run() DoctorReport {
+	cfg := d.cfg
+	config := DoctorCheck{Name: "config"}
+	var errs error
+	if cfg.Level.l == nil {
+		errs = multierr.Append(errs, errors.New("no level configured"))
+	} else {
+		config.Details = append(config.Details, fmt.Sprintf("level: %v", cfg.Level.Level()))
+	}
+	if _, err := newCallerPathRewriter(cfg.CallerPathPrefixes); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	if cfg.Sampling != nil {
+		if _, err := cfg.Sampling.wrapper(); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	if _, err := cfg.nameFilter(); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	if _, err := cfg.buildCoreWrappers(); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	type route struct {
+		title string
+		enc   zapcore.Encoder
+		paths []string
+	}
+	var routes []route
+	if len(cfg.OutputPaths) > 0 || len(cfg.Outputs) == 0 {
+		enc, err := cfg.buildEncoder()
+		errs = multierr.Append(errs, err)
+		routes = append(routes, route{"output", enc, cfg.OutputPaths})
+	}
+	for i, out := range cfg.Outputs {
+		enc, err := out.buildEncoder(cfg)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("outputs[%d]: %v", i, err))
+		}
+		routes = append(routes, route{fmt.Sprintf("outputs[%d]", i), enc, out.Paths})
+	}
+	config.Err = errs
+	d.report.Checks = append(d.report.Checks, config)
+
+	for _, r := range routes {
+		d.checkSinks(r.title, r.enc, r.paths)
+	}
+	d.checkSinks("error output", nil, cfg.ErrorOutputPaths)
+	return d.report
+}
+
+// checkSinks checks each of paths. Probes are encoded with enc, or written
+// as plain text if enc is nil.
+func (d *doctor) checkSinks(title string, enc zapcore.Encoder, paths []string) {
+	if len(paths) == 0 {
+		d.report.Checks = append(d.report.Checks, DoctorCheck{
+			Name:     title + "s",
+			Warnings: []string{"none configured"},
+		})
+		return
+	}
+	resolved := d.cfg.Transport.applyToPaths(paths)
+	for i, path := range paths {
+		check := DoctorCheck{Name: title + " " + path}
+		check.Err = d.checkSink(&check, enc, resolved[i])
+		d.report.Checks = append(d.report.Checks, check)
+	}
+}
+
+func (d *doctor) checkSink(check *DoctorCheck, enc zapcore.Encoder, rawURL string) error {
+	file, err := localFile(rawURL)
+	if err != nil {
+		return err
+	}
+	if file != "" {
+		if err := d.checkFile(check, file); err != nil {
+			return err
+		}
+		if !d.writeProbes {
+			return nil
+		}
+	}
+	if !d.writeProbes {
+		return checkScheme(check, rawURL)
+	}
+
+	start := time.Now()
+	sink, err := newSink(rawURL)
+	if err != nil {
+		return err
+	}
+	check.Details = append(check.Details, fmt.Sprintf("opened in %v", time.Since(start)))
+
+	id, probe, err := newProbe(enc)
+	if err != nil {
+		sink.Close()
+		return err
+	}
+	start = time.Now()
+	_, err = sink.Write(probe)
+	err = multierr.Combine(err, sink.Sync(), sink.Close())
+	if err != nil {
+		return fmt.Errorf("can't write probe: %v", err)
+	}
+	check.Details = append(check.Details, fmt.Sprintf("probe %s written in %v", id, time.Since(start)))
+
+	if file != "" {
+		if err := findProbe(file, id); err != nil {
+			return err
+		}
+		check.Details = append(check.Details, "probe read back")
+	}
+	return nil
+}
+
+// checkFile checks that file can be written to, without creating it, and
+// that the file system holding it has space.
+func (d *doctor) checkFile(check *DoctorCheck, file string) error {
+	dir := filepath.Dir(file)
+	if info, err := os.Stat(file); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", file)
+		}
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("can't open for writing: %v", err)
+		}
+		f.Close()
+		check.Details = append(check.Details, fmt.Sprintf("exists (%d bytes)", info.Size()))
+	} else if os.IsNotExist(err) {
+		if info, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("can't use directory: %v", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s isn't a directory", dir)
+		}
+		// The file will be created, so check that the directory is
+		// writable.
+		f, err := ioutil.TempFile(dir, ".zap-doctor-")
+		if err != nil {
+			return fmt.Errorf("can't create files in %s: %v", dir, err)
+		}
+		f.Close()
+		os.Remove(f.Name())
+		check.Details = append(check.Details, "doesn't exist yet, but can be created")
+	} else {
+		return err
+	}
+
+	if free, ok := diskFree(dir); ok {
+		check.Details = append(check.Details, fmt.Sprintf("free space: %d MiB", free>>20))
+		if free < d.minFree {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("only %d MiB free on the file system", free>>20))
+		}
+	}
+	return nil
+}
+
+// checkScheme checks that a sink is registered for a non-file output,
+// without opening it.
+func checkScheme(check *DoctorCheck, rawURL string) error {
+	u, err := parseSinkURL(rawURL)
+	if err != nil {
+		return fmt.Errorf("can't parse %q as a URL: %v", rawURL, err)
+	}
+	if u.Scheme == "" || u.Scheme == schemeFile {
+		// Standard output or error.
+		return nil
+	}
+	_sinkMutex.RLock()
+	_, ok := _sinkFactories[u.Scheme]
+	_sinkMutex.RUnlock()
+	if !ok {
+		return &errSinkNotFound{u.Scheme}
+	}
+	check.Warnings = append(check.Warnings, "not opened; use DoctorWriteProbes to check it")
+	return nil
+}
+
+// localFile returns the path of the file that a sink URL writes to, or ""
+// if it isn't a file (or is standard output or error).
+func localFile(rawURL string) (string, error) {
+	u, err := parseSinkURL(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("can't parse %q as a URL: %v", rawURL, err)
+	}
+	if u.Scheme != "" && u.Scheme != schemeFile {
+		return "", nil
+	}
+	path := filePath(u)
+	if path == "stdout" || path == "stderr" {
+		return "", nil
+	}
+	if expand := u.Query().Get("expandHome"); expand == "true" || expand == "1" {
+		return expandHome(path)
+	}
+	return path, nil
+}
+
+// newProbe builds a probe entry with a random ID, encoded with enc, or as
+// a line of plain text if enc is nil.
+func newProbe(enc zapcore.Encoder) (string, []byte, error) {
+	var raw [8]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", nil, err
+	}
+	id := hex.EncodeToString(raw[:])
+	now := time.Now()
+	if enc == nil {
+		return id, []byte(fmt.Sprintf("%v %s %s\n", now.UTC(), DoctorProbeMessage, id)), nil
+	}
+	ent := zapcore.Entry{
+		Level:      InfoLevel,
+		Time:       now,
+		LoggerName: "zap.doctor",
+		Message:    DoctorProbeMessage,
+	}
+	buf, err := enc.Clone().EncodeEntry(ent, []Field{String("probe", id)})
+	if buf == nil {
+		return "", nil, fmt.Errorf("can't encode probe: %v", err)
+	}
+	defer buf.Free()
+	return id, append([]byte(nil), buf.Bytes()...), nil
+}
+
+// findProbe checks that the end of file contains the probe with the given
+// ID.
+func findProbe(file, id string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("can't read probe back: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > _doctorTailBytes {
+		if _, err := f.Seek(-_doctorTailBytes, io.SeekEnd); err != nil {
+			return fmt.Errorf("can't read probe back: %v", err)
+		}
+	}
+	tail, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("can't read probe back: %v", err)
+	}
+	if !strings.Contains(string(tail), id) {
+		return fmt.Errorf("probe %s was written but isn't in the file", id)
+	}
+	return nil
+}
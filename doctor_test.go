@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doctorConfig(t testing.TB) (Config, string) {
+	dir, err := ioutil.TempDir("", "zap-doctor-test")
+	require.NoError(t, err, "Failed to create temp dir.")
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{filepath.Join(dir, "app.log")}
+	cfg.ErrorOutputPaths = []string{filepath.Join(dir, "errors.log")}
+	return cfg, dir
+}
+
+func findCheck(t testing.TB, r DoctorReport, name string) DoctorCheck {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("No check named %q in report:\n%v", name, r)
+	return DoctorCheck{}
+}
+
+func TestDoctor(t *testing.T) {
+	cfg, dir := doctorConfig(t)
+	defer os.RemoveAll(dir)
+
+	report := Doctor(cfg, DoctorWriteProbes())
+	require.True(t, report.OK(), "Unexpected problems:\n%v", report)
+	assert.NoError(t, report.Err(), "Unexpected error from a healthy report.")
+
+	out := findCheck(t, report, "output "+cfg.OutputPaths[0])
+	assert.Contains(t, out.Details, "probe read back", "Expected the probe to be verified.")
+	contents, err := ioutil.ReadFile(cfg.OutputPaths[0])
+	require.NoError(t, err, "Failed to read output.")
+	assert.Contains(t, string(contents), `"msg":"`+DoctorProbeMessage+`"`, "Expected a JSON probe entry.")
+	assert.Contains(t, string(contents), `"logger":"zap.doctor"`, "Expected the probe to be named.")
+
+	errOut := findCheck(t, report, "error output "+cfg.ErrorOutputPaths[0])
+	assert.Contains(t, errOut.Details, "probe read back", "Expected the error output probe to be verified.")
+	contents, err = ioutil.ReadFile(cfg.ErrorOutputPaths[0])
+	require.NoError(t, err, "Failed to read error output.")
+	assert.Contains(t, string(contents), DoctorProbeMessage, "Expected a plain-text probe.")
+
+	assert.Contains(t, report.String(), "config: ok\n", "Unexpected formatted report.")
+}
+
+func TestDoctorWithoutProbes(t *testing.T) {
+	cfg, dir := doctorConfig(t)
+	defer os.RemoveAll(dir)
+
+	report := Doctor(cfg)
+	require.True(t, report.OK(), "Unexpected problems:\n%v", report)
+	out := findCheck(t, report, "output "+cfg.OutputPaths[0])
+	assert.Contains(t, out.Details, "doesn't exist yet, but can be created", "Unexpected details.")
+	_, err := os.Stat(cfg.OutputPaths[0])
+	assert.True(t, os.IsNotExist(err), "Expected Doctor not to create the output without probes.")
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err, "Failed to list temp dir.")
+	assert.Empty(t, files, "Expected Doctor to leave nothing behind without probes.")
+}
+
+func TestDoctorDoesNotOpenOtherOutputs(t *testing.T) {
+	defer resetSinkRegistry()
+	opened := 0
+	require.NoError(t, RegisterSink("doctor", func(*url.URL) (Sink, error) {
+		opened++
+		return nopCloserSink{zapcore.AddSync(ioutil.Discard)}, nil
+	}), "Failed to register sink.")
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{"doctor://live", "unknown://host", "stdout"}
+	report := Doctor(cfg)
+	assert.Equal(t, 0, opened, "Expected Doctor not to open outputs without probes.")
+	assert.Equal(t, []string{"not opened; use DoctorWriteProbes to check it"}, findCheck(t, report, "output doctor://live").Warnings, "Unexpected warnings.")
+	assert.Contains(t, findCheck(t, report, "output unknown://host").Err.Error(), "no sink found", "Unexpected error for an unknown scheme.")
+	assert.NoError(t, findCheck(t, report, "output stdout").Err, "Expected stdout to be fine.")
+
+	report = Doctor(cfg, DoctorWriteProbes())
+	assert.Equal(t, 1, opened, "Expected DoctorWriteProbes to open outputs.")
+}
+
+func TestDoctorProblems(t *testing.T) {
+	cfg, dir := doctorConfig(t)
+	defer os.RemoveAll(dir)
+
+	cfg.Encoding = "bogus"
+	cfg.OutputPaths = []string{
+		filepath.Join(dir, "missing", "app.log"),
+		dir,
+		"unknown://host",
+	}
+	report := Doctor(cfg, DoctorWriteProbes())
+	require.False(t, report.OK(), "Expected problems.")
+
+	assert.Error(t, findCheck(t, report, "config").Err, "Expected the bad encoding to be reported.")
+	assert.Contains(t, findCheck(t, report, "output "+cfg.OutputPaths[0]).Err.Error(), "can't use directory", "Unexpected error for a missing directory.")
+	assert.Contains(t, findCheck(t, report, "output "+dir).Err.Error(), "is a directory", "Unexpected error for a directory.")
+	assert.Contains(t, findCheck(t, report, "output unknown://host").Err.Error(), "no sink found", "Unexpected error for an unknown scheme.")
+	assert.NoError(t, findCheck(t, report, "error output "+cfg.ErrorOutputPaths[0]).Err, "Expected the error output to be fine.")
+
+	err := report.Err()
+	require.Error(t, err, "Expected a combined error.")
+	assert.Equal(t, 4, strings.Count(err.Error(), ";")+1, "Expected every failed check in the combined error.")
+	assert.Contains(t, report.String(), "output "+dir+": error: ", "Unexpected formatted report.")
+}
+
+func TestDoctorLowDiskSpace(t *testing.T) {
+	cfg, dir := doctorConfig(t)
+	defer os.RemoveAll(dir)
+	if _, ok := diskFree(dir); !ok {
+		t.Skip("Free space isn't available on this platform.")
+	}
+
+	report := Doctor(cfg, DoctorMinFreeSpace(1<<62))
+	require.True(t, report.OK(), "Expected warnings not to fail checks.")
+	out := findCheck(t, report, "output "+cfg.OutputPaths[0])
+	require.Equal(t, 1, len(out.Warnings), "Expected a low disk space warning.")
+	assert.Contains(t, out.Warnings[0], "MiB free", "Unexpected warning.")
+}
+
+func TestDoctorNoOutputs(t *testing.T) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.ErrorOutputPaths = nil
+	report := Doctor(cfg)
+	assert.True(t, report.OK(), "Expected missing outputs to be warnings.")
+	assert.Equal(t, []string{"none configured"}, findCheck(t, report, "outputs").Warnings, "Unexpected warnings.")
+	assert.Equal(t, []string{"none configured"}, findCheck(t, report, "error outputs").Warnings, "Unexpected warnings.")
+}
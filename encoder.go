@@ -23,7 +23,9 @@ package zap
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/blastbao/zap/zapcore"
 )
@@ -44,19 +46,27 @@ var (
 			return zapcore.NewJSONEncoder(encoderConfig), nil
 		},
 
+		"gcp": func(encoderConfig zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return NewGCPEncoder(encoderConfig), nil
+		},
+
+		"journald": func(encoderConfig zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return NewJournaldEncoder(encoderConfig), nil
+		},
+
 	}
 	_encoderMutex sync.RWMutex
 )
 
 //RegisterEncoder registers an encoder constructor, which the Config struct
-//can then reference. By default, the "json" and "console" encoders are
-//registered.
+//can then reference. By default, the "json", "console", "gcp", and
+//"journald" encoders are registered.
 //
 //Attempting to register an encoder whose name is already taken returns an
 //error.
 
 // RegisterEncoder 注册一个编码器构造函数，然后配置结构可以引用该构造函数。
-// 默认情况下，“json” 和 “console” 编码器是注册的。
+// 默认情况下，“json”、“console”、“gcp” 和 “journald” 编码器是注册的。
 // 尝试注册一个名称已被采用的编码器将返回一个错误。
 func RegisterEncoder(name string, constructor func(zapcore.EncoderConfig) (zapcore.Encoder, error) ) error {
 	_encoderMutex.Lock()
@@ -71,6 +81,24 @@ func RegisterEncoder(name string, constructor func(zapcore.EncoderConfig) (zapco
 	return nil
 }
 
+// NewEncoder constructs the encoder registered under name, as Config does
+// for its Encoding. It returns an error if no such encoder is registered.
+func NewEncoder(name string, encoderConfig zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	return newEncoder(name, encoderConfig)
+}
+
+// RegisteredEncoders returns the names of all registered encoders, sorted.
+func RegisteredEncoders() []string {
+	_encoderMutex.RLock()
+	defer _encoderMutex.RUnlock()
+	names := make([]string, 0, len(_encoderNameToConstructor))
+	for name := range _encoderNameToConstructor {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func newEncoder(name string, encoderConfig zapcore.EncoderConfig) (zapcore.Encoder, error) {
 	_encoderMutex.RLock()
 	defer _encoderMutex.RUnlock()
@@ -81,5 +109,10 @@ func newEncoder(name string, encoderConfig zapcore.EncoderConfig) (zapcore.Encod
 	if !ok {
 		return nil, fmt.Errorf("no encoder registered for name %q", name)
 	}
+	if encoderConfig.TimeLocation != "" {
+		if _, err := time.LoadLocation(encoderConfig.TimeLocation); err != nil {
+			return nil, fmt.Errorf("invalid time location: %v", err)
+		}
+	}
 	return constructor(encoderConfig)
 }
@@ -29,7 +29,7 @@ import (
 )
 
 func TestRegisterDefaultEncoders(t *testing.T) {
-	testEncodersRegistered(t, "console", "json")
+	testEncodersRegistered(t, "console", "json", "gcp", "journald")
 }
 
 func TestRegisterEncoder(t *testing.T) {
@@ -59,6 +59,19 @@ func TestNewEncoder(t *testing.T) {
 	})
 }
 
+func TestExportedEncoderLookups(t *testing.T) {
+	testEncoders(func() {
+		RegisterEncoder("foo", newNilEncoder)
+		RegisterEncoder("bar", newNilEncoder)
+		assert.Equal(t, []string{"bar", "foo"}, RegisteredEncoders(), "Unexpected registered encoders.")
+
+		_, err := NewEncoder("foo", zapcore.EncoderConfig{})
+		assert.NoError(t, err, "Unexpected error constructing a registered encoder.")
+		_, err = NewEncoder("baz", zapcore.EncoderConfig{})
+		assert.Error(t, err, "Expected an error constructing an unregistered encoder.")
+	})
+}
+
 func TestNewEncoderNotRegistered(t *testing.T) {
 	_, err := newEncoder("foo", zapcore.EncoderConfig{})
 	assert.Error(t, err, "expected an error when trying to create an encoder of an unregistered name")
@@ -69,6 +82,13 @@ func TestNewEncoderNoName(t *testing.T) {
 	assert.Equal(t, errNoEncoderNameSpecified, err, "expected an error when creating an encoder with no name")
 }
 
+func TestNewEncoderTimeLocation(t *testing.T) {
+	_, err := newEncoder("json", zapcore.EncoderConfig{TimeLocation: "UTC"})
+	assert.NoError(t, err, "Unexpected error with a valid time location.")
+	_, err = newEncoder("json", zapcore.EncoderConfig{TimeLocation: "Not/AZone"})
+	assert.Error(t, err, "Expected an error with an unknown time location.")
+}
+
 func testEncoders(f func()) {
 	existing := _encoderNameToConstructor
 	_encoderNameToConstructor = make(map[string]func(zapcore.EncoderConfig) (zapcore.Encoder, error))
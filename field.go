@@ -21,6 +21,7 @@
 package zap
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math"
 	"time"
@@ -39,13 +40,78 @@ func Skip() Field {
 	}
 }
 
+// NoSample constructs a field that exempts its entry from sampling, so that
+// events such as audit or security records are always written by a sampled
+// Logger without keeping a separate, unsampled one. The field itself isn't
+// encoded, and doesn't count towards the sampler's limits:
+//
+//	logger.Info("permission changed", zap.String("user", user), zap.NoSample())
+//
+// Samplers see it when it's passed to a Logger's logging methods or a
+// SugaredLogger's, but not when it's added with With or written to a
+// CheckedEntry; use WithSampling with Check instead.
+func NoSample() Field {
+	return Field{Type: zapcore.SkipType, Interface: zapcore.SampleKeep}
+}
+
+// ForceSample constructs a field that makes samplers drop its entry, as if
+// it had been sampled out, for entries that are only worth writing when
+// sampling is off. Like NoSample, the field isn't encoded.
+func ForceSample() Field {
+	return Field{Type: zapcore.SkipType, Interface: zapcore.SampleDrop}
+}
+
+// samplingOverride returns the last sampling override among fields, or zero
+// if there's none.
+func samplingOverride(fields []Field) zapcore.SamplingOverride {
+	var o zapcore.SamplingOverride
+	for i := range fields {
+		if fields[i].Type != zapcore.SkipType {
+			continue
+		}
+		if v, ok := fields[i].Interface.(zapcore.SamplingOverride); ok {
+			o = v
+		}
+	}
+	return o
+}
+
+// OmitEmpty returns f, or a no-op field if f's value is empty (see
+// zapcore.Field.IsEmpty). It's useful for optional context that's often
+// unset:
+//
+//	logger.Info("request", zap.OmitEmpty(zap.String("user", userID)))
+//
+// To drop empty fields everywhere, or by key, see the OmitEmpty and
+// OmitEmptyKeys settings in zapcore.EncoderConfig.
+func OmitEmpty(f Field) Field {
+	if f.IsEmpty() {
+		return Skip()
+	}
+	return f
+}
+
+// If returns f if cond is true, or a no-op field otherwise. It keeps optional
+// fields inline at the log site:
+//
+//	logger.Info("request", zap.If(debugHeaders, zap.Any("headers", r.Header)))
+//
+// Like any argument, f is built whether or not cond holds, so guard fields
+// that are expensive to construct with Logger.Enabled instead.
+func If(cond bool, f Field) Field {
+	if !cond {
+		return Skip()
+	}
+	return f
+}
 
 // Binary constructs a field that carries an opaque binary blob.
 //
 // Binary data is serialized in an encoding-appropriate format.
 //
-// For example, zap's JSON encoder base64-encodes binary blobs.
-// To log UTF-8 encoded text, use ByteString.
+// For example, zap's JSON encoder base64-encodes binary blobs, unless
+// EncoderConfig.EncodeBinary selects another format. To log UTF-8 encoded
+// text, use ByteString.
 func Binary(key string, val []byte) Field {
 	return Field{
 		Key: key,
@@ -54,6 +120,18 @@ func Binary(key string, val []byte) Field {
 	}
 }
 
+// Hex constructs a field whose value is the bytes as a lowercase hex
+// string, whatever the encoder's EncodeBinary. The bytes are encoded lazily.
+func Hex(key string, val []byte) Field {
+	return Stringer(key, hexBytes(val))
+}
+
+type hexBytes []byte
+
+func (b hexBytes) String() string {
+	return hex.EncodeToString(b)
+}
+
 // Bool constructs a field that carries a bool.
 func Bool(key string, val bool) Field {
 	var ival int64
@@ -131,6 +209,24 @@ func String(key string, val string) Field {
 	return Field{Key: key, Type: zapcore.StringType, String: val}
 }
 
+// StringMax constructs a field with the given key and value, truncated to at
+// most max bytes. Truncated values end with a marker recording how many
+// bytes were cut, as in "abc...(+1021 bytes)"; see zapcore.TruncateString.
+// A non-positive max keeps the whole value. To cap every field, use
+// zapcore.EncoderConfig.MaxFieldBytes instead.
+func StringMax(key string, val string, max int) Field {
+	return String(key, zapcore.TruncateString(val, max))
+}
+
+// StringOmitEmpty constructs a field with the given key and value, or a
+// no-op field if the value is empty.
+func StringOmitEmpty(key string, val string) Field {
+	if val == "" {
+		return Skip()
+	}
+	return String(key, val)
+}
+
 // Uint constructs a field with the given key and value.
 func Uint(key string, val uint) Field {
 	return Uint64(key, uint64(val))
@@ -211,6 +307,58 @@ func Duration(key string, val time.Duration) Field {
 	return Field{Key: key, Type: zapcore.DurationType, Integer: int64(val)}
 }
 
+// Since constructs a field with the given key whose value is the time that
+// has passed since start, as a duration. The duration is measured for each
+// entry logged, even if the field was added with With, using the Logger's
+// clock (see WithClock) rather than time.Now, so it agrees with the entry's
+// timestamp:
+//
+//	start := time.Now()
+//	...
+//	logger.Info("request done", zap.Since("elapsed", start))
+func Since(key string, start time.Time) Field {
+	return Field{Key: key, Type: zapcore.SinceType, Integer: start.UnixNano()}
+}
+
+// Until constructs a field with the given key whose value is the time
+// remaining until deadline, as a duration, which is negative once the
+// deadline has passed. Like Since, it's measured with the Logger's clock
+// when the entry is logged.
+func Until(key string, deadline time.Time) Field {
+	return Field{Key: key, Type: zapcore.UntilType, Integer: deadline.UnixNano()}
+}
+
+// TimeWithLayout constructs a field with the given key whose value is the
+// time formatted with layout (see time.Time.Format), regardless of the
+// encoder's EncodeTime. This lets a record mix, say, epoch timestamps for
+// machines with a human-readable time. The time is formatted lazily.
+func TimeWithLayout(key string, val time.Time, layout string) Field {
+	return Stringer(key, layoutTime{val, layout})
+}
+
+type layoutTime struct {
+	t      time.Time
+	layout string
+}
+
+func (t layoutTime) String() string {
+	return t.t.Format(t.layout)
+}
+
+// DurationIn constructs a field with the given key whose value is the
+// duration as a (possibly fractional) number of units, regardless of the
+// encoder's EncodeDuration:
+//
+//	zap.DurationIn("latency_ms", 1500*time.Microsecond, time.Millisecond) // 1.5
+//
+// If unit isn't positive, it's the same as Duration.
+func DurationIn(key string, val time.Duration, unit time.Duration) Field {
+	if unit <= 0 {
+		return Duration(key, val)
+	}
+	return Float64(key, float64(val)/float64(unit))
+}
+
 // Object constructs a field with the given key and ObjectMarshaler. It
 // provides a flexible, but still type-safe and efficient, way to add map- or
 // struct-like user-defined types to the logging context. The struct's
@@ -219,6 +367,50 @@ func Object(key string, val zapcore.ObjectMarshaler) Field {
 	return Field{Key: key, Type: zapcore.ObjectMarshalerType, Interface: val}
 }
 
+// Inline constructs a field that is similar to Object, but it will add the
+// elements of the provided ObjectMarshaler to the current namespace, rather
+// than nesting them under a key. This is useful for logging conventions,
+// like ECS or Google Cloud's structured logging, that expect flat top-level
+// keys:
+//
+//	logger.Info("served request", zap.Inline(httpRequest))
+func Inline(val zapcore.ObjectMarshaler) Field {
+	return Field{Type: zapcore.InlineMarshalerType, Interface: val}
+}
+
+// Dict constructs a field containing the provided fields as a nested object.
+// It's a shortcut for one-off groupings that don't warrant a dedicated
+// ObjectMarshaler:
+//
+//	logger.Info("request", zap.Dict("user", zap.String("name", name), zap.Int("id", id)))
+func Dict(key string, fields ...Field) Field {
+	return Object(key, dictObject(fields))
+}
+
+type dictObject []Field
+
+func (d dictObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i := range d {
+		d[i].AddTo(enc)
+	}
+	return nil
+}
+
+// Lazy constructs a field with the given key whose value is computed by fn.
+// fn is only called if the entry is actually written (that is, it passes the
+// logger's level check and any sampling), so it's a good fit for values that
+// are expensive to compute and are logged at verbose levels. The result is
+// encoded as if it were passed to Any, and fn is called at most once.
+func Lazy(key string, fn func() interface{}) Field {
+	return Field{
+		Key:  key,
+		Type: zapcore.LazyType,
+		Interface: zapcore.NewLazyField(func() Field {
+			return Any(key, fn())
+		}),
+	}
+}
+
 
 
 
@@ -229,7 +421,10 @@ func Object(key string, val zapcore.ObjectMarshaler) Field {
 //
 // To minimize surprises, []byte values are treated as binary blobs, byte values are treated as uint8, and runes are always treated as integers.
 //
-//
+// Values of other named types whose underlying type is a boolean, an integer,
+// or a string are logged as that basic type, unless they implement
+// json.Marshaler or encoding.TextMarshaler. How to handle each such type is
+// worked out once and cached.
 func Any(key string, value interface{}) Field {
 
 
@@ -325,6 +520,6 @@ func Any(key string, value interface{}) Field {
 	case fmt.Stringer:
 		return Stringer(key, val)
 	default:
-		return Reflect(key, val)
+		return anyByType(key, val)
 	}
 }
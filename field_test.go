@@ -22,12 +22,16 @@ package zap
 
 import (
 	"net"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zaptest/observer"
 )
 
 type username string
@@ -37,6 +41,20 @@ func (n username) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	return nil
 }
 
+type (
+	userID   int64
+	quota    uint16
+	region   string
+	verified bool
+)
+
+// jsonID implements json.Marshaler, so Any must encode it with encoding/json.
+type jsonID int
+
+func (id jsonID) MarshalJSON() ([]byte, error) {
+	return []byte(`"id-` + strconv.Itoa(int(id)) + `"`), nil
+}
+
 func assertCanBeReused(t testing.TB, field Field) {
 	var wg sync.WaitGroup
 
@@ -139,7 +157,15 @@ func TestFieldConstructors(t *testing.T) {
 		{"Any:Duration", Any("k", time.Second), Duration("k", time.Second)},
 		{"Any:Durations", Any("k", []time.Duration{time.Second}), Durations("k", []time.Duration{time.Second})},
 		{"Any:Fallback", Any("k", struct{}{}), Reflect("k", struct{}{})},
+		{"Any:Nil", Any("k", nil), Reflect("k", nil)},
+		{"Any:NamedInt", Any("k", userID(42)), Int64("k", 42)},
+		{"Any:NamedUint", Any("k", quota(7)), Uint64("k", 7)},
+		{"Any:NamedString", Any("k", region("eu")), String("k", "eu")},
+		{"Any:NamedBool", Any("k", verified(true)), Bool("k", true)},
+		{"Any:NamedJSONMarshaler", Any("k", jsonID(1)), Reflect("k", jsonID(1))},
+		{"Any:NamedSlice", Any("k", []userID{1}), Reflect("k", []userID{1})},
 		{"Namespace", Namespace("k"), Field{Key: "k", Type: zapcore.NamespaceType}},
+		{"Inline", Inline(name), Field{Type: zapcore.InlineMarshalerType, Interface: name}},
 	}
 
 	for _, tt := range tests {
@@ -150,6 +176,24 @@ func TestFieldConstructors(t *testing.T) {
 	}
 }
 
+func TestOmitEmptyFields(t *testing.T) {
+	assert.Equal(t, Skip(), StringOmitEmpty("k", ""), "Expected empty strings to be skipped.")
+	assert.Equal(t, String("k", "v"), StringOmitEmpty("k", "v"), "Expected non-empty strings to be kept.")
+	assert.Equal(t, Skip(), OmitEmpty(Int("k", 0)), "Expected empty fields to be skipped.")
+	assert.Equal(t, Int("k", 1), OmitEmpty(Int("k", 1)), "Expected non-empty fields to be kept.")
+}
+
+func TestIfField(t *testing.T) {
+	assert.Equal(t, String("k", "v"), If(true, String("k", "v")), "Expected the field when the condition holds.")
+	assert.Equal(t, Skip(), If(false, String("k", "v")), "Expected a no-op field otherwise.")
+}
+
+func TestStringMax(t *testing.T) {
+	assert.Equal(t, String("k", "abc"), StringMax("k", "abc", 3), "Expected values that fit to be kept.")
+	assert.Equal(t, String("k", "ab...(+2 bytes)"), StringMax("k", "abcd", 2), "Expected long values to be truncated.")
+	assert.Equal(t, String("k", "abcd"), StringMax("k", "abcd", 0), "Expected a non-positive max to keep the value.")
+}
+
 func TestStackField(t *testing.T) {
 	f := Stack("stacktrace")
 	assert.Equal(t, "stacktrace", f.Key, "Unexpected field key.")
@@ -157,3 +201,94 @@ func TestStackField(t *testing.T) {
 	assert.Equal(t, takeStacktrace(), f.String, "Unexpected stack trace")
 	assertCanBeReused(t, f)
 }
+
+func TestLazyField(t *testing.T) {
+	calls := 0
+	expensive := func() interface{} {
+		calls++
+		return "computed"
+	}
+
+	withLogger(t, InfoLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Debug("dropped", Lazy("k", expensive))
+		assert.Equal(t, 0, calls, "Expected value not to be computed for a disabled level.")
+
+		f := Lazy("k", expensive)
+		logger.Info("kept", f)
+		entries := logs.AllUntimed()
+		require.Equal(t, 1, len(entries), "Unexpected number of logs.")
+		assert.Equal(t, map[string]interface{}{"k": "computed"}, entries[0].ContextMap(), "Unexpected encoded field.")
+		assertCanBeReused(t, f)
+		assert.Equal(t, 1, calls, "Expected value to be computed exactly once.")
+	})
+}
+
+func TestSinceUntilFields(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := ztest.NewMockClock(start)
+	withLogger(t, InfoLevel, opts(WithClock(clock)), func(logger *Logger, logs *observer.ObservedLogs) {
+		began := start.Add(-time.Second)
+		deadline := start.Add(time.Minute)
+		child := logger.With(Since("age", began))
+
+		clock.Add(time.Second)
+		child.Info("tick", Since("elapsed", began), Until("remaining", deadline))
+		clock.Add(2 * time.Minute)
+		logger.Info("late", Until("remaining", deadline))
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of logs.")
+		assert.Equal(t, map[string]interface{}{
+			"age":       2 * time.Second,
+			"elapsed":   2 * time.Second,
+			"remaining": 59 * time.Second,
+		}, entries[0].ContextMap(), "Expected durations measured with the logger's clock when logging, even for With fields.")
+		assert.Equal(t, map[string]interface{}{
+			"remaining": -61 * time.Second,
+		}, entries[1].ContextMap(), "Expected a negative duration past the deadline.")
+	})
+}
+
+func TestFieldFormatOverrides(t *testing.T) {
+	ts := time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC)
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		MessageKey:     "M",
+		EncodeTime:     zapcore.EpochTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	})
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "done"}, []Field{
+		Time("at", ts),
+		TimeWithLayout("at_human", ts, time.Kitchen),
+		Duration("took", 1500*time.Microsecond),
+		DurationIn("took_ms", 1500*time.Microsecond, time.Millisecond),
+		DurationIn("took_s", 2*time.Second, time.Second),
+		DurationIn("took_default", time.Second, 0),
+	})
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	assert.Equal(t,
+		`{"M":"done","at":1527856200,"at_human":"12:30PM","took":"1.5ms","took_ms":1.5,"took_s":2,"took_default":"1s"}`+"\n",
+		buf.String(),
+		"Expected per-field layouts and units to override the encoder's.",
+	)
+	assertCanBeReused(t, TimeWithLayout("at", ts, time.RFC822))
+}
+
+func TestDictField(t *testing.T) {
+	f := Dict("user", String("name", "phil"), Int("id", 42), Dict("prefs", Bool("dark", true)))
+	assert.Equal(t, zapcore.ObjectMarshalerType, f.Type, "Unexpected field type.")
+
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	assert.Equal(t, map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "phil",
+			"id":    int64(42),
+			"prefs": map[string]interface{}{"dark": true},
+		},
+	}, enc.Fields, "Unexpected encoded dict.")
+	assertCanBeReused(t, f)
+
+	enc = zapcore.NewMapObjectEncoder()
+	Dict("empty").AddTo(enc)
+	assert.Equal(t, map[string]interface{}{"empty": map[string]interface{}{}}, enc.Fields, "Expected an empty object.")
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"runtime"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// DefaultFingerprintKey is the key Fingerprint uses when it's given an empty
+// one.
+const DefaultFingerprintKey = "fingerprint"
+
+// Fingerprint adds a field, under key, holding a stable fingerprint of each
+// entry's log site: a hex-encoded 64-bit FNV-1a hash of the message template,
+// the Logger's name, and the calling function. Downstream systems can group
+// and deduplicate entries by it, much like Sentry groups events into issues,
+// without hashing their contents.
+//
+// For SugaredLogger's templated methods (Infof and friends), the template is
+// hashed rather than the formatted message, so entries from one call site
+// share a fingerprint whatever their arguments. Line numbers aren't hashed,
+// so fingerprints survive unrelated edits to the same file. Without the
+// AddCaller option, the caller can't be hashed, and log sites that share a
+// message and a Logger name share a fingerprint.
+func Fingerprint(key string) Option {
+	if key == "" {
+		key = DefaultFingerprintKey
+	}
+	return optionFunc(func(log *Logger) {
+		log.fingerprintKey = key
+	})
+}
+
+// withTemplate records the template a message was formatted from, so that
+// Fingerprint can hash it instead of the message.
+func withTemplate(template string) CheckOption {
+	return checkOptionFunc(func(cfg *checkConfig) {
+		cfg.template = template
+	})
+}
+
+// fingerprint hashes an entry's message template, Logger name, and caller.
+func fingerprint(template, name string, caller zapcore.EntryCaller) string {
+	h := fnv.New64a()
+	h.Write([]byte(template))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	if caller.Defined {
+		if fn := runtime.FuncForPC(caller.PC); fn != nil {
+			h.Write([]byte(fn.Name()))
+		} else {
+			h.Write([]byte(caller.File))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// addFingerprint wraps ce so that it's written with a fingerprint field.
+func (log *Logger) addFingerprint(ce *zapcore.CheckedEntry, template string) *zapcore.CheckedEntry {
+	if template == "" {
+		template = ce.Entry.Message
+	}
+	w := &fingerprintWriter{
+		LevelEnabler: zapcore.DebugLevel,
+		ce:           ce,
+		field:        String(log.fingerprintKey, fingerprint(template, ce.Entry.LoggerName, ce.Entry.Caller)),
+	}
+	outer := (*zapcore.CheckedEntry)(nil).AddCore(ce.Entry, w)
+	outer.ErrorOutput = ce.ErrorOutput
+	outer.ErrorSink = ce.ErrorSink
+	return outer
+}
+
+// fingerprintWriter is a Core that adds a fingerprint to the fields an entry
+// is written with, then writes the entry to the CheckedEntry it wraps.
+type fingerprintWriter struct {
+	zapcore.LevelEnabler
+	ce    *zapcore.CheckedEntry
+	field Field
+}
+
+func (w *fingerprintWriter) With([]Field) zapcore.Core { return w }
+
+func (w *fingerprintWriter) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, w)
+}
+
+func (w *fingerprintWriter) Write(ent zapcore.Entry, fields []Field) error {
+	w.ce.Write(append(fields[:len(fields):len(fields)], w.field)...)
+	return nil
+}
+
+func (w *fingerprintWriter) Sync() error { return nil }
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func logFromElsewhere(log *Logger, msg string) {
+	log.Info(msg)
+}
+
+func TestFingerprint(t *testing.T) {
+	withLogger(t, DebugLevel, opts(AddCaller(), Fingerprint("")), func(logger *Logger, logs *observer.ObservedLogs) {
+		for i := 0; i < 2; i++ {
+			logger.Info("same site", Int("i", i))
+		}
+		logger.Info("other message")
+		logger.Named("child").Info("same site")
+		logFromElsewhere(logger, "same site")
+		for i := 0; i < 2; i++ {
+			logger.Sugar().Infof("templated %d", i)
+		}
+		logger.Sugar().Info("templated ", 0)
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 8, len(entries), "Unexpected number of entries.")
+		fps := make([]string, len(entries))
+		for i, e := range entries {
+			fp, ok := e.ContextMap()[DefaultFingerprintKey].(string)
+			require.True(t, ok, "Expected a fingerprint on entry %q.", e.Message)
+			assert.Len(t, fp, 16, "Expected a hex-encoded 64-bit hash.")
+			fps[i] = fp
+		}
+
+		assert.Equal(t, fps[0], fps[1], "Expected entries from one site to share a fingerprint.")
+		assert.NotEqual(t, fps[0], fps[2], "Expected different messages to have different fingerprints.")
+		assert.NotEqual(t, fps[0], fps[3], "Expected different Logger names to have different fingerprints.")
+		assert.NotEqual(t, fps[0], fps[4], "Expected different callers to have different fingerprints.")
+		assert.Equal(t, fps[5], fps[6], "Expected templated messages to share a fingerprint.")
+		assert.NotEqual(t, fps[5], fps[7], "Expected the template to be hashed rather than the message.")
+	})
+}
+
+func TestFingerprintKey(t *testing.T) {
+	withLogger(t, DebugLevel, opts(Fingerprint("fp")), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("no caller")
+		logger.Info("no caller")
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+		assert.NotEmpty(t, entries[0].ContextMap()["fp"], "Expected a fingerprint under the given key.")
+		assert.Equal(t, entries[0].Context, entries[1].Context, "Expected a stable fingerprint without callers.")
+	})
+}
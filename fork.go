@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"sync"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+var _fork struct {
+	// mu is held from PrepareForFork until the matching After call.
+	mu        sync.Mutex
+	preparing bool
+
+	handlersMu sync.Mutex
+	handlers   []*forkHandler
+}
+
+type forkHandler struct {
+	zapcore.ForkHandler
+}
+
+// RegisterForkHandler registers a Core or WriteSyncer, such as an async Core
+// or a zapcore.BufferedWriteSyncer, to be prepared for forks by
+// PrepareForFork and restored by AfterForkInParent and AfterForkInChild. It
+// returns a function that unregisters it.
+func RegisterForkHandler(h zapcore.ForkHandler) func() {
+	entry := &forkHandler{h}
+	_fork.handlersMu.Lock()
+	_fork.handlers = append(_fork.handlers, entry)
+	_fork.handlersMu.Unlock()
+
+	return func() {
+		_fork.handlersMu.Lock()
+		defer _fork.handlersMu.Unlock()
+		for i, e := range _fork.handlers {
+			if e == entry {
+				_fork.handlers = append(_fork.handlers[:i:i], _fork.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// PrepareForFork readies zap for a fork.
+//
+// Go's runtime doesn't support fork(2) without a following exec, but some
+// programs fork anyway, typically through cgo or raw system calls to
+// daemonize. The child then inherits zap's state as it was in the forking
+// thread: locks held by other threads stay locked forever, background
+// goroutines (in async cores and buffered WriteSyncers) are gone, and
+// buffered output is flushed twice, once by each process.
+//
+// PrepareForFork, AfterForkInParent, and AfterForkInChild bracket such a
+// fork, like the handlers given to pthread_atfork:
+//
+//	zap.PrepareForFork()
+//	pid := fork()
+//	if pid == 0 {
+//		zap.AfterForkInChild()
+//	} else {
+//		zap.AfterForkInParent()
+//	}
+//
+// PrepareForFork flushes the global Logger, calls the PrepareForFork method
+// of every registered zapcore.ForkHandler, in the order they were
+// registered, and takes zap's global locks. Nothing may be logged between
+// PrepareForFork and the matching After call, since logging may need those
+// locks.
+//
+// Buffered file sinks opened by Open and Config.Build (those with the
+// bufferSize or flushInterval parameters) are registered automatically until
+// they're closed; other ForkHandlers must be registered with
+// RegisterForkHandler.
+//
+// Files opened by Open and Config.Build are already close-on-exec, as are
+// all files opened by the os package; see CloseOnExec for others.
+func PrepareForFork() {
+	_fork.mu.Lock()
+	_fork.preparing = true
+
+	L().Sync()
+
+	_fork.handlersMu.Lock()
+	for _, h := range _fork.handlers {
+		h.PrepareForFork()
+	}
+
+	_globalMu.Lock()
+	_sinkMutex.Lock()
+	_encoderMutex.Lock()
+	_coreWrapperMutex.Lock()
+}
+
+// AfterForkInParent undoes PrepareForFork in the parent process. It panics
+// if PrepareForFork wasn't called first.
+func AfterForkInParent() {
+	afterFork(zapcore.ForkHandler.AfterForkInParent)
+}
+
+// AfterForkInChild undoes PrepareForFork in the child process, restarting
+// background goroutines as it goes. It panics if PrepareForFork wasn't
+// called first.
+func AfterForkInChild() {
+	afterFork(zapcore.ForkHandler.AfterForkInChild)
+}
+
+func afterFork(after func(zapcore.ForkHandler)) {
+	if !_fork.preparing {
+		panic("zap: AfterFork called without PrepareForFork")
+	}
+
+	_coreWrapperMutex.Unlock()
+	_encoderMutex.Unlock()
+	_sinkMutex.Unlock()
+	_globalMu.Unlock()
+
+	for i := len(_fork.handlers) - 1; i >= 0; i-- {
+		after(_fork.handlers[i])
+	}
+	_fork.handlersMu.Unlock()
+
+	_fork.preparing = false
+	_fork.mu.Unlock()
+}
+
+// CloseOnExec marks a file as close-on-exec, so that processes started by
+// exec don't inherit it. It's meant for sinks built on files that weren't
+// opened by the os package, such as inherited descriptors wrapped with
+// os.NewFile. On Windows, where handles aren't inherited unless requested,
+// it does nothing.
+func CloseOnExec(f interface{ Fd() uintptr }) {
+	setCloseOnExec(f.Fd())
+}
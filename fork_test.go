@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingForkHandler struct {
+	name  string
+	calls *[]string
+}
+
+func (h recordingForkHandler) PrepareForFork() {
+	*h.calls = append(*h.calls, "prepare "+h.name)
+}
+
+func (h recordingForkHandler) AfterForkInParent() {
+	*h.calls = append(*h.calls, "parent "+h.name)
+}
+
+func (h recordingForkHandler) AfterForkInChild() {
+	*h.calls = append(*h.calls, "child "+h.name)
+}
+
+func TestForkHandlers(t *testing.T) {
+	var calls []string
+	unregisterA := RegisterForkHandler(recordingForkHandler{"a", &calls})
+	unregisterB := RegisterForkHandler(recordingForkHandler{"b", &calls})
+
+	PrepareForFork()
+	AfterForkInParent()
+	assert.Equal(t, []string{"prepare a", "prepare b", "parent b", "parent a"}, calls, "Unexpected calls in the parent.")
+
+	calls = nil
+	unregisterA()
+	PrepareForFork()
+	AfterForkInChild()
+	assert.Equal(t, []string{"prepare b", "child b"}, calls, "Unexpected calls in the child.")
+
+	unregisterB()
+	unregisterB()
+	calls = nil
+	PrepareForFork()
+	AfterForkInParent()
+	assert.Empty(t, calls, "Expected no calls after unregistering.")
+
+	// The global locks must have been released.
+	ReplaceGlobals(L())
+	_, err := NewEncoder("json", NewProductionEncoderConfig())
+	assert.NoError(t, err, "Unexpected error using the encoder registry after a fork.")
+}
+
+func TestBufferedFileSinkForkHandler(t *testing.T) {
+	f, err := ioutil.TempFile("", "zap-fork-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	handlers := func() int {
+		_fork.handlersMu.Lock()
+		defer _fork.handlersMu.Unlock()
+		return len(_fork.handlers)
+	}
+	before := handlers()
+	sink, err := newSink("file://" + filepath.ToSlash(f.Name()) + "?bufferSize=1KiB")
+	require.NoError(t, err, "Failed to open buffered file sink.")
+	assert.Equal(t, before+1, handlers(), "Expected the buffered sink to be registered for forks.")
+
+	sink.Write([]byte("before\n"))
+	PrepareForFork()
+	AfterForkInParent()
+	contents, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Equal(t, "before\n", string(contents), "Expected PrepareForFork to flush the sink.")
+
+	require.NoError(t, sink.Close(), "Unexpected error closing sink.")
+	assert.Equal(t, before, handlers(), "Expected closing the sink to unregister it.")
+}
+
+func TestAfterForkWithoutPrepare(t *testing.T) {
+	assert.Panics(t, AfterForkInParent, "Expected AfterForkInParent to panic without PrepareForFork.")
+	assert.Panics(t, AfterForkInChild, "Expected AfterForkInChild to panic without PrepareForFork.")
+}
+
+func TestCloseOnExec(t *testing.T) {
+	f, err := ioutil.TempFile("", "zap-fork-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	assert.NotPanics(t, func() { CloseOnExec(f) }, "Unexpected panic marking a file close-on-exec.")
+}
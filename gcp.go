@@ -0,0 +1,309 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/blastbao/zap/buffer"
+	"github.com/blastbao/zap/zapcore"
+)
+
+// Keys with special meaning to Google Cloud Logging. See
+// https://cloud.google.com/logging/docs/structured-logging.
+const (
+	GCPSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	GCPTraceKey          = "logging.googleapis.com/trace"
+	GCPSpanIDKey         = "logging.googleapis.com/spanId"
+	GCPTraceSampledKey   = "logging.googleapis.com/trace_sampled"
+)
+
+// _gcpFieldKeys maps the keys commonly used for tracing fields to the keys
+// Cloud Logging recognizes.
+var _gcpFieldKeys = map[string]string{
+	"trace":         GCPTraceKey,
+	"trace_id":      GCPTraceKey,
+	"traceID":       GCPTraceKey,
+	"span_id":       GCPSpanIDKey,
+	"spanID":        GCPSpanIDKey,
+	"spanId":        GCPSpanIDKey,
+	"trace_sampled": GCPTraceSampledKey,
+	"traceSampled":  GCPTraceSampledKey,
+}
+
+// NewGCPEncoderConfig returns an EncoderConfig for Google Cloud Logging (for
+// example, on GKE or Cloud Run). Levels are written as Cloud Logging
+// severities, timestamps as RFC3339 strings with nanosecond precision, and
+// messages under the "message" key.
+//
+// It's meant to be used with the "gcp" encoding, which also writes the
+// caller as a structured source location and moves tracing fields to the
+// keys Cloud Logging uses to correlate entries with traces. See
+// NewGCPEncoder.
+func NewGCPEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "timestamp",
+		LevelKey:       "severity",
+		NameKey:        "logger",
+		CallerKey:      GCPSourceLocationKey,
+		MessageKey:     "message",
+		StacktraceKey:  "stack_trace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    GCPSeverityEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.FullCallerEncoder,
+	}
+}
+
+// GCPSeverityEncoder serializes a Level to a Cloud Logging severity. DPanic,
+// Panic, and Fatal map to CRITICAL, ALERT, and EMERGENCY respectively.
+func GCPSeverityEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case TraceLevel, DebugLevel:
+		enc.AppendString("DEBUG")
+	case InfoLevel:
+		enc.AppendString("INFO")
+	case WarnLevel:
+		enc.AppendString("WARNING")
+	case ErrorLevel:
+		enc.AppendString("ERROR")
+	case DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case PanicLevel:
+		enc.AppendString("ALERT")
+	case FatalLevel:
+		enc.AppendString("EMERGENCY")
+	default:
+		enc.AppendString("DEFAULT")
+	}
+}
+
+// GCPTrace constructs a field that associates an entry with a Cloud Trace
+// trace. Cloud Logging expects the trace's full resource name, which
+// includes the project ID.
+func GCPTrace(projectID, traceID string) Field {
+	return String(GCPTraceKey, "projects/"+projectID+"/traces/"+traceID)
+}
+
+// NewGCPEncoder builds the "gcp" encoding: a JSON encoder that lays entries
+// out as Cloud Logging expects. Use it with NewGCPEncoderConfig, or with any
+// EncoderConfig whose keys suit Cloud Logging.
+//
+// The caller is written as an object with "file", "line", and "function"
+// under cfg.CallerKey, rather than as a string. Top-level fields named
+// "trace", "trace_id", "traceID", "span_id", "spanID", "spanId",
+// "trace_sampled", or "traceSampled" are written under GCPTraceKey,
+// GCPSpanIDKey, and GCPTraceSampledKey; fields inside namespaces keep their
+// keys.
+func NewGCPEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	callerKey := cfg.CallerKey
+	cfg.CallerKey = ""
+	return &gcpEncoder{
+		Encoder:   zapcore.NewJSONEncoder(cfg),
+		callerKey: callerKey,
+	}
+}
+
+type gcpEncoder struct {
+	zapcore.Encoder
+	callerKey string
+	// nested is set once a namespace is opened, since all subsequent fields
+	// belong to it.
+	nested bool
+}
+
+func (e *gcpEncoder) key(key string) string {
+	if e.nested {
+		return key
+	}
+	if mapped, ok := _gcpFieldKeys[key]; ok {
+		return mapped
+	}
+	return key
+}
+
+func (e *gcpEncoder) Clone() zapcore.Encoder {
+	return &gcpEncoder{
+		Encoder:   e.Encoder.Clone(),
+		callerKey: e.callerKey,
+		nested:    e.nested,
+	}
+}
+
+func (e *gcpEncoder) EncodeEntry(ent zapcore.Entry, fields []Field) (*buffer.Buffer, error) {
+	var extra []Field
+	if e.callerKey != "" && ent.Caller.Defined {
+		extra = append(extra, Object(e.callerKey, gcpSourceLocation(ent.Caller)))
+	}
+
+	for i := range fields {
+		if fields[i].Type == zapcore.InlineMarshalerType {
+			// Inlined keys are only known once they're marshaled, so add
+			// everything through a clone, which renames as it goes.
+			clone := e.Clone().(*gcpEncoder)
+			for _, f := range extra {
+				f.AddTo(clone)
+			}
+			for _, f := range fields {
+				f.AddTo(clone)
+			}
+			return clone.Encoder.EncodeEntry(ent, nil)
+		}
+	}
+
+	// Only copy the fields if some of them need to be renamed.
+	renamed, copied := fields, false
+	for i := range fields {
+		if e.nested || fields[i].Type == zapcore.NamespaceType {
+			break
+		}
+		key := e.key(fields[i].Key)
+		if key == fields[i].Key {
+			continue
+		}
+		if !copied {
+			renamed = append(make([]Field, 0, len(fields)), fields...)
+			copied = true
+		}
+		renamed[i].Key = key
+	}
+	if len(extra) > 0 {
+		renamed = append(extra, renamed...)
+	}
+	return e.Encoder.EncodeEntry(ent, renamed)
+}
+
+// gcpSourceLocation marshals a caller in the shape of Cloud Logging's
+// LogEntrySourceLocation.
+type gcpSourceLocation zapcore.EntryCaller
+
+func (loc gcpSourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", loc.File)
+	enc.AddString("line", strconv.Itoa(loc.Line))
+	if fn := runtime.FuncForPC(loc.PC); fn != nil {
+		enc.AddString("function", fn.Name())
+	}
+	return nil
+}
+
+func (e *gcpEncoder) OpenNamespace(key string) {
+	e.Encoder.OpenNamespace(key)
+	e.nested = true
+}
+
+func (e *gcpEncoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
+	return e.Encoder.AddArray(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
+	return e.Encoder.AddObject(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddReflected(key string, v interface{}) error {
+	return e.Encoder.AddReflected(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddBinary(key string, v []byte) {
+	e.Encoder.AddBinary(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddByteString(key string, v []byte) {
+	e.Encoder.AddByteString(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddBool(key string, v bool) {
+	e.Encoder.AddBool(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddComplex128(key string, v complex128) {
+	e.Encoder.AddComplex128(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddComplex64(key string, v complex64) {
+	e.Encoder.AddComplex64(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddDuration(key string, v time.Duration) {
+	e.Encoder.AddDuration(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddFloat64(key string, v float64) {
+	e.Encoder.AddFloat64(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddFloat32(key string, v float32) {
+	e.Encoder.AddFloat32(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddInt(key string, v int) {
+	e.Encoder.AddInt(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddInt64(key string, v int64) {
+	e.Encoder.AddInt64(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddInt32(key string, v int32) {
+	e.Encoder.AddInt32(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddInt16(key string, v int16) {
+	e.Encoder.AddInt16(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddInt8(key string, v int8) {
+	e.Encoder.AddInt8(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddString(key, v string) {
+	e.Encoder.AddString(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddTime(key string, v time.Time) {
+	e.Encoder.AddTime(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddUint(key string, v uint) {
+	e.Encoder.AddUint(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddUint64(key string, v uint64) {
+	e.Encoder.AddUint64(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddUint32(key string, v uint32) {
+	e.Encoder.AddUint32(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddUint16(key string, v uint16) {
+	e.Encoder.AddUint16(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddUint8(key string, v uint8) {
+	e.Encoder.AddUint8(e.key(key), v)
+}
+
+func (e *gcpEncoder) AddUintptr(key string, v uintptr) {
+	e.Encoder.AddUintptr(e.key(key), v)
+}
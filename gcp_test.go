@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCPSeverityEncoder(t *testing.T) {
+	tests := []struct {
+		lvl  zapcore.Level
+		want string
+	}{
+		{DebugLevel, "DEBUG"},
+		{InfoLevel, "INFO"},
+		{WarnLevel, "WARNING"},
+		{ErrorLevel, "ERROR"},
+		{DPanicLevel, "CRITICAL"},
+		{PanicLevel, "ALERT"},
+		{FatalLevel, "EMERGENCY"},
+		{zapcore.Level(42), "DEFAULT"},
+	}
+	for _, tt := range tests {
+		enc := zapcore.NewMapObjectEncoder()
+		enc.AddArray("k", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			GCPSeverityEncoder(tt.lvl, arr)
+			return nil
+		}))
+		assert.Equal(t, []interface{}{tt.want}, enc.Fields["k"], "Unexpected severity for %v.", tt.lvl)
+	}
+}
+
+func TestGCPEncoder(t *testing.T) {
+	buf := &ztest.Buffer{}
+	cfg := Config{
+		Level:         NewAtomicLevelAt(DebugLevel),
+		Encoding:      "gcp",
+		EncoderConfig: NewGCPEncoderConfig(),
+	}
+	enc, err := cfg.buildEncoder()
+	require.NoError(t, err, "Failed to build gcp encoder.")
+	logger := New(zapcore.NewCore(enc, buf, DebugLevel), AddCaller()).With(String("trace_id", "abc"))
+
+	logger.Warn("hello", String("spanId", "123"), Bool("trace_sampled", true), Namespace("ns"), String("trace", "kept"))
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(buf.Stripped()), &got), "Expected valid JSON.")
+
+	ts, err := time.Parse(time.RFC3339Nano, got["timestamp"].(string))
+	require.NoError(t, err, "Expected an RFC3339 timestamp.")
+	assert.WithinDuration(t, time.Now(), ts, time.Minute, "Unexpected timestamp.")
+	assert.Equal(t, "WARNING", got["severity"], "Unexpected severity.")
+	assert.Equal(t, "hello", got["message"], "Unexpected message.")
+	assert.Equal(t, "abc", got[GCPTraceKey], "Expected context trace field to be renamed.")
+	assert.Equal(t, "123", got[GCPSpanIDKey], "Expected span field to be renamed.")
+	assert.Equal(t, true, got[GCPTraceSampledKey], "Expected sampling field to be renamed.")
+	assert.Equal(t, map[string]interface{}{"trace": "kept"}, got["ns"], "Expected namespaced fields to keep their keys.")
+
+	loc, ok := got[GCPSourceLocationKey].(map[string]interface{})
+	require.True(t, ok, "Expected the caller to be an object, got %v.", got[GCPSourceLocationKey])
+	assert.Contains(t, loc["file"], "gcp_test.go", "Unexpected caller file.")
+	assert.NotEmpty(t, loc["line"], "Expected a caller line.")
+	assert.Contains(t, loc["function"], "TestGCPEncoder", "Unexpected caller function.")
+}
+
+func TestGCPEncoderInline(t *testing.T) {
+	enc := NewGCPEncoder(zapcore.EncoderConfig{MessageKey: "message"})
+	inline := zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("span_id", "123")
+		return nil
+	})
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi"}, []Field{Inline(inline), GCPTrace("proj", "abc")})
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	defer buf.Free()
+	assert.Equal(
+		t,
+		`{"message":"hi","logging.googleapis.com/spanId":"123","logging.googleapis.com/trace":"projects/proj/traces/abc"}`+"\n",
+		buf.String(),
+		"Unexpected encoding of inlined fields.",
+	)
+}
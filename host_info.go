@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"sync"
+)
+
+// A containerIDSource is a file that may mention the current container's
+// ID, which Docker, containerd, and CRI-O make 64 hex digits long, and a
+// pattern whose first group captures it.
+type containerIDSource struct {
+	file    string
+	pattern *regexp.Regexp
+}
+
+// _containerIDSources are searched in order. Under cgroup v1 the ID appears
+// in the process's cgroups; under cgroup v2, only in the paths of the files
+// the runtime mounts into the container, such as /etc/hostname. (Other
+// mounts, like overlay layers, have IDs of the same form.)
+var _containerIDSources = []containerIDSource{
+	{"/proc/self/cgroup", regexp.MustCompile(`([0-9a-f]{64})`)},
+	{"/proc/self/mountinfo", regexp.MustCompile(`/containers/([0-9a-f]{64})/`)},
+}
+
+var (
+	_hostInfoOnce    sync.Once
+	_hostInfo        []Field
+	_processInfoOnce sync.Once
+	_processInfo     []Field
+)
+
+// WithHostInfo adds fields describing the host to the Logger: "hostname",
+// and "containerID" if the process is running in a container whose ID can
+// be found. The host is only inspected once per process.
+func WithHostInfo() Option {
+	return optionFunc(func(log *Logger) {
+		_hostInfoOnce.Do(func() {
+			_hostInfo = hostInfo()
+		})
+		Fields(_hostInfo...).apply(log)
+	})
+}
+
+// WithProcessInfo adds fields describing the process to the Logger: "pid",
+// "executable", and "goVersion". The process is only inspected once.
+func WithProcessInfo() Option {
+	return optionFunc(func(log *Logger) {
+		_processInfoOnce.Do(func() {
+			_processInfo = processInfo()
+		})
+		Fields(_processInfo...).apply(log)
+	})
+}
+
+func hostInfo() []Field {
+	var fields []Field
+	if host, err := os.Hostname(); err == nil {
+		fields = append(fields, String("hostname", host))
+	}
+	if id := containerID(_containerIDSources); id != "" {
+		fields = append(fields, String("containerID", id))
+	}
+	return fields
+}
+
+func processInfo() []Field {
+	fields := []Field{Int("pid", os.Getpid())}
+	if exe, err := os.Executable(); err == nil {
+		fields = append(fields, String("executable", exe))
+	}
+	return append(fields, String("goVersion", runtime.Version()))
+}
+
+// containerID returns the first container ID found in sources, or "" if
+// there isn't one.
+func containerID(sources []containerIDSource) string {
+	for _, src := range sources {
+		f, err := os.Open(src.file)
+		if err != nil {
+			continue
+		}
+		id := findContainerID(f, src.pattern)
+		f.Close()
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func findContainerID(r io.Reader, pattern *regexp.Regexp) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if m := pattern.FindStringSubmatch(scanner.Text()); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
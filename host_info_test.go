@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProcessInfo(t *testing.T) {
+	withLogger(t, DebugLevel, opts(WithProcessInfo()), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("hello")
+		logger.With(String("k", "v")).Info("again")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Expected two entries.")
+		for _, ent := range entries {
+			ctx := ent.ContextMap()
+			assert.Equal(t, int64(os.Getpid()), ctx["pid"], "Unexpected pid.")
+			assert.Equal(t, runtime.Version(), ctx["goVersion"], "Unexpected Go version.")
+			assert.NotEmpty(t, ctx["executable"], "Expected the executable.")
+		}
+	})
+}
+
+func TestWithHostInfo(t *testing.T) {
+	host, err := os.Hostname()
+	require.NoError(t, err, "Failed to get hostname.")
+	withLogger(t, DebugLevel, opts(WithHostInfo()), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("hello")
+		require.Equal(t, 1, logs.Len(), "Expected an entry.")
+		assert.Equal(t, host, logs.AllUntimed()[0].ContextMap()["hostname"], "Unexpected hostname.")
+	})
+}
+
+func TestContainerID(t *testing.T) {
+	const id = "3f4b0c8a5c1f2c1d9f6a7e8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d"
+	const layer = "aaaabbbbccccddddeeeeffff0000111122223333444455556666777788889999"
+	tests := []struct {
+		desc     string
+		cgroup   string
+		mounts   string
+		expected string
+	}{
+		{
+			desc:     "cgroup v1 docker",
+			cgroup:   "12:memory:/docker/" + id + "\n11:cpu:/docker/" + id + "\n",
+			expected: id,
+		},
+		{
+			desc:     "cgroup v1 kubernetes",
+			cgroup:   "1:name=systemd:/kubepods/burstable/pod1234/" + id + "\n",
+			expected: id,
+		},
+		{
+			desc:   "cgroup v2",
+			cgroup: "0::/\n",
+			mounts: strings.Join([]string{
+				"648 600 0:52 / / rw - overlay overlay rw,upperdir=/var/lib/docker/overlay2/" + layer + "/diff",
+				"660 648 254:1 /var/lib/docker/containers/" + id + "/hostname /etc/hostname rw - ext4 /dev/vda1 rw",
+			}, "\n"),
+			expected: id,
+		},
+		{
+			desc:   "not in a container",
+			cgroup: "0::/user.slice/user-1000.slice/session-2.scope\n",
+			mounts: "22 1 254:1 / / rw - ext4 /dev/vda1 rw\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "zap-container-id-test")
+			require.NoError(t, err, "Failed to create temp dir.")
+			defer os.RemoveAll(dir)
+
+			sources := make([]containerIDSource, len(_containerIDSources))
+			copy(sources, _containerIDSources)
+			for i, contents := range []string{tt.cgroup, tt.mounts} {
+				sources[i].file = filepath.Join(dir, filepath.Base(sources[i].file))
+				require.NoError(t, ioutil.WriteFile(sources[i].file, []byte(contents), 0644), "Failed to write %s.", sources[i].file)
+			}
+			assert.Equal(t, tt.expected, containerID(sources), "Unexpected container ID.")
+		})
+	}
+
+	missing := []containerIDSource{{"/does/not/exist", _containerIDSources[0].pattern}}
+	assert.Equal(t, "", containerID(missing), "Expected no container ID without the files.")
+}
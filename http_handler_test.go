@@ -100,6 +100,16 @@ func TestHTTPHandlerPutLevel(t *testing.T) {
 	assertResponse(t, lvl.Level(), body)
 }
 
+func TestHTTPHandlerPutTraceLevel(t *testing.T) {
+	lvl, _ := newHandler()
+
+	code, body := makeRequest(t, "PUT", lvl, strings.NewReader(`{"level":"trace"}`))
+
+	assertCodeOK(t, code)
+	assert.Equal(t, TraceLevel, lvl.Level(), "Expected the handler to accept TraceLevel.")
+	assertResponse(t, lvl.Level(), body)
+}
+
 func TestHTTPHandlerPutUnrecognizedLevel(t *testing.T) {
 	lvl, _ := newHandler()
 	code, body := makeRequest(t, "PUT", lvl, strings.NewReader(`{"level":"unrecognized-level"}`))
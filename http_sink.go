@@ -0,0 +1,495 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+
+	_defaultHTTPBatchSize     = 100
+	_defaultHTTPFlushInterval = time.Second
+	_defaultHTTPMaxRetries    = 3
+	_defaultHTTPBackoff       = 100 * time.Millisecond
+	_defaultHTTPTimeout       = 10 * time.Second
+	// _httpMaxPendingBatches bounds the entries held while the endpoint is
+	// failing, in units of batches.
+	_httpMaxPendingBatches = 10
+)
+
+var errHTTPSinkClosed = errors.New("http sink: write to closed sink")
+
+// httpBatchEncoder turns a batch of entries into a request body, returning
+// the body's content type.
+type httpBatchEncoder func(batch [][]byte) (body []byte, contentType string, err error)
+
+// encodeNDJSON sends entries as newline-delimited JSON.
+func encodeNDJSON(batch [][]byte) ([]byte, string, error) {
+	var body bytes.Buffer
+	for _, p := range batch {
+		body.Write(bytes.TrimRight(p, "\r\n"))
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), "application/x-ndjson", nil
+}
+
+// encodeJSONArray sends entries as the elements of a JSON array.
+func encodeJSONArray(batch [][]byte) ([]byte, string, error) {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, p := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(bytes.TrimSpace(p))
+	}
+	body.WriteByte(']')
+	return body.Bytes(), "application/json", nil
+}
+
+// httpSinkConfig holds the settings shared by the sinks that POST batches
+// of entries to an HTTP endpoint.
+type httpSinkConfig struct {
+	endpoint      string
+	client        *http.Client
+	encode        httpBatchEncoder
+	headers       http.Header
+	gzip          bool
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	backoff       time.Duration
+}
+
+// httpSink POSTs batches of entries to an HTTP endpoint. Batches are sent
+// when full, when the flush interval elapses, and when the sink is synced or
+// closed. Failed requests are retried with exponential backoff; entries that
+// still can't be sent stay buffered for the next attempt, up to a limit,
+// after which new entries are dropped.
+//
+// Writes only append to the buffer; a background goroutine makes the
+// requests, so a slow or failing endpoint never holds up the goroutines that
+// are logging.
+type httpSink struct {
+	cfg httpSinkConfig
+
+	mu        sync.Mutex
+	flushed   *sync.Cond // broadcast when a flush ends
+	pending   [][]byte
+	requested uint64 // flushes requested by Sync
+	completed uint64 // the last requested flush that has finished
+	asyncErr  error  // from background flushes, reported by the next Sync
+	closed    bool
+	stopped   bool // the background goroutine has exited
+
+	kick chan struct{} // asks the sender to flush
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newHTTPSinkWithConfig(cfg httpSinkConfig) *httpSink {
+	s := &httpSink{
+		cfg:  cfg,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	s.flushed = sync.NewCond(&s.mu)
+	go s.run()
+	return s
+}
+
+// newHTTPSink opens a sink for "http" and "https" URLs. The URL, minus the
+// sink's own query parameters, is the endpoint.
+func newHTTPSink(u *url.URL) (Sink, error) {
+	cfg, rest, err := parseHTTPSinkURL(u, u.Scheme == schemeHTTPS)
+	if err != nil {
+		return nil, err
+	}
+	cfg.encode = encodeNDJSON
+	for key, vals := range rest {
+		val := vals[len(vals)-1]
+		switch key {
+		case "format":
+			switch val {
+			case "ndjson":
+				cfg.encode = encodeNDJSON
+			case "array":
+				cfg.encode = encodeJSONArray
+			default:
+				return nil, fmt.Errorf("invalid format %q in %s URL: must be ndjson or array", val, u.Scheme)
+			}
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in %s URL: got %v", key, u.Scheme, u)
+		}
+	}
+	return newHTTPSinkWithConfig(cfg), nil
+}
+
+// parseHTTPSinkURL reads the settings shared by HTTP-based sinks from u,
+// returning the query parameters it didn't recognize. The endpoint uses
+// HTTPS if secure is set, and plain HTTP otherwise.
+func parseHTTPSinkURL(u *url.URL, secure bool) (httpSinkConfig, url.Values, error) {
+	cfg := httpSinkConfig{
+		headers:       make(http.Header),
+		batchSize:     _defaultHTTPBatchSize,
+		flushInterval: _defaultHTTPFlushInterval,
+		maxRetries:    _defaultHTTPMaxRetries,
+		backoff:       _defaultHTTPBackoff,
+	}
+	if u.Host == "" {
+		return cfg, nil, fmt.Errorf("%s URLs must include a host: got %v", u.Scheme, u)
+	}
+	if u.Fragment != "" {
+		return cfg, nil, fmt.Errorf("fragments not allowed with %s URLs: got %v", u.Scheme, u)
+	}
+
+	transport, q, err := TransportConfig{}.ApplyQuery(u.Query())
+	if err != nil {
+		return cfg, nil, fmt.Errorf("invalid %s URL: %v", u.Scheme, err)
+	}
+	if !secure && transport.HasTLS() {
+		return cfg, nil, fmt.Errorf("TLS parameters require a secure URL: got %v", u)
+	}
+
+	timeout := _defaultHTTPTimeout
+	rest := make(url.Values)
+	for key, vals := range q {
+		val := vals[len(vals)-1]
+		switch key {
+		case "batchSize":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return cfg, nil, fmt.Errorf("invalid batchSize %q in %s URL: must be a positive integer", val, u.Scheme)
+			}
+			cfg.batchSize = n
+		case "flushInterval":
+			d, err := time.ParseDuration(val)
+			if err != nil || d < 0 {
+				return cfg, nil, fmt.Errorf("invalid flushInterval %q in %s URL: must be a non-negative duration", val, u.Scheme)
+			}
+			cfg.flushInterval = d
+		case "maxRetries":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				return cfg, nil, fmt.Errorf("invalid maxRetries %q in %s URL: must be a non-negative integer", val, u.Scheme)
+			}
+			cfg.maxRetries = n
+		case "backoff":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return cfg, nil, fmt.Errorf("invalid backoff %q in %s URL: must be a positive duration", val, u.Scheme)
+			}
+			cfg.backoff = d
+		case "timeout":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return cfg, nil, fmt.Errorf("invalid timeout %q in %s URL: must be a positive duration", val, u.Scheme)
+			}
+			timeout = d
+		case "compress":
+			if val != "gzip" {
+				return cfg, nil, fmt.Errorf("invalid compress %q in %s URL: must be gzip", val, u.Scheme)
+			}
+			cfg.gzip = true
+		case "authorization":
+			cfg.headers.Set("Authorization", val)
+		case "authorizationEnv":
+			token, ok := os.LookupEnv(val)
+			if !ok {
+				return cfg, nil, fmt.Errorf("environment variable %s named by authorizationEnv in %s URL isn't set", val, u.Scheme)
+			}
+			cfg.headers.Set("Authorization", token)
+		case "header":
+			for _, h := range vals {
+				i := strings.IndexByte(h, ':')
+				if i <= 0 {
+					return cfg, nil, fmt.Errorf("invalid header %q in %s URL: must be Name:value", h, u.Scheme)
+				}
+				cfg.headers.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+			}
+		default:
+			rest[key] = vals
+		}
+	}
+
+	tr, err := transport.HTTPTransport()
+	if err != nil {
+		return cfg, nil, err
+	}
+	cfg.client = &http.Client{Transport: tr, Timeout: timeout}
+
+	endpoint := *u
+	endpoint.RawQuery = ""
+	if secure {
+		endpoint.Scheme = schemeHTTPS
+	} else {
+		endpoint.Scheme = schemeHTTP
+	}
+	if endpoint.User != nil {
+		// Send credentials in the URL as basic auth, unless an explicit
+		// Authorization header was given.
+		if cfg.headers.Get("Authorization") == "" {
+			password, _ := endpoint.User.Password()
+			req := http.Request{Header: make(http.Header)}
+			req.SetBasicAuth(endpoint.User.Username(), password)
+			cfg.headers.Set("Authorization", req.Header.Get("Authorization"))
+		}
+		endpoint.User = nil
+	}
+	cfg.endpoint = endpoint.String()
+	return cfg, rest, nil
+}
+
+// Write buffers a copy of p, asking the background goroutine to send the
+// current batch if it's full. If too many entries are already waiting to be
+// sent, p is dropped.
+func (s *httpSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errHTTPSinkClosed
+	}
+	if len(s.pending) >= s.cfg.batchSize*_httpMaxPendingBatches {
+		return 0, fmt.Errorf("http sink: dropped entry, %d entries already waiting to be sent", len(s.pending))
+	}
+	s.pending = append(s.pending, append([]byte(nil), p...))
+	if len(s.pending) >= s.cfg.batchSize {
+		s.flushAsync()
+	}
+	return len(p), nil
+}
+
+// Sync waits for the background goroutine to send all buffered entries,
+// returning any errors from sending since the last Sync. Once the sink is
+// closed, there's nothing left to send, and Sync only reports errors.
+func (s *httpSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) > 0 && !s.stopped {
+		s.requested++
+		round := s.requested
+		s.flushAsync()
+		for s.completed < round && !s.stopped {
+			s.flushed.Wait()
+		}
+	}
+	err := s.asyncErr
+	s.asyncErr = nil
+	return err
+}
+
+// Close sends any buffered entries and stops the background goroutine.
+// Entries that still can't be sent are dropped and reported.
+func (s *httpSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.Sync()
+	close(s.stop)
+	<-s.done
+	s.cfg.client.CloseIdleConnections()
+
+	s.mu.Lock()
+	if n := len(s.pending); n > 0 {
+		err = multierr.Append(err, fmt.Errorf("http sink: %d entries undelivered", n))
+		s.pending = nil
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// flushAsync asks the background goroutine to flush, without waiting.
+func (s *httpSink) flushAsync() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run is the background goroutine that sends batches, on request and every
+// flush interval.
+func (s *httpSink) run() {
+	defer close(s.done)
+
+	var tick <-chan time.Time
+	if s.cfg.flushInterval > 0 {
+		ticker := time.NewTicker(s.cfg.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			// Release any Sync that raced with Close.
+			s.mu.Lock()
+			s.stopped = true
+			s.flushed.Broadcast()
+			s.mu.Unlock()
+			return
+		case <-tick:
+		case <-s.kick:
+		}
+
+		s.mu.Lock()
+		round := s.requested
+		n := len(s.pending)
+		s.mu.Unlock()
+
+		err := s.flush(n)
+
+		s.mu.Lock()
+		if err != nil {
+			s.asyncErr = multierr.Append(s.asyncErr, err)
+		}
+		s.completed = round
+		s.flushed.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// flush sends up to n buffered entries in batches, stopping at the first
+// batch that can't be sent; it and the batches after it stay buffered.
+// Batches rejected by the endpoint are dropped. It runs only on the
+// background goroutine, and doesn't hold the lock while it sends.
+func (s *httpSink) flush(n int) error {
+	var errs error
+	for n > 0 {
+		size := s.cfg.batchSize
+		if size > n {
+			size = n
+		}
+		s.mu.Lock()
+		if size > len(s.pending) {
+			size = len(s.pending)
+		}
+		// Writes only append, so the front of pending is stable until we
+		// remove it.
+		batch := s.pending[:size:size]
+		s.mu.Unlock()
+		if size == 0 {
+			break
+		}
+
+		rejected, err := s.send(batch)
+		if err != nil && !rejected {
+			return multierr.Append(errs, err)
+		}
+		errs = multierr.Append(errs, err)
+
+		s.mu.Lock()
+		s.pending = s.pending[size:]
+		if len(s.pending) == 0 {
+			s.pending = nil
+		}
+		s.mu.Unlock()
+		n -= size
+	}
+	return errs
+}
+
+// send POSTs a batch, retrying network errors, throttling, and server errors
+// with exponential backoff. It reports whether the endpoint rejected the
+// batch with a client error, since retrying those won't help.
+func (s *httpSink) send(batch [][]byte) (rejected bool, err error) {
+	body, contentType, err := s.cfg.encode(batch)
+	if err != nil {
+		return true, fmt.Errorf("http sink: dropped %d entries: %v", len(batch), err)
+	}
+	encoding := ""
+	if s.cfg.gzip {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(body)
+		w.Close()
+		body, encoding = buf.Bytes(), "gzip"
+	}
+
+	backoff := s.cfg.backoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := s.post(body, contentType, encoding)
+		if err == nil {
+			return false, nil
+		}
+		if !retryable {
+			return true, fmt.Errorf("http sink: dropped %d entries: %v", len(batch), err)
+		}
+		if attempt >= s.cfg.maxRetries {
+			return false, fmt.Errorf("http sink: sending %d entries failed after %d attempts: %v", len(batch), attempt+1, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes a single request, reporting whether a failure is worth
+// retrying.
+func (s *httpSink) post(body []byte, contentType, encoding string) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, vals := range s.cfg.headers {
+		req.Header[name] = vals
+	}
+	req.Header.Set("Content-Type", contentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := s.cfg.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500
+	return retryable, err
+}
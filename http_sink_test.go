@@ -0,0 +1,295 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+// httpCollector records the requests it receives, answering the first few
+// with the supplied status codes and the rest with 204 No Content.
+type httpCollector struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+func newHTTPCollector(statuses ...int) *httpCollector {
+	c := &httpCollector{statuses: statuses}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	return c
+}
+
+func (c *httpCollector) serve(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	data, _ := ioutil.ReadAll(body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := http.StatusNoContent
+	if len(c.statuses) > 0 {
+		status, c.statuses = c.statuses[0], c.statuses[1:]
+	}
+	if status < 300 {
+		c.requests = append(c.requests, receivedRequest{r.Header, string(data)})
+	}
+	w.WriteHeader(status)
+}
+
+func (c *httpCollector) Requests() []receivedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]receivedRequest(nil), c.requests...)
+}
+
+func TestHTTPSinkBatches(t *testing.T) {
+	c := newHTTPCollector()
+	defer c.Close()
+
+	sink, err := newSink(c.URL + "/ingest?batchSize=2&flushInterval=0s")
+	require.NoError(t, err, "Failed to open http sink.")
+	defer sink.Close()
+
+	sink.Write([]byte(`{"msg":"one"}` + "\n"))
+	assert.Empty(t, c.Requests(), "Expected entries to be buffered until the batch is full.")
+	sink.Write([]byte(`{"msg":"two"}` + "\n"))
+	sink.Write([]byte(`{"msg":"three"}` + "\n"))
+	require.NoError(t, sink.Sync(), "Unexpected error syncing.")
+
+	reqs := c.Requests()
+	require.Len(t, reqs, 2, "Expected a full batch and a partial one.")
+	assert.Equal(t, "{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n", reqs[0].body, "Unexpected first batch.")
+	assert.Equal(t, "application/x-ndjson", reqs[0].header.Get("Content-Type"), "Unexpected content type.")
+	assert.Equal(t, "{\"msg\":\"three\"}\n", reqs[1].body, "Unexpected second batch.")
+}
+
+func TestHTTPSinkArrayFormatAndHeaders(t *testing.T) {
+	c := newHTTPCollector()
+	defer c.Close()
+	defer os.Unsetenv("ZAP_TEST_HEC_TOKEN")
+	os.Setenv("ZAP_TEST_HEC_TOKEN", "Splunk secret")
+
+	query := url.Values{
+		"format":           {"array"},
+		"compress":         {"gzip"},
+		"authorizationEnv": {"ZAP_TEST_HEC_TOKEN"},
+		"header":           {"X-Splunk-Request-Channel: abc", "X-Team:logs"},
+	}
+	sink, err := newSink(c.URL + "/services/collector?" + query.Encode())
+	require.NoError(t, err, "Failed to open http sink.")
+	sink.Write([]byte(`{"msg":"one"}` + "\n"))
+	sink.Write([]byte(`{"msg":"two"}` + "\n"))
+	require.NoError(t, sink.Close(), "Unexpected error closing.")
+
+	reqs := c.Requests()
+	require.Len(t, reqs, 1, "Expected Close to send the buffered entries.")
+	assert.Equal(t, `[{"msg":"one"},{"msg":"two"}]`, reqs[0].body, "Unexpected array payload.")
+	h := reqs[0].header
+	assert.Equal(t, "application/json", h.Get("Content-Type"), "Unexpected content type.")
+	assert.Equal(t, "gzip", h.Get("Content-Encoding"), "Expected a gzipped body.")
+	assert.Equal(t, "Splunk secret", h.Get("Authorization"), "Unexpected authorization header.")
+	assert.Equal(t, "abc", h.Get("X-Splunk-Request-Channel"), "Unexpected custom header.")
+	assert.Equal(t, "logs", h.Get("X-Team"), "Unexpected custom header.")
+
+	_, err = sink.Write([]byte("late\n"))
+	assert.Equal(t, errHTTPSinkClosed, err, "Expected writes after Close to fail.")
+}
+
+func TestHTTPSinkBasicAuth(t *testing.T) {
+	c := newHTTPCollector()
+	defer c.Close()
+
+	u, err := url.Parse(c.URL)
+	require.NoError(t, err, "Failed to parse server URL.")
+	u.User = url.UserPassword("user", "pass")
+	sink, err := newSink(u.String())
+	require.NoError(t, err, "Failed to open http sink.")
+	sink.Write([]byte("{}\n"))
+	require.NoError(t, sink.Close(), "Unexpected error closing.")
+
+	reqs := c.Requests()
+	require.Len(t, reqs, 1, "Expected one request.")
+	r := http.Request{Header: reqs[0].header}
+	user, pass, ok := r.BasicAuth()
+	assert.True(t, ok, "Expected basic auth.")
+	assert.Equal(t, "user", user, "Unexpected username.")
+	assert.Equal(t, "pass", pass, "Unexpected password.")
+}
+
+func TestHTTPSinkRetries(t *testing.T) {
+	c := newHTTPCollector(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer c.Close()
+
+	sink, err := newSink(c.URL + "?flushInterval=0s&backoff=1ms&maxRetries=2")
+	require.NoError(t, err, "Failed to open http sink.")
+	defer sink.Close()
+	sink.Write([]byte("{}\n"))
+	require.NoError(t, sink.Sync(), "Expected the batch to succeed after retrying.")
+	assert.Len(t, c.Requests(), 1, "Expected the batch to be delivered once.")
+
+	// Exhausting the retries keeps the entries for the next flush.
+	c.mu.Lock()
+	c.statuses = []int{500, 500, 500}
+	c.mu.Unlock()
+	sink.Write([]byte("{}\n"))
+	err = sink.Sync()
+	if assert.Error(t, err, "Expected an error after exhausting retries.") {
+		assert.Contains(t, err.Error(), "failed after 3 attempts", "Unexpected error message.")
+	}
+	require.NoError(t, sink.Sync(), "Expected the retained entries to be sent on the next Sync.")
+	assert.Len(t, c.Requests(), 2, "Expected the retained batch to be delivered.")
+
+	// Client errors aren't retried, and the batch is dropped.
+	c.mu.Lock()
+	c.statuses = []int{http.StatusBadRequest}
+	c.mu.Unlock()
+	sink.Write([]byte("bad\n"))
+	err = sink.Sync()
+	if assert.Error(t, err, "Expected an error for a rejected batch.") {
+		assert.Contains(t, err.Error(), "dropped 1 entries: 400 Bad Request", "Unexpected error message.")
+	}
+	assert.NoError(t, sink.Sync(), "Expected the rejected batch to be dropped.")
+	assert.Len(t, c.Requests(), 2, "Expected the rejected batch not to be resent.")
+}
+
+func TestHTTPSinkBufferLimit(t *testing.T) {
+	sink, err := newSink("http://127.0.0.1:1?batchSize=1&flushInterval=0s&maxRetries=0")
+	require.NoError(t, err, "Failed to open http sink.")
+	defer sink.Close()
+
+	for i := 0; i < _httpMaxPendingBatches; i++ {
+		_, err := sink.Write([]byte("{}\n"))
+		assert.NoError(t, err, "Expected writes to be buffered.")
+	}
+	_, err = sink.Write([]byte("{}\n"))
+	if assert.Error(t, err, "Expected the buffer to be full.") {
+		assert.Contains(t, err.Error(), "dropped entry", "Unexpected error message.")
+	}
+	assert.Error(t, sink.Sync(), "Expected sending to an unreachable endpoint to fail.")
+}
+
+func TestHTTPSinkWriteDoesNotWaitForEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := newSink(srv.URL + "?batchSize=1&flushInterval=0s")
+	require.NoError(t, err, "Failed to open http sink.")
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := sink.Write([]byte("{}\n"))
+		require.NoError(t, err, "Unexpected error writing.")
+	}
+	assert.True(t, time.Since(start) < 100*time.Millisecond, "Expected writes not to wait for a slow endpoint.")
+
+	close(release)
+	assert.NoError(t, sink.Close(), "Expected Close to deliver the buffered entries.")
+}
+
+func TestHTTPSinkSyncAfterClose(t *testing.T) {
+	c := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer c.Close()
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{c.URL + "/push?maxRetries=0&backoff=1ms&flushInterval=0s"}
+	cfg.ErrorOutputPaths = nil
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Failed to build logger.")
+
+	logger.Info("undelivered")
+	err = logger.Shutdown(context.Background())
+	if assert.Error(t, err, "Expected Shutdown to report the undelivered entry.") {
+		assert.Contains(t, err.Error(), "1 entries undelivered", "Unexpected error message.")
+	}
+
+	synced := make(chan struct{})
+	go func() {
+		logger.Sync()
+		close(synced)
+	}()
+	select {
+	case <-synced:
+	case <-time.After(time.Second):
+		t.Fatal("Sync after Shutdown didn't return.")
+	}
+}
+
+func TestHTTPSinkErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"http:///ingest", "must include a host"},
+		{"http://logs/ingest#frag", "fragments not allowed"},
+		{"http://logs/ingest?color=blue", "unknown query parameter"},
+		{"http://logs/ingest?format=xml", "invalid format"},
+		{"http://logs/ingest?batchSize=0", "invalid batchSize"},
+		{"http://logs/ingest?flushInterval=-1s", "invalid flushInterval"},
+		{"http://logs/ingest?maxRetries=-1", "invalid maxRetries"},
+		{"http://logs/ingest?backoff=0s", "invalid backoff"},
+		{"http://logs/ingest?timeout=forever", "invalid timeout"},
+		{"http://logs/ingest?compress=zstd", "invalid compress"},
+		{"http://logs/ingest?header=NoColon", "invalid header"},
+		{"http://logs/ingest?authorizationEnv=ZAP_TEST_UNSET_VARIABLE", "isn't set"},
+		{"http://logs/ingest?caFile=ca.pem", "TLS parameters require a secure URL"},
+		{"https://logs/ingest?dialTimeout=soon", "invalid https URL"},
+	}
+	for _, tt := range tests {
+		_, err := newSink(tt.url)
+		if assert.Error(t, err, "Expected an error for URL %q.", tt.url) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error for URL %q.", tt.url)
+		}
+	}
+}
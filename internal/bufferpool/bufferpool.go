@@ -22,10 +22,34 @@
 // packages can recreate the same functionality with buffers.NewPool.
 package bufferpool
 
-import "github.com/blastbao/zap/buffer"
+import (
+	"sync"
+
+	"github.com/blastbao/zap/buffer"
+)
 
 var (
 	_pool = buffer.NewPool()
 	// Get retrieves a buffer from the pool, creating one if necessary.
 	Get = _pool.Get
+
+	_sizedMu    sync.Mutex
+	_sizedPools = make(map[int]buffer.Pool)
 )
+
+// Sized returns a shared pool of buffers with the given initial capacity, or
+// the default pool if size isn't positive. Callers should look the pool up
+// once and keep it, rather than calling Sized for each buffer.
+func Sized(size int) buffer.Pool {
+	if size <= 0 {
+		return _pool
+	}
+	_sizedMu.Lock()
+	defer _sizedMu.Unlock()
+	p, ok := _sizedPools[size]
+	if !ok {
+		p = buffer.NewPoolSized(size)
+		_sizedPools[size] = p
+	}
+	return p
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package proxy dials TCP connections through HTTP CONNECT and SOCKS5
+// proxies. It exists so that zap's network sinks can traverse egress proxies
+// without pulling in additional dependencies.
+package proxy // import "github.com/blastbao/zap/internal/proxy"
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DialFunc has the same signature as net.Dialer's DialContext method.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dialer returns a DialFunc that reaches its targets through the proxy at u,
+// using forward to connect to the proxy itself. Supported schemes are "http"
+// (using the CONNECT method), "socks5", and "socks5h". Credentials in u's user
+// info are sent to the proxy.
+func Dialer(u *url.URL, forward *net.Dialer) (DialFunc, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URLs must include a host: got %v", u)
+	}
+	switch u.Scheme {
+	case "http":
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialTunnel(ctx, forward, u, addr, connectHTTP)
+		}, nil
+	case "socks5", "socks5h":
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialTunnel(ctx, forward, u, addr, connectSOCKS5)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+}
+
+type handshake func(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error)
+
+func dialTunnel(ctx context.Context, forward *net.Dialer, proxy *url.URL, addr string, connect handshake) (net.Conn, error) {
+	conn, err := forward.DialContext(ctx, "tcp", proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bound the handshake by the context's deadline, if any, or the dialer's
+	// timeout.
+	deadline, ok := ctx.Deadline()
+	if !ok && forward.Timeout > 0 {
+		deadline, ok = time.Now().Add(forward.Timeout), true
+	}
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tunnel, err := connect(conn, proxy, addr)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %v", proxy.Host, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+func connectHTTP(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT to %s failed: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{conn, br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn preserves any bytes the proxy sent after its response.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// SOCKS5 constants, from RFC 1928 and RFC 1929.
+const (
+	_socksVersion     = 5
+	_socksNoAuth      = 0
+	_socksUserPass    = 2
+	_socksConnect     = 1
+	_socksIPv4        = 1
+	_socksDomain      = 3
+	_socksIPv6        = 4
+	_socksUserPassVer = 1
+)
+
+func connectSOCKS5(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 0xffff {
+		return nil, fmt.Errorf("invalid port in %q", addr)
+	}
+
+	method := byte(_socksNoAuth)
+	if proxy.User != nil {
+		method = _socksUserPass
+	}
+	if _, err := conn.Write([]byte{_socksVersion, 1, method}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != _socksVersion {
+		return nil, fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+	if reply[1] != method {
+		return nil, errors.New("no acceptable SOCKS authentication method")
+	}
+
+	if method == _socksUserPass {
+		user := proxy.User.Username()
+		password, _ := proxy.User.Password()
+		if len(user) > 255 || len(password) > 255 {
+			return nil, errors.New("SOCKS username and password must be at most 255 bytes")
+		}
+		req := []byte{_socksUserPassVer, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0 {
+			return nil, errors.New("SOCKS authentication failed")
+		}
+	}
+
+	req := []byte{_socksVersion, _socksConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name %q is too long", host)
+		}
+		req = append(req, _socksDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, _socksIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, _socksIPv6)
+		req = append(req, ip...)
+	}
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	// The reply echoes a bound address, whose length depends on its type.
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, err
+	}
+	if head[1] != 0 {
+		return nil, fmt.Errorf("SOCKS CONNECT to %s failed with code %d", addr, head[1])
+	}
+	var skip int
+	switch head[3] {
+	case _socksIPv4:
+		skip = net.IPv4len
+	case _socksIPv6:
+		skip = net.IPv6len
+	case _socksDomain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return nil, err
+		}
+		skip = int(n[0])
+	default:
+		return nil, fmt.Errorf("unexpected SOCKS address type %d", head[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
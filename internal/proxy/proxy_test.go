@@ -0,0 +1,250 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listen starts a TCP server that hands every connection to handle. The
+// returned function stops the server.
+func listen(t testing.TB, handle func(net.Conn)) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen.")
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return lis.Addr().String(), func() { lis.Close() }
+}
+
+func echoServer(t testing.TB) (string, func()) {
+	return listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		io.Copy(conn, conn)
+	})
+}
+
+func pipe(a, b net.Conn) {
+	go io.Copy(a, b)
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+}
+
+// httpProxy is a minimal CONNECT proxy that records the credentials and
+// targets of the requests it handles.
+func httpProxy(t testing.TB, auth chan<- string) (string, func()) {
+	return listen(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			conn.Close()
+			return
+		}
+		auth <- req.Header.Get("Proxy-Authorization")
+		if req.Method != http.MethodConnect {
+			io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+			conn.Close()
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			conn.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		pipe(conn, target)
+	})
+}
+
+// socksProxy is a minimal SOCKS5 proxy that accepts a single username and
+// password, or no authentication if user is empty.
+func socksProxy(t testing.TB, user, password string) (string, func()) {
+	return listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		methods := make([]byte, buf[1])
+		io.ReadFull(conn, methods)
+		if user == "" {
+			conn.Write([]byte{5, 0})
+		} else {
+			conn.Write([]byte{5, 2})
+			io.ReadFull(conn, buf)
+			u := make([]byte, buf[1])
+			io.ReadFull(conn, u)
+			io.ReadFull(conn, buf[:1])
+			p := make([]byte, buf[0])
+			io.ReadFull(conn, p)
+			if string(u) != user || string(p) != password {
+				conn.Write([]byte{1, 1})
+				return
+			}
+			conn.Write([]byte{1, 0})
+		}
+
+		head := make([]byte, 4)
+		io.ReadFull(conn, head)
+		var host string
+		switch head[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 3:
+			io.ReadFull(conn, buf[:1])
+			name := make([]byte, buf[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		}
+		io.ReadFull(conn, buf)
+		port := binary.BigEndian.Uint16(buf)
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		pipe(conn, target)
+	})
+}
+
+func assertEchoes(t testing.TB, dial DialFunc, addr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, err := dial(ctx, "tcp", addr)
+	require.NoError(t, err, "Failed to dial through proxy.")
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err, "Failed to write through proxy.")
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err, "Failed to read through proxy.")
+	assert.Equal(t, "ping", string(buf), "Unexpected echo.")
+}
+
+func TestHTTPProxy(t *testing.T) {
+	auth := make(chan string, 1)
+	proxyAddr, stopProxy := httpProxy(t, auth)
+	defer stopProxy()
+	echo, stopEcho := echoServer(t)
+	defer stopEcho()
+
+	u := &url.URL{Scheme: "http", Host: proxyAddr, User: url.UserPassword("user", "pass")}
+	dial, err := Dialer(u, &net.Dialer{})
+	require.NoError(t, err, "Unexpected error constructing dialer.")
+
+	assertEchoes(t, dial, echo)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", <-auth, "Unexpected proxy credentials.")
+}
+
+func TestHTTPProxyRejects(t *testing.T) {
+	auth := make(chan string, 1)
+	proxyAddr, stopProxy := httpProxy(t, auth)
+	defer stopProxy()
+
+	u := &url.URL{Scheme: "http", Host: proxyAddr}
+	dial, err := Dialer(u, &net.Dialer{})
+	require.NoError(t, err, "Unexpected error constructing dialer.")
+
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:1")
+	require.Error(t, err, "Expected an error when the proxy can't reach the target.")
+	assert.Contains(t, err.Error(), "502 Bad Gateway", "Unexpected error message.")
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	tests := []struct {
+		user     *url.Userinfo
+		wantErr  string
+		hostname string
+	}{
+		{user: nil, hostname: "127.0.0.1"},
+		{user: url.UserPassword("user", "pass"), hostname: "localhost"},
+		{user: url.UserPassword("user", "wrong"), hostname: "127.0.0.1", wantErr: "authentication failed"},
+	}
+
+	echo, stopEcho := echoServer(t)
+	defer stopEcho()
+	_, port, _ := net.SplitHostPort(echo)
+	for _, tt := range tests {
+		user := ""
+		if tt.user != nil {
+			user = "user"
+		}
+		proxyAddr, stopProxy := socksProxy(t, user, "pass")
+		defer stopProxy()
+
+		u := &url.URL{Scheme: "socks5", Host: proxyAddr, User: tt.user}
+		dial, err := Dialer(u, &net.Dialer{})
+		require.NoError(t, err, "Unexpected error constructing dialer.")
+
+		addr := net.JoinHostPort(tt.hostname, port)
+		if tt.wantErr == "" {
+			assertEchoes(t, dial, addr)
+			continue
+		}
+		_, err = dial(context.Background(), "tcp", addr)
+		if assert.Error(t, err, "Expected an error dialing %v.", addr) {
+			assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error message.")
+		}
+	}
+}
+
+func TestDialerErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"ftp://proxy:21", "unsupported proxy scheme"},
+		{"http:///no-host", "must include a host"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		require.NoError(t, err, "Failed to parse test URL.")
+		_, err = Dialer(u, &net.Dialer{})
+		if assert.Error(t, err, "Expected an error for proxy URL %q.", tt.url) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error for proxy URL %q.", tt.url)
+		}
+	}
+}
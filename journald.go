@@ -0,0 +1,408 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blastbao/zap/buffer"
+	"github.com/blastbao/zap/internal/bufferpool"
+	"github.com/blastbao/zap/zapcore"
+)
+
+const (
+	schemeJournald = "journald"
+
+	// _journaldSocket is where systemd-journald listens for the native
+	// protocol.
+	_journaldSocket = "/run/systemd/journal/socket"
+
+	// _journaldMaxFieldName is the longest field name journald accepts.
+	_journaldMaxFieldName = 64
+)
+
+// NewJournaldEncoder builds the "journald" encoding, which writes entries in
+// the native protocol of systemd-journald, one KEY=value pair per line. Use
+// it with a "journald://" sink, so that the journal keeps each entry's
+// fields rather than a single line of text:
+//
+//	cfg := zap.NewProductionConfig()
+//	cfg.Encoding = "journald"
+//	cfg.OutputPaths = []string{"journald://?identifier=myapp"}
+//
+// The level is written as PRIORITY, using the syslog severities: debug is 7,
+// info is 6, warn is 4, error is 3, and DPanic, Panic, and Fatal are 2
+// (critical). The message is written as MESSAGE and the caller as CODE_FILE,
+// CODE_LINE, and CODE_FUNC. If cfg.NameKey and cfg.StacktraceKey are set,
+// the logger name and stacktrace are written under those keys. The journal
+// timestamps entries itself, so the time isn't encoded.
+//
+// Field keys, after cfg.KeyMapper is applied, are converted to valid journal
+// field names: letters are upper-cased, other characters except digits
+// become underscores, and leading underscores and digits, which journald
+// reserves or rejects, are dropped. Names are truncated to 64 characters. Fields inside a namespace are prefixed with the namespace's name.
+// Objects, arrays, and reflected values are written as JSON.
+func NewJournaldEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &journaldEncoder{
+		nameKey:       journaldFieldName(cfg.NameKey),
+		stacktraceKey: journaldFieldName(cfg.StacktraceKey),
+		keyMapper:     cfg.KeyMapper,
+		buf:           bufferpool.Get(),
+	}
+}
+
+type journaldEncoder struct {
+	nameKey       string
+	stacktraceKey string
+	keyMapper     func(string) string
+	prefix        string
+	// buf holds the fields added with With.
+	buf *buffer.Buffer
+}
+
+// journaldPriority maps a Level to a syslog severity.
+func journaldPriority(l zapcore.Level) int {
+	switch {
+	case l <= DebugLevel:
+		return 7
+	case l == InfoLevel:
+		return 6
+	case l == WarnLevel:
+		return 4
+	case l == ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// journaldFieldName converts a key to a valid journal field name, truncating
+// it if necessary, and returns an empty string if nothing valid remains.
+func journaldFieldName(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+			if b.Len() == 0 {
+				continue
+			}
+		default:
+			if b.Len() == 0 {
+				continue
+			}
+			c = '_'
+		}
+		b.WriteByte(c)
+		if b.Len() == _journaldMaxFieldName {
+			break
+		}
+	}
+	return b.String()
+}
+
+// appendJournaldField appends a field in the native protocol, using the
+// length-prefixed form if the value spans several lines.
+func appendJournaldField(buf *buffer.Buffer, name, value string) {
+	if name == "" {
+		return
+	}
+	buf.AppendString(name)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.AppendByte('=')
+		buf.AppendString(value)
+		buf.AppendByte('\n')
+		return
+	}
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(value)))
+	buf.AppendByte('\n')
+	buf.Write(n[:])
+	buf.AppendString(value)
+	buf.AppendByte('\n')
+}
+
+func (e *journaldEncoder) add(key, value string) {
+	appendJournaldField(e.buf, journaldFieldName(e.prefix+e.mapKey(key)), value)
+}
+
+// mapKey applies the EncoderConfig's KeyMapper, if any.
+func (e *journaldEncoder) mapKey(key string) string {
+	if e.keyMapper == nil {
+		return key
+	}
+	return e.keyMapper(key)
+}
+
+func (e *journaldEncoder) addJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.add(key, string(b))
+	return nil
+}
+
+func (e *journaldEncoder) Clone() zapcore.Encoder {
+	clone := e.clone()
+	clone.buf.Write(e.buf.Bytes())
+	return clone
+}
+
+func (e *journaldEncoder) clone() *journaldEncoder {
+	return &journaldEncoder{
+		nameKey:       e.nameKey,
+		stacktraceKey: e.stacktraceKey,
+		keyMapper:     e.keyMapper,
+		prefix:        e.prefix,
+		buf:           bufferpool.Get(),
+	}
+}
+
+func (e *journaldEncoder) EncodeEntry(ent zapcore.Entry, fields []Field) (*buffer.Buffer, error) {
+	fields = zapcore.ResolveTimeFields(fields, ent.Time)
+	final := e.clone()
+	appendJournaldField(final.buf, "PRIORITY", strconv.Itoa(journaldPriority(ent.Level)))
+	appendJournaldField(final.buf, "MESSAGE", ent.Message)
+	if ent.LoggerName != "" {
+		appendJournaldField(final.buf, e.nameKey, ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		appendJournaldField(final.buf, "CODE_FILE", ent.Caller.File)
+		appendJournaldField(final.buf, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if fn := runtime.FuncForPC(ent.Caller.PC); fn != nil {
+			appendJournaldField(final.buf, "CODE_FUNC", fn.Name())
+		}
+	}
+	if stack := ent.Stacktrace(); stack != "" {
+		appendJournaldField(final.buf, e.stacktraceKey, stack)
+	}
+	final.buf.Write(e.buf.Bytes())
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+
+	ret := final.buf
+	final.buf = nil
+	return ret, nil
+}
+
+func (e *journaldEncoder) OpenNamespace(key string) {
+	e.prefix += e.mapKey(key) + "_"
+}
+
+func (e *journaldEncoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, v); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *journaldEncoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := v.MarshalLogObject(m); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields)
+}
+
+func (e *journaldEncoder) AddReflected(key string, v interface{}) error {
+	return e.addJSON(key, v)
+}
+
+func (e *journaldEncoder) AddBinary(key string, v []byte) {
+	e.add(key, base64.StdEncoding.EncodeToString(v))
+}
+
+func (e *journaldEncoder) AddByteString(key string, v []byte) {
+	e.add(key, string(v))
+}
+
+func (e *journaldEncoder) AddBool(key string, v bool) {
+	e.add(key, strconv.FormatBool(v))
+}
+
+func (e *journaldEncoder) AddComplex128(key string, v complex128) {
+	e.add(key, strconv.FormatComplex(v, 'g', -1, 128))
+}
+
+func (e *journaldEncoder) AddComplex64(key string, v complex64) {
+	e.add(key, strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+func (e *journaldEncoder) AddDuration(key string, v time.Duration) {
+	e.add(key, v.String())
+}
+
+func (e *journaldEncoder) AddFloat64(key string, v float64) {
+	e.add(key, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (e *journaldEncoder) AddFloat32(key string, v float32) {
+	e.add(key, strconv.FormatFloat(float64(v), 'g', -1, 32))
+}
+
+func (e *journaldEncoder) AddInt(key string, v int)     { e.AddInt64(key, int64(v)) }
+func (e *journaldEncoder) AddInt32(key string, v int32) { e.AddInt64(key, int64(v)) }
+func (e *journaldEncoder) AddInt16(key string, v int16) { e.AddInt64(key, int64(v)) }
+func (e *journaldEncoder) AddInt8(key string, v int8)   { e.AddInt64(key, int64(v)) }
+
+func (e *journaldEncoder) AddInt64(key string, v int64) {
+	e.add(key, strconv.FormatInt(v, 10))
+}
+
+func (e *journaldEncoder) AddString(key, v string) {
+	e.add(key, v)
+}
+
+func (e *journaldEncoder) AddTime(key string, v time.Time) {
+	e.add(key, v.Format(time.RFC3339Nano))
+}
+
+func (e *journaldEncoder) AddUint(key string, v uint)       { e.AddUint64(key, uint64(v)) }
+func (e *journaldEncoder) AddUint32(key string, v uint32)   { e.AddUint64(key, uint64(v)) }
+func (e *journaldEncoder) AddUint16(key string, v uint16)   { e.AddUint64(key, uint64(v)) }
+func (e *journaldEncoder) AddUint8(key string, v uint8)     { e.AddUint64(key, uint64(v)) }
+func (e *journaldEncoder) AddUintptr(key string, v uintptr) { e.AddUint64(key, uint64(v)) }
+
+func (e *journaldEncoder) AddUint64(key string, v uint64) {
+	e.add(key, strconv.FormatUint(v, 10))
+}
+
+// newJournaldSink opens a sink for URLs like "journald://" or
+// "journald:///path/to/socket". Each write is sent to systemd-journald as a
+// single datagram, or as a file descriptor if it's too large. Writes that aren't already in the native protocol (for
+// example, entries from the JSON encoder) are sent as the MESSAGE field, so
+// nothing is lost if the "journald" encoding isn't used.
+//
+// The "identifier" query parameter sets SYSLOG_IDENTIFIER on every entry.
+func newJournaldSink(u *url.URL) (Sink, error) {
+	if u.User != nil {
+		return nil, fmt.Errorf("user and password not allowed with journald URLs: got %v", u)
+	}
+	if u.Fragment != "" {
+		return nil, fmt.Errorf("fragments not allowed with journald URLs: got %v", u)
+	}
+	if u.Host != "" {
+		return nil, fmt.Errorf("journald URLs must not include a host: got %v", u)
+	}
+	s := &journaldSink{}
+	for key, vals := range u.Query() {
+		switch key {
+		case "identifier":
+			buf := bufferpool.Get()
+			appendJournaldField(buf, "SYSLOG_IDENTIFIER", vals[len(vals)-1])
+			s.identifier = append([]byte(nil), buf.Bytes()...)
+			buf.Free()
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in journald URL %v", key, u)
+		}
+	}
+
+	path := u.Path
+	if path == "" {
+		path = _journaldSocket
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("can't connect to journald: %v", err)
+	}
+	// Like sd_journal_send, use an unconnected socket, since sending file
+	// descriptors requires an explicit destination.
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to journald: %v", err)
+	}
+	s.conn = conn
+	s.addr = &net.UnixAddr{Name: path, Net: "unixgram"}
+	return s, nil
+}
+
+type journaldSink struct {
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier []byte
+}
+
+func (s *journaldSink) Write(p []byte) (int, error) {
+	msg := p
+	if len(s.identifier) > 0 || !isJournaldNative(p) {
+		buf := bufferpool.Get()
+		defer buf.Free()
+		buf.Write(s.identifier)
+		if isJournaldNative(p) {
+			buf.Write(p)
+		} else {
+			appendJournaldField(buf, "MESSAGE", string(bytes.TrimRight(p, "\n")))
+		}
+		msg = buf.Bytes()
+	}
+
+	if _, err := s.conn.WriteToUnix(msg, s.addr); err != nil {
+		// Datagrams are limited in size; journald also accepts larger
+		// entries as a file descriptor, where the platform supports it.
+		if err := sendJournaldFile(s.conn, s.addr, msg, err); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Sync is a no-op: each write is delivered as a datagram immediately.
+func (s *journaldSink) Sync() error {
+	return nil
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
+
+// isJournaldNative reports whether p looks like an entry in the native
+// protocol: that is, whether it starts with a valid field name followed by
+// '=' or a newline.
+func isJournaldNative(p []byte) bool {
+	end := bytes.IndexAny(p, "=\n")
+	if end <= 0 || end > _journaldMaxFieldName {
+		return false
+	}
+	for i, c := range p[:end] {
+		switch {
+		case c >= 'A' && c <= 'Z', c == '_' && i > 0, c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package zap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournaldFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user":                  "USER",
+		"request.id":            "REQUEST_ID",
+		"_private":              "PRIVATE",
+		"2fa":                   "FA",
+		"a2":                    "A2",
+		"":                      "",
+		"!!!":                   "",
+		strings.Repeat("x", 70): strings.Repeat("X", 64),
+	}
+	for key, want := range tests {
+		assert.Equal(t, want, journaldFieldName(key), "Unexpected field name for %q.", key)
+	}
+}
+
+func TestJournaldEncoder(t *testing.T) {
+	enc := NewJournaldEncoder(zapcore.EncoderConfig{NameKey: "logger", StacktraceKey: "stacktrace"})
+	String("user", "bob").AddTo(enc)
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:      WarnLevel,
+		Message:    "hello",
+		LoggerName: "main",
+		Stack:      "line1\nline2",
+	}, []Field{
+		Int("attempt", 3),
+		Strings("tags", []string{"a", "b"}),
+		Namespace("req"),
+		String("path", "/"),
+	})
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	defer buf.Free()
+
+	stack := "line1\nline2"
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(stack)))
+	assert.Equal(t, strings.Join([]string{
+		"PRIORITY=4\n",
+		"MESSAGE=hello\n",
+		"LOGGER=main\n",
+		"STACKTRACE\n", string(n[:]), stack, "\n",
+		"USER=bob\n",
+		"ATTEMPT=3\n",
+		`TAGS=["a","b"]` + "\n",
+		"REQ_PATH=/\n",
+	}, ""), buf.String(), "Unexpected encoding.")
+}
+
+func TestJournaldEncoderLazyStack(t *testing.T) {
+	enc := NewJournaldEncoder(zapcore.EncoderConfig{StacktraceKey: "stacktrace"})
+	stack := zapcore.NewLazyStack([]uintptr{1}, func([]uintptr) string { return "lazy" })
+	buf, err := enc.EncodeEntry(zapcore.Entry{Level: ErrorLevel, Message: "oops", LazyStack: stack}, nil)
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	defer buf.Free()
+	assert.Equal(t, "PRIORITY=3\nMESSAGE=oops\nSTACKTRACE=lazy\n", buf.String(), "Expected the lazy stack trace to be symbolized.")
+}
+
+func TestJournaldEncoderKeyMapper(t *testing.T) {
+	enc := NewJournaldEncoder(zapcore.EncoderConfig{
+		NameKey:   "logger",
+		KeyMapper: zapcore.KeyMap(map[string]string{"user": "user.name", "req": "http"}),
+	})
+	String("user", "bob").AddTo(enc)
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hello", LoggerName: "main"}, []Field{
+		Namespace("req"),
+		String("path", "/"),
+	})
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	defer buf.Free()
+	assert.Equal(t, "PRIORITY=6\nMESSAGE=hello\nLOGGER=main\nUSER_NAME=bob\nHTTP_PATH=/\n", buf.String(), "Unexpected encoding.")
+}
+
+func TestJournaldPriority(t *testing.T) {
+	want := map[zapcore.Level]int{
+		DebugLevel:  7,
+		InfoLevel:   6,
+		WarnLevel:   4,
+		ErrorLevel:  3,
+		DPanicLevel: 2,
+		PanicLevel:  2,
+		FatalLevel:  2,
+	}
+	for lvl, p := range want {
+		assert.Equal(t, p, journaldPriority(lvl), "Unexpected priority for %v.", lvl)
+	}
+}
+
+// withJournald runs f with the path of a fake journald socket and a function
+// that reads the next entry it receives, whether as a datagram or as a file
+// descriptor.
+func withJournald(t testing.TB, f func(path string, recv func() string)) {
+	dir, err := ioutil.TempDir("", "zap-journald-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err, "Failed to listen.")
+	defer conn.Close()
+
+	f(path, func() string {
+		buf, oob := make([]byte, 4096), make([]byte, syscall.CmsgSpace(4))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		require.NoError(t, err, "Failed to read datagram.")
+		if oobn == 0 {
+			return string(buf[:n])
+		}
+
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, err, "Failed to parse control message.")
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		require.NoError(t, err, "Failed to parse file descriptors.")
+		f := os.NewFile(uintptr(fds[0]), "entry")
+		defer f.Close()
+		f.Seek(0, io.SeekStart)
+		contents, err := ioutil.ReadAll(f)
+		require.NoError(t, err, "Failed to read entry from file descriptor.")
+		return string(contents)
+	})
+}
+
+func TestJournaldSink(t *testing.T) {
+	withJournald(t, func(path string, recv func() string) {
+		sink, err := newSink("journald://" + path + "?identifier=app")
+		require.NoError(t, err, "Failed to open journald sink.")
+		defer sink.Close()
+
+		_, err = sink.Write([]byte("PRIORITY=6\nMESSAGE=native\n"))
+		require.NoError(t, err, "Unexpected error writing native entry.")
+		assert.Equal(t, "SYSLOG_IDENTIFIER=app\nPRIORITY=6\nMESSAGE=native\n", recv(), "Unexpected native datagram.")
+
+		_, err = sink.Write([]byte(`{"msg":"json"}` + "\n"))
+		require.NoError(t, err, "Unexpected error writing JSON entry.")
+		assert.Equal(t, "SYSLOG_IDENTIFIER=app\nMESSAGE={\"msg\":\"json\"}\n", recv(), "Expected other encodings to be sent as MESSAGE.")
+
+		assert.NoError(t, sink.Sync(), "Unexpected error syncing.")
+	})
+}
+
+func TestJournaldLogger(t *testing.T) {
+	withJournald(t, func(path string, recv func() string) {
+		cfg := NewProductionConfig()
+		cfg.Sampling = nil
+		cfg.Encoding = "journald"
+		cfg.OutputPaths = []string{"journald://" + path}
+		logger, err := cfg.Build()
+		require.NoError(t, err, "Failed to build logger.")
+
+		logger.Error("failed", Error(errors.New("boom")))
+		got := recv()
+		assert.True(t, strings.HasPrefix(got, "PRIORITY=3\nMESSAGE=failed\nCODE_FILE="), "Unexpected datagram: %q.", got)
+		assert.Regexp(t, `CODE_FUNC=\S+\.TestJournaldLogger`, got, "Expected the calling function.")
+		assert.Contains(t, got, "ERROR=boom\n", "Expected fields to be encoded.")
+	})
+}
+
+func TestJournaldSinkLargeEntries(t *testing.T) {
+	withJournald(t, func(path string, recv func() string) {
+		sink, err := newSink("journald://" + path)
+		require.NoError(t, err, "Failed to open journald sink.")
+		defer sink.Close()
+
+		entry := "MESSAGE=" + strings.Repeat("x", 4<<20) + "\n"
+		_, err = sink.Write([]byte(entry))
+		require.NoError(t, err, "Expected large entries to be sent as a file descriptor.")
+		assert.Equal(t, entry, recv(), "Unexpected entry.")
+	})
+}
+
+func TestJournaldSinkErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"journald://host", "must not include a host"},
+		{"journald://?color=blue", "unknown query parameter"},
+		{"journald:///does/not/exist", "can't connect to journald"},
+	}
+	for _, tt := range tests {
+		_, err := newSink(tt.url)
+		if assert.Error(t, err, "Expected an error for URL %q.", tt.url) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error for URL %q.", tt.url)
+		}
+	}
+}
+
+func TestIsJournaldNative(t *testing.T) {
+	assert.True(t, isJournaldNative([]byte("MESSAGE=hi\n")), "Expected KEY=value to be native.")
+	assert.True(t, isJournaldNative([]byte("MESSAGE\n\x02\x00\x00\x00\x00\x00\x00\x00hi\n")), "Expected binary fields to be native.")
+	assert.False(t, isJournaldNative([]byte(`{"msg":"hi"}`)), "Expected JSON not to be native.")
+	assert.False(t, isJournaldNative([]byte("2020-01-01T00:00:00Z\tINFO\thi")), "Expected console output not to be native.")
+	assert.False(t, isJournaldNative([]byte("_PID=1\n")), "Expected reserved names not to be native.")
+}
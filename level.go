@@ -21,11 +21,16 @@
 package zap
 
 import (
+	"sync"
+
 	"go.uber.org/atomic"
 	"github.com/blastbao/zap/zapcore"
 )
 
 const (
+	// TraceLevel logs are even more voluminous than Debug logs, for very
+	// chatty subsystems.
+	TraceLevel = zapcore.TraceLevel
 	// DebugLevel logs are typically voluminous, and are usually disabled in
 	// production.
 	DebugLevel = zapcore.DebugLevel
@@ -68,13 +73,24 @@ func (f LevelEnablerFunc) Enabled(lvl zapcore.Level) bool { return f(lvl) }
 // their internal atomic pointer.
 type AtomicLevel struct {
 	l *atomic.Int32
+
+	// listeners is shared by copies of the AtomicLevel, like l.
+	listeners *levelListeners
+}
+
+// levelListeners holds the callbacks registered with AtomicLevel.OnChange.
+// Its mutex also serializes changes, so that callbacks see them in order.
+type levelListeners struct {
+	mu sync.Mutex
+	fs []func(old, new zapcore.Level)
 }
 
 // NewAtomicLevel creates an AtomicLevel with InfoLevel and above logging
 // enabled.
 func NewAtomicLevel() AtomicLevel {
 	return AtomicLevel{
-		l: atomic.NewInt32(int32(InfoLevel)),
+		l:         atomic.NewInt32(int32(InfoLevel)),
+		listeners: &levelListeners{},
 	}
 }
 
@@ -97,9 +113,33 @@ func (lvl AtomicLevel) Level() zapcore.Level {
 	return zapcore.Level(int8(lvl.l.Load()))
 }
 
-// SetLevel alters the logging level.
+// SetLevel alters the logging level, and calls the callbacks registered
+// with OnChange if the level changed.
 func (lvl AtomicLevel) SetLevel(l zapcore.Level) {
-	lvl.l.Store(int32(l))
+	lvl.listeners.mu.Lock()
+	defer lvl.listeners.mu.Unlock()
+
+	old := zapcore.Level(int8(lvl.l.Swap(int32(l))))
+	if old == l {
+		return
+	}
+	for _, f := range lvl.listeners.fs {
+		f(old, l)
+	}
+}
+
+// OnChange registers f to be called with the old and new levels whenever
+// the level changes, whether through SetLevel or the AtomicLevel's HTTP
+// handler, so that subsystems such as verbose tracing can follow operators'
+// changes. Setting the level it already has doesn't call f.
+//
+// Callbacks run synchronously, in the order they were registered, on the
+// goroutine changing the level. They should be quick, and mustn't change
+// the level themselves.
+func (lvl AtomicLevel) OnChange(f func(old, new zapcore.Level)) {
+	lvl.listeners.mu.Lock()
+	lvl.listeners.fs = append(lvl.listeners.fs, f)
+	lvl.listeners.mu.Unlock()
 }
 
 // String returns the string representation of the underlying Level.
@@ -113,6 +153,7 @@ func (lvl AtomicLevel) String() string {
 func (lvl *AtomicLevel) UnmarshalText(text []byte) error {
 	if lvl.l == nil {
 		lvl.l = &atomic.Int32{}
+		lvl.listeners = &levelListeners{}
 	}
 
 	var l zapcore.Level
@@ -21,6 +21,8 @@
 package zap
 
 import (
+	"net/http"
+	"strings"
 	"sync"
 	"testing"
 
@@ -75,12 +77,43 @@ func TestAtomicLevelMutation(t *testing.T) {
 	wg.Wait()
 }
 
+func TestAtomicLevelOnChange(t *testing.T) {
+	type change struct{ old, new zapcore.Level }
+	var first, second []change
+	lvl := NewAtomicLevel()
+	lvl.OnChange(func(old, new zapcore.Level) { first = append(first, change{old, new}) })
+	lvl.OnChange(func(old, new zapcore.Level) { second = append(second, change{old, new}) })
+
+	lvl.SetLevel(DebugLevel)
+	lvl.SetLevel(DebugLevel)
+	copied := lvl
+	copied.SetLevel(ErrorLevel)
+
+	expected := []change{{InfoLevel, DebugLevel}, {DebugLevel, ErrorLevel}}
+	assert.Equal(t, expected, first, "Unexpected changes seen by the first callback.")
+	assert.Equal(t, expected, second, "Unexpected changes seen by the second callback.")
+
+	code, _ := makeRequest(t, "PUT", lvl, strings.NewReader(`{"level":"warn"}`))
+	assert.Equal(t, http.StatusOK, code, "Unexpected response status code.")
+	assert.Equal(t, change{ErrorLevel, WarnLevel}, first[len(first)-1], "Expected the HTTP handler to notify callbacks.")
+}
+
+func TestAtomicLevelOnChangeUnmarshaled(t *testing.T) {
+	var lvl AtomicLevel
+	assert.NoError(t, lvl.UnmarshalText([]byte("debug")), "Unexpected error unmarshaling level.")
+	var changes int
+	lvl.OnChange(func(zapcore.Level, zapcore.Level) { changes++ })
+	lvl.SetLevel(WarnLevel)
+	assert.Equal(t, 1, changes, "Expected unmarshaled levels to support callbacks.")
+}
+
 func TestAtomicLevelText(t *testing.T) {
 	tests := []struct {
 		text   string
 		expect zapcore.Level
 		err    bool
 	}{
+		{"trace", TraceLevel, false},
 		{"debug", DebugLevel, false},
 		{"info", InfoLevel, false},
 		{"", InfoLevel, false},
@@ -21,14 +21,21 @@
 package zap
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blastbao/zap/zapcore"
+
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
 )
 
 //A Logger provides fast, leveled, structured logging. All methods are safe
@@ -54,17 +61,61 @@ type Logger struct {
 	// logger name
 	name        string
 
-	// 日志组件中出现异常时的输出
+	// 日志组件中出现异常时的输出，由 setErrorOutput 包装以节流和统计内部错误
 	errorOutput zapcore.WriteSyncer
 
+	// ErrorOutput 设置的原始错误输出，未经节流和统计
+	errorDest zapcore.WriteSyncer
+
+	// 通过 ThrottleErrorOutput 选项设置，为 nil 时不节流
+	errorThrottle *errorThrottle
+
+	// 由 Logger 及其派生的所有 Logger 共享，统计内部错误的数量
+	internalErrors *atomic.Uint64
+
+	// 通过 ErrorSink 选项注册，内部错误除了写入 errorOutput 外还会交给它处理
+	errorSink func(error, zapcore.Entry)
+
 	// 在日志输出内容里增加行号和文件名
 	addCaller bool
 
 	// 对指定的日志等级增加调用栈输出能力
 	addStack  zapcore.LevelEnabler
 
+	// 只在日志点捕获 PC，符号化推迟到编码时进行
+	deferStack bool
+
 	// 指定在调用栈中跳过的调用深度
 	callerSkip int
+
+	// 在编码前改写调用者的文件路径，例如去掉构建沙箱的前缀
+	callerPaths callerPathRewriter
+
+	// 非空时，为每个条目添加该 key 下的日志点指纹，见 Fingerprint
+	fingerprintKey string
+
+	// 由 Logger 及其派生的所有 Logger 共享，记录是否已经 Shutdown 以及需要关闭的资源
+	shutdown *shutdownState
+
+	// 通过 With 和 Fields 选项累积的字段，供 Logger.Fields 返回
+	fields *fieldChain
+
+	// 日志条目的时间来源，默认为系统时钟
+	clock zapcore.Clock
+
+	// 在 panic 或 exit 之前运行的 hooks，例如上报崩溃信息
+	terminalHooks []zapcore.CheckWriteHook
+
+	// 调试用：记录每个字段是在哪里添加的，见 TrackFieldOrigins
+	provenance   *FieldProvenance
+	fieldOrigins []FieldOrigin
+	fieldsNested bool
+
+	// 通过 ContextExtractor 注册，WithContext 绑定 context 时从中提取字段
+	contextExtractors []func(context.Context) []Field
+
+	// 通过 WithContext 绑定的 context，随 Entry 传递给 Core 以约束同步写入的时限
+	ctx context.Context
 }
 
 // New constructs a new Logger from the provided zapcore.Core and Options.
@@ -84,10 +135,13 @@ func New(core zapcore.Core, options ...Option) *Logger {
 
 	// 构造 Logger
 	log := &Logger{
-		core:        core,
-		errorOutput: zapcore.Lock(os.Stderr),  	// zap 内部错误输出到 stdErr
-		addStack:    zapcore.FatalLevel + 1, 	// 对指定的日志等级增加调用栈输出能力
+		core:           core,
+		addStack:       zapcore.FatalLevel + 1, 	// 对指定的日志等级增加调用栈输出能力
+		shutdown:       &shutdownState{},
+		clock:          zapcore.DefaultClock,
+		internalErrors: atomic.NewUint64(0),
 	}
+	log.setErrorOutput(zapcore.Lock(os.Stderr)) // zap 内部错误输出到 stdErr
 
 	// 在 logger 上应用各个 options
 	return log.WithOptions(options...)
@@ -101,11 +155,15 @@ func New(core zapcore.Core, options ...Option) *Logger {
 //
 //
 func NewNop() *Logger {
-	return &Logger{
-		core:        zapcore.NewNopCore(),
-		errorOutput: zapcore.AddSync(ioutil.Discard),
-		addStack:    zapcore.FatalLevel + 1,
+	log := &Logger{
+		core:           zapcore.NewNopCore(),
+		addStack:       zapcore.FatalLevel + 1,
+		shutdown:       &shutdownState{},
+		clock:          zapcore.DefaultClock,
+		internalErrors: atomic.NewUint64(0),
 	}
+	log.setErrorOutput(zapcore.AddSync(ioutil.Discard))
+	return log
 }
 
 
@@ -192,33 +250,131 @@ func (log *Logger) WithOptions(opts ...Option) *Logger {
 // With creates a child logger and adds structured context to it.
 // Fields added to the child don't affect the parent, and vice versa.
 func (log *Logger) With(fields ...Field) *Logger {
+	return log.with(fields)
+}
+
+// with implements With for Loggers and SugaredLogger, which must call it
+// directly so that TrackFieldOrigins finds their callers.
+func (log *Logger) with(fields []Field) *Logger {
 	if len(fields) == 0 {
 		return log
 	}
 	l := log.clone()
 	l.core = l.core.With(fields)
+	l.fields = l.fields.append(fields)
+	if l.provenance != nil {
+		l.trackWith(fields, 2)
+	}
 	return l
 }
 
+// Fields returns a copy of the fields added to the Logger with With or the
+// Fields option, in the order they were added. Middleware can use it to
+// avoid adding fields twice, and tests to check a Logger's context.
+func (log *Logger) Fields() []Field {
+	return log.fields.all()
+}
+
+// fieldChain holds a Logger's fields as a list of the fields added by each
+// With, linked to those of its parent, so that With only copies the fields
+// it adds. A nil *fieldChain has no fields.
+type fieldChain struct {
+	parent *fieldChain
+	fields []Field
+	len    int // number of fields in the chain, including the parents'
+}
+
+// append returns a chain with fields added after c's.
+func (c *fieldChain) append(fields []Field) *fieldChain {
+	if len(fields) == 0 {
+		return c
+	}
+	return &fieldChain{
+		parent: c,
+		fields: append([]Field(nil), fields...),
+		len:    c.Len() + len(fields),
+	}
+}
+
+// Len returns the number of fields in the chain.
+func (c *fieldChain) Len() int {
+	if c == nil {
+		return 0
+	}
+	return c.len
+}
+
+// all returns the chain's fields, in the order they were added.
+func (c *fieldChain) all() []Field {
+	if c == nil {
+		return nil
+	}
+	out := make([]Field, c.len)
+	for ; c != nil; c = c.parent {
+		copy(out[c.len-len(c.fields):], c.fields)
+	}
+	return out
+}
+
+// has reports whether the chain holds a field equal to f.
+func (c *fieldChain) has(f Field) bool {
+	for ; c != nil; c = c.parent {
+		if hasField(c.fields, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendFields appends fields to dst without sharing dst's backing array.
+func appendFields(dst, fields []Field) []Field {
+	return append(dst[:len(dst):len(dst)], fields...)
+}
+
 
 
 
 // Check returns a CheckedEntry if logging a message at the specified level is enabled.
 // It's a completely optional optimization; in high-performance applications,
 // Check can help avoid allocating a slice to hold fields.
-func (log *Logger) Check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
-	return log.check(lvl, msg)
+//
+// Options such as WithCallerSkip apply to this entry only.
+func (log *Logger) Check(lvl zapcore.Level, msg string, opts ...CheckOption) *zapcore.CheckedEntry {
+	return log.check(lvl, msg, nil, opts...)
+}
+
+// Enabled reports whether the Logger writes entries at the given level. It's
+// a cheap way to skip building expensive fields, or whole branches of
+// diagnostic code, without the ceremony of Check:
+//
+//	if logger.Enabled(zap.DebugLevel) {
+//		logger.Debug("state", zap.Any("snapshot", expensiveSnapshot()))
+//	}
+//
+// Cores that decide per entry, such as samplers, may still drop entries at
+// an enabled level. Enabled is false for every level after Shutdown.
+func (log *Logger) Enabled(lvl zapcore.Level) bool {
+	return !log.isShutdown() && log.core.Enabled(lvl)
 }
 
 
 
 
 
+// Trace logs a message at TraceLevel.
+// The message includes any fields passed at the log site,
+// as well as any fields accumulated on the logger.
+func (log *Logger) Trace(msg string, fields ...Field) {
+	if ce := log.check(TraceLevel, msg, fields); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
 // Debug logs a message at DebugLevel.
 // The message includes any fields passed at the log site,
 // as well as any fields accumulated on the logger.
 func (log *Logger) Debug(msg string, fields ...Field) {
-	if ce := log.check(DebugLevel, msg); ce != nil {
+	if ce := log.check(DebugLevel, msg, fields); ce != nil {
 		ce.Write(fields...)
 	}
 }
@@ -228,7 +384,7 @@ func (log *Logger) Debug(msg string, fields ...Field) {
 // as well as any fields accumulated on the logger.
 func (log *Logger) Info(msg string, fields ...Field) {
 	// log.check() 检查 InfoLevel 级别日志是否应该输出，如果应该则会返回 CheckedEntry 结构体 ce，ce 中包含了需要输出到文件的信息。
-	if ce := log.check(InfoLevel, msg); ce != nil {
+	if ce := log.check(InfoLevel, msg, fields); ce != nil {
 		// 遍历 ce.cores 逐个调用 ce.cores[i].Write(ce.Entry, fields...) 函数，以将 Entry 和 fields 写入多个目标文件中。
 		ce.Write(fields...)
 	}
@@ -237,7 +393,7 @@ func (log *Logger) Info(msg string, fields ...Field) {
 // Warn logs a message at WarnLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 func (log *Logger) Warn(msg string, fields ...Field) {
-	if ce := log.check(WarnLevel, msg); ce != nil {
+	if ce := log.check(WarnLevel, msg, fields); ce != nil {
 		ce.Write(fields...)
 	}
 }
@@ -245,19 +401,32 @@ func (log *Logger) Warn(msg string, fields ...Field) {
 // Error logs a message at ErrorLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 func (log *Logger) Error(msg string, fields ...Field) {
-	if ce := log.check(ErrorLevel, msg); ce != nil {
+	if ce := log.check(ErrorLevel, msg, fields); ce != nil {
 		ce.Write(fields...)
 	}
 }
 
+// PanicError is the value Logger.Panic and Logger.DPanic panic with. It
+// carries the entry, including its message, and the fields passed at the log
+// site:
+//
+//	defer func() {
+//		if r := recover(); r != nil {
+//			if pe, ok := r.(zap.PanicError); ok {
+//				report(pe.Entry.Message, pe.Fields)
+//			}
+//		}
+//	}()
+type PanicError = zapcore.PanicError
+
 // DPanic logs a message at DPanicLevel. The message includes any fields
 // passed at the log site, as well as any fields accumulated on the logger.
 //
 // If the logger is in development mode, it then panics (DPanic means
-// "development panic"). This is useful for catching errors that are
-// recoverable, but shouldn't ever happen.
+// "development panic") with a PanicError. This is useful for catching errors
+// that are recoverable, but shouldn't ever happen.
 func (log *Logger) DPanic(msg string, fields ...Field) {
-	if ce := log.check(DPanicLevel, msg); ce != nil {
+	if ce := log.check(DPanicLevel, msg, fields); ce != nil {
 		ce.Write(fields...)
 	}
 }
@@ -265,9 +434,10 @@ func (log *Logger) DPanic(msg string, fields ...Field) {
 // Panic logs a message at PanicLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 //
-// The logger then panics, even if logging at PanicLevel is disabled.
+// The logger then panics with a PanicError holding the entry and fields,
+// even if logging at PanicLevel is disabled.
 func (log *Logger) Panic(msg string, fields ...Field) {
-	if ce := log.check(PanicLevel, msg); ce != nil {
+	if ce := log.check(PanicLevel, msg, fields); ce != nil {
 		ce.Write(fields...)
 	}
 }
@@ -277,15 +447,109 @@ func (log *Logger) Panic(msg string, fields ...Field) {
 //
 // The logger then calls os.Exit(1), even if logging at FatalLevel is disabled.
 func (log *Logger) Fatal(msg string, fields ...Field) {
-	if ce := log.check(FatalLevel, msg); ce != nil {
+	if ce := log.check(FatalLevel, msg, fields); ce != nil {
 		ce.Write(fields...)
 	}
 }
 
 // Sync calls the underlying Core's Sync method, flushing any buffered log
 // entries. Applications should take care to call Sync before exiting.
+//
+// Errors from every output are aggregated, except for those that only mean
+// an output can't be synced (for example, syncing a terminal or pipe fails
+// with EINVAL on Linux and ERROR_INVALID_HANDLE on Windows); since those
+// outputs don't buffer, they're ignored.
+//
+// If the Core is, or wraps, a zapcore.Flusher, such as an async Core, Sync
+// flushes it before syncing, so that the entries it holds are written first.
+// Wrappers are followed with zapcore.UnwrapCore.
 func (log *Logger) Sync() error {
-	return log.core.Sync()
+	return log.sync(context.Background())
+}
+
+// sync is Sync, but gives up flushing the Core if ctx is done first.
+func (log *Logger) sync(ctx context.Context) error {
+	var err error
+	if f, ok := zapcore.AsFlusher(log.core); ok {
+		if err = f.Flush(ctx); ctx.Err() != nil {
+			return err
+		}
+	}
+	return multierr.Append(err, dropIgnorableSyncErrors(log.core.Sync()))
+}
+
+// errCallerUnavailable is reported to the ErrorSink when AddCaller can't find
+// the caller of a log call.
+var errCallerUnavailable = errors.New("failed to get caller")
+
+// stopper is implemented by Cores that can be stopped within a deadline,
+// such as zapcore.AsyncCore.
+type stopper interface {
+	Stop(context.Context) error
+}
+
+// shutdownState is shared by a Logger and every Logger derived from it with
+// With, Named, or WithOptions.
+type shutdownState struct {
+	mu      sync.Mutex
+	stopped atomic.Bool
+	closers []func() error
+}
+
+func (log *Logger) isShutdown() bool {
+	return log.shutdown != nil && log.shutdown.stopped.Load()
+}
+
+// Shutdown stops the Logger, and every Logger derived from it, from
+// accepting new entries; log calls made afterwards are silently dropped,
+// though Panic and Fatal still panic and exit. It then flushes the Core as
+// Sync does, stops the Core if it's a zapcore.AsyncCore or otherwise closes
+// it if it implements io.Closer (as buffering cores may, to stop their
+// background goroutines), and closes the outputs opened by Config.Build.
+// Cores wrapped by the Core, as reported by zapcore.UnwrapCore, are stopped
+// or closed the same way, outermost first.
+// Async cores are stopped with ctx, so entries they haven't written by the
+// time ctx is done are dropped rather than holding up shutdown.
+//
+// If ctx is done before all of that finishes, Shutdown returns ctx.Err() and
+// the remaining work continues in the background. Calling Shutdown more than
+// once does nothing and returns nil.
+func (log *Logger) Shutdown(ctx context.Context) error {
+	state := log.shutdown
+	if state == nil {
+		return log.Sync()
+	}
+	state.mu.Lock()
+	if state.stopped.Load() {
+		state.mu.Unlock()
+		return nil
+	}
+	state.stopped.Store(true)
+	closers := state.closers
+	state.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		err := log.sync(ctx)
+		for core := log.core; core != nil; core = zapcore.UnwrapCore(core) {
+			if s, ok := core.(stopper); ok {
+				err = multierr.Append(err, s.Stop(ctx))
+			} else if c, ok := core.(io.Closer); ok {
+				err = multierr.Append(err, c.Close())
+			}
+		}
+		for _, c := range closers {
+			err = multierr.Append(err, c())
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Core returns the Logger's underlying zapcore.Core.
@@ -293,6 +557,70 @@ func (log *Logger) Core() zapcore.Core {
 	return log.core
 }
 
+// ErrorOutputStats reports how many internal errors the Logger has written
+// to and dropped from its error output. The counts are only maintained if
+// the Logger was built with ThrottleErrorOutput; otherwise, they're zero.
+func (log *Logger) ErrorOutputStats() zapcore.ThrottleStats {
+	if t, ok := log.rawErrorOutput().(*zapcore.ThrottledWriteSyncer); ok {
+		return t.Stats()
+	}
+	return zapcore.ThrottleStats{}
+}
+
+// InternalErrors returns the number of internal errors, such as failures to
+// encode entries or write to sinks, that the Logger and every Logger derived
+// from it have reported, including any that ThrottleErrorOutput dropped. It
+// can be exported as a metric, for example with expvar:
+//
+//	expvar.Publish("zap_internal_errors", expvar.Func(func() interface{} {
+//		return logger.InternalErrors()
+//	}))
+func (log *Logger) InternalErrors() uint64 {
+	if log.internalErrors == nil {
+		return 0
+	}
+	return log.internalErrors.Load()
+}
+
+// errorThrottle holds the settings of ThrottleErrorOutput.
+type errorThrottle struct {
+	first    int
+	interval time.Duration
+}
+
+// setErrorOutput replaces the Logger's error output, throttling it if
+// ThrottleErrorOutput was used and counting each internal error written to
+// it.
+func (log *Logger) setErrorOutput(ws zapcore.WriteSyncer) {
+	log.errorDest = ws
+	if t := log.errorThrottle; t != nil {
+		ws = zapcore.NewThrottledWriteSyncer(ws, t.first, t.interval)
+	}
+	log.errorOutput = &countingErrorOutput{WriteSyncer: ws, count: log.internalErrors}
+}
+
+// rawErrorOutput returns the error output without its counter.
+func (log *Logger) rawErrorOutput() zapcore.WriteSyncer {
+	if c, ok := log.errorOutput.(*countingErrorOutput); ok {
+		return c.WriteSyncer
+	}
+	return log.errorOutput
+}
+
+// countingErrorOutput counts writes to a Logger's error output. Every
+// internal error is reported with a single write.
+type countingErrorOutput struct {
+	zapcore.WriteSyncer
+	count *atomic.Uint64
+}
+
+func (c *countingErrorOutput) Write(p []byte) (int, error) {
+	if c.count != nil {
+		c.count.Inc()
+	}
+	return c.WriteSyncer.Write(p)
+}
+
 func (log *Logger) clone() *Logger {
 	copy := *log
 	return &copy
@@ -303,7 +631,7 @@ func (log *Logger) clone() *Logger {
 // 3. 如果 ce != nil 则需要执行写操作，设置 willWrite 变量为 true ，否则直接返回 nil 。
 // 4. 填充 ce.ErrorOutput、ce.Entry.Caller、ce.Entry.Stack 等信息。
 // 5. 返回 ce 。
-func (log *Logger) check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
+func (log *Logger) check(lvl zapcore.Level, msg string, fields []Field, opts ...CheckOption) *zapcore.CheckedEntry {
 
 	// check must always be called directly by a method in the Logger interface (e.g., Check, Info, Fatal).
 	const callerSkipOffset = 2
@@ -312,15 +640,33 @@ func (log *Logger) check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
 	// this will be non-nil if the log message will actually be written somewhere.
 	//
 	// 1. 创建 Entry 并存储当前已确定的部分信息，比如 logger name、timestamp、level、msg 字段。
+	// 应用 Check 的单次调用选项
+	var cfg checkConfig
+	if len(opts) > 0 {
+		cfg = newCheckConfig(opts)
+	}
+
 	ent := zapcore.Entry{
 		LoggerName: log.name,		// logger name
-		Time:       time.Now(), 	// 时间
+		Time:       log.clock.Now(), // 时间
 		Level:      lvl,			// 级别
 		Message:    msg, 			// 内容
+		Context:    log.ctx,		// 绑定的 context
+	}
+
+	// 字段或选项可以覆盖采样器对该条目的决定
+	ent.Sampling = cfg.sampling
+	if o := samplingOverride(fields); o != 0 {
+		ent.Sampling = o
 	}
 
 	// 2. （重要）创建 CheckedEntry 结构体 ce 并把 log.core 添加 ce.cores 中，这些 ce.cores 会在 ce.Write() 中被逐个调用。
-	ce := log.core.Check(ent, nil)
+	//
+	// Shutdown 之后不再写入任何日志，但 Panic、Fatal 等级别的终止行为仍然保留。
+	var ce *zapcore.CheckedEntry
+	if !log.isShutdown() {
+		ce = log.core.Check(ent, nil)
+	}
 
 	// 3. 如果 ce 为 nil 则不会发生写行为
 	willWrite := ce != nil
@@ -329,6 +675,7 @@ func (log *Logger) check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
 	//
 	// 判断 ent.Level 是否为特殊级别，主要是会导致进程退出的 PanicLevel，FatalLevel，DPanicLevel；
 	// 在这几个级别下，进程已经发生了严重错误，需要特殊处理。
+	terminal := true
 	switch ent.Level {
 	case zapcore.PanicLevel:
 		ce = ce.Should(ent, zapcore.WriteThenPanic)
@@ -337,6 +684,17 @@ func (log *Logger) check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
 	case zapcore.DPanicLevel:
 		if log.development {
 			ce = ce.Should(ent, zapcore.WriteThenPanic)
+		} else {
+			terminal = false
+		}
+	default:
+		terminal = false
+	}
+
+	// 对于会导致 panic 或 exit 的条目，先运行通过 WithTerminalHooks 注册的 hooks。
+	if terminal {
+		for _, hook := range log.terminalHooks {
+			ce = ce.After(ent, hook)
 		}
 	}
 
@@ -353,22 +711,45 @@ func (log *Logger) check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
 
 	// Thread the error output through to the CheckedEntry.
 	ce.ErrorOutput = log.errorOutput
+	ce.ErrorSink = log.errorSink
 
 	// 判断是否需要打印文件名、行号，如果需要，调用 runtime.Caller(）获取并附加进entry里。
 	if log.addCaller {
 		// 保存调用者信息到 ce.Entry.Caller 中
-		ce.Entry.Caller = zapcore.NewEntryCaller(runtime.Caller(log.callerSkip + callerSkipOffset))
+		ce.Entry.Caller = zapcore.NewEntryCaller(runtime.Caller(log.callerSkip + cfg.callerSkip + callerSkipOffset))
 
 		// 如果调用 runtime.Caller(）失败，则输出错误信息到 log.errorOutput 中，并实时的 sync 刷盘。
 		if !ce.Entry.Caller.Defined {
-			fmt.Fprintf(log.errorOutput, "%v Logger.check error: failed to get caller\n", time.Now().UTC())
+			fmt.Fprintf(log.errorOutput, "%v Logger.check error: failed to get caller\n", log.clock.Now().UTC())
 			log.errorOutput.Sync()
+			if log.errorSink != nil {
+				log.errorSink(errCallerUnavailable, ce.Entry)
+			}
+		}
+
+		// 按 RewriteCallerPaths 配置改写调用者的文件路径
+		if ce.Entry.Caller.Defined && log.callerPaths != nil {
+			ce.Entry.Caller.File = log.callerPaths.rewrite(ce.Entry.Caller.File)
 		}
 	}
 
 	// 判断是否需要打印调用栈，如果需要，调用 runtime.CallersFrames(）获取并附加到 ce.Entry.Stack 里。
 	if log.addStack.Enabled(ce.Entry.Level) {
-		ce.Entry.Stack = Stack("").String
+		if log.deferStack {
+			ce.Entry.LazyStack = captureStacktrace()
+		} else {
+			ce.Entry.Stack = Stack("").String
+		}
+	}
+
+	// 添加日志点指纹
+	if log.fingerprintKey != "" {
+		ce = log.addFingerprint(ce, cfg.template)
+	}
+
+	// 记录调用点传入的字段来源
+	if log.provenance != nil {
+		ce = log.trackCallSite(ce)
 	}
 
 	return ce
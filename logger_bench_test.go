@@ -22,6 +22,7 @@ package zap
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -179,6 +180,29 @@ func BenchmarkAddCallerHook(b *testing.B) {
 	})
 }
 
+func BenchmarkWith(b *testing.B) {
+	for _, n := range []int{0, 10, 100} {
+		b.Run(fmt.Sprintf("%d existing", n), func(b *testing.B) {
+			fields := make([]Field, n)
+			for i := range fields {
+				fields[i] = Int("field", i)
+			}
+			logger := New(zapcore.NewCore(
+				zapcore.NewJSONEncoder(NewProductionConfig().EncoderConfig),
+				&ztest.Discarder{},
+				DebugLevel,
+			)).With(fields...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					logger.With(String("request", "abc"))
+				}
+			})
+		})
+	}
+}
+
 func Benchmark10Fields(b *testing.B) {
 	withBenchedLogger(b, func(log *Logger) {
 		log.Info("Ten fields, passed at the log site.",
@@ -220,3 +244,10 @@ func Benchmark100Fields(b *testing.B) {
 		logger.With(first...).Info("Child loggers with lots of context.", second...)
 	}
 }
+
+func BenchmarkAnyNamedIntField(b *testing.B) {
+	type userID int64
+	withBenchedLogger(b, func(log *Logger) {
+		log.Info("Named int.", Any("foo", userID(42)))
+	})
+}
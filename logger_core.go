@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "github.com/blastbao/zap/zapcore"
+
+// NewLoggerCore returns a zapcore.Core that writes to an existing Logger. It
+// lets one Logger feed another: for example, a library can be handed a
+// Logger with its own wrapping (sampling, field filtering, and so on) whose
+// output still flows through the application's main pipeline.
+//
+// The entries it receives keep their level, time, caller, and stacktrace,
+// and the fields added with With or at the log site are passed through
+// unchanged. The Logger's name is prepended to the entry's logger name, and
+// its fields and core wrappers apply as usual. The Logger's caller,
+// stacktrace, and panic settings don't apply, since the entry has already
+// been checked by the Logger that created it.
+//
+// This function lives in package zap rather than zapcore because zapcore
+// can't depend on Logger.
+func NewLoggerCore(l *Logger) zapcore.Core {
+	return &loggerCore{log: l}
+}
+
+type loggerCore struct {
+	log *Logger
+}
+
+func (c *loggerCore) Enabled(lvl zapcore.Level) bool {
+	return c.log.core.Enabled(lvl)
+}
+
+func (c *loggerCore) With(fields []Field) zapcore.Core {
+	return &loggerCore{log: c.log.With(fields...)}
+}
+
+func (c *loggerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write hands the entry to the wrapped Logger's core. Errors from its cores
+// are reported to the Logger's ErrorOutput, as they would be for entries
+// logged directly.
+func (c *loggerCore) Write(ent zapcore.Entry, fields []Field) error {
+	if c.log.isShutdown() {
+		return nil
+	}
+	switch {
+	case c.log.name == "":
+	case ent.LoggerName == "":
+		ent.LoggerName = c.log.name
+	default:
+		ent.LoggerName = c.log.name + "." + ent.LoggerName
+	}
+
+	ce := c.log.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	ce.ErrorOutput = c.log.errorOutput
+	ce.Write(fields...)
+	return nil
+}
+
+func (c *loggerCore) Sync() error {
+	return c.log.Sync()
+}
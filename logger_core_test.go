@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerCore(t *testing.T) {
+	withLogger(t, InfoLevel, []Option{Fields(String("app", "main"))}, func(main *Logger, logs *observer.ObservedLogs) {
+		lib := New(NewLoggerCore(main.Named("app")), AddCaller()).Named("lib").With(Int("lib", 1))
+
+		lib.Debug("dropped")
+		assert.Equal(t, 0, logs.Len(), "Expected the main logger's level to apply.")
+
+		lib.Info("hello", String("k", "v"))
+		require.Equal(t, 1, logs.Len(), "Expected an entry in the main pipeline.")
+		entry := logs.AllUntimed()[0]
+		assert.Equal(t, "app.lib", entry.LoggerName, "Expected names to be joined.")
+		assert.Equal(t, InfoLevel, entry.Level, "Unexpected level.")
+		assert.True(t, entry.Caller.Defined, "Expected the library logger's caller to be kept.")
+		assert.Contains(t, entry.Caller.File, "logger_core_test.go", "Unexpected caller.")
+		assert.Equal(t, []Field{String("app", "main"), Int("lib", 1), String("k", "v")}, entry.Context, "Unexpected fields.")
+	})
+}
+
+func TestLoggerCoreUnnamed(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(main *Logger, logs *observer.ObservedLogs) {
+		New(NewLoggerCore(main)).Info("anonymous")
+		New(NewLoggerCore(main)).Named("lib").Info("named")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, "", entries[0].LoggerName, "Expected no name.")
+		assert.Equal(t, "lib", entries[1].LoggerName, "Expected the inner name only.")
+	})
+}
+
+func TestLoggerCoreWriteErrors(t *testing.T) {
+	errSink := &ztest.Buffer{}
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionEncoderConfig()),
+		zapcore.AddSync(ztest.FailWriter{}),
+		DebugLevel,
+	)
+	main := New(core, ErrorOutput(errSink))
+	assert.NoError(t, NewLoggerCore(main).Write(zapcore.Entry{Message: "fail"}, nil), "Expected errors to be reported to the error output.")
+	assert.Contains(t, errSink.String(), "write error", "Expected the main logger's error output to be used.")
+
+	sync := &ztest.Buffer{}
+	sync.SetError(errors.New("sync failed"))
+	main = New(zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), sync, DebugLevel))
+	assert.Error(t, NewLoggerCore(main).Sync(), "Expected Sync to sync the main logger.")
+}
@@ -21,9 +21,15 @@
 package zap
 
 import (
+	"context"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/blastbao/zap/internal/exit"
 	"github.com/blastbao/zap/internal/ztest"
@@ -113,6 +119,31 @@ func TestLoggerWith(t *testing.T) {
 	})
 }
 
+func TestLoggerFields(t *testing.T) {
+	withLogger(t, DebugLevel, opts(Fields(Int("foo", 42))), func(logger *Logger, _ *observer.ObservedLogs) {
+		assert.Equal(t, []Field{Int("foo", 42)}, logger.Fields(), "Expected fields from the Fields option.")
+
+		one := logger.With(String("one", "two"))
+		three := logger.With(String("three", "four"))
+		assert.Equal(t, []Field{Int("foo", 42), String("one", "two")}, one.Fields(), "Unexpected child fields.")
+		assert.Equal(t, []Field{Int("foo", 42), String("three", "four")}, three.Fields(), "Unexpected cross-talk between children.")
+		assert.Equal(t, []Field{Int("foo", 42)}, logger.Fields(), "Expected the parent's fields to be unchanged.")
+
+		fields := one.Fields()
+		fields[0] = String("mutated", "")
+		assert.Equal(t, Int("foo", 42), one.Fields()[0], "Expected Fields to return a copy.")
+
+		added := []Field{String("five", "six")}
+		five := one.With(added...)
+		added[0] = String("mutated", "")
+		assert.Equal(t, []Field{Int("foo", 42), String("one", "two"), String("five", "six")}, five.Fields(), "Expected With to copy its fields.")
+
+		other := logger.WithOptions(WrapCore(func(c zapcore.Core) zapcore.Core { return c })).Named("x")
+		assert.Equal(t, []Field{Int("foo", 42)}, other.Fields(), "Expected other options not to add fields.")
+	})
+	assert.Empty(t, NewNop().Fields(), "Expected no fields on a no-op logger.")
+}
+
 func TestLoggerLogPanic(t *testing.T) {
 	for _, tt := range []struct {
 		do       func(*Logger)
@@ -142,6 +173,27 @@ func TestLoggerLogPanic(t *testing.T) {
 	}
 }
 
+func TestLoggerPanicError(t *testing.T) {
+	withLogger(t, DebugLevel, opts(Development()), func(logger *Logger, _ *observer.ObservedLogs) {
+		for _, do := range []func(...Field){
+			func(fs ...Field) { logger.With(String("ctx", "v")).Panic("boom", fs...) },
+			func(fs ...Field) { logger.DPanic("boom", fs...) },
+			func(fs ...Field) { logger.Sugar().Panicw("boom", "user", "alice") },
+		} {
+			func() {
+				defer func() {
+					pe, ok := recover().(PanicError)
+					require.True(t, ok, "Expected to panic with a PanicError.")
+					assert.Equal(t, "boom", pe.Entry.Message, "Unexpected message.")
+					assert.Equal(t, []Field{String("user", "alice")}, pe.Fields, "Expected the log-site fields.")
+					assert.EqualError(t, pe, "boom", "Unexpected error string.")
+				}()
+				do(String("user", "alice"))
+			}()
+		}
+	})
+}
+
 func TestLoggerLogFatal(t *testing.T) {
 	for _, tt := range []struct {
 		do       func(*Logger)
@@ -169,11 +221,12 @@ func TestLoggerLogFatal(t *testing.T) {
 }
 
 func TestLoggerLeveledMethods(t *testing.T) {
-	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+	withLogger(t, TraceLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
 		tests := []struct {
 			method        func(string, ...Field)
 			expectedLevel zapcore.Level
 		}{
+			{logger.Trace, TraceLevel},
 			{logger.Debug, DebugLevel},
 			{logger.Info, InfoLevel},
 			{logger.Warn, WarnLevel},
@@ -227,6 +280,27 @@ func TestLoggerAlwaysFatals(t *testing.T) {
 	})
 }
 
+func TestLoggerTerminalHooks(t *testing.T) {
+	var reported []string
+	report := zapcore.CheckWriteHookFunc(func(ce *zapcore.CheckedEntry, fields []Field) {
+		reported = append(reported, ce.Level.String()+":"+ce.Message)
+	})
+
+	withLogger(t, DebugLevel, opts(WithTerminalHooks(report)), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Error("not terminal")
+		logger.DPanic("not terminal in production")
+		assert.Panics(t, func() { logger.Panic("panic") }, "Expected the logger to panic after hooks.")
+		stub := exit.WithStub(func() { logger.Fatal("fatal") })
+		assert.True(t, stub.Exited, "Expected the logger to exit after hooks.")
+		assert.Equal(t, 4, logs.Len(), "Expected every entry to be written.")
+	})
+	withLogger(t, FatalLevel+1, opts(Development(), WithTerminalHooks(report)), func(logger *Logger, logs *observer.ObservedLogs) {
+		assert.Panics(t, func() { logger.DPanic("dpanic") }, "Expected DPanic to panic in development.")
+		assert.Equal(t, 0, logs.Len(), "Expected disabled entries not to be written.")
+	})
+	assert.Equal(t, []string{"panic:panic", "fatal:fatal", "dpanic:dpanic"}, reported, "Expected hooks to run for terminal entries only.")
+}
+
 func TestLoggerDPanic(t *testing.T) {
 	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
 		assert.NotPanics(t, func() { logger.DPanic("") })
@@ -314,6 +388,113 @@ func TestLoggerWriteFailure(t *testing.T) {
 	assert.True(t, errSink.Called(), "Expected logging an internal error to call Sync the error sink.")
 }
 
+func TestLoggerThrottleErrorOutput(t *testing.T) {
+	errSink := &ztest.Buffer{}
+	logger := New(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(NewProductionConfig().EncoderConfig),
+			zapcore.Lock(zapcore.AddSync(ztest.FailWriter{})),
+			DebugLevel,
+		),
+		ErrorOutput(errSink),
+		ThrottleErrorOutput(2, time.Hour),
+	)
+
+	for i := 0; i < 10; i++ {
+		logger.Info("foo")
+	}
+	assert.Equal(t, 2, len(errSink.Lines()), "Expected only the first errors to be written.")
+	assert.Equal(t, zapcore.ThrottleStats{Written: 2, Suppressed: 8}, logger.ErrorOutputStats(), "Unexpected error output stats.")
+	assert.Equal(t, zapcore.ThrottleStats{}, NewNop().ErrorOutputStats(), "Expected no stats without throttling.")
+}
+
+func TestLoggerThrottleErrorOutputOrder(t *testing.T) {
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionConfig().EncoderConfig),
+		zapcore.Lock(zapcore.AddSync(ztest.FailWriter{})),
+		DebugLevel,
+	)
+	tests := []struct {
+		desc string
+		opts func(zapcore.WriteSyncer) []Option
+	}{
+		{"throttle first", func(ws zapcore.WriteSyncer) []Option {
+			return []Option{ThrottleErrorOutput(2, time.Hour), ErrorOutput(ws)}
+		}},
+		{"repeated throttle", func(ws zapcore.WriteSyncer) []Option {
+			return []Option{ErrorOutput(ws), ThrottleErrorOutput(1, time.Hour), ThrottleErrorOutput(2, time.Hour)}
+		}},
+	}
+	for _, tt := range tests {
+		errSink := &ztest.Buffer{}
+		logger := New(core, tt.opts(errSink)...)
+		for i := 0; i < 10; i++ {
+			logger.Info("foo")
+		}
+		assert.Equal(t, 2, len(errSink.Lines()), "%s: expected only the first errors to be written.", tt.desc)
+		assert.Equal(t, zapcore.ThrottleStats{Written: 2, Suppressed: 8}, logger.ErrorOutputStats(), "%s: unexpected error output stats.", tt.desc)
+	}
+}
+
+func TestLoggerInternalErrors(t *testing.T) {
+	errSink := &ztest.Buffer{}
+	logger := New(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(NewProductionConfig().EncoderConfig),
+			zapcore.Lock(zapcore.AddSync(ztest.FailWriter{})),
+			DebugLevel,
+		),
+		ErrorOutput(errSink),
+	)
+	child := logger.With(String("k", "v")).Named("child")
+
+	logger.Info("foo")
+	child.Info("bar")
+	assert.Equal(t, uint64(2), logger.InternalErrors(), "Expected errors from derived loggers to be counted.")
+	assert.Equal(t, uint64(2), child.InternalErrors(), "Expected derived loggers to share the count.")
+
+	throttled := child.WithOptions(ThrottleErrorOutput(1, time.Hour))
+	for i := 0; i < 3; i++ {
+		throttled.Info("baz")
+	}
+	assert.Equal(t, uint64(5), logger.InternalErrors(), "Expected dropped errors to be counted.")
+	assert.Equal(t, zapcore.ThrottleStats{Written: 1, Suppressed: 2}, throttled.ErrorOutputStats(), "Unexpected error output stats.")
+	assert.Equal(t, 3, len(errSink.Lines()), "Expected throttled errors to be dropped.")
+
+	assert.Equal(t, uint64(0), NewNop().InternalErrors(), "Expected no internal errors from a no-op logger.")
+}
+
+func TestLoggerErrorSink(t *testing.T) {
+	type report struct {
+		err error
+		msg string
+	}
+	var first, second []report
+	logger := New(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(NewProductionConfig().EncoderConfig),
+			zapcore.Lock(zapcore.AddSync(ztest.FailWriter{})),
+			DebugLevel,
+		),
+		ErrorOutput(zapcore.AddSync(ioutil.Discard)),
+		ErrorSink(func(err error, ent zapcore.Entry) {
+			first = append(first, report{err, ent.Message})
+		}),
+	)
+	child := logger.WithOptions(ErrorSink(func(err error, ent zapcore.Entry) {
+		second = append(second, report{err, ent.Message})
+	}))
+
+	logger.Info("foo")
+	child.Warn("bar")
+	require.Equal(t, 2, len(first), "Expected every write error to be reported.")
+	assert.EqualError(t, first[0].err, "failed", "Unexpected error.")
+	assert.Equal(t, "foo", first[0].msg, "Expected the entry that failed.")
+	assert.Equal(t, "bar", first[1].msg, "Expected derived loggers to report to the sink.")
+	assert.Equal(t, []report{{first[1].err, "bar"}}, second, "Expected repeated use to be additive.")
+	assert.Equal(t, uint64(2), logger.InternalErrors(), "Expected errors to be counted too.")
+}
+
 func TestLoggerSync(t *testing.T) {
 	withLogger(t, DebugLevel, nil, func(logger *Logger, _ *observer.ObservedLogs) {
 		assert.NoError(t, logger.Sync(), "Expected syncing a test logger to succeed.")
@@ -321,6 +502,27 @@ func TestLoggerSync(t *testing.T) {
 	})
 }
 
+func TestLoggerSyncIgnoresUnsyncableOutputs(t *testing.T) {
+	stderr := &ztest.Buffer{}
+	stderr.SetError(&os.PathError{Op: "sync", Path: "/dev/stderr", Err: syscall.EINVAL})
+	failing := &ztest.Buffer{}
+	failing.SetError(errors.New("fail"))
+
+	logger := New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zapcore.EncoderConfig{}),
+		stderr,
+		DebugLevel,
+	))
+	assert.NoError(t, logger.Sync(), "Expected EINVAL from syncing stderr to be ignored.")
+
+	logger = New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zapcore.EncoderConfig{}),
+		zapcore.NewMultiWriteSyncer(stderr, failing),
+		DebugLevel,
+	))
+	assert.Equal(t, errors.New("fail"), logger.Sync(), "Expected other errors to be returned.")
+}
+
 func TestLoggerSyncFail(t *testing.T) {
 	noSync := &ztest.Buffer{}
 	err := errors.New("fail")
@@ -334,6 +536,95 @@ func TestLoggerSyncFail(t *testing.T) {
 	assert.Equal(t, err, logger.Sugar().Sync(), "Expected SugaredLogger.Sync to propagate errors.")
 }
 
+// closingCore records whether it was closed, and can block Sync until
+// released.
+type closingCore struct {
+	zapcore.Core
+	closed  atomic.Bool
+	release chan struct{}
+}
+
+func (c *closingCore) Sync() error {
+	if c.release != nil {
+		<-c.release
+	}
+	return c.Core.Sync()
+}
+
+func (c *closingCore) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestLoggerEnabled(t *testing.T) {
+	withLogger(t, InfoLevel, nil, func(logger *Logger, _ *observer.ObservedLogs) {
+		assert.False(t, logger.Enabled(DebugLevel), "Expected DebugLevel to be disabled.")
+		assert.True(t, logger.Enabled(InfoLevel), "Expected InfoLevel to be enabled.")
+		assert.True(t, logger.Enabled(ErrorLevel), "Expected ErrorLevel to be enabled.")
+
+		require.NoError(t, logger.Shutdown(context.Background()), "Unexpected error shutting down.")
+		assert.False(t, logger.Enabled(ErrorLevel), "Expected every level to be disabled after Shutdown.")
+	})
+}
+
+func TestLoggerShutdown(t *testing.T) {
+	obs, logs := observer.New(DebugLevel)
+	core := &closingCore{Core: obs}
+	logger := New(core)
+	derived := logger.With(String("k", "v")).Named("derived")
+
+	logger.Info("before")
+	require.NoError(t, logger.Shutdown(context.Background()), "Unexpected error shutting down.")
+	assert.True(t, core.closed.Load(), "Expected Shutdown to close the core.")
+
+	logger.Info("after")
+	derived.Info("after")
+	derived.Sugar().Infow("after")
+	assert.Nil(t, logger.Check(InfoLevel, "after"), "Expected no checked entries after Shutdown.")
+	assert.Equal(t, []observer.LoggedEntry{{
+		Entry:   zapcore.Entry{Level: InfoLevel, Message: "before"},
+		Context: []Field{},
+	}}, logs.AllUntimed(), "Expected entries after Shutdown to be dropped.")
+
+	assert.Panics(t, func() { logger.Panic("still panics") }, "Expected Panic to panic after Shutdown.")
+	assert.Equal(t, 1, logs.Len(), "Expected the panic message not to be written.")
+
+	assert.NoError(t, derived.Shutdown(context.Background()), "Expected a second Shutdown to be a no-op.")
+}
+
+func TestLoggerShutdownDeadline(t *testing.T) {
+	core := &closingCore{Core: zapcore.NewNopCore(), release: make(chan struct{})}
+	defer close(core.release)
+	logger := New(core)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, logger.Shutdown(ctx), "Expected Shutdown to respect the context deadline.")
+}
+
+func TestLoggerShutdownClosesSinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-shutdown-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{path + "?bufferSize=1KiB&flushInterval=1h"}
+	cfg.ErrorOutputPaths = []string{"stderr"}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+
+	logger.Info("buffered")
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Empty(t, contents, "Expected the entry to be buffered.")
+
+	require.NoError(t, logger.Shutdown(context.Background()), "Unexpected error shutting down.")
+	contents, err = ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Contains(t, string(contents), `"msg":"buffered"`, "Expected Shutdown to flush buffered output.")
+}
+
 func TestLoggerAddCaller(t *testing.T) {
 	tests := []struct {
 		options []Option
@@ -361,6 +652,30 @@ func TestLoggerAddCaller(t *testing.T) {
 	}
 }
 
+func TestLoggerCheckCallerSkip(t *testing.T) {
+	tests := []struct {
+		options   []Option
+		checkOpts []CheckOption
+		pat       string
+		laterPat  string
+	}{
+		{opts(AddCaller()), nil, `.+/zap/logger_test.go:[\d]+$`, `.+/zap/logger_test.go:[\d]+$`},
+		{opts(AddCaller()), []CheckOption{WithCallerSkip(1)}, `.+/zap/common_test.go:[\d]+$`, `.+/zap/logger_test.go:[\d]+$`},
+		{opts(AddCaller(), AddCallerSkip(1)), []CheckOption{WithCallerSkip(-1)}, `.+/zap/logger_test.go:[\d]+$`, `.+/zap/common_test.go:[\d]+$`},
+		{opts(AddCaller()), []CheckOption{WithCallerSkip(1), WithCallerSkip(3)}, `.+/src/runtime/.*:[\d]+$`, `.+/zap/logger_test.go:[\d]+$`},
+	}
+	for _, tt := range tests {
+		withLogger(t, DebugLevel, tt.options, func(logger *Logger, logs *observer.ObservedLogs) {
+			logger.Check(InfoLevel, "", tt.checkOpts...).Write()
+			logger.Check(InfoLevel, "").Write()
+			output := logs.AllUntimed()
+			require.Equal(t, 2, len(output), "Unexpected number of logs written out.")
+			assert.Regexp(t, tt.pat, output[0].Entry.Caller, "Unexpected caller with per-call options.")
+			assert.Regexp(t, tt.laterPat, output[1].Entry.Caller, "Expected per-call options not to affect later entries.")
+		})
+	}
+}
+
 func TestLoggerAddCallerFail(t *testing.T) {
 	errBuf := &ztest.Buffer{}
 	withLogger(t, DebugLevel, opts(AddCaller(), ErrorOutput(errBuf)), func(log *Logger, logs *observer.ObservedLogs) {
@@ -392,6 +707,49 @@ func TestLoggerReplaceCore(t *testing.T) {
 	})
 }
 
+func TestLoggerPartitionKey(t *testing.T) {
+	clock := ztest.NewMockClock(time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC))
+	withLogger(t, DebugLevel, opts(WithClock(clock), PartitionKey("week", zapcore.ISOWeekLayout)), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("hello")
+		entries := logs.All()
+		require.Equal(t, 1, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, map[string]interface{}{"week": "2024-W20"}, entries[0].ContextMap(), "Expected a partition key from the entry's time.")
+	})
+}
+
+func TestLoggerMaxFields(t *testing.T) {
+	withLogger(t, DebugLevel, opts(MaxFields(2)), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.With(String("a", "1")).Info("capped", String("b", "2"), String("c", "3"), String("d", "4"))
+
+		entries := logs.FilterMessage("capped").AllUntimed()
+		require.Equal(t, 1, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, map[string]interface{}{
+			"a":                      "1",
+			"b":                      "2",
+			zapcore.DroppedFieldsKey: int64(2),
+		}, entries[0].ContextMap(), "Expected fields beyond the cap to be dropped and counted.")
+	})
+}
+
+func TestLoggerWithClock(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := ztest.NewMockClock(start)
+	sampled := WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSampler(core, time.Second, 1, 100)
+	})
+	withLogger(t, DebugLevel, opts(WithClock(clock), sampled), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("hello")
+		logger.Info("hello") // sampled out
+		clock.Add(time.Second)
+		logger.Info("hello")
+
+		entries := logs.All()
+		require.Equal(t, 2, len(entries), "Expected the sampler to tick with the clock.")
+		assert.Equal(t, start, entries[0].Time, "Expected entries to be timestamped by the clock.")
+		assert.Equal(t, start.Add(time.Second), entries[1].Time, "Expected entries to be timestamped by the clock.")
+	})
+}
+
 func TestLoggerHooks(t *testing.T) {
 	hook, seen := makeCountingHook()
 	withLogger(t, DebugLevel, opts(Hooks(hook)), func(logger *Logger, logs *observer.ObservedLogs) {
@@ -401,6 +759,60 @@ func TestLoggerHooks(t *testing.T) {
 	assert.Equal(t, int64(2), seen.Load(), "Hook saw an unexpected number of logs.")
 }
 
+func TestLoggerIncreaseLevel(t *testing.T) {
+	errBuf := &ztest.Buffer{}
+	withLogger(t, InfoLevel, opts(ErrorOutput(errBuf)), func(logger *Logger, logs *observer.ObservedLogs) {
+		quiet := logger.WithOptions(IncreaseLevel(WarnLevel))
+		assert.False(t, quiet.Core().Enabled(InfoLevel), "Expected the child's level to be increased.")
+		quiet.Info("dropped")
+		quiet.Warn("kept")
+		logger.Info("parent")
+
+		louder := logger.WithOptions(IncreaseLevel(DebugLevel))
+		louder.Debug("dropped")
+		assert.Contains(t, errBuf.String(), "failed to IncreaseLevel", "Expected an error decreasing the level.")
+
+		var msgs []string
+		for _, ent := range logs.AllUntimed() {
+			msgs = append(msgs, ent.Message)
+		}
+		assert.Equal(t, []string{"kept", "parent"}, msgs, "Unexpected entries logged.")
+	})
+}
+
+func TestLoggerSyncOn(t *testing.T) {
+	out := &ztest.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), out, DebugLevel)
+	logger := New(core, SyncOn(ErrorLevel)).With(String("k", "v"))
+
+	logger.Info("not synced")
+	logger.Warn("not synced")
+	assert.False(t, out.Called(), "Expected entries below ErrorLevel not to be synced.")
+	logger.Error("synced")
+	assert.True(t, out.Called(), "Expected entries at ErrorLevel to be synced.")
+	assert.Equal(t, 3, len(out.Lines()), "Expected every entry to be written.")
+}
+
+func TestLoggerWithDynamicFields(t *testing.T) {
+	var calls int
+	counter := WithDynamicFields(func() []Field {
+		calls++
+		return []Field{Int("call", calls)}
+	})
+	withLogger(t, InfoLevel, opts(counter), func(logger *Logger, logs *observer.ObservedLogs) {
+		child := logger.With(String("k", "v"))
+		child.Info("first", Bool("site", true))
+		logger.Debug("disabled")
+		logger.Info("second")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, []Field{String("k", "v"), Bool("site", true), Int("call", 1)}, entries[0].Context, "Unexpected fields on first entry.")
+		assert.Equal(t, []Field{Int("call", 2)}, entries[1].Context, "Expected fields to be re-evaluated for each entry.")
+	})
+	assert.Equal(t, 2, calls, "Expected the provider not to run for disabled entries.")
+}
+
 func TestLoggerConcurrent(t *testing.T) {
 	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
 		child := logger.With(String("foo", "bar"))
@@ -430,3 +842,83 @@ func TestLoggerConcurrent(t *testing.T) {
 		}
 	})
 }
+
+// flushingCore counts calls to Flush.
+type flushingCore struct {
+	zapcore.Core
+	flushes int
+}
+
+func (c *flushingCore) Flush(context.Context) error {
+	c.flushes++
+	return nil
+}
+
+func TestLoggerSyncFlushes(t *testing.T) {
+	core := &flushingCore{Core: zapcore.NewNopCore()}
+	require.NoError(t, New(core).Sync(), "Unexpected error syncing.")
+	assert.Equal(t, 1, core.flushes, "Expected Sync to flush the core.")
+}
+
+func TestLoggerShutdownStopsAsyncCore(t *testing.T) {
+	obs, logs := observer.New(DebugLevel)
+	core := zapcore.NewAsyncCore(obs, zapcore.AsyncConfig{ReorderWindow: time.Hour}).(zapcore.AsyncCore)
+	logger := New(core)
+
+	logger.Info("queued")
+	require.NoError(t, logger.Shutdown(context.Background()), "Unexpected error shutting down.")
+	assert.Equal(t, 1, logs.Len(), "Expected Shutdown to write queued entries.")
+	assert.Equal(t, zapcore.AsyncStats{Written: 1}, core.Stats(), "Unexpected stats after Shutdown.")
+}
+
+func TestLoggerSyncFlushesWrappedCore(t *testing.T) {
+	obs, _ := observer.New(DebugLevel)
+	core := &flushingCore{Core: obs}
+	logger := New(core, IncreaseLevel(WarnLevel), SyncOn(ErrorLevel))
+	_, ok := logger.Core().(zapcore.Flusher)
+	require.False(t, ok, "Expected the Flusher to be wrapped.")
+	require.NoError(t, logger.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, 1, core.flushes, "Expected Sync to flush the wrapped core.")
+}
+
+func TestLoggerShutdownStopsWrappedAsyncCore(t *testing.T) {
+	obs, logs := observer.New(DebugLevel)
+	core := zapcore.NewAsyncCore(obs, zapcore.AsyncConfig{ReorderWindow: time.Hour}).(zapcore.AsyncCore)
+	logger := New(core, WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewSampler(c, time.Second, 10, 10)
+	}))
+
+	logger.Info("queued")
+	require.NoError(t, logger.Shutdown(context.Background()), "Unexpected error shutting down.")
+	assert.Equal(t, 1, logs.Len(), "Expected Shutdown to write queued entries.")
+	assert.Equal(t, zapcore.AsyncStats{Written: 1}, core.Stats(), "Expected Shutdown to stop the wrapped core.")
+}
+
+func TestLoggerSamplingOverride(t *testing.T) {
+	sample := WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSampler(core, time.Minute, 1, 1000)
+	})
+	withLogger(t, DebugLevel, opts(sample), func(logger *Logger, logs *observer.ObservedLogs) {
+		for i := 0; i < 3; i++ {
+			logger.Info("kept", NoSample(), Int("i", i))
+			logger.Sugar().Infow("kept sugared", "i", i, NoSample())
+			if ce := logger.Check(InfoLevel, "kept checked", WithSampling(zapcore.SampleKeep)); ce != nil {
+				ce.Write()
+			}
+			logger.Info("sampled", Int("i", i))
+			logger.Info("dropped", ForceSample())
+		}
+
+		counts := make(map[string]int)
+		for _, e := range logs.AllUntimed() {
+			counts[e.Message]++
+		}
+		assert.Equal(t, map[string]int{
+			"kept":         3,
+			"kept sugared": 3,
+			"kept checked": 3,
+			"sampled":      1,
+		}, counts, "Unexpected entries after sampling.")
+		assert.Equal(t, map[string]interface{}{"i": int64(0)}, logs.AllUntimed()[0].ContextMap(), "Expected the override field not to be encoded.")
+	})
+}
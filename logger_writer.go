@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// WriterAt returns an io.WriteCloser that logs each line written to it as a
+// separate entry at the given level, with the line (minus its line ending)
+// as the message. Partial lines are buffered until they're completed by a
+// later write or flushed by Close; a partial line that grows past 64KiB is
+// logged in 64KiB pieces rather than buffered without bound. It's a way to route output that's only
+// available as an io.Writer into the logger, for example:
+//
+//	srv := &http.Server{ErrorLog: log.New(logger.WriterAt(zap.ErrorLevel), "", 0)}
+//
+//	cmd := exec.Command("backup")
+//	cmd.Stdout = logger.WriterAt(zap.InfoLevel)
+//
+// With AddCaller, entries report the caller of Write (or Close). The writer
+// is safe for concurrent use. After Close, it can still be written to.
+func (log *Logger) WriterAt(level zapcore.Level) io.WriteCloser {
+	return &levelWriter{
+		// writeLine sits between Write and check, one frame deeper than
+		// Logger's own methods.
+		log:     log.WithOptions(AddCallerSkip(1)),
+		level:   level,
+		maxLine: _writerMaxPartialLine,
+	}
+}
+
+// _writerMaxPartialLine caps how much of an unterminated line levelWriter
+// buffers before logging it.
+const _writerMaxPartialLine = 64 * 1024
+
+type levelWriter struct {
+	log     *Logger
+	level   zapcore.Level
+	maxLine int
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.appendPartial(p)
+			break
+		}
+		line := p[:i]
+		if len(w.buf) > 0 {
+			w.appendPartial(line)
+			line = w.buf
+		}
+		w.writeLine(line)
+		w.buf = w.buf[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// appendPartial buffers part of a line, logging the buffer whenever it's
+// full. It leaves the buffer non-empty if p is.
+func (w *levelWriter) appendPartial(p []byte) {
+	for {
+		room := w.maxLine - len(w.buf)
+		if len(p) <= room {
+			w.buf = append(w.buf, p...)
+			return
+		}
+		w.buf = append(w.buf, p[:room]...)
+		w.writeLine(w.buf)
+		w.buf = w.buf[:0]
+		p = p[room:]
+	}
+}
+
+// Close logs any buffered partial line.
+func (w *levelWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.writeLine(w.buf)
+		w.buf = w.buf[:0]
+	}
+	return nil
+}
+
+func (w *levelWriter) writeLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if ce := w.log.check(w.level, string(line), nil); ce != nil {
+		ce.Write()
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerWriterAt(t *testing.T) {
+	tests := []struct {
+		desc   string
+		writes []string
+		want   []string
+	}{
+		{"single line", []string{"foo\n"}, []string{"foo"}},
+		{"several lines", []string{"foo\nbar\n"}, []string{"foo", "bar"}},
+		{"split across writes", []string{"fo", "o\nba", "r\n"}, []string{"foo", "bar"}},
+		{"windows line endings", []string{"foo\r\nbar\r\n"}, []string{"foo", "bar"}},
+		{"empty lines", []string{"\n\n"}, []string{"", ""}},
+		{"partial line flushed on close", []string{"foo\nbar"}, []string{"foo", "bar"}},
+	}
+
+	for _, tt := range tests {
+		withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+			w := logger.WriterAt(WarnLevel)
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				require.NoError(t, err, "%s: unexpected error writing.", tt.desc)
+				assert.Equal(t, len(s), n, "%s: unexpected number of bytes written.", tt.desc)
+			}
+			require.NoError(t, w.Close(), "%s: unexpected error closing.", tt.desc)
+
+			var msgs []string
+			for _, ent := range logs.AllUntimed() {
+				assert.Equal(t, WarnLevel, ent.Level, "%s: unexpected level.", tt.desc)
+				msgs = append(msgs, ent.Message)
+			}
+			assert.Equal(t, tt.want, msgs, "%s: unexpected messages.", tt.desc)
+		})
+	}
+}
+
+func TestLoggerWriterAtDisabledLevel(t *testing.T) {
+	withLogger(t, InfoLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		w := logger.WriterAt(DebugLevel)
+		fmt.Fprintln(w, "dropped")
+		w.Close()
+		assert.Equal(t, 0, logs.Len(), "Expected no entries at a disabled level.")
+	})
+}
+
+func TestLoggerWriterAtStdLog(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		std := log.New(logger.WriterAt(ErrorLevel), "http: ", 0)
+		std.Printf("TLS handshake error from %s", "127.0.0.1")
+		assert.Equal(t, []observer.LoggedEntry{{
+			Entry:   zapcore.Entry{Level: ErrorLevel, Message: "http: TLS handshake error from 127.0.0.1"},
+			Context: []Field{},
+		}}, logs.AllUntimed(), "Unexpected entries.")
+	})
+}
+
+func TestLoggerWriterAtCaller(t *testing.T) {
+	withLogger(t, DebugLevel, opts(AddCaller()), func(logger *Logger, logs *observer.ObservedLogs) {
+		w := logger.WriterAt(InfoLevel)
+		w.Write([]byte("line\npartial"))
+		w.Close()
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+		for _, ent := range entries {
+			assert.Regexp(t, `logger_writer_test.go:\d+$`, ent.Entry.Caller.String(), "Expected the caller of Write or Close.")
+		}
+	})
+}
+
+func TestLoggerWriterAtLongPartialLine(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		w := logger.WriterAt(InfoLevel)
+		w.(*levelWriter).maxLine = 4
+		fmt.Fprint(w, "abcdefghij")
+		assert.Equal(t, []string{"abcd", "efgh"}, messages(logs), "Expected full buffers to be logged.")
+
+		fmt.Fprint(w, "k\nl")
+		w.Close()
+		assert.Equal(t, []string{"abcd", "efgh", "ijk", "l"}, messages(logs), "Unexpected messages.")
+	})
+}
+
+func messages(logs *observer.ObservedLogs) []string {
+	var msgs []string
+	for _, ent := range logs.AllUntimed() {
+		msgs = append(msgs, ent.Message)
+	}
+	return msgs
+}
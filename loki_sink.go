@@ -0,0 +1,305 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	schemeLoki  = "loki"
+	schemeLokiS = "lokis"
+
+	_lokiDefaultPath = "/loki/api/v1/push"
+	_lokiLegacyPath  = "/api/prom/push"
+	// _lokiTimestampSize is the size of the timestamp, in Unix nanoseconds,
+	// that lokiSink stores before each buffered entry.
+	_lokiTimestampSize = 8
+)
+
+// lokiSink ships entries to Grafana Loki's push API. Selected top-level keys
+// of each JSON entry become the labels of its stream, and the remaining keys,
+// in their original order, become the log line. Entries that aren't JSON
+// objects are shipped as-is, with only the static labels.
+type lokiSink struct {
+	*httpSink
+	now func() time.Time
+}
+
+// lokiLabels configures how streams are labeled.
+type lokiLabels struct {
+	fields []string          // entry keys promoted to labels
+	static map[string]string // labels added to every stream
+}
+
+func newLokiSink(u *url.URL) (Sink, error) {
+	cfg, rest, err := parseHTTPSinkURL(u, u.Scheme == schemeLokiS)
+	if err != nil {
+		return nil, err
+	}
+	labels := lokiLabels{
+		fields: []string{"level"},
+		static: make(map[string]string),
+	}
+	for key, vals := range rest {
+		val := vals[len(vals)-1]
+		switch key {
+		case "labels":
+			labels.fields = nil
+			for _, f := range strings.Split(val, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					labels.fields = append(labels.fields, f)
+				}
+			}
+		case "staticLabels":
+			for _, kv := range strings.Split(val, ",") {
+				i := strings.IndexByte(kv, ':')
+				if i <= 0 {
+					return nil, fmt.Errorf("invalid staticLabels %q in %s URL: must be name:value pairs separated by commas", val, u.Scheme)
+				}
+				labels.static[lokiLabelName(strings.TrimSpace(kv[:i]))] = strings.TrimSpace(kv[i+1:])
+			}
+		case "tenant":
+			cfg.headers.Set("X-Scope-OrgID", val)
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in %s URL: got %v", key, u.Scheme, u)
+		}
+	}
+
+	// The endpoint has already been stripped of its query; fill in the
+	// default path and pick the payload format to match.
+	endpoint, err := url.Parse(cfg.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = _lokiDefaultPath
+	}
+	cfg.endpoint = endpoint.String()
+	legacy := strings.HasSuffix(endpoint.Path, _lokiLegacyPath)
+	cfg.encode = func(batch [][]byte) ([]byte, string, error) {
+		return encodeLokiPush(batch, labels, legacy)
+	}
+	return &lokiSink{httpSink: newHTTPSinkWithConfig(cfg), now: time.Now}, nil
+}
+
+// Write buffers a copy of p, stamped with the current time.
+func (s *lokiSink) Write(p []byte) (int, error) {
+	rec := make([]byte, _lokiTimestampSize+len(p))
+	binary.BigEndian.PutUint64(rec, uint64(s.now().UnixNano()))
+	copy(rec[_lokiTimestampSize:], p)
+	if _, err := s.httpSink.Write(rec); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type lokiEntry struct {
+	ts   time.Time
+	line string
+}
+
+type lokiStream struct {
+	labels  map[string]string
+	entries []lokiEntry
+}
+
+// encodeLokiPush groups a batch of timestamped entries into streams and
+// encodes them as a push request, in the format of the v1 API or, if legacy
+// is set, of the original /api/prom/push API.
+func encodeLokiPush(batch [][]byte, labels lokiLabels, legacy bool) ([]byte, string, error) {
+	var (
+		streams []*lokiStream
+		byKey   = make(map[string]*lokiStream)
+	)
+	for _, rec := range batch {
+		if len(rec) < _lokiTimestampSize {
+			continue
+		}
+		ts := time.Unix(0, int64(binary.BigEndian.Uint64(rec)))
+		line, set := splitLokiLabels(rec[_lokiTimestampSize:], labels)
+		key := formatLokiLabels(set)
+		stream, ok := byKey[key]
+		if !ok {
+			stream = &lokiStream{labels: set}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.entries = append(stream.entries, lokiEntry{ts, line})
+	}
+
+	var body interface{}
+	if legacy {
+		type entry struct {
+			TS   string `json:"ts"`
+			Line string `json:"line"`
+		}
+		type stream struct {
+			Labels  string  `json:"labels"`
+			Entries []entry `json:"entries"`
+		}
+		out := make([]stream, len(streams))
+		for i, s := range streams {
+			out[i].Labels = formatLokiLabels(s.labels)
+			for _, e := range s.entries {
+				out[i].Entries = append(out[i].Entries, entry{e.ts.UTC().Format(time.RFC3339Nano), e.line})
+			}
+		}
+		body = map[string]interface{}{"streams": out}
+	} else {
+		type stream struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		}
+		out := make([]stream, len(streams))
+		for i, s := range streams {
+			out[i].Stream = s.labels
+			for _, e := range s.entries {
+				out[i].Values = append(out[i].Values, [2]string{strconv.FormatInt(e.ts.UnixNano(), 10), e.line})
+			}
+		}
+		body = map[string]interface{}{"streams": out}
+	}
+	data, err := json.Marshal(body)
+	return data, "application/json", err
+}
+
+// splitLokiLabels removes the keys promoted to labels from a JSON entry,
+// returning the rest of the entry as the log line along with the stream's
+// labels. Only string, number, and boolean values become labels.
+func splitLokiLabels(entry []byte, labels lokiLabels) (string, map[string]string) {
+	set := make(map[string]string, len(labels.static)+len(labels.fields))
+	for k, v := range labels.static {
+		set[k] = v
+	}
+	entry = bytes.TrimRight(entry, "\r\n")
+
+	dec := json.NewDecoder(bytes.NewReader(entry))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return string(entry), set
+	}
+	var (
+		line    = bytes.NewBufferString("{")
+		matched bool
+	)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return string(entry), set
+		}
+		key, _ := tok.(string)
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return string(entry), set
+		}
+		if label, ok := lokiLabelValue(key, val, labels.fields); ok {
+			set[lokiLabelName(key)] = label
+			matched = true
+			continue
+		}
+		if line.Len() > 1 {
+			line.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		line.Write(k)
+		line.WriteByte(':')
+		line.Write(val)
+	}
+	if !matched {
+		return string(entry), set
+	}
+	line.WriteByte('}')
+	return line.String(), set
+}
+
+// lokiLabelValue reports whether key is promoted to a label, returning its
+// value as a string.
+func lokiLabelValue(key string, val json.RawMessage, fields []string) (string, bool) {
+	promoted := false
+	for _, f := range fields {
+		if f == key {
+			promoted = true
+			break
+		}
+	}
+	if !promoted || len(val) == 0 {
+		return "", false
+	}
+	switch val[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(val, &s); err != nil {
+			return "", false
+		}
+		return s, true
+	case '{', '[', 'n':
+		return "", false
+	default:
+		return string(val), true
+	}
+}
+
+// lokiLabelName makes name a valid Prometheus label name by replacing
+// disallowed characters with underscores.
+func lokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9'
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// formatLokiLabels formats a label set in Prometheus syntax, with the names
+// sorted, as in {job="api",level="info"}.
+func formatLokiLabels(set map[string]string) string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(set[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lokiURL(c *httpCollector, path string, query url.Values) string {
+	return strings.Replace(c.URL, "http://", "loki://", 1) + path + "?" + query.Encode()
+}
+
+func TestLokiSinkPush(t *testing.T) {
+	c := newHTTPCollector()
+	defer c.Close()
+
+	query := url.Values{
+		"labels":       {"level,logger,attempt,nested"},
+		"staticLabels": {"job:api, env:prod"},
+		"tenant":       {"team-a"},
+	}
+	sink, err := newSink(lokiURL(c, "", query))
+	require.NoError(t, err, "Failed to open loki sink.")
+	ls := sink.(*lokiSink)
+	ls.now = func() time.Time { return time.Unix(1, 500) }
+
+	sink.Write([]byte(`{"level":"info","logger":"db","msg":"one","attempt":2,"nested":{"a":1}}` + "\n"))
+	sink.Write([]byte(`{"level":"error","msg":"two"}` + "\n"))
+	sink.Write([]byte(`{"level":"info","logger":"db","msg":"three","attempt":2}` + "\n"))
+	sink.Write([]byte("not JSON\n"))
+	require.NoError(t, sink.Close(), "Unexpected error closing.")
+
+	reqs := c.Requests()
+	require.Len(t, reqs, 1, "Expected one push request.")
+	assert.Equal(t, "team-a", reqs[0].header.Get("X-Scope-OrgID"), "Unexpected tenant header.")
+	assert.Equal(t, "application/json", reqs[0].header.Get("Content-Type"), "Unexpected content type.")
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(reqs[0].body), &push), "Failed to decode push request.")
+	require.Len(t, push.Streams, 3, "Expected entries to be grouped by label set.")
+
+	ts := "1000000500"
+	assert.Equal(t, map[string]string{"job": "api", "env": "prod", "level": "info", "logger": "db", "attempt": "2"}, push.Streams[0].Stream, "Unexpected labels.")
+	assert.Equal(t, [][2]string{
+		{ts, `{"msg":"one","nested":{"a":1}}`},
+		{ts, `{"msg":"three"}`},
+	}, push.Streams[0].Values, "Unexpected lines.")
+	assert.Equal(t, map[string]string{"job": "api", "env": "prod", "level": "error"}, push.Streams[1].Stream, "Unexpected labels.")
+	assert.Equal(t, [][2]string{{ts, `{"msg":"two"}`}}, push.Streams[1].Values, "Unexpected lines.")
+	assert.Equal(t, map[string]string{"job": "api", "env": "prod"}, push.Streams[2].Stream, "Expected non-JSON entries to get only static labels.")
+	assert.Equal(t, [][2]string{{ts, "not JSON"}}, push.Streams[2].Values, "Expected non-JSON entries to be shipped as-is.")
+}
+
+func TestLokiSinkLegacyPush(t *testing.T) {
+	c := newHTTPCollector()
+	defer c.Close()
+
+	sink, err := newSink(lokiURL(c, "/api/prom/push", url.Values{"staticLabels": {"app-name:api"}}))
+	require.NoError(t, err, "Failed to open loki sink.")
+	sink.(*lokiSink).now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC) }
+	sink.Write([]byte(`{"level":"warn","msg":"legacy"}` + "\n"))
+	require.NoError(t, sink.Close(), "Unexpected error closing.")
+
+	reqs := c.Requests()
+	require.Len(t, reqs, 1, "Expected one push request.")
+	assert.JSONEq(t, `{"streams":[{
+		"labels":"{app_name=\"api\",level=\"warn\"}",
+		"entries":[{"ts":"2020-01-02T03:04:05.000000006Z","line":"{\"msg\":\"legacy\"}"}]
+	}]}`, reqs[0].body, "Unexpected legacy push request.")
+}
+
+func TestLokiSinkDefaultPath(t *testing.T) {
+	sink, err := newSink("lokis://logs.example.com?flushInterval=0s")
+	require.NoError(t, err, "Failed to open loki sink.")
+	defer sink.Close()
+	assert.Equal(t, "https://logs.example.com/loki/api/v1/push", sink.(*lokiSink).cfg.endpoint, "Unexpected endpoint.")
+}
+
+func TestLokiSinkSyncAfterClose(t *testing.T) {
+	statuses := make([]int, 10)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	c := newHTTPCollector(statuses...)
+	defer c.Close()
+
+	query := url.Values{"maxRetries": {"0"}, "backoff": {"1ms"}, "flushInterval": {"0s"}}
+	sink, err := newSink(lokiURL(c, "", query))
+	require.NoError(t, err, "Failed to open loki sink.")
+
+	sink.Write([]byte(`{"level":"info","msg":"undelivered"}` + "\n"))
+	err = sink.Close()
+	if assert.Error(t, err, "Expected Close to report the undelivered entry.") {
+		assert.Contains(t, err.Error(), "1 entries undelivered", "Unexpected error message.")
+	}
+
+	synced := make(chan error, 1)
+	go func() { synced <- sink.Sync() }()
+	select {
+	case err := <-synced:
+		assert.NoError(t, err, "Expected errors to have been reported by Close.")
+	case <-time.After(time.Second):
+		t.Fatal("Sync after Close didn't return.")
+	}
+}
+
+func TestLokiSinkErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"loki:///push", "must include a host"},
+		{"loki://logs?color=blue", "unknown query parameter"},
+		{"loki://logs?staticLabels=job", "invalid staticLabels"},
+		{"loki://logs?caFile=ca.pem", "TLS parameters require a secure URL"},
+		{"loki://logs?batchSize=none", "invalid batchSize"},
+	}
+	for _, tt := range tests {
+		_, err := newSink(tt.url)
+		if assert.Error(t, err, "Expected an error for URL %q.", tt.url) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error for URL %q.", tt.url)
+		}
+	}
+}
+
+func TestLokiLabelName(t *testing.T) {
+	tests := map[string]string{
+		"level":      "level",
+		"app-name":   "app_name",
+		"2fa":        "_fa",
+		"request.id": "request_id",
+		"":           "_",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, lokiLabelName(in), "Unexpected label name for %q.", in)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"sort"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// Map constructs a field that carries a map with string keys as a nested
+// object. Each value is encoded as if it were passed to Any, so maps of
+// primitives, times, durations, errors, and marshalers are encoded without
+// reflection. Keys are encoded in sorted order, so output is deterministic.
+func Map[T any](key string, m map[string]T) Field {
+	return Object(key, mapObject[T](m))
+}
+
+type mapObject[T any] map[string]T
+
+func (m mapObject[T]) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range sortedKeys(m) {
+		Any(k, m[k]).AddTo(enc)
+	}
+	return nil
+}
+
+// ErrorMap constructs a field that carries a set of errors as a nested
+// object, each error under its own key. This suits operations that fail
+// independently, such as a fan-out to several backends:
+//
+//	logger.Warn("partial failure", zap.ErrorMap("errors", map[string]error{
+//		"primary": errPrimary,
+//		"replica": errReplica,
+//	}))
+//
+// Like NamedError, each error's verbose representation, if any, is stored
+// under its key plus "Verbose", and nil errors are skipped. Keys are encoded
+// in sorted order.
+func ErrorMap[E error](key string, errs map[string]E) Field {
+	return Object(key, errorMap[E](errs))
+}
+
+type errorMap[E error] map[string]E
+
+func (m errorMap[E]) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range sortedKeys(m) {
+		NamedError(k, m[k]).AddTo(enc)
+	}
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		desc string
+		give Field
+		want interface{}
+	}{
+		{"nil map", Map[int]("k", nil), map[string]interface{}{}},
+		{"ints", Map("k", map[string]int{"b": 2, "a": 1}), map[string]interface{}{"a": int64(1), "b": int64(2)}},
+		{"durations", Map("k", map[string]time.Duration{"d": time.Second}), map[string]interface{}{"d": time.Second}},
+		{"any", Map("k", map[string]interface{}{"s": "str", "f": 1.5}), map[string]interface{}{"s": "str", "f": 1.5}},
+		{
+			"other values fall back to reflection",
+			Map("k", map[string]map[string]bool{"outer": {"inner": true}}),
+			map[string]interface{}{"outer": map[string]bool{"inner": true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := zapcore.NewMapObjectEncoder()
+			tt.give.AddTo(enc)
+			assert.Equal(t, tt.want, enc.Fields["k"], "Unexpected output.")
+		})
+	}
+}
+
+func TestMapKeyOrder(t *testing.T) {
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "M"})
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "m"}, []Field{
+		Map("k", map[string]int{"c": 3, "a": 1, "b": 2}),
+	})
+	if assert.NoError(t, err, "Unexpected encoding error.") {
+		assert.Equal(t, `{"M":"m","k":{"a":1,"b":2,"c":3}}`+"\n", buf.String(), "Expected keys in sorted order.")
+	}
+	buf.Free()
+}
+
+type codeError struct{ code int }
+
+func (e *codeError) Error() string { return "code " + string(rune('0'+e.code)) }
+
+func TestErrorMap(t *testing.T) {
+	enc := zapcore.NewMapObjectEncoder()
+	ErrorMap("errs", map[string]error{
+		"primary": errors.New("timeout"),
+		"replica": nil,
+	}).AddTo(enc)
+	ErrorMap("codes", map[string]*codeError{"a": {code: 1}}).AddTo(enc)
+	assert.Equal(t, map[string]interface{}{
+		"errs":  map[string]interface{}{"primary": "timeout"},
+		"codes": map[string]interface{}{"a": "code 1"},
+	}, enc.Fields, "Unexpected encoded errors.")
+}
@@ -20,7 +20,13 @@
 
 package zap
 
-import "github.com/blastbao/zap/zapcore"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+)
 
 // An Option configures a Logger.
 type Option interface {
@@ -57,11 +63,79 @@ func Hooks(hooks ...func(zapcore.Entry) error) Option {
 	})
 }
 
+// IncreaseLevel increases the level of the Logger, so that it drops entries
+// that the level doesn't enable. It's meant for quieting noisy dependencies
+// that are handed a shared Logger:
+//
+//	client := thirdparty.New(logger.WithOptions(zap.IncreaseLevel(zap.WarnLevel)))
+//
+// It can't decrease the level: if lvl enables a level that the Logger
+// doesn't, the option reports an error to the Logger's error output and has
+// no effect.
+func IncreaseLevel(lvl zapcore.LevelEnabler) Option {
+	return optionFunc(func(log *Logger) {
+		core, err := zapcore.NewIncreaseLevelCore(log.core, lvl)
+		if err != nil {
+			fmt.Fprintf(log.errorOutput, "failed to IncreaseLevel: %v\n", err)
+			return
+		}
+		log.core = core
+	})
+}
+
+// SyncOn syncs the Logger's output after writing each entry at a level that
+// lvl enables, so that, for example, SyncOn(ErrorLevel) makes errors durable
+// as soon as they're logged without paying for a sync on every Info entry.
+// Without it, output is only synced after entries above ErrorLevel.
+func SyncOn(lvl zapcore.LevelEnabler) Option {
+	return optionFunc(func(log *Logger) {
+		log.core = syncingCore{Core: log.core, on: lvl}
+	})
+}
+
+// CorrelateProfiles annotates entries at levels lvl enables with the pprof
+// labels of the context they're logged with (see WithContext), and records
+// them as runtime/trace user log events while an execution trace is running.
+// See zapcore.CorrelateProfiles for details.
+func CorrelateProfiles(lvl zapcore.LevelEnabler) Option {
+	return WrapCore(zapcore.CorrelateProfiles(lvl))
+}
+
+// WithDynamicFields adds the fields returned by f to every entry, after
+// the fields supplied at the log site. Unlike Fields and With, which freeze
+// their values when they're called, f runs each time an entry is written
+// (only once the entry has passed the level check), so it suits values such
+// as the goroutine count or a rotating correlation ID. f must be safe for
+// concurrent use, and should be cheap. Repeated use is additive.
+func WithDynamicFields(f func() []Field) Option {
+	return WrapCore(zapcore.Enrich(func(zapcore.Entry) []Field {
+		return f()
+	}))
+}
+
+// ContextExtractor registers a function that returns fields for a context,
+// such as a request ID or trace ID stored in it by middleware. The fields are
+// added whenever the Logger, or a Logger derived from it, is bound to a
+// context with Logger.WithContext or SugaredLogger.Ctx. Extractors run in
+// the order they're registered, and should return nil for contexts that
+// carry nothing of interest. Repeated use is additive.
+func ContextExtractor(f func(context.Context) []Field) Option {
+	return optionFunc(func(log *Logger) {
+		log.contextExtractors = append(log.contextExtractors[:len(log.contextExtractors):len(log.contextExtractors)], f)
+	})
+}
+
 // Fields adds fields to the Logger.
 // Fields 为日志打印增加待打印的字段。
 func Fields(fs ...Field) Option {
 	return optionFunc(func(log *Logger) {
 		log.core = log.core.With(fs)
+		log.fields = log.fields.append(fs)
+		if log.provenance != nil {
+			start := len(log.fieldOrigins)
+			log.fieldOrigins, log.fieldsNested = appendOrigins(log.fieldOrigins, log.fieldsNested, fs, FieldSourceFields, zapcore.EntryCaller{})
+			log.provenance.record(log.fieldOrigins[start:])
+		}
 	})
 }
 
@@ -77,7 +151,43 @@ func Fields(fs ...Field) Option {
 // ErrorOutput 用来指定日志组件中出现异常时的输出目的地。
 func ErrorOutput(w zapcore.WriteSyncer) Option {
 	return optionFunc(func(log *Logger) {
-		log.errorOutput = w
+		log.setErrorOutput(w)
+	})
+}
+
+// ThrottleErrorOutput limits how many internal errors, such as failures to
+// write to a sink, are reported to the Logger's error output: the first
+// errors in each interval are written, the rest are dropped, and a summary
+// of how many were dropped follows at the end of the interval. Use
+// Logger.ErrorOutputStats or Logger.InternalErrors to monitor the counts.
+//
+// The throttle applies to the Logger's error output wherever it's set, so
+// the option can come before or after ErrorOutput. Repeated use replaces the
+// earlier settings rather than throttling twice.
+func ThrottleErrorOutput(first int, interval time.Duration) Option {
+	return optionFunc(func(log *Logger) {
+		log.errorThrottle = &errorThrottle{first: first, interval: interval}
+		log.setErrorOutput(log.errorDest)
+	})
+}
+
+// ErrorSink registers a function that's called with the internal errors the
+// Logger reports, such as failures to encode entries or write to sinks, and
+// the entry that caused each one, so applications can count them, alert on
+// them, or crash. Errors are still written to the Logger's error output.
+// The function runs synchronously on the logging goroutine, so it should be
+// quick, and mustn't log through the same Logger. Repeated use is additive.
+func ErrorSink(f func(err error, ent zapcore.Entry)) Option {
+	return optionFunc(func(log *Logger) {
+		prev := log.errorSink
+		if prev == nil {
+			log.errorSink = f
+			return
+		}
+		log.errorSink = func(err error, ent zapcore.Entry) {
+			prev(err, ent)
+			f(err, ent)
+		}
 	})
 }
 
@@ -113,6 +223,79 @@ func AddCallerSkip(skip int) Option {
 	})
 }
 
+// A CheckOption configures a single call to Logger.Check.
+type CheckOption interface {
+	apply(*checkConfig)
+}
+
+type checkOptionFunc func(*checkConfig)
+
+func (f checkOptionFunc) apply(cfg *checkConfig) {
+	f(cfg)
+}
+
+type checkConfig struct {
+	callerSkip int
+	template   string
+	sampling   zapcore.SamplingOverride
+}
+
+// newCheckConfig applies opts to a new checkConfig. It's kept out of
+// Logger.check so that the config only escapes to the heap when Check is
+// given options.
+func newCheckConfig(opts []CheckOption) checkConfig {
+	var cfg checkConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg
+}
+
+// WithCallerSkip increases the number of callers skipped by caller
+// annotation for a single entry, on top of the Logger's AddCallerSkip. It
+// lets wrapper libraries whose log sites sit at different depths report the
+// real call site without keeping a Logger per depth:
+//
+//	if ce := logger.Check(zap.InfoLevel, msg, zap.WithCallerSkip(depth)); ce != nil {
+//		ce.Write(fields...)
+//	}
+func WithCallerSkip(skip int) CheckOption {
+	return checkOptionFunc(func(cfg *checkConfig) {
+		cfg.callerSkip += skip
+	})
+}
+
+// WithSampling overrides the decisions of samplers for a single entry, like
+// the NoSample and ForceSample fields do for the Logger's logging methods:
+//
+//	if ce := logger.Check(zap.InfoLevel, "audit", zap.WithSampling(zapcore.SampleKeep)); ce != nil {
+//		ce.Write(fields...)
+//	}
+func WithSampling(o zapcore.SamplingOverride) CheckOption {
+	return checkOptionFunc(func(cfg *checkConfig) {
+		cfg.sampling = o
+	})
+}
+
+// RewriteCallerPaths rewrites the file paths of callers (as added by the
+// AddCaller option) before they're encoded, replacing each prefix in the map
+// with its value. This is useful when builds record paths that mean nothing
+// at runtime, such as Bazel's sandbox paths:
+//
+//	zap.RewriteCallerPaths(map[string]string{
+//		"/home/*/.cache/bazel/_bazel_*/*/sandbox/*/*/execroot/__main__/": "",
+//		"/src/github.com/acme/": "acme/",
+//	})
+//
+// Wildcards (see path.Match) match whole path segments. Longer prefixes are
+// tried first, and prefixes with invalid wildcards never match. Calling the
+// option again replaces the previous map.
+func RewriteCallerPaths(prefixes map[string]string) Option {
+	return optionFunc(func(log *Logger) {
+		log.callerPaths, _ = newCallerPathRewriter(prefixes)
+	})
+}
+
 // AddStacktrace configures the Logger to record a stack trace for all messages at
 // or above a given level.
 //
@@ -122,3 +305,78 @@ func AddStacktrace(lvl zapcore.LevelEnabler) Option {
 		log.addStack = lvl
 	})
 }
+
+// DeferStacktrace configures the Logger to capture only the raw program
+// counters of stack traces at the log site, leaving the expensive work of
+// symbolizing and formatting them to the encoder. Stack traces of entries that
+// are never encoded (for example, because a core drops them after Check) then
+// cost little more than a call to runtime.Callers.
+//
+// With this option, stack traces are carried in zapcore.Entry.LazyStack
+// rather than Entry.Stack; custom encoders and hooks should read them with
+// Entry.Stacktrace.
+func DeferStacktrace() Option {
+	return optionFunc(func(log *Logger) {
+		log.deferStack = true
+	})
+}
+
+// WithClock configures the Logger to timestamp entries with the given clock
+// rather than the system clock. Since samplers measure their ticks by entry
+// timestamps, they follow the clock too. It's mostly useful in tests and
+// simulations that need to control time.
+func WithClock(clock zapcore.Clock) Option {
+	return optionFunc(func(log *Logger) {
+		log.clock = clock
+	})
+}
+
+// WithTerminalHooks registers hooks that run after an entry that will panic
+// or exit the process is written, and before the Logger panics or exits. This
+// covers Panic and Fatal entries, and DPanic entries in development mode. It's
+// the place to flush buffers or report the entry to a crash-reporting service
+// without losing the Logger's terminal behavior. Hooks run even if no core
+// writes the entry, and run in the order they're registered.
+func WithTerminalHooks(hooks ...zapcore.CheckWriteHook) Option {
+	return optionFunc(func(log *Logger) {
+		log.terminalHooks = append(log.terminalHooks[:len(log.terminalHooks):len(log.terminalHooks)], hooks...)
+	})
+}
+
+// PartitionKey stamps each entry with a string field, under key, holding the
+// entry's time in UTC formatted with layout: for example, "2006-01-02" for
+// daily partitions, or zapcore.ISOWeekLayout for weekly ones like
+// "2024-W20". Sinks that write to object stores or partitioned tables can
+// route entries by it without re-parsing timestamps. It's a shortcut for
+// wrapping the Logger's core with zapcore.NewPartitionKeyCore.
+func PartitionKey(key, layout string) Option {
+	return WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewPartitionKeyCore(core, key, layout)
+	})
+}
+
+// MaxFields caps the number of fields in each entry at max, counting both
+// the fields added with With and those passed at the log site. Fields
+// beyond the cap are dropped and counted under zapcore.DroppedFieldsKey, and
+// the first drop writes a warning. It guards against code that keeps calling
+// With in a loop. It's a shortcut for wrapping the Logger's core with
+// zapcore.NewFieldLimitCore; a non-positive max does nothing.
+func MaxFields(max int) Option {
+	return WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewFieldLimitCore(core, max)
+	})
+}
+
+// closeOnShutdown registers a function that Logger.Shutdown calls to release
+// resources, such as the outputs opened by Config.Build. The registration is
+// shared by the Logger and every Logger derived from it.
+func closeOnShutdown(f func() error) Option {
+	return optionFunc(func(log *Logger) {
+		if log.shutdown == nil {
+			return
+		}
+		log.shutdown.mu.Lock()
+		log.shutdown.closers = append(log.shutdown.closers, f)
+		log.shutdown.mu.Unlock()
+	})
+}
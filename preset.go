@@ -0,0 +1,185 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"go.uber.org/multierr"
+)
+
+// A Preset builds a Logger for a named logging profile, applying the
+// caller's Options on top of the profile's own. NewProduction and
+// NewDevelopment are Presets.
+type Preset func(overrides ...Option) (*Logger, error)
+
+var (
+	errNoPresetNameSpecified = errors.New("no preset name specified")
+
+	_presets = map[string]Preset{
+		"production":  NewProduction,
+		"development": NewDevelopment,
+		"cli":         NewCLI,
+		"serverless":  NewServerless,
+		"audit":       NewAudit,
+	}
+	_presetMutex sync.RWMutex
+)
+
+// RegisterPreset registers a named logging profile, which NewPreset can
+// then build. This lets an organization ship its own blessed profiles in a
+// shared package and have services pick one with a single configuration
+// string. By default, the "production", "development", "cli",
+// "serverless", and "audit" presets are registered; see NewProduction,
+// NewDevelopment, NewCLI, NewServerless, and NewAudit.
+//
+// Attempting to register a preset whose name is already taken returns an
+// error.
+func RegisterPreset(name string, preset Preset) error {
+	_presetMutex.Lock()
+	defer _presetMutex.Unlock()
+	if name == "" {
+		return errNoPresetNameSpecified
+	}
+	if _, ok := _presets[name]; ok {
+		return fmt.Errorf("preset already registered for name %q", name)
+	}
+	_presets[name] = preset
+	return nil
+}
+
+// RegisteredPresets returns the names of all registered presets, sorted.
+func RegisteredPresets() []string {
+	_presetMutex.RLock()
+	defer _presetMutex.RUnlock()
+	names := make([]string, 0, len(_presets))
+	for name := range _presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPreset builds a Logger from the preset registered under name, applying
+// overrides on top of the preset's own Options. It returns an error if no
+// such preset is registered.
+func NewPreset(name string, overrides ...Option) (*Logger, error) {
+	_presetMutex.RLock()
+	preset, ok := _presets[name]
+	_presetMutex.RUnlock()
+	if name == "" {
+		return nil, errNoPresetNameSpecified
+	}
+	if !ok {
+		return nil, fmt.Errorf("no preset registered for name %q", name)
+	}
+	return preset(overrides...)
+}
+
+// NewCLIConfig is a logging configuration for command-line tools, whose
+// output is read by people at a terminal. Logging is enabled at InfoLevel
+// and above.
+//
+// It uses a console encoder that writes only each entry's level, message,
+// and fields to standard error, without timestamps, callers, or
+// stacktraces, and disables sampling.
+func NewCLIConfig() Config {
+	return Config{
+		Level:    NewAtomicLevelAt(InfoLevel),
+		Encoding: "console",
+		EncoderConfig: zapcore.EncoderConfig{
+			LevelKey:       "L",
+			NameKey:        "N",
+			MessageKey:     "M",
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeLevel:    zapcore.CapitalLevelEncoder,
+			EncodeDuration: zapcore.StringDurationEncoder,
+		},
+		DisableCaller:     true,
+		DisableStacktrace: true,
+		OutputPaths:       []string{"stderr"},
+		ErrorOutputPaths:  []string{"stderr"},
+	}
+}
+
+// NewCLI builds a Logger for command-line tools.
+//
+// It's a shortcut for NewCLIConfig().Build(...Option).
+func NewCLI(options ...Option) (*Logger, error) {
+	return NewCLIConfig().Build(options...)
+}
+
+// NewAuditConfig is a logging configuration for audit trails, which
+// mustn't lose records. Logging is enabled at InfoLevel and above.
+//
+// It's NewProductionConfig without sampling.
+// Loggers built with NewAudit also sync their output after every entry.
+// For sequence numbers and tamper detection, see the zapaudit package.
+func NewAuditConfig() Config {
+	cfg := NewProductionConfig()
+	cfg.Sampling = nil
+	return cfg
+}
+
+// NewAudit builds a Logger from NewAuditConfig that syncs its output after
+// every entry.
+func NewAudit(options ...Option) (*Logger, error) {
+	syncEach := WrapCore(func(core zapcore.Core) zapcore.Core {
+		return syncingCore{Core: core}
+	})
+	return NewAuditConfig().Build(append([]Option{syncEach}, options...)...)
+}
+
+// syncingCore syncs the Core it wraps after writing entries at the levels
+// that on enables, or after every entry if on is nil.
+type syncingCore struct {
+	zapcore.Core
+	on zapcore.LevelEnabler
+}
+
+// Unwrap returns the wrapped Core.
+func (c syncingCore) Unwrap() zapcore.Core {
+	return c.Core
+}
+
+func (c syncingCore) With(fields []Field) zapcore.Core {
+	return syncingCore{c.Core.With(fields), c.on}
+}
+
+func (c syncingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c syncingCore) Write(ent zapcore.Entry, fields []Field) error {
+	err := c.Core.Write(ent, fields)
+	if c.on == nil || c.on.Enabled(ent.Level) {
+		err = multierr.Append(err, c.Core.Sync())
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresetRegistry(t *testing.T) {
+	for _, name := range []string{"production", "development", "cli", "serverless", "audit"} {
+		assert.Contains(t, RegisteredPresets(), name, "Expected a default preset.")
+	}
+	assert.Error(t, RegisterPreset("production", NewProduction), "Expected an error re-registering a preset.")
+	assert.Equal(t, errNoPresetNameSpecified, RegisterPreset("", NewProduction), "Expected an error registering an unnamed preset.")
+
+	_, err := NewPreset("no-such-preset")
+	assert.Error(t, err, "Expected an error building an unregistered preset.")
+	_, err = NewPreset("")
+	assert.Equal(t, errNoPresetNameSpecified, err, "Expected an error building an unnamed preset.")
+}
+
+func TestNewPresetOverrides(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	require.NoError(t, RegisterPreset("test-team", func(overrides ...Option) (*Logger, error) {
+		return New(core, append([]Option{Fields(String("team", "payments"))}, overrides...)...), nil
+	}), "Unexpected error registering preset.")
+	defer func() {
+		_presetMutex.Lock()
+		delete(_presets, "test-team")
+		_presetMutex.Unlock()
+	}()
+
+	logger, err := NewPreset("test-team", Fields(String("service", "ledger")))
+	require.NoError(t, err, "Unexpected error building preset.")
+	logger.Info("hello")
+	require.Equal(t, 1, logs.Len(), "Expected an entry from the preset's logger.")
+	assert.Equal(t, map[string]interface{}{
+		"team":    "payments",
+		"service": "ledger",
+	}, logs.All()[0].ContextMap(), "Expected the preset's options and the overrides.")
+}
+
+func TestCLIConfig(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-cli-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	cfg := NewCLIConfig()
+	cfg.OutputPaths = []string{temp.Name()}
+	logger, err := cfg.Build()
+	require.NoError(t, err, "Unexpected error building logger.")
+	logger.Debug("hidden")
+	logger.Error("can't open file", String("path", "a.txt"))
+	require.NoError(t, logger.Sync(), "Unexpected error syncing logger.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Equal(t, "ERROR\tcan't open file\t{\"path\": \"a.txt\"}\n", string(contents), "Unexpected CLI output.")
+}
+
+func TestAuditPreset(t *testing.T) {
+	cfg := NewAuditConfig()
+	assert.Nil(t, cfg.Sampling, "Expected sampling to be disabled.")
+
+	out := &ztest.Buffer{}
+	core := syncingCore{Core: zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), out, InfoLevel)}
+	logger := New(core).With(String("actor", "alice"))
+	logger.Debug("hidden")
+	logger.Info("login")
+	assert.True(t, out.Called(), "Expected output to be synced after the entry.")
+	assert.Contains(t, out.String(), `"msg":"login","actor":"alice"`, "Unexpected audit record.")
+	assert.NotContains(t, out.String(), "hidden", "Expected disabled levels to be skipped.")
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// FieldConflictsKey is the key under which a FieldProvenance that annotates
+// entries lists the fields added more than once.
+const FieldConflictsKey = "fieldConflicts"
+
+// Sources of fields, as reported in FieldOrigin.Source.
+const (
+	// FieldSourceFields marks fields added with the Fields option, which
+	// includes Config.InitialFields.
+	FieldSourceFields = "Fields"
+	// FieldSourceWith marks fields added with Logger.With or
+	// SugaredLogger.With.
+	FieldSourceWith = "With"
+	// FieldSourceCallSite marks fields passed to a logging method.
+	FieldSourceCallSite = "call site"
+)
+
+// A FieldOrigin records where a field was added to a Logger.
+type FieldOrigin struct {
+	Key    string
+	Source string
+	// Caller is the code that called With or the logging method. It's
+	// undefined for fields added with the Fields option, and for fields
+	// passed to logging methods unless the Logger annotates entries with
+	// callers (see AddCaller).
+	Caller zapcore.EntryCaller
+}
+
+func (o FieldOrigin) String() string {
+	if !o.Caller.Defined {
+		return o.Source
+	}
+	return o.Source + " at " + o.Caller.TrimmedPath()
+}
+
+// originKey identifies a FieldOrigin regardless of the caller's PC.
+type originKey struct {
+	key, source, file string
+	line              int
+}
+
+func (o FieldOrigin) id() originKey {
+	return originKey{o.Key, o.Source, o.Caller.File, o.Caller.Line}
+}
+
+// A FieldProvenance is a debugging aid that records where each field key is
+// added to Loggers built with the TrackFieldOrigins option, so that you can
+// find out which code injects conflicting values for keys like "user_id" or
+// "env". Tracking makes With and logging noticeably slower, so it's meant
+// for debugging rather than production.
+//
+// A FieldProvenance is safe for concurrent use.
+type FieldProvenance struct {
+	// Annotate adds a FieldConflictsKey field to each entry that has a key
+	// more than once, listing where each copy came from.
+	Annotate bool
+
+	mu      sync.Mutex
+	origins []FieldOrigin
+	counts  map[originKey]int
+}
+
+// NewFieldProvenance creates an empty FieldProvenance.
+func NewFieldProvenance() *FieldProvenance {
+	return &FieldProvenance{counts: make(map[originKey]int)}
+}
+
+func (p *FieldProvenance) record(origins []FieldOrigin) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.counts == nil {
+		p.counts = make(map[originKey]int)
+	}
+	for _, o := range origins {
+		id := o.id()
+		if p.counts[id] == 0 {
+			p.origins = append(p.origins, o)
+		}
+		p.counts[id]++
+	}
+}
+
+// Origins returns the distinct places that the given key has been added,
+// in the order they were first seen.
+func (p *FieldProvenance) Origins(key string) []FieldOrigin {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var origins []FieldOrigin
+	for _, o := range p.origins {
+		if o.Key == key {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// Report writes every key recorded so far, sorted, with the places it was
+// added and how many times. Keys added in more than one place are marked as
+// conflicting.
+func (p *FieldProvenance) Report(w io.Writer) error {
+	p.mu.Lock()
+	byKey := make(map[string][]FieldOrigin)
+	for _, o := range p.origins {
+		byKey[o.Key] = append(byKey[o.Key], o)
+	}
+	counts := make(map[originKey]int, len(p.counts))
+	for id, n := range p.counts {
+		counts[id] = n
+	}
+	p.mu.Unlock()
+
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := bufio.NewWriter(w)
+	for _, k := range keys {
+		origins := byKey[k]
+		if len(origins) > 1 {
+			fmt.Fprintf(buf, "%s (conflicting):\n", k)
+		} else {
+			fmt.Fprintf(buf, "%s:\n", k)
+		}
+		for _, o := range origins {
+			fmt.Fprintf(buf, "  %v (%d times)\n", o, counts[o.id()])
+		}
+	}
+	return buf.Flush()
+}
+
+// TrackFieldOrigins records where fields are added to the Logger, and the
+// Loggers derived from it, in p. Fields already added with the Fields
+// option are recorded too.
+func TrackFieldOrigins(p *FieldProvenance) Option {
+	return optionFunc(func(log *Logger) {
+		log.provenance = p
+		log.fieldOrigins, log.fieldsNested = appendOrigins(nil, false, log.fields.all(), FieldSourceFields, zapcore.EntryCaller{})
+		p.record(log.fieldOrigins)
+	})
+}
+
+// appendOrigins appends the origins of fields to dst, skipping fields inside
+// namespaces. nested reports whether a namespace has been opened.
+func appendOrigins(dst []FieldOrigin, nested bool, fields []Field, source string, caller zapcore.EntryCaller) ([]FieldOrigin, bool) {
+	dst = dst[:len(dst):len(dst)]
+	for _, f := range fields {
+		if nested {
+			break
+		}
+		switch {
+		case f.Type == zapcore.NamespaceType:
+			nested = true
+		case f.Key != "" && f.Type != zapcore.SkipType:
+			dst = append(dst, FieldOrigin{Key: f.Key, Source: source, Caller: caller})
+		}
+	}
+	return dst, nested
+}
+
+// trackWith records the origins of fields added with With. skip counts the
+// frames from trackWith's caller up to the code that called With.
+func (log *Logger) trackWith(fields []Field, skip int) {
+	var caller zapcore.EntryCaller
+	if log.addCaller {
+		caller = zapcore.NewEntryCaller(runtime.Caller(skip + 1))
+		if caller.Defined && log.callerPaths != nil {
+			caller.File = log.callerPaths.rewrite(caller.File)
+		}
+	}
+	start := len(log.fieldOrigins)
+	log.fieldOrigins, log.fieldsNested = appendOrigins(log.fieldOrigins, log.fieldsNested, fields, FieldSourceWith, caller)
+	log.provenance.record(log.fieldOrigins[start:])
+}
+
+// trackCallSite wraps a CheckedEntry so that the fields it's written with
+// are recorded, and annotated if the FieldProvenance asks for it.
+func (log *Logger) trackCallSite(ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	w := &provenanceWriter{
+		LevelEnabler: zapcore.DebugLevel,
+		ce:           ce,
+		p:            log.provenance,
+		origins:      log.fieldOrigins,
+		nested:       log.fieldsNested,
+	}
+	outer := (*zapcore.CheckedEntry)(nil).AddCore(ce.Entry, w)
+	outer.ErrorOutput = ce.ErrorOutput
+	return outer
+}
+
+// provenanceWriter is a Core that records the fields an entry is written
+// with, then writes the entry to the CheckedEntry it wraps.
+type provenanceWriter struct {
+	zapcore.LevelEnabler
+	ce      *zapcore.CheckedEntry
+	p       *FieldProvenance
+	origins []FieldOrigin
+	nested  bool
+}
+
+func (w *provenanceWriter) With([]Field) zapcore.Core { return w }
+
+func (w *provenanceWriter) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, w)
+}
+
+func (w *provenanceWriter) Write(ent zapcore.Entry, fields []Field) error {
+	origins, _ := appendOrigins(w.origins, w.nested, fields, FieldSourceCallSite, ent.Caller)
+	w.p.record(origins[len(w.origins):])
+	if w.p.Annotate {
+		if conflicts := fieldConflicts(origins); len(conflicts) > 0 {
+			fields = append(fields[:len(fields):len(fields)], Strings(FieldConflictsKey, conflicts))
+		}
+	}
+	w.ce.Write(fields...)
+	return nil
+}
+
+func (w *provenanceWriter) Sync() error { return nil }
+
+// fieldConflicts describes each key that appears more than once in origins.
+func fieldConflicts(origins []FieldOrigin) []string {
+	var conflicts []string
+	for i, o := range origins {
+		seen := false
+		for _, prev := range origins[:i] {
+			if prev.Key == o.Key {
+				seen = true
+				break
+			}
+		}
+		if seen {
+			continue
+		}
+		var where []string
+		for _, other := range origins[i:] {
+			if other.Key == o.Key {
+				where = append(where, other.String())
+			}
+		}
+		if len(where) > 1 {
+			conflicts = append(conflicts, o.Key+": "+strings.Join(where, ", "))
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackFieldOrigins(t *testing.T) {
+	p := NewFieldProvenance()
+	p.Annotate = true
+	withLogger(t, DebugLevel, opts(AddCaller(), Fields(String("env", "prod")), TrackFieldOrigins(p)), func(logger *Logger, logs *observer.ObservedLogs) {
+		child := logger.With(String("user_id", "a"))
+		child.Info("conflict", String("user_id", "b"), String("env", "dev"))
+		child.Info("no conflict", String("request", "r1"))
+		logger.Sugar().With("user_id", "c").Infow("sugared", "user_id", "d")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 3, len(entries), "Unexpected number of entries.")
+		conflicts := entries[0].ContextMap()[FieldConflictsKey]
+		require.Len(t, conflicts, 2, "Expected both duplicated keys to be annotated.")
+		assert.Equal(t, "env: Fields, call site at zap/provenance_test.go:38", conflicts.([]interface{})[0], "Unexpected env conflict.")
+		assert.Equal(t, "user_id: With at zap/provenance_test.go:37, call site at zap/provenance_test.go:38", conflicts.([]interface{})[1], "Unexpected user_id conflict.")
+		assert.NotContains(t, entries[1].ContextMap(), FieldConflictsKey, "Expected entries without duplicates to be left alone.")
+		assert.Equal(t,
+			[]interface{}{"user_id: With at zap/provenance_test.go:40, call site at zap/provenance_test.go:40"},
+			entries[2].ContextMap()[FieldConflictsKey],
+			"Expected the sugared logger's callers to be found.",
+		)
+	})
+
+	assert.Equal(t, 4, len(p.Origins("user_id")), "Unexpected number of user_id origins.")
+	var report bytes.Buffer
+	require.NoError(t, p.Report(&report), "Unexpected error writing report.")
+	assert.Equal(t, `env (conflicting):
+  Fields (1 times)
+  call site at zap/provenance_test.go:38 (1 times)
+request:
+  call site at zap/provenance_test.go:39 (1 times)
+user_id (conflicting):
+  With at zap/provenance_test.go:37 (1 times)
+  call site at zap/provenance_test.go:38 (1 times)
+  With at zap/provenance_test.go:40 (1 times)
+  call site at zap/provenance_test.go:40 (1 times)
+`, report.String(), "Unexpected report.")
+}
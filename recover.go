@@ -0,0 +1,149 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// A RecoverOption configures RecoverAndLog and Logger.WithRecover.
+type RecoverOption interface {
+	apply(*recoverConfig)
+}
+
+type recoverOptionFunc func(*recoverConfig)
+
+func (f recoverOptionFunc) apply(cfg *recoverConfig) {
+	f(cfg)
+}
+
+type recoverConfig struct {
+	level   zapcore.Level
+	msg     string
+	repanic bool
+}
+
+// RecoverLevel sets the level recovered panics are logged at. It defaults to
+// ErrorLevel; DPanicLevel makes development Loggers panic again after
+// logging.
+func RecoverLevel(lvl zapcore.Level) RecoverOption {
+	return recoverOptionFunc(func(cfg *recoverConfig) {
+		cfg.level = lvl
+	})
+}
+
+// RecoverMessage sets the message recovered panics are logged with. It
+// defaults to "recovered from panic".
+func RecoverMessage(msg string) RecoverOption {
+	return recoverOptionFunc(func(cfg *recoverConfig) {
+		cfg.msg = msg
+	})
+}
+
+// Repanic makes RecoverAndLog sync the Logger and panic again with the
+// original value after logging it, so that the panic still reaches outer
+// handlers or crashes the program, but with its context on record.
+func Repanic() RecoverOption {
+	return recoverOptionFunc(func(cfg *recoverConfig) {
+		cfg.repanic = true
+	})
+}
+
+// RecoverAndLog recovers a panic and logs it, with the panic value under the
+// "panic" key and the stack of the panicking goroutine under "stacktrace".
+// It must be deferred directly, since recover only works in deferred
+// functions:
+//
+//	func (s *server) handle(w http.ResponseWriter, r *http.Request) {
+//		defer zap.RecoverAndLog(s.logger, zap.RecoverMessage("handler panicked"))
+//		...
+//	}
+//
+// Errors, including the PanicError from Logger.Panic, are logged with Error;
+// other values with Any. Without Repanic, the panic stops here and the
+// deferring function returns normally. With AddCaller, the entry's caller is
+// the function that panicked, which is usually the one that deferred
+// RecoverAndLog, rather than RecoverAndLog or the runtime.
+func RecoverAndLog(logger *Logger, opts ...RecoverOption) {
+	if r := recover(); r != nil {
+		logRecovered(logger, r, opts)
+	}
+}
+
+// WithRecover runs fn, recovering and logging any panic as RecoverAndLog
+// does. It reports whether fn panicked. With AddCaller, the entry's caller is
+// the function that panicked.
+func (log *Logger) WithRecover(fn func(), opts ...RecoverOption) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			logRecovered(log, r, opts)
+		}
+	}()
+	fn()
+	return false
+}
+
+func logRecovered(logger *Logger, r interface{}, opts []RecoverOption) {
+	cfg := recoverConfig{level: ErrorLevel, msg: "recovered from panic"}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+
+	value := Any("panic", r)
+	if err, ok := r.(error); ok {
+		value = NamedError("panic", err)
+	}
+	if ce := logger.Check(cfg.level, cfg.msg, panicCallerSkip()); ce != nil {
+		ce.Write(value, Stack("stacktrace"))
+	}
+	if cfg.repanic {
+		logger.Sync()
+		panic(r)
+	}
+}
+
+// panicCallerSkip returns a CheckOption that makes caller annotation skip
+// logRecovered, the deferred function that recovered, and the runtime's
+// panic frames (gopanic, and sigpanic and friends for runtime errors), so
+// that it reports the function that panicked. It must be called directly by
+// logRecovered.
+func panicCallerSkip() CheckOption {
+	// Skip runtime.Callers, panicCallerSkip, logRecovered, and the deferred
+	// function.
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(4, pcs)])
+	skip := 2
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			break
+		}
+		skip++
+		if !more {
+			break
+		}
+	}
+	return WithCallerSkip(skip)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverAndLog(t *testing.T) {
+	tests := []struct {
+		desc     string
+		value    interface{}
+		opts     []RecoverOption
+		level    zapcore.Level
+		msg      string
+		expected Field
+	}{
+		{
+			desc:     "string",
+			value:    "boom",
+			level:    ErrorLevel,
+			msg:      "recovered from panic",
+			expected: Any("panic", "boom"),
+		},
+		{
+			desc:     "error",
+			value:    errors.New("boom"),
+			opts:     []RecoverOption{RecoverLevel(WarnLevel), RecoverMessage("handler panicked")},
+			level:    WarnLevel,
+			msg:      "handler panicked",
+			expected: NamedError("panic", errors.New("boom")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+				assert.NotPanics(t, func() {
+					defer RecoverAndLog(logger, tt.opts...)
+					panic(tt.value)
+				}, "Expected the panic to be recovered.")
+
+				require.Equal(t, 1, logs.Len(), "Expected the panic to be logged.")
+				entry := logs.All()[0]
+				assert.Equal(t, tt.level, entry.Level, "Unexpected level.")
+				assert.Equal(t, tt.msg, entry.Message, "Unexpected message.")
+				require.Equal(t, 2, len(entry.Context), "Expected the panic value and a stack trace.")
+				assert.Equal(t, tt.expected, entry.Context[0], "Unexpected panic field.")
+				assert.Equal(t, "stacktrace", entry.Context[1].Key, "Expected a stack trace.")
+				assert.Contains(t, entry.Context[1].String, "TestRecoverAndLog", "Expected the stack of the panicking goroutine.")
+			})
+		})
+	}
+}
+
+func TestRecoverAndLogNoPanic(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		func() {
+			defer RecoverAndLog(logger)
+		}()
+		assert.Equal(t, 0, logs.Len(), "Expected nothing to be logged without a panic.")
+	})
+}
+
+func TestRecoverAndLogRepanic(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		assert.PanicsWithValue(t, "boom", func() {
+			defer RecoverAndLog(logger, Repanic())
+			panic("boom")
+		}, "Expected the panic to continue.")
+		assert.Equal(t, 1, logs.Len(), "Expected the panic to be logged first.")
+	})
+}
+
+func TestLoggerWithRecover(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		assert.False(t, logger.WithRecover(func() {}), "Expected no panic.")
+		assert.True(t, logger.WithRecover(func() {
+			logger.Panic("boom", String("k", "v"))
+		}, RecoverMessage("task panicked")), "Expected a recovered panic.")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Expected the Panic entry and the recovered panic.")
+		assert.Equal(t, "task panicked", entries[1].Message, "Unexpected message.")
+		pe, ok := entries[1].Context[0].Interface.(PanicError)
+		require.True(t, ok, "Expected the PanicError to be logged.")
+		assert.Equal(t, zapcore.ErrorType, entries[1].Context[0].Type, "Expected PanicErrors to be logged as errors.")
+		assert.Equal(t, []Field{String("k", "v")}, pe.Fields, "Expected the panic's fields to be kept.")
+	})
+}
+
+func TestLoggerWithRecoverNilPanic(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		assert.True(t, logger.WithRecover(func() {
+			panic(nil)
+		}), "Expected a recovered panic.")
+		assert.Equal(t, 1, logs.FilterMessage("recovered from panic").Len(), "Expected the panic to be logged.")
+	})
+}
+
+func TestLoggerWithRecoverGoexit(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			logger.WithRecover(runtime.Goexit, Repanic())
+			t.Error("Expected Goexit to stop the goroutine.")
+		}()
+		<-done
+		assert.Equal(t, 0, logs.Len(), "Expected Goexit not to be logged as a panic.")
+	})
+}
+
+func TestRecoverCaller(t *testing.T) {
+	withLogger(t, DebugLevel, opts(AddCaller()), func(logger *Logger, logs *observer.ObservedLogs) {
+		var lines []int
+		func() {
+			defer RecoverAndLog(logger)
+			_, _, line, _ := runtime.Caller(0)
+			lines = append(lines, line+2)
+			panic("boom")
+		}()
+		logger.WithRecover(func() {
+			var m map[string]int
+			_, _, line, _ := runtime.Caller(0)
+			lines = append(lines, line+2)
+			m["nil map"] = 1
+		})
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Expected both panics to be logged.")
+		for i, ent := range entries {
+			assert.Regexp(
+				t,
+				fmt.Sprintf(`recover_test.go:%d$`, lines[i]),
+				ent.Caller.String(),
+				"Expected the caller to be the function that panicked.",
+			)
+		}
+	})
+}
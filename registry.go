@@ -0,0 +1,270 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"go.uber.org/atomic"
+)
+
+// _noLevelOverride marks a tenant whose level hasn't been overridden.
+const _noLevelOverride = math.MinInt32
+
+// A RegistryOption configures a Registry.
+type RegistryOption interface {
+	apply(*Registry)
+}
+
+type registryOptionFunc func(*Registry)
+
+func (f registryOptionFunc) apply(r *Registry) {
+	f(r)
+}
+
+// DefaultQuota sets the rate limit, in entries per second, given to each
+// tenant when the Registry first sees it. Zero, the default, means no limit.
+func DefaultQuota(perSecond int) RegistryOption {
+	return registryOptionFunc(func(r *Registry) {
+		r.quota = perSecond
+	})
+}
+
+// A Registry hands out named Loggers to the tenants of a multi-tenant
+// server. The Loggers share the Core of a base Logger, but each tenant has
+// its own level override and rate limit, both of which can be changed at any
+// time, and the Registry can list every tenant with its settings. This gives
+// platform teams one place to quiet a noisy tenant or turn up logging for
+// one that's misbehaving.
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	base  *Logger
+	quota int
+
+	mu      sync.RWMutex
+	tenants map[string]*tenant
+}
+
+// NewRegistry creates a Registry whose Loggers are children of base.
+func NewRegistry(base *Logger, opts ...RegistryOption) *Registry {
+	r := &Registry{
+		base:    base,
+		tenants: make(map[string]*tenant),
+	}
+	for _, opt := range opts {
+		opt.apply(r)
+	}
+	return r
+}
+
+// Logger returns the Logger for the named tenant, creating the tenant if
+// it's new. The Logger is named after the tenant (see Logger.Named), and
+// the same Logger is returned every time.
+func (r *Registry) Logger(name string) *Logger {
+	return r.tenant(name).logger
+}
+
+// SetLevel overrides the named tenant's level. Since the tenant's Logger
+// shares the base Logger's Core, levels the base Logger doesn't enable stay
+// disabled.
+func (r *Registry) SetLevel(name string, lvl zapcore.Level) {
+	r.tenant(name).level.Store(int32(lvl))
+}
+
+// ResetLevel removes the named tenant's level override, if it has one.
+func (r *Registry) ResetLevel(name string) {
+	r.tenant(name).level.Store(_noLevelOverride)
+}
+
+// SetQuota limits the named tenant to perSecond entries per second; entries
+// over the limit are dropped and counted. Zero or a negative quota removes
+// the limit.
+func (r *Registry) SetQuota(name string, perSecond int) {
+	r.tenant(name).limiter.setQuota(perSecond)
+}
+
+// Remove forgets the named tenant. Loggers already handed out keep their
+// last settings, but can no longer be changed through the Registry.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, name)
+}
+
+// A RegisteredLogger describes a tenant of a Registry.
+type RegisteredLogger struct {
+	Name string
+	// Level is the lowest level the tenant's Logger writes, taking both its
+	// override and the base Logger's level into account.
+	Level zapcore.Level
+	// Overridden reports whether the tenant's level has been overridden
+	// with SetLevel.
+	Overridden bool
+	// Quota is the tenant's rate limit, in entries per second, or zero if
+	// it has none.
+	Quota int
+	// Dropped counts the entries dropped for exceeding the quota.
+	Dropped uint64
+}
+
+// Loggers lists the Registry's tenants, sorted by name.
+func (r *Registry) Loggers() []RegisteredLogger {
+	r.mu.RLock()
+	tenants := make([]*tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	r.mu.RUnlock()
+
+	loggers := make([]RegisteredLogger, len(tenants))
+	for i, t := range tenants {
+		quota, dropped := t.limiter.stats()
+		loggers[i] = RegisteredLogger{
+			Name:       t.name,
+			Level:      lowestEnabled(t.logger.Core()),
+			Overridden: t.level.Load() != _noLevelOverride,
+			Quota:      quota,
+			Dropped:    dropped,
+		}
+	}
+	sort.Slice(loggers, func(i, j int) bool { return loggers[i].Name < loggers[j].Name })
+	return loggers
+}
+
+func (r *Registry) tenant(name string) *tenant {
+	r.mu.RLock()
+	t, ok := r.tenants[name]
+	r.mu.RUnlock()
+	if ok {
+		return t
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tenants[name]; ok {
+		return t
+	}
+	t = &tenant{
+		name:    name,
+		level:   atomic.NewInt32(_noLevelOverride),
+		limiter: &quotaLimiter{quota: r.quota},
+	}
+	t.logger = r.base.Named(name).WithOptions(WrapCore(func(core zapcore.Core) zapcore.Core {
+		return tenantCore{core, t}
+	}))
+	r.tenants[name] = t
+	return t
+}
+
+// lowestEnabled returns the lowest level that core enables, or one above
+// FatalLevel if it enables none.
+func lowestEnabled(core zapcore.Core) zapcore.Level {
+	lvl := TraceLevel
+	for lvl <= FatalLevel && !core.Enabled(lvl) {
+		lvl++
+	}
+	return lvl
+}
+
+type tenant struct {
+	name    string
+	logger  *Logger
+	level   *atomic.Int32
+	limiter *quotaLimiter
+}
+
+// tenantCore applies a tenant's level override and quota to the Core it
+// wraps.
+type tenantCore struct {
+	zapcore.Core
+	t *tenant
+}
+
+// Unwrap returns the wrapped Core.
+func (c tenantCore) Unwrap() zapcore.Core {
+	return c.Core
+}
+
+func (c tenantCore) Enabled(lvl zapcore.Level) bool {
+	if override := c.t.level.Load(); override != _noLevelOverride && lvl < zapcore.Level(override) {
+		return false
+	}
+	return c.Core.Enabled(lvl)
+}
+
+func (c tenantCore) With(fields []Field) zapcore.Core {
+	return tenantCore{c.Core.With(fields), c.t}
+}
+
+func (c tenantCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) || !c.t.limiter.allow(ent.Time) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// quotaLimiter allows a fixed number of entries in each second.
+type quotaLimiter struct {
+	mu      sync.Mutex
+	quota   int
+	start   time.Time // start of the current second
+	count   int
+	dropped uint64
+}
+
+func (l *quotaLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.quota <= 0 {
+		return true
+	}
+	if now.Sub(l.start) >= time.Second || now.Before(l.start) {
+		l.start = now
+		l.count = 0
+	}
+	if l.count >= l.quota {
+		l.dropped++
+		return false
+	}
+	l.count++
+	return true
+}
+
+func (l *quotaLimiter) setQuota(perSecond int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if perSecond < 0 {
+		perSecond = 0
+	}
+	l.quota = perSecond
+}
+
+func (l *quotaLimiter) stats() (quota int, dropped uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.quota, l.dropped
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryLevels(t *testing.T) {
+	withLogger(t, DebugLevel, nil, func(base *Logger, logs *observer.ObservedLogs) {
+		r := NewRegistry(base)
+		acme := r.Logger("acme")
+		assert.Equal(t, acme, r.Logger("acme"), "Expected the same Logger for a tenant.")
+
+		acme.Debug("before")
+		r.SetLevel("acme", WarnLevel)
+		acme.Info("quiet")
+		acme.With(String("k", "v")).Info("quiet")
+		acme.Warn("loud")
+		r.Logger("globex").Debug("other tenant")
+		r.SetLevel("acme", TraceLevel)
+		acme.Trace("below base")
+		r.ResetLevel("acme")
+		acme.Debug("after")
+
+		var msgs []string
+		for _, ent := range logs.AllUntimed() {
+			msgs = append(msgs, ent.LoggerName+": "+ent.Message)
+		}
+		assert.Equal(t, []string{
+			"acme: before",
+			"acme: loud",
+			"globex: other tenant",
+			"acme: after",
+		}, msgs, "Unexpected entries.")
+	})
+}
+
+func TestRegistryQuotas(t *testing.T) {
+	clock := ztest.NewMockClock(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+	withLogger(t, DebugLevel, opts(WithClock(clock)), func(base *Logger, logs *observer.ObservedLogs) {
+		r := NewRegistry(base, DefaultQuota(2))
+		r.SetQuota("unlimited", 0)
+		for i := 0; i < 5; i++ {
+			r.Logger("acme").Info("acme")
+			r.Logger("unlimited").Info("unlimited")
+		}
+		clock.Add(time.Second)
+		r.Logger("acme").Info("acme")
+
+		counts := make(map[string]int)
+		for _, ent := range logs.AllUntimed() {
+			counts[ent.Message]++
+		}
+		assert.Equal(t, map[string]int{"acme": 3, "unlimited": 5}, counts, "Unexpected entries after rate limiting.")
+
+		r.SetLevel("acme", ErrorLevel)
+		assert.Equal(t, []RegisteredLogger{
+			{Name: "acme", Level: ErrorLevel, Overridden: true, Quota: 2, Dropped: 3},
+			{Name: "unlimited", Level: DebugLevel},
+		}, r.Loggers(), "Unexpected registered loggers.")
+
+		r.Remove("unlimited")
+		require.Equal(t, 1, len(r.Loggers()), "Expected removed tenants not to be listed.")
+		assert.Equal(t, "acme", r.Loggers()[0].Name, "Unexpected remaining tenant.")
+	})
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	r := NewRegistry(New(core), DefaultQuota(1000))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				r.Logger("acme").Info("hello")
+				r.SetLevel("acme", InfoLevel)
+				r.Loggers()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, logs.Len(), "Expected every entry to be logged.")
+	assert.Equal(t, 1, len(r.Loggers()), "Expected a single tenant.")
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// _processStart approximates when the process started, so the cold-start
+// entry can report how long initialization took.
+var _processStart = time.Now()
+
+// _serverlessEnv lists the environment variables that name the running
+// function on common FaaS platforms: AWS Lambda, Cloud Run and Cloud
+// Functions, and Azure Functions.
+var _serverlessEnv = []string{
+	"AWS_LAMBDA_FUNCTION_NAME",
+	"K_SERVICE",
+	"FUNCTION_TARGET",
+	"WEBSITE_SITE_NAME",
+}
+
+// NewServerlessConfig is a logging configuration for functions-as-a-service
+// platforms like AWS Lambda and Cloud Run, where the platform collects
+// standard output and the process may be frozen between invocations.
+// Logging is enabled at InfoLevel and above.
+//
+// It uses a JSON encoder with ISO8601 timestamps and writes each entry to
+// standard out synchronously, without buffering. Sampling and error output
+// throttling are disabled, so the Logger starts no background goroutines or
+// timers that a frozen process would leave stranded.
+func NewServerlessConfig() Config {
+	encoderCfg := NewProductionEncoderConfig()
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	return Config{
+		Level:            NewAtomicLevelAt(InfoLevel),
+		Encoding:         "json",
+		EncoderConfig:    encoderCfg,
+		OutputPaths:      []string{"stdout"},
+		ErrorOutputPaths: []string{"stderr"},
+	}
+}
+
+// NewServerless builds a Logger from NewServerlessConfig and writes an
+// InfoLevel "cold start" entry recording the function's name (if the
+// platform provides one), the Go version, and how long the process took to
+// start. Call it once, during initialization, and wrap handlers with
+// WrapLambdaHandler so that output is flushed before the platform freezes
+// the process.
+func NewServerless(options ...Option) (*Logger, error) {
+	log, err := NewServerlessConfig().Build(options...)
+	if err != nil {
+		return nil, err
+	}
+	fields := []Field{
+		String("goVersion", runtime.Version()),
+		Duration("initDuration", time.Since(_processStart)),
+	}
+	for _, key := range _serverlessEnv {
+		if name := os.Getenv(key); name != "" {
+			fields = append(fields, String("function", name))
+			break
+		}
+	}
+	log.Info("cold start", fields...)
+	return log, nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"context"
+	"fmt"
+)
+
+// WrapLambdaHandler wraps a function handler, such as an AWS Lambda handler,
+// so that the Logger is synced when each invocation returns. Platforms may
+// freeze or stop the process as soon as the handler returns, so anything
+// still buffered would otherwise be delayed or lost. If the handler panics,
+// the panic is logged at ErrorLevel and the Logger synced before the panic
+// continues.
+//
+//	log, _ := zap.NewServerless()
+//	lambda.Start(zap.WrapLambdaHandler(log, handle))
+func WrapLambdaHandler[In, Out any](log *Logger, handler func(context.Context, In) (Out, error)) func(context.Context, In) (Out, error) {
+	return func(ctx context.Context, in In) (Out, error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("handler panicked", String("panic", fmt.Sprint(r)))
+				log.Sync()
+				panic(r)
+			}
+			log.Sync()
+		}()
+		return handler(ctx, in)
+	}
+}
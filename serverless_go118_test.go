@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapLambdaHandler(t *testing.T) {
+	buf := &ztest.Buffer{}
+	logger := New(zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), buf, DebugLevel))
+
+	handler := WrapLambdaHandler(logger, func(ctx context.Context, n int) (string, error) {
+		logger.Info("handling")
+		if n < 0 {
+			return "", errors.New("negative")
+		}
+		if n == 0 {
+			panic("zero")
+		}
+		return "ok", nil
+	})
+
+	out, err := handler(context.Background(), 1)
+	assert.NoError(t, err, "Unexpected error from handler.")
+	assert.Equal(t, "ok", out, "Unexpected handler result.")
+	assert.True(t, buf.Called(), "Expected the logger to be synced after the handler returned.")
+
+	_, err = handler(context.Background(), -1)
+	assert.EqualError(t, err, "negative", "Expected the handler's error to be returned.")
+
+	assert.PanicsWithValue(t, "zero", func() { handler(context.Background(), 0) }, "Expected the panic to continue.")
+	assert.Equal(t, []string{
+		`{"msg":"handling"}`,
+		`{"msg":"handling"}`,
+		`{"msg":"handling"}`,
+		`{"msg":"handler panicked","panic":"zero"}`,
+	}, buf.Lines(), "Unexpected output.")
+}
+
+func TestWrapLambdaHandlerNilPanic(t *testing.T) {
+	buf := &ztest.Buffer{}
+	logger := New(zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), buf, DebugLevel))
+
+	handler := WrapLambdaHandler(logger, func(ctx context.Context, _ int) (string, error) {
+		panic(nil)
+	})
+	assert.Panics(t, func() { handler(context.Background(), 0) }, "Expected the panic to continue.")
+	lines := buf.Lines()
+	if assert.Equal(t, 1, len(lines), "Expected the panic to be logged.") {
+		assert.Contains(t, lines[0], `"msg":"handler panicked"`, "Unexpected output.")
+	}
+	assert.True(t, buf.Called(), "Expected the logger to be synced.")
+}
+
+func TestWrapLambdaHandlerGoexit(t *testing.T) {
+	buf := &ztest.Buffer{}
+	logger := New(zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), buf, DebugLevel))
+
+	handler := WrapLambdaHandler(logger, func(ctx context.Context, _ int) (string, error) {
+		runtime.Goexit()
+		return "", nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(context.Background(), 0)
+		t.Error("Expected Goexit to stop the goroutine.")
+	}()
+	<-done
+	assert.Empty(t, buf.Lines(), "Expected Goexit not to be logged as a panic.")
+	assert.True(t, buf.Called(), "Expected the logger to be synced.")
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerlessConfig(t *testing.T) {
+	cfg := NewServerlessConfig()
+	assert.Nil(t, cfg.Sampling, "Expected sampling to be disabled.")
+	assert.Equal(t, []string{"stdout"}, cfg.OutputPaths, "Expected output to standard out.")
+	assert.True(t, cfg.ErrorOutputRate <= 0, "Expected error output throttling to be disabled.")
+	assert.Equal(t, InfoLevel, cfg.Level.Level(), "Unexpected level.")
+}
+
+func TestNewServerless(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zap-serverless-test")
+	require.NoError(t, err, "Failed to create temp file.")
+	defer os.Remove(temp.Name())
+
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	os.Stdout = temp
+	defer os.Setenv("AWS_LAMBDA_FUNCTION_NAME", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
+	os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "my-function")
+
+	logger, err := NewServerless()
+	require.NoError(t, err, "Unexpected error building logger.")
+	logger.Info("handled")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Regexp(t, `"msg":"cold start","goVersion":"go[^"]*","initDuration":[0-9.e-]+,"function":"my-function"}`, string(contents), "Expected a cold-start entry.")
+	assert.Contains(t, string(contents), `"msg":"handled"`, "Expected entries to be written synchronously.")
+}
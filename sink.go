@@ -24,19 +24,39 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/blastbao/zap/zapcore"
+
+	"go.uber.org/multierr"
 )
 
 const schemeFile = "file"
 
 var (
-	_sinkMutex     sync.RWMutex
-	_sinkFactories map[string] func(*url.URL) (Sink, error) // keyed by scheme
+	_sinkMutex      sync.RWMutex
+	_sinkFactories  map[string] func(*url.URL) (Sink, error) // keyed by scheme
+	_sinkTransports map[string]transportSupport               // keyed by scheme
+)
+
+// transportSupport records which of Config.Transport's parameters the sinks
+// for a scheme understand. Schemes missing from _sinkTransports get none.
+type transportSupport int
+
+const (
+	// _transportDialOnly schemes accept proxy and dialTimeout, but reject
+	// TLS settings.
+	_transportDialOnly transportSupport = iota + 1
+	// _transportWithTLS schemes accept every TransportConfig parameter.
+	_transportWithTLS
 )
 
 func init() {
@@ -48,7 +68,23 @@ func resetSinkRegistry() {
 	defer _sinkMutex.Unlock()
 
 	_sinkFactories = map[string] func(*url.URL) (Sink, error) {
-		schemeFile: newFileSink,
+		schemeFile:     newFileSink,
+		schemeJournald: newJournaldSink,
+		schemeWALQueue: newWALQueueSink,
+		schemeTCP:      newTCPSink,
+		schemeTCPS:     newTCPSink,
+		schemeHTTP:     newHTTPSink,
+		schemeHTTPS:    newHTTPSink,
+		schemeLoki:     newLokiSink,
+		schemeLokiS:    newLokiSink,
+	}
+	_sinkTransports = map[string]transportSupport{
+		schemeTCP:   _transportDialOnly,
+		schemeTCPS:  _transportWithTLS,
+		schemeHTTP:  _transportDialOnly,
+		schemeHTTPS: _transportWithTLS,
+		schemeLoki:  _transportDialOnly,
+		schemeLokiS: _transportWithTLS,
 	}
 }
 
@@ -68,6 +104,74 @@ func (nopCloserSink) Close() error {
 	return nil
 }
 
+// fileSink wraps the sinks opened for the "file" scheme. Syncing a file that
+// doesn't support it, such as a terminal, a pipe, or /dev/stderr in a
+// container, fails with errors like "sync /dev/stderr: invalid argument";
+// since there's nothing to flush, fileSink ignores them. With noSync set,
+// Sync does nothing at all.
+type fileSink struct {
+	Sink
+	noSync bool
+}
+
+func (s fileSink) Sync() error {
+	if s.noSync {
+		return nil
+	}
+	if err := s.Sink.Sync(); err != nil && !isIgnorableSyncError(err) {
+		return err
+	}
+	return nil
+}
+
+// isIgnorableSyncError reports whether an error from syncing a file only
+// means that the file can't be synced. The errors vary by platform; see
+// _ignorableSyncErrors.
+func isIgnorableSyncError(err error) bool {
+	err = unwrapOSError(err)
+	for _, errno := range _ignorableSyncErrors {
+		if err == errno {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapOSError returns the error underneath the wrappers that the os and
+// net packages put around system call errors, so that it can be compared
+// with a syscall.Errno.
+func unwrapOSError(err error) error {
+	for {
+		switch e := err.(type) {
+		case *os.PathError:
+			err = e.Err
+		case *os.LinkError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		default:
+			return err
+		}
+	}
+}
+
+// dropIgnorableSyncErrors removes the errors classified by
+// isIgnorableSyncError from a (possibly aggregated) error.
+func dropIgnorableSyncErrors(err error) error {
+	if err == nil {
+		return nil
+	}
+	var kept error
+	for _, e := range multierr.Errors(err) {
+		if !isIgnorableSyncError(e) {
+			kept = multierr.Append(kept, e)
+		}
+	}
+	return kept
+}
+
 
 
 type errSinkNotFound struct {
@@ -83,36 +187,74 @@ func (e *errSinkNotFound) Error() string {
 // All schemes must be ASCII, valid under section 3.1 of RFC 3986 (https://tools.ietf.org/html/rfc3986#section-3.1),
 // and must not already have a factory registered.
 //
-// Zap automatically registers a factory for the "file" scheme.
+// Zap automatically registers factories for the "file" and "journald"
+// schemes.
 func RegisterSink(scheme string, factory func(*url.URL) (Sink, error)) error {
+	_, err := registerSink(scheme, factory)
+	return err
+}
+
+// RegisterTransportSink registers a sink factory like RegisterSink, and also
+// opts the scheme into Config.Transport: when a logger is built from a
+// Config, the transport settings are added to the query of every URL with
+// this scheme, unless the URL already sets them. The factory should read them
+// with TransportConfig.ApplyQuery.
+//
+// If tls is false, the scheme's URLs only receive the proxy and dialTimeout
+// parameters, since TLS settings are usually an error on a plaintext
+// connection.
+func RegisterTransportSink(scheme string, tls bool, factory func(*url.URL) (Sink, error)) error {
+	normalized, err := registerSink(scheme, factory)
+	if err != nil {
+		return err
+	}
+	support := _transportDialOnly
+	if tls {
+		support = _transportWithTLS
+	}
+	_sinkMutex.Lock()
+	_sinkTransports[normalized] = support
+	_sinkMutex.Unlock()
+	return nil
+}
+
+func registerSink(scheme string, factory func(*url.URL) (Sink, error)) (string, error) {
 
 	_sinkMutex.Lock()
 	defer _sinkMutex.Unlock()
 
 	if scheme == "" {
-		return errors.New("can't register a sink factory for empty string")
+		return "", errors.New("can't register a sink factory for empty string")
 	}
 
 	// 检查 scheme 是否符合要求
 	normalized, err := normalizeScheme(scheme)
 	if err != nil {
-		return fmt.Errorf("%q is not a valid scheme: %v", scheme, err)
+		return "", fmt.Errorf("%q is not a valid scheme: %v", scheme, err)
 	}
 
 	// 根据 normalized 获取 Sink ，如果已经存在，意味着此次是重复注册，则报错
 	if _, ok := _sinkFactories[normalized]; ok {
-		return fmt.Errorf("sink factory already registered for scheme %q", normalized)
+		return "", fmt.Errorf("sink factory already registered for scheme %q", normalized)
 	}
 
 	// 若不存在，则注册
 	_sinkFactories[normalized] = factory
 
-	return nil
+	return normalized, nil
+}
+
+// sinkTransport reports which transport parameters the sinks for a scheme
+// accept.
+func sinkTransport(scheme string) transportSupport {
+	_sinkMutex.RLock()
+	defer _sinkMutex.RUnlock()
+	return _sinkTransports[strings.ToLower(scheme)]
 }
 
 func newSink(rawURL string) (Sink, error) {
-	// 解析 url
-	u, err := url.Parse(rawURL)
+	// 解析 url，Windows 下的盘符路径（如 C:\logs\app.log）会被当作文件路径
+	u, err := parseSinkURL(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("can't parse %q as a URL: %v", rawURL, err)
 	}
@@ -135,6 +277,58 @@ func newSink(rawURL string) (Sink, error) {
 }
 
 
+// parseSinkURL parses a sink URL. On Windows, a path starting with a drive
+// letter, such as C:\logs\app.log or C:/logs/app.log, would otherwise parse
+// as a URL with a one-letter scheme; it's treated as a file path instead,
+// though anything after a "?" is still a query.
+func parseSinkURL(rawURL string) (*url.URL, error) {
+	if !_windowsPaths || !hasDriveLetter(rawURL) {
+		return url.Parse(rawURL)
+	}
+	path, query := rawURL, ""
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		path, query = rawURL[:i], rawURL[i+1:]
+	}
+	return &url.URL{Scheme: schemeFile, Path: path, RawQuery: query}, nil
+}
+
+// hasDriveLetter reports whether path starts with a Windows drive letter and
+// a separator, as in C:\ or C:/.
+func hasDriveLetter(path string) bool {
+	if len(path) < 3 || path[1] != ':' || (path[2] != '\\' && path[2] != '/') {
+		return false
+	}
+	c := path[0] | 0x20 // lowercase
+	return 'a' <= c && c <= 'z'
+}
+
+// filePath extracts the path to open from a file URL. Relative paths may be
+// written as opaque URLs (file:logs/app.log), and on Windows, the leading
+// slash of file:///C:/logs/app.log is dropped.
+func filePath(u *url.URL) string {
+	path := u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if _windowsPaths && len(path) > 1 && path[0] == '/' && hasDriveLetter(path[1:]) {
+		path = path[1:]
+	}
+	return path
+}
+
+// expandHome replaces a leading ~ in path with the current user's home
+// directory, as named by $HOME (or %USERPROFILE% on Windows).
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !(_windowsPaths && strings.HasPrefix(path, `~\`)) {
+		return path, nil
+	}
+	home := os.Getenv(_homeEnv)
+	if home == "" {
+		return "", fmt.Errorf("can't expand ~: $%s is not set", _homeEnv)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
 func newFileSink(u *url.URL) (Sink, error) {
 
 	// 对于 file 类型的 url，不应该包含一些冗余参数，需要进行检查，
@@ -148,10 +342,6 @@ func newFileSink(u *url.URL) (Sink, error) {
 		return nil, fmt.Errorf("fragments not allowed with file URLs: got %v", u)
 	}
 
-	if u.RawQuery != "" {
-		return nil, fmt.Errorf("query parameters not allowed with file URLs: got %v", u)
-	}
-
 	// Error messages are better if we check hostname and port separately.
 	if u.Port() != "" {
 		return nil, fmt.Errorf("ports not allowed with file URLs: got %v", u)
@@ -161,17 +351,187 @@ func newFileSink(u *url.URL) (Sink, error) {
 		return nil, fmt.Errorf("file URLs must leave host empty or use localhost: got %v", u)
 	}
 
-	// 根据 u.Path 决定输出目的地
-	switch u.Path {
-	case "stdout":
-		return nopCloserSink{os.Stdout}, nil
-	case "stderr":
-		return nopCloserSink{os.Stderr}, nil
+	// 解析 query 参数，支持 nosync、mode、flag、bufferSize、flushInterval、expandHome 和 compress
+	var (
+		path          = filePath(u)
+		noSync        bool
+		mode          os.FileMode = 0644
+		flag                      = os.O_WRONLY | os.O_APPEND | os.O_CREATE
+		bufferSize    int
+		flushInterval time.Duration
+		buffered      bool
+		compress      zapcore.CompressionCodec
+	)
+	q := u.Query()
+	for key, vals := range q {
+		val := vals[len(vals)-1]
+		switch key {
+		case "nosync":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nosync %q in file URL: %v", val, err)
+			}
+			noSync = b
+		case "mode":
+			m, err := strconv.ParseUint(val, 8, 32)
+			if err != nil || m&^uint64(os.ModePerm) != 0 {
+				return nil, fmt.Errorf("invalid mode %q in file URL: must be octal permission bits like 0600", val)
+			}
+			mode = os.FileMode(m)
+		case "flag":
+			switch val {
+			case "append":
+				flag = os.O_WRONLY | os.O_APPEND | os.O_CREATE
+			case "trunc":
+				flag = os.O_WRONLY | os.O_TRUNC | os.O_CREATE
+			default:
+				return nil, fmt.Errorf("invalid flag %q in file URL: must be append or trunc", val)
+			}
+		case "bufferSize":
+			n, err := parseByteSize(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bufferSize %q in file URL: %v", val, err)
+			}
+			bufferSize, buffered = n, true
+		case "flushInterval":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid flushInterval %q in file URL: must be a positive duration", val)
+			}
+			flushInterval, buffered = d, true
+		case "expandHome":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expandHome %q in file URL: %v", val, err)
+			}
+			if b {
+				expanded, err := expandHome(path)
+				if err != nil {
+					return nil, fmt.Errorf("can't expand ~ in file URL: %v", err)
+				}
+				path = expanded
+			}
+		case "compress":
+			compress = zapcore.CompressionCodec(val)
+			if !compress.Available() {
+				return nil, fmt.Errorf("invalid compress %q in file URL: must be gzip or a registered codec, such as zstd from the zapzstd package", val)
+			}
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in file URL: got %v", key, u)
+		}
 	}
 
 	// 对于 os.Stdout / os.Stderr 需要用 nopCloserSink 包一层以 Hook 掉 Close() 函数，
-	// 避免影响标准输出/错误输出的处理，而对于普通的 os.File 则可以直接返回。
-	return os.OpenFile(u.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	// 避免影响标准输出/错误输出的处理，而对于普通的 os.File 则可以直接使用。
+	var sink Sink
+	switch path {
+	case "stdout", "stderr":
+		if _, ok := q["mode"]; ok {
+			return nil, fmt.Errorf("mode not allowed with %s: got %v", path, u)
+		}
+		if _, ok := q["flag"]; ok {
+			return nil, fmt.Errorf("flag not allowed with %s: got %v", path, u)
+		}
+		if path == "stdout" {
+			sink = nopCloserSink{os.Stdout}
+		} else {
+			sink = nopCloserSink{os.Stderr}
+		}
+	default:
+		f, err := os.OpenFile(path, flag, mode)
+		if err != nil {
+			return nil, err
+		}
+		sink = f
+	}
+
+	var out Sink = fileSink{Sink: sink, noSync: noSync}
+	if compress != "" {
+		// 压缩写入：Close 时先写入压缩流的结尾，再关闭文件。
+		cws, err := zapcore.NewCompressingWriteSyncer(out, compress, 0)
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		out = &compressedSink{CompressingWriteSyncer: cws, closer: out}
+	}
+	if !buffered {
+		return out, nil
+	}
+
+	// 配置了 bufferSize 或 flushInterval 时，用 BufferedWriteSyncer 包装，
+	// Close 时先停止后台刷新并落盘，再关闭文件。
+	// 缓冲区和后台刷新协程在 fork 时需要特殊处理，因此注册为 ForkHandler，关闭时注销。
+	bs := &bufferedSink{
+		BufferedWriteSyncer: &zapcore.BufferedWriteSyncer{
+			WS:            out,
+			Size:          bufferSize,
+			FlushInterval: flushInterval,
+		},
+		closer: out,
+	}
+	bs.unregisterFork = RegisterForkHandler(bs.BufferedWriteSyncer)
+	return bs, nil
+}
+
+// compressedSink is a file sink that compresses its output. Closing it ends
+// the compressed stream before closing the file.
+type compressedSink struct {
+	*zapcore.CompressingWriteSyncer
+	closer io.Closer
+}
+
+func (s *compressedSink) Close() error {
+	return multierr.Append(s.CompressingWriteSyncer.Close(), s.closer.Close())
+}
+
+// bufferedSink is a file sink that buffers writes in memory. It's registered
+// with RegisterForkHandler until it's closed. Closing it flushes the buffer
+// before closing the file.
+type bufferedSink struct {
+	*zapcore.BufferedWriteSyncer
+	closer         io.Closer
+	unregisterFork func()
+}
+
+func (s *bufferedSink) Close() error {
+	s.unregisterFork()
+	return multierr.Append(s.Stop(), s.closer.Close())
+}
+
+// parseByteSize parses a size in bytes with an optional binary (KiB, MiB,
+// GiB) or decimal (KB, MB, GB) unit suffix, such as "256KiB" or "1MB".
+func parseByteSize(s string) (int, error) {
+	units := []struct {
+		suffix string
+		scale  int
+	}{
+		{"KiB", 1 << 10},
+		{"MiB", 1 << 20},
+		{"GiB", 1 << 30},
+		{"KB", 1000},
+		{"MB", 1000 * 1000},
+		{"GB", 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+	scale := 1
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, scale = strings.TrimSuffix(s, u.suffix), u.scale
+			break
+		}
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	if n > math.MaxInt32/scale {
+		return 0, errors.New("too large")
+	}
+	return n * scale, nil
 }
 
 // 归一化
@@ -22,14 +22,24 @@ package zap
 
 import (
 	"bytes"
+	"compress/gzip"
+	"errors"
 	"io/ioutil"
+	"net"
 	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
 
+	"github.com/blastbao/zap/internal/ztest"
 	"github.com/blastbao/zap/zapcore"
 )
 
@@ -98,3 +108,277 @@ func TestRegisterSinkErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestFileSinkSync(t *testing.T) {
+	einval := &os.PathError{Op: "sync", Path: "/dev/stderr", Err: syscall.EINVAL}
+	failure := errors.New("disk on fire")
+
+	tests := []struct {
+		desc    string
+		syncErr error
+		noSync  bool
+		want    error
+	}{
+		{"success", nil, false, nil},
+		{"unsyncable file", einval, false, nil},
+		{"real failure", failure, false, failure},
+		{"nosync", failure, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			buf := &ztest.Buffer{}
+			buf.SetError(tt.syncErr)
+			sink := fileSink{Sink: nopCloserSink{buf}, noSync: tt.noSync}
+			assert.Equal(t, tt.want, sink.Sync(), "Unexpected error from Sync.")
+			assert.Equal(t, !tt.noSync, buf.Called(), "Unexpected call to the wrapped Sync.")
+		})
+	}
+}
+
+func TestFileSinkNoSyncQuery(t *testing.T) {
+	for _, path := range []string{"stderr?nosync=true", "file:///dev/null?nosync=1", "stdout?nosync=false"} {
+		sink, err := newSink(path)
+		require.NoError(t, err, "Unexpected error opening %q.", path)
+		fs, ok := sink.(fileSink)
+		require.True(t, ok, "Expected a fileSink for %q, got %T.", path, sink)
+		assert.Equal(t, !strings.HasSuffix(path, "false"), fs.noSync, "Unexpected nosync setting for %q.", path)
+		assert.NoError(t, sink.Sync(), "Unexpected error syncing %q.", path)
+		sink.Close()
+	}
+}
+
+func TestDropIgnorableSyncErrors(t *testing.T) {
+	einval := &os.PathError{Op: "sync", Path: "/dev/stdout", Err: syscall.EINVAL}
+	failure := errors.New("fail")
+
+	assert.NoError(t, dropIgnorableSyncErrors(nil), "Expected nil to stay nil.")
+	assert.NoError(t, dropIgnorableSyncErrors(einval), "Expected EINVAL to be ignored.")
+	assert.Equal(t, failure, dropIgnorableSyncErrors(multierr.Combine(einval, failure)), "Expected other errors to be kept.")
+	assert.Equal(t, multierr.Combine(failure, failure), dropIgnorableSyncErrors(multierr.Combine(failure, einval, failure)), "Expected errors to stay aggregated.")
+}
+
+func TestUnwrapOSError(t *testing.T) {
+	errno := syscall.EINVAL
+	tests := []error{
+		errno,
+		&os.PathError{Op: "sync", Path: "/dev/stdout", Err: errno},
+		&os.LinkError{Op: "link", Old: "a", New: "b", Err: errno},
+		&net.OpError{Op: "write", Net: "unixgram", Err: os.NewSyscallError("sendmsg", errno)},
+	}
+	for _, err := range tests {
+		assert.Equal(t, errno, unwrapOSError(err), "Unexpected underlying error for %v.", err)
+	}
+	other := errors.New("fail")
+	assert.Equal(t, other, unwrapOSError(other), "Expected other errors to be returned as is.")
+}
+
+func TestFileSinkModeAndFlag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-sink-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+
+	write := func(rawURL, msg string) {
+		sink, err := newSink(rawURL)
+		require.NoError(t, err, "Unexpected error opening %q.", rawURL)
+		_, err = sink.Write([]byte(msg))
+		require.NoError(t, err, "Unexpected error writing to %q.", rawURL)
+		require.NoError(t, sink.Close(), "Unexpected error closing %q.", rawURL)
+	}
+
+	write("file://"+filepath.ToSlash(path)+"?mode=0600", "foo\n")
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err, "Failed to stat log file.")
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Unexpected file permissions.")
+	}
+
+	write("file://"+filepath.ToSlash(path)+"?flag=append", "bar\n")
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Equal(t, "foo\nbar\n", string(contents), "Expected appending to keep existing contents.")
+
+	write("file://"+filepath.ToSlash(path)+"?flag=trunc", "baz\n")
+	contents, err = ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Equal(t, "baz\n", string(contents), "Expected truncating to drop existing contents.")
+}
+
+func TestFileSinkBuffering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-sink-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+
+	sink, err := newSink("file://" + filepath.ToSlash(path) + "?bufferSize=1KiB&flushInterval=1h")
+	require.NoError(t, err, "Unexpected error opening buffered sink.")
+	bs, ok := sink.(*bufferedSink)
+	require.True(t, ok, "Expected a bufferedSink, got %T.", sink)
+	assert.Equal(t, 1024, bs.Size, "Unexpected buffer size.")
+	assert.Equal(t, time.Hour, bs.FlushInterval, "Unexpected flush interval.")
+
+	sink.Write([]byte("buffered\n"))
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Empty(t, contents, "Expected writes to be buffered.")
+
+	require.NoError(t, sink.Close(), "Unexpected error closing buffered sink.")
+	contents, err = ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Equal(t, "buffered\n", string(contents), "Expected Close to flush the buffer.")
+}
+
+func TestFileSinkCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-sink-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log.gz")
+
+	sink, err := newSink("file://" + filepath.ToSlash(path) + "?compress=gzip&bufferSize=1KiB")
+	require.NoError(t, err, "Unexpected error opening compressed sink.")
+	sink.Write([]byte("compressed\n"))
+	require.NoError(t, sink.Close(), "Unexpected error closing compressed sink.")
+
+	f, err := os.Open(path)
+	require.NoError(t, err, "Failed to open log file.")
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err, "Expected the log file to be gzipped.")
+	contents, err := ioutil.ReadAll(r)
+	require.NoError(t, err, "Failed to decompress log file.")
+	assert.Equal(t, "compressed\n", string(contents), "Unexpected decompressed contents.")
+
+	_, err = newSink("file://" + filepath.ToSlash(path) + "?compress=lz4")
+	if assert.Error(t, err, "Expected an error for an unknown codec.") {
+		assert.Contains(t, err.Error(), "invalid compress", "Unexpected error message.")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  bool
+	}{
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "256KiB", want: 256 << 10},
+		{in: "4MiB", want: 4 << 20},
+		{in: "1GiB", want: 1 << 30},
+		{in: "64KB", want: 64000},
+		{in: "2MB", want: 2000000},
+		{in: "0", err: true},
+		{in: "-1KiB", err: true},
+		{in: "KiB", err: true},
+		{in: "8GiB", err: true},
+		{in: "lots", err: true},
+	}
+	for _, tt := range tests {
+		n, err := parseByteSize(tt.in)
+		if tt.err {
+			assert.Error(t, err, "Expected an error parsing %q.", tt.in)
+			continue
+		}
+		if assert.NoError(t, err, "Unexpected error parsing %q.", tt.in) {
+			assert.Equal(t, tt.want, n, "Unexpected size for %q.", tt.in)
+		}
+	}
+}
+
+func TestParseSinkURLWindowsPaths(t *testing.T) {
+	defer func(saved bool) { _windowsPaths = saved }(_windowsPaths)
+
+	tests := []struct {
+		raw    string
+		scheme string
+		path   string
+		query  string
+	}{
+		{`C:\logs\app.log`, schemeFile, `C:\logs\app.log`, ""},
+		{`d:/logs/app.log?nosync=true`, schemeFile, `d:/logs/app.log`, "nosync=true"},
+		{`file:///C:/logs/app.log`, schemeFile, "/C:/logs/app.log", ""},
+		{`stdout`, "", "stdout", ""},
+		{`C:`, "c", "", ""},
+	}
+	_windowsPaths = true
+	for _, tt := range tests {
+		u, err := parseSinkURL(tt.raw)
+		require.NoError(t, err, "Unexpected error parsing %q.", tt.raw)
+		assert.Equal(t, tt.scheme, u.Scheme, "Unexpected scheme for %q.", tt.raw)
+		assert.Equal(t, tt.path, u.Path, "Unexpected path for %q.", tt.raw)
+		assert.Equal(t, tt.query, u.RawQuery, "Unexpected query for %q.", tt.raw)
+	}
+
+	_windowsPaths = false
+	u, err := parseSinkURL(`C:/logs/app.log`)
+	require.NoError(t, err, "Unexpected error parsing a drive path.")
+	assert.Equal(t, "c", u.Scheme, "Expected drive letters to be schemes on other platforms.")
+}
+
+func TestFilePath(t *testing.T) {
+	defer func(saved bool) { _windowsPaths = saved }(_windowsPaths)
+
+	tests := []struct {
+		raw     string
+		windows string
+		other   string
+	}{
+		{"/var/log/app.log", "/var/log/app.log", "/var/log/app.log"},
+		{"file:///var/log/app.log", "/var/log/app.log", "/var/log/app.log"},
+		{"file:logs/app.log", "logs/app.log", "logs/app.log"},
+		{"file:///C:/logs/app.log", "C:/logs/app.log", "/C:/logs/app.log"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		require.NoError(t, err, "Unexpected error parsing %q.", tt.raw)
+		_windowsPaths = true
+		assert.Equal(t, tt.windows, filePath(u), "Unexpected Windows path for %q.", tt.raw)
+		_windowsPaths = false
+		assert.Equal(t, tt.other, filePath(u), "Unexpected path for %q.", tt.raw)
+	}
+}
+
+func TestFileSinkRelativeAndHomePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-sink-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err, "Failed to get working directory.")
+	require.NoError(t, os.Chdir(dir), "Failed to change working directory.")
+	defer os.Chdir(wd)
+
+	for _, env := range []string{"HOME", "USERPROFILE"} {
+		defer func(env, saved string) { os.Setenv(env, saved) }(env, os.Getenv(env))
+		os.Setenv(env, filepath.Join(dir, "home"))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "home"), 0755), "Failed to create home directory.")
+
+	for _, tt := range []struct {
+		raw  string
+		want string
+	}{
+		{"file:relative.log", "relative.log"},
+		{"~/home.log?expandHome=true", filepath.Join("home", "home.log")},
+		{"file:~/opaque.log?expandHome=1", filepath.Join("home", "opaque.log")},
+	} {
+		sink, err := newSink(tt.raw)
+		require.NoError(t, err, "Unexpected error opening %q.", tt.raw)
+		sink.Close()
+		_, err = os.Stat(filepath.Join(dir, tt.want))
+		assert.NoError(t, err, "Expected %q to create %v.", tt.raw, tt.want)
+	}
+
+	_, err = newSink("~/home.log")
+	assert.Error(t, err, "Expected ~ not to be expanded without expandHome.")
+	_, err = newSink("~/home.log?expandHome=maybe")
+	if assert.Error(t, err, "Expected an error for an invalid expandHome.") {
+		assert.Contains(t, err.Error(), "invalid expandHome", "Unexpected error message.")
+	}
+
+	os.Setenv(_homeEnv, "")
+	_, err = newSink("~/home.log?expandHome=true")
+	if assert.Error(t, err, "Expected an error without a home directory.") {
+		assert.Contains(t, err.Error(), "is not set", "Unexpected error message.")
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"sync"
 
 	"github.com/blastbao/zap/internal/bufferpool"
+	"github.com/blastbao/zap/zapcore"
 )
 
 const _zapPackage = "github.com/blastbao"
@@ -44,27 +45,31 @@ var (
 )
 
 func takeStacktrace() string {
-	buffer := bufferpool.Get()
-	defer buffer.Free()
 	programCounters := _stacktracePool.Get().(*programCounters)
 	defer _stacktracePool.Put(programCounters)
 
-	var numFrames int
-	for {
-		// Skip the call to runtime.Counters and takeStacktrace so that the
-		// program counters start at the caller of takeStacktrace.
-		numFrames = runtime.Callers(2, programCounters.pcs)
-		if numFrames < len(programCounters.pcs) {
-			break
-		}
-		// Don't put the too-short counter slice back into the pool; this lets
-		// the pool adjust if we consistently take deep stacktraces.
-		programCounters = newProgramCounters(len(programCounters.pcs) * 2)
-	}
+	// Skip the calls to runtime.Callers, capture, and takeStacktrace so that
+	// the program counters start at the caller of takeStacktrace.
+	return formatStacktrace(programCounters.capture(3))
+}
+
+// captureStacktrace records the program counters of the current goroutine's
+// stack, leaving symbolization to the returned LazyStack.
+func captureStacktrace() *zapcore.LazyStack {
+	programCounters := _stacktracePool.Get().(*programCounters)
+	defer _stacktracePool.Put(programCounters)
+
+	pcs := programCounters.capture(3)
+	return zapcore.NewLazyStack(append([]uintptr(nil), pcs...), formatStacktrace)
+}
+
+func formatStacktrace(pcs []uintptr) string {
+	buffer := bufferpool.Get()
+	defer buffer.Free()
 
 	i := 0
 	skipZapFrames := true // skip all consecutive zap frames at the beginning.
-	frames := runtime.CallersFrames(programCounters.pcs[:numFrames])
+	frames := runtime.CallersFrames(pcs)
 
 	// Note: On the last iteration, frames.Next() returns false, with a valid
 	// frame, but we ignore this frame. The last frame is a a runtime frame which
@@ -117,6 +122,19 @@ func newProgramCounters(size int) *programCounters {
 	return &programCounters{make([]uintptr, size)}
 }
 
+// capture fills the program counters with the current goroutine's stack,
+// skipping the given number of frames as runtime.Callers does, and returns
+// the filled portion. The slice grows if the stack is too deep to fit.
+func (p *programCounters) capture(skip int) []uintptr {
+	for {
+		numFrames := runtime.Callers(skip, p.pcs)
+		if numFrames < len(p.pcs) {
+			return p.pcs[:numFrames]
+		}
+		p.pcs = make([]uintptr, len(p.pcs)*2)
+	}
+}
+
 func addPrefix(prefix string, ss ...string) []string {
 	withPrefix := make([]string, len(ss))
 	for i, s := range ss {
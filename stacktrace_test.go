@@ -26,6 +26,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap/zaptest/observer"
 )
 
 func TestTakeStacktrace(t *testing.T) {
@@ -40,6 +42,27 @@ func TestTakeStacktrace(t *testing.T) {
 	)
 }
 
+func TestCaptureStacktrace(t *testing.T) {
+	stack := captureStacktrace()
+	assert.Equal(t, takeStacktrace(), stack.String(), "Expected deferred and eager stack traces to match.")
+}
+
+func TestLoggerDeferStacktrace(t *testing.T) {
+	withLogger(t, DebugLevel, opts(AddStacktrace(ErrorLevel), DeferStacktrace()), func(logger *Logger, logs *observer.ObservedLogs) {
+		logger.Info("no stack")
+		logger.Error("stack")
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of logs.")
+		assert.Nil(t, entries[0].LazyStack, "Expected no stack trace below the configured level.")
+
+		ent := entries[1].Entry
+		assert.Equal(t, "", ent.Stack, "Expected eager stack trace to be empty.")
+		require.NotNil(t, ent.LazyStack, "Expected a deferred stack trace.")
+		assert.Contains(t, ent.Stacktrace(), "testing.", "Expected stack trace to start with the test runner.")
+	})
+}
+
 func TestIsZapFrame(t *testing.T) {
 	zapFrames := []string{
 		"github.com/blastbao.Stack",
@@ -73,3 +96,9 @@ func BenchmarkTakeStacktrace(b *testing.B) {
 		takeStacktrace()
 	}
 }
+
+func BenchmarkCaptureStacktrace(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		captureStacktrace()
+	}
+}
@@ -42,6 +42,10 @@ const (
 // structured logging, one for println-style formatting, and one for
 // printf-style formatting. For example, SugaredLoggers can produce InfoLevel
 // output with Infow ("info with" structured context), Info, or Infof.
+//
+// In development (see Development), the printf-style methods check their
+// arguments against the template's verbs, much like go vet, and report any
+// mismatches to the error output along with the caller.
 type SugaredLogger struct {
 	base *Logger
 }
@@ -89,7 +93,28 @@ func (s *SugaredLogger) Named(name string) *SugaredLogger {
 // and execution continues. Passing an orphaned key triggers similar behavior:
 // panics in development and errors in production.
 func (s *SugaredLogger) With(args ...interface{}) *SugaredLogger {
-	return &SugaredLogger{base: s.base.With(s.sweetenFields(args)...)}
+	return &SugaredLogger{base: s.base.with(s.sweetenFields(args))}
+}
+
+// WithGroup opens a namespace, much like slog's Logger.WithGroup: every
+// key-value pair and field added afterwards, whether with With or at the
+// log site, is nested under name. For example,
+//   sugaredLogger.WithGroup("request").With("id", 42).Infow("done", "status", 200)
+// logs {"msg":"done","request":{"id":42,"status":200}} with the JSON
+// encoder. Groups may be nested. Pairs added to the logger before the call
+// stay where they were. If name is empty, the receiver is returned unchanged.
+//
+// See Namespace for details of how namespaces are encoded.
+func (s *SugaredLogger) WithGroup(name string) *SugaredLogger {
+	if name == "" {
+		return s
+	}
+	return &SugaredLogger{base: s.base.with([]Field{Namespace(name)})}
+}
+
+// Trace uses fmt.Sprint to construct and log a message.
+func (s *SugaredLogger) Trace(args ...interface{}) {
+	s.log(TraceLevel, "", args, nil)
 }
 
 // Debug uses fmt.Sprint to construct and log a message.
@@ -128,6 +153,11 @@ func (s *SugaredLogger) Fatal(args ...interface{}) {
 	s.log(FatalLevel, "", args, nil)
 }
 
+// Tracef uses fmt.Sprintf to log a templated message.
+func (s *SugaredLogger) Tracef(template string, args ...interface{}) {
+	s.log(TraceLevel, template, args, nil)
+}
+
 // Debugf uses fmt.Sprintf to log a templated message.
 func (s *SugaredLogger) Debugf(template string, args ...interface{}) {
 	s.log(DebugLevel, template, args, nil)
@@ -164,6 +194,15 @@ func (s *SugaredLogger) Fatalf(template string, args ...interface{}) {
 	s.log(FatalLevel, template, args, nil)
 }
 
+// Tracew logs a message with some additional context. The variadic key-value
+// pairs are treated as they are in With.
+//
+// When trace-level logging is disabled, this is much faster than
+//  s.With(keysAndValues).Trace(msg)
+func (s *SugaredLogger) Tracew(msg string, keysAndValues ...interface{}) {
+	s.log(TraceLevel, msg, nil, keysAndValues)
+}
+
 // Debugw logs a message with some additional context. The variadic key-value
 // pairs are treated as they are in With.
 //
@@ -227,14 +266,40 @@ func (s *SugaredLogger) log(lvl zapcore.Level, template string, fmtArgs []interf
 	if msg == "" && len(fmtArgs) > 0 {
 		msg = fmt.Sprint(fmtArgs...)
 	} else if msg != "" && len(fmtArgs) > 0 {
+		// In development, catch mismatched arguments before they turn into
+		// "%!s(MISSING)" and the like.
+		if s.base.development {
+			s.base.reportPrintfProblems(template, fmtArgs, s.base.callerSkip)
+		}
 		msg = fmt.Sprintf(template, fmtArgs...)
 	}
 
-	if ce := s.base.Check(lvl, msg); ce != nil {
+	var opts []CheckOption
+	if s.base.fingerprintKey != "" && template != "" && len(fmtArgs) > 0 {
+		opts = append(opts, withTemplate(template))
+	}
+	if o := sugarSamplingOverride(context); o != 0 {
+		opts = append(opts, WithSampling(o))
+	}
+	if ce := s.base.Check(lvl, msg, opts...); ce != nil {
 		ce.Write(s.sweetenFields(context)...)
 	}
 }
 
+// sugarSamplingOverride returns the last sampling override among the
+// strongly-typed fields in args, or zero if there's none.
+func sugarSamplingOverride(args []interface{}) zapcore.SamplingOverride {
+	var o zapcore.SamplingOverride
+	for _, arg := range args {
+		if f, ok := arg.(Field); ok && f.Type == zapcore.SkipType {
+			if v, ok := f.Interface.(zapcore.SamplingOverride); ok {
+				o = v
+			}
+		}
+	}
+	return o
+}
+
 func (s *SugaredLogger) sweetenFields(args []interface{}) []Field {
 	if len(args) == 0 {
 		return nil
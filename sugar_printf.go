@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// reportPrintfProblems checks a templated message's arguments against its
+// verbs, like go vet's printf check, and reports any mismatches to the
+// Logger's error output along with the caller. skip counts the frames
+// above reportPrintfProblems's caller to the code that logged the message.
+func (log *Logger) reportPrintfProblems(template string, args []interface{}, skip int) {
+	problems := checkPrintf(template, args)
+	if len(problems) == 0 {
+		return
+	}
+	where := "unknown caller"
+	if caller := zapcore.NewEntryCaller(runtime.Caller(skip + 1)); caller.Defined {
+		if log.callerPaths != nil {
+			caller.File = log.callerPaths.rewrite(caller.File)
+		}
+		where = caller.TrimmedPath()
+	}
+	fmt.Fprintf(log.errorOutput, "%v SugaredLogger: format %q at %s: %s\n",
+		log.clock.Now().UTC(), template, where, strings.Join(problems, "; "))
+	log.errorOutput.Sync()
+}
+
+// checkPrintf describes the ways in which args don't match the verbs in
+// format, leaving out anything fmt would print sensibly anyway. Formats with
+// explicit argument indexes aren't checked.
+func checkPrintf(format string, args []interface{}) []string {
+	var problems []string
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		// Flags.
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		// Width and precision, either of which may come from an argument.
+		for _, prefix := range []string{"", "."} {
+			if prefix != "" {
+				if i >= len(format) || format[i] != '.' {
+					break
+				}
+				i++
+			}
+			if i < len(format) && format[i] == '*' {
+				i++
+				if next >= len(args) {
+					problems = append(problems, "missing argument for * in %"+prefix+"*")
+				} else if !isIntArg(args[next]) {
+					problems = append(problems, fmt.Sprintf("argument %d for * is %T, not an int", next+1, args[next]))
+				}
+				next++
+				continue
+			}
+			for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+				i++
+			}
+		}
+		if i >= len(format) {
+			problems = append(problems, "format ends with an incomplete verb")
+			break
+		}
+		if format[i] == '[' {
+			return nil
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if verb == '%' {
+			continue
+		}
+		if next >= len(args) {
+			problems = append(problems, fmt.Sprintf("missing argument for %%%c", verb))
+			continue
+		}
+		if problem := checkVerb(verb, args[next]); problem != "" {
+			problems = append(problems, fmt.Sprintf("%%%c has argument %d of %s", verb, next+1, problem))
+		}
+		next++
+	}
+	if next < len(args) {
+		problems = append(problems, fmt.Sprintf("%d extra arguments", len(args)-next))
+	}
+	return problems
+}
+
+// checkVerb reports whether arg suits verb, returning a description of the
+// mismatch if it doesn't.
+func checkVerb(verb rune, arg interface{}) string {
+	if _, ok := arg.(fmt.Formatter); ok {
+		return ""
+	}
+	if arg == nil {
+		if strings.ContainsRune("vTp", verb) {
+			return ""
+		}
+		return "nil"
+	}
+	kind := reflect.TypeOf(arg).Kind()
+	_, isStringer := arg.(fmt.Stringer)
+	_, isError := arg.(error)
+	isNumber := isIntArg(arg) || kind == reflect.Float32 || kind == reflect.Float64 ||
+		kind == reflect.Complex64 || kind == reflect.Complex128
+
+	var ok bool
+	switch verb {
+	case 'v', 'T':
+		ok = true
+	case 's':
+		ok = isStringer || isError || (kind != reflect.Bool && !isNumber)
+	case 'q':
+		ok = isStringer || isError || (kind != reflect.Bool && (!isNumber || isIntArg(arg)))
+	case 'd', 'b', 'o', 'O', 'c', 'U':
+		ok = isIntArg(arg) || isComposite(kind) || (verb == 'b' && isNumber)
+	case 'x', 'X':
+		ok = kind != reflect.Bool
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		ok = (isNumber && !isIntArg(arg)) || isComposite(kind)
+	case 't':
+		ok = kind == reflect.Bool || isComposite(kind)
+	case 'p':
+		ok = kind == reflect.Ptr || kind == reflect.Chan || kind == reflect.Func ||
+			kind == reflect.Map || kind == reflect.Slice || kind == reflect.UnsafePointer
+	default:
+		return fmt.Sprintf("unknown verb (%T)", arg)
+	}
+	if ok {
+		return ""
+	}
+	return fmt.Sprintf("type %T", arg)
+}
+
+func isIntArg(arg interface{}) bool {
+	switch reflect.TypeOf(arg).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// isComposite reports whether fmt applies verbs to a kind's elements rather
+// than to the value itself.
+func isComposite(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct, reflect.Ptr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPrintf(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []interface{}
+		want   []string
+	}{
+		{"%s took %v", []interface{}{"job", time.Second}, nil},
+		{"%d%% of %q", []interface{}{42, 'x'}, nil},
+		{"%-8s|%6.2f|%x|%t", []interface{}{errors.New("e"), 1.5, "hex", true}, nil},
+		{"%*d %.*f", []interface{}{4, 1, 2, 3.14}, nil},
+		{"%d %s", []interface{}{[]int{1}, InfoLevel}, nil},
+		{"%[2]s %[1]d", []interface{}{1}, nil},
+		{"%s and %s", []interface{}{"one"}, []string{"missing argument for %s"}},
+		{"%s", []interface{}{"one", "two", "three"}, []string{"2 extra arguments"}},
+		{"%s", []interface{}{42}, []string{"%s has argument 1 of type int"}},
+		{"%d", []interface{}{"42"}, []string{"%d has argument 1 of type string"}},
+		{"%f", []interface{}{nil}, []string{"%f has argument 1 of nil"}},
+		{"%t", []interface{}{1}, []string{"%t has argument 1 of type int"}},
+		{"%p", []interface{}{1}, []string{"%p has argument 1 of type int"}},
+		{"%z", []interface{}{1}, []string{"%z has argument 1 of unknown verb (int)"}},
+		{"%*d", []interface{}{"4", 1}, []string{"argument 1 for * is string, not an int"}},
+		{"100%", []interface{}{1}, []string{"format ends with an incomplete verb", "1 extra arguments"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, checkPrintf(tt.format, tt.args), "Unexpected problems with %q.", tt.format)
+	}
+}
+
+func TestSugarReportsPrintfProblemsInDevelopment(t *testing.T) {
+	for _, dev := range []bool{true, false} {
+		errBuf := &ztest.Buffer{}
+		options := opts(ErrorOutput(errBuf))
+		if dev {
+			options = append(options, Development())
+		}
+		withSugar(t, DebugLevel, options, func(logger *SugaredLogger, logs *observer.ObservedLogs) {
+			logger.Infof("user %s logged in", 42)
+			logger.Errorf("%d of %d", 1, 2)
+			assert.Equal(t, 2, logs.Len(), "Expected entries to be logged regardless.")
+		})
+		if !dev {
+			assert.Empty(t, errBuf.String(), "Expected no checks outside development.")
+			continue
+		}
+		lines := errBuf.Lines()
+		if assert.Equal(t, 1, len(lines), "Expected one problem to be reported.") {
+			assert.Regexp(t, `SugaredLogger: format "user %s logged in" at zap/sugar_printf_test.go:\d+: %s has argument 1 of type int$`, lines[0], "Unexpected report.")
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	schemeTCP  = "tcp"
+	schemeTCPS = "tcps"
+
+	// Defaults used when a URL doesn't set dialTimeout or writeTimeout, so
+	// an unreachable or stalled collector can't block logging forever.
+	_tcpDefaultDialTimeout  = 10 * time.Second
+	_tcpDefaultWriteTimeout = 10 * time.Second
+)
+
+// _tcpQueryAliases are short spellings of TransportConfig's parameters
+// accepted by tcp and tcps URLs.
+var _tcpQueryAliases = map[string]string{
+	"ca":   _transportCAFile,
+	"cert": _transportCertFile,
+	"key":  _transportKeyFile,
+}
+
+// tcpSink writes entries to a TCP connection, optionally secured with TLS.
+// The connection is opened on the first write and reopened after an error,
+// so a collector that restarts only loses the entry being written when it
+// went away.
+//
+// Connections are dialed without holding mu, so Close and writers waiting
+// on an existing connection aren't stuck behind a slow dial; dialMu keeps
+// concurrent writers from each opening their own connection.
+type tcpSink struct {
+	addr         string
+	dial         func(ctx context.Context, network, addr string) (net.Conn, error)
+	tls          *tls.Config // nil for plaintext
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+
+	dialMu sync.Mutex
+	mu     sync.Mutex
+	conn   net.Conn
+}
+
+func newTCPSink(u *url.URL) (Sink, error) {
+	if u.Host == "" || u.Port() == "" {
+		return nil, fmt.Errorf("%s URLs must include a host and port: got %v", u.Scheme, u)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("paths not allowed with %s URLs: got %v", u.Scheme, u)
+	}
+	if u.User != nil || u.Fragment != "" {
+		return nil, fmt.Errorf("user info and fragments not allowed with %s URLs: got %v", u.Scheme, u)
+	}
+
+	q := u.Query()
+	for alias, key := range _tcpQueryAliases {
+		if vals, ok := q[alias]; ok {
+			if _, dup := q[key]; dup {
+				return nil, fmt.Errorf("%s and %s both set in %s URL: got %v", alias, key, u.Scheme, u)
+			}
+			q[key] = vals
+			delete(q, alias)
+		}
+	}
+	transport, rest, err := TransportConfig{}.ApplyQuery(q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s URL: %v", u.Scheme, err)
+	}
+
+	if transport.DialTimeout <= 0 {
+		transport.DialTimeout = _tcpDefaultDialTimeout
+	}
+	s := &tcpSink{
+		addr:         u.Host,
+		dialTimeout:  transport.DialTimeout,
+		writeTimeout: _tcpDefaultWriteTimeout,
+	}
+	for key, vals := range rest {
+		val := vals[len(vals)-1]
+		switch key {
+		case "writeTimeout":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid writeTimeout %q in %s URL: must be a positive duration", val, u.Scheme)
+			}
+			s.writeTimeout = d
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in %s URL: got %v", key, u.Scheme, u)
+		}
+	}
+
+	if u.Scheme == schemeTCPS {
+		if transport.ServerName == "" {
+			transport.ServerName = u.Hostname()
+		}
+		if s.tls, err = transport.TLSConfig(); err != nil {
+			return nil, err
+		}
+	} else if transport.HasTLS() {
+		return nil, fmt.Errorf("TLS parameters require a tcps URL: got %v", u)
+	}
+	if s.dial, err = transport.DialContext(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect opens a connection, completing the TLS handshake for tcps URLs.
+// Both steps are bounded by the dial timeout.
+func (s *tcpSink) connect() (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.dialTimeout)
+	defer cancel()
+	conn, err := s.dial(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.tls != nil {
+		conn.SetDeadline(time.Now().Add(s.dialTimeout))
+		tc := tls.Client(conn, s.tls)
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		conn = tc
+	}
+	return conn, nil
+}
+
+// connection returns the current connection, dialing a new one if there
+// isn't one. It must be called without holding mu.
+func (s *tcpSink) connection() (net.Conn, error) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	s.dialMu.Lock()
+	defer s.dialMu.Unlock()
+	// Another writer may have connected while we waited.
+	s.mu.Lock()
+	conn = s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	return conn, nil
+}
+
+func (s *tcpSink) Write(p []byte) (int, error) {
+	// A connection closed by the server often isn't noticed until a write
+	// fails, so retry once on a fresh connection.
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var conn net.Conn
+		if conn, err = s.connection(); err != nil {
+			return 0, err
+		}
+
+		// Writes are serialized so entries from concurrent callers can't
+		// interleave; the deadline bounds how long each holds the lock.
+		s.mu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+		var n int
+		n, err = conn.Write(p)
+		if err != nil && s.conn == conn {
+			s.conn.Close()
+			s.conn = nil
+		}
+		s.mu.Unlock()
+		if err == nil {
+			return n, nil
+		}
+		if n > 0 {
+			// Don't resend a partial entry.
+			break
+		}
+	}
+	return 0, err
+}
+
+// Sync does nothing, since TCP has no way to flush data that's already been
+// written to the connection.
+func (s *tcpSink) Sync() error {
+	return nil
+}
+
+func (s *tcpSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
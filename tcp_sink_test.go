@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acceptLines accepts connections on ln and sends each line received on
+// any of them to the returned channel.
+func acceptLines(ln net.Listener) <-chan string {
+	lines := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receiveLine(t testing.TB, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a line.")
+		return ""
+	}
+}
+
+func TestTCPSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen.")
+	lines := acceptLines(ln)
+
+	sink, err := newSink("tcp://" + ln.Addr().String() + "?writeTimeout=1s")
+	require.NoError(t, err, "Failed to open tcp sink.")
+	defer sink.Close()
+
+	_, err = sink.Write([]byte("first\n"))
+	require.NoError(t, err, "Unexpected error writing.")
+	assert.Equal(t, "first", receiveLine(t, lines), "Unexpected line received.")
+	assert.NoError(t, sink.Sync(), "Unexpected error syncing.")
+
+	// Drop the connection; the next write should reconnect.
+	s := sink.(*tcpSink)
+	s.mu.Lock()
+	s.conn.Close()
+	s.mu.Unlock()
+	_, err = sink.Write([]byte("second\n"))
+	require.NoError(t, err, "Expected the sink to reconnect.")
+	assert.Equal(t, "second", receiveLine(t, lines), "Unexpected line received after reconnecting.")
+
+	ln.Close()
+	require.NoError(t, sink.Close(), "Unexpected error closing sink.")
+	_, err = sink.Write([]byte("third\n"))
+	assert.Error(t, err, "Expected writes to fail without a server.")
+}
+
+func TestTCPSinkMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-tcp-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err, "Failed to load certificate.")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err, "Failed to parse certificate.")
+	clients := x509.NewCertPool()
+	clients.AddCert(leaf)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	})
+	require.NoError(t, err, "Failed to listen.")
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	// The test certificate has no subject alternative names, so it can't be
+	// verified against the server's address.
+	query := url.Values{
+		"ca":                 {certFile},
+		"cert":               {certFile},
+		"key":                {keyFile},
+		"insecureSkipVerify": {"true"},
+	}
+	sink, err := newSink("tcps://" + ln.Addr().String() + "?" + query.Encode())
+	require.NoError(t, err, "Failed to open tcps sink.")
+	defer sink.Close()
+	_, err = sink.Write([]byte("secure\n"))
+	require.NoError(t, err, "Unexpected error writing over TLS.")
+	assert.Equal(t, "secure", receiveLine(t, lines), "Unexpected line received over TLS.")
+
+	query.Del("insecureSkipVerify")
+	sink, err = newSink("tcps://" + ln.Addr().String() + "?" + query.Encode())
+	require.NoError(t, err, "Failed to open tcps sink.")
+	defer sink.Close()
+	_, err = sink.Write([]byte("unverified\n"))
+	assert.Error(t, err, "Expected the server's certificate to fail verification.")
+}
+
+func TestTCPSinkErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"tcp://logs", "must include a host and port"},
+		{"tcp://logs:514/path", "paths not allowed"},
+		{"tcp://user@logs:514", "user info and fragments not allowed"},
+		{"tcp://logs:514?color=blue", "unknown query parameter"},
+		{"tcp://logs:514?writeTimeout=0s", "invalid writeTimeout"},
+		{"tcp://logs:514?ca=ca.pem", "TLS parameters require a tcps URL"},
+		{"tcps://logs:514?ca=ca.pem&caFile=ca.pem", "ca and caFile both set"},
+		{"tcps://logs:514?insecureSkipVerify=maybe", "invalid tcps URL"},
+		{"tcps://logs:514?ca=/does/not/exist.pem", "can't read CA bundle"},
+		{"tcps://logs:514?cert=cert.pem", "must be set together"},
+	}
+	for _, tt := range tests {
+		_, err := newSink(tt.url)
+		if assert.Error(t, err, "Expected an error for URL %q.", tt.url) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error for URL %q.", tt.url)
+		}
+	}
+}
+
+func TestTCPSinkDefaultTimeouts(t *testing.T) {
+	sink, err := newSink("tcp://127.0.0.1:514")
+	require.NoError(t, err, "Failed to open tcp sink.")
+	s := sink.(*tcpSink)
+	assert.Equal(t, _tcpDefaultDialTimeout, s.dialTimeout, "Unexpected default dial timeout.")
+	assert.Equal(t, _tcpDefaultWriteTimeout, s.writeTimeout, "Unexpected default write timeout.")
+
+	sink, err = newSink("tcp://127.0.0.1:514?dialTimeout=1s&writeTimeout=2s")
+	require.NoError(t, err, "Failed to open tcp sink.")
+	s = sink.(*tcpSink)
+	assert.Equal(t, time.Second, s.dialTimeout, "Unexpected dial timeout.")
+	assert.Equal(t, 2*time.Second, s.writeTimeout, "Unexpected write timeout.")
+}
+
+func TestTCPSinkDialsWithoutLock(t *testing.T) {
+	sink, err := newSink("tcp://127.0.0.1:514")
+	require.NoError(t, err, "Failed to open tcp sink.")
+	s := sink.(*tcpSink)
+
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	s.dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		close(dialing)
+		<-release
+		return nil, errors.New("unreachable")
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := sink.Write([]byte("stuck\n"))
+		done <- err
+	}()
+	<-dialing
+
+	closed := make(chan struct{})
+	go func() {
+		sink.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked behind a pending dial.")
+	}
+	close(release)
+	assert.Error(t, <-done, "Expected the write to fail.")
+}
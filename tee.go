@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "github.com/blastbao/zap/zapcore"
+
+// A TeeSpec describes one destination of a Logger built by NewTeeLogger.
+type TeeSpec struct {
+	// Encoder serializes the entries written to Sink.
+	Encoder zapcore.Encoder
+	// Sink receives the encoded entries. It must be safe for concurrent use;
+	// the Open and zapcore.Lock functions are the simplest ways to protect
+	// files with a mutex.
+	Sink zapcore.WriteSyncer
+	// Level decides which entries are written to Sink. If nil, every level
+	// is enabled.
+	Level zapcore.LevelEnabler
+}
+
+// NewTeeLogger builds a Logger that writes each entry to every spec whose
+// Level enables it, so that the common trio of human-readable output on the
+// terminal, JSON in a file, and errors in a file of their own doesn't
+// require assembling zapcore.Cores by hand:
+//
+//	logger := zap.NewTeeLogger(
+//		zap.TeeSpec{
+//			Encoder: zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+//			Sink:    zapcore.Lock(os.Stderr),
+//			Level:   zap.InfoLevel,
+//		},
+//		zap.TeeSpec{
+//			Encoder: zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+//			Sink:    appLog,
+//			Level:   zap.DebugLevel,
+//		},
+//		zap.TeeSpec{
+//			Encoder: zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+//			Sink:    errorLog,
+//			Level:   zap.ErrorLevel,
+//		},
+//	)
+//
+// Use WithOptions to add options to the result. With no specs, the Logger
+// discards everything.
+func NewTeeLogger(specs ...TeeSpec) *Logger {
+	cores := make([]zapcore.Core, 0, len(specs))
+	for _, spec := range specs {
+		lvl := spec.Level
+		if lvl == nil {
+			lvl = zapcore.TraceLevel
+		}
+		cores = append(cores, zapcore.NewCore(spec.Encoder, spec.Sink, lvl))
+	}
+	return New(zapcore.NewTee(cores...))
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTeeLogger(t *testing.T) {
+	console, all, errs := &ztest.Buffer{}, &ztest.Buffer{}, &ztest.Buffer{}
+	encoderCfg := zapcore.EncoderConfig{MessageKey: "msg", LevelKey: "level", EncodeLevel: zapcore.LowercaseLevelEncoder}
+	logger := NewTeeLogger(
+		TeeSpec{Encoder: zapcore.NewConsoleEncoder(encoderCfg), Sink: console, Level: InfoLevel},
+		TeeSpec{Encoder: zapcore.NewJSONEncoder(encoderCfg), Sink: all},
+		TeeSpec{Encoder: zapcore.NewJSONEncoder(encoderCfg), Sink: errs, Level: ErrorLevel},
+	)
+
+	logger.Debug("debug")
+	logger.Info("info", Int("n", 1))
+	logger.Error("error")
+	assert.Equal(t, []string{"info\tinfo\t{\"n\": 1}", "error\terror"}, console.Lines(), "Unexpected console output.")
+	assert.Equal(t, []string{
+		`{"level":"debug","msg":"debug"}`,
+		`{"level":"info","msg":"info","n":1}`,
+		`{"level":"error","msg":"error"}`,
+	}, all.Lines(), "Expected a nil Level to enable every level.")
+	assert.Equal(t, []string{`{"level":"error","msg":"error"}`}, errs.Lines(), "Unexpected error output.")
+}
+
+func TestNewTeeLoggerNoSpecs(t *testing.T) {
+	logger := NewTeeLogger()
+	assert.Nil(t, logger.Check(ErrorLevel, "dropped"), "Expected a Logger without specs to discard entries.")
+}
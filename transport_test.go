@@ -0,0 +1,205 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate and its key to dir,
+// returning their paths.
+func writeTestCert(t testing.TB, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Failed to generate key.")
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "zap test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err, "Failed to create certificate.")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "Failed to marshal key.")
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestTransportConfigApplyQuery(t *testing.T) {
+	base := TransportConfig{CAFile: "ca.pem", Proxy: "http://proxy:3128"}
+	q := url.Values{
+		"proxy":              {"socks5://proxy:1080"},
+		"serverName":         {"logs.internal"},
+		"insecureSkipVerify": {"true"},
+		"dialTimeout":        {"3s"},
+		"batchSize":          {"10"},
+	}
+
+	cfg, rest, err := base.ApplyQuery(q)
+	require.NoError(t, err, "Unexpected error applying query.")
+	assert.Equal(t, TransportConfig{
+		CAFile:             "ca.pem",
+		ServerName:         "logs.internal",
+		InsecureSkipVerify: true,
+		Proxy:              "socks5://proxy:1080",
+		DialTimeout:        3 * time.Second,
+	}, cfg, "Unexpected transport config.")
+	assert.Equal(t, url.Values{"batchSize": {"10"}}, rest, "Expected unknown parameters to be returned.")
+	assert.Equal(t, "http://proxy:3128", base.Proxy, "Expected ApplyQuery not to modify the receiver.")
+}
+
+func TestTransportConfigApplyQueryErrors(t *testing.T) {
+	for _, q := range []url.Values{
+		{"insecureSkipVerify": {"maybe"}},
+		{"dialTimeout": {"soon"}},
+	} {
+		_, _, err := TransportConfig{}.ApplyQuery(q)
+		assert.Error(t, err, "Expected an error applying query %v.", q)
+	}
+}
+
+func TestTransportConfigTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zap-transport-test")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	cfg, err := TransportConfig{
+		CAFile:     certFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ServerName: "logs.internal",
+	}.TLSConfig()
+	require.NoError(t, err, "Unexpected error building TLS config.")
+	assert.Equal(t, "logs.internal", cfg.ServerName, "Unexpected server name.")
+	assert.NotNil(t, cfg.RootCAs, "Expected custom root CAs.")
+	assert.Equal(t, 1, len(cfg.Certificates), "Expected a client certificate.")
+
+	tests := []struct {
+		cfg TransportConfig
+		err string
+	}{
+		{TransportConfig{CAFile: filepath.Join(dir, "missing.pem")}, "can't read CA bundle"},
+		{TransportConfig{CAFile: keyFile}, "no certificates found"},
+		{TransportConfig{CertFile: certFile}, "must be set together"},
+		{TransportConfig{CertFile: certFile, KeyFile: certFile}, "can't load client certificate"},
+	}
+	for _, tt := range tests {
+		_, err := tt.cfg.TLSConfig()
+		if assert.Error(t, err, "Expected an error building TLS config from %+v.", tt.cfg) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error message.")
+		}
+	}
+}
+
+func TestTransportConfigHasTLS(t *testing.T) {
+	assert.False(t, TransportConfig{Proxy: "http://proxy:3128", DialTimeout: time.Second}.HasTLS(), "Expected dial settings not to imply TLS.")
+	assert.True(t, TransportConfig{ServerName: "logs.internal"}.HasTLS(), "Expected server name to imply TLS.")
+	assert.True(t, TransportConfig{InsecureSkipVerify: true}.HasTLS(), "Expected insecureSkipVerify to imply TLS.")
+}
+
+func TestTransportConfigDialers(t *testing.T) {
+	_, err := TransportConfig{Proxy: "gopher://proxy:70"}.DialContext()
+	assert.Error(t, err, "Expected an error for an unsupported proxy scheme.")
+
+	_, err = TransportConfig{Proxy: ":%"}.DialContext()
+	assert.Error(t, err, "Expected an error for an unparseable proxy URL.")
+
+	tr, err := TransportConfig{}.HTTPTransport()
+	require.NoError(t, err, "Unexpected error building HTTP transport.")
+	assert.NotNil(t, tr.Proxy, "Expected environment proxies to be honored by default.")
+
+	tr, err = TransportConfig{Proxy: "socks5://proxy:1080"}.HTTPTransport()
+	require.NoError(t, err, "Unexpected error building HTTP transport.")
+	assert.Nil(t, tr.Proxy, "Expected an explicit proxy to override the environment.")
+}
+
+func TestTransportConfigApplyToPaths(t *testing.T) {
+	defer resetSinkRegistry()
+	nopFactory := func(*url.URL) (Sink, error) { return nopCloserSink{zapcore.AddSync(ioutil.Discard)}, nil }
+	require.NoError(t, RegisterSink("custom", nopFactory), "Failed to register sink.")
+	require.NoError(t, RegisterTransportSink("rpc", false, nopFactory), "Failed to register sink.")
+	require.NoError(t, RegisterTransportSink("rpcs", true, nopFactory), "Failed to register sink.")
+
+	cfg := TransportConfig{CAFile: "ca.pem", DialTimeout: time.Second}
+	paths := []string{
+		"stdout",
+		"/var/log/app.log",
+		"file:///var/log/app.log",
+		"custom://logs:9000",
+		"unknown://logs:9000",
+		"tcp://logs:9000",
+		"tcps://logs:9000",
+		"rpc://logs:9000",
+		"rpcs://logs:9000",
+		"rpcs://logs:9000?caFile=other.pem&batchSize=1",
+	}
+	assert.Equal(t, []string{
+		"stdout",
+		"/var/log/app.log",
+		"file:///var/log/app.log",
+		"custom://logs:9000",
+		"unknown://logs:9000",
+		"tcp://logs:9000?dialTimeout=1s",
+		"tcps://logs:9000?caFile=ca.pem&dialTimeout=1s",
+		"rpc://logs:9000?dialTimeout=1s",
+		"rpcs://logs:9000?caFile=ca.pem&dialTimeout=1s",
+		"rpcs://logs:9000?batchSize=1&caFile=other.pem&dialTimeout=1s",
+	}, cfg.applyToPaths(paths), "Unexpected paths.")
+
+	assert.Equal(t, paths, TransportConfig{}.applyToPaths(paths), "Expected zero config to leave paths unchanged.")
+	assert.Equal(
+		t,
+		[]string{"http://logs:9000"},
+		TransportConfig{CAFile: "ca.pem"}.applyToPaths([]string{"http://logs:9000"}),
+		"Expected TLS settings to skip plaintext schemes.",
+	)
+}
+
+func TestRegisterTransportSinkErrors(t *testing.T) {
+	defer resetSinkRegistry()
+	nopFactory := func(*url.URL) (Sink, error) { return nopCloserSink{zapcore.AddSync(ioutil.Discard)}, nil }
+	assert.Error(t, RegisterTransportSink("tcp", true, nopFactory), "Expected an error re-registering a scheme.")
+	assert.Equal(t, _transportDialOnly, sinkTransport("tcp"), "Expected a failed registration to leave transport support unchanged.")
+}
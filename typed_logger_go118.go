@@ -0,0 +1,259 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+)
+
+// A TypedLogger logs entries whose fields are described by a struct type T,
+// so that every call site logs the same, compile-checked set of fields:
+//
+//	type RequestFields struct {
+//		Method  string        `zap:"method"`
+//		Status  int           `zap:"status"`
+//		Latency time.Duration `zap:"latency"`
+//		Err     error         `zap:"error,omitempty"`
+//	}
+//
+//	requests := zap.NewTypedLogger[RequestFields](logger)
+//	requests.Info("served", RequestFields{Method: "GET", Status: 200})
+//
+// Each exported field of T becomes a logged field. Its key is taken from the
+// field's "zap" tag, or is the field's name if there's no tag; a tag of "-"
+// skips the field, and the "omitempty" option skips zero values. Embedded
+// structs without a tag are flattened into their parent.
+//
+// How to encode each field is worked out once per type and cached, so
+// logging a T costs little more than passing the equivalent Fields. Common
+// field types are logged without boxing; times, marshalers, errors,
+// Stringers, and other types are logged as Any would log them.
+type TypedLogger[T any] struct {
+	log  *Logger
+	plan *typedPlan
+}
+
+// NewTypedLogger wraps a Logger in a TypedLogger for the struct type T. It
+// panics if T isn't a struct.
+func NewTypedLogger[T any](l *Logger) *TypedLogger[T] {
+	return &TypedLogger[T]{
+		log:  l,
+		plan: typedPlanFor(reflect.TypeOf((*T)(nil)).Elem()),
+	}
+}
+
+// Logger returns the underlying Logger.
+func (t *TypedLogger[T]) Logger() *Logger {
+	return t.log
+}
+
+// With adds a variadic number of fields to the logging context, in addition
+// to those described by T.
+func (t *TypedLogger[T]) With(fields ...Field) *TypedLogger[T] {
+	return &TypedLogger[T]{log: t.log.With(fields...), plan: t.plan}
+}
+
+// Fields returns the fields that describe v, for use with an ordinary
+// Logger.
+func (t *TypedLogger[T]) Fields(v T) []Field {
+	return t.plan.fields(reflect.ValueOf(&v).Elem())
+}
+
+// Debug logs a message at DebugLevel, with the fields that describe v.
+func (t *TypedLogger[T]) Debug(msg string, v T) {
+	if ce := t.log.check(DebugLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+// Info logs a message at InfoLevel, with the fields that describe v.
+func (t *TypedLogger[T]) Info(msg string, v T) {
+	if ce := t.log.check(InfoLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+// Warn logs a message at WarnLevel, with the fields that describe v.
+func (t *TypedLogger[T]) Warn(msg string, v T) {
+	if ce := t.log.check(WarnLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+// Error logs a message at ErrorLevel, with the fields that describe v.
+func (t *TypedLogger[T]) Error(msg string, v T) {
+	if ce := t.log.check(ErrorLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+// DPanic logs a message at DPanicLevel, with the fields that describe v. As
+// with Logger.DPanic, the logger then panics if it's in development mode.
+func (t *TypedLogger[T]) DPanic(msg string, v T) {
+	if ce := t.log.check(DPanicLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+// Panic logs a message at PanicLevel, with the fields that describe v. The
+// logger then panics, even if logging at PanicLevel is disabled.
+func (t *TypedLogger[T]) Panic(msg string, v T) {
+	if ce := t.log.check(PanicLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+// Fatal logs a message at FatalLevel, with the fields that describe v. The
+// logger then calls os.Exit(1), even if logging at FatalLevel is disabled.
+func (t *TypedLogger[T]) Fatal(msg string, v T) {
+	if ce := t.log.check(FatalLevel, msg, nil); ce != nil {
+		ce.Write(t.Fields(v)...)
+	}
+}
+
+var (
+	_typedPlans sync.Map // map[reflect.Type]*typedPlan
+
+	_durationType  = reflect.TypeOf(time.Duration(0))
+	_typedAnyTypes = []reflect.Type{
+		reflect.TypeOf((*zapcore.ObjectMarshaler)(nil)).Elem(),
+		reflect.TypeOf((*zapcore.ArrayMarshaler)(nil)).Elem(),
+		reflect.TypeOf((*error)(nil)).Elem(),
+		reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+	}
+)
+
+// typedPlan describes how to turn a struct into fields.
+type typedPlan struct {
+	steps []typedField
+}
+
+type typedField struct {
+	key       string
+	index     []int
+	omitEmpty bool
+	encode    func(key string, v reflect.Value) Field
+}
+
+func typedPlanFor(t reflect.Type) *typedPlan {
+	if p, ok := _typedPlans.Load(t); ok {
+		return p.(*typedPlan)
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("zap: TypedLogger needs a struct type, got %v", t))
+	}
+	p := &typedPlan{}
+	p.add(t, nil)
+	actual, _ := _typedPlans.LoadOrStore(t, p)
+	return actual.(*typedPlan)
+}
+
+func (p *typedPlan) add(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("zap")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+		idx := append(index[:len(index):len(index)], i)
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			p.add(sf.Type, idx)
+			continue
+		}
+		if sf.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		p.steps = append(p.steps, typedField{
+			key:       name,
+			index:     idx,
+			omitEmpty: opts == "omitempty",
+			encode:    typedEncoderFor(sf.Type),
+		})
+	}
+}
+
+func (p *typedPlan) fields(v reflect.Value) []Field {
+	fields := make([]Field, 0, len(p.steps))
+	for i := range p.steps {
+		f := &p.steps[i]
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		fields = append(fields, f.encode(f.key, fv))
+	}
+	return fields
+}
+
+// typedEncoderFor picks the cheapest way to log values of type t.
+func typedEncoderFor(t reflect.Type) func(string, reflect.Value) Field {
+	if t == _durationType {
+		return func(key string, v reflect.Value) Field { return Duration(key, time.Duration(v.Int())) }
+	}
+	for _, iface := range _typedAnyTypes {
+		if t.Implements(iface) {
+			return typedAny
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(key string, v reflect.Value) Field { return Bool(key, v.Bool()) }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(key string, v reflect.Value) Field { return Int64(key, v.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(key string, v reflect.Value) Field { return Uint64(key, v.Uint()) }
+	case reflect.Uintptr:
+		return func(key string, v reflect.Value) Field { return Uintptr(key, uintptr(v.Uint())) }
+	case reflect.Float32:
+		return func(key string, v reflect.Value) Field { return Float32(key, float32(v.Float())) }
+	case reflect.Float64:
+		return func(key string, v reflect.Value) Field { return Float64(key, v.Float()) }
+	case reflect.String:
+		return func(key string, v reflect.Value) Field { return String(key, v.String()) }
+	default:
+		return typedAny
+	}
+}
+
+func typedAny(key string, v reflect.Value) Field {
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && v.IsNil() {
+		return Skip()
+	}
+	return Any(key, v.Interface())
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package zap
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedBase struct {
+	Service string `zap:"service"`
+}
+
+type typedRequest struct {
+	typedBase
+	Method   string        `zap:"method"`
+	Status   int           `zap:"status"`
+	Bytes    uint32        `zap:"bytes,omitempty"`
+	Ratio    float64       `zap:"ratio"`
+	Cached   bool          `zap:"cached"`
+	Latency  time.Duration `zap:"latency"`
+	Err      error         `zap:"error,omitempty"`
+	At       time.Time     `zap:"at"`
+	Tags     []string      `zap:"tags,omitempty"`
+	Untagged string
+	Ignored  string `zap:"-"`
+	internal string
+}
+
+func TestTypedLogger(t *testing.T) {
+	at := time.Unix(0, 0)
+	withLogger(t, DebugLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		requests := NewTypedLogger[typedRequest](logger).With(String("component", "http"))
+		requests.Info("served", typedRequest{
+			typedBase: typedBase{Service: "api"},
+			Method:    "GET",
+			Status:    200,
+			Ratio:     0.5,
+			Cached:    true,
+			Latency:   time.Millisecond,
+			At:        at,
+			Untagged:  "u",
+			Ignored:   "i",
+			internal:  "x",
+		})
+		requests.Error("failed", typedRequest{Status: 500, Bytes: 12, Err: errors.New("boom"), Tags: []string{"a"}})
+
+		entries := logs.AllUntimed()
+		require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+		assert.Equal(t, map[string]interface{}{
+			"component": "http",
+			"service":   "api",
+			"method":    "GET",
+			"status":    int64(200),
+			"ratio":     0.5,
+			"cached":    true,
+			"latency":   time.Millisecond,
+			"at":        at,
+			"Untagged":  "u",
+		}, entries[0].ContextMap(), "Unexpected fields.")
+		assert.Equal(t, ErrorLevel, entries[1].Level, "Unexpected level.")
+		assert.Equal(t, uint64(12), entries[1].ContextMap()["bytes"], "Expected non-zero omitempty fields to be logged.")
+		assert.Equal(t, "boom", entries[1].ContextMap()["error"], "Unexpected error field.")
+		assert.Equal(t, []interface{}{"a"}, entries[1].ContextMap()["tags"], "Unexpected tags field.")
+	})
+}
+
+func TestTypedLoggerLevels(t *testing.T) {
+	type fields struct {
+		N int `zap:"n"`
+	}
+	withLogger(t, InfoLevel, nil, func(logger *Logger, logs *observer.ObservedLogs) {
+		typed := NewTypedLogger[fields](logger)
+		typed.Debug("debug", fields{1})
+		typed.Info("info", fields{2})
+		typed.Warn("warn", fields{3})
+		typed.DPanic("dpanic", fields{4})
+		assert.Panics(t, func() { typed.Panic("panic", fields{5}) }, "Expected Panic to panic.")
+
+		var levels []zapcore.Level
+		for _, ent := range logs.AllUntimed() {
+			levels = append(levels, ent.Level)
+		}
+		assert.Equal(t, []zapcore.Level{InfoLevel, WarnLevel, DPanicLevel, PanicLevel}, levels, "Unexpected levels.")
+		assert.Equal(t, logger, typed.Logger(), "Expected the underlying Logger.")
+	})
+}
+
+func TestTypedLoggerCaller(t *testing.T) {
+	withLogger(t, DebugLevel, opts(AddCaller()), func(logger *Logger, logs *observer.ObservedLogs) {
+		NewTypedLogger[typedBase](logger).Info("here", typedBase{})
+		require.Equal(t, 1, logs.Len(), "Expected an entry.")
+		assert.Regexp(t, `typed_logger_go118_test.go:\d+$`, logs.All()[0].Caller.String(), "Expected the caller to be the call site.")
+	})
+}
+
+func TestTypedLoggerNonStruct(t *testing.T) {
+	assert.Panics(t, func() { NewTypedLogger[string](NewNop()) }, "Expected non-struct types to panic.")
+}
+
+func BenchmarkTypedLogger(b *testing.B) {
+	type fields struct {
+		Method string        `zap:"method"`
+		Status int           `zap:"status"`
+		Took   time.Duration `zap:"took"`
+	}
+	logger := New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionEncoderConfig()),
+		zapcore.AddSync(ioutil.Discard),
+		DebugLevel,
+	))
+	typed := NewTypedLogger[fields](logger)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			typed.Info("served", fields{"GET", 200, time.Millisecond})
+		}
+	})
+}
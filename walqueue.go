@@ -0,0 +1,608 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	schemeWALQueue = "walqueue"
+
+	_walSegmentSuffix      = ".wal"
+	_walCursorFile         = "cursor"
+	_walRecordHeader       = 8 // length and CRC-32C, both big-endian uint32s
+	_walMaxRecord          = 64 << 20
+	_defaultWALSegmentSize = 64 << 20
+	_defaultWALRetry       = time.Second
+	_defaultWALBatchSize   = 256
+)
+
+var (
+	errWALQueueClosed = errors.New("walqueue: write to closed queue")
+	_walCRCTable      = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// WALQueueConfig configures a WALQueue.
+type WALQueueConfig struct {
+	// Dir is the directory holding the queue's segment files. It's created
+	// if it doesn't exist. Only one WALQueue may use a directory at a time.
+	Dir string
+	// SegmentSize is the size at which a segment file is closed and a new
+	// one started. Segments are deleted once all their entries have been
+	// delivered. The default is 64 MiB.
+	SegmentSize int
+	// RetryInterval is how long the shipper waits after a failed delivery
+	// before trying again. The default is one second.
+	RetryInterval time.Duration
+	// BatchSize is the most entries delivered between acknowledgements. The
+	// default is 256.
+	BatchSize int
+}
+
+// WALQueueStats counts the work done by a WALQueue's shipper.
+type WALQueueStats struct {
+	// Shipped is the number of entries delivered and acknowledged.
+	Shipped uint64
+	// Failures is the number of failed delivery attempts.
+	Failures uint64
+	// Corrupt is the number of entries found damaged on disk and skipped.
+	Corrupt uint64
+	// LastError is the error from the most recent failed delivery.
+	LastError error
+}
+
+// A WALQueue is a Sink that gives at-least-once delivery to another Sink
+// (the downstream). Entries are appended to segment files on local disk, and
+// a shipper goroutine writes them to the downstream in batches. A batch is
+// acknowledged when the downstream's Sync succeeds, and only then is the
+// queue's cursor advanced past it, so entries survive both downstream
+// outages and process restarts: a WALQueue reopened on the same directory
+// resumes from its cursor, and entries may be delivered twice, but aren't
+// lost.
+//
+// Sync flushes the current segment to disk, but doesn't wait for delivery.
+// Since Loggers only sync after entries above ErrorLevel, consider SyncOn
+// to make errors durable as soon as they're logged.
+//
+// WALQueues can also be opened from Config.OutputPaths with "walqueue" URLs,
+// naming the downstream in the downstream parameter:
+//
+//	walqueue:///var/spool/app-logs?segmentSize=64MB&downstream=grpcs%3A%2F%2Flogs.example.com%3A9000
+//
+// The parameters segmentSize, retryInterval, and batchSize match the fields
+// of WALQueueConfig; sizes take units like MiB or MB.
+type WALQueue struct {
+	cfg        WALQueueConfig
+	downstream Sink
+
+	mu       sync.Mutex
+	segments []uint64 // indexes of the segments on disk, ascending
+	w        walFile  // the last segment, being appended to
+	wSize    int64
+	closed   bool
+	stats    WALQueueStats
+
+	// The cursor is only used by the shipper, after the queue is opened.
+	cursorIndex  uint64
+	cursorOffset int64
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// walFile is the part of *os.File a WALQueue appends to.
+type walFile interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// NewWALQueue opens the queue in cfg.Dir, recovering any entries left by a
+// previous process, and starts shipping them to downstream. Closing the
+// queue closes the downstream too.
+func NewWALQueue(cfg WALQueueConfig, downstream Sink) (*WALQueue, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("walqueue: no directory specified")
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = _defaultWALSegmentSize
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = _defaultWALRetry
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = _defaultWALBatchSize
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	q := &WALQueue{
+		cfg:        cfg,
+		downstream: downstream,
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	go q.ship()
+	return q, nil
+}
+
+// recover finds the segments and cursor left in the directory, and
+// truncates any partly written entry at the end of the last segment.
+func (q *WALQueue) recover() error {
+	names, err := ioutil.ReadDir(q.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range names {
+		name := info.Name()
+		if !strings.HasSuffix(name, _walSegmentSuffix) {
+			continue
+		}
+		idx, err := strconv.ParseUint(strings.TrimSuffix(name, _walSegmentSuffix), 16, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, idx)
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if err := q.readCursor(); err != nil {
+		return err
+	}
+	if len(q.segments) == 0 {
+		first := q.cursorIndex
+		if first == 0 {
+			first = 1
+		}
+		q.cursorIndex, q.cursorOffset = first, 0
+		return q.openSegment(first)
+	}
+	if q.cursorIndex < q.segments[0] {
+		// The cursor's segment was delivered and deleted.
+		q.cursorIndex, q.cursorOffset = q.segments[0], 0
+	}
+
+	last := q.segments[len(q.segments)-1]
+	f, err := os.OpenFile(q.segmentPath(last), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	end, err := validEnd(f)
+	if err == nil {
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	q.w, q.wSize = f, end
+	return nil
+}
+
+func (q *WALQueue) segmentPath(idx uint64) string {
+	return filepath.Join(q.cfg.Dir, fmt.Sprintf("%016x%s", idx, _walSegmentSuffix))
+}
+
+// openSegment starts a new, empty segment. It must be called with the lock
+// held, or before the shipper starts.
+func (q *WALQueue) openSegment(idx uint64) error {
+	f, err := os.OpenFile(q.segmentPath(idx), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	q.segments = append(q.segments, idx)
+	q.w, q.wSize = f, 0
+	return nil
+}
+
+// Write appends a copy of p to the queue as one entry.
+func (q *WALQueue) Write(p []byte) (int, error) {
+	if len(p) > _walMaxRecord {
+		return 0, fmt.Errorf("walqueue: entry of %d bytes is too large", len(p))
+	}
+	rec := make([]byte, _walRecordHeader+len(p))
+	binary.BigEndian.PutUint32(rec, uint32(len(p)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.Checksum(p, _walCRCTable))
+	copy(rec[_walRecordHeader:], p)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return 0, errWALQueueClosed
+	}
+	if q.wSize > 0 && q.wSize+int64(len(rec)) > int64(q.cfg.SegmentSize) {
+		if err := q.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	if n, err := q.w.Write(rec); err != nil {
+		if n > 0 {
+			// Cut off the torn record, so that it doesn't hide the entries
+			// written after it from the shipper.
+			err = multierr.Append(err, q.truncateLocked())
+		}
+		return 0, err
+	}
+	q.wSize += int64(len(rec))
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// truncateLocked discards anything written to the current segment after
+// wSize. If that fails, it starts a new segment instead, leaving the torn
+// record at the end of the old one, where the shipper skips it.
+func (q *WALQueue) truncateLocked() error {
+	err := q.w.Truncate(q.wSize)
+	if err == nil {
+		_, err = q.w.Seek(q.wSize, io.SeekStart)
+	}
+	if err == nil {
+		return nil
+	}
+	return multierr.Append(err, q.rotateLocked())
+}
+
+func (q *WALQueue) rotateLocked() error {
+	if err := q.w.Sync(); err != nil {
+		return err
+	}
+	if err := q.w.Close(); err != nil {
+		return err
+	}
+	return q.openSegment(q.segments[len(q.segments)-1] + 1)
+}
+
+// Sync flushes the current segment to disk.
+func (q *WALQueue) Sync() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	return q.w.Sync()
+}
+
+// Close stops the shipper after one last attempt to deliver the queued
+// entries, then closes the queue and the downstream. Entries that couldn't
+// be delivered stay on disk for the next WALQueue opened on the directory.
+func (q *WALQueue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.stop)
+	<-q.done
+	q.mu.Lock()
+	err := multierr.Append(q.w.Sync(), q.w.Close())
+	q.mu.Unlock()
+	return multierr.Append(err, q.downstream.Close())
+}
+
+// Stats reports what the shipper has done so far.
+func (q *WALQueue) Stats() WALQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
+
+// ship delivers entries until the queue is closed.
+func (q *WALQueue) ship() {
+	defer close(q.done)
+	for {
+		n, err := q.shipBatch()
+		select {
+		case <-q.stop:
+			// One last attempt to deliver everything.
+			for err == nil && n > 0 {
+				n, err = q.shipBatch()
+			}
+			return
+		default:
+		}
+		switch {
+		case err != nil:
+			select {
+			case <-time.After(q.cfg.RetryInterval):
+			case <-q.stop:
+			}
+		case n == 0:
+			select {
+			case <-q.notify:
+			case <-q.stop:
+			}
+		}
+	}
+}
+
+// shipBatch delivers the next batch of entries after the cursor, returning
+// how many were acknowledged.
+func (q *WALQueue) shipBatch() (int, error) {
+	batch, nextIndex, nextOffset, corrupt, err := q.readBatch()
+	if corrupt > 0 {
+		q.mu.Lock()
+		q.stats.Corrupt += uint64(corrupt)
+		q.mu.Unlock()
+	}
+	if err == nil && len(batch) > 0 {
+		for _, p := range batch {
+			if _, err = q.downstream.Write(p); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = q.downstream.Sync()
+		}
+	}
+	if err != nil {
+		q.mu.Lock()
+		q.stats.Failures++
+		q.stats.LastError = err
+		q.mu.Unlock()
+		return 0, err
+	}
+	if nextIndex == q.cursorIndex && nextOffset == q.cursorOffset {
+		return 0, nil
+	}
+	q.cursorIndex, q.cursorOffset = nextIndex, nextOffset
+	if err := q.writeCursor(); err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	q.stats.Shipped += uint64(len(batch))
+	var done []uint64
+	for len(q.segments) > 1 && q.segments[0] < q.cursorIndex {
+		done = append(done, q.segments[0])
+		q.segments = q.segments[1:]
+	}
+	q.mu.Unlock()
+	for _, idx := range done {
+		os.Remove(q.segmentPath(idx))
+	}
+	return len(batch), nil
+}
+
+// readBatch reads up to BatchSize entries after the cursor, returning them
+// along with the position after the last one and the number of damaged
+// entries skipped.
+func (q *WALQueue) readBatch() (batch [][]byte, index uint64, offset int64, corrupt int, err error) {
+	index, offset = q.cursorIndex, q.cursorOffset
+	for len(batch) < q.cfg.BatchSize {
+		q.mu.Lock()
+		limit, next := q.wSize, uint64(0)
+		if last := q.segments[len(q.segments)-1]; index != last {
+			limit = -1 // a finished segment; read to its end
+			for _, idx := range q.segments {
+				if idx > index {
+					next = idx
+					break
+				}
+			}
+		}
+		q.mu.Unlock()
+
+		var entries [][]byte
+		var end int64
+		var bad bool
+		entries, end, bad, err = readSegment(q.segmentPath(index), offset, limit, q.cfg.BatchSize-len(batch))
+		if err != nil {
+			return nil, q.cursorIndex, q.cursorOffset, corrupt, err
+		}
+		batch = append(batch, entries...)
+		offset = end
+		if bad {
+			corrupt++
+		}
+		if len(batch) == q.cfg.BatchSize {
+			break
+		}
+		if next == 0 {
+			// Caught up with the segment being written.
+			break
+		}
+		index, offset = next, 0
+	}
+	return batch, index, offset, corrupt, nil
+}
+
+// readSegment reads up to max entries from the segment at path, starting
+// at offset and stopping at limit (or the end of the file if limit is
+// negative). If it finds a damaged entry, it skips the rest of the segment
+// and reports it.
+func readSegment(path string, offset, limit int64, max int) (entries [][]byte, end int64, bad bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, offset, true, nil
+		}
+		return nil, offset, false, err
+	}
+	defer f.Close()
+	if limit < 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, offset, false, err
+		}
+		limit = info.Size()
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, false, err
+	}
+
+	end = offset
+	var header [_walRecordHeader]byte
+	for len(entries) < max && end < limit {
+		if limit-end < _walRecordHeader {
+			return entries, limit, true, nil
+		}
+		if _, err := io.ReadFull(f, header[:]); err != nil {
+			return entries, end, false, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:]))
+		if size > _walMaxRecord || end+_walRecordHeader+size > limit {
+			return entries, limit, true, nil
+		}
+		p := make([]byte, size)
+		if _, err := io.ReadFull(f, p); err != nil {
+			return entries, end, false, err
+		}
+		if crc32.Checksum(p, _walCRCTable) != binary.BigEndian.Uint32(header[4:]) {
+			return entries, limit, true, nil
+		}
+		entries = append(entries, p)
+		end += _walRecordHeader + size
+	}
+	return entries, end, false, nil
+}
+
+// validEnd returns the offset just past the last intact entry in f.
+func validEnd(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var end int64
+	for {
+		entries, next, bad, err := readSegment(f.Name(), end, info.Size(), 1)
+		if err != nil || bad || len(entries) == 0 {
+			return end, err
+		}
+		end = next
+	}
+}
+
+// readCursor loads the cursor, which is missing for a new queue.
+func (q *WALQueue) readCursor() error {
+	data, err := ioutil.ReadFile(filepath.Join(q.cfg.Dir, _walCursorFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Sscanf(string(data), "%x %d", &q.cursorIndex, &q.cursorOffset); err != nil {
+		return fmt.Errorf("walqueue: invalid cursor %q: %v", data, err)
+	}
+	return nil
+}
+
+// writeCursor saves the cursor, replacing the old one atomically.
+func (q *WALQueue) writeCursor() error {
+	path := filepath.Join(q.cfg.Dir, _walCursorFile)
+	tmp := path + ".tmp"
+	data := fmt.Sprintf("%016x %d\n", q.cursorIndex, q.cursorOffset)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(data)
+	err = multierr.Combine(err, f.Sync(), f.Close())
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// newWALQueueSink opens a WALQueue from a "walqueue" URL.
+func newWALQueueSink(u *url.URL) (Sink, error) {
+	if u.Host != "" {
+		return nil, fmt.Errorf("walqueue URLs must leave host empty: got %v", u)
+	}
+	cfg := WALQueueConfig{Dir: filePath(u)}
+	var downstream string
+	for key, vals := range u.Query() {
+		val := vals[len(vals)-1]
+		switch key {
+		case "downstream":
+			downstream = val
+		case "segmentSize":
+			n, err := parseByteSize(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid segmentSize %q in walqueue URL: %v", val, err)
+			}
+			cfg.SegmentSize = n
+		case "retryInterval":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid retryInterval %q in walqueue URL: must be a positive duration", val)
+			}
+			cfg.RetryInterval = d
+		case "batchSize":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid batchSize %q in walqueue URL: must be a positive integer", val)
+			}
+			cfg.BatchSize = n
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in walqueue URL: got %v", key, u)
+		}
+	}
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("walqueue URLs must include a directory: got %v", u)
+	}
+	if downstream == "" {
+		return nil, fmt.Errorf("walqueue URLs must include a downstream parameter: got %v", u)
+	}
+	sink, err := newSink(downstream)
+	if err != nil {
+		return nil, fmt.Errorf("can't open walqueue downstream: %v", err)
+	}
+	q, err := NewWALQueue(cfg, sink)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink records what's written to it, and fails while failing is set.
+type memorySink struct {
+	mu      sync.Mutex
+	failing bool
+	pending []string
+	acked   []string
+	closed  bool
+}
+
+func (s *memorySink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return 0, errors.New("downstream unavailable")
+	}
+	s.pending = append(s.pending, string(p))
+	return len(p), nil
+}
+
+func (s *memorySink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		s.pending = nil
+		return errors.New("downstream unavailable")
+	}
+	s.acked = append(s.acked, s.pending...)
+	s.pending = nil
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *memorySink) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *memorySink) Acked() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.acked...)
+}
+
+func waitForAcked(t testing.TB, s *memorySink, n int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if acked := s.Acked(); len(acked) >= n {
+			return acked
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d entries to be delivered, got %v.", n, s.Acked())
+	return nil
+}
+
+func walSegments(t testing.TB, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+_walSegmentSuffix))
+	require.NoError(t, err, "Failed to list segments.")
+	return matches
+}
+
+func TestWALQueueDelivers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	down := &memorySink{}
+	q, err := NewWALQueue(WALQueueConfig{Dir: dir}, down)
+	require.NoError(t, err, "Failed to open queue.")
+
+	for _, msg := range []string{"one", "two", "three"} {
+		_, err := q.Write([]byte(msg))
+		require.NoError(t, err, "Unexpected error writing to queue.")
+	}
+	require.NoError(t, q.Sync(), "Unexpected error syncing queue.")
+	assert.Equal(t, []string{"one", "two", "three"}, waitForAcked(t, down, 3), "Unexpected entries delivered.")
+	assert.Equal(t, uint64(3), q.Stats().Shipped, "Unexpected shipped count.")
+
+	require.NoError(t, q.Close(), "Unexpected error closing queue.")
+	assert.True(t, down.closed, "Expected closing the queue to close the downstream.")
+	_, err = q.Write([]byte("four"))
+	assert.Equal(t, errWALQueueClosed, err, "Expected writes after Close to fail.")
+}
+
+func TestWALQueueRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	down := &memorySink{failing: true}
+	q, err := NewWALQueue(WALQueueConfig{Dir: dir, RetryInterval: time.Millisecond}, down)
+	require.NoError(t, err, "Failed to open queue.")
+	defer q.Close()
+
+	q.Write([]byte("one"))
+	q.Write([]byte("two"))
+	for q.Stats().Failures < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	stats := q.Stats()
+	assert.Equal(t, uint64(0), stats.Shipped, "Expected nothing to be shipped while downstream fails.")
+	assert.EqualError(t, stats.LastError, "downstream unavailable", "Unexpected last error.")
+
+	down.setFailing(false)
+	assert.Equal(t, []string{"one", "two"}, waitForAcked(t, down, 2), "Expected entries to be delivered once downstream recovers.")
+}
+
+func TestWALQueueSurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	first := &memorySink{}
+	q, err := NewWALQueue(WALQueueConfig{Dir: dir}, first)
+	require.NoError(t, err, "Failed to open queue.")
+	q.Write([]byte("delivered"))
+	waitForAcked(t, first, 1)
+
+	first.setFailing(true)
+	q.Write([]byte("queued 1"))
+	q.Write([]byte("queued 2"))
+	require.NoError(t, q.Close(), "Unexpected error closing queue.")
+
+	second := &memorySink{}
+	q, err = NewWALQueue(WALQueueConfig{Dir: dir}, second)
+	require.NoError(t, err, "Failed to reopen queue.")
+	defer q.Close()
+	assert.Equal(t, []string{"queued 1", "queued 2"}, waitForAcked(t, second, 2), "Expected undelivered entries to survive a restart.")
+
+	q.Write([]byte("after restart"))
+	assert.Equal(t, "after restart", waitForAcked(t, second, 3)[2], "Expected new entries to follow recovered ones.")
+}
+
+func TestWALQueueSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	down := &memorySink{failing: true}
+	q, err := NewWALQueue(WALQueueConfig{Dir: dir, SegmentSize: 64, RetryInterval: time.Millisecond}, down)
+	require.NoError(t, err, "Failed to open queue.")
+	defer q.Close()
+
+	var want []string
+	for i := 0; i < 10; i++ {
+		msg := strings.Repeat(string('a'+rune(i)), 20)
+		want = append(want, msg)
+		q.Write([]byte(msg))
+	}
+	assert.Len(t, walSegments(t, dir), 5, "Expected the queue to rotate segments at SegmentSize.")
+
+	down.setFailing(false)
+	assert.Equal(t, want, waitForAcked(t, down, 10), "Unexpected entries delivered across segments.")
+	require.NoError(t, q.Close(), "Unexpected error closing queue.")
+	assert.Len(t, walSegments(t, dir), 1, "Expected delivered segments to be deleted.")
+}
+
+func TestWALQueueTruncatesTornEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	down := &memorySink{failing: true}
+	q, err := NewWALQueue(WALQueueConfig{Dir: dir}, down)
+	require.NoError(t, err, "Failed to open queue.")
+	q.Write([]byte("intact"))
+	require.NoError(t, q.Close(), "Unexpected error closing queue.")
+
+	// Simulate a crash partway through writing an entry.
+	segments := walSegments(t, dir)
+	require.Len(t, segments, 1, "Expected one segment.")
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err, "Failed to open segment.")
+	f.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, 'p', 'a', 'r'})
+	f.Close()
+
+	down = &memorySink{}
+	q, err = NewWALQueue(WALQueueConfig{Dir: dir}, down)
+	require.NoError(t, err, "Failed to reopen queue.")
+	defer q.Close()
+	q.Write([]byte("next"))
+	assert.Equal(t, []string{"intact", "next"}, waitForAcked(t, down, 2), "Expected the torn entry to be discarded.")
+	assert.Equal(t, uint64(0), q.Stats().Corrupt, "Expected no corrupt entries after truncating the torn one.")
+}
+
+// tornFile writes only half of the record after fail is set, then fails.
+type tornFile struct {
+	walFile
+	fail bool
+}
+
+func (f *tornFile) Write(p []byte) (int, error) {
+	if !f.fail {
+		return f.walFile.Write(p)
+	}
+	f.fail = false
+	n, _ := f.walFile.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func TestWALQueueDiscardsPartialWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	down := &memorySink{failing: true}
+	q, err := NewWALQueue(WALQueueConfig{Dir: dir}, down)
+	require.NoError(t, err, "Failed to open queue.")
+	defer q.Close()
+
+	q.Write([]byte("before"))
+	q.mu.Lock()
+	q.w = &tornFile{walFile: q.w, fail: true}
+	q.mu.Unlock()
+	_, err = q.Write([]byte("torn"))
+	assert.Error(t, err, "Expected the partial write to fail.")
+	_, err = q.Write([]byte("after"))
+	assert.NoError(t, err, "Unexpected error writing after a partial write.")
+
+	down.setFailing(false)
+	assert.Equal(t, []string{"before", "after"}, waitForAcked(t, down, 2), "Expected the torn entry to be discarded.")
+	assert.Equal(t, uint64(0), q.Stats().Corrupt, "Expected no corrupt entries.")
+}
+
+func TestWALQueueSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walqueue")
+	require.NoError(t, err, "Failed to create temporary directory.")
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.log")
+	downstream := "file://" + filepath.ToSlash(out)
+	sink, err := newSink("walqueue://" + filepath.ToSlash(filepath.Join(dir, "spool")) +
+		"?segmentSize=1MiB&retryInterval=10ms&batchSize=16&downstream=" + url.QueryEscape(downstream))
+	require.NoError(t, err, "Failed to open walqueue sink.")
+	q, ok := sink.(*WALQueue)
+	require.True(t, ok, "Expected a *WALQueue, got %T.", sink)
+	assert.Equal(t, WALQueueConfig{
+		Dir:           filepath.Join(dir, "spool"),
+		SegmentSize:   1 << 20,
+		RetryInterval: 10 * time.Millisecond,
+		BatchSize:     16,
+	}, q.cfg, "Unexpected queue config.")
+
+	q.Write([]byte("hello\n"))
+	require.NoError(t, q.Close(), "Unexpected error closing queue.")
+	contents, err := ioutil.ReadFile(out)
+	require.NoError(t, err, "Failed to read downstream file.")
+	assert.Equal(t, "hello\n", string(contents), "Unexpected downstream contents.")
+}
+
+func TestWALQueueSinkErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"walqueue://host/spool?downstream=stderr", "must leave host empty"},
+		{"walqueue:///tmp/spool", "must include a downstream parameter"},
+		{"walqueue:///tmp/spool?downstream=stderr&color=blue", "unknown query parameter"},
+		{"walqueue:///tmp/spool?downstream=stderr&segmentSize=big", "invalid segmentSize"},
+		{"walqueue:///tmp/spool?downstream=stderr&retryInterval=-1s", "invalid retryInterval"},
+		{"walqueue:///tmp/spool?downstream=stderr&batchSize=0", "invalid batchSize"},
+		{"walqueue:///tmp/spool?downstream=nope%3A%2F%2Fx", "can't open walqueue downstream"},
+	}
+	for _, tt := range tests {
+		_, err := newSink(tt.url)
+		if assert.Error(t, err, "Expected an error for URL %q.", tt.url) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error for URL %q.", tt.url)
+		}
+	}
+}
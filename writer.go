@@ -36,18 +36,93 @@ import (
 // any opened files.
 //
 // Passing no URLs returns a no-op WriteSyncer. Zap handles URLs without a
-// scheme and URLs with the "file" scheme. Third-party code may register
-// factories for other schemes using RegisterSink.
+// scheme and URLs with the "file", "journald", "walqueue", "tcp", "tcps",
+// "http", "https", "loki", and "lokis" schemes. Third-party code may register factories for other schemes using
+// RegisterSink.
 //
-// URLs with the "file" scheme must use absolute paths on the local
-// filesystem. No user, password, port, fragments, or query parameters are
-// allowed, and the hostname must be empty or "localhost".
+// URLs with the "file" scheme use absolute paths on the local filesystem,
+// or relative paths if written without slashes after the scheme, as in
+// "file:logs/app.log". On Windows, "file:///C:/logs/app.log" refers to a
+// file on drive C. No user, password, port, or fragments are allowed, and
+// the hostname must be empty or "localhost". The following query parameters
+// tune how the file is opened and written:
+//
+//   - mode: the octal permissions of a newly created file (default 0644)
+//   - flag: "append" (the default) or "trunc" to truncate an existing file
+//   - bufferSize: buffer writes in memory, flushing when this many bytes
+//     (e.g., "256KiB") are pending
+//   - flushInterval: buffer writes in memory, flushing at least this often
+//     (e.g., "5s")
+//   - compress: "gzip", or "zstd" once the zapzstd package is registered, to
+//     compress the file as it's written; see
+//     zapcore.NewCompressingWriteSyncer
+//   - nosync: if true, Sync does nothing
+//   - expandHome: if true, a leading "~" in the path is replaced by the
+//     current user's home directory
+//
+// For example, "file:///var/log/app.log?mode=0600&bufferSize=256KiB". When
+// only one of bufferSize and flushInterval is set, the other uses the
+// defaults of zapcore.BufferedWriteSyncer. Closing a buffered sink flushes it.
+//
+// URLs with the "journald" scheme send each entry to systemd-journald, at
+// the path of its socket or /run/systemd/journal/socket by default (e.g.,
+// "journald://?identifier=myapp"). The "identifier" query parameter sets
+// SYSLOG_IDENTIFIER. Pair them with the "journald" encoding to keep fields
+// structured; see NewJournaldEncoder.
+//
+// URLs with the "walqueue" scheme queue entries in a directory on the local
+// filesystem and forward them, with at-least-once delivery, to the sink
+// named by the (URL-encoded) "downstream" query parameter, as in
+// "walqueue:///var/spool/app-logs?segmentSize=64MB&downstream=...". See
+// WALQueue.
+//
+// URLs with the "tcp" and "tcps" schemes write entries to a TCP connection
+// to host:port, in plaintext or over TLS. The connection is opened on the
+// first write and reopened if it fails. The "writeTimeout" query parameter
+// bounds each write (default 10s), "dialTimeout" bounds connecting and the
+// TLS handshake (default 10s), and tcps URLs accept TLS settings for mutual
+// authentication: "ca" (or "caFile"), "cert" (or "certFile"), "key" (or
+// "keyFile"), "serverName", and "insecureSkipVerify", as in
+// "tcps://logs:6514?ca=/etc/ssl/ca.pem&cert=/etc/ssl/app.pem&key=/etc/ssl/app-key.pem".
+// Both also accept "proxy"; see TransportConfig.
+//
+// URLs with the "http" and "https" schemes POST batches of entries to the
+// URL, without its query, as newline-delimited JSON, or with "format=array",
+// as a JSON array. This suits webhooks and collectors like Splunk's HTTP
+// Event Collector. Batches are sent when "batchSize" entries (default 100)
+// are buffered, every "flushInterval" (default 1s; 0s disables it), and on
+// Sync. Network errors, throttling, and server errors are retried up to
+// "maxRetries" times (default 3), waiting "backoff" (default 100ms) and
+// doubling it each time; batches rejected with other errors are dropped.
+// The remaining parameters are:
+//
+//   - timeout: the time limit for each request (default 10s)
+//   - compress: "gzip" to compress request bodies
+//   - authorization: the value of the Authorization header
+//   - authorizationEnv: the name of an environment variable holding the
+//     value of the Authorization header, to keep secrets out of the URL
+//   - header: an extra header, as "Name:value"; may be repeated
+//
+// User info in the URL is sent as basic authentication. https URLs also
+// accept the TLS and proxy parameters of TransportConfig, and http URLs
+// accept its proxy and dial parameters.
+//
+// URLs with the "loki" and "lokis" schemes push entries to Grafana Loki
+// over HTTP or HTTPS, at the URL's path or /loki/api/v1/push by default;
+// paths ending in /api/prom/push use the legacy API's payload instead.
+// Top-level keys of JSON entries named by the comma-separated "labels"
+// parameter (default "level") become stream labels, and the rest of the
+// entry becomes the log line. "staticLabels" adds labels to every stream,
+// as in "staticLabels=job:api,env:prod", and "tenant" sets the
+// X-Scope-OrgID header for multi-tenant installations. All the parameters
+// of http and https URLs are accepted too, except "format".
 //
 // Since it's common to write logs to the local filesystem, URLs without a
 // scheme (e.g., "/var/log/foo.log") are treated as local file paths. Without
 // a scheme, the special paths "stdout" and "stderr" are interpreted as
 // os.Stdout and os.Stderr. When specified without a scheme, relative file
-// paths also work.
+// paths also work, and on Windows, so do paths starting with a drive letter
+// (e.g., "C:\logs\app.log"); query parameters may follow either.
 func Open(paths ...string) (zapcore.WriteSyncer, func(), error) {
 
 	//
@@ -58,19 +133,21 @@ func Open(paths ...string) (zapcore.WriteSyncer, func(), error) {
 
 	writer := CombineWriteSyncers(writers...)
 
-	return writer, close, nil
+	return writer, func() { close() }, nil
 
 }
 
-func open(paths []string) ([]zapcore.WriteSyncer, func(), error) {
+func open(paths []string) ([]zapcore.WriteSyncer, func() error, error) {
 
 	writers := make([]zapcore.WriteSyncer, 0, len(paths))
 	closers := make([]io.Closer, 0, len(paths))
 
-	close := func() {
+	close := func() error {
+		var err error
 		for _, c := range closers {
-			c.Close()
+			err = multierr.Append(err, c.Close())
 		}
+		return err
 	}
 
 	var openErr error
@@ -77,7 +77,15 @@ func TestOpen(t *testing.T) {
 		{[]string{"file://host01.test.com" + tempName}, []string{"empty or use localhost"}},
 		{[]string{"file://rms@localhost" + tempName}, []string{"user and password not allowed"}},
 		{[]string{"file://localhost" + tempName + "#foo"}, []string{"fragments not allowed"}},
-		{[]string{"file://localhost" + tempName + "?foo=bar"}, []string{"query parameters not allowed"}},
+		{[]string{"file://localhost" + tempName + "?foo=bar"}, []string{`unknown query parameter "foo"`}},
+		{[]string{"file://localhost" + tempName + "?nosync=sometimes"}, []string{"invalid nosync"}},
+		{[]string{"file://localhost" + tempName + "?mode=rw"}, []string{"invalid mode"}},
+		{[]string{"file://localhost" + tempName + "?mode=01777"}, []string{"invalid mode"}},
+		{[]string{"file://localhost" + tempName + "?flag=excl"}, []string{"invalid flag"}},
+		{[]string{"file://localhost" + tempName + "?bufferSize=lots"}, []string{"invalid bufferSize"}},
+		{[]string{"file://localhost" + tempName + "?flushInterval=-1s"}, []string{"invalid flushInterval"}},
+		{[]string{"stdout?mode=0600"}, []string{"mode not allowed with stdout"}},
+		{[]string{"stderr?flag=trunc"}, []string{"flag not allowed with stderr"}},
 		{[]string{"file://localhost:8080" + tempName}, []string{"ports not allowed"}},
 	}
 
@@ -0,0 +1,151 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapaudit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// _maxRecordBytes is the longest record Verify can read.
+const _maxRecordBytes = 1 << 20
+
+var (
+	// ErrSequenceGap is returned by Verify when records are missing or out
+	// of order.
+	ErrSequenceGap = errors.New("audit trail sequence is broken")
+	// ErrTampered is returned by Verify when a record's HMAC doesn't match
+	// its contents and the records before it.
+	ErrTampered = errors.New("audit trail record doesn't match its HMAC")
+)
+
+// A VerifyError is returned by Verify when an audit trail fails to verify.
+type VerifyError struct {
+	// Line is the line of the offending record, counting from 1.
+	Line int
+	// Err is ErrSequenceGap or ErrTampered, or the error parsing the record.
+	Err error
+	// Detail optionally describes the problem further.
+	Detail string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v: %s", e.Line, e.Err, e.Detail)
+}
+
+// Unwrap returns Err, so that errors.Is can match ErrSequenceGap and
+// ErrTampered.
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify reads an audit trail written by a Core, one record per line, and
+// checks that its sequence numbers run consecutively from 1. If key isn't
+// nil, it also checks each record's HMAC, as added by WithHMAC(key).
+//
+// It returns the last sequence number it read, which callers can compare
+// with their own records to detect a truncated trail. Verification failures
+// are reported as a *VerifyError, which identifies the offending line.
+func Verify(r io.Reader, key []byte) (last uint64, err error) {
+	t := &trail{key: key}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, _maxRecordBytes)
+	for line := 1; scanner.Scan(); line++ {
+		record := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(record) == 0 {
+			continue
+		}
+		if key != nil {
+			if detail := t.verify(record); detail != "" {
+				return last, &VerifyError{Line: line, Err: ErrTampered, Detail: detail}
+			}
+		}
+		seq, err := sequence(record)
+		if err != nil {
+			return last, &VerifyError{Line: line, Err: err}
+		}
+		if seq != last+1 {
+			return last, &VerifyError{
+				Line:   line,
+				Err:    ErrSequenceGap,
+				Detail: fmt.Sprintf("expected record %d, found %d", last+1, seq),
+			}
+		}
+		last = seq
+	}
+	return last, scanner.Err()
+}
+
+// verify checks a record's HMAC against the chain so far, then extends the
+// chain. If the record doesn't verify, it returns a description of why.
+func (t *trail) verify(record []byte) string {
+	marker := []byte(`,"` + HMACKey + `":"`)
+	start := bytes.LastIndex(record, marker)
+	if start < 0 {
+		return "record has no HMAC"
+	}
+	value := record[start+len(marker):]
+	end := bytes.IndexByte(value, '"')
+	if end < 0 {
+		return "record has no HMAC"
+	}
+	want, err := hex.DecodeString(string(value[:end]))
+	if err != nil {
+		return fmt.Sprintf("malformed HMAC %q", value[:end])
+	}
+	got := t.sum(record[:start])
+	if !hmac.Equal(got, want) {
+		return "HMAC mismatch"
+	}
+	t.prev = got
+	return ""
+}
+
+// sequence extracts a record's sequence number. Any prefix before the JSON
+// object, such as the encoder's RecordPrefix, is ignored.
+func sequence(record []byte) (uint64, error) {
+	if i := bytes.IndexByte(record, '{'); i > 0 {
+		record = record[i:]
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(record, &fields); err != nil {
+		return 0, fmt.Errorf("can't parse record: %v", err)
+	}
+	raw, ok := fields[SequenceKey]
+	if !ok {
+		return 0, fmt.Errorf("record has no %q field", SequenceKey)
+	}
+	seq, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed sequence number %s", raw)
+	}
+	return seq, nil
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapaudit provides a Core for audit trails, which mustn't lose or
+// silently alter records.
+//
+// Unlike an ordinary Core, an audit Core syncs its WriteSyncer after every
+// record, numbers its records with a sequence number that starts at 1, and
+// isn't subject to sampling: samplers offer it the entries they drop (see
+// zapcore.UnsampledCore). With WithHMAC, each record also carries an HMAC
+// of its contents chained to the previous record's, so removing, reordering,
+// or editing records can be detected with Verify.
+//
+// Tee the Core with the Logger's usual one, directly under any sampler:
+//
+//	cfg := zap.NewProductionConfig()
+//	logger, err := cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+//		return zapcore.NewTee(core, zapaudit.NewCore(auditFile, zapaudit.WithHMAC(key)))
+//	}))
+package zapaudit // import "github.com/blastbao/zap/zapaudit"
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/internal/bufferpool"
+	"github.com/blastbao/zap/zapcore"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	// SequenceKey is the key of each record's sequence number.
+	SequenceKey = "seq"
+	// HMACKey is the key of each record's HMAC, if WithHMAC is used.
+	HMACKey = "hmac"
+)
+
+var errNotJSON = errors.New("zapaudit: HMAC chaining requires records that are JSON objects")
+
+// An Option configures a Core.
+type Option interface {
+	apply(*core)
+}
+
+type optionFunc func(*core)
+
+func (f optionFunc) apply(c *core) {
+	f(c)
+}
+
+// WithLevel sets the levels that are recorded. By default, all levels are.
+func WithLevel(enab zapcore.LevelEnabler) Option {
+	return optionFunc(func(c *core) {
+		c.LevelEnabler = enab
+	})
+}
+
+// WithEncoder sets the Encoder used for records. By default, records are
+// encoded as JSON with zap.NewProductionEncoderConfig. Chaining with
+// WithHMAC requires an encoder that writes each record as a JSON object
+// without a checksum (see zapcore.EncoderConfig.ChecksumKey).
+func WithEncoder(enc zapcore.Encoder) Option {
+	return optionFunc(func(c *core) {
+		c.enc = enc
+	})
+}
+
+// WithHMAC adds an HMAC-SHA256 of each record, keyed with key, as the
+// record's last field. Each HMAC covers the previous record's HMAC as well
+// as the record itself, chaining the records together.
+func WithHMAC(key []byte) Option {
+	return optionFunc(func(c *core) {
+		c.trail.key = append([]byte(nil), key...)
+	})
+}
+
+// NewCore builds a Core that writes an audit trail to ws.
+func NewCore(ws zapcore.WriteSyncer, opts ...Option) zapcore.Core {
+	c := &core{
+		LevelEnabler: zapcore.DebugLevel,
+		trail:        &trail{out: ws},
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	if c.enc == nil {
+		c.enc = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+	return c
+}
+
+type core struct {
+	zapcore.LevelEnabler
+	enc   zapcore.Encoder
+	trail *trail
+}
+
+// trail is the state shared by a Core and the Cores derived from it with
+// With, so that their records form a single sequence.
+type trail struct {
+	mu   sync.Mutex
+	out  zapcore.WriteSyncer
+	seq  uint64
+	key  []byte
+	prev []byte
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// CheckUnsampled records entries that a sampler dropped.
+func (c *core) CheckUnsampled(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.Check(ent, ce)
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	t := c.trail
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seq := zapcore.Field{Key: SequenceKey, Type: zapcore.Uint64Type, Integer: int64(t.seq + 1)}
+	buf, err := c.enc.EncodeEntry(ent, append(fields[:len(fields):len(fields)], seq))
+	if buf == nil {
+		return err
+	}
+	defer buf.Free()
+	// Once the sequence number is used, it's never reused, so records that
+	// fail to write show up as gaps.
+	t.seq++
+
+	record := buf.Bytes()
+	if t.key != nil {
+		end := bytes.LastIndexByte(record, '}')
+		if end < 0 {
+			return multierr.Append(err, errNotJSON)
+		}
+		t.prev = t.sum(record[:end])
+
+		chained := bufferpool.Get()
+		defer chained.Free()
+		chained.Write(record[:end])
+		chained.AppendString(`,"` + HMACKey + `":"`)
+		chained.AppendString(hex.EncodeToString(t.prev))
+		chained.AppendByte('"')
+		chained.Write(record[end:])
+		record = chained.Bytes()
+	}
+
+	if _, werr := t.out.Write(record); werr != nil {
+		return multierr.Append(err, werr)
+	}
+	return multierr.Append(err, t.out.Sync())
+}
+
+func (c *core) Sync() error {
+	c.trail.mu.Lock()
+	defer c.trail.mu.Unlock()
+	return c.trail.out.Sync()
+}
+
+// sum computes the HMAC of a record, less its HMAC field and closing brace,
+// chained to the previous record's.
+func (t *trail) sum(record []byte) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write(t.prev)
+	mac.Write(record)
+	return mac.Sum(nil)
+}
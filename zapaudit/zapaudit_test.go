@@ -0,0 +1,172 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapaudit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/buffer"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncCounter records what's written to it and counts calls to Sync.
+type syncCounter struct {
+	bytes.Buffer
+	syncs int
+}
+
+func (s *syncCounter) Sync() error {
+	s.syncs++
+	return nil
+}
+
+func writeRecords(core zapcore.Core, msgs ...string) {
+	for _, msg := range msgs {
+		ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: msg, Time: time.Unix(0, 0)}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(zap.String("user", "alice"))
+		}
+	}
+}
+
+func TestCoreSequenceAndSync(t *testing.T) {
+	out := &syncCounter{}
+	core := NewCore(out, WithLevel(zapcore.InfoLevel)).With([]zapcore.Field{zap.String("app", "billing")})
+
+	writeRecords(core, "login", "transfer")
+	writeRecords(core.With([]zapcore.Field{zap.Int("attempt", 2)}), "logout")
+	assert.False(t, core.Enabled(zapcore.DebugLevel), "Expected WithLevel to be honored.")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, 3, len(lines), "Unexpected number of records.")
+	assert.Contains(t, lines[0], `"msg":"login","app":"billing","user":"alice","seq":1}`, "Unexpected first record.")
+	assert.Contains(t, lines[2], `"attempt":2,"user":"alice","seq":3}`, "Expected derived cores to share the sequence.")
+	assert.Equal(t, 3, out.syncs, "Expected every record to be synced.")
+
+	last, err := Verify(strings.NewReader(out.String()), nil)
+	assert.NoError(t, err, "Unexpected error verifying trail.")
+	assert.Equal(t, uint64(3), last, "Unexpected last sequence number.")
+}
+
+func TestCoreBypassesSampling(t *testing.T) {
+	out := &syncCounter{}
+	sampled, logs := observer.New(zapcore.DebugLevel)
+	core := zapcore.NewSampler(zapcore.NewTee(sampled, NewCore(out)), time.Minute, 1, 100)
+
+	writeRecords(core, "login", "login", "login")
+	assert.Equal(t, 1, logs.Len(), "Expected the ordinary core to be sampled.")
+	last, err := Verify(strings.NewReader(out.String()), nil)
+	assert.NoError(t, err, "Unexpected error verifying trail.")
+	assert.Equal(t, uint64(3), last, "Expected every entry in the audit trail.")
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	key := []byte("secret")
+	out := &syncCounter{}
+	writeRecords(NewCore(out, WithHMAC(key)), "login", "transfer", "logout")
+	trail := out.String()
+	lines := strings.SplitAfter(trail, "\n")
+
+	last, err := Verify(strings.NewReader(trail), key)
+	require.NoError(t, err, "Unexpected error verifying trail.")
+	assert.Equal(t, uint64(3), last, "Unexpected last sequence number.")
+	assert.Contains(t, lines[0], `"seq":1,"hmac":"`, "Expected the HMAC to be the last field.")
+
+	tests := []struct {
+		desc  string
+		trail string
+		key   []byte
+		err   error
+		line  string
+	}{
+		{
+			desc:  "wrong key",
+			trail: trail,
+			key:   []byte("guess"),
+			err:   ErrTampered,
+			line:  "line 1",
+		},
+		{
+			desc:  "edited record",
+			trail: lines[0] + strings.Replace(lines[1], "transfer", "deposit", 1) + lines[2],
+			key:   key,
+			err:   ErrTampered,
+			line:  "line 2",
+		},
+		{
+			desc:  "removed record",
+			trail: lines[0] + lines[2],
+			key:   key,
+			err:   ErrTampered,
+			line:  "line 2",
+		},
+		{
+			desc:  "removed record without key",
+			trail: lines[0] + lines[2],
+			err:   ErrSequenceGap,
+			line:  "line 2",
+		},
+		{
+			desc:  "reordered records",
+			trail: lines[1] + lines[0] + lines[2],
+			key:   key,
+			err:   ErrTampered,
+			line:  "line 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(tt.trail), tt.key)
+			if assert.Error(t, err, "Expected verification to fail.") {
+				verr, ok := err.(*VerifyError)
+				require.True(t, ok, "Expected a *VerifyError, got %T.", err)
+				assert.Equal(t, tt.err, verr.Err, "Unexpected error: %v.", err)
+				assert.Contains(t, err.Error(), tt.line, "Expected the error to identify the line.")
+			}
+		})
+	}
+}
+
+// plainEncoder writes each entry's message on its own line.
+type plainEncoder struct{ zapcore.Encoder }
+
+func (plainEncoder) EncodeEntry(ent zapcore.Entry, _ []zapcore.Field) (*buffer.Buffer, error) {
+	buf := buffer.NewPool().Get()
+	buf.AppendString(ent.Message + "\n")
+	return buf, nil
+}
+
+func TestHMACRequiresJSON(t *testing.T) {
+	out := &syncCounter{}
+	core := NewCore(out, WithEncoder(plainEncoder{}), WithHMAC([]byte("secret")))
+
+	err := core.Write(zapcore.Entry{Message: "login"}, nil)
+	assert.Equal(t, errNotJSON, err, "Expected an error chaining non-JSON records.")
+	assert.Equal(t, 0, out.Len(), "Expected nothing to be written.")
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.13
+// +build go1.13
+
+package zapbench
+
+import (
+	"errors"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+)
+
+// An Option configures a benchmark.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+type options struct {
+	level       zapcore.Level
+	message     string
+	fields      []zap.Field
+	parallelism int
+	keepOutputs bool
+}
+
+func defaultOptions() options {
+	return options{
+		level:   zapcore.InfoLevel,
+		message: "Test logging, but use a somewhat realistic message length.",
+		fields: []zap.Field{
+			zap.String("user", "jane@example.com"),
+			zap.Int("attempt", 3),
+			zap.Duration("elapsed", 1500*time.Millisecond),
+			zap.Time("started", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+			zap.Strings("tags", []string{"api", "billing", "eu-west-1"}),
+			zap.Error(errors.New("connection reset by peer")),
+		},
+	}
+}
+
+// WithLevel sets the level entries are logged at. It defaults to
+// InfoLevel; benchmarking a level the Config doesn't enable measures the
+// cost of a disabled log call.
+func WithLevel(lvl zapcore.Level) Option {
+	return optionFunc(func(o *options) {
+		o.level = lvl
+	})
+}
+
+// WithMessage sets the message of each entry.
+func WithMessage(msg string) Option {
+	return optionFunc(func(o *options) {
+		o.message = msg
+	})
+}
+
+// WithFields replaces the fields added to each entry. By default, entries
+// carry a handful of typical fields: strings, numbers, a duration, a time, a
+// string slice, and an error.
+func WithFields(fields ...zap.Field) Option {
+	return optionFunc(func(o *options) {
+		o.fields = fields
+	})
+}
+
+// WithParallelism logs from p*GOMAXPROCS goroutines at once, to measure
+// contention in the Config's Core and outputs. By default, entries are
+// logged from a single goroutine.
+func WithParallelism(p int) Option {
+	return optionFunc(func(o *options) {
+		o.parallelism = p
+	})
+}
+
+// KeepOutputs makes Logger and BenchmarkLogger write to the outputs the
+// Config names, so that the measurements include the cost of the sinks
+// themselves (say, a file on a slow disk). By default, output is counted and
+// discarded, isolating the cost of zap itself. Result.OutputBytesPerEntry
+// isn't measured when outputs are kept.
+func KeepOutputs() Option {
+	return optionFunc(func(o *options) {
+		o.keepOutputs = true
+	})
+}
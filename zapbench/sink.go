@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.13
+// +build go1.13
+
+package zapbench
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/blastbao/zap"
+
+	"go.uber.org/atomic"
+)
+
+// _scheme is the sink scheme that routes a benchmarked Logger's output to
+// its counter.
+const _scheme = "zapbench"
+
+var (
+	_registerOnce sync.Once
+	_registerErr  error
+
+	_countersMu sync.Mutex
+	_counters   = make(map[string]*counter)
+	_nextID     atomic.Uint64
+)
+
+// counter is a zap.Sink that counts and discards the bytes written to it.
+type counter struct {
+	id string
+	n  atomic.Int64
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+func (c *counter) Sync() error  { return nil }
+func (c *counter) Close() error { return nil }
+
+func (c *counter) reset() {
+	c.n.Store(0)
+}
+
+// newCounter returns a new counter, along with the URL that opens it.
+func newCounter() (*counter, string, error) {
+	_registerOnce.Do(func() {
+		_registerErr = zap.RegisterSink(_scheme, openCounter)
+	})
+	if _registerErr != nil {
+		return nil, "", _registerErr
+	}
+	c := &counter{id: strconv.FormatUint(_nextID.Inc(), 10)}
+	_countersMu.Lock()
+	_counters[c.id] = c
+	_countersMu.Unlock()
+	return c, _scheme + "://" + c.id, nil
+}
+
+func openCounter(u *url.URL) (zap.Sink, error) {
+	_countersMu.Lock()
+	defer _countersMu.Unlock()
+	c, ok := _counters[u.Host]
+	if !ok {
+		return nil, fmt.Errorf("zapbench: no benchmark is running for %v", u)
+	}
+	return c, nil
+}
+
+func releaseCounter(c *counter) {
+	_countersMu.Lock()
+	delete(_counters, c.id)
+	_countersMu.Unlock()
+}
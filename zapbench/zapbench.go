@@ -0,0 +1,289 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.13
+// +build go1.13
+
+// Package zapbench measures how a zap.Config performs, so users can check
+// the cost of their settings (encoders, sampling, core wrappers, outputs,
+// and so on) in their own environment rather than relying on published
+// numbers.
+//
+// Logger and Encoder run a benchmark directly and return a Result, which
+// is handy in a self-test command or at startup:
+//
+//	res, err := zapbench.Logger(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(res)
+//
+// BenchmarkLogger and BenchmarkEncoder do the same from a Go benchmark, so
+// the numbers can be tracked with go test -bench and benchstat:
+//
+//	func BenchmarkProductionLogger(b *testing.B) {
+//		zapbench.BenchmarkLogger(b, newConfig())
+//	}
+//
+// The package builds only with Go 1.13 or later. It needs
+// testing.B.ReportMetric, and older versions of the testing package register
+// their command-line flags as soon as they're imported, which would leak
+// -test.* flags into every binary that links zapbench.
+package zapbench // import "github.com/blastbao/zap/zapbench"
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+)
+
+// Result summarizes a benchmark run.
+type Result struct {
+	// Entries is the number of entries logged or encoded in the final run,
+	// and Elapsed the time they took.
+	Entries int
+	Elapsed time.Duration
+	// EntriesPerSec is the throughput of the run.
+	EntriesPerSec float64
+	// AllocsPerEntry and AllocBytesPerEntry are the number and total size
+	// of the heap allocations made for each entry.
+	AllocsPerEntry     int64
+	AllocBytesPerEntry int64
+	// OutputBytesPerEntry is the average number of bytes written to the
+	// outputs for each entry, or the size of each encoded entry. It's zero
+	// if the outputs were kept (see KeepOutputs).
+	OutputBytesPerEntry float64
+}
+
+func newResult(res testing.BenchmarkResult, outputBytes int64) Result {
+	r := Result{
+		Entries:            res.N,
+		Elapsed:            res.T,
+		AllocsPerEntry:     res.AllocsPerOp(),
+		AllocBytesPerEntry: res.AllocedBytesPerOp(),
+	}
+	if res.T > 0 {
+		r.EntriesPerSec = float64(res.N) / res.T.Seconds()
+	}
+	if res.N > 0 {
+		r.OutputBytesPerEntry = float64(outputBytes) / float64(res.N)
+	}
+	return r
+}
+
+// String formats the Result on one line.
+func (r Result) String() string {
+	return fmt.Sprintf("%d entries in %v: %.0f entries/s, %d allocs/entry, %d B allocated/entry, %.0f B written/entry",
+		r.Entries, r.Elapsed, r.EntriesPerSec, r.AllocsPerEntry, r.AllocBytesPerEntry, r.OutputBytesPerEntry)
+}
+
+// Logger builds a Logger from cfg and measures the cost of logging through
+// it, end to end: level checks, sampling, core wrappers, encoding, and, with
+// KeepOutputs, writing. Like a Go benchmark, it runs for about a second.
+//
+// Every part of cfg applies, so with sampling enabled, most of the identical
+// entries the benchmark logs are dropped, as they would be in production.
+func Logger(cfg zap.Config, opts ...Option) (Result, error) {
+	lb, err := newLoggerBench(cfg, newOptions(opts))
+	if err != nil {
+		return Result{}, err
+	}
+	defer lb.close()
+	res := testing.Benchmark(lb.run)
+	return newResult(res, lb.outputBytes()), nil
+}
+
+// BenchmarkLogger is the Go benchmark equivalent of Logger. Besides the
+// usual metrics, it reports the bytes written per entry as "out-B/op".
+func BenchmarkLogger(b *testing.B, cfg zap.Config, opts ...Option) {
+	lb, err := newLoggerBench(cfg, newOptions(opts))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lb.close()
+	lb.run(b)
+	if lb.counter != nil {
+		b.ReportMetric(float64(lb.outputBytes())/float64(b.N), "out-B/op")
+	}
+}
+
+// Encoder measures the encoder cfg's Encoding and EncoderConfig select,
+// without the rest of the Logger. Result.OutputBytesPerEntry is the size of
+// each encoded entry.
+func Encoder(cfg zap.Config, opts ...Option) (Result, error) {
+	eb, err := newEncoderBench(cfg, newOptions(opts))
+	if err != nil {
+		return Result{}, err
+	}
+	res := testing.Benchmark(eb.run)
+	return newResult(res, int64(eb.size)*int64(res.N)), nil
+}
+
+// BenchmarkEncoder is the Go benchmark equivalent of Encoder. It sets the
+// benchmark's byte count to the size of an encoded entry, so go test
+// reports the encoder's throughput in MB/s.
+func BenchmarkEncoder(b *testing.B, cfg zap.Config, opts ...Option) {
+	eb, err := newEncoderBench(cfg, newOptions(opts))
+	if err != nil {
+		b.Fatal(err)
+	}
+	eb.run(b)
+}
+
+func newOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return o
+}
+
+// loop calls f b.N times, from several goroutines if parallelism is
+// positive.
+func loop(b *testing.B, parallelism int, f func()) {
+	if parallelism <= 0 {
+		for i := 0; i < b.N; i++ {
+			f()
+		}
+		return
+	}
+	b.SetParallelism(parallelism)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f()
+		}
+	})
+}
+
+type loggerBench struct {
+	opts    options
+	log     *zap.Logger
+	counter *counter // nil if the outputs are kept
+}
+
+func newLoggerBench(cfg zap.Config, o options) (*loggerBench, error) {
+	if o.level > zapcore.ErrorLevel {
+		return nil, fmt.Errorf("zapbench: can't benchmark logging at %v level", o.level)
+	}
+	lb := &loggerBench{opts: o}
+	if !o.keepOutputs {
+		c, u, err := newCounter()
+		if err != nil {
+			return nil, err
+		}
+		lb.counter = c
+		cfg.OutputPaths = []string{u}
+		outputs := make([]zap.OutputConfig, len(cfg.Outputs))
+		for i, out := range cfg.Outputs {
+			out.Paths = []string{u}
+			outputs[i] = out
+		}
+		cfg.Outputs = outputs
+	}
+	log, err := cfg.Build()
+	if err != nil {
+		lb.close()
+		return nil, err
+	}
+	lb.log = log
+	return lb, nil
+}
+
+func (lb *loggerBench) run(b *testing.B) {
+	b.ReportAllocs()
+	if lb.counter != nil {
+		lb.counter.reset()
+	}
+	b.ResetTimer()
+	loop(b, lb.opts.parallelism, func() {
+		if ce := lb.log.Check(lb.opts.level, lb.opts.message); ce != nil {
+			ce.Write(lb.opts.fields...)
+		}
+	})
+	// Flush buffered and asynchronous outputs, so their cost is counted.
+	lb.log.Sync()
+	b.StopTimer()
+}
+
+func (lb *loggerBench) outputBytes() int64 {
+	if lb.counter == nil {
+		return 0
+	}
+	return lb.counter.n.Load()
+}
+
+func (lb *loggerBench) close() {
+	if lb.log != nil {
+		lb.log.Shutdown(context.Background())
+	}
+	if lb.counter != nil {
+		releaseCounter(lb.counter)
+	}
+}
+
+type encoderBench struct {
+	opts options
+	enc  zapcore.Encoder
+	ent  zapcore.Entry
+	size int
+}
+
+func newEncoderBench(cfg zap.Config, o options) (*encoderBench, error) {
+	enc, err := zap.NewEncoder(cfg.Encoding, cfg.EncoderConfig)
+	if err != nil {
+		return nil, err
+	}
+	eb := &encoderBench{
+		opts: o,
+		enc:  enc,
+		ent: zapcore.Entry{
+			Level:      o.level,
+			Time:       time.Now(),
+			LoggerName: "zapbench",
+			Message:    o.message,
+		},
+	}
+	if !cfg.DisableCaller {
+		eb.ent.Caller = zapcore.NewEntryCaller(runtime.Caller(0))
+	}
+	buf, err := enc.EncodeEntry(eb.ent, o.fields)
+	if err != nil {
+		return nil, err
+	}
+	eb.size = buf.Len()
+	buf.Free()
+	return eb, nil
+}
+
+func (eb *encoderBench) run(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(eb.size))
+	b.ResetTimer()
+	loop(b, eb.opts.parallelism, func() {
+		if buf, err := eb.enc.EncodeEntry(eb.ent, eb.opts.fields); err == nil {
+			buf.Free()
+		}
+	})
+}
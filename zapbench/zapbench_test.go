@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.13
+// +build go1.13
+
+package zapbench
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	cfg := zap.NewProductionConfig()
+	cfg.Sampling = nil
+	cfg.Outputs = []zap.OutputConfig{{Encoding: "console"}}
+	res, err := Logger(cfg, WithParallelism(2))
+	require.NoError(t, err, "Unexpected error benchmarking logger.")
+	assert.True(t, res.Entries > 0, "Expected entries to be logged.")
+	assert.True(t, res.EntriesPerSec > 0, "Expected a positive throughput.")
+	assert.True(t, res.OutputBytesPerEntry > 200, "Expected every entry to be written to both outputs, got %v bytes per entry.", res.OutputBytesPerEntry)
+	assert.Contains(t, res.String(), "entries/s", "Unexpected summary.")
+	assert.Empty(t, cfg.Outputs[0].Paths, "Expected the caller's Config not to be modified.")
+	assert.Empty(t, _counters, "Expected the counter to be released.")
+}
+
+func TestLoggerDisabledLevel(t *testing.T) {
+	res, err := Logger(zap.NewProductionConfig(), WithLevel(zapcore.DebugLevel))
+	require.NoError(t, err, "Unexpected error benchmarking logger.")
+	assert.Zero(t, res.OutputBytesPerEntry, "Expected disabled entries not to be written.")
+	assert.Zero(t, res.AllocsPerEntry, "Expected disabled entries not to allocate.")
+}
+
+func TestLoggerKeepOutputs(t *testing.T) {
+	temp, err := ioutil.TempFile("", "zapbench")
+	require.NoError(t, err, "Failed to create temp file.")
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	// Sampling keeps the file small.
+	cfg := zap.NewProductionConfig()
+	cfg.OutputPaths = []string{temp.Name()}
+	res, err := Logger(cfg, KeepOutputs(), WithMessage("kept"), WithFields(zap.Int("n", 1)))
+	require.NoError(t, err, "Unexpected error benchmarking logger.")
+	assert.Zero(t, res.OutputBytesPerEntry, "Expected output not to be counted.")
+
+	contents, err := ioutil.ReadFile(temp.Name())
+	require.NoError(t, err, "Failed to read log file.")
+	assert.Contains(t, string(contents), `"msg":"kept","n":1}`, "Expected entries in the configured output.")
+}
+
+func TestEncoder(t *testing.T) {
+	cfg := zap.NewProductionConfig()
+	res, err := Encoder(cfg)
+	require.NoError(t, err, "Unexpected error benchmarking encoder.")
+	assert.True(t, res.EntriesPerSec > 0, "Expected a positive throughput.")
+	assert.True(t, res.OutputBytesPerEntry > 100, "Expected the size of an encoded entry, got %v.", res.OutputBytesPerEntry)
+}
+
+func TestErrors(t *testing.T) {
+	cfg := zap.NewProductionConfig()
+	_, err := Logger(cfg, WithLevel(zapcore.PanicLevel))
+	assert.Error(t, err, "Expected an error benchmarking panics.")
+
+	cfg.Encoding = "bogus"
+	_, err = Logger(cfg)
+	assert.Error(t, err, "Expected an error building the logger.")
+	assert.Empty(t, _counters, "Expected the counter to be released.")
+	_, err = Encoder(cfg)
+	assert.Error(t, err, "Expected an error building the encoder.")
+
+	_, _, err = zap.Open(_scheme + "://missing")
+	assert.Error(t, err, "Expected an error opening an unknown counter.")
+}
+
+func BenchmarkProductionLogger(b *testing.B) {
+	cfg := zap.NewProductionConfig()
+	cfg.Sampling = nil
+	BenchmarkLogger(b, cfg)
+}
+
+func BenchmarkProductionEncoder(b *testing.B) {
+	BenchmarkEncoder(b, zap.NewProductionConfig())
+}
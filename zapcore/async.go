@@ -0,0 +1,588 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/blastbao/zap/buffer"
+
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+)
+
+// _defaultAsyncQueueSize is the number of entries an async Core queues when
+// AsyncConfig.QueueSize is zero.
+const _defaultAsyncQueueSize = 1024
+
+// AsyncConfig configures NewAsyncCore.
+type AsyncConfig struct {
+	// QueueSize is the number of entries that may be waiting to be written
+	// before logging blocks. It defaults to 1024.
+	QueueSize int
+
+	// DropWhenFull drops entries that arrive while the queue is full, rather
+	// than blocking the caller until there's room. Dropped entries are
+	// counted in AsyncStats.
+	DropWhenFull bool
+
+	// ReorderWindow, if positive, holds entries for up to this long so that
+	// entries logged concurrently are written in timestamp order rather than
+	// in the order their goroutines were scheduled. A few milliseconds is
+	// usually enough. Entries are never held longer than about twice the
+	// window, and Sync writes everything that's held.
+	ReorderWindow time.Duration
+
+	// Clock drives the reorder window. It should be the clock the Logger
+	// timestamps entries with, and defaults to DefaultClock.
+	Clock Clock
+
+	// MaxBatch, if greater than one, lets the background goroutine write up
+	// to this many queued entries with a single call. Batches adapt to the
+	// load: an entry that arrives on its own is written straight away, while
+	// under a burst the goroutine takes whatever is waiting and yields once
+	// to let producers add more before writing. Only entries for Cores built
+	// with NewCore are batched. Batches for network connections are written
+	// with net.Buffers, which uses writev where the platform supports it;
+	// other batches are concatenated and written at once.
+	MaxBatch int
+}
+
+// NewAsyncCore wraps a Core so that entries are written by a background
+// goroutine rather than by the goroutine that logs them, taking encoding and
+// I/O off the caller's path.
+//
+// Entries keep their fields until they're written, so values referenced by
+// fields (byte slices, marshalers, and the like) mustn't be modified after
+// they're logged. Entries above ErrorLevel are written synchronously, after
+// everything queued before them, so that they aren't lost when the Logger
+// panics or exits. Errors from background writes are returned by the next
+// call to Sync.
+//
+// The returned Core is an AsyncCore. Close and Stop write the queued entries
+// and stop the background goroutine, after which entries are written
+// synchronously; Logger.Shutdown calls Stop. Logger.Sync flushes the queue
+// through the Flusher interface.
+func NewAsyncCore(core Core, cfg AsyncConfig) Core {
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = _defaultAsyncQueueSize
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = DefaultClock
+	}
+	q := &asyncQueue{
+		cfg:   cfg,
+		items: make(chan asyncItem, size),
+		flush: make(chan chan struct{}),
+		pause: make(chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go q.run(q.stop, q.done)
+	return &asyncCore{Core: core, q: q}
+}
+
+// A Flusher is a Core that holds entries before writing them, such as an
+// async Core. Logger.Sync calls Flush before syncing the Core, and Tees pass
+// the call on to their members.
+type Flusher interface {
+	// Flush writes the entries held so far. If ctx is done first, it gives
+	// up waiting and returns ctx.Err(); the entries are still written.
+	Flush(ctx context.Context) error
+}
+
+// An AsyncCore is a Core that writes entries from a background goroutine.
+// NewAsyncCore returns one.
+type AsyncCore interface {
+	Core
+	Flusher
+	io.Closer
+
+	// Stop writes the queued entries and stops the background goroutine, like
+	// Close. If ctx is done first, the entries that haven't been written yet
+	// are dropped and counted, and Stop returns ctx.Err(). Entries logged
+	// after Stop are written synchronously. It's safe to call Stop more than
+	// once.
+	Stop(ctx context.Context) error
+
+	// Stats reports the state of the queue.
+	Stats() AsyncStats
+}
+
+// AsyncStats reports the state of an AsyncCore's queue.
+type AsyncStats struct {
+	// Queued is the number of entries waiting to be written.
+	Queued int64
+	// Written is the number of queued entries that have been handed to the
+	// wrapped Core.
+	Written uint64
+	// Dropped is the number of entries that were discarded, either because
+	// the queue was full and AsyncConfig.DropWhenFull is set, or because
+	// the context passed to Stop was done before they could be written.
+	Dropped uint64
+}
+
+var _ AsyncCore = (*asyncCore)(nil)
+
+type asyncCore struct {
+	Core
+	q *asyncQueue
+}
+
+// Unwrap returns the wrapped Core.
+func (c *asyncCore) Unwrap() Core {
+	return c.Core
+}
+
+type asyncItem struct {
+	core    Core
+	ent     Entry
+	fields  []Field
+	arrived time.Time
+	seq     uint64
+}
+
+func (c *asyncCore) With(fields []Field) Core {
+	return &asyncCore{Core: c.Core.With(fields), q: c.q}
+}
+
+func (c *asyncCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	// The wrapped Core is checked when the entry is written, so that
+	// stateful Cores like samplers see entries in the order they're written.
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *asyncCore) Write(ent Entry, fields []Field) error {
+	if ent.Level > ErrorLevel {
+		return c.writeNow(ent, fields)
+	}
+
+	c.q.mu.RLock()
+	defer c.q.mu.RUnlock()
+	if c.q.closed {
+		return writeChecked(c.Core, ent, fields)
+	}
+	// Nobody waits for queued entries, so their contexts mustn't bound
+	// writes that happen long after the caller has moved on.
+	ent.Context = nil
+	item := asyncItem{
+		core:   c.Core,
+		ent:    ent,
+		fields: append([]Field(nil), fields...),
+	}
+	c.q.queued.Inc()
+	if !c.q.cfg.DropWhenFull {
+		c.q.items <- item
+		return nil
+	}
+	select {
+	case c.q.items <- item:
+	default:
+		c.q.queued.Dec()
+		c.q.dropped.Inc()
+	}
+	return nil
+}
+
+// writeNow writes an entry synchronously, after everything already queued.
+func (c *asyncCore) writeNow(ent Entry, fields []Field) error {
+	err := c.q.drain()
+	return multierr.Append(err, writeChecked(c.Core, ent, fields))
+}
+
+// Sync writes every queued entry, then syncs the wrapped Core.
+func (c *asyncCore) Sync() error {
+	err := c.q.drain()
+	return multierr.Append(err, c.Core.Sync())
+}
+
+// Flush writes every queued entry, giving up waiting if ctx is done first.
+func (c *asyncCore) Flush(ctx context.Context) error {
+	return c.q.drainContext(ctx)
+}
+
+// Close writes every queued entry and stops the background goroutine. It's
+// safe to call Close more than once.
+func (c *asyncCore) Close() error {
+	return c.Stop(context.Background())
+}
+
+func (c *asyncCore) Stop(ctx context.Context) error {
+	c.q.mu.Lock()
+	closed := c.q.closed
+	c.q.closed = true
+	if !closed {
+		c.q.stopCtx = ctx
+	}
+	c.q.mu.Unlock()
+
+	if !closed {
+		close(c.q.stop)
+	}
+	select {
+	case <-c.q.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	err := c.Sync()
+	if c.q.stopDropped.Load() {
+		err = multierr.Append(err, ctx.Err())
+	}
+	return err
+}
+
+func (c *asyncCore) Stats() AsyncStats {
+	return AsyncStats{
+		Queued:  c.q.queued.Load(),
+		Written: c.q.written.Load(),
+		Dropped: c.q.dropped.Load(),
+	}
+}
+
+// asyncQueue is shared by an async Core and every Core derived from it with
+// With.
+type asyncQueue struct {
+	cfg AsyncConfig
+
+	// mu guards closed and stopCtx, and is held for reading while sending on
+	// items so that Stop doesn't race with senders.
+	mu      sync.RWMutex
+	closed  bool
+	stopCtx context.Context
+
+	queued      atomic.Int64
+	written     atomic.Uint64
+	dropped     atomic.Uint64
+	stopDropped atomic.Bool
+
+	items chan asyncItem
+	flush chan chan struct{}
+	pause chan struct{} // stops the goroutine without closing the queue, for forks
+	stop  chan struct{}
+	done  chan struct{}
+
+	// The remaining fields are only used by the background goroutine, except
+	// for err, which is guarded by errMu.
+	held  asyncHeap
+	seq   uint64
+	errMu sync.Mutex
+	err   error
+
+	// batch holds encoded entries for batchCore's output, which batchKey
+	// identifies, until they're written together; batchLevel is the most
+	// severe of their levels.
+	batch      []*buffer.Buffer
+	batchCore  *ioCore
+	batchLevel Level
+	batchKey   uintptr
+	vec        net.Buffers
+}
+
+// drain waits until every entry queued so far has been written, and returns
+// any errors from background writes since the last drain.
+func (q *asyncQueue) drain() error {
+	return q.drainContext(context.Background())
+}
+
+// drainContext is drain, but gives up waiting and returns ctx.Err() if ctx
+// is done first.
+func (q *asyncQueue) drainContext(ctx context.Context) error {
+	q.mu.RLock()
+	if !q.closed {
+		req := make(chan struct{})
+		select {
+		case q.flush <- req:
+			select {
+			case <-req:
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+	}
+	q.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	q.errMu.Lock()
+	defer q.errMu.Unlock()
+	err := q.err
+	q.err = nil
+	return err
+}
+
+func (q *asyncQueue) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	var tick <-chan time.Time
+	if q.cfg.ReorderWindow > 0 {
+		ticker := q.cfg.Clock.NewTicker(q.cfg.ReorderWindow)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		// Once stopping, leave the remaining entries to shutdown, which
+		// respects the context passed to Stop.
+		select {
+		case <-stop:
+			q.shutdown()
+			return
+		default:
+		}
+
+		select {
+		case item := <-q.items:
+			q.add(item)
+			if q.cfg.MaxBatch > 1 {
+				q.gather()
+			}
+			q.flushBatch()
+		case <-tick:
+			q.release(false)
+			q.flushBatch()
+		case req := <-q.flush:
+			q.receiveQueued()
+			q.release(true)
+			q.flushBatch()
+			close(req)
+		case <-q.pause:
+			q.receiveQueued()
+			q.release(true)
+			q.flushBatch()
+			return
+		case <-stop:
+			q.shutdown()
+			return
+		}
+	}
+}
+
+// shutdown writes every queued and held entry, in order, until the context
+// passed to Stop is done, and drops the rest.
+func (q *asyncQueue) shutdown() {
+	q.mu.RLock()
+	ctx := q.stopCtx
+	q.mu.RUnlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if q.cfg.ReorderWindow > 0 {
+		// Stop has closed the queue, so this holds everything that's left.
+		q.receiveQueued()
+	}
+	for {
+		item, ok := q.next()
+		if !ok {
+			break
+		}
+		if ctx.Err() != nil {
+			q.queued.Dec()
+			q.dropped.Inc()
+			q.stopDropped.Store(true)
+			continue
+		}
+		q.write(item)
+	}
+	q.flushBatch()
+}
+
+// next takes the oldest held entry, or else the next queued one.
+func (q *asyncQueue) next() (asyncItem, bool) {
+	if q.held.Len() > 0 {
+		return heap.Pop(&q.held).(asyncItem), true
+	}
+	select {
+	case item := <-q.items:
+		return item, true
+	default:
+		return asyncItem{}, false
+	}
+}
+
+// receiveQueued takes every entry that's already been queued.
+func (q *asyncQueue) receiveQueued() {
+	for {
+		select {
+		case item := <-q.items:
+			q.add(item)
+		default:
+			return
+		}
+	}
+}
+
+// gather receives up to a batch's worth of entries that are already queued.
+func (q *asyncQueue) gather() {
+	n, yielded := 1, false
+	for n < q.cfg.MaxBatch {
+		select {
+		case item := <-q.items:
+			q.add(item)
+			n++
+		default:
+			// Running dry in the middle of a burst usually means producers
+			// are between entries rather than idle, so give them a chance
+			// to fill the batch before it's written.
+			if n == 1 || yielded {
+				return
+			}
+			yielded = true
+			runtime.Gosched()
+		}
+	}
+}
+
+func (q *asyncQueue) add(item asyncItem) {
+	if q.cfg.ReorderWindow <= 0 {
+		q.write(item)
+		return
+	}
+	item.arrived = q.cfg.Clock.Now()
+	item.seq = q.seq
+	q.seq++
+	heap.Push(&q.held, item)
+}
+
+// release writes the held entries whose window has passed, in timestamp
+// order, or every held entry if all is set.
+func (q *asyncQueue) release(all bool) {
+	cutoff := q.cfg.Clock.Now().Add(-q.cfg.ReorderWindow)
+	for q.held.Len() > 0 {
+		next := q.held[0]
+		if !all && next.ent.Time.After(cutoff) && next.arrived.After(cutoff) {
+			return
+		}
+		q.write(heap.Pop(&q.held).(asyncItem))
+	}
+}
+
+func (q *asyncQueue) write(item asyncItem) {
+	q.queued.Dec()
+	q.written.Inc()
+	if q.cfg.MaxBatch > 1 {
+		if c, ok := item.core.(*ioCore); ok {
+			q.writeBatched(c, item)
+			return
+		}
+	}
+	// Keep entries in order by writing any batch first.
+	q.flushBatch()
+	q.addErr(writeChecked(item.core, item.ent, item.fields))
+}
+
+func (q *asyncQueue) addErr(err error) {
+	if err != nil {
+		q.errMu.Lock()
+		q.err = multierr.Append(q.err, err)
+		q.errMu.Unlock()
+	}
+}
+
+// writeBatched encodes an entry for an ioCore and adds it to the batch,
+// writing the batch first if it's full or for a different WriteSyncer.
+func (q *asyncQueue) writeBatched(c *ioCore, item asyncItem) {
+	if !c.Enabled(item.ent.Level) {
+		return
+	}
+	key := batchKey(c.out)
+	if key == 0 || key != q.batchKey || len(q.batch) >= q.cfg.MaxBatch {
+		q.flushBatch()
+	}
+	buf, err := c.encode(item.ent, item.fields)
+	if err != nil {
+		q.addErr(err)
+		return
+	}
+	if len(q.batch) == 0 || item.ent.Level > q.batchLevel {
+		q.batchLevel = item.ent.Level
+	}
+	q.batch = append(q.batch, buf)
+	q.batchCore, q.batchKey = c, key
+	if key == 0 {
+		q.flushBatch()
+	}
+}
+
+// flushBatch writes and empties the batch.
+func (q *asyncQueue) flushBatch() {
+	if len(q.batch) == 0 {
+		return
+	}
+	q.addErr(q.batchCore.writeEncodedBatch(q.batchLevel, q.batch, &q.vec))
+	for i := range q.batch {
+		q.batch[i].Free()
+		q.batch[i] = nil
+	}
+	q.batch = q.batch[:0]
+	q.batchCore, q.batchKey = nil, 0
+}
+
+// batchKey identifies the destination of a WriteSyncer, so that entries for
+// the same one can be batched. Only pointers (possibly wrapped by AddSync)
+// can be told apart safely; other WriteSyncers get no key, and their
+// entries are written one at a time.
+func batchKey(ws WriteSyncer) uintptr {
+	var w interface{} = ws
+	if ww, ok := ws.(writerWrapper); ok {
+		w = ww.Writer
+	}
+	if v := reflect.ValueOf(w); v.Kind() == reflect.Ptr {
+		return v.Pointer()
+	}
+	return 0
+}
+
+// asyncHeap orders held entries by timestamp, then by arrival.
+type asyncHeap []asyncItem
+
+func (h asyncHeap) Len() int { return len(h) }
+
+func (h asyncHeap) Less(i, j int) bool {
+	if !h[i].ent.Time.Equal(h[j].ent.Time) {
+		return h[i].ent.Time.Before(h[j].ent.Time)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h asyncHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *asyncHeap) Push(x interface{}) { *h = append(*h, x.(asyncItem)) }
+
+func (h *asyncHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = asyncItem{}
+	*h = old[:len(old)-1]
+	return item
+}
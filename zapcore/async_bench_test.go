@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/blastbao/zap/zapcore"
+)
+
+// BenchmarkAsyncCore compares writing each queued entry separately with
+// adaptive batching, reporting the 99th percentile time to enqueue an entry
+// alongside the usual per-entry cost.
+func BenchmarkAsyncCore(b *testing.B) {
+	for _, bm := range []struct {
+		name     string
+		maxBatch int
+	}{
+		{"PerEntry", 0},
+		{"Batched", 64},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer out.Close()
+			core := NewAsyncCore(
+				NewCore(NewJSONEncoder(testEncoderConfig()), out, InfoLevel),
+				AsyncConfig{QueueSize: 4096, MaxBatch: bm.maxBatch},
+			)
+			ent := Entry{Level: InfoLevel, Message: "fake", Time: time.Unix(0, 0)}
+
+			var (
+				mu        sync.Mutex
+				latencies []time.Duration
+			)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				local := make([]time.Duration, 0, 1024)
+				for pb.Next() {
+					start := time.Now()
+					if ce := core.Check(ent, nil); ce != nil {
+						ce.Write()
+					}
+					local = append(local, time.Since(start))
+				}
+				mu.Lock()
+				latencies = append(latencies, local...)
+				mu.Unlock()
+			})
+			core.(io.Closer).Close()
+			b.StopTimer()
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			if len(latencies) > 0 {
+				b.ReportMetric(float64(latencies[len(latencies)*99/100]), "p99-enqueue-ns")
+			}
+		})
+	}
+}
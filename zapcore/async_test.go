@@ -0,0 +1,295 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap/internal/ztest"
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func messages(logs *observer.ObservedLogs) []string {
+	var msgs []string
+	for _, ent := range logs.AllUntimed() {
+		msgs = append(msgs, ent.Message)
+	}
+	return msgs
+}
+
+func TestAsyncCore(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewAsyncCore(fac, AsyncConfig{QueueSize: 2})
+	defer core.(io.Closer).Close()
+
+	child := core.With([]Field{makeInt64Field("k", 1)})
+	for i := 0; i < 10; i++ {
+		writeEntry(child, InfoLevel, "info")
+	}
+	writeEntry(core, DebugLevel, "disabled")
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+
+	entries := logs.AllUntimed()
+	require.Equal(t, 10, len(entries), "Expected every enabled entry to be written by Sync.")
+	assert.Equal(t, []Field{makeInt64Field("k", 1)}, entries[0].Context, "Expected context to be kept.")
+}
+
+func TestAsyncCoreCopiesFields(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewAsyncCore(fac, AsyncConfig{})
+	defer core.(io.Closer).Close()
+
+	fields := []Field{makeInt64Field("k", 1)}
+	if ce := core.Check(Entry{Level: InfoLevel, Message: "msg"}, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	fields[0] = makeInt64Field("k", 2)
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, []Field{makeInt64Field("k", 1)}, logs.AllUntimed()[0].Context, "Expected the field slice to be copied.")
+}
+
+func TestAsyncCoreDropsContexts(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewAsyncCore(fac, AsyncConfig{})
+	defer core.(io.Closer).Close()
+
+	ent := Entry{Level: InfoLevel, Message: "queued", Context: context.Background()}
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write()
+	}
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+	assert.Nil(t, logs.All()[0].Entry.Context, "Expected queued entries not to be bounded by the caller's context.")
+}
+
+func TestAsyncCoreWritesSevereEntriesSynchronously(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewAsyncCore(fac, AsyncConfig{ReorderWindow: time.Hour})
+	defer core.(io.Closer).Close()
+
+	writeEntry(core, InfoLevel, "queued")
+	writeEntry(core, DPanicLevel, "severe")
+	assert.Equal(t, []string{"queued", "severe"}, messages(logs), "Expected severe entries to be written after queued ones, immediately.")
+}
+
+func TestAsyncCoreReorders(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewAsyncCore(fac, AsyncConfig{ReorderWindow: 10 * time.Millisecond})
+	defer core.(io.Closer).Close()
+
+	now := time.Now()
+	for _, offset := range []int{2, 0, 3, 1} {
+		ent := Entry{Level: InfoLevel, Message: string(rune('a' + offset)), Time: now.Add(time.Duration(offset) * time.Microsecond)}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return logs.Len() == 4
+	}, time.Second, time.Millisecond, "Expected held entries to be released once the window passed.")
+	assert.Equal(t, []string{"a", "b", "c", "d"}, messages(logs), "Expected entries in timestamp order.")
+}
+
+func TestAsyncCoreSyncReleasesHeldEntries(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	clock := ztest.NewMockClock(time.Now())
+	core := NewAsyncCore(fac, AsyncConfig{ReorderWindow: time.Minute, Clock: clock})
+	defer core.(io.Closer).Close()
+
+	writeEntry(core, InfoLevel, "held")
+	assert.Equal(t, 0, logs.Len(), "Expected the entry to be held.")
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, []string{"held"}, messages(logs), "Expected Sync to release held entries.")
+}
+
+func TestAsyncCoreErrors(t *testing.T) {
+	core := NewAsyncCore(NewCore(NewJSONEncoder(testEncoderConfig()), &ztest.FailWriter{}, InfoLevel), AsyncConfig{})
+	defer core.(io.Closer).Close()
+
+	writeEntry(core, InfoLevel, "fails")
+	assert.Error(t, core.Sync(), "Expected Sync to report failed background writes.")
+	assert.NoError(t, core.Sync(), "Expected errors to be reported once.")
+}
+
+func TestAsyncCoreClose(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewAsyncCore(fac, AsyncConfig{ReorderWindow: time.Hour})
+	closer := core.(io.Closer)
+
+	writeEntry(core, InfoLevel, "queued")
+	require.NoError(t, closer.Close(), "Unexpected error closing.")
+	assert.Equal(t, []string{"queued"}, messages(logs), "Expected Close to write queued entries.")
+	assert.NoError(t, closer.Close(), "Expected closing twice to succeed.")
+
+	writeEntry(core, InfoLevel, "after")
+	assert.Equal(t, []string{"queued", "after"}, messages(logs), "Expected entries after Close to be written synchronously.")
+}
+
+// gatedWriter blocks its first Write until it's released, and counts writes.
+type gatedWriter struct {
+	entered chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	first := w.writes == 0
+	w.writes++
+	w.mu.Unlock()
+	if first {
+		close(w.entered)
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) Sync() error { return nil }
+
+func TestAsyncCoreBatches(t *testing.T) {
+	out := &gatedWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	core := NewAsyncCore(
+		NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), out, InfoLevel),
+		AsyncConfig{QueueSize: 16, MaxBatch: 8},
+	)
+	defer core.(io.Closer).Close()
+
+	writeEntry(core, InfoLevel, "0")
+	<-out.entered
+	for i := 1; i <= 10; i++ {
+		writeEntry(core, InfoLevel, string(rune('0'+i)))
+	}
+	writeEntry(core, DebugLevel, "disabled")
+	close(out.release)
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+
+	lines := strings.Split(strings.TrimSpace(out.buf.String()), "\n")
+	require.Equal(t, 11, len(lines), "Expected every enabled entry to be written.")
+	for i, line := range lines {
+		assert.Equal(t, `{"msg":"`+string(rune('0'+i))+`"}`, line, "Expected entries in order.")
+	}
+	assert.Equal(t, 3, out.writes, "Expected queued entries to be written in batches of at most MaxBatch.")
+}
+
+func TestAsyncCoreBatchesToConn(t *testing.T) {
+	client, server := net.Pipe()
+	received := make(chan []byte)
+	go func() {
+		bs, _ := ioutil.ReadAll(server)
+		received <- bs
+	}()
+
+	core := NewAsyncCore(
+		NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), AddSync(client), InfoLevel),
+		AsyncConfig{MaxBatch: 4},
+	)
+	for i := 0; i < 10; i++ {
+		writeEntry(core, InfoLevel, "msg")
+	}
+	require.NoError(t, core.(io.Closer).Close(), "Unexpected error closing.")
+	client.Close()
+	assert.Equal(t, strings.Repeat(`{"msg":"msg"}`+"\n", 10), string(<-received), "Unexpected output.")
+}
+
+func TestAsyncCoreDropWhenFull(t *testing.T) {
+	out := &gatedWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	core := NewAsyncCore(
+		NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), out, InfoLevel),
+		AsyncConfig{QueueSize: 1, DropWhenFull: true},
+	).(AsyncCore)
+	defer core.Close()
+
+	writeEntry(core, InfoLevel, "0")
+	<-out.entered
+	for i := 1; i <= 3; i++ {
+		writeEntry(core, InfoLevel, string(rune('0'+i)))
+	}
+	assert.Equal(t, AsyncStats{Queued: 1, Written: 1, Dropped: 2}, core.Stats(), "Expected entries to be dropped while the queue is full.")
+
+	close(out.release)
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, `{"msg":"0"}`+"\n"+`{"msg":"1"}`+"\n", out.buf.String(), "Unexpected output.")
+	assert.Equal(t, AsyncStats{Written: 2, Dropped: 2}, core.Stats(), "Unexpected stats after Sync.")
+}
+
+func TestAsyncCoreStop(t *testing.T) {
+	out := &gatedWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	core := NewAsyncCore(
+		NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), out, InfoLevel),
+		AsyncConfig{},
+	).(AsyncCore)
+
+	writeEntry(core, InfoLevel, "0")
+	<-out.entered
+	writeEntry(core, InfoLevel, "1")
+	writeEntry(core, InfoLevel, "2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, core.Stop(ctx), "Expected Stop to give up when the context is done.")
+	close(out.release)
+	assert.NoError(t, core.Stop(context.Background()), "Expected stopping twice to succeed.")
+	assert.Equal(t, `{"msg":"0"}`+"\n", out.buf.String(), "Expected unwritten entries to be dropped.")
+	assert.Equal(t, AsyncStats{Written: 1, Dropped: 2}, core.Stats(), "Unexpected stats after Stop.")
+
+	writeEntry(core, InfoLevel, "after")
+	assert.Contains(t, out.buf.String(), `{"msg":"after"}`, "Expected entries after Stop to be written synchronously.")
+}
+
+func TestAsyncCoreFlush(t *testing.T) {
+	out := &gatedWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	async := NewAsyncCore(
+		NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), out, InfoLevel),
+		AsyncConfig{},
+	)
+	defer async.(io.Closer).Close()
+	obs, logs := observer.New(InfoLevel)
+	core := NewTee(async, obs)
+
+	writeEntry(core, InfoLevel, "queued")
+	<-out.entered
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	flusher, ok := core.(Flusher)
+	require.True(t, ok, "Expected Tees to be Flushers.")
+	assert.Equal(t, context.DeadlineExceeded, flusher.Flush(ctx), "Expected Flush to give up when the context is done.")
+
+	close(out.release)
+	require.NoError(t, flusher.Flush(context.Background()), "Unexpected error flushing.")
+	assert.Equal(t, `{"msg":"queued"}`+"\n", out.buf.String(), "Expected Flush to write queued entries.")
+	assert.Equal(t, 1, logs.Len(), "Expected synchronous members to be unaffected.")
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"bufio"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	// _defaultBufferSize is the buffer size used by BufferedWriteSyncer when
+	// Size is zero.
+	_defaultBufferSize = 256 * 1024
+
+	// _defaultFlushInterval is how often BufferedWriteSyncer flushes when
+	// FlushInterval is zero.
+	_defaultFlushInterval = 30 * time.Second
+)
+
+// BufferedWriteSyncer buffers writes in memory before flushing them to a
+// wrapped WriteSyncer. The buffer is flushed when it fills up, when Sync is
+// called, and periodically by a background goroutine, so a buffered logger
+// trades a window of possible log loss on crashes for far fewer system calls.
+//
+// The zero value is not usable: WS must be set. The remaining fields are
+// optional and may not be changed after the first call to Write. Stop must be
+// called to flush the buffer and release the background goroutine.
+//
+//	ws := &zapcore.BufferedWriteSyncer{WS: os.Stderr, Size: 512 * 1024}
+//	defer ws.Stop()
+type BufferedWriteSyncer struct {
+	// WS is the WriteSyncer that receives the buffered output.
+	WS WriteSyncer
+
+	// Size is the maximum number of bytes buffered before a flush. It
+	// defaults to 256 KiB.
+	Size int
+
+	// FlushInterval is how often the buffer is flushed even if it isn't full.
+	// It defaults to 30 seconds.
+	FlushInterval time.Duration
+
+	// Clock provides the ticker that drives periodic flushes. It defaults to
+	// DefaultClock.
+	Clock Clock
+
+	mu          sync.Mutex
+	initialized bool
+	stopped     bool
+	writer      *bufio.Writer
+	ticker      *time.Ticker
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func (s *BufferedWriteSyncer) initialize() {
+	size := s.Size
+	if size <= 0 {
+		size = _defaultBufferSize
+	}
+
+	s.writer = bufio.NewWriterSize(s.WS, size)
+	s.initialized = true
+	s.startFlushLoop()
+}
+
+// startFlushLoop starts the goroutine that flushes the buffer periodically.
+func (s *BufferedWriteSyncer) startFlushLoop() {
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = _defaultFlushInterval
+	}
+	clock := s.Clock
+	if clock == nil {
+		clock = DefaultClock
+	}
+
+	s.ticker = clock.NewTicker(interval)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.flushLoop(s.ticker.C, s.stop, s.done)
+}
+
+// Write buffers bs, flushing first if bs doesn't fit in the remaining space.
+// After Stop, writes go directly to the wrapped WriteSyncer.
+func (s *BufferedWriteSyncer) Write(bs []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		// Keep output in order if Stop hasn't flushed the buffer yet.
+		if s.initialized {
+			if err := s.writer.Flush(); err != nil {
+				return 0, err
+			}
+		}
+		return s.WS.Write(bs)
+	}
+	if !s.initialized {
+		s.initialize()
+	}
+
+	// Don't split a single log entry across two flushes if we can help it.
+	if len(bs) > s.writer.Available() && s.writer.Buffered() > 0 {
+		if err := s.writer.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return s.writer.Write(bs)
+}
+
+// Sync flushes the buffer and syncs the wrapped WriteSyncer.
+func (s *BufferedWriteSyncer) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.initialized {
+		err = s.writer.Flush()
+	}
+	return multierr.Append(err, s.WS.Sync())
+}
+
+// flushLoop flushes the buffer on every tick until Stop is called.
+func (s *BufferedWriteSyncer) flushLoop(tick <-chan time.Time, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case <-tick:
+			// Errors here have nowhere to go; the next Write or Sync will
+			// report them, since bufio.Writer remembers them.
+			s.mu.Lock()
+			s.writer.Flush()
+			s.mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop flushes the buffer, syncs the wrapped WriteSyncer, and stops the
+// background goroutine. It's safe to call Stop more than once.
+func (s *BufferedWriteSyncer) Stop() error {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.stopped = true
+	var done <-chan struct{}
+	if !stopped {
+		done = s.stopFlushLoopLocked()
+	}
+	s.mu.Unlock()
+
+	if stopped {
+		return nil
+	}
+	if done != nil {
+		<-done
+	}
+	return s.Sync()
+}
+
+// stopFlushLoopLocked tells the flush goroutine, if it's running, to exit,
+// and returns a channel that's closed once it has. The caller must release
+// the lock before waiting, since the goroutine may be waiting for it.
+func (s *BufferedWriteSyncer) stopFlushLoopLocked() <-chan struct{} {
+	if s.stop == nil {
+		return nil
+	}
+	s.ticker.Stop()
+	close(s.stop)
+	s.stop = nil
+	return s.done
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedWriteSyncer(t *testing.T) {
+	buf := &ztest.Buffer{}
+	ws := &BufferedWriteSyncer{WS: buf, Size: 16, FlushInterval: time.Hour}
+	// The buffered output is flushed under the syncer's lock.
+	output := func() string {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		return buf.String()
+	}
+
+	n, err := ws.Write([]byte("foo\n"))
+	require.NoError(t, err, "Unexpected error writing.")
+	assert.Equal(t, 4, n, "Unexpected number of bytes written.")
+	assert.Empty(t, output(), "Expected small writes to be buffered.")
+
+	// A write that doesn't fit flushes what's already buffered.
+	ws.Write([]byte("0123456789abcdef\n"))
+	assert.Equal(t, "foo\n0123456789abcdef\n", output(), "Expected oversized writes to flush the buffer.")
+
+	ws.Write([]byte("bar\n"))
+	require.NoError(t, ws.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, "foo\n0123456789abcdef\nbar\n", output(), "Expected Sync to flush the buffer.")
+	assert.True(t, buf.Called(), "Expected Sync to sync the wrapped WriteSyncer.")
+
+	ws.Write([]byte("baz\n"))
+	require.NoError(t, ws.Stop(), "Unexpected error stopping.")
+	assert.Contains(t, output(), "baz\n", "Expected Stop to flush the buffer.")
+	assert.NoError(t, ws.Stop(), "Expected stopping twice to succeed.")
+
+	ws.Write([]byte("qux\n"))
+	assert.Contains(t, output(), "qux\n", "Expected writes after Stop to go directly to the wrapped WriteSyncer.")
+}
+
+func TestBufferedWriteSyncerFlushInterval(t *testing.T) {
+	buf := &ztest.Buffer{}
+	ws := &BufferedWriteSyncer{WS: buf, FlushInterval: time.Millisecond}
+	defer ws.Stop()
+
+	ws.Write([]byte("tick\n"))
+	assert.Eventually(t, func() bool {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		return buf.String() == "tick\n"
+	}, time.Second, time.Millisecond, "Expected a periodic flush.")
+}
+
+func TestBufferedWriteSyncerClock(t *testing.T) {
+	buf := &ztest.Buffer{}
+	clock := ztest.NewMockClock(time.Now())
+	ws := &BufferedWriteSyncer{WS: buf, FlushInterval: time.Minute, Clock: clock}
+	defer ws.Stop()
+	output := func() string {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		return buf.String()
+	}
+
+	ws.Write([]byte("tick\n"))
+	assert.Empty(t, output(), "Expected no flush before the clock ticks.")
+	clock.Add(time.Minute)
+	assert.Eventually(t, func() bool {
+		return output() == "tick\n"
+	}, time.Second, time.Millisecond, "Expected a flush when the clock ticks.")
+}
+
+func TestBufferedWriteSyncerErrors(t *testing.T) {
+	ws := &BufferedWriteSyncer{WS: AddSync(ztest.FailWriter{}), Size: 4}
+	defer ws.Stop()
+
+	ws.Write([]byte("foo"))
+	_, err := ws.Write([]byte("bar"))
+	assert.Error(t, err, "Expected flushing to a failing writer to fail.")
+
+	syncErr := errors.New("sync failed")
+	s := &ztest.Buffer{}
+	s.SetError(syncErr)
+	stopped := &BufferedWriteSyncer{WS: s}
+	assert.Equal(t, syncErr, stopped.Stop(), "Expected Stop to return sync errors.")
+}
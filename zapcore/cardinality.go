@@ -0,0 +1,332 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// _hllPrecision is the number of hash bits used to pick a HyperLogLog
+// register. With 2^10 registers, estimates have a standard error of about 3%
+// and each tracked key costs 1KiB.
+const (
+	_hllPrecision = 10
+	_hllRegisters = 1 << _hllPrecision
+)
+
+// _defaultCardinalityMaxKeys is how many keys a cardinality-guarding Core
+// tracks by default when CardinalityConfig.Keys is empty, bounding its
+// sketches to about 1MiB.
+const _defaultCardinalityMaxKeys = 1024
+
+// CardinalityAction is what a cardinality-guarding Core does with the values
+// of a key once it has seen more distinct values than its limit allows.
+type CardinalityAction int8
+
+const (
+	// CardinalityWarn leaves values as they are.
+	CardinalityWarn CardinalityAction = iota
+	// CardinalityHash replaces the values of the key with one of Limit hash
+	// buckets, so that the key can't take more than twice its limit of
+	// distinct values downstream.
+	CardinalityHash
+)
+
+// CardinalityConfig configures NewCardinalityCore.
+type CardinalityConfig struct {
+	// Limit is the number of distinct values a key may take before Action
+	// applies. It's compared to an approximate count, so keys may go a few
+	// percent over (or under) it.
+	Limit uint64
+	// Keys restricts tracking to the fields with these keys. If empty, every
+	// field is tracked, up to MaxKeys keys.
+	Keys []string
+	// MaxKeys caps the number of keys tracked when Keys is empty, since each
+	// costs a 1KiB sketch and the keys themselves may be unbounded. Once
+	// that many keys have been seen, fields with other keys pass through
+	// untracked. Zero means 1024.
+	MaxKeys int
+	// Action is applied to the values of keys that exceed Limit.
+	Action CardinalityAction
+	// OnExceed, if non-nil, is called once for each key that exceeds Limit,
+	// with the key's estimated cardinality.
+	OnExceed func(key string, estimate uint64)
+}
+
+// NewCardinalityCore wraps a Core to track the approximate number of distinct
+// values logged under each field key, using a HyperLogLog sketch per key.
+// Log-indexing systems often treat keys as labels or index fields, and
+// logging an unbounded value (say, a request ID) under such a key can blow up
+// their storage. Once a key's estimated cardinality exceeds the configured
+// limit, the Core writes a warning through the wrapped Core, calls OnExceed,
+// and applies the configured action to that key from then on.
+//
+// Only string, byte string, boolean, and integer fields are tracked, since
+// those are the values label-like keys typically hold; other fields are
+// passed through untouched. Fields added with With are counted, and hashed if
+// necessary, when With is called.
+func NewCardinalityCore(core Core, cfg CardinalityConfig) Core {
+	var keys map[string]struct{}
+	if len(cfg.Keys) > 0 {
+		keys = newKeySet(cfg.Keys)
+	}
+	max := cfg.MaxKeys
+	if max <= 0 {
+		max = _defaultCardinalityMaxKeys
+	}
+	if keys != nil {
+		max = len(keys)
+	}
+	return &cardinalityCore{
+		Core: core,
+		cfg:  cfg,
+		keys: keys,
+		seen: &cardinalityTracker{sketches: make(map[string]*keySketch), max: max},
+	}
+}
+
+type cardinalityCore struct {
+	Core
+	cfg  CardinalityConfig
+	keys map[string]struct{}
+	seen *cardinalityTracker
+}
+
+// Unwrap returns the wrapped Core.
+func (c *cardinalityCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *cardinalityCore) With(fields []Field) Core {
+	fields, exceeded := c.observe(fields)
+	clone := &cardinalityCore{
+		Core: c.Core.With(fields),
+		cfg:  c.cfg,
+		keys: c.keys,
+		seen: c.seen,
+	}
+	c.warn(Entry{Time: time.Now()}, exceeded)
+	return clone
+}
+
+func (c *cardinalityCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	// The fields aren't known until Write, so route the entry through this
+	// Core and check the wrapped Core once they've been rewritten.
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *cardinalityCore) Write(ent Entry, fields []Field) error {
+	fields, exceeded := c.observe(fields)
+	err := writeChecked(c.Core, ent, fields)
+	c.warn(ent, exceeded)
+	return err
+}
+
+// warn reports keys that just exceeded the limit.
+func (c *cardinalityCore) warn(ent Entry, exceeded []keyEstimate) {
+	for _, e := range exceeded {
+		if c.cfg.OnExceed != nil {
+			c.cfg.OnExceed(e.key, e.estimate)
+		}
+		writeChecked(c.Core, Entry{
+			Level:      WarnLevel,
+			Time:       ent.Time,
+			LoggerName: ent.LoggerName,
+			Message:    "field cardinality exceeded limit",
+		}, []Field{
+			{Key: "key", Type: StringType, String: e.key},
+			{Key: "estimate", Type: Uint64Type, Integer: int64(e.estimate)},
+			{Key: "limit", Type: Uint64Type, Integer: int64(c.cfg.Limit)},
+		})
+	}
+}
+
+type keyEstimate struct {
+	key      string
+	estimate uint64
+}
+
+// observe adds the tracked fields' values to their sketches. It returns the
+// fields to write, with values hashed if necessary, and the keys that have
+// just exceeded the limit.
+func (c *cardinalityCore) observe(fields []Field) ([]Field, []keyEstimate) {
+	var (
+		exceeded []keyEstimate
+		copied   bool
+	)
+	for i := range fields {
+		f := &fields[i]
+		if c.keys != nil {
+			if _, ok := c.keys[f.Key]; !ok {
+				continue
+			}
+		}
+		h, ok := hashFieldValue(f)
+		if !ok {
+			continue
+		}
+		over, estimate, first := c.seen.add(f.Key, h, c.cfg.Limit)
+		if first {
+			exceeded = append(exceeded, keyEstimate{f.Key, estimate})
+		}
+		if over && c.cfg.Action == CardinalityHash {
+			if !copied {
+				// Don't modify the caller's slice.
+				fields = append([]Field(nil), fields...)
+				f = &fields[i]
+				copied = true
+			}
+			*f = Field{Key: f.Key, Type: StringType, String: hashBucket(h, c.cfg.Limit)}
+		}
+	}
+	return fields, exceeded
+}
+
+// hashBucket maps a value's hash to one of limit buckets.
+func hashBucket(h, limit uint64) string {
+	if limit == 0 {
+		limit = 1
+	}
+	return "h" + strconv.FormatUint(h%limit, 16)
+}
+
+// hashFieldValue returns a 64-bit hash of a field's value, if it's of a
+// tracked type.
+func hashFieldValue(f *Field) (uint64, bool) {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	switch f.Type {
+	case StringType:
+		for i := 0; i < len(f.String); i++ {
+			h = (h ^ uint64(f.String[i])) * prime64
+		}
+	case ByteStringType:
+		b, ok := f.Interface.([]byte)
+		if !ok {
+			return 0, false
+		}
+		for _, c := range b {
+			h = (h ^ uint64(c)) * prime64
+		}
+	case BoolType, Int64Type, Int32Type, Int16Type, Int8Type,
+		Uint64Type, Uint32Type, Uint16Type, Uint8Type, UintptrType:
+		h = (h ^ uint64(f.Integer)) * prime64
+	default:
+		return 0, false
+	}
+	// FNV mixes its low bits poorly for short inputs, which matters since
+	// the sketch relies on every bit; finish with a MurmurHash3-style
+	// avalanche.
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h, true
+}
+
+type cardinalityTracker struct {
+	mu       sync.Mutex
+	sketches map[string]*keySketch
+	max      int // most keys to track
+}
+
+type keySketch struct {
+	hyperLogLog
+	exceeded bool
+}
+
+// add records a hashed value for key. It reports whether the key is over
+// limit, its current estimate, and whether this value pushed it over. Keys
+// beyond the tracker's capacity are ignored.
+func (t *cardinalityTracker) add(key string, h, limit uint64) (over bool, estimate uint64, first bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sketches[key]
+	if !ok {
+		if len(t.sketches) >= t.max {
+			return false, 0, false
+		}
+		s = &keySketch{hyperLogLog: newHyperLogLog()}
+		t.sketches[key] = s
+	}
+	s.insert(h)
+	estimate = s.estimate()
+	if s.exceeded {
+		return true, estimate, false
+	}
+	if estimate > limit {
+		s.exceeded = true
+		return true, estimate, true
+	}
+	return false, estimate, false
+}
+
+// hyperLogLog is a fixed-precision HyperLogLog sketch (Flajolet et al.,
+// 2007) that keeps its estimate up to date as registers change, so that
+// reading it is cheap.
+type hyperLogLog struct {
+	registers [_hllRegisters]uint8
+	sum       float64 // sum of 2^-register
+	zeros     int     // number of zero registers
+}
+
+func newHyperLogLog() hyperLogLog {
+	return hyperLogLog{sum: _hllRegisters, zeros: _hllRegisters}
+}
+
+func (h *hyperLogLog) insert(hash uint64) {
+	idx := hash >> (64 - _hllPrecision)
+	// The rank is the position of the first set bit in the remaining bits,
+	// capped so that it fits the register.
+	rank := uint8(bits.LeadingZeros64(hash<<_hllPrecision|1<<(_hllPrecision-1)) + 1)
+	old := h.registers[idx]
+	if rank <= old {
+		return
+	}
+	if old == 0 {
+		h.zeros--
+	}
+	h.sum += math.Ldexp(1, -int(rank)) - math.Ldexp(1, -int(old))
+	h.registers[idx] = rank
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	const m = float64(_hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	e := alpha * m * m / h.sum
+	if e <= 2.5*m && h.zeros > 0 {
+		// Use linear counting for small cardinalities.
+		e = m * math.Log(m/float64(h.zeros))
+	}
+	return uint64(e + 0.5)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func withCardinalityCore(cfg CardinalityConfig, f func(Core, *observer.ObservedLogs)) {
+	fac, logs := observer.New(InfoLevel)
+	f(NewCardinalityCore(fac, cfg), logs)
+}
+
+func writeIDs(t testing.TB, core Core, key string, n int) {
+	for i := 0; i < n; i++ {
+		ent := Entry{Level: InfoLevel, Message: "request"}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(Field{Key: key, Type: StringType, String: fmt.Sprintf("id-%d", i)})
+		}
+	}
+}
+
+func TestCardinalityCoreWarns(t *testing.T) {
+	var exceeded []string
+	cfg := CardinalityConfig{
+		Limit:    100,
+		OnExceed: func(key string, estimate uint64) { exceeded = append(exceeded, key) },
+	}
+	withCardinalityCore(cfg, func(core Core, logs *observer.ObservedLogs) {
+		writeIDs(t, core, "user", 50)
+		writeIDs(t, core, "request_id", 1000)
+		writeIDs(t, core, "user", 50) // repeated values don't count
+
+		assert.Equal(t, []string{"request_id"}, exceeded, "Expected only the high-cardinality key to exceed the limit.")
+		warnings := logs.FilterMessage("field cardinality exceeded limit")
+		require.Equal(t, 1, warnings.Len(), "Expected a single warning.")
+		ctx := warnings.All()[0].ContextMap()
+		assert.Equal(t, "request_id", ctx["key"], "Unexpected key in warning.")
+		assert.Equal(t, uint64(100), ctx["limit"], "Unexpected limit in warning.")
+
+		// Values are left alone.
+		last := logs.FilterMessage("request").All()
+		assert.Equal(t, "id-999", last[len(last)-51].ContextMap()["request_id"], "Expected values not to be hashed.")
+	})
+}
+
+func TestCardinalityCoreHashes(t *testing.T) {
+	cfg := CardinalityConfig{Limit: 10, Action: CardinalityHash, Keys: []string{"request_id"}}
+	withCardinalityCore(cfg, func(core Core, logs *observer.ObservedLogs) {
+		writeIDs(t, core, "request_id", 500)
+		writeIDs(t, core, "untracked", 500)
+
+		distinct := make(map[interface{}]struct{})
+		for _, e := range logs.FilterMessage("request").All() {
+			if v, ok := e.ContextMap()["request_id"]; ok {
+				distinct[v] = struct{}{}
+			}
+		}
+		assert.True(t, len(distinct) <= 2*int(cfg.Limit), "Expected hashing to bound cardinality, got %d values.", len(distinct))
+		assert.Equal(t, 1, logs.FilterMessage("field cardinality exceeded limit").Len(), "Expected a single warning.")
+		assert.Equal(t, 1, logs.FilterField(Field{Key: "untracked", Type: StringType, String: "id-499"}).Len(), "Expected untracked keys to pass through.")
+	})
+}
+
+func TestCardinalityCoreMaxKeys(t *testing.T) {
+	var exceeded []string
+	cfg := CardinalityConfig{
+		Limit:    10,
+		MaxKeys:  2,
+		OnExceed: func(key string, estimate uint64) { exceeded = append(exceeded, key) },
+	}
+	withCardinalityCore(cfg, func(core Core, logs *observer.ObservedLogs) {
+		for i := 0; i < 100; i++ {
+			writeIDs(t, core, fmt.Sprintf("key-%d", i), 1)
+		}
+		writeIDs(t, core, "key-1", 100)
+		writeIDs(t, core, "key-50", 100)
+		assert.Equal(t, []string{"key-1"}, exceeded, "Expected keys beyond MaxKeys to be untracked.")
+	})
+}
+
+func TestCardinalityCoreWith(t *testing.T) {
+	cfg := CardinalityConfig{Limit: 3, Action: CardinalityHash}
+	withCardinalityCore(cfg, func(core Core, logs *observer.ObservedLogs) {
+		var child Core
+		for i := 0; i < 20; i++ {
+			child = core.With([]Field{{Key: "tenant", Type: Int64Type, Integer: int64(i)}})
+		}
+		require.Equal(t, 1, logs.Len(), "Expected a warning once the context exceeded the limit.")
+
+		ent := Entry{Level: InfoLevel, Message: "hi"}
+		child.Check(ent, nil).Write()
+		tenant := logs.FilterMessage("hi").All()[0].ContextMap()["tenant"]
+		assert.IsType(t, "", tenant, "Expected the context value to be hashed.")
+	})
+}
+
+func TestCardinalityCoreRespectsWrappedCheck(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewCardinalityCore(NewSampler(fac, time.Minute, 1, 100), CardinalityConfig{Limit: 10})
+	for i := 0; i < 3; i++ {
+		if ce := core.Check(Entry{Level: InfoLevel, Message: "sampled"}, nil); ce != nil {
+			ce.Write()
+		}
+	}
+	assert.Equal(t, 1, logs.Len(), "Expected the wrapped sampler to still drop entries.")
+
+	assert.Nil(t, core.Check(Entry{Level: DebugLevel}, nil), "Expected disabled levels to be dropped.")
+}
+
+func TestCardinalityEstimateAccuracy(t *testing.T) {
+	for _, n := range []int{10, 1000, 50000} {
+		var estimate uint64
+		cfg := CardinalityConfig{
+			Limit:    uint64(n) / 2,
+			OnExceed: func(_ string, e uint64) { estimate = e },
+		}
+		withCardinalityCore(cfg, func(core Core, _ *observer.ObservedLogs) {
+			writeIDs(t, core, "k", n)
+		})
+		// Once over the limit, it should have happened close to the limit.
+		assert.True(t, math.Abs(float64(estimate)-float64(cfg.Limit)) <= 0.1*float64(cfg.Limit)+1,
+			"Expected the limit to trip near %d, tripped at %d.", cfg.Limit, estimate)
+	}
+}
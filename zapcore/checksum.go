@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/blastbao/zap/internal/bufferpool"
+)
+
+// _crc32c is the Castagnoli polynomial table used for EncoderConfig.ChecksumKey.
+var _crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// _checksumLen is the number of hex digits in an encoded checksum.
+const _checksumLen = 8
+
+var (
+	// ErrChecksumMissing is returned by VerifyChecksum when a record doesn't
+	// end with a well-formed checksum field, as happens when it's truncated.
+	ErrChecksumMissing = errors.New("record has no checksum field")
+	// ErrChecksumMismatch is returned by VerifyChecksum when a record's
+	// contents don't match its checksum.
+	ErrChecksumMismatch = errors.New("record checksum mismatch")
+)
+
+func formatChecksum(sum uint32) string {
+	s := strconv.FormatUint(uint64(sum), 16)
+	for len(s) < _checksumLen {
+		s = "0" + s
+	}
+	return s
+}
+
+// VerifyChecksum checks a record written by the JSON encoder with
+// EncoderConfig.ChecksumKey set to key. The record may include the
+// encoder's RecordPrefix and line ending, so it can come straight from a
+// bufio.Scanner using bufio.ScanLines or ScanJSONSeq.
+//
+// It returns ErrChecksumMissing if the record doesn't end with the checksum
+// field, and ErrChecksumMismatch if the record was altered.
+func VerifyChecksum(record []byte, key string) error {
+	// Drop the prefix and line ending.
+	if i := bytes.IndexByte(record, '{'); i >= 0 {
+		record = record[i:]
+	}
+	record = bytes.TrimRight(record, "\r\n\x00 \t")
+	if len(record) == 0 || record[len(record)-1] != '}' {
+		return ErrChecksumMissing
+	}
+
+	buf := bufferpool.Get()
+	defer buf.Free()
+	enc := &jsonEncoder{buf: buf}
+	buf.AppendByte('"')
+	enc.safeAddString(key)
+	buf.AppendString(`":"`)
+
+	start := bytes.LastIndex(record, buf.Bytes())
+	if start < 0 {
+		return ErrChecksumMissing
+	}
+	value := record[start+buf.Len() : len(record)-1]
+	if len(value) != _checksumLen+1 || value[_checksumLen] != '"' {
+		return ErrChecksumMissing
+	}
+	want, err := hex.DecodeString(string(value[:_checksumLen]))
+	if err != nil {
+		return ErrChecksumMissing
+	}
+
+	// Remove the field, along with its separator, and close the object.
+	if start > 0 && record[start-1] == ',' {
+		start--
+	}
+	sum := crc32.Update(crc32.Checksum(record[:start], _crc32c), _crc32c, []byte{'}'})
+	if formatChecksum(sum) != hex.EncodeToString(want) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
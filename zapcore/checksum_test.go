@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/blastbao/zap/zapcore"
+)
+
+func encodeWithChecksum(t testing.TB, cfg EncoderConfig, msg string, fields ...Field) []byte {
+	buf, err := NewJSONEncoder(cfg).EncodeEntry(Entry{Message: msg}, fields)
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	out := append([]byte(nil), buf.Bytes()...)
+	buf.Free()
+	return out
+}
+
+func TestJSONEncoderChecksum(t *testing.T) {
+	cfg := EncoderConfig{MessageKey: "msg", ChecksumKey: "crc", LineEnding: "\n"}
+	sum := crc32.Checksum([]byte(`{"msg":"hello"}`), crc32.MakeTable(crc32.Castagnoli))
+	assert.Equal(
+		t,
+		fmt.Sprintf(`{"msg":"hello","crc":"%08x"}`+"\n", sum),
+		string(encodeWithChecksum(t, cfg, "hello")),
+		"Unexpected checksum field.",
+	)
+
+	// An object with no other fields.
+	record := encodeWithChecksum(t, EncoderConfig{ChecksumKey: "crc"}, "ignored")
+	assert.Regexp(t, `^\{"crc":"[0-9a-f]{8}"\}\n$`, string(record), "Unexpected record without other fields.")
+	assert.NoError(t, VerifyChecksum(record, "crc"), "Expected a record without other fields to verify.")
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tests := []struct {
+		desc string
+		cfg  EncoderConfig
+	}{
+		{"newlines", EncoderConfig{MessageKey: "msg", ChecksumKey: "crc"}},
+		{"JSON sequence", EncoderConfig{MessageKey: "msg", ChecksumKey: "crc", RecordPrefix: JSONSeqRecordSeparator}},
+		{"NUL endings", EncoderConfig{MessageKey: "msg", ChecksumKey: "crc", LineEnding: NULLineEnding}},
+		{"escaped key", EncoderConfig{MessageKey: "msg", ChecksumKey: "check\"sum"}},
+		{"deduplicated", EncoderConfig{MessageKey: "msg", ChecksumKey: "crc", DeduplicateKeys: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			record := encodeWithChecksum(t, tt.cfg, "hello\nworld",
+				Field{Key: "crc", Type: StringType, String: "user-supplied"},
+				Field{Key: "n", Type: Int64Type, Integer: 42},
+			)
+			assert.NoError(t, VerifyChecksum(record, tt.cfg.ChecksumKey), "Expected an intact record to verify.")
+
+			tampered := bytes.Replace(record, []byte("42"), []byte("43"), 1)
+			assert.Equal(t, ErrChecksumMismatch, VerifyChecksum(tampered, tt.cfg.ChecksumKey), "Expected a mismatch for a tampered record.")
+
+			truncated := record[:len(record)/2]
+			assert.Equal(t, ErrChecksumMissing, VerifyChecksum(truncated, tt.cfg.ChecksumKey), "Expected a truncated record to be missing its checksum.")
+
+			assert.Equal(t, ErrChecksumMissing, VerifyChecksum(record, "other"), "Expected an error for the wrong key.")
+		})
+	}
+}
+
+func TestVerifyChecksumMalformed(t *testing.T) {
+	for _, record := range []string{
+		"",
+		"{}",
+		`{"crc":"1234567"}`,
+		`{"crc":"123456789"}`,
+		`{"crc":"zzzzzzzz"}`,
+		`{"crc":"12345678"`,
+	} {
+		assert.Equal(t, ErrChecksumMissing, VerifyChecksum([]byte(record), "crc"), "Expected %q to have no checksum.", record)
+	}
+}
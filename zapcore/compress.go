@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+var errClosedCompressor = errors.New("write to closed CompressingWriteSyncer")
+
+// A CompressionCodec is a compression format supported by
+// NewCompressingWriteSyncer.
+type CompressionCodec string
+
+const (
+	// GzipCodec compresses with gzip (RFC 1952). Levels run from 1 (fastest)
+	// to 9 (smallest).
+	GzipCodec CompressionCodec = "gzip"
+	// ZstdCodec compresses with Zstandard (RFC 8878). It's only available
+	// once the zapzstd package has registered it, which keeps the zstd
+	// implementation out of programs that don't use it.
+	ZstdCodec CompressionCodec = "zstd"
+)
+
+// A Compressor is a compressed stream, such as a gzip.Writer, written by a
+// CompressingWriteSyncer. Flush must emit everything written so far in a
+// form that can be decompressed, and Close must end the stream without
+// closing the underlying writer.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+var (
+	_codecMu sync.RWMutex
+	_codecs  = map[CompressionCodec]func(w io.Writer, level int) (Compressor, error){
+		GzipCodec: newGzipCompressor,
+	}
+)
+
+// RegisterCompressionCodec makes a codec available to
+// NewCompressingWriteSyncer. The constructor is passed the writer to
+// compress into and the requested level, which is zero for the codec's
+// default; it should reject levels it doesn't support. Registering the same
+// codec twice is an error.
+func RegisterCompressionCodec(codec CompressionCodec, newCompressor func(w io.Writer, level int) (Compressor, error)) error {
+	_codecMu.Lock()
+	defer _codecMu.Unlock()
+	if codec == "" {
+		return errors.New("can't register a compression codec with an empty name")
+	}
+	if _, ok := _codecs[codec]; ok {
+		return fmt.Errorf("compression codec %q already registered", codec)
+	}
+	_codecs[codec] = newCompressor
+	return nil
+}
+
+// Available reports whether the codec is registered.
+func (c CompressionCodec) Available() bool {
+	_codecMu.RLock()
+	defer _codecMu.RUnlock()
+	_, ok := _codecs[c]
+	return ok
+}
+
+func newGzipCompressor(w io.Writer, level int) (Compressor, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.BestSpeed && level != gzip.DefaultCompression || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level %d: must be between %d and %d", level, gzip.BestSpeed, gzip.BestCompression)
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// A CompressingWriteSyncer compresses everything written to it before
+// passing it on to another WriteSyncer. See NewCompressingWriteSyncer.
+type CompressingWriteSyncer struct {
+	mu     sync.Mutex
+	ws     WriteSyncer
+	w      Compressor
+	closed bool
+}
+
+// NewCompressingWriteSyncer wraps a WriteSyncer so that its output is
+// compressed with codec as a single stream. A level of zero selects the
+// codec's default. Gzip is always available; other codecs must be
+// registered first (see RegisterCompressionCodec).
+//
+// Compressed data is held in memory until a block fills up or Sync is called,
+// so Sync flushes the compressor, emitting everything written so far in a
+// form that can be decompressed, before syncing ws. Flushing often worsens
+// the compression ratio, so compression works best with Loggers that don't
+// sync after every entry. Close ends the stream and should be called before
+// ws is closed; otherwise, the final stream is left without its trailer,
+// though flushed data can still be recovered.
+//
+// Both gzip and Zstandard allow streams to be concatenated, so appending to
+// an existing compressed file leaves a file that standard tools decompress
+// as a whole.
+func NewCompressingWriteSyncer(ws WriteSyncer, codec CompressionCodec, level int) (*CompressingWriteSyncer, error) {
+	_codecMu.RLock()
+	newCompressor, ok := _codecs[codec]
+	_codecMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown compression codec %q", codec)
+	}
+	w, err := newCompressor(ws, level)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressingWriteSyncer{ws: ws, w: w}, nil
+}
+
+// Write compresses p.
+func (s *CompressingWriteSyncer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errClosedCompressor
+	}
+	return s.w.Write(p)
+}
+
+// Sync flushes the compressed data written so far and syncs the wrapped
+// WriteSyncer.
+func (s *CompressingWriteSyncer) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return s.ws.Sync()
+	}
+	return multierr.Append(s.w.Flush(), s.ws.Sync())
+}
+
+// Close ends the compressed stream and syncs the wrapped WriteSyncer, but
+// doesn't close it. Writes after Close fail.
+func (s *CompressingWriteSyncer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return multierr.Append(s.w.Close(), s.ws.Sync())
+}
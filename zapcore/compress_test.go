@@ -0,0 +1,134 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/blastbao/zap/internal/ztest"
+	. "github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decompress(t testing.TB, codec CompressionCodec, data []byte) string {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch codec {
+	case GzipCodec:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case "identity":
+		r = bytes.NewReader(data)
+	}
+	require.NoError(t, err, "Failed to open %v reader.", codec)
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err, "Failed to decompress %v output.", codec)
+	return string(out)
+}
+
+func TestCompressingWriteSyncer(t *testing.T) {
+	for _, codec := range []CompressionCodec{GzipCodec} {
+		t.Run(string(codec), func(t *testing.T) {
+			buf := &ztest.Buffer{}
+			ws, err := NewCompressingWriteSyncer(buf, codec, 0)
+			require.NoError(t, err, "Unexpected error creating WriteSyncer.")
+
+			ws.Write([]byte("foo\n"))
+			require.NoError(t, ws.Sync(), "Unexpected error syncing.")
+			assert.True(t, buf.Called(), "Expected Sync to sync the wrapped WriteSyncer.")
+			synced := len(buf.Bytes())
+			assert.NotZero(t, synced, "Expected Sync to flush compressed output.")
+
+			ws.Write(bytes.Repeat([]byte("bar\n"), 1000))
+			require.NoError(t, ws.Close(), "Unexpected error closing.")
+			assert.Equal(t, "foo\n"+string(bytes.Repeat([]byte("bar\n"), 1000)), decompress(t, codec, buf.Bytes()), "Unexpected decompressed output.")
+			assert.True(t, len(buf.Bytes()) < 1000, "Expected repetitive output to compress well, got %d bytes.", len(buf.Bytes()))
+
+			_, err = ws.Write([]byte("baz\n"))
+			assert.Error(t, err, "Expected writes after Close to fail.")
+			assert.NoError(t, ws.Close(), "Expected closing twice to succeed.")
+		})
+	}
+}
+
+func TestCompressingWriteSyncerConcatenatedStreams(t *testing.T) {
+	for _, codec := range []CompressionCodec{GzipCodec} {
+		buf := &ztest.Buffer{}
+		for _, msg := range []string{"first\n", "second\n"} {
+			ws, err := NewCompressingWriteSyncer(buf, codec, 1)
+			require.NoError(t, err, "Unexpected error creating %v WriteSyncer.", codec)
+			ws.Write([]byte(msg))
+			require.NoError(t, ws.Close(), "Unexpected error closing %v WriteSyncer.", codec)
+		}
+		assert.Equal(t, "first\nsecond\n", decompress(t, codec, buf.Bytes()), "Expected %v streams to concatenate.", codec)
+	}
+}
+
+func TestCompressingWriteSyncerErrors(t *testing.T) {
+	tests := []struct {
+		codec CompressionCodec
+		level int
+		err   string
+	}{
+		{"lz4", 0, `unknown compression codec "lz4"`},
+		{GzipCodec, 10, "invalid gzip compression level 10"},
+		{GzipCodec, -3, "invalid gzip compression level -3"},
+		{ZstdCodec, 0, `unknown compression codec "zstd"`},
+	}
+	for _, tt := range tests {
+		_, err := NewCompressingWriteSyncer(&ztest.Buffer{}, tt.codec, tt.level)
+		if assert.Error(t, err, "Expected an error for %v at level %d.", tt.codec, tt.level) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error message.")
+		}
+	}
+}
+
+type identityCompressor struct{ io.Writer }
+
+func (identityCompressor) Flush() error { return nil }
+func (identityCompressor) Close() error { return nil }
+
+func TestRegisterCompressionCodec(t *testing.T) {
+	newIdentity := func(w io.Writer, level int) (Compressor, error) {
+		return identityCompressor{w}, nil
+	}
+	assert.False(t, CompressionCodec("identity").Available(), "Expected the codec to be unavailable before registering it.")
+	require.NoError(t, RegisterCompressionCodec("identity", newIdentity), "Unexpected error registering codec.")
+	assert.True(t, CompressionCodec("identity").Available(), "Expected the codec to be available once registered.")
+
+	buf := &ztest.Buffer{}
+	ws, err := NewCompressingWriteSyncer(buf, "identity", 0)
+	require.NoError(t, err, "Unexpected error creating WriteSyncer.")
+	ws.Write([]byte("foo\n"))
+	require.NoError(t, ws.Close(), "Unexpected error closing.")
+	assert.Equal(t, "foo\n", decompress(t, "identity", buf.Bytes()), "Unexpected output.")
+
+	assert.Error(t, RegisterCompressionCodec("identity", newIdentity), "Expected an error registering a codec twice.")
+	assert.Error(t, RegisterCompressionCodec(GzipCodec, newIdentity), "Expected an error replacing gzip.")
+	assert.Error(t, RegisterCompressionCodec("", newIdentity), "Expected an error for an empty name.")
+}
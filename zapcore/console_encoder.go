@@ -25,7 +25,6 @@ import (
 	"sync"
 
 	"github.com/blastbao/zap/buffer"
-	"github.com/blastbao/zap/internal/bufferpool"
 )
 
 var _sliceEncoderPool = sync.Pool{
@@ -64,7 +63,8 @@ func (c consoleEncoder) Clone() Encoder {
 }
 
 func (c consoleEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer, error) {
-	line := bufferpool.Get()
+	line := c.pool.Get()
+	line.AppendString(c.RecordPrefix)
 
 	// We don't want the entry's metadata to be quoted and escaped (if it's
 	// encoded as strings), which means that we can't use the JSON encoder. The
@@ -107,27 +107,47 @@ func (c consoleEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer,
 	}
 
 	// Add any structured context.
-	c.writeContext(line, fields)
+	c.writeContext(line, ent, fields)
 
 	// If there's no stacktrace key, honor that; this allows users to force
 	// single-line output.
-	if ent.Stack != "" && c.StacktraceKey != "" {
-		line.AppendByte('\n')
-		line.AppendString(ent.Stack)
+	if c.StacktraceKey != "" {
+		if stack := ent.Stacktrace(); stack != "" {
+			line.AppendByte('\n')
+			line.AppendString(stack)
+		}
 	}
 
-	if c.LineEnding != "" {
-		line.AppendString(c.LineEnding)
-	} else {
-		line.AppendString(DefaultLineEnding)
-	}
+	c.appendLineEnding(line)
 	return line, nil
 }
 
-func (c consoleEncoder) writeContext(line *buffer.Buffer, extra []Field) {
-	context := c.jsonEncoder.Clone().(*jsonEncoder)
+func (c consoleEncoder) writeContext(line *buffer.Buffer, ent Entry, extra []Field) {
+	var context *jsonEncoder
+	if c.EncodeExtra == nil && c.SchemaKey == "" {
+		context = c.jsonEncoder.Clone().(*jsonEncoder)
+	} else {
+		// The schema and extra fields are top-level, so they go before the
+		// fields added with With and outside any namespaces those open.
+		context = c.jsonEncoder.clone()
+		context.openNamespaces = 0
+		if c.SchemaKey != "" {
+			context.addRawKey(c.SchemaKey)
+			context.AppendString(c.SchemaValue.Load())
+		}
+		if c.EncodeExtra != nil {
+			c.EncodeExtra(ent, context)
+		}
+		if c.jsonEncoder.buf.Len() > 0 {
+			context.mergeKeys(c.jsonEncoder.keys)
+			context.buf.Write(c.jsonEncoder.buf.Bytes())
+		}
+		context.nonFinite += c.jsonEncoder.nonFinite
+		context.openNamespaces = c.jsonEncoder.openNamespaces
+	}
 	defer context.buf.Free()
 
+	context.entTime = ent.Time
 	addFields(context, extra)
 	context.closeOpenNamespaces()
 	if context.buf.Len() == 0 {
@@ -141,7 +161,7 @@ func (c consoleEncoder) writeContext(line *buffer.Buffer, extra []Field) {
 }
 
 func (c consoleEncoder) addTabIfNecessary(line *buffer.Buffer) {
-	if line.Len() > 0 {
+	if line.Len() > len(c.RecordPrefix) {
 		line.AppendByte('\t')
 	}
 }
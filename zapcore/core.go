@@ -20,6 +20,14 @@
 
 package zapcore
 
+import (
+	"context"
+	"net"
+
+	"github.com/blastbao/zap/buffer"
+	"github.com/blastbao/zap/internal/bufferpool"
+)
+
 // Core is a minimal, fast logger interface.
 // It's designed for library authors to wrap in a more user-friendly API.
 //
@@ -79,12 +87,24 @@ type ioCore struct {
 	LevelEnabler 		// 根据日志级别 level 判断当前日志是否应该输出
 	enc Encoder			// 编码器，能够将 Entry 和 fields 编码成 bytes
 	out WriteSyncer 	// 输出器，能够将 bytes 写入到目标文件中，返回写入成功数
+
+	// 通过 With 添加的 Since 和 Until 字段，不能提前编码，需要在每条日志编码时相对于其时间计算
+	timeFields []Field
 }
 
+// With encodes fields into a clone of the Core's encoder, except for
+// SinceType and UntilType fields, which must be measured from each entry's
+// time; those are kept and encoded ahead of each entry's own fields.
 func (c *ioCore) With(fields []Field) Core {
 	clone := c.clone()
 
-	addFields(clone.enc, fields)
+	for i := range fields {
+		if t := fields[i].Type; t == SinceType || t == UntilType {
+			clone.timeFields = append(clone.timeFields, fields[i])
+			continue
+		}
+		addFields(clone.enc, fields[i:i+1])
+	}
 
 	return clone
 }
@@ -106,31 +126,87 @@ func (c *ioCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
 
 func (c *ioCore) Write(ent Entry, fields []Field) error {
 
-	// 调用 EncodeEntry() 将 ent, fields 编码成字节序列
-	buf, err := c.enc.EncodeEntry(ent, fields)
+	// 将 ent, fields 编码成字节序列
+	buf, err := c.encode(ent, fields)
 	if err != nil {
 		return err
 	}
 
-	// 调用 Write 方法进行真正的输出
-	_, err = c.out.Write(buf.Bytes())
-
-	// 释放 buf
+	// 输出并释放 buf
+	err = c.writeEncoded(ent.Context, ent.Level, buf.Bytes())
 	buf.Free()
+	return err
+}
 
-	// 错误检查
-	if err != nil {
+// writeEncoded writes entries encoded by encode the way Write does: bounded
+// by ctx if the output is a ContextWriter, and followed by a Sync if lvl,
+// the most severe of their levels, is above ErrorLevel.
+func (c *ioCore) writeEncoded(ctx context.Context, lvl Level, p []byte) error {
+
+	// 调用 Write 方法进行真正的输出，若 entry 带有 context 则由其约束写入时限
+	if _, err := writeContext(ctx, c.out, p); err != nil {
 		return err
 	}
 
 	// 如果错误级别大于 Error 则立即刷盘
-	if ent.Level > ErrorLevel {
+	c.syncAfter(lvl)
+	return nil
+}
+
+// writeEncodedBatch writes several entries encoded by encode, none of which
+// carry a context, in one go: with a single vectored write if the output is
+// a network connection, and otherwise by joining them. scratch holds the
+// vector between calls.
+func (c *ioCore) writeEncodedBatch(lvl Level, bufs []*buffer.Buffer, scratch *net.Buffers) error {
+	if len(bufs) == 1 {
+		return c.writeEncoded(nil, lvl, bufs[0].Bytes())
+	}
+	if w, ok := c.out.(writerWrapper); ok {
+		if conn, ok := w.Writer.(net.Conn); ok {
+			vec := (*scratch)[:0]
+			for _, buf := range bufs {
+				vec = append(vec, buf.Bytes())
+			}
+			*scratch = vec
+			if _, err := vec.WriteTo(conn); err != nil {
+				return err
+			}
+			c.syncAfter(lvl)
+			return nil
+		}
+	}
+	joined := bufferpool.Get()
+	defer joined.Free()
+	for _, buf := range bufs {
+		joined.Write(buf.Bytes())
+	}
+	return c.writeEncoded(nil, lvl, joined.Bytes())
+}
+
+// syncAfter syncs the output after writing an entry above ErrorLevel.
+func (c *ioCore) syncAfter(lvl Level) {
+	if lvl > ErrorLevel {
 		// Since we may be crashing the program, sync the output. Ignore Sync
 		// errors, pending a clean solution to issue #370.
 		c.Sync()
 	}
+}
 
-	return nil
+// encode encodes an entry the way Write writes it.
+func (c *ioCore) encode(ent Entry, fields []Field) (*buffer.Buffer, error) {
+
+	if len(c.timeFields) > 0 {
+		fields = append(c.timeFields[:len(c.timeFields):len(c.timeFields)], fields...)
+	}
+
+	// 调用 EncodeEntry() 将 ent, fields 编码成字节序列
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果编码后超过 MaxEntryBytes，按 OversizePolicy 重新编码
+	return limitEntrySize(c.enc, ent, fields, buf)
 }
 
 func (c *ioCore) Sync() error {
@@ -142,5 +218,6 @@ func (c *ioCore) clone() *ioCore {
 		LevelEnabler: c.LevelEnabler,
 		enc:          c.enc.Clone(),
 		out:          c.out,
+		timeFields:   c.timeFields[:len(c.timeFields):len(c.timeFields)],
 	}
 }
@@ -105,6 +105,38 @@ func TestIOCore(t *testing.T) {
 	)
 }
 
+func TestIOCoreTimeFields(t *testing.T) {
+	start := time.Unix(100, 0)
+	cfg := EncoderConfig{MessageKey: "msg", EncodeDuration: StringDurationEncoder}
+	tests := []struct {
+		enc  Encoder
+		want []string
+	}{
+		{NewJSONEncoder(cfg), []string{
+			`{"msg":"tick","k":1,"age":"1s","left":"59m59s"}`,
+			`{"msg":"tick","k":1,"age":"1m0s","left":"59m0s"}`,
+		}},
+		{NewConsoleEncoder(cfg), []string{
+			`tick	{"k": 1, "age": "1s", "left": "59m59s"}`,
+			`tick	{"k": 1, "age": "1m0s", "left": "59m0s"}`,
+		}},
+	}
+	for _, tt := range tests {
+		buf := &ztest.Buffer{}
+		core := NewCore(tt.enc, buf, DebugLevel).With([]Field{
+			{Key: "age", Type: SinceType, Integer: start.UnixNano()},
+			makeInt64Field("k", 1),
+		})
+		for _, offset := range []time.Duration{time.Second, time.Minute} {
+			ent := Entry{Message: "tick", Time: start.Add(offset)}
+			require.NoError(t, core.Write(ent, []Field{
+				{Key: "left", Type: UntilType, Integer: start.Add(time.Hour).UnixNano()},
+			}), "Unexpected error writing.")
+		}
+		assert.Equal(t, tt.want, buf.Lines(), "Expected durations measured from each entry's time.")
+	}
+}
+
 func TestIOCoreSyncFail(t *testing.T) {
 	sink := &ztest.Discarder{}
 	err := errors.New("failed")
@@ -0,0 +1,277 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// RepeatCountKey is the key under which a deduplicating Core records how
+// many copies of an entry it suppressed.
+const RepeatCountKey = "repeat_count"
+
+// _dedupeEncoderConfig configures the encoder that fingerprints fields.
+var _dedupeEncoderConfig = EncoderConfig{
+	EncodeTime:     EpochNanosTimeEncoder,
+	EncodeDuration: NanosDurationEncoder,
+}
+
+// A DedupeOption configures a deduplicating Core.
+type DedupeOption interface {
+	apply(*dedupeShared)
+}
+
+type dedupeOptionFunc func(*dedupeShared)
+
+func (f dedupeOptionFunc) apply(s *dedupeShared) {
+	f(s)
+}
+
+// DedupeClock sets the Clock that schedules summary entries. It should be
+// the clock the Logger uses (see zap.WithClock). It defaults to
+// DefaultClock.
+func DedupeClock(clock Clock) DedupeOption {
+	return dedupeOptionFunc(func(s *dedupeShared) {
+		s.clock = clock
+	})
+}
+
+// NewDedupeCore wraps a Core to suppress identical entries, such as those
+// from a retry storm. Entries are identical if they have the same level,
+// logger name, message, and fields, including those added with With. The
+// first entry is written as usual; copies logged within window of it are
+// dropped. Once the window has passed, if any copies were dropped, the Core
+// writes the entry once more, timestamped with the last copy and with an
+// extra RepeatCountKey field holding the number dropped.
+//
+// Windows are measured by the entries' timestamps, and summaries are written
+// by a background goroutine checking every window. Sync writes the
+// summaries of windows still in progress without closing them. Entries above
+// ErrorLevel are never suppressed, so Panic and Fatal always log.
+//
+// The returned Core implements io.Closer; Close writes any pending summaries
+// and stops the background goroutine. If window isn't positive, the Core is
+// returned unchanged.
+func NewDedupeCore(core Core, window time.Duration, opts ...DedupeOption) Core {
+	if window <= 0 {
+		return core
+	}
+	shared := &dedupeShared{
+		window:  window,
+		clock:   DefaultClock,
+		entries: make(map[uint64]*dedupeEntry),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(shared)
+	}
+	// Start the ticker before returning, so it sees every tick of a mock
+	// Clock.
+	go shared.run(shared.clock.NewTicker(window))
+	return &dedupeCore{
+		Core:    core,
+		shared:  shared,
+		context: newJSONEncoder(_dedupeEncoderConfig, false),
+	}
+}
+
+type dedupeCore struct {
+	Core
+	shared *dedupeShared
+	// context holds the encoded fields added with With, which identify
+	// entries along with their own fields.
+	context *jsonEncoder
+}
+
+// Unwrap returns the wrapped Core.
+func (c *dedupeCore) Unwrap() Core {
+	return c.Core
+}
+
+// dedupeShared is shared by a deduplicating Core and every Core derived
+// from it with With.
+type dedupeShared struct {
+	window time.Duration
+	clock  Clock
+
+	mu      sync.Mutex
+	entries map[uint64]*dedupeEntry
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// dedupeEntry tracks the window opened by an entry.
+type dedupeEntry struct {
+	core       Core // the wrapped Core, with the entry's context
+	ent        Entry
+	fields     []Field
+	start      time.Time
+	last       time.Time // when the last copy was logged
+	suppressed int64
+}
+
+// summary returns the entry that reports the suppressed copies.
+func (e *dedupeEntry) summary() (Core, Entry, []Field) {
+	ent := e.ent
+	ent.Time = e.last
+	fields := append(e.fields[:len(e.fields):len(e.fields)], Field{
+		Key:     RepeatCountKey,
+		Type:    Int64Type,
+		Integer: e.suppressed,
+	})
+	return e.core, ent, fields
+}
+
+func (c *dedupeCore) With(fields []Field) Core {
+	context := c.context.clone()
+	context.buf.Write(c.context.buf.Bytes())
+	addFields(context, fields)
+	return &dedupeCore{
+		Core:    c.Core.With(fields),
+		shared:  c.shared,
+		context: context,
+	}
+}
+
+func (c *dedupeCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupeCore) Write(ent Entry, fields []Field) error {
+	if ent.Level > ErrorLevel {
+		return writeChecked(c.Core, ent, fields)
+	}
+	key := c.fingerprint(ent, fields)
+
+	s := c.shared
+	s.mu.Lock()
+	prev, ok := s.entries[key]
+	if ok && ent.Time.Sub(prev.start) < s.window {
+		prev.suppressed++
+		prev.last = ent.Time
+		s.mu.Unlock()
+		return nil
+	}
+	s.entries[key] = &dedupeEntry{
+		core:   c.Core,
+		ent:    Entry{Level: ent.Level, LoggerName: ent.LoggerName, Message: ent.Message, Caller: ent.Caller, Stack: ent.Stack, LazyStack: ent.LazyStack},
+		fields: append([]Field(nil), fields...),
+		start:  ent.Time,
+	}
+	s.mu.Unlock()
+
+	var err error
+	if ok && prev.suppressed > 0 {
+		err = writeChecked(prev.summary())
+	}
+	return multierr.Append(err, writeChecked(c.Core, ent, fields))
+}
+
+// fingerprint hashes everything that makes entries identical.
+func (c *dedupeCore) fingerprint(ent Entry, fields []Field) uint64 {
+	enc := c.context.clone()
+	defer enc.buf.Free()
+	addFields(enc, fields)
+
+	h := fnv.New64a()
+	h.Write([]byte{byte(ent.Level)})
+	h.Write([]byte(ent.LoggerName))
+	h.Write([]byte{0})
+	h.Write([]byte(ent.Message))
+	h.Write([]byte{0})
+	h.Write(c.context.buf.Bytes())
+	h.Write([]byte{0})
+	h.Write(enc.buf.Bytes())
+	return h.Sum64()
+}
+
+// Sync writes the summaries of windows in progress, then syncs the wrapped
+// Core.
+func (c *dedupeCore) Sync() error {
+	err := c.shared.flush(time.Time{}, true)
+	return multierr.Append(err, c.Core.Sync())
+}
+
+// Close writes pending summaries and stops the background goroutine. It's
+// safe to call Close more than once.
+func (c *dedupeCore) Close() error {
+	s := c.shared
+	s.mu.Lock()
+	closed := s.closed
+	s.closed = true
+	s.mu.Unlock()
+
+	if !closed {
+		close(s.stop)
+		<-s.done
+	}
+	return c.Sync()
+}
+
+func (s *dedupeShared) run(ticker *time.Ticker) {
+	defer close(s.done)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.flush(now, false)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flush writes the summaries of the windows that closed by now and forgets
+// them. If all is set, it also writes the summaries of the windows still in
+// progress, which remain open.
+func (s *dedupeShared) flush(now time.Time, all bool) error {
+	var summaries []*dedupeEntry
+	s.mu.Lock()
+	for key, e := range s.entries {
+		expired := !all && now.Sub(e.start) >= s.window
+		if expired {
+			delete(s.entries, key)
+		}
+		if (expired || all) && e.suppressed > 0 {
+			summary := *e
+			summaries = append(summaries, &summary)
+			e.suppressed = 0
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].start.Before(summaries[j].start) })
+	var err error
+	for _, e := range summaries {
+		err = multierr.Append(err, writeChecked(e.summary()))
+	}
+	return err
+}
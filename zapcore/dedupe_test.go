@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap/internal/ztest"
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func writeAt(core Core, t time.Time, lvl Level, msg string, fields ...Field) {
+	if ce := core.Check(Entry{Level: lvl, Time: t, Message: msg}, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+func repeatCounts(logs *observer.ObservedLogs) []interface{} {
+	var counts []interface{}
+	for _, e := range logs.AllUntimed() {
+		counts = append(counts, e.ContextMap()[RepeatCountKey])
+	}
+	return counts
+}
+
+func TestDedupeCore(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := ztest.NewMockClock(start)
+	fac, logs := observer.New(InfoLevel)
+	core := NewDedupeCore(fac, time.Second, DedupeClock(clock))
+	defer core.(io.Closer).Close()
+
+	attempt := Field{Key: "attempt", Type: Int64Type, Integer: 1}
+	child := core.With([]Field{{Key: "k", Type: StringType, String: "v"}})
+	for i := 0; i < 5; i++ {
+		writeAt(core, start, WarnLevel, "retrying", attempt)
+	}
+	writeAt(core, start, WarnLevel, "retrying", Field{Key: "attempt", Type: Int64Type, Integer: 2})
+	writeAt(core, start, ErrorLevel, "retrying", attempt)
+	writeAt(child, start, WarnLevel, "retrying", attempt)
+	writeAt(core, start, DebugLevel, "disabled")
+	writeAt(core, start.Add(500*time.Millisecond), WarnLevel, "retrying", attempt)
+	assert.Equal(t, 4, logs.Len(), "Expected copies to be suppressed.")
+
+	clock.Add(time.Second)
+	require.Eventually(t, func() bool { return logs.Len() == 5 }, time.Second, time.Millisecond, "Expected a summary once the window passed.")
+	summary := logs.All()[4]
+	assert.Equal(t, "retrying", summary.Message, "Unexpected summary message.")
+	assert.Equal(t, WarnLevel, summary.Level, "Unexpected summary level.")
+	assert.Equal(t, start.Add(500*time.Millisecond), summary.Time, "Expected the summary to be timestamped with the last copy.")
+	assert.Equal(t, map[string]interface{}{"attempt": int64(1), RepeatCountKey: int64(5)}, summary.ContextMap(), "Unexpected summary fields.")
+
+	// The window has closed, so the entry is written again.
+	writeAt(core, start.Add(time.Second), WarnLevel, "retrying", attempt)
+	assert.Equal(t, 6, logs.Len(), "Expected a new window to open.")
+}
+
+func TestDedupeCoreWindowEndsOnWrite(t *testing.T) {
+	start := time.Unix(0, 0)
+	fac, logs := observer.New(InfoLevel)
+	// The clock never ticks, so only writes and syncs close windows.
+	core := NewDedupeCore(fac, time.Minute, DedupeClock(ztest.NewMockClock(start)))
+	defer core.(io.Closer).Close()
+
+	writeAt(core, start, InfoLevel, "hello")
+	writeAt(core, start.Add(time.Second), InfoLevel, "hello")
+	writeAt(core, start.Add(time.Minute), InfoLevel, "hello")
+	assert.Equal(t, []interface{}{nil, int64(1), nil}, repeatCounts(logs), "Expected the summary before the next window's first entry.")
+
+	writeAt(core, start.Add(time.Minute+time.Second), InfoLevel, "hello")
+	require.NoError(t, core.Sync(), "Unexpected error syncing.")
+	writeAt(core, start.Add(time.Minute+2*time.Second), InfoLevel, "hello")
+	require.NoError(t, core.(io.Closer).Close(), "Unexpected error closing.")
+	assert.Equal(t, []interface{}{nil, int64(1), nil, int64(1), int64(1)}, repeatCounts(logs), "Expected Sync and Close to write pending summaries.")
+}
+
+func TestDedupeCoreNeverSuppressesPanics(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewDedupeCore(fac, time.Minute)
+	defer core.(io.Closer).Close()
+
+	now := time.Now()
+	writeAt(core, now, DPanicLevel, "bad")
+	writeAt(core, now, DPanicLevel, "bad")
+	assert.Equal(t, 2, logs.Len(), "Expected entries above ErrorLevel to be written.")
+}
+
+func TestDedupeCoreDisabled(t *testing.T) {
+	fac, _ := observer.New(InfoLevel)
+	assert.Equal(t, fac, NewDedupeCore(fac, 0), "Expected a non-positive window to disable deduplication.")
+}
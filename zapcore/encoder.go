@@ -21,15 +21,32 @@
 package zapcore
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
 
 	"github.com/blastbao/zap/buffer"
+
+	"go.uber.org/atomic"
 )
 
 // DefaultLineEnding defines the default line ending when writing logs.
 // Alternate line endings specified in EncoderConfig can override this behavior.
 const DefaultLineEnding = "\n"
 
+// Alternate record separators, for sinks that frame entries some other way
+// than by newlines.
+const (
+	// NULLineEnding terminates each entry with a NUL byte. Use it as
+	// EncoderConfig.LineEnding.
+	NULLineEnding = "\x00"
+	// JSONSeqRecordSeparator is the ASCII record separator (RS) that begins
+	// each entry of an RFC 7464 JSON text sequence. Use it as
+	// EncoderConfig.RecordPrefix, keeping the default line ending.
+	JSONSeqRecordSeparator = "\x1e"
+)
+
 // A LevelEncoder serializes a Level to a primitive type.
 type LevelEncoder func(Level, PrimitiveArrayEncoder)
 
@@ -114,13 +131,46 @@ func ISO8601TimeEncoder(t time.Time, enc PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("2006-01-02T15:04:05.000Z0700"))
 }
 
+// RFC3339NanoTimeEncoder serializes a time.Time to an RFC3339-formatted
+// string with nanosecond precision.
+func RFC3339NanoTimeEncoder(t time.Time, enc PrimitiveArrayEncoder) {
+	enc.AppendString(t.Format(time.RFC3339Nano))
+}
+
+// TimeEncoderIn returns a TimeEncoder that converts times to loc before
+// serializing them with enc, so that entries are stamped in a fixed time zone
+// regardless of the host's local zone. A nil loc leaves times unchanged.
+func TimeEncoderIn(enc TimeEncoder, loc *time.Location) TimeEncoder {
+	if loc == nil {
+		return enc
+	}
+	return func(t time.Time, arr PrimitiveArrayEncoder) {
+		enc(t.In(loc), arr)
+	}
+}
+
+// ISO8601TimeEncoderIn is like ISO8601TimeEncoder, but serializes times in
+// loc.
+func ISO8601TimeEncoderIn(loc *time.Location) TimeEncoder {
+	return TimeEncoderIn(ISO8601TimeEncoder, loc)
+}
+
+// RFC3339NanoTimeEncoderIn is like RFC3339NanoTimeEncoder, but serializes
+// times in loc.
+func RFC3339NanoTimeEncoderIn(loc *time.Location) TimeEncoder {
+	return TimeEncoderIn(RFC3339NanoTimeEncoder, loc)
+}
+
 // UnmarshalText unmarshals text to a TimeEncoder. "iso8601" and "ISO8601" are
-// unmarshaled to ISO8601TimeEncoder, "millis" is unmarshaled to
+// unmarshaled to ISO8601TimeEncoder, "rfc3339nano" and "RFC3339Nano" are
+// unmarshaled to RFC3339NanoTimeEncoder, "millis" is unmarshaled to
 // EpochMillisTimeEncoder, and anything else is unmarshaled to EpochTimeEncoder.
 func (e *TimeEncoder) UnmarshalText(text []byte) error {
 	switch string(text) {
 	case "iso8601", "ISO8601":
 		*e = ISO8601TimeEncoder
+	case "rfc3339nano", "RFC3339Nano":
+		*e = RFC3339NanoTimeEncoder
 	case "millis":
 		*e = EpochMillisTimeEncoder
 	case "nanos":
@@ -183,18 +233,89 @@ func ShortCallerEncoder(caller EntryCaller, enc PrimitiveArrayEncoder) {
 	enc.AppendString(caller.TrimmedPath())
 }
 
+// ModuleCallerEncoder serializes a caller in path/to/package/file:line
+// format, relative to the root of the caller's Go module. Unlike
+// ShortCallerEncoder, its output doesn't depend on how deeply the caller's
+// package is nested or on where the source was checked out.
+func ModuleCallerEncoder(caller EntryCaller, enc PrimitiveArrayEncoder) {
+	enc.AppendString(caller.ModulePath())
+}
+
+// PackageCallerEncoder serializes a caller as its package's import path,
+// leaving out the file and line.
+func PackageCallerEncoder(caller EntryCaller, enc PrimitiveArrayEncoder) {
+	enc.AppendString(caller.Package())
+}
+
+// BasenameCallerEncoder serializes a caller in file:line format, trimming
+// all directories from the path.
+func BasenameCallerEncoder(caller EntryCaller, enc PrimitiveArrayEncoder) {
+	enc.AppendString(caller.Basename())
+}
+
 // UnmarshalText unmarshals text to a CallerEncoder. "full" is unmarshaled to
-// FullCallerEncoder and anything else is unmarshaled to ShortCallerEncoder.
+// FullCallerEncoder, "module" to ModuleCallerEncoder, "package" to
+// PackageCallerEncoder, "basename" to BasenameCallerEncoder, and anything
+// else is unmarshaled to ShortCallerEncoder.
 func (e *CallerEncoder) UnmarshalText(text []byte) error {
 	switch string(text) {
 	case "full":
 		*e = FullCallerEncoder
+	case "module":
+		*e = ModuleCallerEncoder
+	case "package":
+		*e = PackageCallerEncoder
+	case "basename":
+		*e = BasenameCallerEncoder
 	default:
 		*e = ShortCallerEncoder
 	}
 	return nil
 }
 
+// A BinaryEncoder serializes the bytes of a Binary field to a primitive
+// type.
+type BinaryEncoder func([]byte, PrimitiveArrayEncoder)
+
+// _fingerprintBytes is how many bytes of a SHA-256 hash
+// FingerprintBinaryEncoder keeps.
+const _fingerprintBytes = 8
+
+// Base64BinaryEncoder serializes bytes as a standard base64 string. It's the
+// default.
+func Base64BinaryEncoder(b []byte, enc PrimitiveArrayEncoder) {
+	enc.AppendString(base64.StdEncoding.EncodeToString(b))
+}
+
+// HexBinaryEncoder serializes bytes as a lowercase hex string, which is
+// easier to compare with checksums, IDs, and dumps by eye.
+func HexBinaryEncoder(b []byte, enc PrimitiveArrayEncoder) {
+	enc.AppendString(hex.EncodeToString(b))
+}
+
+// FingerprintBinaryEncoder serializes bytes as "sha256:" followed by the
+// first 16 hex digits of their SHA-256 hash. It identifies payloads, such as
+// request bodies, without writing them out.
+func FingerprintBinaryEncoder(b []byte, enc PrimitiveArrayEncoder) {
+	sum := sha256.Sum256(b)
+	enc.AppendString("sha256:" + hex.EncodeToString(sum[:_fingerprintBytes]))
+}
+
+// UnmarshalText unmarshals text to a BinaryEncoder. "hex" is unmarshaled to
+// HexBinaryEncoder, "fingerprint" to FingerprintBinaryEncoder, and anything
+// else to Base64BinaryEncoder.
+func (e *BinaryEncoder) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "hex":
+		*e = HexBinaryEncoder
+	case "fingerprint":
+		*e = FingerprintBinaryEncoder
+	default:
+		*e = Base64BinaryEncoder
+	}
+	return nil
+}
+
 // A NameEncoder serializes a period-separated logger name to a primitive
 // type.
 type NameEncoder func(string, PrimitiveArrayEncoder)
@@ -252,6 +373,20 @@ type EncoderConfig struct {
 	// 输出的时间格式
 	EncodeTime     TimeEncoder     `json:"timeEncoder" yaml:"timeEncoder"`
 
+	// TimeLocation, if set, names the time zone EncodeTime serializes times
+	// in, both entry timestamps and Time fields, as understood by
+	// time.LoadLocation: "UTC", "Local", or an IANA name such as
+	// "America/New_York". It's the configuration-file equivalent of
+	// TimeEncoderIn. zap's Config rejects names that can't be loaded; the
+	// encoders in this package ignore them.
+	TimeLocation string `json:"timeLocation" yaml:"timeLocation"`
+
+	// BufferSize is the initial capacity, in bytes, of the buffers the JSON
+	// and console encoders encode entries into. It defaults to 1 KiB; raising
+	// it to fit typical entries (zapbench.Encoder reports their size) avoids
+	// growing and copying buffers for workloads with large entries.
+	BufferSize int `json:"bufferSize" yaml:"bufferSize"`
+
 	// 一般 zapcore.SecondsDurationEncoder，执行消耗的时间转化成浮点型的秒
 	EncodeDuration DurationEncoder `json:"durationEncoder" yaml:"durationEncoder"`
 
@@ -263,6 +398,170 @@ type EncoderConfig struct {
 	//
 	// 可选值。
 	EncodeName NameEncoder `json:"nameEncoder" yaml:"nameEncoder"`
+
+	// EncodeBinary is optional too. It serializes Binary fields, and the zero
+	// value falls back to Base64BinaryEncoder.
+	EncodeBinary BinaryEncoder `json:"binaryEncoder" yaml:"binaryEncoder"`
+
+	// EncodeExtra, if set, is called for every entry to add top-level fields
+	// to it, such as the host name, process ID, build version, or Kubernetes
+	// pod. The fields are written after the entry's metadata and before the
+	// fields added with Logger.With, outside any namespaces those open, so
+	// deployments can inject a standard schema without touching every place
+	// loggers are built. It can't be set from JSON or YAML.
+	EncodeExtra func(Entry, ObjectEncoder) `json:"-" yaml:"-"`
+
+	// SkipLineEnding omits the line ending after each entry, regardless of
+	// LineEnding. This suits datagram sinks and framed protocols, which
+	// delimit entries themselves. Note that the console encoder still puts
+	// stacktraces on their own lines.
+	SkipLineEnding bool `json:"skipLineEnding" yaml:"skipLineEnding"`
+	// RecordPrefix, if set, is written before each entry.
+	RecordPrefix string `json:"recordPrefix" yaml:"recordPrefix"`
+
+	// DeduplicateKeys makes the JSON encoder drop a field when a later field
+	// at the same level of the same object reuses its key, so fields added at
+	// the call site override those added with Logger.With, and both override
+	// the entry's metadata (level, time, message, and so on). The output is
+	// then accepted by strict JSON parsers. Keys inside namespaces are only
+	// compared with other keys in the same namespace, and a namespace itself
+	// is never replaced.
+	DeduplicateKeys bool `json:"deduplicateKeys" yaml:"deduplicateKeys"`
+
+	// ChecksumKey, if set, makes the JSON encoder end each entry with a field
+	// holding the CRC-32C checksum of the entry, as eight lowercase hex
+	// digits. The checksum covers the encoded object with the checksum field
+	// itself removed, excluding RecordPrefix and the line ending, so
+	// consumers can detect entries truncated or corrupted by log shippers;
+	// see VerifyChecksum. The console encoder ignores it.
+	ChecksumKey string `json:"checksumKey" yaml:"checksumKey"`
+
+	// OmitEmpty drops fields whose values are empty (see Field.IsEmpty),
+	// such as empty strings, zero numbers, and nil values, whether they're
+	// added with Logger.With or at the log site. OmitEmptyKeys does the same
+	// for fields with particular keys only. Fields nested in objects and
+	// arrays are always kept.
+	OmitEmpty     bool     `json:"omitEmpty" yaml:"omitEmpty"`
+	OmitEmptyKeys []string `json:"omitEmptyKeys" yaml:"omitEmptyKeys"`
+
+	// MaxEntryBytes, if positive, caps the size of each encoded entry
+	// (including the line ending). Cores built with NewCore re-encode larger
+	// entries according to OversizePolicy and count them in Stats, if it's
+	// set; an entry whose placeholder is still too large is dropped and
+	// reported as a write error. This keeps accidental giant payloads, such
+	// as whole HTTP bodies, away from log collectors.
+	MaxEntryBytes  int            `json:"maxEntryBytes" yaml:"maxEntryBytes"`
+	OversizePolicy OversizePolicy `json:"oversizePolicy" yaml:"oversizePolicy"`
+
+	// MaxFieldBytes, if positive, caps the size of string and byte string
+	// field values, including fields added with Logger.With and members of
+	// nested objects. Longer values are cut to at most MaxFieldBytes bytes,
+	// without splitting a UTF-8 sequence, and end with a marker such as
+	// "...(+1048576 bytes)" recording how many bytes were cut; see
+	// TruncateString. Binary values are truncated after base64 encoding.
+	// Entry metadata, such as the message, and array elements are never
+	// truncated.
+	MaxFieldBytes int `json:"maxFieldBytes" yaml:"maxFieldBytes"`
+
+	// TrailingKeys lists the keys of mandatory fields, such as sequence
+	// numbers or hosts, that must survive MaxEntryBytes. The JSON encoder
+	// writes log-site fields with these keys after the other top-level
+	// fields (and before the checksum, if any), and OversizePolicy never
+	// drops them: their size is set aside before the message is truncated or
+	// other fields are dropped, and placeholders keep them. Fields added
+	// with Logger.With are always kept.
+	TrailingKeys []string `json:"trailingKeys" yaml:"trailingKeys"`
+
+	// StrictJSON makes the JSON encoder guarantee RFC 8259 output. NaN and
+	// infinite floats, which JSON can't represent as numbers, are always
+	// written as the strings "NaN", "+Inf", and "-Inf", and the entries
+	// containing them are counted in Stats, if it's set, so the bad values
+	// can be tracked down. In strict mode, every entry is also validated
+	// before it's written; entries that aren't valid JSON are dropped and
+	// reported as write errors instead of reaching downstream parsers.
+	StrictJSON bool `json:"strictJSON" yaml:"strictJSON"`
+
+	// SchemaKey, if set, stamps every entry with a field holding the current
+	// value of SchemaValue, identifying the output format so downstream
+	// parsers can handle format migrations. The JSON encoder writes it
+	// first, before the level and time, and the console encoder writes it
+	// as the first context field. SchemaValue can be bumped at runtime with
+	// Schema.Set; entries are stamped with an empty string while it's nil.
+	SchemaKey   string  `json:"schemaKey" yaml:"schemaKey"`
+	SchemaValue *Schema `json:"schemaValue" yaml:"schemaValue"`
+
+	// KeyMapper, if set, rewrites the key of every field, including fields
+	// added with Logger.With, by EncodeExtra, and inside namespaces and
+	// nested objects, so deployments can enforce a naming convention (such
+	// as snake_case or Elastic Common Schema names) without changing call
+	// sites; see KeyMap for a simple rename table. It must be safe for
+	// concurrent use. The metadata keys configured above are written as-is,
+	// and options that select fields by key, such as OmitEmptyKeys and
+	// TrailingKeys, match the keys used at the call site. Keys mapped to the
+	// same name are merged by DeduplicateKeys.
+	KeyMapper func(string) string `json:"-" yaml:"-"`
+
+	// Stats, if set, counts the entries the encoder had to alter in order to
+	// write them. It can't be set from JSON or YAML.
+	Stats *EncodeStats `json:"-" yaml:"-"`
+}
+
+// EncodeStats counts the entries that Cores built with NewCore wrote only
+// after altering them. It's safe for concurrent use, and the zero value is
+// ready to use.
+type EncodeStats struct {
+	oversized atomic.Uint64
+	nonFinite atomic.Uint64
+}
+
+// Oversized returns the number of entries that exceeded
+// EncoderConfig.MaxEntryBytes, including those dropped because even their
+// placeholder was too large.
+func (s *EncodeStats) Oversized() uint64 {
+	return s.oversized.Load()
+}
+
+// NonFinite returns the number of entries the JSON encoder wrote with NaN or
+// infinite floats spelled as strings.
+func (s *EncodeStats) NonFinite() uint64 {
+	return s.nonFinite.Load()
+}
+
+// KeyMap returns a KeyMapper that renames the keys in m and leaves others
+// unchanged.
+func KeyMap(m map[string]string) func(string) string {
+	return func(key string) string {
+		if mapped, ok := m[key]; ok {
+			return mapped
+		}
+		return key
+	}
+}
+
+// omitsEmpty reports whether an empty field with the given key should be
+// dropped.
+func (cfg *EncoderConfig) omitsEmpty(key string) bool {
+	if cfg.OmitEmpty {
+		return true
+	}
+	for _, k := range cfg.OmitEmptyKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// appendLineEnding terminates an encoded entry according to LineEnding and
+// SkipLineEnding.
+func (cfg *EncoderConfig) appendLineEnding(buf *buffer.Buffer) {
+	switch {
+	case cfg.SkipLineEnding:
+	case cfg.LineEnding != "":
+		buf.AppendString(cfg.LineEnding)
+	default:
+		buf.AppendString(DefaultLineEnding)
+	}
 }
 
 
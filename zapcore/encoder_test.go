@@ -471,6 +471,70 @@ func TestEncoderConfiguration(t *testing.T) {
 			expectedJSON:    `{"L":"info","T":0,"N":"main","C":"foo.go:42","M":"hello","S":"fake-stack"}` + DefaultLineEnding,
 			expectedConsole: "0\tinfo\tmain\tfoo.go:42\thello\nfake-stack" + DefaultLineEnding,
 		},
+		{
+			desc: "skip line ending",
+			cfg: EncoderConfig{
+				LevelKey:       "L",
+				TimeKey:        "T",
+				MessageKey:     "M",
+				NameKey:        "N",
+				CallerKey:      "C",
+				StacktraceKey:  "S",
+				LineEnding:     "\r\n",
+				SkipLineEnding: true,
+				EncodeTime:     base.EncodeTime,
+				EncodeDuration: base.EncodeDuration,
+				EncodeLevel:    base.EncodeLevel,
+				EncodeCaller:   base.EncodeCaller,
+			},
+			expectedJSON:    `{"L":"info","T":0,"N":"main","C":"foo.go:42","M":"hello","S":"fake-stack"}`,
+			expectedConsole: "0\tinfo\tmain\tfoo.go:42\thello\nfake-stack",
+		},
+		{
+			desc: "NUL line ending",
+			cfg: EncoderConfig{
+				LevelKey:       "L",
+				TimeKey:        "T",
+				MessageKey:     "M",
+				NameKey:        "N",
+				CallerKey:      "C",
+				StacktraceKey:  "S",
+				LineEnding:     NULLineEnding,
+				EncodeTime:     base.EncodeTime,
+				EncodeDuration: base.EncodeDuration,
+				EncodeLevel:    base.EncodeLevel,
+				EncodeCaller:   base.EncodeCaller,
+			},
+			expectedJSON:    `{"L":"info","T":0,"N":"main","C":"foo.go:42","M":"hello","S":"fake-stack"}` + "\x00",
+			expectedConsole: "0\tinfo\tmain\tfoo.go:42\thello\nfake-stack\x00",
+		},
+		{
+			desc: "JSON text sequence",
+			cfg: EncoderConfig{
+				LevelKey:       "L",
+				TimeKey:        "T",
+				MessageKey:     "M",
+				NameKey:        "N",
+				CallerKey:      "C",
+				StacktraceKey:  "S",
+				RecordPrefix:   JSONSeqRecordSeparator,
+				EncodeTime:     base.EncodeTime,
+				EncodeDuration: base.EncodeDuration,
+				EncodeLevel:    base.EncodeLevel,
+				EncodeCaller:   base.EncodeCaller,
+			},
+			expectedJSON:    "\x1e" + `{"L":"info","T":0,"N":"main","C":"foo.go:42","M":"hello","S":"fake-stack"}` + "\n",
+			expectedConsole: "\x1e0\tinfo\tmain\tfoo.go:42\thello\nfake-stack\n",
+		},
+		{
+			desc: "record prefix without metadata",
+			cfg: EncoderConfig{
+				MessageKey:   "M",
+				RecordPrefix: JSONSeqRecordSeparator,
+			},
+			expectedJSON:    "\x1e" + `{"M":"hello"}` + "\n",
+			expectedConsole: "\x1ehello\n",
+		},
 	}
 
 	for i, tt := range tests {
@@ -536,6 +600,8 @@ func TestTimeEncoders(t *testing.T) {
 	}{
 		{"iso8601", "1970-01-01T00:01:40.050Z"},
 		{"ISO8601", "1970-01-01T00:01:40.050Z"},
+		{"rfc3339nano", "1970-01-01T00:01:40.050005Z"},
+		{"RFC3339Nano", "1970-01-01T00:01:40.050005Z"},
 		{"millis", 100050.005},
 		{"nanos", int64(100050005000)},
 		{"", 100.050005},
@@ -554,6 +620,62 @@ func TestTimeEncoders(t *testing.T) {
 	}
 }
 
+func TestTimeEncodersIn(t *testing.T) {
+	moment := time.Unix(100, 50005000).UTC()
+	tokyo := time.FixedZone("JST", 9*60*60)
+	tests := []struct {
+		desc     string
+		te       TimeEncoder
+		expected interface{}
+	}{
+		{"ISO8601 in zone", ISO8601TimeEncoderIn(tokyo), "1970-01-01T09:01:40.050+0900"},
+		{"RFC3339Nano in zone", RFC3339NanoTimeEncoderIn(tokyo), "1970-01-01T09:01:40.050005+09:00"},
+		{"custom encoder in zone", TimeEncoderIn(func(t time.Time, enc PrimitiveArrayEncoder) {
+			enc.AppendString(t.Format("15:04 MST"))
+		}, tokyo), "09:01 JST"},
+		{"nil location", ISO8601TimeEncoderIn(nil), "1970-01-01T00:01:40.050Z"},
+	}
+
+	for _, tt := range tests {
+		assertAppended(
+			t,
+			tt.expected,
+			func(arr ArrayEncoder) { tt.te(moment, arr) },
+			"Unexpected output serializing %v: %s.", moment, tt.desc,
+		)
+	}
+}
+
+func TestEncoderConfigTimeLocation(t *testing.T) {
+	moment := time.Unix(100, 0).In(time.FixedZone("JST", 9*60*60))
+	cfg := EncoderConfig{
+		TimeKey:      "ts",
+		EncodeTime:   ISO8601TimeEncoder,
+		TimeLocation: "UTC",
+	}
+	tests := []struct {
+		desc     string
+		enc      Encoder
+		expected string
+	}{
+		{"json", NewJSONEncoder(cfg), `{"ts":"1970-01-01T00:01:40.000Z","at":"1970-01-01T00:01:40.000Z"}`},
+		{"console", NewConsoleEncoder(cfg), "1970-01-01T00:01:40.000Z\t{\"at\": \"1970-01-01T00:01:40.000Z\"}"},
+	}
+
+	for _, tt := range tests {
+		buf, err := tt.enc.EncodeEntry(Entry{Time: moment}, []Field{{Key: "at", Type: TimeType, Integer: moment.UnixNano(), Interface: moment.Location()}})
+		require.NoError(t, err, "Unexpected error encoding entry with %s encoder.", tt.desc)
+		assert.Equal(t, tt.expected+"\n", buf.String(), "Expected times in the configured location with %s encoder.", tt.desc)
+		buf.Free()
+	}
+
+	cfg.TimeLocation = "Not/AZone"
+	buf, err := NewJSONEncoder(cfg).EncodeEntry(Entry{Time: moment}, nil)
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	assert.Equal(t, `{"ts":"1970-01-01T09:01:40.000+0900"}`+"\n", buf.String(), "Expected unknown locations to be ignored.")
+	buf.Free()
+}
+
 func TestDurationEncoders(t *testing.T) {
 	elapsed := time.Second + 500*time.Nanosecond
 	tests := []struct {
@@ -578,6 +700,30 @@ func TestDurationEncoders(t *testing.T) {
 	}
 }
 
+func TestBinaryEncoders(t *testing.T) {
+	data := []byte("zap")
+	tests := []struct {
+		name     string
+		expected interface{} // output of serializing data
+	}{
+		{"hex", "7a6170"},
+		{"fingerprint", "sha256:b4c5ae2ae961fe88"},
+		{"base64", "emFw"},
+		{"", "emFw"},
+	}
+
+	for _, tt := range tests {
+		var be BinaryEncoder
+		require.NoError(t, be.UnmarshalText([]byte(tt.name)), "Unexpected error unmarshaling %q.", tt.name)
+		assertAppended(
+			t,
+			tt.expected,
+			func(arr ArrayEncoder) { be(data, arr) },
+			"Unexpected output serializing %q with %q.", data, tt.name,
+		)
+	}
+}
+
 func TestCallerEncoders(t *testing.T) {
 	caller := EntryCaller{Defined: true, File: "/home/jack/src/github.com/foo/foo.go", Line: 42}
 	tests := []struct {
@@ -588,6 +734,9 @@ func TestCallerEncoders(t *testing.T) {
 		{"something-random", "foo/foo.go:42"},
 		{"short", "foo/foo.go:42"},
 		{"full", "/home/jack/src/github.com/foo/foo.go:42"},
+		{"module", "foo/foo.go:42"},
+		{"package", "foo"},
+		{"basename", "foo.go:42"},
 	}
 
 	for _, tt := range tests {
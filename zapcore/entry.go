@@ -21,6 +21,7 @@
 package zapcore
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -152,6 +153,26 @@ type Entry struct {
 	Message    string
 	Caller     EntryCaller
 	Stack      string
+	// LazyStack, if set, holds a captured but not yet symbolized stack. It
+	// takes the place of Stack; use Stacktrace to read whichever is present.
+	LazyStack *LazyStack
+	// Context, if set, is the context the entry was logged with. Encoders
+	// ignore it; Cores built with NewCore use it to bound writes to
+	// ContextWriters.
+	Context context.Context
+	// Sampling, if set, overrides the decisions of samplers for this entry.
+	// Loggers set it from zap.NoSample and zap.ForceSample fields.
+	Sampling SamplingOverride
+}
+
+// Stacktrace returns the entry's stack trace: Stack if it's set, and
+// otherwise the symbolized LazyStack. Encoders should call it instead of
+// reading Stack directly.
+func (e Entry) Stacktrace() string {
+	if e.Stack == "" && e.LazyStack != nil {
+		return e.LazyStack.String()
+	}
+	return e.Stack
 }
 
 // CheckWriteAction indicates what action to take after a log entry is processed.
@@ -169,7 +190,49 @@ const (
 	WriteThenFatal
 )
 
+// OnWrite implements CheckWriteHook, performing the action.
+func (a CheckWriteAction) OnWrite(ce *CheckedEntry, fields []Field) {
+	switch a {
+	case WriteThenPanic:
+		panic(PanicError{Entry: ce.Entry, Fields: append([]Field(nil), fields...)})
+	case WriteThenFatal:
+		exit.Exit()
+	}
+}
 
+// A CheckWriteHook runs after a CheckedEntry has been written, and before
+// its CheckWriteAction. Hooks let terminal behaviors compose: an entry can
+// be written, reported to a crash-reporting service by a hook, and only then
+// panic. Hooks must not retain the CheckedEntry.
+type CheckWriteHook interface {
+	OnWrite(*CheckedEntry, []Field)
+}
+
+// CheckWriteHookFunc adapts a function to a CheckWriteHook.
+type CheckWriteHookFunc func(*CheckedEntry, []Field)
+
+// OnWrite implements CheckWriteHook.
+func (f CheckWriteHookFunc) OnWrite(ce *CheckedEntry, fields []Field) {
+	f(ce, fields)
+}
+
+
+// PanicError is the value CheckedEntry.Write panics with when the entry's
+// CheckWriteAction is WriteThenPanic, as it is for entries logged with
+// Logger.Panic (and Logger.DPanic in development). It carries the entry and
+// the fields passed at the log site, so recover() handlers can re-log or
+// report the full context; fields added with Logger.With have already been
+// encoded by the Cores and aren't included.
+type PanicError struct {
+	Entry  Entry
+	Fields []Field
+}
+
+// Error returns the entry's message, so an unrecovered panic reads as it did
+// when zap panicked with the message itself.
+func (e PanicError) Error() string {
+	return e.Entry.Message
+}
 
 // CheckedEntry is an Entry together with a collection of Cores that have already agreed to log it.
 //
@@ -178,8 +241,12 @@ const (
 type CheckedEntry struct {
 	Entry
 	ErrorOutput WriteSyncer
+	// ErrorSink, if set, is also called with errors writing the entry, so
+	// that applications can handle them programmatically.
+	ErrorSink func(error, Entry)
 	dirty       bool // best-effort detection of pool misuse
 	should      CheckWriteAction
+	hooks       []CheckWriteHook
 	cores       []Core
 }
 
@@ -189,10 +256,16 @@ func (ce *CheckedEntry) reset() {
 	ce.Entry = Entry{}
 	// 重置 ce.ErrorOutput
 	ce.ErrorOutput = nil
+	ce.ErrorSink = nil
 	// dirty 是用来标识该 CheckedEntry 是不是一个脏数据，置为 false
 	ce.dirty = false
 	//
 	ce.should = WriteThenNoop
+	// 清空 hooks，同样不持有引用
+	for i := range ce.hooks {
+		ce.hooks[i] = nil
+	}
+	ce.hooks = ce.hooks[:0]
 	// 一个 CheckedEntry 上可能绑定多个不同的 cores ，这里把所有的 cores 都置空，并使切片长度归零。
 	for i := range ce.cores {
 		// don't keep references to cores
@@ -203,7 +276,8 @@ func (ce *CheckedEntry) reset() {
 
 // Write writes the entry to the stored Cores, returns any errors,
 // and returns the CheckedEntry reference to a pool for immediate re-use.
-// Finally, it executes any required CheckWriteAction.
+// Finally, it runs any CheckWriteHooks, in the order they were added, and
+// then executes any required CheckWriteAction.
 //
 //
 //
@@ -244,16 +318,28 @@ func (ce *CheckedEntry) Write(fields ...Field) {
 		err = multierr.Append(err, ce.cores[i].Write(ce.Entry, fields))
 	}
 
-	// 如果 err 不为 nil ，则把汇总后的错误信息写到错误输出中
+	// 如果 err 不为 nil ，则把汇总后的错误信息写到错误输出中，并交给 ErrorSink 处理
 	if ce.ErrorOutput != nil {
 		if err != nil {
 			fmt.Fprintf(ce.ErrorOutput, "%v write error: %v\n", time.Now(), err)
 			ce.ErrorOutput.Sync()
 		}
 	}
+	if err != nil && ce.ErrorSink != nil {
+		ce.ErrorSink(err, ce.Entry)
+	}
 
-	// 获取 ce.should 和 ce.Message 字段
-	should, msg := ce.should, ce.Message
+	// 依次执行 hooks（例如上报崩溃信息），它们总在 panic 或 exit 之前运行。
+	for _, hook := range ce.hooks {
+		hook.OnWrite(ce, fields)
+	}
+
+	// 获取 ce.should，需要 panic 时把 Entry 和字段一起放进 PanicError
+	should := ce.should
+	var panicErr PanicError
+	if should == WriteThenPanic {
+		panicErr = PanicError{Entry: ce.Entry, Fields: append([]Field(nil), fields...)}
+	}
 
 	// 至此，ce 使用完毕，将其放回对象池中，以备下次使用
 	putCheckedEntry(ce)
@@ -262,7 +348,7 @@ func (ce *CheckedEntry) Write(fields ...Field) {
 	// 但对于 Panic 和 Fatal 级别的日志，分别需要 `调用 panic 方法` 或者 `进程直接无条件退出`。
 	switch should {
 	case WriteThenPanic:
-		panic(msg)
+		panic(panicErr)
 	case WriteThenFatal:
 		exit.Exit()
 	}
@@ -299,3 +385,15 @@ func (ce *CheckedEntry) Should(ent Entry, should CheckWriteAction) *CheckedEntry
 	ce.should = should
 	return ce
 }
+
+// After adds a CheckWriteHook to run after this CheckedEntry is written and
+// before its CheckWriteAction. Hooks run in the order they're added. Like
+// AddCore, it's safe to call on nil CheckedEntry references.
+func (ce *CheckedEntry) After(ent Entry, hook CheckWriteHook) *CheckedEntry {
+	if ce == nil {
+		ce = getCheckedEntry()
+		ce.Entry = ent
+	}
+	ce.hooks = append(ce.hooks, hook)
+	return ce
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// _callerPackages caches the package import path of each caller's PC.
+	_callerPackages sync.Map
+
+	_buildInfoOnce sync.Once
+	_mainPackage   string
+	_modulePaths   []string
+)
+
+// loadBuildInfo reads the binary's build information (see readBuildInfo)
+// once, sorting the module paths longest first.
+func loadBuildInfo() {
+	_buildInfoOnce.Do(func() {
+		pkg, paths, ok := readBuildInfo()
+		if !ok {
+			return
+		}
+		sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+		_mainPackage, _modulePaths = pkg, paths
+	})
+}
+
+// modulePaths lists the paths of the modules in the binary, longest first,
+// as reported by its build information.
+func modulePaths() []string {
+	loadBuildInfo()
+	return _modulePaths
+}
+
+// Basename returns a file:line description of the caller, without any
+// directories. Both forward slashes and backslashes separate directories,
+// so paths recorded on Windows are handled too.
+func (ec EntryCaller) Basename() string {
+	if !ec.Defined {
+		return "undefined"
+	}
+	return basename(ec.File) + ":" + strconv.Itoa(ec.Line)
+}
+
+// Package returns the import path of the caller's package, such as
+// "github.com/blastbao/zap/zapcore", without a file or line. If the caller's
+// function can't be identified, it falls back to the name of the directory
+// holding the caller's file.
+func (ec EntryCaller) Package() string {
+	if !ec.Defined {
+		return "undefined"
+	}
+	return ec.pkg()
+}
+
+// ModulePath returns a path/to/file.go:line description of the caller,
+// relative to the root of the Go module that contains it. Files in the
+// standard library, or in modules the binary has no build information for,
+// are described by their package's import path instead, like
+// "net/http/server.go:100".
+func (ec EntryCaller) ModulePath() string {
+	if !ec.Defined {
+		return "undefined"
+	}
+	pkg := ec.pkg()
+	for _, mod := range modulePaths() {
+		if pkg == mod {
+			pkg = ""
+			break
+		}
+		if strings.HasPrefix(pkg, mod+"/") {
+			pkg = pkg[len(mod)+1:]
+			break
+		}
+	}
+	file := basename(ec.File)
+	if pkg != "" {
+		file = pkg + "/" + file
+	}
+	return file + ":" + strconv.Itoa(ec.Line)
+}
+
+func (ec EntryCaller) pkg() string {
+	if ec.PC == 0 {
+		return dirBase(ec.File)
+	}
+	if pkg, ok := _callerPackages.Load(ec.PC); ok {
+		return pkg.(string)
+	}
+	pkg := dirBase(ec.File)
+	if fn := runtime.FuncForPC(ec.PC); fn != nil {
+		pkg = funcPackage(fn.Name(), pkg)
+	}
+	_callerPackages.Store(ec.PC, pkg)
+	return pkg
+}
+
+// funcPackage extracts the package import path from a function name as
+// reported by the runtime, such as "gopkg.in/yaml.v2.(*decoder).unmarshal".
+// Since package names may contain dots, dir (the name of the directory
+// holding the function's file) is used to tell where the package ends.
+func funcPackage(name, dir string) string {
+	slash := strings.LastIndexByte(name, '/') + 1
+	rest := name[slash:]
+	switch {
+	case dir != "" && strings.HasPrefix(rest, dir+"."):
+		rest = dir
+	case strings.IndexByte(rest, '.') >= 0:
+		rest = rest[:strings.IndexByte(rest, '.')]
+	}
+	pkg := name[:slash] + rest
+	if pkg == "main" {
+		if loadBuildInfo(); _mainPackage != "" {
+			return _mainPackage
+		}
+	}
+	return pkg
+}
+
+func isPathSeparator(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+// basename returns the last element of a slash- or backslash-separated path.
+func basename(file string) string {
+	for i := len(file) - 1; i >= 0; i-- {
+		if isPathSeparator(file[i]) {
+			return file[i+1:]
+		}
+	}
+	return file
+}
+
+// dirBase returns the name of the directory holding file, or "" if file has
+// no directory.
+func dirBase(file string) string {
+	end := len(file) - len(basename(file)) - 1
+	if end <= 0 {
+		return ""
+	}
+	dir := file[:end]
+	name := basename(dir)
+	if strings.HasSuffix(name, ":") {
+		// A Windows volume, like C:.
+		return ""
+	}
+	if at := strings.IndexByte(name, '@'); at >= 0 {
+		// The root of a module in the module cache, like yaml.v2@v2.4.0.
+		name = name[:at]
+	}
+	return name
+}
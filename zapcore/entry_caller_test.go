@@ -0,0 +1,224 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryCallerLayouts(t *testing.T) {
+	tests := []struct {
+		desc     string
+		caller   EntryCaller
+		module   string
+		pkg      string
+		basename string
+	}{
+		{
+			desc:     "undefined",
+			caller:   EntryCaller{File: "/path/to/foo.go", Line: 42},
+			module:   "undefined",
+			pkg:      "undefined",
+			basename: "undefined",
+		},
+		{
+			desc:     "unix path",
+			caller:   EntryCaller{Defined: true, File: "/home/jack/src/mod/pkg/foo.go", Line: 42},
+			module:   "pkg/foo.go:42",
+			pkg:      "pkg",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "windows path",
+			caller:   EntryCaller{Defined: true, File: `C:\src\mod\pkg\foo.go`, Line: 42},
+			module:   "pkg/foo.go:42",
+			pkg:      "pkg",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "windows path with forward slashes",
+			caller:   EntryCaller{Defined: true, File: "C:/src/mod/pkg/foo.go", Line: 42},
+			module:   "pkg/foo.go:42",
+			pkg:      "pkg",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "mixed separators",
+			caller:   EntryCaller{Defined: true, File: `C:\src/mod\pkg/foo.go`, Line: 42},
+			module:   "pkg/foo.go:42",
+			pkg:      "pkg",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "UNC path",
+			caller:   EntryCaller{Defined: true, File: `\\server\share\pkg\foo.go`, Line: 42},
+			module:   "pkg/foo.go:42",
+			pkg:      "pkg",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "file at the root of a volume",
+			caller:   EntryCaller{Defined: true, File: `C:\foo.go`, Line: 42},
+			module:   "foo.go:42",
+			pkg:      "",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "file at the root of the filesystem",
+			caller:   EntryCaller{Defined: true, File: "/foo.go", Line: 42},
+			module:   "foo.go:42",
+			pkg:      "",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "bare file",
+			caller:   EntryCaller{Defined: true, File: "foo.go", Line: 42},
+			module:   "foo.go:42",
+			pkg:      "",
+			basename: "foo.go:42",
+		},
+		{
+			desc:     "module cache",
+			caller:   EntryCaller{Defined: true, File: "/go/pkg/mod/gopkg.in/yaml.v2@v2.4.0/decode.go", Line: 7},
+			module:   "yaml.v2/decode.go:7",
+			pkg:      "yaml.v2",
+			basename: "decode.go:7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.module, tt.caller.ModulePath(), "Unexpected ModulePath.")
+			assert.Equal(t, tt.pkg, tt.caller.Package(), "Unexpected Package.")
+			assert.Equal(t, tt.basename, tt.caller.Basename(), "Unexpected Basename.")
+		})
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{"github.com/blastbao/zap/zapcore.TestFuncPackage", "zapcore", "github.com/blastbao/zap/zapcore"},
+		{"github.com/blastbao/zap/zapcore.(*ioCore).Write", "zapcore", "github.com/blastbao/zap/zapcore"},
+		{"github.com/blastbao/zap/zapcore.TestFuncPackage.func1", "", "github.com/blastbao/zap/zapcore"},
+		{"gopkg.in/yaml.v2.(*decoder).unmarshal", "yaml.v2", "gopkg.in/yaml.v2"},
+		{"net/http.(*conn).serve", "http", "net/http"},
+		{"fmt.Sprintf", "fmt", "fmt"},
+		{"example.com/mod/v2.New", "v2", "example.com/mod/v2"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, funcPackage(tt.name, tt.dir), "Unexpected package for function %q.", tt.name)
+	}
+}
+
+func TestEntryCallerFromRuntime(t *testing.T) {
+	caller := NewEntryCaller(runtime.Caller(0))
+	require.True(t, caller.Defined, "Expected to find the caller.")
+	line := ":" + strconv.Itoa(caller.Line)
+
+	pkg := caller.Package()
+	assert.True(t, strings.HasSuffix(pkg, "/zapcore"), "Unexpected package %q.", pkg)
+	assert.Equal(t, "entry_caller_test.go"+line, caller.Basename(), "Unexpected Basename.")
+	module := caller.ModulePath()
+	assert.True(t, strings.HasSuffix(module, "zapcore/entry_caller_test.go"+line), "Unexpected ModulePath %q.", module)
+	assert.False(t, strings.HasPrefix(module, "/"), "Expected a relative ModulePath, got %q.", module)
+
+	// Cached packages are reused.
+	assert.Equal(t, pkg, caller.Package(), "Unexpected package on second lookup.")
+}
+
+// quickPath is a file path built from random segments and separators.
+type quickPath struct {
+	File string
+	Line int
+}
+
+func (quickPath) Generate(r *rand.Rand, size int) reflect.Value {
+	const chars = `abcXYZ019._-@: /\`
+	var b strings.Builder
+	n := r.Intn(size + 1)
+	for i := 0; i < n; i++ {
+		b.WriteByte(chars[r.Intn(len(chars))])
+	}
+	return reflect.ValueOf(quickPath{File: b.String(), Line: r.Intn(10000)})
+}
+
+func (p quickPath) caller() EntryCaller {
+	return EntryCaller{Defined: true, File: p.File, Line: p.Line}
+}
+
+func TestEntryCallerProperties(t *testing.T) {
+	hasSeparator := func(s string) bool { return strings.ContainsAny(s, `/\`) }
+
+	t.Run("basename has no directories", func(t *testing.T) {
+		prop := func(p quickPath) bool {
+			suffix := ":" + strconv.Itoa(p.Line)
+			base := p.caller().Basename()
+			return strings.HasSuffix(base, suffix) &&
+				!hasSeparator(strings.TrimSuffix(base, suffix)) &&
+				strings.HasSuffix(p.File, strings.TrimSuffix(base, suffix))
+		}
+		require.NoError(t, quick.Check(prop, nil))
+	})
+
+	t.Run("package is a single directory", func(t *testing.T) {
+		prop := func(p quickPath) bool {
+			pkg := p.caller().Package()
+			return !hasSeparator(pkg) && strings.Contains(p.File, pkg)
+		}
+		require.NoError(t, quick.Check(prop, nil))
+	})
+
+	t.Run("module path ends with the basename", func(t *testing.T) {
+		prop := func(p quickPath) bool {
+			c := p.caller()
+			module := c.ModulePath()
+			return !strings.Contains(module, `\`) &&
+				(module == c.Basename() || strings.HasSuffix(module, "/"+c.Basename()))
+		}
+		require.NoError(t, quick.Check(prop, nil))
+	})
+
+	t.Run("separators are interchangeable", func(t *testing.T) {
+		prop := func(p quickPath) bool {
+			unix := p.caller()
+			windows := unix
+			windows.File = strings.Replace(unix.File, "/", `\`, -1)
+			return unix.ModulePath() == windows.ModulePath() &&
+				unix.Package() == windows.Package() &&
+				unix.Basename() == windows.Basename()
+		}
+		require.NoError(t, quick.Check(prop, nil))
+	})
+}
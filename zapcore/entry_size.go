@@ -0,0 +1,295 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/blastbao/zap/buffer"
+)
+
+const (
+	// OversizedMessage replaces the message of entries that exceed
+	// EncoderConfig.MaxEntryBytes under the ReplaceWithPlaceholder policy.
+	OversizedMessage = "entry too large"
+
+	// _truncatedMarker ends messages shortened by the TruncateMessage policy.
+	_truncatedMarker = "...(truncated)"
+)
+
+// An OversizePolicy determines what a Core does with an entry that's larger
+// than EncoderConfig.MaxEntryBytes once encoded.
+type OversizePolicy int8
+
+const (
+	// TruncateMessage shortens the entry's message.
+	TruncateMessage OversizePolicy = iota
+	// DropLargestFields drops the largest of the fields added at the log
+	// site, recording how many were dropped under DroppedFieldsKey.
+	DropLargestFields
+	// ReplaceWithPlaceholder replaces the message with OversizedMessage and
+	// drops the fields added at the log site, recording the original size
+	// under "entryBytes".
+	ReplaceWithPlaceholder
+)
+
+// String returns a lower-camel-case representation of the policy.
+func (p OversizePolicy) String() string {
+	switch p {
+	case TruncateMessage:
+		return "truncateMessage"
+	case DropLargestFields:
+		return "dropLargestFields"
+	case ReplaceWithPlaceholder:
+		return "placeholder"
+	default:
+		return fmt.Sprintf("OversizePolicy(%d)", p)
+	}
+}
+
+// MarshalText marshals the OversizePolicy to text.
+func (p OversizePolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText unmarshals "truncateMessage", "dropLargestFields", or
+// "placeholder" to an OversizePolicy.
+func (p *OversizePolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "truncateMessage", "":
+		*p = TruncateMessage
+	case "dropLargestFields":
+		*p = DropLargestFields
+	case "placeholder":
+		*p = ReplaceWithPlaceholder
+	default:
+		return fmt.Errorf("unrecognized oversize policy: %q", text)
+	}
+	return nil
+}
+
+// entrySizeLimiter is implemented by encoders that limit the size of
+// encoded entries.
+type entrySizeLimiter interface {
+	entrySizeLimit() (int, OversizePolicy)
+	trailingKeys() []string
+	encodeStats() *EncodeStats
+}
+
+func (cfg *EncoderConfig) entrySizeLimit() (int, OversizePolicy) {
+	return cfg.MaxEntryBytes, cfg.OversizePolicy
+}
+
+func (cfg *EncoderConfig) trailingKeys() []string {
+	return cfg.TrailingKeys
+}
+
+func (cfg *EncoderConfig) encodeStats() *EncodeStats {
+	return cfg.Stats
+}
+
+// limitEntrySize applies the encoder's MaxEntryBytes, if any, to an encoded
+// entry. If the entry is too large, it's re-encoded according to the
+// encoder's OversizePolicy, falling back to a placeholder if that's not
+// enough, and counted in the encoder's EncodeStats. If even the smallest
+// placeholder is too large, the entry is dropped and limitEntrySize returns
+// a nil buffer and an error.
+func limitEntrySize(enc Encoder, ent Entry, fields []Field, buf *buffer.Buffer) (*buffer.Buffer, error) {
+	l, ok := enc.(entrySizeLimiter)
+	if !ok {
+		return buf, nil
+	}
+	max, policy := l.entrySizeLimit()
+	size := buf.Len()
+	if max <= 0 || size <= max {
+		return buf, nil
+	}
+	buf.Free()
+	if stats := l.encodeStats(); stats != nil {
+		stats.oversized.Inc()
+	}
+
+	var err error
+	switch policy {
+	case TruncateMessage:
+		ent.Message = truncateMessage(ent.Message, len(ent.Message)-(size-max))
+	case DropLargestFields:
+		fields, _ = dropLargestFields(enc, fields, size-max, l.trailingKeys())
+	}
+	if policy != ReplaceWithPlaceholder {
+		if buf, err = enc.EncodeEntry(ent, fields); buf == nil {
+			return nil, err
+		}
+		if buf.Len() <= max {
+			return buf, nil
+		}
+		buf.Free()
+	}
+
+	// Try the placeholder with the trailing fields first, then without them
+	// or the stack trace.
+	ent.Message = OversizedMessage
+	entryBytes := Field{Key: "entryBytes", Type: Int64Type, Integer: int64(size)}
+	placeholder := append(trailingFields(fields, l.trailingKeys()), entryBytes)
+	for _, minimal := range []bool{false, true} {
+		if minimal {
+			if len(placeholder) == 1 && ent.Stack == "" && ent.LazyStack == nil {
+				break
+			}
+			placeholder = placeholder[len(placeholder)-1:]
+			ent.Stack, ent.LazyStack = "", nil
+		}
+		if buf, err = enc.EncodeEntry(ent, placeholder); buf == nil {
+			return nil, err
+		}
+		if buf.Len() <= max {
+			return buf, nil
+		}
+		buf.Free()
+	}
+	return nil, fmt.Errorf("dropped an entry of %d bytes: it exceeded MaxEntryBytes (%d), and so did its placeholder", size, max)
+}
+
+// truncateMessage shortens msg to at most n bytes, including a marker, without
+// splitting a UTF-8 sequence.
+func truncateMessage(msg string, n int) string {
+	n -= len(_truncatedMarker)
+	if n < 0 {
+		n = 0
+	}
+	if n >= len(msg) {
+		return msg
+	}
+	for n > 0 && !utf8.RuneStart(msg[n]) {
+		n--
+	}
+	return msg[:n] + _truncatedMarker
+}
+
+// dropLargestFields drops fields, largest first, until roughly excess bytes
+// have been removed. Namespaces are kept, since dropping them would move
+// the fields that follow, and so are fields with trailing keys. It returns
+// the remaining fields and the number dropped.
+func dropLargestFields(enc Encoder, fields []Field, excess int, trailing []string) ([]Field, int) {
+	type sized struct {
+		i, size int
+	}
+	base, _ := enc.EncodeEntry(Entry{}, nil)
+	if base == nil {
+		return fields, 0
+	}
+	baseLen := base.Len()
+	base.Free()
+
+	sizes := make([]sized, 0, len(fields))
+	for i := range fields {
+		if fields[i].Type == NamespaceType || isTrailingKey(fields[i].Key, trailing) {
+			continue
+		}
+		buf, _ := enc.EncodeEntry(Entry{}, fields[i:i+1])
+		if buf == nil {
+			continue
+		}
+		sizes = append(sizes, sized{i, buf.Len() - baseLen})
+		buf.Free()
+	}
+	sort.SliceStable(sizes, func(a, b int) bool { return sizes[a].size > sizes[b].size })
+
+	drop := make(map[int]bool)
+	for _, s := range sizes {
+		if excess <= 0 {
+			break
+		}
+		drop[s.i] = true
+		excess -= s.size
+	}
+	kept := make([]Field, 0, len(fields)-len(drop)+1)
+	for i := range fields {
+		if !drop[i] {
+			kept = append(kept, fields[i])
+		}
+	}
+	if len(drop) > 0 {
+		kept = append(kept, Field{Key: DroppedFieldsKey, Type: Int64Type, Integer: int64(len(drop))})
+	}
+	return kept, len(drop)
+}
+
+// topLevelFields returns the number of fields before the first namespace.
+func topLevelFields(fields []Field) int {
+	for i := range fields {
+		if fields[i].Type == NamespaceType {
+			return i
+		}
+	}
+	return len(fields)
+}
+
+func isTrailingKey(key string, trailing []string) bool {
+	if key == "" {
+		return false
+	}
+	for _, k := range trailing {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// moveTrailingFields moves the top-level fields with trailing keys after the
+// other top-level fields, keeping their order. Fields are only copied if
+// they need to be reordered.
+func moveTrailingFields(fields []Field, trailing []string) []Field {
+	top := topLevelFields(fields)
+	reorder, seen := false, false
+	for i := 0; i < top && !reorder; i++ {
+		if isTrailingKey(fields[i].Key, trailing) {
+			seen = true
+		} else if seen {
+			reorder = true
+		}
+	}
+	if !reorder {
+		return fields
+	}
+	moved := make([]Field, 0, len(fields))
+	for i := 0; i < top; i++ {
+		if !isTrailingKey(fields[i].Key, trailing) {
+			moved = append(moved, fields[i])
+		}
+	}
+	moved = append(moved, trailingFields(fields, trailing)...)
+	return append(moved, fields[top:]...)
+}
+
+// trailingFields returns the top-level fields with trailing keys.
+func trailingFields(fields []Field, trailing []string) []Field {
+	var kept []Field
+	for i, top := 0, topLevelFields(fields); i < top; i++ {
+		if isTrailingKey(fields[i].Key, trailing) {
+			kept = append(kept, fields[i])
+		}
+	}
+	return kept
+}
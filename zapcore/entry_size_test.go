@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap/internal/ztest"
+	. "github.com/blastbao/zap/zapcore"
+)
+
+func TestMaxEntryBytes(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	tests := []struct {
+		desc      string
+		policy    OversizePolicy
+		msg       string
+		fields    []Field
+		want      string
+		oversized bool
+	}{
+		{
+			desc:   "under the limit",
+			policy: TruncateMessage,
+			msg:    "small",
+			want:   `{"msg":"small"}`,
+		},
+		{
+			desc:      "truncate message",
+			policy:    TruncateMessage,
+			msg:       big,
+			want:      `{"msg":"` + strings.Repeat("x", 39) + `...(truncated)"}`,
+			oversized: true,
+		},
+		{
+			desc:      "truncate message falls back to placeholder",
+			policy:    TruncateMessage,
+			msg:       "small",
+			fields:    []Field{{Key: "body", Type: StringType, String: big}},
+			want:      `{"msg":"entry too large","entryBytes":126}`,
+			oversized: true,
+		},
+		{
+			desc:   "drop largest fields",
+			policy: DropLargestFields,
+			msg:    "small",
+			fields: []Field{
+				{Key: "a", Type: StringType, String: "a"},
+				{Key: "body", Type: StringType, String: big},
+				{Key: "b", Type: StringType, String: "b"},
+			},
+			want:      `{"msg":"small","a":"a","b":"b","droppedFields":1}`,
+			oversized: true,
+		},
+		{
+			desc:      "placeholder",
+			policy:    ReplaceWithPlaceholder,
+			msg:       big,
+			want:      `{"msg":"entry too large","entryBytes":111}`,
+			oversized: true,
+		},
+	}
+
+	for _, tt := range tests {
+		buf := &ztest.Buffer{}
+		stats := &EncodeStats{}
+		core := NewCore(NewJSONEncoder(EncoderConfig{
+			MessageKey:     "msg",
+			MaxEntryBytes:  64,
+			OversizePolicy: tt.policy,
+			Stats:          stats,
+		}), buf, DebugLevel)
+
+		err := core.Write(Entry{Level: InfoLevel, Message: tt.msg}, tt.fields)
+		assert.NoError(t, err, "%s: unexpected error.", tt.desc)
+		assert.Equal(t, tt.want, buf.Stripped(), "%s: unexpected output.", tt.desc)
+		assert.True(t, len(buf.String()) <= 64, "%s: expected the entry to fit.", tt.desc)
+		if tt.oversized {
+			assert.Equal(t, uint64(1), stats.Oversized(), "%s: expected the entry to be counted.", tt.desc)
+		} else {
+			assert.Zero(t, stats.Oversized(), "%s: unexpected oversized count.", tt.desc)
+		}
+	}
+}
+
+func TestMaxEntryBytesPlaceholderTooLarge(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	stats := &EncodeStats{}
+	enc := NewJSONEncoder(EncoderConfig{
+		MessageKey:     "msg",
+		StacktraceKey:  "stack",
+		MaxEntryBytes:  64,
+		OversizePolicy: ReplaceWithPlaceholder,
+		TrailingKeys:   []string{"trace"},
+		Stats:          stats,
+	})
+
+	// The trailing field and the stack trace are dropped from the
+	// placeholder if they don't fit.
+	buf := &ztest.Buffer{}
+	core := NewCore(enc, buf, DebugLevel)
+	trace := Field{Key: "trace", Type: StringType, String: big}
+	require.NoError(t, core.Write(Entry{Message: big, Stack: big}, []Field{trace}), "Unexpected error.")
+	assert.Equal(t, `{"msg":"entry too large","entryBytes":333}`, buf.Stripped(), "Unexpected placeholder.")
+
+	// If the context added with With doesn't fit either, the entry is
+	// dropped.
+	buf = &ztest.Buffer{}
+	core = NewCore(enc, buf, DebugLevel).With([]Field{{Key: "ctx", Type: StringType, String: big}})
+	err := core.Write(Entry{Message: big}, nil)
+	if assert.Error(t, err, "Expected an error dropping the entry.") {
+		assert.Contains(t, err.Error(), "and so did its placeholder", "Unexpected error message.")
+	}
+	assert.Empty(t, buf.String(), "Expected the entry to be dropped.")
+	assert.Equal(t, uint64(2), stats.Oversized(), "Unexpected oversized count.")
+}
+
+func TestTruncateMessageKeepsUTF8(t *testing.T) {
+	buf := &ztest.Buffer{}
+	core := NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg", MaxEntryBytes: 32}), buf, DebugLevel)
+	core.Write(Entry{Message: strings.Repeat("é", 20)}, nil)
+	assert.Equal(t, `{"msg":"ééé...(truncated)"}`, buf.Stripped(), "Expected truncation on a rune boundary.")
+}
+
+func TestOversizePolicyText(t *testing.T) {
+	for _, p := range []OversizePolicy{TruncateMessage, DropLargestFields, ReplaceWithPlaceholder} {
+		text, err := p.MarshalText()
+		require.NoError(t, err, "Unexpected error marshaling %v.", p)
+		var got OversizePolicy
+		require.NoError(t, got.UnmarshalText(text), "Unexpected error unmarshaling %q.", text)
+		assert.Equal(t, p, got, "Expected %v to round-trip.", p)
+	}
+
+	var p OversizePolicy
+	assert.Error(t, p.UnmarshalText([]byte("shrink")), "Expected an error for an unknown policy.")
+	assert.Equal(t, "OversizePolicy(9)", OversizePolicy(9).String(), "Unexpected string for an unknown policy.")
+}
+
+func TestTrailingKeys(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	seq := Field{Key: "seq", Type: Int64Type, Integer: 7}
+	tests := []struct {
+		desc   string
+		policy OversizePolicy
+		msg    string
+		fields []Field
+		want   string
+	}{
+		{
+			desc:   "moved after other fields",
+			policy: TruncateMessage,
+			msg:    "small",
+			fields: []Field{
+				seq,
+				{Key: "a", Type: StringType, String: "a"},
+				{Key: "ns", Type: NamespaceType},
+				{Key: "b", Type: StringType, String: "b"},
+			},
+			want: `{"msg":"small","a":"a","seq":7,"ns":{"b":"b"}}`,
+		},
+		{
+			desc:   "kept by drop largest fields",
+			policy: DropLargestFields,
+			msg:    "small",
+			fields: []Field{
+				seq,
+				{Key: "body", Type: StringType, String: big},
+			},
+			want: `{"msg":"small","droppedFields":1,"seq":7}`,
+		},
+		{
+			desc:   "kept by placeholder",
+			policy: ReplaceWithPlaceholder,
+			msg:    big,
+			fields: []Field{seq},
+			want:   `{"msg":"entry too large","entryBytes":119,"seq":7}`,
+		},
+	}
+
+	for _, tt := range tests {
+		buf := &ztest.Buffer{}
+		core := NewCore(NewJSONEncoder(EncoderConfig{
+			MessageKey:     "msg",
+			MaxEntryBytes:  64,
+			OversizePolicy: tt.policy,
+			TrailingKeys:   []string{"seq"},
+		}), buf, DebugLevel)
+
+		core.Write(Entry{Level: InfoLevel, Message: tt.msg}, tt.fields)
+		assert.Equal(t, tt.want, buf.Stripped(), "%s: unexpected output.", tt.desc)
+	}
+}
@@ -21,6 +21,7 @@
 package zapcore
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 
@@ -93,8 +94,12 @@ func TestCheckedEntryWrite(t *testing.T) {
 	assert.NotPanics(t, func() { ce.Write() }, "Unexpected panic writing nil CheckedEntry.")
 
 	// WriteThenPanic
-	ce = ce.Should(Entry{}, WriteThenPanic)
-	assert.Panics(t, func() { ce.Write() }, "Expected to panic when WriteThenPanic is set.")
+	ce = ce.Should(Entry{Level: PanicLevel, Message: "boom"}, WriteThenPanic)
+	fields := []Field{{Key: "k", Type: StringType, String: "v"}}
+	assert.Equal(t, PanicError{
+		Entry:  Entry{Level: PanicLevel, Message: "boom"},
+		Fields: fields,
+	}, recoverValue(func() { ce.Write(fields...) }), "Expected to panic with the entry and fields when WriteThenPanic is set.")
 	ce.reset()
 
 	// WriteThenFatal
@@ -105,3 +110,72 @@ func TestCheckedEntryWrite(t *testing.T) {
 	assert.True(t, stub.Exited, "Expected to exit when WriteThenFatal is set.")
 	ce.reset()
 }
+
+// recoverValue calls f and returns the value it panics with.
+func recoverValue(f func()) (r interface{}) {
+	defer func() { r = recover() }()
+	f()
+	return nil
+}
+
+func TestPanicError(t *testing.T) {
+	var err error = PanicError{Entry: Entry{Message: "boom"}}
+	assert.EqualError(t, err, "boom", "Expected the message as the error string.")
+}
+
+func TestCheckedEntryHooks(t *testing.T) {
+	var calls []string
+	hook := func(name string) CheckWriteHook {
+		return CheckWriteHookFunc(func(ce *CheckedEntry, fields []Field) {
+			calls = append(calls, fmt.Sprintf("%s:%s:%d", name, ce.Message, len(fields)))
+		})
+	}
+
+	var ce *CheckedEntry
+	ce = ce.After(Entry{Message: "boom"}, hook("report"))
+	ce = ce.Should(Entry{}, WriteThenPanic)
+	ce = ce.After(Entry{}, hook("flush"))
+	assert.Panics(t, func() { ce.Write(Field{Type: SkipType}) }, "Expected to panic after running hooks.")
+	assert.Equal(t, []string{"report:boom:1", "flush:boom:1"}, calls, "Expected hooks to run in order before panicking.")
+
+	ce = getCheckedEntry()
+	assert.Equal(t, 0, len(ce.hooks), "Expected pooled CheckedEntries to have no hooks.")
+	putCheckedEntry(ce)
+
+	// CheckWriteActions are hooks too.
+	stub := exit.WithStub(func() {
+		WriteThenFatal.OnWrite(&CheckedEntry{}, nil)
+	})
+	assert.True(t, stub.Exited, "Expected WriteThenFatal to exit.")
+	assert.Equal(t, PanicError{Entry: Entry{Message: "boom"}}, recoverValue(func() {
+		WriteThenPanic.OnWrite(&CheckedEntry{Entry: Entry{Message: "boom"}}, nil)
+	}), "Expected WriteThenPanic to panic.")
+	assert.NotPanics(t, func() { WriteThenNoop.OnWrite(&CheckedEntry{}, nil) }, "Expected WriteThenNoop to do nothing.")
+}
+
+func TestEntryStacktrace(t *testing.T) {
+	calls := 0
+	format := func(pcs []uintptr) string {
+		calls++
+		return "lazy-stack"
+	}
+
+	assert.Equal(t, "", Entry{}.Stacktrace(), "Expected no stack trace by default.")
+	assert.Equal(t, "eager-stack", Entry{Stack: "eager-stack"}.Stacktrace(), "Unexpected eager stack trace.")
+
+	ent := Entry{Message: "msg", LazyStack: NewLazyStack([]uintptr{1, 2}, format)}
+	assert.Equal(t, 0, calls, "Expected symbolization to be deferred.")
+
+	enc := NewJSONEncoder(EncoderConfig{MessageKey: "M", StacktraceKey: "S"})
+	for i := 0; i < 2; i++ {
+		buf, err := enc.EncodeEntry(ent, nil)
+		if assert.NoError(t, err, "Unexpected error encoding entry.") {
+			assert.Equal(t, `{"M":"msg","S":"lazy-stack"}`+"\n", buf.String(), "Unexpected JSON output.")
+			buf.Free()
+		}
+	}
+	assert.Equal(t, 1, calls, "Expected stack trace to be symbolized exactly once.")
+
+	ent.Stack = "eager-stack"
+	assert.Equal(t, "eager-stack", ent.Stacktrace(), "Expected Stack to take precedence over LazyStack.")
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	// _defaultFailoverThreshold is the number of consecutive primary errors
+	// that trigger a failover when FailoverConfig.Threshold is zero.
+	_defaultFailoverThreshold = 3
+
+	// _defaultFailoverRetryInterval is how often a failed-over
+	// FailoverWriteSyncer retries the primary when FailoverConfig.RetryInterval
+	// is zero.
+	_defaultFailoverRetryInterval = 10 * time.Second
+)
+
+// FailoverConfig configures NewFailoverWriteSyncer.
+type FailoverConfig struct {
+	// Threshold is the number of consecutive failed writes to the primary
+	// after which writes go to the fallback instead. It defaults to 3.
+	Threshold int
+
+	// RetryInterval is how often, once failed over, a write is tried against
+	// the primary to see whether it has recovered. It defaults to 10 seconds.
+	RetryInterval time.Duration
+
+	// ReplayBufferSize, if positive, keeps up to this many bytes of the
+	// writes made while failed over, starting with the one that triggered
+	// the failover, and replays them to the primary, in
+	// order, when it recovers. The oldest writes are discarded once the
+	// limit is reached. Replayed writes have also been written to the
+	// fallback.
+	ReplayBufferSize int
+
+	// Clock decides when to retry the primary. It defaults to DefaultClock.
+	Clock Clock
+}
+
+// FailoverStats counts the writes handled by a FailoverWriteSyncer.
+type FailoverStats struct {
+	// PrimaryErrors is the number of writes the primary failed.
+	PrimaryErrors uint64
+	// Failovers is the number of times writes switched to the fallback.
+	Failovers uint64
+	// Recoveries is the number of times writes switched back to the primary.
+	Recoveries uint64
+	// Replayed is the number of buffered writes replayed to the primary.
+	Replayed uint64
+	// ReplayDropped is the number of writes discarded from a full replay
+	// buffer.
+	ReplayDropped uint64
+}
+
+// A FailoverWriteSyncer writes to a primary WriteSyncer, typically a network
+// sink, and switches to a fallback, typically a local file, when the primary
+// fails repeatedly. While failed over, it periodically retries the primary
+// and switches back once a write succeeds.
+type FailoverWriteSyncer struct {
+	primary  WriteSyncer
+	fallback WriteSyncer
+	cfg      FailoverConfig
+
+	mu          sync.Mutex
+	failures    int       // consecutive primary errors
+	failedOver  bool      // whether writes currently go to the fallback
+	lastAttempt time.Time // last time the primary was tried while failed over
+	pending     [][]byte  // writes to replay when the primary recovers
+	pendingSize int
+	stats       FailoverStats
+}
+
+// NewFailoverWriteSyncer wraps a primary and a fallback WriteSyncer. Writes
+// the primary fails are written to the fallback, so that no output is lost
+// before the failover threshold is reached; a write fails only if both
+// destinations fail it.
+//
+// Neither WriteSyncer needs to be safe for concurrent use.
+func NewFailoverWriteSyncer(primary, fallback WriteSyncer, cfg FailoverConfig) *FailoverWriteSyncer {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = _defaultFailoverThreshold
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = _defaultFailoverRetryInterval
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = DefaultClock
+	}
+	return &FailoverWriteSyncer{
+		primary:  primary,
+		fallback: fallback,
+		cfg:      cfg,
+	}
+}
+
+// Write implements io.Writer.
+func (f *FailoverWriteSyncer) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failedOver {
+		now := f.cfg.Clock.Now()
+		if now.Sub(f.lastAttempt) < f.cfg.RetryInterval {
+			return f.writeFallback(p, nil)
+		}
+		f.lastAttempt = now
+		if err := f.recover(p); err != nil {
+			f.stats.PrimaryErrors++
+			return f.writeFallback(p, err)
+		}
+		return len(p), nil
+	}
+
+	n, err := f.primary.Write(p)
+	if err == nil {
+		f.failures = 0
+		return n, nil
+	}
+	f.stats.PrimaryErrors++
+	f.failures++
+	if f.failures >= f.cfg.Threshold {
+		f.failedOver = true
+		f.lastAttempt = f.cfg.Clock.Now()
+		f.stats.Failovers++
+	}
+	return f.writeFallback(p, err)
+}
+
+// recover replays any buffered writes and then p to the primary, switching
+// back to it if they all succeed. It must be called with the lock held.
+func (f *FailoverWriteSyncer) recover(p []byte) error {
+	for len(f.pending) > 0 {
+		if _, err := f.primary.Write(f.pending[0]); err != nil {
+			return err
+		}
+		f.pendingSize -= len(f.pending[0])
+		f.pending[0] = nil
+		f.pending = f.pending[1:]
+		f.stats.Replayed++
+	}
+	if _, err := f.primary.Write(p); err != nil {
+		return err
+	}
+	f.pending = nil
+	f.failures = 0
+	f.failedOver = false
+	f.stats.Recoveries++
+	return nil
+}
+
+// writeFallback writes p to the fallback, buffering it for replay if the
+// primary is failed over. primaryErr is returned along with the fallback's
+// error if the fallback fails too. It must be called with the lock held.
+func (f *FailoverWriteSyncer) writeFallback(p []byte, primaryErr error) (int, error) {
+	if f.failedOver {
+		f.buffer(p)
+	}
+	n, err := f.fallback.Write(p)
+	if err != nil {
+		return n, multierr.Append(primaryErr, err)
+	}
+	return n, nil
+}
+
+// buffer keeps a copy of p for replay, discarding the oldest writes to stay
+// within ReplayBufferSize. It must be called with the lock held.
+func (f *FailoverWriteSyncer) buffer(p []byte) {
+	limit := f.cfg.ReplayBufferSize
+	if limit <= 0 {
+		return
+	}
+	if len(p) > limit {
+		f.stats.ReplayDropped++
+		return
+	}
+	for f.pendingSize+len(p) > limit {
+		f.pendingSize -= len(f.pending[0])
+		f.pending[0] = nil
+		f.pending = f.pending[1:]
+		f.stats.ReplayDropped++
+	}
+	f.pending = append(f.pending, append([]byte(nil), p...))
+	f.pendingSize += len(p)
+}
+
+// Sync implements WriteSyncer. The fallback is always synced, since it may
+// hold writes the primary failed; the primary is synced unless writes are
+// failed over.
+func (f *FailoverWriteSyncer) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.fallback.Sync()
+	if !f.failedOver {
+		err = multierr.Append(f.primary.Sync(), err)
+	}
+	return err
+}
+
+// FailedOver reports whether writes are currently going to the fallback.
+func (f *FailoverWriteSyncer) FailedOver() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failedOver
+}
+
+// Stats returns counts of the writes handled so far.
+func (f *FailoverWriteSyncer) Stats() FailoverStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyWriter is a Buffer whose writes can be made to fail.
+type flakyWriter struct {
+	ztest.Buffer
+	fail bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("primary down")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestFailoverWriteSyncer(t *testing.T) {
+	primary, fallback := &flakyWriter{}, &ztest.Buffer{}
+	clock := ztest.NewMockClock(time.Unix(0, 0))
+	ws := NewFailoverWriteSyncer(primary, fallback, FailoverConfig{
+		Threshold:     2,
+		RetryInterval: time.Minute,
+		Clock:         clock,
+	})
+
+	ws.Write([]byte("a\n"))
+	assert.Equal(t, []string{"a"}, primary.Lines(), "Expected writes to go to the primary.")
+
+	primary.fail = true
+	n, err := ws.Write([]byte("b\n"))
+	require.NoError(t, err, "Expected a fallback write to succeed.")
+	assert.Equal(t, 2, n, "Unexpected number of bytes written.")
+	assert.False(t, ws.FailedOver(), "Expected a single error not to trigger a failover.")
+	ws.Write([]byte("c\n"))
+	assert.True(t, ws.FailedOver(), "Expected repeated errors to trigger a failover.")
+	assert.Equal(t, []string{"b", "c"}, fallback.Lines(), "Expected failed writes to go to the fallback.")
+
+	// The primary isn't retried until the retry interval has passed.
+	primary.fail = false
+	ws.Write([]byte("d\n"))
+	assert.Equal(t, []string{"a"}, primary.Lines(), "Expected the primary not to be retried yet.")
+
+	clock.Add(time.Minute)
+	ws.Write([]byte("e\n"))
+	assert.False(t, ws.FailedOver(), "Expected a successful retry to switch back to the primary.")
+	assert.Equal(t, []string{"a", "e"}, primary.Lines(), "Unexpected primary output without replay.")
+	assert.Equal(t, []string{"b", "c", "d"}, fallback.Lines(), "Unexpected fallback output.")
+	assert.Equal(t, FailoverStats{PrimaryErrors: 2, Failovers: 1, Recoveries: 1}, ws.Stats(), "Unexpected stats.")
+}
+
+func TestFailoverWriteSyncerReplay(t *testing.T) {
+	primary, fallback := &flakyWriter{fail: true}, &ztest.Buffer{}
+	clock := ztest.NewMockClock(time.Unix(0, 0))
+	ws := NewFailoverWriteSyncer(primary, fallback, FailoverConfig{
+		Threshold:        1,
+		RetryInterval:    time.Minute,
+		ReplayBufferSize: 4,
+		Clock:            clock,
+	})
+
+	// The oldest buffered writes are discarded to stay within the limit, and
+	// writes larger than the limit aren't buffered at all.
+	for _, s := range []string{"a\n", "b\n", "c\n", "d\n", "too long\n"} {
+		ws.Write([]byte(s))
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "too long"}, fallback.Lines(), "Expected every write to reach the fallback.")
+
+	// A failed retry keeps the buffer.
+	clock.Add(time.Minute)
+	ws.Write([]byte("e\n"))
+	assert.True(t, ws.FailedOver(), "Expected a failed retry to stay failed over.")
+
+	primary.fail = false
+	clock.Add(time.Minute)
+	ws.Write([]byte("f\n"))
+	assert.False(t, ws.FailedOver(), "Expected a successful retry to switch back to the primary.")
+	assert.Equal(t, []string{"d", "e", "f"}, primary.Lines(), "Expected buffered writes to be replayed in order.")
+	assert.Equal(t, FailoverStats{
+		PrimaryErrors: 2,
+		Failovers:     1,
+		Recoveries:    1,
+		Replayed:      2,
+		ReplayDropped: 4,
+	}, ws.Stats(), "Unexpected stats.")
+}
+
+func TestFailoverWriteSyncerErrors(t *testing.T) {
+	fallback := &ztest.FailWriter{}
+	ws := NewFailoverWriteSyncer(&flakyWriter{fail: true}, fallback, FailoverConfig{})
+	_, err := ws.Write([]byte("lost\n"))
+	require.Error(t, err, "Expected an error when both destinations fail.")
+	assert.Contains(t, err.Error(), "primary down", "Expected the primary's error.")
+	assert.Contains(t, err.Error(), "failed", "Expected the fallback's error.")
+
+	primary := &flakyWriter{}
+	primary.SetError(errors.New("sync failed"))
+	ws = NewFailoverWriteSyncer(primary, &ztest.Buffer{}, FailoverConfig{Threshold: 1})
+	assert.Error(t, ws.Sync(), "Expected the primary's Sync error.")
+	assert.True(t, primary.Called(), "Expected the primary to be synced.")
+
+	primary.fail = true
+	ws.Write([]byte("x\n"))
+	assert.NoError(t, ws.Sync(), "Expected the primary not to be synced while failed over.")
+}
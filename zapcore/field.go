@@ -89,6 +89,19 @@ const (
 	ErrorType
 	// SkipType indicates that the field is a no-op.
 	SkipType
+	// LazyType indicates that the field carries a *LazyField, whose value is
+	// computed when the field is first encoded.
+	LazyType
+	// InlineMarshalerType indicates that the field carries an
+	// ObjectMarshaler whose fields should be added to the enclosing object,
+	// rather than nested under the field's key.
+	InlineMarshalerType
+	// SinceType indicates that the field carries a time, encoded as the
+	// duration from that time until the entry was logged.
+	SinceType
+	// UntilType indicates that the field carries a time, encoded as the
+	// duration from when the entry was logged until that time.
+	UntilType
 )
 
 // A Field is a marshaling operation used to add a key-value pair to a logger's context.
@@ -166,6 +179,18 @@ func (f Field) AddTo(enc ObjectEncoder) {
 		encodeError(f.Key, f.Interface.(error), enc)
 	case SkipType:
 		break
+	case LazyType:
+		f.Interface.(*LazyField).Field().AddTo(enc)
+	case InlineMarshalerType:
+		err = f.Interface.(ObjectMarshaler).MarshalLogObject(enc)
+	case SinceType, UntilType:
+		// Measure from the time of the entry being encoded, which comes
+		// from the Logger's clock, if the encoder knows it.
+		var now time.Time
+		if et, ok := enc.(entryTimer); ok {
+			now = et.entryTime()
+		}
+		f.resolveTime(now).AddTo(enc)
 	default:
 		panic(fmt.Sprintf("unknown field type: %v", f))
 	}
@@ -188,15 +213,122 @@ func (f Field) Equals(other Field) bool {
 	switch f.Type {
 	case BinaryType, ByteStringType:
 		return bytes.Equal(f.Interface.([]byte), other.Interface.([]byte))
-	case ArrayMarshalerType, ObjectMarshalerType, ErrorType, ReflectType:
+	case ArrayMarshalerType, ObjectMarshalerType, InlineMarshalerType, ErrorType, ReflectType:
 		return reflect.DeepEqual(f.Interface, other.Interface)
 	default:
 		return f == other
 	}
 }
 
+// IsEmpty reports whether the field carries an empty value: an empty string
+// or byte slice, a zero number, duration, or time, false, or a nil value. A
+// reflected value is also empty if it's an empty slice, map, array, or
+// string. Skipped fields are always empty, while namespaces, lazy fields,
+// and inlined objects never are.
+func (f Field) IsEmpty() bool {
+	switch f.Type {
+	case BinaryType, ByteStringType:
+		return len(f.Interface.([]byte)) == 0
+	case BoolType, DurationType, Float64Type, Float32Type,
+		Int64Type, Int32Type, Int16Type, Int8Type,
+		Uint64Type, Uint32Type, Uint16Type, Uint8Type, UintptrType:
+		return f.Integer == 0
+	case Complex128Type:
+		return f.Interface.(complex128) == 0
+	case Complex64Type:
+		return f.Interface.(complex64) == 0
+	case StringType:
+		return f.String == ""
+	case TimeType:
+		return f.Integer == _zeroTimeNanos
+	case ArrayMarshalerType, ObjectMarshalerType, StringerType, ErrorType:
+		return isNil(f.Interface)
+	case ReflectType:
+		if isNil(f.Interface) {
+			return true
+		}
+		switch v := reflect.ValueOf(f.Interface); v.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+			return v.Len() == 0
+		}
+		return false
+	case SkipType:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResolveTimeFields converts any SinceType and UntilType fields into
+// durations measured from now, which should be the entry's time; a zero now
+// means the system clock. If there are none, fields is returned as is;
+// otherwise the result is a copy. Encoders built into zapcore resolve these
+// fields as they encode them; other encoders and Cores that keep fields
+// should call ResolveTimeFields with the entry's time.
+func ResolveTimeFields(fields []Field, now time.Time) []Field {
+	for i := range fields {
+		if t := fields[i].Type; t != SinceType && t != UntilType {
+			continue
+		}
+		resolved := make([]Field, len(fields))
+		copy(resolved, fields)
+		for j := i; j < len(resolved); j++ {
+			resolved[j] = resolved[j].resolveTime(now)
+		}
+		return resolved
+	}
+	return fields
+}
+
+func (f Field) resolveTime(now time.Time) Field {
+	if now.IsZero() {
+		now = DefaultClock.Now()
+	}
+	switch f.Type {
+	case SinceType:
+		return Field{Key: f.Key, Type: DurationType, Integer: now.UnixNano() - f.Integer}
+	case UntilType:
+		return Field{Key: f.Key, Type: DurationType, Integer: f.Integer - now.UnixNano()}
+	}
+	return f
+}
+
+// _zeroTimeNanos is what time.Time{} looks like in a TimeType field.
+var _zeroTimeNanos = time.Time{}.UnixNano()
+
+// isNil reports whether v is nil or holds a nil pointer, map, slice, or
+// function.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// entryTimer is implemented by encoders that know the time of the entry
+// they're encoding, so SinceType and UntilType fields can be measured from
+// it. entryTime returns the zero time outside EncodeEntry.
+type entryTimer interface {
+	entryTime() time.Time
+}
+
+// emptyFieldOmitter is implemented by encoders that drop some empty fields;
+// see EncoderConfig.OmitEmpty.
+type emptyFieldOmitter interface {
+	omitsEmpty(key string) bool
+}
+
 func addFields(enc ObjectEncoder, fields []Field) {
+	omitter, _ := enc.(emptyFieldOmitter)
 	for i := range fields {
+		if omitter != nil && omitter.omitsEmpty(fields[i].Key) && fields[i].IsEmpty() {
+			continue
+		}
 		fields[i].AddTo(enc)
 	}
 }
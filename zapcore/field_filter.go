@@ -0,0 +1,312 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"time"
+
+	"github.com/blastbao/zap/buffer"
+)
+
+// A FieldFilter reports whether a field with the given key should be encoded.
+type FieldFilter func(key string) bool
+
+// AllowFields returns a FieldFilter that keeps only fields with the given
+// keys.
+func AllowFields(keys ...string) FieldFilter {
+	set := newKeySet(keys)
+	return func(key string) bool {
+		_, ok := set[key]
+		return ok
+	}
+}
+
+// DenyFields returns a FieldFilter that drops fields with the given keys and
+// keeps all others.
+func DenyFields(keys ...string) FieldFilter {
+	set := newKeySet(keys)
+	return func(key string) bool {
+		_, ok := set[key]
+		return !ok
+	}
+}
+
+func newKeySet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+// NewFieldFilterEncoder wraps an Encoder so that only the context fields
+// accepted by keep are encoded. This makes it possible to send a trimmed-down
+// version of each entry to one destination (for example, a metered network
+// sink) while another destination in the same Tee keeps every field:
+//
+//	remote := zapcore.NewCore(
+//		zapcore.NewFieldFilterEncoder(
+//			zapcore.NewJSONEncoder(cfg),
+//			zapcore.AllowFields("trace_id", "error"),
+//		),
+//		remoteSink,
+//		zapcore.InfoLevel,
+//	)
+//	core := zapcore.NewTee(local, remote)
+//
+// Fields are filtered as they're encoded, both those added with With and
+// those supplied at the log site, so no work is wasted on dropped fields.
+// Only top-level keys are considered; the contents of objects and arrays are
+// encoded as-is. Dropping a namespace drops every field nested within it,
+// and keeping one keeps them all.
+//
+// Entry metadata, such as the level and message, is controlled by the
+// wrapped encoder's EncoderConfig rather than the filter.
+func NewFieldFilterEncoder(enc Encoder, keep FieldFilter) Encoder {
+	return &filterEncoder{Encoder: enc, keep: keep}
+}
+
+type filterEncoder struct {
+	Encoder
+	keep FieldFilter
+	// skipping is set once a dropped namespace is opened, and nested once a
+	// kept one is, since all subsequent fields belong to it.
+	skipping bool
+	nested   bool
+}
+
+func (e *filterEncoder) kept(key string) bool {
+	return !e.skipping && (e.nested || e.keep(key))
+}
+
+func (e *filterEncoder) Clone() Encoder {
+	return &filterEncoder{
+		Encoder:  e.Encoder.Clone(),
+		keep:     e.keep,
+		skipping: e.skipping,
+		nested:   e.nested,
+	}
+}
+
+func (e *filterEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer, error) {
+	if e.skipping {
+		fields = nil
+	}
+	if e.nested {
+		return e.Encoder.EncodeEntry(ent, fields)
+	}
+	for i := range fields {
+		if fields[i].Type == InlineMarshalerType {
+			return e.encodeInline(ent, fields)
+		}
+	}
+
+	// Only copy the fields if some of them need to be dropped.
+	kept, copied := fields, false
+	for i := range fields {
+		if e.keep(fields[i].Key) {
+			if fields[i].Type == NamespaceType {
+				// The rest of the fields are nested in a kept namespace.
+				if copied {
+					kept = append(kept, fields[i:]...)
+				}
+				break
+			}
+			if copied {
+				kept = append(kept, fields[i])
+			}
+			continue
+		}
+		if !copied {
+			kept = append(make([]Field, 0, len(fields)), fields[:i]...)
+			copied = true
+		}
+		if fields[i].Type == NamespaceType {
+			break
+		}
+	}
+	return e.Encoder.EncodeEntry(ent, kept)
+}
+
+// encodeInline handles fields containing inlined objects, whose keys are
+// only known once they're marshaled. It adds the fields through a filtered
+// clone, which is slower but sees every key.
+func (e *filterEncoder) encodeInline(ent Entry, fields []Field) (*buffer.Buffer, error) {
+	clone := e.Clone().(*filterEncoder)
+	addFields(clone, fields)
+	return clone.Encoder.EncodeEntry(ent, nil)
+}
+
+func (e *filterEncoder) OpenNamespace(key string) {
+	if e.kept(key) {
+		e.Encoder.OpenNamespace(key)
+		e.nested = true
+		return
+	}
+	e.skipping = true
+}
+
+func (e *filterEncoder) AddArray(key string, v ArrayMarshaler) error {
+	if e.kept(key) {
+		return e.Encoder.AddArray(key, v)
+	}
+	return nil
+}
+
+func (e *filterEncoder) AddObject(key string, v ObjectMarshaler) error {
+	if e.kept(key) {
+		return e.Encoder.AddObject(key, v)
+	}
+	return nil
+}
+
+func (e *filterEncoder) AddReflected(key string, v interface{}) error {
+	if e.kept(key) {
+		return e.Encoder.AddReflected(key, v)
+	}
+	return nil
+}
+
+func (e *filterEncoder) AddBinary(key string, v []byte) {
+	if e.kept(key) {
+		e.Encoder.AddBinary(key, v)
+	}
+}
+
+func (e *filterEncoder) AddByteString(key string, v []byte) {
+	if e.kept(key) {
+		e.Encoder.AddByteString(key, v)
+	}
+}
+
+func (e *filterEncoder) AddBool(key string, v bool) {
+	if e.kept(key) {
+		e.Encoder.AddBool(key, v)
+	}
+}
+
+func (e *filterEncoder) AddComplex128(key string, v complex128) {
+	if e.kept(key) {
+		e.Encoder.AddComplex128(key, v)
+	}
+}
+
+func (e *filterEncoder) AddComplex64(key string, v complex64) {
+	if e.kept(key) {
+		e.Encoder.AddComplex64(key, v)
+	}
+}
+
+func (e *filterEncoder) AddDuration(key string, v time.Duration) {
+	if e.kept(key) {
+		e.Encoder.AddDuration(key, v)
+	}
+}
+
+func (e *filterEncoder) AddFloat64(key string, v float64) {
+	if e.kept(key) {
+		e.Encoder.AddFloat64(key, v)
+	}
+}
+
+func (e *filterEncoder) AddFloat32(key string, v float32) {
+	if e.kept(key) {
+		e.Encoder.AddFloat32(key, v)
+	}
+}
+
+func (e *filterEncoder) AddInt(key string, v int) {
+	if e.kept(key) {
+		e.Encoder.AddInt(key, v)
+	}
+}
+
+func (e *filterEncoder) AddInt64(key string, v int64) {
+	if e.kept(key) {
+		e.Encoder.AddInt64(key, v)
+	}
+}
+
+func (e *filterEncoder) AddInt32(key string, v int32) {
+	if e.kept(key) {
+		e.Encoder.AddInt32(key, v)
+	}
+}
+
+func (e *filterEncoder) AddInt16(key string, v int16) {
+	if e.kept(key) {
+		e.Encoder.AddInt16(key, v)
+	}
+}
+
+func (e *filterEncoder) AddInt8(key string, v int8) {
+	if e.kept(key) {
+		e.Encoder.AddInt8(key, v)
+	}
+}
+
+func (e *filterEncoder) AddString(key, v string) {
+	if e.kept(key) {
+		e.Encoder.AddString(key, v)
+	}
+}
+
+func (e *filterEncoder) AddTime(key string, v time.Time) {
+	if e.kept(key) {
+		e.Encoder.AddTime(key, v)
+	}
+}
+
+func (e *filterEncoder) AddUint(key string, v uint) {
+	if e.kept(key) {
+		e.Encoder.AddUint(key, v)
+	}
+}
+
+func (e *filterEncoder) AddUint64(key string, v uint64) {
+	if e.kept(key) {
+		e.Encoder.AddUint64(key, v)
+	}
+}
+
+func (e *filterEncoder) AddUint32(key string, v uint32) {
+	if e.kept(key) {
+		e.Encoder.AddUint32(key, v)
+	}
+}
+
+func (e *filterEncoder) AddUint16(key string, v uint16) {
+	if e.kept(key) {
+		e.Encoder.AddUint16(key, v)
+	}
+}
+
+func (e *filterEncoder) AddUint8(key string, v uint8) {
+	if e.kept(key) {
+		e.Encoder.AddUint8(key, v)
+	}
+}
+
+func (e *filterEncoder) AddUintptr(key string, v uintptr) {
+	if e.kept(key) {
+		e.Encoder.AddUintptr(key, v)
+	}
+}
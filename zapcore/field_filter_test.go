@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap"
+	. "github.com/blastbao/zap/zapcore"
+)
+
+func encodeFiltered(t testing.TB, enc Encoder, with, fields []Field) string {
+	for i := range with {
+		with[i].AddTo(enc)
+	}
+	buf, err := enc.EncodeEntry(Entry{Message: "msg"}, fields)
+	require.NoError(t, err, "Unexpected error encoding entry.")
+	defer buf.Free()
+	return buf.String()
+}
+
+func TestFieldFilterEncoder(t *testing.T) {
+	cfg := EncoderConfig{MessageKey: "M"}
+	tests := []struct {
+		desc   string
+		keep   FieldFilter
+		with   []Field
+		fields []Field
+		want   string
+	}{
+		{
+			desc:   "allowlist",
+			keep:   AllowFields("trace_id", "error"),
+			with:   []Field{zap.String("trace_id", "abc"), zap.String("user", "bob")},
+			fields: []Field{zap.Error(errors.New("boom")), zap.Int("attempt", 2)},
+			want:   `{"M":"msg","trace_id":"abc","error":"boom"}`,
+		},
+		{
+			desc:   "denylist",
+			keep:   DenyFields("password"),
+			with:   []Field{zap.String("password", "hunter2"), zap.String("user", "bob")},
+			fields: []Field{zap.String("password", "hunter2"), zap.Int("attempt", 2)},
+			want:   `{"M":"msg","user":"bob","attempt":2}`,
+		},
+		{
+			desc:   "nested keys aren't filtered",
+			keep:   AllowFields("obj"),
+			fields: []Field{zap.Object("obj", users(1)), zap.Int("users", 2)},
+			want:   `{"M":"msg","obj":{"users":1}}`,
+		},
+		{
+			desc:   "inlined keys are filtered",
+			keep:   DenyFields("users"),
+			with:   []Field{zap.Inline(users(1))},
+			fields: []Field{zap.Int("a", 1), zap.Inline(users(2)), zap.Object("obj", users(3))},
+			want:   `{"M":"msg","a":1,"obj":{"users":3}}`,
+		},
+		{
+			desc:   "inlined keys are kept",
+			keep:   AllowFields("users", "b"),
+			fields: []Field{zap.Int("a", 1), zap.Inline(users(2)), zap.Int("b", 3)},
+			want:   `{"M":"msg","users":2,"b":3}`,
+		},
+		{
+			desc:   "dropped namespace at the log site",
+			keep:   DenyFields("secret"),
+			fields: []Field{zap.Int("a", 1), zap.Namespace("secret"), zap.Int("b", 2)},
+			want:   `{"M":"msg","a":1}`,
+		},
+		{
+			desc:   "dropped namespace in context",
+			keep:   DenyFields("secret"),
+			with:   []Field{zap.Int("a", 1), zap.Namespace("secret"), zap.Int("b", 2)},
+			fields: []Field{zap.Int("c", 3)},
+			want:   `{"M":"msg","a":1}`,
+		},
+		{
+			desc:   "kept namespace in context",
+			keep:   DenyFields("b"),
+			with:   []Field{zap.Int("b", 1), zap.Namespace("ns"), zap.Int("a", 1), zap.Int("b", 2)},
+			fields: []Field{zap.Int("b", 3), zap.Int("c", 4)},
+			want:   `{"M":"msg","ns":{"a":1,"b":2,"b":3,"c":4}}`,
+		},
+		{
+			desc:   "kept namespace at the log site",
+			keep:   AllowFields("a", "ns"),
+			fields: []Field{zap.Int("a", 1), zap.Int("b", 2), zap.Namespace("ns"), zap.Int("b", 3), zap.Namespace("inner"), zap.Int("c", 4)},
+			want:   `{"M":"msg","a":1,"ns":{"b":3,"inner":{"c":4}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		enc := NewFieldFilterEncoder(NewJSONEncoder(cfg), tt.keep)
+		assert.Equal(t, tt.want+"\n", encodeFiltered(t, enc, tt.with, tt.fields), tt.desc)
+	}
+}
+
+func TestFieldFilterEncoderAllTypes(t *testing.T) {
+	cfg := EncoderConfig{
+		MessageKey:     "M",
+		EncodeTime:     EpochTimeEncoder,
+		EncodeDuration: StringDurationEncoder,
+	}
+	fields := []Field{
+		zap.Bools("k", []bool{true}),
+		zap.Object("k", users(1)),
+		zap.Binary("k", []byte("ab")),
+		zap.ByteString("k", []byte("ab")),
+		zap.Bool("k", true),
+		zap.Complex128("k", 1+2i),
+		zap.Complex64("k", 1+2i),
+		zap.Duration("k", time.Second),
+		zap.Float64("k", 1.5),
+		zap.Float32("k", 1.5),
+		zap.Int64("k", 1),
+		zap.Int32("k", 1),
+		zap.Int16("k", 1),
+		zap.Int8("k", 1),
+		zap.String("k", "v"),
+		zap.Time("k", time.Unix(0, 0)),
+		zap.Uint64("k", 1),
+		zap.Uint32("k", 1),
+		zap.Uint16("k", 1),
+		zap.Uint8("k", 1),
+		zap.Uintptr("k", 1),
+		zap.Reflect("k", []int{1}),
+		zap.Stringer("k", users(1)),
+	}
+
+	for _, f := range fields {
+		want := encodeFiltered(t, NewJSONEncoder(cfg), []Field{f}, nil)
+		kept := encodeFiltered(t, NewFieldFilterEncoder(NewJSONEncoder(cfg), AllowFields("k")), []Field{f}, nil)
+		dropped := encodeFiltered(t, NewFieldFilterEncoder(NewJSONEncoder(cfg), DenyFields("k")), []Field{f}, nil)
+		assert.Equal(t, want, kept, "Expected allowed field %v to be encoded.", f)
+		assert.Equal(t, `{"M":"msg"}`+"\n", dropped, "Expected denied field %v to be dropped.", f)
+	}
+}
+
+func TestFieldFilterEncoderClone(t *testing.T) {
+	enc := NewFieldFilterEncoder(NewJSONEncoder(EncoderConfig{MessageKey: "M"}), DenyFields("secret"))
+	clone := enc.Clone()
+	clone.OpenNamespace("secret")
+	clone.AddInt("a", 1)
+	enc.AddInt("b", 2)
+
+	assert.Equal(t, `{"M":"msg"}`+"\n", encodeFiltered(t, clone, nil, []Field{zap.Int("c", 3)}), "Unexpected output from clone.")
+	assert.Equal(t, `{"M":"msg","b":2,"c":3}`+"\n", encodeFiltered(t, enc, nil, []Field{zap.Int("c", 3)}), "Unexpected output from original.")
+}
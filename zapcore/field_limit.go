@@ -0,0 +1,138 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DroppedFieldsKey is the key under which a field-limiting Core records the
+// number of fields it dropped from an entry.
+const DroppedFieldsKey = "droppedFields"
+
+// NewFieldLimitCore wraps a Core to cap the number of fields in each entry,
+// counting both the context added with With and the fields passed at the
+// call site. Context fields are kept first, in the order they were added;
+// fields beyond the limit are dropped, and entries that lost any are written
+// with an extra DroppedFieldsKey field holding the number dropped.
+//
+// This protects against code that keeps calling With in a loop (say, once
+// per retry) and produces ever-larger entries. The first time fields are
+// dropped, the Core also writes a warning through the wrapped Core. Loggers
+// derived with With share that warning, so it's written at most once per
+// call to NewFieldLimitCore.
+//
+// If max isn't positive, the Core is returned unchanged.
+func NewFieldLimitCore(core Core, max int) Core {
+	if max <= 0 {
+		return core
+	}
+	return &fieldLimitCore{
+		Core:   core,
+		max:    max,
+		warned: new(uint32),
+	}
+}
+
+type fieldLimitCore struct {
+	Core
+	max int
+	// context is the number of context fields added to the wrapped Core,
+	// and dropped the number of context fields that didn't fit.
+	context int
+	dropped int
+	warned  *uint32
+}
+
+// Unwrap returns the wrapped Core.
+func (c *fieldLimitCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *fieldLimitCore) With(fields []Field) Core {
+	kept, dropped := c.limit(c.context, fields)
+	clone := &fieldLimitCore{
+		Core:    c.Core.With(kept),
+		max:     c.max,
+		context: c.context + len(kept),
+		dropped: c.dropped + dropped,
+		warned:  c.warned,
+	}
+	if dropped > 0 {
+		clone.warn(Entry{Time: time.Now()})
+	}
+	return clone
+}
+
+func (c *fieldLimitCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	// The number of call-site fields isn't known until Write, so route the
+	// entry through this Core.
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fieldLimitCore) Write(ent Entry, fields []Field) error {
+	fields, dropped := c.limit(c.context, fields)
+	dropped += c.dropped
+	if dropped == 0 {
+		return writeChecked(c.Core, ent, fields)
+	}
+	// Don't modify the caller's slice.
+	fields = append(fields[:len(fields):len(fields)], Field{
+		Key:     DroppedFieldsKey,
+		Type:    Int64Type,
+		Integer: int64(dropped),
+	})
+	err := writeChecked(c.Core, ent, fields)
+	c.warn(ent)
+	return err
+}
+
+// limit returns the fields that fit alongside n existing fields, and the
+// number that don't.
+func (c *fieldLimitCore) limit(n int, fields []Field) ([]Field, int) {
+	room := c.max - n
+	if room < 0 {
+		room = 0
+	}
+	if len(fields) <= room {
+		return fields, 0
+	}
+	return fields[:room], len(fields) - room
+}
+
+// warn reports that fields were dropped, the first time it's called.
+func (c *fieldLimitCore) warn(ent Entry) {
+	if !atomic.CompareAndSwapUint32(c.warned, 0, 1) {
+		return
+	}
+	writeChecked(c.Core, Entry{
+		Level:      WarnLevel,
+		Time:       ent.Time,
+		LoggerName: ent.LoggerName,
+		Message:    "dropped fields over the per-entry limit",
+	}, []Field{
+		{Key: "limit", Type: Int64Type, Integer: int64(c.max)},
+	})
+}
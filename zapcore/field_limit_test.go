@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func intField(key string, val int64) Field {
+	return Field{Key: key, Type: Int64Type, Integer: val}
+}
+
+func TestFieldLimitCore(t *testing.T) {
+	tests := []struct {
+		desc    string
+		context []Field
+		fields  []Field
+		want    []Field
+	}{
+		{
+			desc:   "under the limit",
+			fields: []Field{intField("a", 1), intField("b", 2)},
+			want:   []Field{intField("a", 1), intField("b", 2)},
+		},
+		{
+			desc:   "call-site fields over the limit",
+			fields: []Field{intField("a", 1), intField("b", 2), intField("c", 3), intField("d", 4)},
+			want:   []Field{intField("a", 1), intField("b", 2), intField("c", 3), intField(DroppedFieldsKey, 1)},
+		},
+		{
+			desc:    "context and call-site fields over the limit",
+			context: []Field{intField("a", 1), intField("b", 2)},
+			fields:  []Field{intField("c", 3), intField("d", 4)},
+			want:    []Field{intField("a", 1), intField("b", 2), intField("c", 3), intField(DroppedFieldsKey, 1)},
+		},
+		{
+			desc:    "context over the limit",
+			context: []Field{intField("a", 1), intField("b", 2), intField("c", 3), intField("d", 4), intField("e", 5)},
+			fields:  []Field{intField("f", 6)},
+			want:    []Field{intField("a", 1), intField("b", 2), intField("c", 3), intField(DroppedFieldsKey, 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		fac, logs := observer.New(InfoLevel)
+		core := NewFieldLimitCore(fac, 3)
+		for _, f := range tt.context {
+			core = core.With([]Field{f})
+		}
+		writeEntry(core, InfoLevel, "hello", tt.fields...)
+
+		entries := logs.FilterMessage("hello").AllUntimed()
+		require.Equal(t, 1, len(entries), "%s: expected one entry.", tt.desc)
+		assert.Equal(t, tt.want, entries[0].Context, "%s: unexpected fields.", tt.desc)
+	}
+}
+
+func TestFieldLimitCoreWarnsOnce(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := NewFieldLimitCore(fac, 1)
+	for i := 0; i < 10; i++ {
+		core = core.With([]Field{intField("attempt", int64(i))})
+		writeEntry(core, InfoLevel, "retrying")
+	}
+	writeEntry(core, DebugLevel, "disabled", intField("a", 1), intField("b", 2))
+
+	warnings := logs.FilterMessage("dropped fields over the per-entry limit").AllUntimed()
+	require.Equal(t, 1, len(warnings), "Expected a single warning.")
+	assert.Equal(t, WarnLevel, warnings[0].Level, "Unexpected warning level.")
+	assert.Equal(t, []Field{intField("attempt", 0), intField("limit", 1)}, warnings[0].Context, "Unexpected warning fields.")
+
+	entries := logs.FilterMessage("retrying").AllUntimed()
+	require.Equal(t, 10, len(entries), "Expected every entry to be written.")
+	assert.Equal(t, []Field{intField("attempt", 0), intField(DroppedFieldsKey, 9)}, entries[9].Context, "Expected later context to be dropped.")
+}
+
+func TestFieldLimitCoreDisabled(t *testing.T) {
+	fac, _ := observer.New(InfoLevel)
+	assert.Equal(t, fac, NewFieldLimitCore(fac, 0), "Expected a non-positive limit to leave the core unchanged.")
+}
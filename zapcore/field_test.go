@@ -117,6 +117,7 @@ func TestFields(t *testing.T) {
 		{t: NamespaceType, want: map[string]interface{}{}},
 		{t: StringerType, iface: users(2), want: "2 users"},
 		{t: SkipType, want: interface{}(nil)},
+		{t: LazyType, iface: NewLazyField(func() Field { return Field{Key: "k", Type: StringType, String: "foo"} }), want: "foo"},
 	}
 
 	for _, tt := range tests {
@@ -132,6 +133,78 @@ func TestFields(t *testing.T) {
 	}
 }
 
+func TestInlineField(t *testing.T) {
+	enc := NewMapObjectEncoder()
+	enc.AddString("before", "b")
+	f := Field{Key: "ignored", Type: InlineMarshalerType, Interface: users(2)}
+	f.AddTo(enc)
+	assert.Equal(t, map[string]interface{}{"before": "b", "users": 2}, enc.Fields, "Expected fields to be added at the top level.")
+	assert.True(t, f.Equals(f), "Field does not equal itself")
+
+	enc = NewMapObjectEncoder()
+	Field{Key: "k", Type: InlineMarshalerType, Interface: users(-1)}.AddTo(enc)
+	assert.Equal(t, map[string]interface{}{"kError": "too few users"}, enc.Fields, "Expected marshaling errors to be reported.")
+}
+
+func TestResolveTimeFields(t *testing.T) {
+	now := time.Unix(100, 0)
+	fields := []Field{
+		{Key: "a", Type: StringType, String: "s"},
+		{Key: "since", Type: SinceType, Integer: time.Unix(90, 0).UnixNano()},
+		{Key: "until", Type: UntilType, Integer: time.Unix(105, 0).UnixNano()},
+	}
+	resolved := ResolveTimeFields(fields, now)
+	assert.Equal(t, []Field{
+		fields[0],
+		{Key: "since", Type: DurationType, Integer: int64(10 * time.Second)},
+		{Key: "until", Type: DurationType, Integer: int64(5 * time.Second)},
+	}, resolved, "Unexpected resolved fields.")
+	assert.Equal(t, SinceType, fields[1].Type, "Expected the original fields to be left alone.")
+
+	plain := fields[:1]
+	assert.Equal(t, &plain[0], &ResolveTimeFields(plain, now)[0], "Expected fields without times to be returned as is.")
+
+	// Outside a Logger, fields are measured against the system clock.
+	enc := NewMapObjectEncoder()
+	Field{Key: "since", Type: SinceType, Integer: time.Now().Add(-time.Hour).UnixNano()}.AddTo(enc)
+	assert.True(t, enc.Fields["since"].(time.Duration) >= time.Hour, "Expected the duration since an hour ago.")
+}
+
+func TestFieldIsEmpty(t *testing.T) {
+	var nilUsers *users
+	tests := []struct {
+		field Field
+		want  bool
+	}{
+		{zap.String("k", ""), true},
+		{zap.String("k", "v"), false},
+		{zap.Int("k", 0), true},
+		{zap.Int("k", 1), false},
+		{zap.Float64("k", 0), true},
+		{zap.Float64("k", 0.5), false},
+		{zap.Bool("k", false), true},
+		{zap.Bool("k", true), false},
+		{zap.Duration("k", 0), true},
+		{zap.Complex128("k", 0), true},
+		{zap.Complex64("k", 1i), false},
+		{zap.Binary("k", nil), true},
+		{zap.ByteString("k", []byte("v")), false},
+		{zap.Time("k", time.Time{}), true},
+		{zap.Time("k", time.Unix(0, 0)), false},
+		{zap.Reflect("k", nil), true},
+		{zap.Reflect("k", []int{}), true},
+		{zap.Reflect("k", map[string]int{"a": 1}), false},
+		{zap.Reflect("k", 0), false},
+		{zap.Stringer("k", nilUsers), true},
+		{zap.Object("k", users(0)), false},
+		{zap.Skip(), true},
+		{zap.Namespace("k"), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.field.IsEmpty(), "Unexpected result for %#v.", tt.field)
+	}
+}
+
 func TestEquals(t *testing.T) {
 	tests := []struct {
 		a, b Field
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+// A ForkHandler is a Core or WriteSyncer that holds locks, buffers, or
+// background goroutines, which a process forked without exec would inherit
+// in a broken state: locks held by threads that don't exist in the child,
+// goroutines that no longer run, and buffers that both processes would
+// flush.
+//
+// PrepareForFork is called just before forking. It flushes buffered output,
+// stops background goroutines, and takes the handler's locks, so that the
+// child starts from a consistent state. After the fork, exactly one of
+// AfterForkInParent and AfterForkInChild is called in each process; both
+// restart the background goroutines and release the locks.
+//
+// Most programs should use zap.PrepareForFork and its counterparts, which
+// call registered ForkHandlers, rather than calling these methods directly.
+type ForkHandler interface {
+	PrepareForFork()
+	AfterForkInParent()
+	AfterForkInChild()
+}
+
+var (
+	_ ForkHandler = (*asyncCore)(nil)
+	_ ForkHandler = (*BufferedWriteSyncer)(nil)
+)
+
+// PrepareForFork blocks new entries, writes every queued one, and stops
+// the background goroutine, so neither process is left with a goroutine
+// that might be halfway through a write.
+func (c *asyncCore) PrepareForFork() {
+	c.q.mu.Lock()
+	if !c.q.closed {
+		c.q.pause <- struct{}{}
+		<-c.q.done
+	}
+	c.Core.Sync()
+}
+
+func (c *asyncCore) AfterForkInParent() {
+	c.resumeAfterFork()
+}
+
+func (c *asyncCore) AfterForkInChild() {
+	c.resumeAfterFork()
+}
+
+func (c *asyncCore) resumeAfterFork() {
+	if !c.q.closed {
+		c.q.done = make(chan struct{})
+		go c.q.run(c.q.stop, c.q.done)
+	}
+	c.q.mu.Unlock()
+}
+
+// PrepareForFork flushes the buffer and stops the periodic flushes, which
+// the After methods restart.
+func (s *BufferedWriteSyncer) PrepareForFork() {
+	s.mu.Lock()
+	if done := s.stopFlushLoopLocked(); done != nil {
+		// The flush goroutine may be waiting for the lock.
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+	}
+	if s.initialized {
+		s.writer.Flush()
+	}
+}
+
+func (s *BufferedWriteSyncer) AfterForkInParent() {
+	s.resumeAfterFork()
+}
+
+func (s *BufferedWriteSyncer) AfterForkInChild() {
+	s.resumeAfterFork()
+}
+
+func (s *BufferedWriteSyncer) resumeAfterFork() {
+	if s.initialized && !s.stopped {
+		s.startFlushLoop()
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedWriteSyncerFork(t *testing.T) {
+	for _, child := range []bool{false, true} {
+		buf := &ztest.Buffer{}
+		ws := &BufferedWriteSyncer{WS: buf, FlushInterval: time.Millisecond}
+
+		ws.Write([]byte("before\n"))
+		done := ws.done
+		ws.PrepareForFork()
+		assert.Equal(t, "before\n", buf.String(), "Expected PrepareForFork to flush the buffer.")
+		select {
+		case <-done:
+		default:
+			t.Fatal("Expected PrepareForFork to stop the flush goroutine.")
+		}
+		if child {
+			ws.AfterForkInChild()
+		} else {
+			ws.AfterForkInParent()
+		}
+
+		ws.Write([]byte("after\n"))
+		assert.Eventually(t, func() bool {
+			ws.mu.Lock()
+			defer ws.mu.Unlock()
+			return buf.String() == "before\nafter\n"
+		}, time.Second, time.Millisecond, "Expected periodic flushes to restart (child: %v).", child)
+		assert.NoError(t, ws.Stop(), "Unexpected error stopping.")
+		<-ws.done
+	}
+}
+
+func TestBufferedWriteSyncerForkAfterStop(t *testing.T) {
+	ws := &BufferedWriteSyncer{WS: &ztest.Buffer{}}
+	ws.Write([]byte("foo\n"))
+	assert.NoError(t, ws.Stop(), "Unexpected error stopping.")
+
+	ws.PrepareForFork()
+	ws.AfterForkInChild()
+	assert.Nil(t, ws.stop, "Expected a stopped WriteSyncer not to restart flushing.")
+}
+
+func TestAsyncCoreFork(t *testing.T) {
+	buf := &ztest.Buffer{}
+	core := NewAsyncCore(NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), buf, InfoLevel), AsyncConfig{})
+	defer core.(*asyncCore).Close()
+
+	h := core.(ForkHandler)
+	core.Write(Entry{Message: "before"}, nil)
+	h.PrepareForFork()
+	assert.Equal(t, `{"msg":"before"}`, buf.Stripped(), "Expected PrepareForFork to write queued entries.")
+	h.AfterForkInParent()
+
+	core.Write(Entry{Message: "after"}, nil)
+	assert.NoError(t, core.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, []string{`{"msg":"before"}`, `{"msg":"after"}`}, buf.Lines(), "Expected the parent to keep writing.")
+}
+
+func TestAsyncCoreForkInChild(t *testing.T) {
+	buf := &ztest.Buffer{}
+	core := NewAsyncCore(NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), buf, InfoLevel), AsyncConfig{})
+	defer core.(*asyncCore).Close()
+
+	h := core.(ForkHandler)
+	done := core.(*asyncCore).q.done
+	core.Write(Entry{Message: "before"}, nil)
+	h.PrepareForFork()
+	select {
+	case <-done:
+	default:
+		t.Fatal("Expected PrepareForFork to stop the background goroutine.")
+	}
+	h.AfterForkInChild()
+
+	core.Write(Entry{Message: "after"}, nil)
+	assert.NoError(t, core.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, []string{`{"msg":"before"}`, `{"msg":"after"}`}, buf.Lines(), "Expected the child to keep writing.")
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/blastbao/zap/buffer"
+	"github.com/blastbao/zap/internal/bufferpool"
+)
+
+// _lengthPrefixSize is the size of the big-endian record length written by
+// NewLengthPrefixEncoder.
+const _lengthPrefixSize = 4
+
+// NewJSONSeqEncoder wraps an Encoder so that each entry is framed as a record
+// of an RFC 7464 JSON text sequence (media type application/json-seq): it's
+// preceded by an ASCII record separator (0x1E) and followed by a line feed.
+// The line feed is only added if the wrapped encoder's output doesn't
+// already end with one.
+//
+// Since the JSON encoder escapes control characters, records can't contain
+// the separator, so readers can find every entry even when messages or
+// reflected values contain newlines. Use ScanJSONSeq to read them back.
+func NewJSONSeqEncoder(enc Encoder) Encoder {
+	return jsonSeqEncoder{enc}
+}
+
+type jsonSeqEncoder struct {
+	Encoder
+}
+
+func (e jsonSeqEncoder) Clone() Encoder {
+	return jsonSeqEncoder{e.Encoder.Clone()}
+}
+
+func (e jsonSeqEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if buf == nil {
+		return nil, err
+	}
+	defer buf.Free()
+
+	out := bufferpool.Get()
+	out.AppendString(JSONSeqRecordSeparator)
+	out.Write(buf.Bytes())
+	if b := buf.Bytes(); len(b) == 0 || b[len(b)-1] != '\n' {
+		out.AppendByte('\n')
+	}
+	return out, err
+}
+
+// NewLengthPrefixEncoder wraps an Encoder so that each entry is preceded by
+// its length in bytes, as a 4-byte big-endian unsigned integer. The wrapped
+// encoder's output is otherwise left as-is; set EncoderConfig.SkipLineEnding
+// to drop the trailing newline. Use ScanLengthPrefixed to read the records
+// back.
+func NewLengthPrefixEncoder(enc Encoder) Encoder {
+	return lengthPrefixEncoder{enc}
+}
+
+type lengthPrefixEncoder struct {
+	Encoder
+}
+
+func (e lengthPrefixEncoder) Clone() Encoder {
+	return lengthPrefixEncoder{e.Encoder.Clone()}
+}
+
+func (e lengthPrefixEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if buf == nil {
+		return nil, err
+	}
+	defer buf.Free()
+
+	var prefix [_lengthPrefixSize]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(buf.Len()))
+	out := bufferpool.Get()
+	out.Write(prefix[:])
+	out.Write(buf.Bytes())
+	return out, err
+}
+
+// ScanJSONSeq is a split function for a bufio.Scanner that returns each
+// record of an RFC 7464 JSON text sequence, such as the output of an encoder
+// wrapped by NewJSONSeqEncoder. The record separator and any trailing line
+// feed are stripped, and empty records are skipped.
+//
+// Note that bufio.Scanner limits the size of a token, 64KiB by default; use
+// its Buffer method to read larger entries.
+func ScanJSONSeq(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	rs := JSONSeqRecordSeparator[0]
+	for {
+		rest := data[advance:]
+		start := 0
+		for start < len(rest) && rest[start] == rs {
+			start++
+		}
+		if start == len(rest) {
+			// Nothing but separators; consume them only at EOF, since
+			// partial records are kept for the next call.
+			if atEOF {
+				advance = len(data)
+			}
+			return advance, nil, nil
+		}
+		end := bytes.IndexByte(rest[start:], rs)
+		if end < 0 {
+			if !atEOF {
+				// The record may be incomplete.
+				return advance, nil, nil
+			}
+			end = len(rest) - start
+		}
+		advance += start + end
+		if token = bytes.TrimSuffix(rest[start:start+end], []byte{'\n'}); len(token) > 0 {
+			return advance, token, nil
+		}
+	}
+}
+
+// ScanLengthPrefixed is a split function for a bufio.Scanner that returns
+// each record written by an encoder wrapped by NewLengthPrefixEncoder. It
+// returns io.ErrUnexpectedEOF if the input ends partway through a record.
+//
+// Note that bufio.Scanner limits the size of a token, 64KiB by default; use
+// its Buffer method to read larger entries.
+func ScanLengthPrefixed(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) < _lengthPrefixSize {
+		if atEOF && len(data) > 0 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	n := binary.BigEndian.Uint32(data)
+	if uint64(n) > uint64(len(data)-_lengthPrefixSize) {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	end := _lengthPrefixSize + int(n)
+	return end, data[_lengthPrefixSize:end], nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap"
+	. "github.com/blastbao/zap/zapcore"
+)
+
+func framingEncoderConfig() EncoderConfig {
+	return EncoderConfig{MessageKey: "msg", EncodeDuration: StringDurationEncoder}
+}
+
+// encodeAll encodes an entry per message into a single stream.
+func encodeAll(t testing.TB, enc Encoder, msgs ...string) []byte {
+	var out bytes.Buffer
+	for _, msg := range msgs {
+		buf, err := enc.EncodeEntry(Entry{Message: msg}, []Field{zap.String("k", "v")})
+		require.NoError(t, err, "Unexpected error encoding entry.")
+		out.Write(buf.Bytes())
+		buf.Free()
+	}
+	return out.Bytes()
+}
+
+func scanAll(t testing.TB, r io.Reader, split bufio.SplitFunc) ([]string, error) {
+	s := bufio.NewScanner(r)
+	s.Split(split)
+	var tokens []string
+	for s.Scan() {
+		tokens = append(tokens, s.Text())
+	}
+	return tokens, s.Err()
+}
+
+func TestJSONSeqEncoder(t *testing.T) {
+	msgs := []string{"one", "embedded\nnewline", "three"}
+	for _, enc := range []Encoder{
+		NewJSONSeqEncoder(NewJSONEncoder(framingEncoderConfig())),
+		NewJSONSeqEncoder(NewConsoleEncoder(framingEncoderConfig())),
+	} {
+		out := encodeAll(t, enc.Clone(), msgs...)
+		assert.Equal(t, 3, bytes.Count(out, []byte(JSONSeqRecordSeparator)), "Expected a separator per record.")
+
+		tokens, err := scanAll(t, bytes.NewReader(out), ScanJSONSeq)
+		require.NoError(t, err, "Unexpected error scanning records.")
+		require.Equal(t, 3, len(tokens), "Unexpected number of records: %q.", tokens)
+		for _, tok := range tokens {
+			assert.False(t, strings.HasSuffix(tok, "\n"), "Expected trailing line feed to be stripped.")
+		}
+	}
+
+	cfg := framingEncoderConfig()
+	cfg.SkipLineEnding = true
+	out := encodeAll(t, NewJSONSeqEncoder(NewJSONEncoder(cfg)), "hi")
+	assert.Equal(t, "\x1e"+`{"msg":"hi","k":"v"}`+"\n", string(out), "Expected a line feed to be added.")
+}
+
+func TestScanJSONSeq(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"only separators", "\x1e\x1e\n\x1e", nil},
+		{"truncated last record", "\x1e{\"a\":1}\n\x1e{\"b\":", []string{`{"a":1}`, `{"b":`}},
+		{"repeated separators", "\x1e\x1e1\n\x1e\x1e\x1e2\n", []string{"1", "2"}},
+		{"no trailing line feed", "\x1e1\x1e2", []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			// Feed the input a byte at a time to exercise partial records.
+			tokens, err := scanAll(t, &oneByteReader{strings.NewReader(tt.input)}, ScanJSONSeq)
+			require.NoError(t, err, "Unexpected error scanning records.")
+			assert.Equal(t, tt.want, tokens, "Unexpected records.")
+		})
+	}
+}
+
+func TestLengthPrefixEncoder(t *testing.T) {
+	cfg := framingEncoderConfig()
+	cfg.SkipLineEnding = true
+	enc := NewLengthPrefixEncoder(NewJSONEncoder(cfg)).Clone()
+
+	out := encodeAll(t, enc, "one", "embedded\nnewline")
+	assert.Equal(t, "\x00\x00\x00\x15"+`{"msg":"one","k":"v"}`, string(out[:25]), "Unexpected first record.")
+
+	tokens, err := scanAll(t, &oneByteReader{bytes.NewReader(out)}, ScanLengthPrefixed)
+	require.NoError(t, err, "Unexpected error scanning records.")
+	assert.Equal(t, []string{
+		`{"msg":"one","k":"v"}`,
+		`{"msg":"embedded\nnewline","k":"v"}`,
+	}, tokens, "Unexpected records.")
+}
+
+func TestScanLengthPrefixedErrors(t *testing.T) {
+	for _, input := range []string{"\x00\x00", "\x00\x00\x00\x05abc"} {
+		_, err := scanAll(t, strings.NewReader(input), ScanLengthPrefixed)
+		assert.Equal(t, io.ErrUnexpectedEOF, err, "Expected an error for truncated input %q.", input)
+	}
+
+	tokens, err := scanAll(t, strings.NewReader("\x00\x00\x00\x00"), ScanLengthPrefixed)
+	require.NoError(t, err, "Unexpected error scanning an empty record.")
+	assert.Equal(t, []string{""}, tokens, "Expected an empty record.")
+}
+
+// oneByteReader returns at most one byte per call to Read.
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return o.r.Read(p)
+}
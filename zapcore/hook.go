@@ -27,6 +27,11 @@ type hooked struct {
 	funcs []func(Entry) error
 }
 
+// Unwrap returns the wrapped Core.
+func (h *hooked) Unwrap() Core {
+	return h.Core
+}
+
 // RegisterHooks wraps a Core and runs a collection of user-defined callback
 // hooks each time a message is logged. Execution of the callbacks is blocking.
 //
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncreaseLevel(t *testing.T) {
+	tests := []struct {
+		coreLevel     Level
+		increaseLevel Level
+		wantErr       bool
+		with          []Field
+	}{
+		{
+			coreLevel:     InfoLevel,
+			increaseLevel: DebugLevel,
+			wantErr:       true,
+		},
+		{
+			coreLevel:     InfoLevel,
+			increaseLevel: InfoLevel,
+		},
+		{
+			coreLevel:     InfoLevel,
+			increaseLevel: ErrorLevel,
+		},
+		{
+			coreLevel:     InfoLevel,
+			increaseLevel: ErrorLevel,
+			with:          []Field{makeInt64Field("k", 1)},
+		},
+		{
+			coreLevel:     ErrorLevel,
+			increaseLevel: DebugLevel,
+			wantErr:       true,
+		},
+		{
+			coreLevel:     TraceLevel,
+			increaseLevel: WarnLevel,
+		},
+	}
+
+	for _, tt := range tests {
+		msg := fmt.Sprintf("increase %v to %v", tt.coreLevel, tt.increaseLevel)
+		t.Run(msg, func(t *testing.T) {
+			core, logs := observer.New(tt.coreLevel)
+
+			filteredLogger, err := NewIncreaseLevelCore(core, tt.increaseLevel)
+			if tt.wantErr {
+				require.Error(t, err, "Expected an error increasing the level.")
+				assert.Contains(t, err.Error(), "invalid increase level", "Unexpected error.")
+				return
+			}
+			require.NoError(t, err, "Unexpected error increasing the level.")
+
+			if len(tt.with) > 0 {
+				filteredLogger = filteredLogger.With(tt.with)
+			}
+
+			for l := TraceLevel; l <= FatalLevel; l++ {
+				enabled := filteredLogger.Enabled(l)
+				entry := Entry{Level: l}
+				ce := filteredLogger.Check(entry, nil)
+				ce.Write()
+				entries := logs.TakeAll()
+
+				if l >= tt.increaseLevel {
+					assert.True(t, enabled, "Expected level %v to be enabled.", l)
+					assert.NotNil(t, ce, "Expected non-nil checked entry at level %v.", l)
+					require.Equal(t, 1, len(entries), "Expected one logged entry at level %v.", l)
+					assert.Equal(t, len(tt.with), len(entries[0].Context), "Unexpected context.")
+				} else {
+					assert.False(t, enabled, "Expected level %v to be disabled.", l)
+					assert.Nil(t, ce, "Expected nil checked entry at level %v.", l)
+					assert.Equal(t, 0, len(entries), "No logs should have been output at level %v.", l)
+				}
+
+				assert.NoError(t, filteredLogger.Write(entry, nil), "Unexpected error writing directly.")
+				assert.Equal(t, 1, logs.Len(), "Expected direct writes to reach the wrapped Core.")
+				logs.TakeAll()
+				assert.NoError(t, filteredLogger.Sync(), "Unexpected error syncing.")
+			}
+		})
+	}
+}
@@ -23,6 +23,8 @@ package zapcore
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"hash/crc32"
 	"math"
 	"sync"
 	"time"
@@ -56,6 +58,10 @@ func putJSONEncoder(enc *jsonEncoder) {
 	enc.buf = nil
 	enc.spaced = false
 	enc.openNamespaces = 0
+	enc.nesting = 0
+	enc.keys = enc.keys[:0]
+	enc.nonFinite = 0
+	enc.entTime = time.Time{}
 	enc.reflectBuf = nil
 	enc.reflectEnc = nil
 	_jsonPool.Put(enc)
@@ -71,12 +77,28 @@ type jsonEncoder struct {
 	//
 	buf            *buffer.Buffer
 
+	// pool supplies buf, sized by EncoderConfig.BufferSize.
+	pool buffer.Pool
+
 	//
 	spaced         bool // include spaces after colons and commas
 
 	//
 	openNamespaces int
 
+	// When DeduplicateKeys is set, keys records where each top-level key
+	// (that is, one not inside an array or object value) starts in buf, and
+	// nesting counts the arrays and objects currently being appended.
+	keys    []keyRecord
+	nesting int
+
+	// nonFinite counts the NaN and infinite floats written, for StrictJSON.
+	nonFinite int
+
+	// entTime is the time of the entry being encoded, which SinceType and
+	// UntilType fields are measured from. It's zero outside EncodeEntry.
+	entTime time.Time
+
 	// for encoding generic values by reflection
 	reflectBuf *buffer.Buffer
 	reflectEnc *json.Encoder
@@ -87,7 +109,8 @@ type jsonEncoder struct {
 //
 // The encoder appropriately escapes all field keys and values.
 //
-// Note that the encoder doesn't deduplicate keys, so it's possible to produce a message like
+// Note that unless EncoderConfig.DeduplicateKeys is set, the encoder doesn't
+// deduplicate keys, so it's possible to produce a message like
 //   {
 //   	"foo":"bar",
 //   	"foo":"baz"
@@ -104,9 +127,16 @@ func NewJSONEncoder(cfg EncoderConfig) Encoder {
 }
 
 func newJSONEncoder(cfg EncoderConfig, spaced bool) *jsonEncoder {
+	if cfg.TimeLocation != "" && cfg.EncodeTime != nil {
+		if loc, err := time.LoadLocation(cfg.TimeLocation); err == nil {
+			cfg.EncodeTime = TimeEncoderIn(cfg.EncodeTime, loc)
+		}
+	}
+	pool := bufferpool.Sized(cfg.BufferSize)
 	return &jsonEncoder{
 		EncoderConfig: &cfg,
-		buf:           bufferpool.Get(),
+		buf:           pool.Get(),
+		pool:          pool,
 		spaced:        spaced,
 	}
 }
@@ -122,11 +152,30 @@ func (enc *jsonEncoder) AddObject(key string, obj ObjectMarshaler) error {
 }
 
 func (enc *jsonEncoder) AddBinary(key string, val []byte) {
-	enc.AddString(key, base64.StdEncoding.EncodeToString(val))
+	if enc.EncoderConfig == nil || enc.EncodeBinary == nil {
+		enc.AddString(key, base64.StdEncoding.EncodeToString(val))
+		return
+	}
+	enc.addKey(key)
+	cur := enc.buf.Len()
+	enc.EncodeBinary(val, enc)
+	if cur == enc.buf.Len() {
+		// User-supplied EncodeBinary is a no-op. Fall back to base64 to keep
+		// JSON valid.
+		enc.AppendString(base64.StdEncoding.EncodeToString(val))
+	}
 }
 
 func (enc *jsonEncoder) AddByteString(key string, val []byte) {
 	enc.addKey(key)
+	if n := truncatedByteLen(val, enc.maxFieldBytes()); n < len(val) {
+		enc.addElementSeparator()
+		enc.buf.AppendByte('"')
+		enc.safeAddByteString(val[:n])
+		enc.addTruncationMarker(len(val) - n)
+		enc.buf.AppendByte('"')
+		return
+	}
 	enc.AppendByteString(val)
 }
 
@@ -178,15 +227,42 @@ func (enc *jsonEncoder) AddReflected(key string, obj interface{}) error {
 
 func (enc *jsonEncoder) OpenNamespace(key string) {
 	enc.addKey(key)
+	if n := len(enc.keys); n > 0 && enc.recordsKeys() {
+		enc.keys[n-1].namespace = true
+	}
 	enc.buf.AppendByte('{')
 	enc.openNamespaces++
 }
 
 func (enc *jsonEncoder) AddString(key, val string) {
 	enc.addKey(key)
+	if n := truncatedLen(val, enc.maxFieldBytes()); n < len(val) {
+		enc.addElementSeparator()
+		enc.buf.AppendByte('"')
+		enc.safeAddString(val[:n])
+		enc.addTruncationMarker(len(val) - n)
+		enc.buf.AppendByte('"')
+		return
+	}
 	enc.AppendString(val)
 }
 
+// maxFieldBytes returns the configured MaxFieldBytes, if any.
+func (enc *jsonEncoder) maxFieldBytes() int {
+	if enc.EncoderConfig == nil {
+		return 0
+	}
+	return enc.MaxFieldBytes
+}
+
+// addTruncationMarker appends the marker TruncateString ends values with,
+// inside a quoted string.
+func (enc *jsonEncoder) addTruncationMarker(cut int) {
+	enc.buf.AppendString("...(+")
+	enc.buf.AppendInt(int64(cut))
+	enc.buf.AppendString(" bytes)")
+}
+
 func (enc *jsonEncoder) AddTime(key string, val time.Time) {
 	enc.addKey(key)
 	enc.AppendTime(val)
@@ -200,7 +276,9 @@ func (enc *jsonEncoder) AddUint64(key string, val uint64) {
 func (enc *jsonEncoder) AppendArray(arr ArrayMarshaler) error {
 	enc.addElementSeparator()
 	enc.buf.AppendByte('[')
+	enc.nesting++
 	err := arr.MarshalLogArray(enc)
+	enc.nesting--
 	enc.buf.AppendByte(']')
 	return err
 }
@@ -208,7 +286,9 @@ func (enc *jsonEncoder) AppendArray(arr ArrayMarshaler) error {
 func (enc *jsonEncoder) AppendObject(obj ObjectMarshaler) error {
 	enc.addElementSeparator()
 	enc.buf.AppendByte('{')
+	enc.nesting++
 	err := obj.MarshalLogObject(enc)
+	enc.nesting--
 	enc.buf.AppendByte('}')
 	return err
 }
@@ -315,15 +395,22 @@ func (enc *jsonEncoder) AppendUintptr(v uintptr)            { enc.AppendUint64(u
 func (enc *jsonEncoder) Clone() Encoder {
 	clone := enc.clone()
 	clone.buf.Write(enc.buf.Bytes())
+	clone.keys = append(clone.keys, enc.keys...)
+	clone.nonFinite = enc.nonFinite
 	return clone
 }
 
+func (enc *jsonEncoder) entryTime() time.Time {
+	return enc.entTime
+}
+
 func (enc *jsonEncoder) clone() *jsonEncoder {
 	clone := getJSONEncoder()
 	clone.EncoderConfig = enc.EncoderConfig
 	clone.spaced = enc.spaced
 	clone.openNamespaces = enc.openNamespaces
-	clone.buf = bufferpool.Get()
+	clone.pool = enc.pool
+	clone.buf = enc.pool.Get()
 	return clone
 }
 
@@ -335,13 +422,24 @@ func (enc *jsonEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer,
 
 	final := enc.clone()
 
-	// 添加开始符号
+	// 元数据位于最外层，不属于 context 中尚未关闭的 namespace
+	final.openNamespaces = 0
+	final.entTime = ent.Time
+
+	// 添加记录前缀和开始符号
+	final.buf.AppendString(final.RecordPrefix)
 	final.buf.AppendByte('{')
 
+	// 添加 schema 版本，位于最前面，便于下游解析器据此选择格式
+	if final.SchemaKey != "" {
+		final.addRawKey(final.SchemaKey)
+		final.AppendString(final.SchemaValue.Load())
+	}
+
 	// 检查日志级别，添加 `"key": ent.Level`
 
 	if final.LevelKey != "" {
-		final.addKey(final.LevelKey)
+		final.addRawKey(final.LevelKey)
 		cur := final.buf.Len()
 		final.EncodeLevel(ent.Level, final)
 		if cur == final.buf.Len() {
@@ -353,13 +451,14 @@ func (enc *jsonEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer,
 
 	// 添加 timestamp
 	if final.TimeKey != "" {
-		final.AddTime(final.TimeKey, ent.Time)
+		final.addRawKey(final.TimeKey)
+		final.AppendTime(ent.Time)
 	}
 
 
 	// 添加 logger name
 	if ent.LoggerName != "" && final.NameKey != "" {
-		final.addKey(final.NameKey)
+		final.addRawKey(final.NameKey)
 		cur := final.buf.Len()
 		nameEncoder := final.EncodeName
 
@@ -379,7 +478,7 @@ func (enc *jsonEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer,
 
 	// 添加 调用者信息
 	if ent.Caller.Defined && final.CallerKey != "" {
-		final.addKey(final.CallerKey)
+		final.addRawKey(final.CallerKey)
 		cur := final.buf.Len()
 		final.EncodeCaller(ent.Caller, final)
 		if cur == final.buf.Len() {
@@ -391,35 +490,62 @@ func (enc *jsonEncoder) EncodeEntry(ent Entry, fields []Field) (*buffer.Buffer,
 
 	// 添加 日志内容
 	if final.MessageKey != "" {
-		final.addKey(enc.MessageKey)
+		final.addRawKey(enc.MessageKey)
 		final.AppendString(ent.Message)
 	}
 
+	// 添加 EncodeExtra 注入的顶层字段
+	if final.EncodeExtra != nil {
+		final.EncodeExtra(ent, final)
+	}
+
 	if enc.buf.Len() > 0 {
-		final.addElementSeparator()
+		final.mergeKeys(enc.keys)
 		final.buf.Write(enc.buf.Bytes())
 	}
+	final.nonFinite += enc.nonFinite
+	final.openNamespaces = enc.openNamespaces
 
-	// 添加一组字段信息
+	// 添加一组字段信息，TrailingKeys 对应的字段放在最后
+	if len(final.TrailingKeys) > 0 {
+		fields = moveTrailingFields(fields, final.TrailingKeys)
+	}
 	addFields(final, fields)
 
 	final.closeOpenNamespaces()
+	final.openNamespaces = 0
 
 	// 添加堆栈信息
-	if ent.Stack != "" && final.StacktraceKey != "" {
-		final.AddString(final.StacktraceKey, ent.Stack)
+	if final.StacktraceKey != "" {
+		if stack := ent.Stacktrace(); stack != "" {
+			final.addRawKey(final.StacktraceKey)
+			final.AppendString(stack)
+		}
+	}
+
+	// 添加校验和字段，它总是最后一个字段
+	if final.ChecksumKey != "" {
+		final.appendChecksum()
 	}
 
 	// 添加结束符号
 	final.buf.AppendByte('}')
 
-	// 添加换行符
-	if final.LineEnding != "" {
-		final.buf.AppendString(final.LineEnding)
-	} else {
-		final.buf.AppendString(DefaultLineEnding)
+	// 严格模式下校验输出是否为合法的 JSON
+	if final.StrictJSON && !json.Valid(final.buf.Bytes()[len(final.RecordPrefix):]) {
+		final.buf.Free()
+		putJSONEncoder(final)
+		return nil, errors.New("strict JSON: dropped an entry that isn't valid JSON")
 	}
 
+	// 统计把 NaN 和 Inf 写成字符串的日志条数
+	if final.nonFinite > 0 && final.Stats != nil {
+		final.Stats.nonFinite.Inc()
+	}
+
+	// 添加换行符
+	final.appendLineEnding(final.buf)
+
 	// 返回 bytes
 	ret := final.buf
 
@@ -440,8 +566,36 @@ func (enc *jsonEncoder) closeOpenNamespaces() {
 }
 
 
-// 添加一个 key 到 buf 中
+// appendChecksum adds the ChecksumKey field, whose value is the checksum of
+// the object encoded so far as if it were closed without the field. The key
+// isn't recorded for DeduplicateKeys, since removing an earlier field would
+// invalidate the checksum.
+func (enc *jsonEncoder) appendChecksum() {
+	record := enc.buf.Bytes()[len(enc.RecordPrefix):]
+	sum := crc32.Update(crc32.Checksum(record, _crc32c), _crc32c, []byte{'}'})
+
+	enc.addElementSeparator()
+	enc.buf.AppendByte('"')
+	enc.safeAddString(enc.ChecksumKey)
+	enc.buf.AppendString(`":"`)
+	enc.buf.AppendString(formatChecksum(sum))
+	enc.buf.AppendByte('"')
+}
+
+// 添加一个字段的 key 到 buf 中，key 会先经过 KeyMapper 转换
 func (enc *jsonEncoder) addKey(key string) {
+	if enc.EncoderConfig != nil && enc.KeyMapper != nil {
+		key = enc.KeyMapper(key)
+	}
+	enc.addRawKey(key)
+}
+
+// addRawKey adds a key as-is. It's used for the entry's metadata, whose keys
+// come straight from the EncoderConfig and aren't mapped.
+func (enc *jsonEncoder) addRawKey(key string) {
+	if enc.recordsKeys() {
+		enc.recordKey(key)
+	}
 	enc.addElementSeparator()
 	enc.buf.AppendByte('"')
 	enc.safeAddString(key)
@@ -452,6 +606,86 @@ func (enc *jsonEncoder) addKey(key string) {
 	}
 }
 
+// A keyRecord locates a top-level key in a jsonEncoder's buffer. The key's
+// bytes, including any preceding separator, run from start to the start of
+// the next record (or the end of the buffer).
+type keyRecord struct {
+	key       string
+	level     int // the number of open namespaces when the key was added
+	start     int
+	namespace bool
+}
+
+// recordsKeys reports whether the next key should be recorded for
+// deduplication.
+func (enc *jsonEncoder) recordsKeys() bool {
+	return enc.nesting == 0 && enc.EncoderConfig != nil && enc.DeduplicateKeys
+}
+
+// recordKey removes any earlier field with the same key at the current
+// level, then records the key about to be written.
+func (enc *jsonEncoder) recordKey(key string) {
+	for i := len(enc.keys) - 1; i >= 0; i-- {
+		r := enc.keys[i]
+		if r.key == key && r.level == enc.openNamespaces && !r.namespace {
+			enc.removeKey(i)
+			break
+		}
+	}
+	enc.keys = append(enc.keys, keyRecord{key: key, level: enc.openNamespaces, start: enc.buf.Len()})
+}
+
+// mergeKeys prepares to append the context fields recorded in keys: it
+// removes the metadata fields whose keys they reuse, writes the separator,
+// and adopts the records.
+func (enc *jsonEncoder) mergeKeys(keys []keyRecord) {
+	n := len(enc.keys)
+	for _, r := range keys {
+		for i := n - 1; i >= 0; i-- {
+			if enc.keys[i].key == r.key && enc.keys[i].level == r.level {
+				enc.removeKey(i)
+				n--
+				break
+			}
+		}
+	}
+	enc.addElementSeparator()
+	offset := enc.buf.Len()
+	for _, r := range keys {
+		r.start += offset
+		enc.keys = append(enc.keys, r)
+	}
+}
+
+// removeKey deletes the i'th recorded field from the buffer, along with one
+// of the element separators around it.
+func (enc *jsonEncoder) removeKey(i int) {
+	bs := enc.buf.Bytes()
+	start, end := enc.keys[i].start, len(bs)
+	if i+1 < len(enc.keys) {
+		end = enc.keys[i+1].start
+	}
+	if bs[start] != ',' && end < len(bs) && bs[end] == ',' {
+		// The first field in an object has no leading separator, so take the
+		// following one instead.
+		end++
+		if enc.spaced {
+			end++
+		}
+	}
+
+	tail := bs[end:]
+	enc.buf.Reset()
+	enc.buf.Write(bs[:start])
+	enc.buf.Write(tail)
+
+	removed := end - start
+	for j := i + 1; j < len(enc.keys); j++ {
+		enc.keys[j].start -= removed
+	}
+	enc.keys = append(enc.keys[:i], enc.keys[i+1:]...)
+}
+
 // 添加元素分隔符
 func (enc *jsonEncoder) addElementSeparator() {
 
@@ -481,10 +715,13 @@ func (enc *jsonEncoder) appendFloat(val float64, bitSize int) {
 	enc.addElementSeparator()
 	switch {
 	case math.IsNaN(val):
+		enc.nonFinite++
 		enc.buf.AppendString(`"NaN"`)
 	case math.IsInf(val, 1):
+		enc.nonFinite++
 		enc.buf.AppendString(`"+Inf"`)
 	case math.IsInf(val, -1):
+		enc.nonFinite++
 		enc.buf.AppendString(`"-Inf"`)
 	default:
 		enc.buf.AppendFloat(val, bitSize)
@@ -38,7 +38,7 @@ import (
 
 func TestJSONClone(t *testing.T) {
 	// The parent encoder is created with plenty of excess capacity.
-	parent := &jsonEncoder{buf: bufferpool.Get()}
+	parent := &jsonEncoder{buf: bufferpool.Get(), pool: bufferpool.Sized(0)}
 	clone := parent.Clone()
 
 	// Adding to the parent shouldn't affect the clone, and vice versa.
@@ -477,3 +477,17 @@ func TestJSONQuick(t *testing.T) {
 	check(asciiRoundTripsCorrectlyString)
 	check(asciiRoundTripsCorrectlyByteString)
 }
+
+func TestJSONEncoderStrictDropsInvalid(t *testing.T) {
+	enc := NewJSONEncoder(EncoderConfig{MessageKey: "M", StrictJSON: true, RecordPrefix: "@cee: "}).(*jsonEncoder)
+	buf, err := enc.EncodeEntry(Entry{Message: "ok"}, nil)
+	if assert.NoError(t, err, "Unexpected error encoding a valid entry.") {
+		assert.Equal(t, "@cee: {\"M\":\"ok\"}\n", buf.String(), "Unexpected encoded entry.")
+	}
+
+	// Simulate a buggy marshaler that's left context unterminated.
+	enc.buf.AppendString(`"broken":[`)
+	buf, err = enc.EncodeEntry(Entry{Message: "bad"}, nil)
+	assert.Nil(t, buf, "Expected invalid JSON to be dropped.")
+	assert.Error(t, err, "Expected invalid JSON to be reported.")
+}
@@ -21,12 +21,17 @@
 package zapcore_test
 
 import (
+	"encoding/json"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/internal/ztest"
 	"github.com/blastbao/zap/zapcore"
 )
 
@@ -120,3 +125,480 @@ func TestJSONEncodeEntry(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONEncodeEntryDeduplicateKeys(t *testing.T) {
+	tests := []struct {
+		desc     string
+		context  []zapcore.Field
+		fields   []zapcore.Field
+		expected string
+	}{
+		{
+			desc:     "call site overrides context",
+			context:  []zapcore.Field{zap.String("user", "alice"), zap.Int("n", 1)},
+			fields:   []zapcore.Field{zap.String("user", "bob")},
+			expected: `{"L":"info","M":"hi","n":1,"user":"bob"}`,
+		},
+		{
+			desc:     "duplicates within one set of fields",
+			fields:   []zapcore.Field{zap.Int("n", 1), zap.Int("m", 2), zap.Int("n", 3), zap.Int("n", 4)},
+			expected: `{"L":"info","M":"hi","m":2,"n":4}`,
+		},
+		{
+			desc:     "first context field overridden",
+			context:  []zapcore.Field{zap.Int("a", 1), zap.Int("b", 2)},
+			fields:   []zapcore.Field{zap.Int("a", 3)},
+			expected: `{"L":"info","M":"hi","b":2,"a":3}`,
+		},
+		{
+			desc:     "fields override metadata",
+			context:  []zapcore.Field{zap.String("M", "context")},
+			fields:   []zapcore.Field{zap.String("L", "call site")},
+			expected: `{"M":"context","L":"call site"}`,
+		},
+		{
+			desc:     "nested objects aren't deduplicated",
+			fields:   []zapcore.Field{zap.Object("obj", addDuplicates{}), zap.Int("k", 3)},
+			expected: `{"L":"info","M":"hi","obj":{"k":1,"k":2},"k":3}`,
+		},
+		{
+			desc:     "namespaces",
+			context:  []zapcore.Field{zap.Int("a", 1), zap.Namespace("ns"), zap.Int("a", 2), zap.Int("b", 3)},
+			fields:   []zapcore.Field{zap.Int("a", 4)},
+			expected: `{"L":"info","M":"hi","a":1,"ns":{"b":3,"a":4}}`,
+		},
+		{
+			desc:     "namespaces aren't replaced",
+			fields:   []zapcore.Field{zap.Namespace("ns"), zap.Int("ns", 1)},
+			expected: `{"L":"info","M":"hi","ns":{"ns":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+				MessageKey:      "M",
+				LevelKey:        "L",
+				EncodeLevel:     zapcore.LowercaseLevelEncoder,
+				DeduplicateKeys: true,
+			})
+			for _, f := range tt.context {
+				f.AddTo(enc)
+			}
+			buf, err := enc.Clone().EncodeEntry(zapcore.Entry{Message: "hi"}, tt.fields)
+			if assert.NoError(t, err, "Unexpected JSON encoding error.") {
+				assert.Equal(t, tt.expected+"\n", buf.String(), "Incorrect encoded JSON entry.")
+			}
+			buf.Free()
+		})
+	}
+}
+
+func TestJSONEncodeEntryKeepsDuplicateKeysByDefault(t *testing.T) {
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "M"})
+	enc.AddInt("n", 1)
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi"}, []zapcore.Field{zap.Int("n", 2)})
+	if assert.NoError(t, err, "Unexpected JSON encoding error.") {
+		assert.Equal(t, `{"M":"hi","n":1,"n":2}`+"\n", buf.String(), "Expected duplicate keys to be kept.")
+	}
+	buf.Free()
+}
+
+func TestConsoleEncodeEntryDeduplicateKeys(t *testing.T) {
+	enc := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{MessageKey: "M", DeduplicateKeys: true})
+	enc.AddInt("a", 1)
+	enc.AddInt("b", 2)
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi"}, []zapcore.Field{zap.Int("a", 3)})
+	if assert.NoError(t, err, "Unexpected console encoding error.") {
+		assert.Equal(t, "hi\t{\"b\": 2, \"a\": 3}\n", buf.String(), "Incorrect encoded console entry.")
+	}
+	buf.Free()
+}
+
+func TestEncodeEntryExtra(t *testing.T) {
+	extra := func(ent zapcore.Entry, enc zapcore.ObjectEncoder) {
+		enc.AddString("host", "web-1")
+		enc.AddInt64("pid", 42)
+		if ent.LoggerName != "" {
+			enc.AddString("component", ent.LoggerName)
+		}
+	}
+	tests := []struct {
+		desc     string
+		newEnc   func(zapcore.EncoderConfig) zapcore.Encoder
+		context  []zapcore.Field
+		fields   []zapcore.Field
+		name     string
+		dedupe   bool
+		expected string
+	}{
+		{
+			desc:     "json",
+			newEnc:   zapcore.NewJSONEncoder,
+			fields:   []zapcore.Field{zap.Int("n", 1)},
+			expected: `{"M":"hi","host":"web-1","pid":42,"n":1}`,
+		},
+		{
+			desc:     "json with entry metadata",
+			newEnc:   zapcore.NewJSONEncoder,
+			name:     "db",
+			expected: `{"M":"hi","host":"web-1","pid":42,"component":"db"}`,
+		},
+		{
+			desc:     "json context in a namespace",
+			newEnc:   zapcore.NewJSONEncoder,
+			context:  []zapcore.Field{zap.Int("a", 1), zap.Namespace("ns")},
+			fields:   []zapcore.Field{zap.Int("b", 2)},
+			expected: `{"M":"hi","host":"web-1","pid":42,"a":1,"ns":{"b":2}}`,
+		},
+		{
+			desc:     "json fields override extras when deduplicating",
+			newEnc:   zapcore.NewJSONEncoder,
+			context:  []zapcore.Field{zap.String("host", "override")},
+			dedupe:   true,
+			expected: `{"M":"hi","pid":42,"host":"override"}`,
+		},
+		{
+			desc:     "console",
+			newEnc:   zapcore.NewConsoleEncoder,
+			fields:   []zapcore.Field{zap.Int("n", 1)},
+			expected: `hi	{"host": "web-1", "pid": 42, "n": 1}`,
+		},
+		{
+			desc:     "console context in a namespace",
+			newEnc:   zapcore.NewConsoleEncoder,
+			context:  []zapcore.Field{zap.Int("a", 1), zap.Namespace("ns")},
+			fields:   []zapcore.Field{zap.Int("b", 2)},
+			expected: `hi	{"host": "web-1", "pid": 42, "a": 1, "ns": {"b": 2}}`,
+		},
+		{
+			desc:     "console fields override extras when deduplicating",
+			newEnc:   zapcore.NewConsoleEncoder,
+			context:  []zapcore.Field{zap.String("host", "override")},
+			dedupe:   true,
+			expected: `hi	{"pid": 42, "host": "override"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := tt.newEnc(zapcore.EncoderConfig{
+				MessageKey:      "M",
+				EncodeExtra:     extra,
+				DeduplicateKeys: tt.dedupe,
+			})
+			for _, f := range tt.context {
+				f.AddTo(enc)
+			}
+			buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi", LoggerName: tt.name}, tt.fields)
+			if assert.NoError(t, err, "Unexpected encoding error.") {
+				assert.Equal(t, tt.expected+"\n", buf.String(), "Incorrect encoded entry.")
+			}
+			buf.Free()
+		})
+	}
+}
+
+func TestEncodeEntrySchema(t *testing.T) {
+	schema := zapcore.NewSchema("v1")
+	cfg := zapcore.EncoderConfig{
+		MessageKey:  "M",
+		LevelKey:    "L",
+		EncodeLevel: zapcore.LowercaseLevelEncoder,
+		SchemaKey:   "schema",
+		SchemaValue: schema,
+	}
+	jsonEnc := zapcore.NewJSONEncoder(cfg)
+	console := zapcore.NewConsoleEncoder(cfg)
+	zap.Int("a", 1).AddTo(jsonEnc)
+	zap.Int("a", 1).AddTo(console)
+	clone := jsonEnc.Clone()
+
+	encode := func(enc zapcore.Encoder) string {
+		buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi"}, []zapcore.Field{zap.Int("n", 2)})
+		if !assert.NoError(t, err, "Unexpected encoding error.") {
+			return ""
+		}
+		defer buf.Free()
+		return buf.String()
+	}
+
+	assert.Equal(t, `{"schema":"v1","L":"info","M":"hi","a":1,"n":2}`+"\n", encode(jsonEnc), "Unexpected JSON entry.")
+	assert.Equal(t, "info\thi\t{\"schema\": \"v1\", \"a\": 1, \"n\": 2}\n", encode(console), "Unexpected console entry.")
+
+	assert.Equal(t, "v1", schema.Set("v2"), "Expected Set to return the previous schema.")
+	assert.Equal(t, `{"schema":"v2","L":"info","M":"hi","a":1,"n":2}`+"\n", encode(jsonEnc), "Expected a bumped schema.")
+	assert.Equal(t, `{"schema":"v2","L":"info","M":"hi","a":1,"n":2}`+"\n", encode(clone), "Expected clones to see a bumped schema.")
+	assert.Equal(t, "info\thi\t{\"schema\": \"v2\", \"a\": 1, \"n\": 2}\n", encode(console), "Expected a bumped schema.")
+
+	cfg.SchemaValue = nil
+	assert.Equal(t, `{"schema":"","L":"info","M":"hi","n":2}`+"\n", encode(zapcore.NewJSONEncoder(cfg)), "Expected a nil schema to be stamped as an empty string.")
+}
+
+func TestSchemaUnmarshal(t *testing.T) {
+	var cfg zapcore.EncoderConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"schemaKey":"v","schemaValue":"2024-01"}`), &cfg), "Unexpected error unmarshaling config.")
+	assert.Equal(t, "v", cfg.SchemaKey, "Unexpected schema key.")
+	assert.Equal(t, "2024-01", cfg.SchemaValue.Load(), "Unexpected schema value.")
+
+	data, err := json.Marshal(cfg.SchemaValue)
+	require.NoError(t, err, "Unexpected error marshaling schema.")
+	assert.Equal(t, `"2024-01"`, string(data), "Unexpected marshaled schema.")
+}
+
+func TestEncodeEntryKeyMapper(t *testing.T) {
+	mapper := zapcore.KeyMap(map[string]string{
+		"user":   "user.name",
+		"host":   "host.name",
+		"msg":    "message",
+		"req":    "http",
+		"method": "http.method",
+		"id":     "user.name",
+	})
+	extra := func(_ zapcore.Entry, enc zapcore.ObjectEncoder) {
+		enc.AddString("host", "web-1")
+	}
+	tests := []struct {
+		desc     string
+		newEnc   func(zapcore.EncoderConfig) zapcore.Encoder
+		context  []zapcore.Field
+		fields   []zapcore.Field
+		dedupe   bool
+		expected string
+	}{
+		{
+			desc:     "json",
+			newEnc:   zapcore.NewJSONEncoder,
+			context:  []zapcore.Field{zap.String("user", "bob")},
+			fields:   []zapcore.Field{zap.Int("attempt", 1), zap.String("msg", "field")},
+			expected: `{"L":"info","msg":"hi","host.name":"web-1","user.name":"bob","attempt":1,"message":"field"}`,
+		},
+		{
+			desc:    "json namespaces and nested objects",
+			newEnc:  zapcore.NewJSONEncoder,
+			context: []zapcore.Field{zap.Namespace("req")},
+			fields: []zapcore.Field{zap.Object("user", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+				enc.AddString("id", "42")
+				return nil
+			})), zap.String("method", "GET")},
+			expected: `{"L":"info","msg":"hi","host.name":"web-1","http":{"user.name":{"user.name":"42"},"http.method":"GET"}}`,
+		},
+		{
+			desc:     "json deduplicates mapped keys",
+			newEnc:   zapcore.NewJSONEncoder,
+			context:  []zapcore.Field{zap.String("user", "bob")},
+			fields:   []zapcore.Field{zap.String("id", "alice")},
+			dedupe:   true,
+			expected: `{"L":"info","msg":"hi","host.name":"web-1","user.name":"alice"}`,
+		},
+		{
+			desc:     "console",
+			newEnc:   zapcore.NewConsoleEncoder,
+			context:  []zapcore.Field{zap.String("user", "bob")},
+			fields:   []zapcore.Field{zap.String("msg", "field")},
+			expected: "info\thi\t{\"host.name\": \"web-1\", \"user.name\": \"bob\", \"message\": \"field\"}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := tt.newEnc(zapcore.EncoderConfig{
+				LevelKey:        "L",
+				MessageKey:      "msg",
+				EncodeLevel:     zapcore.LowercaseLevelEncoder,
+				EncodeExtra:     extra,
+				DeduplicateKeys: tt.dedupe,
+				KeyMapper:       mapper,
+			})
+			for _, f := range tt.context {
+				f.AddTo(enc)
+			}
+			buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi"}, tt.fields)
+			if assert.NoError(t, err, "Unexpected encoding error.") {
+				assert.Equal(t, tt.expected+"\n", buf.String(), "Incorrect encoded entry.")
+			}
+			buf.Free()
+		})
+	}
+}
+
+func TestEncodeEntryMaxFieldBytes(t *testing.T) {
+	long := strings.Repeat("x", 10)
+	tests := []struct {
+		desc     string
+		newEnc   func(zapcore.EncoderConfig) zapcore.Encoder
+		context  []zapcore.Field
+		fields   []zapcore.Field
+		expected string
+	}{
+		{
+			desc:     "json strings and byte strings",
+			newEnc:   zapcore.NewJSONEncoder,
+			context:  []zapcore.Field{zap.String("ctx", long)},
+			fields:   []zapcore.Field{zap.String("short", "abc"), zap.ByteString("bytes", []byte(long)), zap.String("quoted", `"""""""`)},
+			expected: `{"msg":"` + long + `","ctx":"xxxx...(+6 bytes)","short":"abc","bytes":"xxxx...(+6 bytes)","quoted":"\"\"\"\"...(+3 bytes)"}`,
+		},
+		{
+			desc:     "json keeps UTF-8 sequences whole",
+			newEnc:   zapcore.NewJSONEncoder,
+			fields:   []zapcore.Field{zap.String("k", "aaaéb")},
+			expected: `{"msg":"` + long + `","k":"aaa...(+3 bytes)"}`,
+		},
+		{
+			desc:   "json nested objects and arrays",
+			newEnc: zapcore.NewJSONEncoder,
+			fields: []zapcore.Field{
+				zap.Object("obj", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+					enc.AddString("k", long)
+					return nil
+				})),
+				zap.Strings("arr", []string{long}),
+			},
+			expected: `{"msg":"` + long + `","obj":{"k":"xxxx...(+6 bytes)"},"arr":["` + long + `"]}`,
+		},
+		{
+			desc:     "console",
+			newEnc:   zapcore.NewConsoleEncoder,
+			fields:   []zapcore.Field{zap.String("k", long)},
+			expected: long + "\t{\"k\": \"xxxx...(+6 bytes)\"}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := tt.newEnc(zapcore.EncoderConfig{MessageKey: "msg", MaxFieldBytes: 4})
+			for _, f := range tt.context {
+				f.AddTo(enc)
+			}
+			buf, err := enc.EncodeEntry(zapcore.Entry{Message: long}, tt.fields)
+			if assert.NoError(t, err, "Unexpected encoding error.") {
+				assert.Equal(t, tt.expected+"\n", buf.String(), "Incorrect encoded entry.")
+			}
+			buf.Free()
+		})
+	}
+}
+
+func TestEncodeEntryBufferSize(t *testing.T) {
+	tests := []struct {
+		desc   string
+		newEnc func(zapcore.EncoderConfig) zapcore.Encoder
+		size   int
+		minCap int
+	}{
+		{"json default", zapcore.NewJSONEncoder, 0, 1024},
+		{"json sized", zapcore.NewJSONEncoder, 16 << 10, 16 << 10},
+		{"console sized", zapcore.NewConsoleEncoder, 16 << 10, 16 << 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := tt.newEnc(zapcore.EncoderConfig{MessageKey: "msg", BufferSize: tt.size})
+			enc.AddString("k", "v")
+			buf, err := enc.Clone().EncodeEntry(zapcore.Entry{Message: "hello"}, nil)
+			require.NoError(t, err, "Unexpected encoding error.")
+			assert.True(t, buf.Cap() >= tt.minCap, "Expected a buffer of at least %v bytes, got %v.", tt.minCap, buf.Cap())
+			assert.Contains(t, buf.String(), "hello", "Unexpected encoded entry.")
+			buf.Free()
+		})
+	}
+}
+
+func TestEncodeEntryOmitEmpty(t *testing.T) {
+	tests := []struct {
+		desc     string
+		cfg      zapcore.EncoderConfig
+		console  bool
+		expected string
+	}{
+		{
+			desc:     "disabled",
+			cfg:      zapcore.EncoderConfig{MessageKey: "M"},
+			expected: `{"M":"hi","user":"","id":0,"n":0,"tags":[],"ok":true}`,
+		},
+		{
+			desc:     "globally",
+			cfg:      zapcore.EncoderConfig{MessageKey: "M", OmitEmpty: true},
+			expected: `{"M":"hi","ok":true}`,
+		},
+		{
+			desc:     "by key",
+			cfg:      zapcore.EncoderConfig{MessageKey: "M", OmitEmptyKeys: []string{"user", "n", "ok"}},
+			expected: `{"M":"hi","id":0,"tags":[],"ok":true}`,
+		},
+		{
+			desc:     "console",
+			cfg:      zapcore.EncoderConfig{MessageKey: "M", OmitEmpty: true},
+			console:  true,
+			expected: "hi\t{\"ok\": true}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var enc zapcore.Encoder = zapcore.NewJSONEncoder(tt.cfg)
+			if tt.console {
+				enc = zapcore.NewConsoleEncoder(tt.cfg)
+			}
+			buf := &ztest.Buffer{}
+			core := zapcore.NewCore(enc, buf, zapcore.DebugLevel).With([]zapcore.Field{
+				zap.String("user", ""),
+				zap.Int("id", 0),
+			})
+			core.Write(zapcore.Entry{Message: "hi"}, []zapcore.Field{
+				zap.Int("n", 0),
+				zap.Strings("tags", nil),
+				zap.Bool("ok", true),
+			})
+			assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected encoded entry.")
+		})
+	}
+}
+
+type addDuplicates struct{}
+
+func (addDuplicates) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("k", 1)
+	enc.AddInt("k", 2)
+	return nil
+}
+
+func TestJSONEncoderStrict(t *testing.T) {
+	fields := []zapcore.Field{zap.Float64("ratio", math.NaN()), zap.Float32s("bounds", []float32{float32(math.Inf(-1)), 1})}
+
+	for _, strict := range []bool{false, true} {
+		buf := &ztest.Buffer{}
+		stats := &zapcore.EncodeStats{}
+		enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "M", StrictJSON: strict, Stats: stats})
+		core := zapcore.NewCore(enc, buf, zapcore.DebugLevel)
+
+		assert.NoError(t, core.Write(zapcore.Entry{Message: "hi"}, fields), "Unexpected error writing non-finite floats.")
+		assert.Equal(t, `{"M":"hi","ratio":"NaN","bounds":["-Inf",1]}`, buf.Stripped(), "Expected the entry to be written either way.")
+		assert.Equal(t, uint64(1), stats.NonFinite(), "Expected the entry to be counted.")
+
+		assert.NoError(t, core.Write(zapcore.Entry{Message: "hi"}, nil), "Unexpected error.")
+		assert.Equal(t, uint64(1), stats.NonFinite(), "Expected only entries with non-finite floats to be counted.")
+	}
+}
+
+func TestJSONEncoderBinary(t *testing.T) {
+	tests := []struct {
+		desc     string
+		encode   zapcore.BinaryEncoder
+		expected string
+	}{
+		{"default", nil, `{"M":"hi","body":"3q2+7w==","id":"deadbeef"}`},
+		{"hex", zapcore.HexBinaryEncoder, `{"M":"hi","body":"deadbeef","id":"deadbeef"}`},
+		{"no-op", func([]byte, zapcore.PrimitiveArrayEncoder) {}, `{"M":"hi","body":"3q2+7w==","id":"deadbeef"}`},
+	}
+	data := []byte{0xde, 0xad, 0xbe, 0xef}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "M", EncodeBinary: tt.encode})
+			buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hi"}, []zapcore.Field{zap.Binary("body", data), zap.Hex("id", data)})
+			if assert.NoError(t, err, "Unexpected error encoding entry.") {
+				assert.Equal(t, tt.expected+"\n", buf.String(), "Unexpected encoded entry.")
+			}
+		})
+	}
+}
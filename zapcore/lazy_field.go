@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import "sync"
+
+// A LazyField defers building a Field until it's first encoded. Since cores
+// only encode entries that pass Check (and, if sampling is enabled, the
+// sampler), the cost of computing the field's value is only paid for entries
+// that are actually written.
+//
+// The underlying Field is built at most once, even if the LazyField is
+// encoded by several cores or from several goroutines. Note that fields
+// passed to a logger's With method are encoded immediately.
+type LazyField struct {
+	once  sync.Once
+	build func() Field
+	field Field
+}
+
+// NewLazyField creates a LazyField that calls build the first time its value
+// is needed. The key of the returned Field is used when encoding.
+func NewLazyField(build func() Field) *LazyField {
+	return &LazyField{build: build}
+}
+
+// Field builds the underlying Field, if it hasn't been built already, and
+// returns it.
+func (l *LazyField) Field() Field {
+	l.once.Do(func() {
+		l.field = l.build()
+		l.build = nil
+	})
+	return l.field
+}
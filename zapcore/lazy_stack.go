@@ -0,0 +1,54 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import "sync"
+
+// A LazyStack is a stack trace captured as raw program counters. Turning
+// program counters into function names, files, and lines is much more
+// expensive than capturing them, so LazyStack defers that work until the
+// stack trace is first needed, typically when an encoder writes the entry.
+//
+// The stack trace is symbolized at most once, so a LazyStack may be shared by
+// several cores and used from several goroutines.
+type LazyStack struct {
+	once   sync.Once
+	pcs    []uintptr
+	format func([]uintptr) string
+	str    string
+}
+
+// NewLazyStack creates a LazyStack from captured program counters. format is
+// called once, with pcs, to produce the stack trace's string form. The
+// LazyStack takes ownership of pcs.
+func NewLazyStack(pcs []uintptr, format func(pcs []uintptr) string) *LazyStack {
+	return &LazyStack{pcs: pcs, format: format}
+}
+
+// String symbolizes the stack trace, if it hasn't been already, and returns
+// it.
+func (s *LazyStack) String() string {
+	s.once.Do(func() {
+		s.str = s.format(s.pcs)
+		s.pcs, s.format = nil, nil
+	})
+	return s.str
+}
@@ -24,9 +24,20 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 )
 
-var errUnmarshalNilLevel = errors.New("can't unmarshal a nil *Level")
+var (
+	errUnmarshalNilLevel    = errors.New("can't unmarshal a nil *Level")
+	errNoLevelNameSpecified = errors.New("no level name specified")
+
+	// _levelNames and _namedLevels hold the levels added with RegisterLevel.
+	_levelNames  = make(map[Level]string)
+	_namedLevels = make(map[string]Level)
+	_levelMutex  sync.RWMutex
+)
 
 // A Level is a logging priority. Higher levels are more important.
 type Level int8
@@ -51,13 +62,80 @@ const (
 	// FatalLevel logs a message, then calls os.Exit(1).
 	FatalLevel
 
-	_minLevel = DebugLevel
+	// TraceLevel logs are even more voluminous than Debug logs, for very
+	// chatty subsystems that are usually only enabled while investigating
+	// them.
+	TraceLevel = DebugLevel - 1
+
+	_minLevel = TraceLevel
 	_maxLevel = FatalLevel
 )
 
+// RegisterLevel names an additional Level, so that it's encoded, parsed
+// (for example, in Config and by AtomicLevel's HTTP handler), and printed
+// with that name. Names are matched case-insensitively when parsed and
+// printed in lower case by String and upper case by CapitalString. Whether
+// a registered level is enabled follows from its value, like any other:
+// for example, a level registered as DebugLevel-2 sits below TraceLevel.
+//
+// Attempting to register a name or level that's already taken, including
+// the built-in levels, returns an error. FatalLevel+1 is reserved: it's the
+// conventional level for enabling nothing, for example in zap.AddStacktrace,
+// so it can't be registered either.
+func RegisterLevel(l Level, name string) error {
+	name = strings.ToLower(name)
+	if name == "" {
+		return errNoLevelNameSpecified
+	}
+	var existing Level
+	if existing.unmarshalText([]byte(name)) {
+		return fmt.Errorf("level name %q is already taken by level %d", name, existing)
+	}
+	if l >= _minLevel && l <= _maxLevel {
+		return fmt.Errorf("level %d is already named %q", l, l.String())
+	}
+	if l == _maxLevel+1 {
+		return fmt.Errorf("level %d is reserved for disabling logging", l)
+	}
+
+	_levelMutex.Lock()
+	defer _levelMutex.Unlock()
+	if prev, ok := _levelNames[l]; ok {
+		return fmt.Errorf("level %d is already named %q", l, prev)
+	}
+	if prev, ok := _namedLevels[name]; ok {
+		return fmt.Errorf("level name %q is already taken by level %d", name, prev)
+	}
+	_levelNames[l] = name
+	_namedLevels[name] = l
+	return nil
+}
+
+// RegisteredLevels returns the levels added with RegisterLevel, sorted.
+func RegisteredLevels() []Level {
+	_levelMutex.RLock()
+	defer _levelMutex.RUnlock()
+	levels := make([]Level, 0, len(_levelNames))
+	for l := range _levelNames {
+		levels = append(levels, l)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	return levels
+}
+
+// registeredName returns the name a level was registered with, if any.
+func (l Level) registeredName() (string, bool) {
+	_levelMutex.RLock()
+	name, ok := _levelNames[l]
+	_levelMutex.RUnlock()
+	return name, ok
+}
+
 // String returns a lower-case ASCII representation of the log level.
 func (l Level) String() string {
 	switch l {
+	case TraceLevel:
+		return "trace"
 	case DebugLevel:
 		return "debug"
 	case InfoLevel:
@@ -73,6 +151,9 @@ func (l Level) String() string {
 	case FatalLevel:
 		return "fatal"
 	default:
+		if name, ok := l.registeredName(); ok {
+			return name
+		}
 		return fmt.Sprintf("Level(%d)", l)
 	}
 }
@@ -82,6 +163,8 @@ func (l Level) CapitalString() string {
 	// Printing levels in all-caps is common enough that we should export this
 	// functionality.
 	switch l {
+	case TraceLevel:
+		return "TRACE"
 	case DebugLevel:
 		return "DEBUG"
 	case InfoLevel:
@@ -97,6 +180,9 @@ func (l Level) CapitalString() string {
 	case FatalLevel:
 		return "FATAL"
 	default:
+		if name, ok := l.registeredName(); ok {
+			return strings.ToUpper(name)
+		}
 		return fmt.Sprintf("LEVEL(%d)", l)
 	}
 }
@@ -117,14 +203,26 @@ func (l *Level) UnmarshalText(text []byte) error {
 	if l == nil {
 		return errUnmarshalNilLevel
 	}
-	if !l.unmarshalText(text) && !l.unmarshalText(bytes.ToLower(text)) {
+	if !l.unmarshalText(text) && !l.unmarshalText(bytes.ToLower(text)) && !l.unmarshalRegistered(text) {
 		return fmt.Errorf("unrecognized level: %q", text)
 	}
 	return nil
 }
 
+func (l *Level) unmarshalRegistered(text []byte) bool {
+	_levelMutex.RLock()
+	defer _levelMutex.RUnlock()
+	lvl, ok := _namedLevels[string(bytes.ToLower(text))]
+	if ok {
+		*l = lvl
+	}
+	return ok
+}
+
 func (l *Level) unmarshalText(text []byte) bool {
 	switch string(text) {
+	case "trace", "TRACE":
+		*l = TraceLevel
 	case "debug", "DEBUG":
 		*l = DebugLevel
 	case "info", "INFO", "": // make the zero value useful
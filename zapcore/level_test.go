@@ -23,14 +23,17 @@ package zapcore
 import (
 	"bytes"
 	"flag"
+	"io/ioutil"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLevelString(t *testing.T) {
 	tests := map[Level]string{
+		TraceLevel:  "trace",
 		DebugLevel:  "debug",
 		InfoLevel:   "info",
 		WarnLevel:   "warn",
@@ -52,6 +55,7 @@ func TestLevelText(t *testing.T) {
 		text  string
 		level Level
 	}{
+		{"trace", TraceLevel},
 		{"debug", DebugLevel},
 		{"info", InfoLevel},
 		{"", InfoLevel}, // make the zero value useful
@@ -81,6 +85,7 @@ func TestCapitalLevelsParse(t *testing.T) {
 		text  string
 		level Level
 	}{
+		{"TRACE", TraceLevel},
 		{"DEBUG", DebugLevel},
 		{"INFO", InfoLevel},
 		{"WARN", WarnLevel},
@@ -175,3 +180,74 @@ func TestLevelAsFlagValue(t *testing.T) {
 		"Unexpected error output from invalid flag input.",
 	)
 }
+
+func TestRegisterLevel(t *testing.T) {
+	const (
+		finest = TraceLevel - 1
+		audit  = FatalLevel + 2
+	)
+	defer func() {
+		_levelMutex.Lock()
+		for _, l := range []Level{finest, audit} {
+			delete(_namedLevels, _levelNames[l])
+			delete(_levelNames, l)
+		}
+		_levelMutex.Unlock()
+	}()
+
+	require.NoError(t, RegisterLevel(finest, "Finest"), "Unexpected error registering a level.")
+	require.NoError(t, RegisterLevel(audit, "audit"), "Unexpected error registering a level.")
+	assert.Equal(t, []Level{finest, audit}, RegisteredLevels(), "Unexpected registered levels.")
+
+	assert.Equal(t, "finest", finest.String(), "Unexpected lowercase name.")
+	assert.Equal(t, "FINEST", finest.CapitalString(), "Unexpected all-caps name.")
+	var parsed Level
+	require.NoError(t, parsed.UnmarshalText([]byte("AuDiT")), "Unexpected error parsing a registered level.")
+	assert.Equal(t, audit, parsed, "Unexpected parsed level.")
+	assert.True(t, finest.Enabled(TraceLevel), "Expected levels to be ordered by value.")
+	assert.False(t, TraceLevel.Enabled(finest), "Expected levels to be ordered by value.")
+
+	tests := []struct {
+		lvl  Level
+		name string
+		err  string
+	}{
+		{finest - 1, "", "no level name specified"},
+		{finest - 1, "DEBUG", `level name "debug" is already taken by level -1`},
+		{finest - 1, "finest", `level name "finest" is already taken by level -3`},
+		{InfoLevel, "notice", `level 0 is already named "info"`},
+		{audit, "security", `level 7 is already named "audit"`},
+		{FatalLevel + 1, "off", "level 6 is reserved"},
+	}
+	for _, tt := range tests {
+		err := RegisterLevel(tt.lvl, tt.name)
+		if assert.Error(t, err, "Expected registering %v as %q to fail.", tt.lvl, tt.name) {
+			assert.Contains(t, err.Error(), tt.err, "Unexpected error.")
+		}
+	}
+}
+
+// levelRange enables the levels from min to max, inclusive.
+type levelRange struct{ min, max Level }
+
+func (r levelRange) Enabled(l Level) bool { return l >= r.min && l <= r.max }
+
+func TestIncreaseLevelRegisteredLevels(t *testing.T) {
+	const audit = FatalLevel + 2
+	defer func() {
+		_levelMutex.Lock()
+		delete(_namedLevels, _levelNames[audit])
+		delete(_levelNames, audit)
+		_levelMutex.Unlock()
+	}()
+
+	core := NewCore(NewJSONEncoder(EncoderConfig{}), AddSync(ioutil.Discard), levelRange{DebugLevel, FatalLevel})
+	_, err := NewIncreaseLevelCore(core, ErrorLevel)
+	require.NoError(t, err, "Unexpected error without registered levels.")
+
+	require.NoError(t, RegisterLevel(audit, "audit"), "Unexpected error registering a level.")
+	_, err = NewIncreaseLevelCore(core, ErrorLevel)
+	if assert.Error(t, err, "Expected an error enabling a registered level the core doesn't.") {
+		assert.Contains(t, err.Error(), `level "audit" is allowed`, "Unexpected error.")
+	}
+}
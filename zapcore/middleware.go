@@ -0,0 +1,302 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+)
+
+// Wrap applies middlewares to a Core, returning the result. Each middleware
+// wraps the Core built so far, and the first one is outermost: Wrap(core, a,
+// b) is equivalent to a(b(core)), so entries pass through a before b.
+//
+// The middlewares in this package (FilterEntries, Enrich, Redact, and
+// Metrics) cover common cross-cutting concerns; NewSampler, NewFilterCore,
+// NewCardinalityCore, and RegisterHooks can be adapted with a closure.
+func Wrap(core Core, middlewares ...func(Core) Core) Core {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		core = middlewares[i](core)
+	}
+	return core
+}
+
+// writeChecked sends an entry through a Core's Check, so that its own
+// filtering (for example, sampling) still applies, and writes the fields to
+// every Core that accepted it.
+func writeChecked(core Core, ent Entry, fields []Field) error {
+	ce := core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	var err error
+	for i := range ce.cores {
+		err = multierr.Append(err, ce.cores[i].Write(ce.Entry, fields))
+	}
+	putCheckedEntry(ce)
+	return err
+}
+
+// FilterEntries returns a middleware that drops every entry for which keep
+// returns false. Since it runs during Check, keep sees the entry's level,
+// message, logger name, and time, but not its fields.
+func FilterEntries(keep func(Entry) bool) func(Core) Core {
+	return func(core Core) Core {
+		return &entryFilterCore{Core: core, keep: keep}
+	}
+}
+
+type entryFilterCore struct {
+	Core
+	keep func(Entry) bool
+}
+
+// Unwrap returns the wrapped Core.
+func (c *entryFilterCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *entryFilterCore) With(fields []Field) Core {
+	return &entryFilterCore{Core: c.Core.With(fields), keep: c.keep}
+}
+
+func (c *entryFilterCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if !c.keep(ent) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// NewFilterCore wraps a Core so that entries are only written if keep
+// returns true. Unlike FilterEntries, keep also sees the entry's fields:
+// those added with With, followed by those supplied at the log site. This
+// can silence a noisy subsystem by message, logger name, or field value
+// without changing its code:
+//
+//	core = zapcore.NewFilterCore(core, func(ent zapcore.Entry, fields []zapcore.Field) bool {
+//		return !strings.HasPrefix(ent.LoggerName, "thirdparty.cache")
+//	})
+//
+// Since fields are only known at write time, keep runs after the level check
+// but before any work in the wrapped Core. It must not modify or retain the
+// slice.
+func NewFilterCore(core Core, keep func(Entry, []Field) bool) Core {
+	return &filterCore{Core: core, keep: keep}
+}
+
+type filterCore struct {
+	Core
+	keep    func(Entry, []Field) bool
+	context []Field
+}
+
+// Unwrap returns the wrapped Core.
+func (c *filterCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *filterCore) With(fields []Field) Core {
+	return &filterCore{
+		Core:    c.Core.With(fields),
+		keep:    c.keep,
+		context: append(c.context[:len(c.context):len(c.context)], fields...),
+	}
+}
+
+func (c *filterCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *filterCore) Write(ent Entry, fields []Field) error {
+	all := fields
+	if len(c.context) > 0 {
+		all = append(c.context[:len(c.context):len(c.context)], fields...)
+	}
+	if !c.keep(ent, all) {
+		return nil
+	}
+	return writeChecked(c.Core, ent, fields)
+}
+
+// Enrich returns a middleware that adds the fields returned by f to every
+// entry, after the fields supplied at the log site. Unlike With, f is called
+// for each entry, so the fields may depend on the entry or on state that
+// changes over time.
+func Enrich(f func(Entry) []Field) func(Core) Core {
+	return func(core Core) Core {
+		return &enrichCore{Core: core, enrich: f}
+	}
+}
+
+type enrichCore struct {
+	Core
+	enrich func(Entry) []Field
+}
+
+// Unwrap returns the wrapped Core.
+func (c *enrichCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *enrichCore) With(fields []Field) Core {
+	return &enrichCore{Core: c.Core.With(fields), enrich: c.enrich}
+}
+
+func (c *enrichCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *enrichCore) Write(ent Entry, fields []Field) error {
+	if extra := c.enrich(ent); len(extra) > 0 {
+		// Don't append to the caller's slice.
+		fields = append(fields[:len(fields):len(fields)], extra...)
+	}
+	return writeChecked(c.Core, ent, fields)
+}
+
+// RedactedValue replaces the values of fields dropped by Redact.
+const RedactedValue = "[REDACTED]"
+
+// Redact returns a middleware that replaces the values of fields with the
+// given keys by RedactedValue, whether they're added with With or at the log
+// site. Only top-level keys are considered.
+func Redact(keys ...string) func(Core) Core {
+	set := newKeySet(keys)
+	return func(core Core) Core {
+		return &redactCore{Core: core, keys: set}
+	}
+}
+
+type redactCore struct {
+	Core
+	keys map[string]struct{}
+}
+
+// Unwrap returns the wrapped Core.
+func (c *redactCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *redactCore) With(fields []Field) Core {
+	return &redactCore{Core: c.Core.With(c.redact(fields)), keys: c.keys}
+}
+
+func (c *redactCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent Entry, fields []Field) error {
+	return writeChecked(c.Core, ent, c.redact(fields))
+}
+
+// redact returns fields with sensitive values replaced, copying the slice
+// only if necessary.
+func (c *redactCore) redact(fields []Field) []Field {
+	out := fields
+	for i := range fields {
+		if _, ok := c.keys[fields[i].Key]; !ok {
+			continue
+		}
+		if &out[0] == &fields[0] {
+			out = append([]Field(nil), fields...)
+		}
+		out[i] = Field{Key: fields[i].Key, Type: StringType, String: RedactedValue}
+	}
+	return out
+}
+
+// CoreMetrics counts the entries that pass through a Core wrapped with
+// Metrics. It's safe for concurrent use, and the zero value is ready to use.
+type CoreMetrics struct {
+	written [_numLevels]atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// Written returns the number of entries at the given level that were handed
+// to the wrapped Core's outputs, including those whose writes failed.
+func (m *CoreMetrics) Written(lvl Level) uint64 {
+	if lvl < _minLevel || lvl > _maxLevel {
+		return 0
+	}
+	return m.written[lvl-_minLevel].Load()
+}
+
+// Failed returns the number of entries whose writes returned an error.
+func (m *CoreMetrics) Failed() uint64 {
+	return m.failed.Load()
+}
+
+// Metrics returns a middleware that counts entries in m. Entries dropped by
+// the wrapped Core, for example by sampling, aren't counted.
+func Metrics(m *CoreMetrics) func(Core) Core {
+	return func(core Core) Core {
+		return &metricsCore{Core: core, metrics: m}
+	}
+}
+
+type metricsCore struct {
+	Core
+	metrics *CoreMetrics
+}
+
+// Unwrap returns the wrapped Core.
+func (c *metricsCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *metricsCore) With(fields []Field) Core {
+	return &metricsCore{Core: c.Core.With(fields), metrics: c.metrics}
+}
+
+func (c *metricsCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *metricsCore) Write(ent Entry, fields []Field) error {
+	ce := c.Core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	if ent.Level >= _minLevel && ent.Level <= _maxLevel {
+		c.metrics.written[ent.Level-_minLevel].Inc()
+	}
+	var err error
+	for i := range ce.cores {
+		err = multierr.Append(err, ce.cores[i].Write(ce.Entry, fields))
+	}
+	putCheckedEntry(ce)
+	if err != nil {
+		c.metrics.failed.Inc()
+	}
+	return err
+}
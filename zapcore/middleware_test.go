@@ -0,0 +1,165 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blastbao/zap/internal/ztest"
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func writeEntry(core Core, lvl Level, msg string, fields ...Field) {
+	if ce := core.Check(Entry{Level: lvl, Message: msg}, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+func TestWrapOrder(t *testing.T) {
+	var order []string
+	record := func(name string) func(Core) Core {
+		return FilterEntries(func(Entry) bool {
+			order = append(order, name)
+			return true
+		})
+	}
+
+	fac, logs := observer.New(DebugLevel)
+	core := Wrap(fac, record("outer"), record("inner"))
+	writeEntry(core, InfoLevel, "hello")
+	assert.Equal(t, []string{"outer", "inner"}, order, "Expected the first middleware to be outermost.")
+	assert.Equal(t, 1, logs.Len(), "Expected the entry to be written.")
+
+	assert.Equal(t, fac, Wrap(fac), "Expected Wrap without middlewares to return the Core.")
+}
+
+func TestFilterEntries(t *testing.T) {
+	fac, logs := observer.New(DebugLevel)
+	core := Wrap(fac, FilterEntries(func(ent Entry) bool {
+		return ent.Message != "noisy"
+	})).With([]Field{{Key: "k", Type: StringType, String: "v"}})
+
+	writeEntry(core, InfoLevel, "noisy")
+	writeEntry(core, InfoLevel, "useful")
+	require.Equal(t, 1, logs.Len(), "Expected filtered entries to be dropped.")
+	entry := logs.All()[0]
+	assert.Equal(t, "useful", entry.Message, "Unexpected message.")
+	assert.Equal(t, map[string]interface{}{"k": "v"}, entry.ContextMap(), "Expected With to keep the filter.")
+}
+
+func TestFilterCore(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	var seen [][]Field
+	core := NewFilterCore(fac, func(ent Entry, fields []Field) bool {
+		seen = append(seen, fields)
+		if ent.LoggerName == "noisy" {
+			return false
+		}
+		for _, f := range fields {
+			if f.Key == "status" && f.Integer == 200 {
+				return false
+			}
+		}
+		return true
+	})
+	component := core.With([]Field{{Key: "component", Type: StringType, String: "http"}})
+
+	if ce := core.Check(Entry{Level: InfoLevel, LoggerName: "noisy", Message: "chatter"}, nil); ce != nil {
+		ce.Write()
+	}
+	writeEntry(component, InfoLevel, "ok", Field{Key: "status", Type: Int64Type, Integer: 200})
+	writeEntry(component, InfoLevel, "failed", Field{Key: "status", Type: Int64Type, Integer: 500})
+	writeEntry(component, DebugLevel, "disabled")
+
+	require.Equal(t, 1, logs.Len(), "Expected only one entry to pass the filter.")
+	assert.Equal(t, map[string]interface{}{
+		"component": "http",
+		"status":    int64(500),
+	}, logs.All()[0].ContextMap(), "Unexpected fields.")
+	require.Equal(t, 3, len(seen), "Expected the filter to skip disabled levels.")
+	assert.Equal(t, "component", seen[1][0].Key, "Expected fields added with With to come first.")
+}
+
+func TestEnrich(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := Wrap(fac, Enrich(func(ent Entry) []Field {
+		return []Field{{Key: "level_name", Type: StringType, String: ent.Level.String()}}
+	})).With([]Field{{Key: "k", Type: StringType, String: "v"}})
+
+	writeEntry(core, DebugLevel, "disabled")
+	site := []Field{{Key: "site", Type: BoolType, Integer: 1}}
+	writeEntry(core, WarnLevel, "enriched", site...)
+	require.Equal(t, 1, logs.Len(), "Expected the wrapped Core's level to apply.")
+	assert.Equal(t, map[string]interface{}{
+		"k":          "v",
+		"site":       true,
+		"level_name": "warn",
+	}, logs.All()[0].ContextMap(), "Unexpected fields.")
+	assert.Equal(t, 1, len(site[:cap(site)]), "Expected the caller's fields not to be modified.")
+}
+
+func TestRedact(t *testing.T) {
+	fac, logs := observer.New(InfoLevel)
+	core := Wrap(fac, Redact("password", "token")).With([]Field{
+		{Key: "token", Type: StringType, String: "abc"},
+		{Key: "user", Type: StringType, String: "alice"},
+	})
+
+	site := []Field{{Key: "password", Type: StringType, String: "hunter2"}}
+	writeEntry(core, InfoLevel, "login", site...)
+	require.Equal(t, 1, logs.Len(), "Expected an entry.")
+	assert.Equal(t, map[string]interface{}{
+		"token":    RedactedValue,
+		"user":     "alice",
+		"password": RedactedValue,
+	}, logs.All()[0].ContextMap(), "Expected sensitive fields to be redacted.")
+	assert.Equal(t, "hunter2", site[0].String, "Expected the caller's fields not to be modified.")
+}
+
+func TestMetrics(t *testing.T) {
+	var m CoreMetrics
+	fac, _ := observer.New(DebugLevel)
+	core := Wrap(fac, Metrics(&m), func(c Core) Core {
+		return NewSampler(c, time.Minute, 2, 1000)
+	})
+
+	for i := 0; i < 5; i++ {
+		writeEntry(core, InfoLevel, "sampled")
+	}
+	writeEntry(core, ErrorLevel, "error")
+	assert.Equal(t, uint64(2), m.Written(InfoLevel), "Expected sampled-out entries not to be counted.")
+	assert.Equal(t, uint64(1), m.Written(ErrorLevel), "Unexpected count of error entries.")
+	assert.Equal(t, uint64(0), m.Written(Level(42)), "Expected unknown levels to report zero.")
+	assert.Equal(t, uint64(0), m.Failed(), "Unexpected failures.")
+
+	failed := Wrap(
+		NewCore(NewJSONEncoder(EncoderConfig{}), AddSync(ztest.FailWriter{}), DebugLevel),
+		Metrics(&m),
+	)
+	assert.Error(t, failed.Write(Entry{Level: WarnLevel}, nil), "Expected write errors to be returned.")
+	assert.Equal(t, uint64(1), m.Failed(), "Expected failed writes to be counted.")
+	assert.Equal(t, uint64(1), m.Written(WarnLevel), "Expected failed writes to count as written.")
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// _nameFilterCacheSize is the most logger names a name-filtering Core
+// caches the matching level of. Names are usually few, but may be built
+// from request data; past the limit, names are matched on every entry.
+const _nameFilterCacheSize = 4096
+
+// NewNameFilterCore wraps a Core so that entries from named loggers are
+// filtered by level according to their names. Each key of levels is a glob
+// pattern, in the syntax of path.Match, matched against the entry's
+// LoggerName; entries from a matching logger are dropped unless they're at
+// or above the pattern's level. For example,
+//
+//	zapcore.NewNameFilterCore(core, map[string]zapcore.Level{
+//		"net.*":      zapcore.WarnLevel,
+//		"net.http.*": zapcore.ErrorLevel,
+//		"db":         zapcore.InfoLevel,
+//	})
+//
+// quiets everything under the "net" logger to warnings, and the HTTP client
+// under it further still. When several patterns match a name, the longest
+// one wins. Entries from loggers that don't match any pattern are left to
+// the wrapped Core.
+//
+// The filter can only drop entries: levels below the wrapped Core's own have
+// no effect. It returns an error if any pattern is malformed.
+func NewNameFilterCore(core Core, levels map[string]Level) (Core, error) {
+	patterns := make([]namePattern, 0, len(levels))
+	for pattern, lvl := range levels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid logger name pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, namePattern{pattern, lvl})
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].pattern) != len(patterns[j].pattern) {
+			return len(patterns[i].pattern) > len(patterns[j].pattern)
+		}
+		return patterns[i].pattern < patterns[j].pattern
+	})
+	return &nameFilterCore{
+		Core:     core,
+		patterns: patterns,
+		levels:   &sync.Map{},
+		cached:   atomic.NewInt32(0),
+	}, nil
+}
+
+type namePattern struct {
+	pattern string
+	level   Level
+}
+
+type nameFilterCore struct {
+	Core
+	patterns []namePattern
+	// levels caches the result of matching each logger name against the
+	// patterns: a *Level, or nil if no pattern matches. cached counts the
+	// names in it, up to _nameFilterCacheSize.
+	levels *sync.Map
+	cached *atomic.Int32
+}
+
+// Unwrap returns the wrapped Core.
+func (c *nameFilterCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *nameFilterCore) With(fields []Field) Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	return &clone
+}
+
+func (c *nameFilterCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if lvl := c.levelFor(ent.LoggerName); lvl != nil && !lvl.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// levelFor returns the level of the longest pattern matching name, or nil
+// if none does.
+func (c *nameFilterCore) levelFor(name string) *Level {
+	if cached, ok := c.levels.Load(name); ok {
+		return cached.(*Level)
+	}
+	var lvl *Level
+	for i := range c.patterns {
+		if ok, _ := path.Match(c.patterns[i].pattern, name); ok {
+			lvl = &c.patterns[i].level
+			break
+		}
+	}
+	if c.cached.Inc() <= _nameFilterCacheSize {
+		c.levels.Store(name, lvl)
+	} else {
+		c.cached.Dec()
+	}
+	return lvl
+}
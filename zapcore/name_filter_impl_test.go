@@ -0,0 +1,49 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameFilterCoreCacheSize(t *testing.T) {
+	core, err := NewNameFilterCore(NewNopCore(), map[string]Level{"req.*": WarnLevel})
+	assert.NoError(t, err, "Unexpected error building core.")
+	nf := core.(*nameFilterCore)
+
+	for i := 0; i < _nameFilterCacheSize+100; i++ {
+		lvl := nf.levelFor(fmt.Sprintf("req.%d", i))
+		if assert.NotNil(t, lvl, "Expected a matching pattern.") {
+			assert.Equal(t, WarnLevel, *lvl, "Unexpected level.")
+		}
+	}
+	assert.Equal(t, int32(_nameFilterCacheSize), nf.cached.Load(), "Expected the cache to stop growing.")
+
+	var cached int
+	nf.levels.Range(func(_, _ interface{}) bool {
+		cached++
+		return true
+	})
+	assert.Equal(t, _nameFilterCacheSize, cached, "Unexpected number of cached names.")
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"testing"
+
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameFilterCore(t *testing.T) {
+	levels := map[string]Level{
+		"net.*":      WarnLevel,
+		"net.http.*": ErrorLevel,
+		"db":         InfoLevel,
+		"verbose":    TraceLevel,
+	}
+	tests := []struct {
+		name    string
+		level   Level
+		enabled bool
+	}{
+		{"", DebugLevel, true},
+		{"app", DebugLevel, true},
+		{"net", DebugLevel, true},
+		{"net.dns", InfoLevel, false},
+		{"net.dns", WarnLevel, true},
+		{"net.http.client", WarnLevel, false},
+		{"net.http.client", ErrorLevel, true},
+		{"db", DebugLevel, false},
+		{"db", InfoLevel, true},
+		{"db.pool", DebugLevel, true},
+		{"verbose", DebugLevel, true},
+	}
+
+	core, logs := observer.New(DebugLevel)
+	filtered, err := NewNameFilterCore(core, levels)
+	require.NoError(t, err, "Unexpected error building name filter.")
+	filtered = filtered.With([]Field{makeInt64Field("k", 1)})
+
+	for _, tt := range tests {
+		// Twice, to exercise the cache.
+		for i := 0; i < 2; i++ {
+			ent := Entry{LoggerName: tt.name, Level: tt.level, Message: "hello"}
+			if ce := filtered.Check(ent, nil); ce != nil {
+				ce.Write()
+			}
+			entries := logs.TakeAll()
+			if !tt.enabled {
+				assert.Empty(t, entries, "Expected %v entry from %q to be dropped.", tt.level, tt.name)
+				continue
+			}
+			if assert.Equal(t, 1, len(entries), "Expected %v entry from %q to be written.", tt.level, tt.name) {
+				assert.Equal(t, []Field{makeInt64Field("k", 1)}, entries[0].Context, "Unexpected context.")
+			}
+		}
+	}
+
+	assert.False(t, filtered.Enabled(TraceLevel), "Expected the wrapped Core's level to apply.")
+	assert.True(t, filtered.Enabled(DebugLevel), "Expected the wrapped Core's level to apply.")
+}
+
+func TestNameFilterCoreErrors(t *testing.T) {
+	core, _ := observer.New(DebugLevel)
+	_, err := NewNameFilterCore(core, map[string]Level{"net.[": WarnLevel})
+	require.Error(t, err, "Expected an error for a malformed pattern.")
+	assert.Contains(t, err.Error(), `invalid logger name pattern "net.["`, "Unexpected error message.")
+}
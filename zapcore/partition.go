@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"fmt"
+	"time"
+)
+
+// ISOWeekLayout is a partition layout that formats an entry's time as its
+// ISO 8601 year and week, such as "2024-W20". It isn't a time.Format layout;
+// PartitionKey recognizes it specially.
+const ISOWeekLayout = "2006-Www"
+
+// PartitionKey formats t, converted to UTC, for use as a partition key.
+// layout is either ISOWeekLayout or a layout for time.Format, such as
+// "2006-01-02" for daily partitions or "2006-01-02T15" for hourly ones.
+func PartitionKey(t time.Time, layout string) string {
+	t = t.UTC()
+	if layout == ISOWeekLayout {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	}
+	return t.Format(layout)
+}
+
+// NewPartitionKeyCore wraps a Core so that each entry is written with an
+// extra string field, under key, holding its partition key: the entry's
+// time formatted with layout (see PartitionKey). Sinks that store logs in
+// object stores or partitioned tables can route entries by that field
+// without parsing timestamps, whatever the encoder's time format.
+func NewPartitionKeyCore(core Core, key, layout string) Core {
+	return &partitionKeyCore{
+		Core:   core,
+		key:    key,
+		layout: layout,
+	}
+}
+
+type partitionKeyCore struct {
+	Core
+	key    string
+	layout string
+}
+
+// Unwrap returns the wrapped Core.
+func (c *partitionKeyCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *partitionKeyCore) With(fields []Field) Core {
+	return &partitionKeyCore{
+		Core:   c.Core.With(fields),
+		key:    c.key,
+		layout: c.layout,
+	}
+}
+
+func (c *partitionKeyCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *partitionKeyCore) Write(ent Entry, fields []Field) error {
+	// Don't modify the caller's slice.
+	fields = append(fields[:len(fields):len(fields)], Field{
+		Key:    c.key,
+		Type:   StringType,
+		String: PartitionKey(ent.Time, c.layout),
+	})
+	return writeChecked(c.Core, ent, fields)
+}
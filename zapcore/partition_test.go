@@ -0,0 +1,72 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionKey(t *testing.T) {
+	// 2024-05-17 is a Friday in ISO week 20; 2021-01-01 belongs to the last
+	// week of 2020.
+	est := time.FixedZone("EST", -5*60*60)
+	tests := []struct {
+		t      time.Time
+		layout string
+		want   string
+	}{
+		{time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC), "2006-01-02", "2024-05-17"},
+		{time.Date(2024, 5, 17, 21, 0, 0, 0, est), "2006-01-02", "2024-05-18"},
+		{time.Date(2024, 5, 17, 9, 30, 0, 0, time.UTC), "2006/01/02/15", "2024/05/17/09"},
+		{time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC), ISOWeekLayout, "2024-W20"},
+		{time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC), ISOWeekLayout, "2020-W53"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, PartitionKey(tt.t, tt.layout), "Unexpected partition key for %v with layout %q.", tt.t, tt.layout)
+	}
+}
+
+func TestPartitionKeyCore(t *testing.T) {
+	core, logs := observer.New(InfoLevel)
+	pc := NewPartitionKeyCore(core, "dt", "2006-01-02").With([]Field{makeInt64Field("ctx", 1)})
+
+	writeEntry(pc, DebugLevel, "disabled")
+	fields := []Field{makeInt64Field("k", 2)}
+	ce := pc.Check(Entry{Level: InfoLevel, Message: "hello", Time: time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)}, nil)
+	require.NotNil(t, ce, "Expected enabled entries to be checked.")
+	ce.Write(fields...)
+
+	entries := logs.All()
+	require.Equal(t, 1, len(entries), "Unexpected number of entries.")
+	assert.Equal(t, map[string]interface{}{
+		"ctx": int64(1),
+		"k":   int64(2),
+		"dt":  "2024-05-17",
+	}, entries[0].ContextMap(), "Expected a partition key field.")
+	assert.Equal(t, 1, len(fields[:cap(fields)]), "Expected the caller's fields to be left alone.")
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+)
+
+// PprofLabelsKey is the key under which CorrelateProfiles records an entry's
+// pprof labels.
+const PprofLabelsKey = "pprofLabels"
+
+// CorrelateProfiles returns a middleware that ties entries at levels lvl
+// enables to profiles and execution traces, so engineers can line logs up
+// with what the program was doing during a profiling session.
+//
+// Entries logged with a context (see zap.Logger.WithContext) that carries
+// pprof labels, set with pprof.Do or pprof.WithLabels, get an extra
+// PprofLabelsKey field holding the labels as an object. While an execution
+// trace is being collected with runtime/trace, each entry is also recorded
+// as a user log event, with the entry's level as its category and its
+// message as its message; if the context belongs to a trace.Task, the event
+// is attached to the task. Entries at other levels pass through untouched.
+func CorrelateProfiles(lvl LevelEnabler) func(Core) Core {
+	return func(core Core) Core {
+		return &profilingCore{Core: core, lvl: lvl}
+	}
+}
+
+type profilingCore struct {
+	Core
+	lvl LevelEnabler
+}
+
+// Unwrap returns the wrapped Core.
+func (c *profilingCore) Unwrap() Core {
+	return c.Core
+}
+
+func (c *profilingCore) With(fields []Field) Core {
+	return &profilingCore{Core: c.Core.With(fields), lvl: c.lvl}
+}
+
+func (c *profilingCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if !c.lvl.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *profilingCore) Write(ent Entry, fields []Field) error {
+	ctx := ent.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if trace.IsEnabled() {
+		trace.Log(ctx, ent.Level.String(), ent.Message)
+	}
+	if labels := pprofLabels(ctx); len(labels) > 0 {
+		// Don't append to the caller's slice.
+		fields = append(fields[:len(fields):len(fields)], Field{
+			Key:       PprofLabelsKey,
+			Type:      ObjectMarshalerType,
+			Interface: labels,
+		})
+	}
+	return writeChecked(c.Core, ent, fields)
+}
+
+// pprofLabelSet is a set of pprof labels, sorted by key.
+type pprofLabelSet [][2]string
+
+func pprofLabels(ctx context.Context) pprofLabelSet {
+	var labels pprofLabelSet
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels = append(labels, [2]string{key, value})
+		return true
+	})
+	sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+	return labels
+}
+
+func (ls pprofLabelSet) MarshalLogObject(enc ObjectEncoder) error {
+	for _, l := range ls {
+		enc.AddString(l[0], l[1])
+	}
+	return nil
+}
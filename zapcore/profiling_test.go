@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func writeContextEntry(core Core, ctx context.Context, lvl Level, msg string) {
+	if ce := core.Check(Entry{Level: lvl, Message: msg, Context: ctx}, nil); ce != nil {
+		ce.Write()
+	}
+}
+
+func TestCorrelateProfilesLabels(t *testing.T) {
+	fac, logs := observer.New(DebugLevel)
+	core := Wrap(fac, CorrelateProfiles(InfoLevel))
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("worker", "3", "endpoint", "/users"))
+	writeContextEntry(core, ctx, InfoLevel, "labeled")
+	writeContextEntry(core, ctx, DebugLevel, "below level")
+	writeContextEntry(core, context.Background(), InfoLevel, "unlabeled")
+	writeContextEntry(core, nil, InfoLevel, "no context")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 4, "Expected every entry to be written.")
+	assert.Equal(t, map[string]interface{}{
+		PprofLabelsKey: map[string]interface{}{"endpoint": "/users", "worker": "3"},
+	}, entries[0].ContextMap(), "Expected pprof labels.")
+	for _, e := range entries[1:] {
+		assert.Empty(t, e.Context, "Expected no labels on %q.", e.Message)
+	}
+}
+
+func TestCorrelateProfilesTrace(t *testing.T) {
+	if trace.IsEnabled() {
+		t.Skip("An execution trace is already running.")
+	}
+	fac, logs := observer.New(InfoLevel)
+	core := Wrap(fac, CorrelateProfiles(WarnLevel))
+
+	var buf bytes.Buffer
+	require.NoError(t, trace.Start(&buf), "Failed to start tracing.")
+	ctx, task := trace.NewTask(context.Background(), "request")
+	writeContextEntry(core, ctx, ErrorLevel, "traced-entry")
+	writeContextEntry(core, ctx, InfoLevel, "untraced-entry")
+	writeContextEntry(core, ctx, DebugLevel, "disabled-entry")
+	task.End()
+	trace.Stop()
+
+	assert.Equal(t, 2, logs.Len(), "Expected the wrapped Core's level to apply.")
+	assert.Contains(t, buf.String(), "traced-entry", "Expected a user log event.")
+	assert.NotContains(t, buf.String(), "untraced-entry", "Expected no event below the level.")
+	assert.NotContains(t, buf.String(), "disabled-entry", "Expected no event for disabled entries.")
+}
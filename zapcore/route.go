@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+// DefaultRouteKey is the conventional key for the field added by
+// NewRouteCore.
+const DefaultRouteKey = "log_route"
+
+// NewRouteCore wraps a Core so that every entry it writes carries a string
+// field, under key, naming the route that produced it. In a configuration
+// with many sinks, naming each route of a Tee shows which path wrote a
+// misrouted or duplicated record:
+//
+//	core := zapcore.NewTee(
+//		zapcore.NewRouteCore(fileCore, zapcore.DefaultRouteKey, "file"),
+//		zapcore.NewRouteCore(kafkaCore, zapcore.DefaultRouteKey, "kafka"),
+//	)
+//
+// Routes nest: wrapping a Tee names each of its Cores, and wrapping a Core
+// that already has a route with the same key (and no fields added with
+// With) prefixes it, so an entry
+// written by the "kafka" route of a Tee routed as "remote" carries
+// "remote/kafka". Entries written by other routes of the same Tee don't
+// carry the field.
+//
+// The field is added as if with With, so it's encoded before the fields
+// added to loggers built on the Core.
+func NewRouteCore(core Core, key, name string) Core {
+	switch c := core.(type) {
+	case multiCore:
+		routed := make(multiCore, len(c))
+		for i := range c {
+			routed[i] = NewRouteCore(c[i], key, name)
+		}
+		return routed
+	case *routeCore:
+		if c.key == key && c.base != nil {
+			return newRouteCore(c.base, key, name+"/"+c.name)
+		}
+	}
+	return newRouteCore(core, key, name)
+}
+
+func newRouteCore(base Core, key, name string) *routeCore {
+	return &routeCore{
+		Core: base.With([]Field{{Key: key, Type: StringType, String: name}}),
+		base: base,
+		key:  key,
+		name: name,
+	}
+}
+
+type routeCore struct {
+	// Core has the route field added, and base doesn't. To avoid encoding
+	// fields twice, base is dropped by With, so only routes that haven't
+	// had fields added can be nested.
+	Core
+	base Core
+	key  string
+	name string
+}
+
+func (c *routeCore) With(fields []Field) Core {
+	return &routeCore{
+		Core: c.Core.With(fields),
+		key:  c.key,
+		name: c.name,
+	}
+}
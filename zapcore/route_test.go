@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/blastbao/zap"
+	. "github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+func routeOf(logs *observer.ObservedLogs) []string {
+	var routes []string
+	for _, e := range logs.AllUntimed() {
+		routes = append(routes, e.ContextMap()[DefaultRouteKey].(string))
+	}
+	return routes
+}
+
+func TestRouteCore(t *testing.T) {
+	file, fileLogs := observer.New(DebugLevel)
+	kafka, kafkaLogs := observer.New(WarnLevel)
+	plain, plainLogs := observer.New(DebugLevel)
+
+	core := NewTee(
+		NewRouteCore(file, DefaultRouteKey, "file"),
+		NewRouteCore(NewTee(NewRouteCore(kafka, DefaultRouteKey, "kafka")), DefaultRouteKey, "remote"),
+		plain,
+	).With([]Field{zap.String("user", "bob")})
+
+	writeEntry(core, InfoLevel, "info")
+	writeEntry(core, WarnLevel, "warn")
+
+	assert.Equal(t, []string{"file", "file"}, routeOf(fileLogs), "Unexpected routes for the file core.")
+	assert.Equal(t, []string{"remote/kafka"}, routeOf(kafkaLogs), "Unexpected routes for the nested core.")
+	for _, e := range plainLogs.AllUntimed() {
+		assert.Equal(t, map[string]interface{}{"user": "bob"}, e.ContextMap(), "Expected unrouted cores not to carry a route.")
+	}
+	assert.Equal(t, "bob", fileLogs.All()[0].ContextMap()["user"], "Expected fields added with With to be kept.")
+}
+
+func TestRouteCoreKeys(t *testing.T) {
+	fac, logs := observer.New(DebugLevel)
+	core := NewRouteCore(NewRouteCore(fac, "inner_route", "a"), "outer_route", "b")
+	writeEntry(core, InfoLevel, "hello")
+	assert.Equal(
+		t,
+		map[string]interface{}{"inner_route": "a", "outer_route": "b"},
+		logs.AllUntimed()[0].ContextMap(),
+		"Expected routes with different keys not to nest.",
+	)
+}
@@ -21,13 +21,14 @@
 package zapcore
 
 import (
+	"regexp"
 	"time"
 
 	"go.uber.org/atomic"
 )
 
 const (
-	_numLevels        = _maxLevel - _minLevel + 1   // 桶的总数 等于 日志级别总数 len(debug, ..., fatal)
+	_numLevels        = _maxLevel - _minLevel + 1   // 桶的总数 等于 日志级别总数 len(trace, ..., fatal)
 	_countersPerLevel = 4096 						// 每个桶有 4096 个槽
 )
 
@@ -44,6 +45,12 @@ func newCounters() *counters {
 }
 
 func (cs *counters) get(lvl Level, key string) *counter {
+	// 通过 RegisterLevel 注册的级别可能超出范围，归入最近的桶
+	if lvl < _minLevel {
+		lvl = _minLevel
+	} else if lvl > _maxLevel {
+		lvl = _maxLevel
+	}
 	i := lvl - _minLevel 				 // 根据日志级别，确定桶号
 	j := fnv32a(key) % _countersPerLevel // 哈希后取模，确定计数器槽号，相同内容的 log entry 会定位到同一个槽
 	return &cs[i][j]
@@ -110,6 +117,81 @@ type sampler struct {
 	counts            *counters
 	tick              time.Duration
 	first, thereafter uint64
+
+	// levels, if set, restricts sampling to the levels it enables; entries
+	// at other levels, and entries whose messages match exclude, are never
+	// dropped.
+	levels  LevelEnabler
+	exclude []*regexp.Regexp
+}
+
+// Unwrap returns the wrapped Core.
+func (s *sampler) Unwrap() Core {
+	return s.Core
+}
+
+// A SamplerOption restricts which entries a sampler may drop.
+type SamplerOption interface {
+	apply(*sampler)
+}
+
+type samplerOptionFunc func(*sampler)
+
+func (f samplerOptionFunc) apply(s *sampler) {
+	f(s)
+}
+
+// SampleLevels restricts sampling to entries at the given levels. Entries at
+// any other level are always passed through, so that, for example, sampling
+// Debug and Info entries never costs an Error.
+func SampleLevels(levels ...Level) SamplerOption {
+	var set levelSet
+	for _, l := range levels {
+		if l >= _minLevel && l <= _maxLevel {
+			set[l-_minLevel] = true
+		}
+	}
+	return samplerOptionFunc(func(s *sampler) {
+		s.levels = set
+	})
+}
+
+// SampleExclude exempts entries whose messages match any of the given
+// regular expressions from sampling, so that messages like audit records are
+// always passed through.
+func SampleExclude(patterns ...*regexp.Regexp) SamplerOption {
+	return samplerOptionFunc(func(s *sampler) {
+		s.exclude = append(s.exclude, patterns...)
+	})
+}
+
+// levelSet enables an explicit set of levels.
+type levelSet [_numLevels]bool
+
+func (s levelSet) Enabled(l Level) bool {
+	return l >= _minLevel && l <= _maxLevel && s[l-_minLevel]
+}
+
+// An UnsampledCore is a Core that must see every entry, even those a sampler
+// drops, such as a Core that writes an audit trail. When a sampler drops an
+// entry, it still offers the entry to the CheckUnsampled method of the Core
+// it wraps, and Tees pass the call on to their members. Other wrapping Cores
+// hide the method, so an UnsampledCore should be teed directly under the
+// sampler.
+type UnsampledCore interface {
+	Core
+
+	// CheckUnsampled is called in place of Check for entries that sampling
+	// dropped.
+	CheckUnsampled(Entry, *CheckedEntry) *CheckedEntry
+}
+
+// checkUnsampled offers a dropped entry to core if it's an UnsampledCore.
+func checkUnsampled(core Core, ent Entry, ce *CheckedEntry) *CheckedEntry {
+	if u, ok := core.(UnsampledCore); ok {
+		return u.CheckUnsampled(ent, ce)
+	}
+	return ce
 }
 
 // NewSampler creates a Core that samples incoming entries, which caps the CPU
@@ -120,17 +202,30 @@ type sampler struct {
 // each tick. If more Entries with the same level and message are seen during
 // the same interval, every Mth message is logged and the rest are dropped.
 //
+// Ticks are measured by the entries' timestamps rather than the wall clock,
+// so a Logger built with WithClock samples according to its Clock.
+//
 // Keep in mind that zap's sampling implementation is optimized for speed over
 // absolute precision; under load, each tick may be slightly over- or
 // under-sampled.
 func NewSampler(core Core, tick time.Duration, first, thereafter int) Core {
-	return &sampler{
+	return NewSamplerWithOptions(core, tick, first, thereafter)
+}
+
+// NewSamplerWithOptions creates a sampling Core like NewSampler, with
+// options that restrict which entries it samples.
+func NewSamplerWithOptions(core Core, tick time.Duration, first, thereafter int, opts ...SamplerOption) Core {
+	s := &sampler{
 		Core:       core,
 		tick:       tick,
 		counts:     newCounters(),
 		first:      uint64(first),
 		thereafter: uint64(thereafter),
 	}
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+	return s
 }
 
 func (s *sampler) With(fields []Field) Core {
@@ -140,6 +235,8 @@ func (s *sampler) With(fields []Field) Core {
 		counts:     s.counts,
 		first:      s.first,
 		thereafter: s.thereafter,
+		levels:     s.levels,
+		exclude:    s.exclude,
 	}
 }
 
@@ -150,6 +247,19 @@ func (s *sampler) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
 		return ce
 	}
 
+	// 不参与采样的日志直接交给下层 Core
+	if !s.samples(ent) {
+		return s.Core.Check(ent, ce)
+	}
+
+	// 指定了采样结果的日志不参与计数
+	switch ent.Sampling {
+	case SampleKeep:
+		return s.Core.Check(ent, ce)
+	case SampleDrop:
+		return checkUnsampled(s.Core, ent, ce)
+	}
+
 	// 根据 `日志级别` 和 `日志信息` 从 s.counts 中获取到该日志对应的计数器
 	counter := s.counts.get(ent.Level, ent.Message)
 
@@ -157,11 +267,38 @@ func (s *sampler) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
 	n := counter.IncCheckReset(ent.Time, s.tick)
 
 
-	// 每隔 s.thereafter 输出一次
+	// 每隔 s.thereafter 输出一次，被丢弃的日志仍然交给不允许采样的 Core
 	if n > s.first && (n-s.first)%s.thereafter != 0 {
-		return ce
+		return checkUnsampled(s.Core, ent, ce)
 	}
 
 	//
 	return s.Core.Check(ent, ce)
 }
+
+// A SamplingOverride overrides a sampler's decision for a single entry (see
+// Entry.Sampling). Overrides only affect entries that are subject to
+// sampling, so entries exempted by SampleLevels or SampleExclude are always
+// written, and overridden entries don't count towards the sampler's limits.
+type SamplingOverride uint8
+
+const (
+	// SampleKeep makes samplers write the entry, as if it weren't sampled.
+	SampleKeep SamplingOverride = iota + 1
+	// SampleDrop makes samplers drop the entry, as if it had been sampled
+	// out. UnsampledCores still see it.
+	SampleDrop
+)
+
+// samples reports whether the entry is subject to sampling.
+func (s *sampler) samples(ent Entry) bool {
+	if s.levels != nil && !s.levels.Enabled(ent.Level) {
+		return false
+	}
+	for _, re := range s.exclude {
+		if re.MatchString(ent.Message) {
+			return false
+		}
+	}
+	return true
+}
@@ -22,6 +22,7 @@ package zapcore_test
 
 import (
 	"fmt"
+	"regexp"
 	"sync"
 	"testing"
 	"time"
@@ -95,6 +96,78 @@ func TestSamplerDisabledLevels(t *testing.T) {
 	assertSequence(t, logs.TakeAll(), InfoLevel, 2)
 }
 
+func TestSamplerOptions(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	sampler := NewSamplerWithOptions(core, time.Minute, 1, 100,
+		SampleLevels(DebugLevel, InfoLevel),
+		SampleExclude(regexp.MustCompile(`^audit:`)),
+	).With([]Field{makeInt64Field("ctx", 1)})
+
+	for i := 0; i < 3; i++ {
+		writeEntry(sampler, InfoLevel, "sampled")
+		writeEntry(sampler, ErrorLevel, "not sampled")
+		writeEntry(sampler, InfoLevel, "audit: login")
+	}
+
+	counts := make(map[string]int)
+	for _, e := range logs.All() {
+		counts[e.Message]++
+	}
+	assert.Equal(t, map[string]int{
+		"sampled":      1,
+		"not sampled":  3,
+		"audit: login": 3,
+	}, counts, "Expected only matching levels and messages to be sampled.")
+}
+
+// unsampledCore sees every entry, including those a sampler drops.
+type unsampledCore struct{ Core }
+
+func (c unsampledCore) With(fields []Field) Core {
+	return unsampledCore{c.Core.With(fields)}
+}
+
+func (c unsampledCore) CheckUnsampled(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	return c.Check(ent, ce)
+}
+
+func TestSamplerUnsampledCore(t *testing.T) {
+	sampledCore, sampled := observer.New(DebugLevel)
+	auditCore, audited := observer.New(InfoLevel)
+	sampler := NewSampler(NewTee(sampledCore, unsampledCore{auditCore}), time.Minute, 1, 100).With([]Field{makeInt64Field("ctx", 1)})
+
+	for i := 0; i < 3; i++ {
+		writeEntry(sampler, InfoLevel, "login")
+		writeEntry(sampler, DebugLevel, "debug")
+	}
+	assert.Equal(t, 2, sampled.Len(), "Expected sampling to drop repeated entries.")
+	assert.Equal(t, 3, audited.Len(), "Expected the unsampled core to see every enabled entry.")
+	for _, e := range audited.All() {
+		assert.Equal(t, int64(1), e.ContextMap()["ctx"], "Expected context to reach the unsampled core.")
+	}
+}
+
+func TestSamplerOverride(t *testing.T) {
+	sampledCore, sampled := observer.New(DebugLevel)
+	auditCore, audited := observer.New(DebugLevel)
+	sampler := NewSampler(NewTee(sampledCore, unsampledCore{auditCore}), time.Minute, 1, 100)
+
+	write := func(msg string, o SamplingOverride) {
+		if ce := sampler.Check(Entry{Level: InfoLevel, Time: time.Now(), Message: msg, Sampling: o}, nil); ce != nil {
+			ce.Write()
+		}
+	}
+	for i := 0; i < 3; i++ {
+		write("keep", SampleKeep)
+		write("drop", SampleDrop)
+	}
+	write("keep", 0)
+	write("drop", 0)
+
+	assert.Equal(t, []string{"keep", "keep", "keep", "keep", "drop"}, messages(sampled), "Unexpected sampled entries.")
+	assert.Equal(t, 4, audited.FilterMessage("drop").Len(), "Expected dropped entries to reach the unsampled core.")
+}
+
 func TestSamplerTicking(t *testing.T) {
 	// Ensure that we're resetting the sampler's counter every tick.
 	sampler, logs := fakeSampler(DebugLevel, 10*time.Millisecond, 5, 10)
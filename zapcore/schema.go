@@ -0,0 +1,85 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// A Schema identifies the format of encoded entries, such as "v2" or
+// "2024-01". Encoders stamp it on every entry under EncoderConfig.SchemaKey,
+// so downstream parsers can tell old entries from new ones while a format
+// migration rolls out.
+//
+// A Schema is safe for concurrent use. Since EncoderConfig holds a pointer
+// to it, every encoder built from the config (and every clone of those
+// encoders) sees a change made with Set on their next entry.
+type Schema struct {
+	mu sync.Mutex // serializes Set, so it can return the value it replaced
+	v  atomic.String
+}
+
+// NewSchema creates a Schema holding v.
+func NewSchema(v string) *Schema {
+	s := &Schema{}
+	s.v.Store(v)
+	return s
+}
+
+// Load returns the current schema identifier. A nil Schema holds the empty
+// string.
+func (s *Schema) Load() string {
+	if s == nil {
+		return ""
+	}
+	return s.v.Load()
+}
+
+// Set atomically replaces the schema identifier, returning the previous one.
+// Entries encoded afterwards carry the new identifier.
+func (s *Schema) Set(v string) (old string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old = s.v.Load()
+	s.v.Store(v)
+	return old
+}
+
+// String implements fmt.Stringer.
+func (s *Schema) String() string {
+	return s.Load()
+}
+
+// MarshalText marshals the Schema to text, so it round-trips through JSON
+// and YAML configuration.
+func (s *Schema) MarshalText() ([]byte, error) {
+	return []byte(s.Load()), nil
+}
+
+// UnmarshalText sets the schema identifier from text. It's called when a
+// Schema is decoded from JSON or YAML, and, like Set, it's safe to call on a
+// Schema that's already in use.
+func (s *Schema) UnmarshalText(text []byte) error {
+	s.Set(string(text))
+	return nil
+}
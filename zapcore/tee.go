@@ -20,7 +20,12 @@
 
 package zapcore
 
-import "go.uber.org/multierr"
+import (
+	"context"
+	"reflect"
+
+	"go.uber.org/multierr"
+)
 
 
 
@@ -39,8 +44,22 @@ type multiCore []Core
 // 这个时候就需要用到 multiCore 了，multiCore 类型的定义实际是一个 Core 的切片。
 //
 // 在 multiCore 的实现中，几乎所有的成员方法都会把所包含的 Cores 遍历一遍，逐个处理。
-
+//
+// Nested Tees are flattened, so the result fans out to their Cores directly.
 func NewTee(cores ...Core) Core {
+	return newTee(flattenCores(nil, cores))
+}
+
+// NewDedupedTee is like NewTee, but also drops Cores that duplicate an
+// earlier one, so that composing configurations from several sources
+// doesn't write every entry twice by accident. A Core is a duplicate if it's
+// the same Core as an earlier one, or if both were created by NewCore with
+// the same Encoder, WriteSyncer, and LevelEnabler.
+func NewDedupedTee(cores ...Core) Core {
+	return newTee(dedupeCores(flattenCores(nil, cores)))
+}
+
+func newTee(cores []Core) Core {
 	switch len(cores) {
 	case 0:
 		return NewNopCore()
@@ -77,6 +96,15 @@ func (mc multiCore) Check(ent Entry, ce *CheckedEntry) *CheckedEntry {
 	return ce
 }
 
+// CheckUnsampled offers an entry dropped by a sampler to the members that are
+// UnsampledCores.
+func (mc multiCore) CheckUnsampled(ent Entry, ce *CheckedEntry) *CheckedEntry {
+	for i := range mc {
+		ce = checkUnsampled(mc[i], ent, ce)
+	}
+	return ce
+}
+
 func (mc multiCore) Write(ent Entry, fields []Field) error {
 	var err error
 	for i := range mc {
@@ -92,3 +120,80 @@ func (mc multiCore) Sync() error {
 	}
 	return err
 }
+
+// Flush flushes the members that are, or wrap, Flushers.
+func (mc multiCore) Flush(ctx context.Context) error {
+	var err error
+	for i := range mc {
+		if f, ok := AsFlusher(mc[i]); ok {
+			err = multierr.Append(err, f.Flush(ctx))
+		}
+	}
+	return err
+}
+
+// flattenCores appends cores to dst, replacing Tees with their contents.
+func flattenCores(dst []Core, cores []Core) []Core {
+	for _, c := range cores {
+		if mc, ok := c.(multiCore); ok {
+			dst = flattenCores(dst, mc)
+			continue
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// dedupeCores removes cores that duplicate an earlier one, in place.
+func dedupeCores(cores []Core) []Core {
+	out := cores[:0]
+	for _, c := range cores {
+		dup := false
+		for _, seen := range out {
+			if sameCore(seen, c) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// sameCore reports whether two Cores write the same entries to the same
+// place.
+func sameCore(a, b Core) bool {
+	if identical(a, b) {
+		return true
+	}
+	ia, ok := a.(*ioCore)
+	if !ok {
+		return false
+	}
+	ib, ok := b.(*ioCore)
+	if !ok {
+		return false
+	}
+	return identical(ia.enc, ib.enc) && identical(ia.out, ib.out) && identical(ia.LevelEnabler, ib.LevelEnabler)
+}
+
+// identical compares two values with ==, reporting false rather than
+// panicking if they aren't comparable (for example, if either holds a
+// func or a slice).
+func identical(a, b interface{}) (same bool) {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	// Comparable structs may still hold incomparable values in interfaces.
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
@@ -151,3 +151,54 @@ func TestTeeSync(t *testing.T) {
 	tee = NewTee(tee, noSync)
 	assert.Equal(t, err, tee.Sync(), "Expected an error when part of tee can't Sync.")
 }
+
+// enablerFunc is an incomparable LevelEnabler.
+type enablerFunc func(Level) bool
+
+func (f enablerFunc) Enabled(lvl Level) bool { return f(lvl) }
+
+func TestTeeNested(t *testing.T) {
+	a, aLogs := observer.New(DebugLevel)
+	b, bLogs := observer.New(DebugLevel)
+	tee := NewTee(NewTee(a, b), NewTee(a))
+	if ce := tee.Check(Entry{Level: InfoLevel, Message: "nested"}, nil); ce != nil {
+		ce.Write()
+	}
+	assert.Equal(t, 2, aLogs.Len(), "Expected NewTee to keep duplicate cores.")
+	assert.Equal(t, 1, bLogs.Len(), "Expected nested cores to be written once.")
+}
+
+func TestDedupedTee(t *testing.T) {
+	buf := &ztest.Buffer{}
+	enc := NewJSONEncoder(EncoderConfig{MessageKey: "msg"})
+	level := DebugLevel
+	core := NewCore(enc, buf, level)
+	same := NewCore(enc, buf, level)
+	other, otherLogs := observer.New(DebugLevel)
+	byFunc := enablerFunc(func(Level) bool { return true })
+
+	tests := []struct {
+		desc   string
+		tee    Core
+		lines  int
+		nested int
+	}{
+		{"same core twice", NewDedupedTee(core, core), 1, 0},
+		{"same encoder, sink, and level", NewDedupedTee(core, same), 1, 0},
+		{"nested tees", NewDedupedTee(NewTee(core, other), NewTee(other, same)), 1, 1},
+		{"incomparable enablers", NewDedupedTee(NewCore(enc, buf, byFunc), NewCore(enc, buf, byFunc)), 2, 0},
+		{"different encoders", NewDedupedTee(core, NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), buf, level)), 2, 0},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		before := otherLogs.Len()
+		if ce := tt.tee.Check(Entry{Level: InfoLevel, Message: tt.desc}, nil); ce != nil {
+			ce.Write()
+		}
+		assert.Equal(t, tt.lines, len(buf.Lines()), "Unexpected number of writes for %s.", tt.desc)
+		assert.Equal(t, tt.nested, otherLogs.Len()-before, "Unexpected number of observed writes for %s.", tt.desc)
+	}
+
+	assert.Equal(t, core, NewDedupedTee(core, same), "Expected a single remaining core to be returned unchanged.")
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ThrottleStats counts the writes handled by a ThrottledWriteSyncer.
+type ThrottleStats struct {
+	// Written is the number of writes passed through.
+	Written uint64
+	// Suppressed is the number of writes dropped.
+	Suppressed uint64
+}
+
+// A ThrottledWriteSyncer passes through at most a fixed number of writes per
+// interval, dropping the rest and then writing a line that summarizes how
+// many were dropped. It's meant for destinations like a Logger's error
+// output, where each write is a single message and a persistently failing
+// sink could otherwise produce a flood of identical errors.
+type ThrottledWriteSyncer struct {
+	ws       WriteSyncer
+	first    int
+	interval time.Duration
+
+	mu         sync.Mutex
+	start      time.Time // start of the current interval
+	count      int       // writes seen in the current interval
+	suppressed int       // writes dropped since the last summary
+	timer      *time.Timer
+	stats      ThrottleStats
+}
+
+// NewThrottledWriteSyncer wraps a WriteSyncer so that only the first writes
+// in each interval reach it. When writes are dropped, a summary is written
+// once the interval ends. Dropped writes report success.
+//
+// The wrapped WriteSyncer must be safe for concurrent use.
+func NewThrottledWriteSyncer(ws WriteSyncer, first int, interval time.Duration) *ThrottledWriteSyncer {
+	return &ThrottledWriteSyncer{
+		ws:       ws,
+		first:    first,
+		interval: interval,
+	}
+}
+
+// Write implements io.Writer.
+func (t *ThrottledWriteSyncer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(t.start) >= t.interval {
+		t.summarize()
+		t.start = now
+		t.count = 0
+	}
+	t.count++
+	if t.count <= t.first {
+		t.stats.Written++
+		return t.ws.Write(p)
+	}
+
+	t.stats.Suppressed++
+	t.suppressed++
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.start.Add(t.interval).Sub(now), t.flush)
+	}
+	return len(p), nil
+}
+
+// Sync implements WriteSyncer.
+func (t *ThrottledWriteSyncer) Sync() error {
+	return t.ws.Sync()
+}
+
+// Stats returns the number of writes passed through and dropped so far.
+func (t *ThrottledWriteSyncer) Stats() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+func (t *ThrottledWriteSyncer) flush() {
+	t.mu.Lock()
+	t.summarize()
+	t.mu.Unlock()
+}
+
+// summarize writes a summary of any dropped writes. It must be called with
+// the lock held.
+func (t *ThrottledWriteSyncer) summarize() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	if t.suppressed == 0 {
+		return
+	}
+	fmt.Fprintf(t.ws, "%v suppressed %d internal errors in the last %v\n", time.Now().UTC(), t.suppressed, t.interval)
+	t.ws.Sync()
+	t.suppressed = 0
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottledWriteSyncer(t *testing.T) {
+	buf := &ztest.Buffer{}
+	ws := NewThrottledWriteSyncer(Lock(buf), 2, 50*time.Millisecond)
+	// The summary is written from a timer, so read the buffer under the
+	// throttle's lock.
+	lines := func() []string {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		return buf.Lines()
+	}
+
+	for i := 0; i < 5; i++ {
+		n, err := ws.Write([]byte("error\n"))
+		assert.NoError(t, err, "Expected dropped writes to succeed.")
+		assert.Equal(t, 6, n, "Unexpected number of bytes written.")
+	}
+	assert.Equal(t, []string{"error", "error"}, lines(), "Expected only the first writes to pass through.")
+	assert.Equal(t, ThrottleStats{Written: 2, Suppressed: 3}, ws.Stats(), "Unexpected stats.")
+
+	// The summary is written when the interval ends, even without more writes.
+	assert.Eventually(t, func() bool {
+		return len(lines()) == 3
+	}, time.Second, time.Millisecond, "Expected a summary of dropped writes.")
+	assert.True(t, strings.HasSuffix(lines()[2], "suppressed 3 internal errors in the last 50ms"), "Unexpected summary: %q.", lines()[2])
+
+	// A new interval starts afresh.
+	ws.Write([]byte("again\n"))
+	assert.Equal(t, "again", lines()[3], "Expected writes in a new interval to pass through.")
+	assert.Equal(t, ThrottleStats{Written: 3, Suppressed: 3}, ws.Stats(), "Unexpected stats.")
+	assert.NoError(t, ws.Sync(), "Unexpected error syncing.")
+}
+
+func TestThrottledWriteSyncerSummarizesOnNextInterval(t *testing.T) {
+	buf := &ztest.Buffer{}
+	ws := NewThrottledWriteSyncer(Lock(buf), 1, time.Hour)
+
+	ws.Write([]byte("one\n"))
+	ws.Write([]byte("two\n"))
+	ws.mu.Lock()
+	// Simulate the interval ending before the timer fires.
+	ws.start = ws.start.Add(-2 * time.Hour)
+	ws.mu.Unlock()
+	ws.Write([]byte("three\n"))
+
+	lines := buf.Lines()
+	if assert.Equal(t, 3, len(lines), "Unexpected output: %q.", lines) {
+		assert.Contains(t, lines[1], "suppressed 1 internal errors", "Expected a summary before the next write.")
+		assert.Equal(t, "three", lines[2], "Unexpected last line.")
+	}
+}
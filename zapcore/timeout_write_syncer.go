@@ -0,0 +1,199 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/blastbao/zap/internal/bufferpool"
+)
+
+// ErrWriteSyncerTimeout is returned by a TimeoutWriteSyncer when the
+// wrapped WriteSyncer doesn't finish a write or sync in time.
+var ErrWriteSyncerTimeout = errors.New("write syncer timed out")
+
+// WriteSyncerHealth describes the recent behavior of a TimeoutWriteSyncer's
+// wrapped WriteSyncer, for health checks and status endpoints.
+type WriteSyncerHealth struct {
+	// LastSuccess is when a write or sync last succeeded.
+	LastSuccess time.Time
+	// LastError is the error from the most recent failed or timed-out write
+	// or sync, and LastErrorTime is when it happened.
+	LastError     error
+	LastErrorTime time.Time
+	// Timeouts is the number of writes and syncs that timed out or whose
+	// context was done first.
+	Timeouts uint64
+	// Hung reports whether a write or sync that timed out is still running.
+	Hung bool
+}
+
+// A TimeoutWriteSyncer bounds how long writes and syncs to a WriteSyncer
+// may take, so that a hung NFS mount or unresponsive network sink can't
+// block the application, Logger.Sync, or Fatal forever. It also tracks the
+// WriteSyncer's health; see Health.
+type TimeoutWriteSyncer struct {
+	ws      WriteSyncer
+	timeout time.Duration
+	// sem holds a token while an operation on ws is running, including one
+	// that timed out, so ws is never used concurrently.
+	sem chan struct{}
+
+	mu     sync.Mutex
+	health WriteSyncerHealth
+}
+
+// NewTimeoutWriteSyncer wraps a WriteSyncer so that each write or sync
+// returns ErrWriteSyncerTimeout if it hasn't finished within timeout. The
+// operation keeps running in the background, and later operations wait for
+// it (counting that time against their own timeout), so a hung WriteSyncer
+// ties up at most one goroutine. Writes are copied, so the caller may reuse
+// its buffer even if the write times out. If timeout isn't positive, writes
+// and syncs are never cut short, but health is still tracked.
+//
+// The wrapped WriteSyncer needn't be safe for concurrent use. If it's a
+// ContextWriter, writes made with a context also give up once the context is
+// done.
+func NewTimeoutWriteSyncer(ws WriteSyncer, timeout time.Duration) *TimeoutWriteSyncer {
+	return &TimeoutWriteSyncer{
+		ws:      ws,
+		timeout: timeout,
+		sem:     make(chan struct{}, 1),
+	}
+}
+
+// Write implements io.Writer.
+func (t *TimeoutWriteSyncer) Write(p []byte) (int, error) {
+	return t.WriteContext(context.Background(), p)
+}
+
+// WriteContext implements ContextWriter.
+func (t *TimeoutWriteSyncer) WriteContext(ctx context.Context, p []byte) (int, error) {
+	buf := bufferpool.Get()
+	buf.Write(p)
+	var n int
+	finished, err := t.do(ctx, func() error {
+		defer buf.Free()
+		var err error
+		n, err = writeContext(ctx, t.ws, buf.Bytes())
+		return err
+	}, buf.Free)
+	if !finished {
+		return 0, err
+	}
+	return n, err
+}
+
+// Sync implements WriteSyncer.
+func (t *TimeoutWriteSyncer) Sync() error {
+	_, err := t.do(context.Background(), t.ws.Sync, nil)
+	return err
+}
+
+// timeoutOp tracks an operation started on a separate goroutine.
+type timeoutOp struct {
+	finished bool // guarded by TimeoutWriteSyncer.mu
+}
+
+// do runs op on a separate goroutine, waiting for it until the timeout
+// passes or ctx is done, and reports whether it finished in time. If op
+// never starts because an earlier operation is still running, abandon is
+// called instead.
+func (t *TimeoutWriteSyncer) do(ctx context.Context, op func() error, abandon func()) (bool, error) {
+	var expired <-chan time.Time
+	if t.timeout > 0 {
+		timer := time.NewTimer(t.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case t.sem <- struct{}{}:
+	case <-expired:
+		if abandon != nil {
+			abandon()
+		}
+		return false, t.cutShort(nil, ErrWriteSyncerTimeout)
+	case <-ctx.Done():
+		if abandon != nil {
+			abandon()
+		}
+		return false, t.cutShort(nil, ctx.Err())
+	}
+
+	state := &timeoutOp{}
+	done := make(chan error, 1)
+	go func() {
+		err := op()
+		t.finish(state, err)
+		<-t.sem
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return true, err
+	case <-expired:
+		return false, t.cutShort(state, ErrWriteSyncerTimeout)
+	case <-ctx.Done():
+		return false, t.cutShort(state, ctx.Err())
+	}
+}
+
+// finish records the outcome of an operation.
+func (t *TimeoutWriteSyncer) finish(state *timeoutOp, err error) {
+	now := DefaultClock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state.finished = true
+	t.health.Hung = false
+	if err != nil {
+		t.health.LastError = err
+		t.health.LastErrorTime = now
+	} else {
+		t.health.LastSuccess = now
+	}
+}
+
+// cutShort records an operation that was abandoned, or that was started but
+// stopped being waited for, and returns err.
+func (t *TimeoutWriteSyncer) cutShort(state *timeoutOp, err error) error {
+	now := DefaultClock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state != nil && !state.finished {
+		t.health.Hung = true
+	}
+	t.health.LastError = err
+	t.health.LastErrorTime = now
+	t.health.Timeouts++
+	return err
+}
+
+// Health reports how the wrapped WriteSyncer has behaved recently.
+func (t *TimeoutWriteSyncer) Health() WriteSyncerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.health
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap/internal/ztest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hangingWriter is a Buffer whose writes and syncs block until release is
+// closed.
+type hangingWriter struct {
+	ztest.Buffer
+	release chan struct{}
+}
+
+func newHangingWriter() *hangingWriter {
+	return &hangingWriter{release: make(chan struct{})}
+}
+
+func (w *hangingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.Buffer.Write(p)
+}
+
+func (w *hangingWriter) Sync() error {
+	<-w.release
+	return w.Buffer.Sync()
+}
+
+func TestTimeoutWriteSyncer(t *testing.T) {
+	buf := &ztest.Buffer{}
+	ws := NewTimeoutWriteSyncer(buf, time.Second)
+	assert.True(t, ws.Health().LastSuccess.IsZero(), "Expected no successes yet.")
+
+	n, err := ws.Write([]byte("foo"))
+	require.NoError(t, err, "Unexpected error writing.")
+	assert.Equal(t, 3, n, "Unexpected number of bytes written.")
+	require.NoError(t, ws.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, "foo", buf.String(), "Unexpected output.")
+	assert.True(t, buf.Called(), "Expected the wrapped WriteSyncer to be synced.")
+
+	h := ws.Health()
+	assert.False(t, h.LastSuccess.IsZero(), "Expected a recorded success.")
+	assert.NoError(t, h.LastError, "Expected no errors.")
+	assert.Zero(t, h.Timeouts, "Expected no timeouts.")
+
+	buf.SetError(errors.New("disk full"))
+	assert.EqualError(t, ws.Sync(), "disk full", "Expected sync errors to be returned.")
+	h = ws.Health()
+	assert.EqualError(t, h.LastError, "disk full", "Expected the error to be recorded.")
+	assert.False(t, h.LastErrorTime.IsZero(), "Expected the error time to be recorded.")
+	assert.Zero(t, h.Timeouts, "Expected errors not to count as timeouts.")
+}
+
+func TestTimeoutWriteSyncerHangs(t *testing.T) {
+	w := newHangingWriter()
+	ws := NewTimeoutWriteSyncer(w, 10*time.Millisecond)
+
+	p := []byte("first")
+	n, err := ws.Write(p)
+	assert.Equal(t, ErrWriteSyncerTimeout, err, "Expected the write to time out.")
+	assert.Zero(t, n, "Expected no bytes to be reported written.")
+	copy(p, "XXXXX") // the caller may reuse its buffer
+
+	// Later operations wait for the hung one rather than piling up.
+	assert.Equal(t, ErrWriteSyncerTimeout, ws.Sync(), "Expected the sync to time out.")
+	_, err = ws.Write([]byte("second"))
+	assert.Equal(t, ErrWriteSyncerTimeout, err, "Expected the write to time out.")
+	h := ws.Health()
+	assert.True(t, h.Hung, "Expected the write to be hung.")
+	assert.Equal(t, uint64(3), h.Timeouts, "Unexpected number of timeouts.")
+	assert.Equal(t, ErrWriteSyncerTimeout, h.LastError, "Unexpected last error.")
+
+	close(w.release)
+	assert.Eventually(t, func() bool { return !ws.Health().Hung }, time.Second, time.Millisecond, "Expected the hung write to finish.")
+	assert.Equal(t, "first", w.String(), "Expected the original bytes to be written, and abandoned writes dropped.")
+	_, err = ws.Write([]byte(" third"))
+	assert.NoError(t, err, "Expected writes to succeed once the WriteSyncer recovers.")
+	assert.Equal(t, "first third", w.String(), "Unexpected output.")
+}
+
+func TestTimeoutWriteSyncerContext(t *testing.T) {
+	w := newHangingWriter()
+	defer close(w.release)
+	ws := NewTimeoutWriteSyncer(w, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := ws.WriteContext(ctx, []byte("foo"))
+	assert.Equal(t, context.DeadlineExceeded, err, "Expected the write to give up with its context.")
+	assert.True(t, ws.Health().Hung, "Expected the write to be hung.")
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// TruncateString shortens s to at most max bytes, without splitting a UTF-8
+// sequence, and appends a marker recording how many bytes were cut, as in
+// "abc...(+1021 bytes)". It returns s unchanged if it fits or if max isn't
+// positive. It's how EncoderConfig.MaxFieldBytes shortens values.
+func TruncateString(s string, max int) string {
+	n := truncatedLen(s, max)
+	if n == len(s) {
+		return s
+	}
+	return s[:n] + "...(+" + strconv.Itoa(len(s)-n) + " bytes)"
+}
+
+// truncatedLen returns the length to which TruncateString cuts s.
+func truncatedLen(s string, max int) int {
+	if max <= 0 || len(s) <= max {
+		return len(s)
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return max
+}
+
+// truncatedByteLen is truncatedLen for byte slices.
+func truncatedByteLen(s []byte, max int) int {
+	if max <= 0 || len(s) <= max {
+		return len(s)
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return max
+}
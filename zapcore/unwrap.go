@@ -0,0 +1,50 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+// An unwrapper is a Core that wraps another Core.
+type unwrapper interface {
+	Unwrap() Core
+}
+
+// UnwrapCore returns the Core that core wraps, or nil if it doesn't wrap
+// one. The Cores returned by this package's wrapping constructors, such as
+// NewSampler, NewIncreaseLevelCore, and RegisterHooks, expose the Core they
+// wrap with an Unwrap() Core method; Cores defined elsewhere (for example,
+// with zap.WrapCore) can implement the same method so that Logger.Sync and
+// Logger.Shutdown reach the Flushers and AsyncCores beneath them.
+func UnwrapCore(core Core) Core {
+	if u, ok := core.(unwrapper); ok {
+		return u.Unwrap()
+	}
+	return nil
+}
+
+// AsFlusher returns the first Flusher in the chain of Cores that starts with
+// core and follows UnwrapCore.
+func AsFlusher(core Core) (Flusher, bool) {
+	for c := core; c != nil; c = UnwrapCore(c) {
+		if f, ok := c.(Flusher); ok {
+			return f, true
+		}
+	}
+	return nil, false
+}
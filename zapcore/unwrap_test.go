@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapcore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testFlusher struct {
+	Core
+	flushes int
+}
+
+func (f *testFlusher) Flush(context.Context) error {
+	f.flushes++
+	return nil
+}
+
+func TestUnwrapCore(t *testing.T) {
+	inner := NewNopCore()
+	wrapped := NewSampler(RegisterHooks(inner), time.Second, 1, 1)
+
+	assert.Equal(t, inner, UnwrapCore(UnwrapCore(wrapped)), "Expected to reach the innermost Core.")
+	assert.Nil(t, UnwrapCore(inner), "Expected nil from a Core that doesn't wrap another.")
+}
+
+func TestAsFlusher(t *testing.T) {
+	f := &testFlusher{Core: NewNopCore()}
+	wrapped := NewSampler(RegisterHooks(f), time.Second, 1, 1)
+
+	got, ok := AsFlusher(wrapped)
+	if assert.True(t, ok, "Expected to find the wrapped Flusher.") {
+		assert.NoError(t, got.Flush(context.Background()), "Unexpected error flushing.")
+		assert.Equal(t, 1, f.flushes, "Expected Flush to reach the wrapped Flusher.")
+	}
+
+	_, ok = AsFlusher(RegisterHooks(NewNopCore()))
+	assert.False(t, ok, "Expected no Flusher.")
+
+	assert.NoError(t, NewTee(RegisterHooks(f), RegisterHooks(NewNopCore())).(Flusher).Flush(context.Background()), "Unexpected error flushing Tee.")
+	assert.Equal(t, 2, f.flushes, "Expected Tee to flush wrapped members.")
+}
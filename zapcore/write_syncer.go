@@ -21,6 +21,7 @@
 package zapcore
 
 import (
+	"context"
 	"io"
 	"sync"
 
@@ -37,6 +38,24 @@ type WriteSyncer interface {
 	Sync() error
 }
 
+// A ContextWriter is a WriteSyncer, such as a network sink that delivers
+// entries synchronously, that can bound a write by a context's deadline. Cores
+// built with NewCore use WriteContext for entries logged with a context (see
+// Entry.Context), so that logging on a request's path can't outlast the
+// request.
+type ContextWriter interface {
+	WriteSyncer
+	WriteContext(ctx context.Context, p []byte) (int, error)
+}
+
+// writeContext writes p to ws, bounded by ctx if ws is a ContextWriter.
+func writeContext(ctx context.Context, ws WriteSyncer, p []byte) (int, error) {
+	if cw, ok := ws.(ContextWriter); ok && ctx != nil {
+		return cw.WriteContext(ctx, p)
+	}
+	return ws.Write(p)
+}
+
 // AddSync converts an io.Writer to a WriteSyncer.
 // It attempts to be intelligent: if the concrete type of the io.Writer implements WriteSyncer,
 // we'll use the existing Sync method. If it doesn't, we'll add a no-op Sync.
@@ -77,6 +96,13 @@ func (s *lockedWriteSyncer) Write(bs []byte) (int, error) {
 	return n, err
 }
 
+func (s *lockedWriteSyncer) WriteContext(ctx context.Context, bs []byte) (int, error) {
+	s.Lock()
+	n, err := writeContext(ctx, s.ws, bs)
+	s.Unlock()
+	return n, err
+}
+
 func (s *lockedWriteSyncer) Sync() error {
 	s.Lock()
 	err := s.ws.Sync()
@@ -110,10 +136,14 @@ func NewMultiWriteSyncer(ws ...WriteSyncer) WriteSyncer {
 // When not all underlying syncers write the same number of bytes,
 // the smallest number is returned even though Write() is called on all of them.
 func (ws multiWriteSyncer) Write(p []byte) (int, error) {
+	return ws.WriteContext(nil, p)
+}
+
+func (ws multiWriteSyncer) WriteContext(ctx context.Context, p []byte) (int, error) {
 	var writeErr error
 	nWritten := 0
 	for _, w := range ws {
-		n, err := w.Write(p)
+		n, err := writeContext(ctx, w, p)
 		writeErr = multierr.Append(writeErr, err)
 		if nWritten == 0 && n != 0 {
 			nWritten = n
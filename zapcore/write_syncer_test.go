@@ -22,13 +22,16 @@ package zapcore
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"net"
 	"testing"
 
 	"io"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/blastbao/zap/buffer"
 	"github.com/blastbao/zap/internal/ztest"
 )
 
@@ -135,3 +138,58 @@ func TestMultiWriteSyncerSync_AllCalled(t *testing.T) {
 	assert.True(t, failed.Called(), "Expected first sink to have Sync method called.")
 	assert.True(t, second.Called(), "Expected call to Sync even with first failure.")
 }
+
+// ctxWriter records the contexts its writes are bounded by.
+type ctxWriter struct {
+	bytes.Buffer
+	ctxs []context.Context
+}
+
+func (w *ctxWriter) Sync() error { return nil }
+
+func (w *ctxWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	w.ctxs = append(w.ctxs, ctx)
+	return w.Write(p)
+}
+
+func TestContextWriter(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	cw := &ctxWriter{}
+	other := &bytes.Buffer{}
+	core := NewCore(
+		NewJSONEncoder(EncoderConfig{MessageKey: "msg"}),
+		Lock(NewMultiWriteSyncer(cw, AddSync(other))),
+		DebugLevel,
+	)
+
+	require.NoError(t, core.Write(Entry{Message: "bound", Context: ctx}, nil), "Unexpected error writing.")
+	require.NoError(t, core.Write(Entry{Message: "unbound"}, nil), "Unexpected error writing.")
+	assert.Equal(t, []context.Context{ctx}, cw.ctxs, "Expected only the bound entry to use WriteContext.")
+	assert.Equal(t, "{\"msg\":\"bound\"}\n{\"msg\":\"unbound\"}\n", cw.String(), "Unexpected output.")
+	assert.Equal(t, cw.String(), other.String(), "Expected plain writers to get every entry.")
+}
+
+func TestIOCoreWriteEncodedBatch(t *testing.T) {
+	for _, tt := range []struct {
+		lvl  Level
+		sync bool
+	}{
+		{ErrorLevel, false},
+		{DPanicLevel, true},
+	} {
+		out := &ztest.Buffer{}
+		core := NewCore(NewJSONEncoder(EncoderConfig{MessageKey: "msg"}), out, DebugLevel).(*ioCore)
+
+		var bufs []*buffer.Buffer
+		for _, msg := range []string{"one", "two"} {
+			buf, err := core.encode(Entry{Message: msg}, nil)
+			require.NoError(t, err, "Unexpected error encoding.")
+			bufs = append(bufs, buf)
+		}
+		var scratch net.Buffers
+		require.NoError(t, core.writeEncodedBatch(tt.lvl, bufs, &scratch), "Unexpected error writing batch.")
+		assert.Equal(t, []string{`{"msg":"one"}`, `{"msg":"two"}`}, out.Lines(), "Unexpected output at %v.", tt.lvl)
+		assert.Equal(t, tt.sync, out.Called(), "Unexpected Sync behavior at %v.", tt.lvl)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logsink
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// The messages in logsink.proto are tiny, so rather than depending on
+// generated code we hand-roll their protocol buffer wire encoding here. The
+// output is byte-for-byte what protoc-generated code would produce, so
+// servers written in any language can use the published proto.
+const (
+	_wireVarint = 0
+	_wireBytes  = 2
+
+	_batchSequenceTag = 1<<3 | _wireVarint
+	_batchEntriesTag  = 2<<3 | _wireBytes
+	_ackSequenceTag   = 1<<3 | _wireVarint
+)
+
+var errTruncated = errors.New("logsink: truncated protocol buffer")
+
+// Batch is a group of encoded log entries. See logsink.proto.
+type Batch struct {
+	Sequence uint64
+	Entries  [][]byte
+}
+
+// Ack acknowledges all batches up to and including Sequence. See
+// logsink.proto.
+type Ack struct {
+	Sequence uint64
+}
+
+type wireMessage interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+func (b *Batch) marshal() []byte {
+	size := 0
+	if b.Sequence != 0 {
+		size += 1 + uvarintLen(b.Sequence)
+	}
+	for _, e := range b.Entries {
+		size += 1 + uvarintLen(uint64(len(e))) + len(e)
+	}
+
+	buf := make([]byte, 0, size)
+	if b.Sequence != 0 {
+		buf = appendUvarint(append(buf, _batchSequenceTag), b.Sequence)
+	}
+	for _, e := range b.Entries {
+		buf = appendUvarint(append(buf, _batchEntriesTag), uint64(len(e)))
+		buf = append(buf, e...)
+	}
+	return buf
+}
+
+func (b *Batch) unmarshal(data []byte) error {
+	*b = Batch{}
+	return walkFields(data, func(num int, typ int, v uint64, bs []byte) {
+		switch {
+		case num == 1 && typ == _wireVarint:
+			b.Sequence = v
+		case num == 2 && typ == _wireBytes:
+			b.Entries = append(b.Entries, append([]byte{}, bs...))
+		}
+	})
+}
+
+func (a *Ack) marshal() []byte {
+	if a.Sequence == 0 {
+		return nil
+	}
+	return appendUvarint([]byte{_ackSequenceTag}, a.Sequence)
+}
+
+func (a *Ack) unmarshal(data []byte) error {
+	*a = Ack{}
+	return walkFields(data, func(num int, typ int, v uint64, _ []byte) {
+		if num == 1 && typ == _wireVarint {
+			a.Sequence = v
+		}
+	})
+}
+
+// walkFields decodes a protocol buffer message, calling f for every varint
+// and length-delimited field. Fixed-width fields are skipped, since neither
+// of our messages uses them.
+func walkFields(data []byte, f func(num, typ int, v uint64, bs []byte)) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		num, typ := int(key>>3), int(key&7)
+
+		switch typ {
+		case _wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+			f(num, typ, v, nil)
+		case _wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return errTruncated
+			}
+			f(num, typ, 0, data[n:n+int(l)])
+			data = data[n+int(l):]
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return errTruncated
+			}
+			data = data[8:]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return errTruncated
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("logsink: unsupported wire type %d", typ)
+		}
+	}
+	return nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// codec marshals Batches and Acks itself and delegates every other message
+// to gRPC's registered protocol buffer codec, so it's safe to install for an
+// entire server (see ServerCodec).
+type codec struct{}
+
+func (codec) Name() string { return "proto" }
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(wireMessage); ok {
+		return m.marshal(), nil
+	}
+	return fallbackCodec().Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(wireMessage); ok {
+		return m.unmarshal(data)
+	}
+	return fallbackCodec().Unmarshal(data, v)
+}
+
+func fallbackCodec() encoding.Codec {
+	if c := encoding.GetCodec("proto"); c != nil {
+		return c
+	}
+	return noCodec{}
+}
+
+type noCodec struct{}
+
+func (noCodec) Name() string { return "proto" }
+
+func (noCodec) Marshal(v interface{}) ([]byte, error) {
+	return nil, fmt.Errorf("logsink: can't marshal %T", v)
+}
+
+func (noCodec) Unmarshal(_ []byte, v interface{}) error {
+	return fmt.Errorf("logsink: can't unmarshal %T", v)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logsink
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchWireFormat(t *testing.T) {
+	b := &Batch{Sequence: 300, Entries: [][]byte{[]byte("a"), []byte("")}}
+	// Field 1 (varint 300), then field 2 twice (length-delimited).
+	expected := []byte{0x08, 0xac, 0x02, 0x12, 0x01, 'a', 0x12, 0x00}
+	assert.Equal(t, expected, b.marshal(), "Unexpected wire encoding.")
+
+	var decoded Batch
+	require.NoError(t, decoded.unmarshal(expected), "Failed to decode batch.")
+	assert.Equal(t, uint64(300), decoded.Sequence, "Unexpected sequence.")
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("")}, decoded.Entries, "Unexpected entries.")
+}
+
+func TestAckWireFormat(t *testing.T) {
+	assert.Nil(t, (&Ack{}).marshal(), "Expected zero-valued Ack to encode to nothing.")
+	assert.Equal(t, []byte{0x08, 0x07}, (&Ack{Sequence: 7}).marshal(), "Unexpected wire encoding.")
+
+	var a Ack
+	require.NoError(t, a.unmarshal([]byte{0x08, 0x07}), "Failed to decode ack.")
+	assert.Equal(t, uint64(7), a.Sequence, "Unexpected sequence.")
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	data := []byte{
+		0x19, 1, 2, 3, 4, 5, 6, 7, 8, // field 3, fixed64
+		0x25, 1, 2, 3, 4, // field 4, fixed32
+		0x2a, 0x02, 'h', 'i', // field 5, bytes
+		0x08, 0x05, // field 1, varint
+	}
+	var a Ack
+	require.NoError(t, a.unmarshal(data), "Failed to decode ack with unknown fields.")
+	assert.Equal(t, uint64(5), a.Sequence, "Unexpected sequence.")
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{0x08}, "truncated"},
+		{[]byte{0x12, 0x05, 'a'}, "truncated"},
+		{[]byte{0x19, 1, 2}, "truncated"},
+		{[]byte{0x0b}, "unsupported wire type"},
+	}
+	for _, tt := range tests {
+		var b Batch
+		err := b.unmarshal(tt.data)
+		if assert.Error(t, err, "Expected an error decoding %v.", tt.data) {
+			assert.True(t, strings.Contains(err.Error(), tt.err), "Unexpected error: %v.", err)
+		}
+	}
+}
+
+func TestCodecFallsBack(t *testing.T) {
+	c := codec{}
+	assert.Equal(t, "proto", c.Name(), "Unexpected codec name.")
+
+	bs, err := c.Marshal(&Ack{Sequence: 1})
+	require.NoError(t, err, "Unexpected error marshaling an Ack.")
+	var a Ack
+	require.NoError(t, c.Unmarshal(bs, &a), "Unexpected error unmarshaling an Ack.")
+	assert.Equal(t, uint64(1), a.Sequence, "Unexpected round-tripped sequence.")
+
+	_, err = c.Marshal(42)
+	assert.Error(t, err, "Expected an error marshaling a non-message.")
+}
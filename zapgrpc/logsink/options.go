@@ -0,0 +1,160 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logsink
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/blastbao/zap"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	_defaultBatchSize     = 128
+	_defaultFlushInterval = time.Second
+	_defaultMaxRetries    = 3
+	_defaultBackoff       = 100 * time.Millisecond
+	_defaultMaxUnacked    = 64
+	_defaultSyncTimeout   = 5 * time.Second
+)
+
+// An Option configures a Sink.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+type options struct {
+	creds         credentials.TransportCredentials
+	transport     zap.TransportConfig
+	dialOptions   []grpc.DialOption
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	backoff       time.Duration
+	maxUnacked    int
+	syncTimeout   time.Duration
+	syncDelivery  bool
+}
+
+func defaultOptions() options {
+	return options{
+		creds:         insecure.NewCredentials(),
+		batchSize:     _defaultBatchSize,
+		flushInterval: _defaultFlushInterval,
+		maxRetries:    _defaultMaxRetries,
+		backoff:       _defaultBackoff,
+		maxUnacked:    _defaultMaxUnacked,
+		syncTimeout:   _defaultSyncTimeout,
+	}
+}
+
+// WithTLS secures the connection to the server with the supplied TLS
+// configuration. By default, connections are unencrypted.
+func WithTLS(cfg *tls.Config) Option {
+	return optionFunc(func(o *options) {
+		o.creds = credentials.NewTLS(cfg)
+	})
+}
+
+// WithTransport configures how the sink connects to the server: through an
+// HTTP or SOCKS5 proxy, with a dial timeout, and, if cfg includes any TLS
+// settings, over TLS with the supplied CA bundle, client certificate, and
+// server name. TLS settings take precedence over WithTLS.
+func WithTransport(cfg zap.TransportConfig) Option {
+	return optionFunc(func(o *options) {
+		o.transport = cfg
+	})
+}
+
+// WithDialOptions appends options used when constructing the underlying
+// grpc.ClientConn.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return optionFunc(func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	})
+}
+
+// WithBatchSize sets the maximum number of entries sent in a single Batch.
+// Non-positive values are ignored.
+func WithBatchSize(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.batchSize = n
+		}
+	})
+}
+
+// WithFlushInterval sets how often partially-filled batches are sent. A
+// non-positive interval disables periodic flushing, so batches are only sent
+// when full or when the Sink is synced.
+func WithFlushInterval(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.flushInterval = d
+	})
+}
+
+// WithRetry controls how many times a failed send is retried (reopening the
+// stream each time) and the initial backoff between attempts. The backoff
+// doubles after each failed attempt.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.maxRetries = maxRetries
+		o.backoff = backoff
+	})
+}
+
+// WithMaxUnacked caps the number of sent-but-unacknowledged batches retained
+// for redelivery. When the cap is exceeded, the oldest batches are dropped.
+func WithMaxUnacked(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.maxUnacked = n
+		}
+	})
+}
+
+// WithSyncTimeout bounds how long Sync waits for the server to acknowledge
+// outstanding batches.
+func WithSyncTimeout(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.syncTimeout = d
+	})
+}
+
+// WithSyncDelivery makes every Write send its entry straight away and wait
+// for the server to acknowledge it, for at most the sync timeout (see
+// WithSyncTimeout). WriteContext additionally gives up at the context's
+// deadline, so synchronous audit logging can't exceed a request's budget.
+func WithSyncDelivery() Option {
+	return optionFunc(func(o *options) {
+		o.syncDelivery = true
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logsink
+
+import (
+	"google.golang.org/grpc"
+)
+
+const _serviceName = "zap.logsink.v1.LogSink"
+
+// Server is the server API for the LogSink service. Implementations must
+// answer each received Batch with an Ack (see logsink.proto).
+type Server interface {
+	Push(PushServer) error
+}
+
+// PushServer is the server side of a LogSink.Push stream.
+type PushServer interface {
+	Send(*Ack) error
+	Recv() (*Batch, error)
+	grpc.ServerStream
+}
+
+// ServerCodec returns a grpc.ServerOption that teaches a gRPC server to
+// decode LogSink messages. Messages of other services are passed through to
+// the standard protocol buffer codec, so the option is safe to use on shared
+// servers.
+func ServerCodec() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// RegisterServer registers a LogSink implementation with a gRPC server. The
+// server must have been constructed with ServerCodec.
+func RegisterServer(s *grpc.Server, srv Server) {
+	s.RegisterService(&_serviceDesc, srv)
+}
+
+var _serviceDesc = grpc.ServiceDesc{
+	ServiceName: _serviceName,
+	HandlerType: (*Server)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Push",
+		Handler:       pushHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "logsink.proto",
+}
+
+var _pushStreamDesc = &_serviceDesc.Streams[0]
+
+const _pushMethod = "/" + _serviceName + "/Push"
+
+func pushHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(Server).Push(pushServer{stream})
+}
+
+type pushServer struct {
+	grpc.ServerStream
+}
+
+func (s pushServer) Send(a *Ack) error {
+	return s.ServerStream.SendMsg(a)
+}
+
+func (s pushServer) Recv() (*Batch, error) {
+	b := &Batch{}
+	if err := s.ServerStream.RecvMsg(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,510 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package logsink streams zap's encoded log output to any gRPC server that
+// implements the LogSink service published in logsink.proto.
+//
+// The client side is a zap.Sink: entries are buffered into batches, sent on a
+// single long-lived bidirectional stream, and retained until the server
+// acknowledges them, so batches in flight when a stream breaks are resent
+// once the stream is reopened. All network I/O happens on a background
+// goroutine, so logging never waits for the server. Sync flushes the current
+// batch and waits for every outstanding batch to be acknowledged.
+//
+// Sinks can be constructed directly with New, or referenced from
+// zap.Config.OutputPaths after calling Register:
+//
+//	logsink.Register()
+//	cfg := zap.NewProductionConfig()
+//	cfg.OutputPaths = []string{"grpcs://logs.example.com:9000?batchSize=256"}
+package logsink // import "github.com/blastbao/zap/zapgrpc/logsink"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blastbao/zap"
+
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var errClosed = errors.New("logsink: write to closed sink")
+
+// Sink is a zap.Sink that streams log entries to a LogSink server. It's safe
+// for concurrent use.
+//
+// Writes only append to the current batch; a background goroutine does all
+// the network I/O, including reconnects and retries, so a slow or failing
+// server never holds up the goroutines that are logging.
+type Sink struct {
+	opts options
+	conn *grpc.ClientConn
+
+	mu       sync.Mutex
+	changed  *sync.Cond // broadcast when acks arrive, the stream fails, or a flush ends
+	stream   grpc.ClientStream
+	cancel   context.CancelFunc
+	pending  [][]byte
+	seq      uint64
+	unacked  []*Batch
+	asyncErr error // from background flushes, reported by the next Sync
+	closed   bool
+
+	kick chan struct{} // asks the sender to flush
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a Sink that delivers entries to the LogSink server at target,
+// which may be any target understood by grpc.NewClient. Connections are
+// established lazily, so New succeeds even if the server is unreachable.
+func New(target string, opts ...Option) (*Sink, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+
+	if o.transport.HasTLS() {
+		cfg, err := o.transport.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		o.creds = credentials.NewTLS(cfg)
+	}
+	dial, err := o.transport.DialContext()
+	if err != nil {
+		return nil, err
+	}
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(o.creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}),
+	}
+	dialOpts = append(dialOpts, o.dialOptions...)
+	conn, err := grpc.NewClient(target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sink{
+		opts: o,
+		conn: conn,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	s.changed = sync.NewCond(&s.mu)
+	go s.run()
+	return s, nil
+}
+
+// Write buffers a copy of p, asking the background goroutine to send the
+// current batch if it's full. With WithSyncDelivery, it waits for p to be
+// acknowledged.
+func (s *Sink) Write(p []byte) (int, error) {
+	return s.WriteContext(context.Background(), p)
+}
+
+// WriteContext is like Write, but with WithSyncDelivery it stops waiting
+// for the acknowledgement once ctx is done, if that's sooner than the sync
+// timeout. It implements zapcore.ContextWriter, so Loggers bound to a
+// context with zap.Logger.WithContext use it.
+func (s *Sink) WriteContext(ctx context.Context, p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errClosed
+	}
+	s.pending = append(s.pending, append([]byte(nil), p...))
+	if s.opts.syncDelivery {
+		return len(p), s.syncLocked(ctx)
+	}
+	if len(s.pending) >= s.opts.batchSize {
+		s.flushAsync()
+	}
+	return len(p), nil
+}
+
+// Sync sends any buffered entries and waits until the server has acknowledged
+// every batch, or until the configured sync timeout expires.
+func (s *Sink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncLocked(context.Background())
+}
+
+// Close syncs the Sink, then closes the stream and the underlying connection.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.syncLocked(context.Background())
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	if s.stream != nil {
+		err = multierr.Append(err, s.stream.CloseSend())
+	}
+	s.resetLocked()
+	s.mu.Unlock()
+
+	return multierr.Append(err, s.conn.Close())
+}
+
+// flushAsync asks the background goroutine to flush, without waiting.
+func (s *Sink) flushAsync() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run is the background goroutine that sends batches, on request and every
+// flush interval.
+func (s *Sink) run() {
+	defer close(s.done)
+
+	var tick <-chan time.Time
+	if s.opts.flushInterval > 0 {
+		ticker := time.NewTicker(s.opts.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-tick:
+		case <-s.kick:
+		}
+		err := s.flush()
+
+		s.mu.Lock()
+		if err != nil {
+			s.asyncErr = multierr.Append(s.asyncErr, err)
+		}
+		s.changed.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// syncLocked asks the background goroutine to send any buffered entries, and
+// waits for every batch to be acknowledged, until the sync timeout expires or
+// ctx is done, whichever is sooner. It gives up early if a flush fails.
+func (s *Sink) syncLocked(ctx context.Context) error {
+	err := s.asyncErr
+	s.asyncErr = nil
+	if len(s.pending) == 0 && len(s.unacked) == 0 {
+		return err
+	}
+
+	// Wake ourselves up when the timeout expires or ctx is done; since the
+	// waker takes the lock, the broadcast can't be lost.
+	timer := time.NewTimer(s.opts.syncTimeout)
+	defer timer.Stop()
+	stopWaker := make(chan struct{})
+	defer close(stopWaker)
+	go func() {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-stopWaker:
+			return
+		}
+		s.mu.Lock()
+		s.changed.Broadcast()
+		s.mu.Unlock()
+	}()
+	deadline := time.Now().Add(s.opts.syncTimeout)
+
+	for len(s.pending) > 0 || len(s.unacked) > 0 {
+		if s.asyncErr != nil {
+			err = multierr.Append(err, s.asyncErr)
+			s.asyncErr = nil
+			return err
+		}
+		if ctx.Err() != nil || !time.Now().Before(deadline) {
+			return multierr.Append(err, fmt.Errorf(
+				"logsink: timed out waiting for %d batches to be acknowledged", len(s.unacked)+batches(len(s.pending), s.opts.batchSize),
+			))
+		}
+		s.flushAsync()
+		s.changed.Wait()
+	}
+	return err
+}
+
+// batches returns the number of batches n entries fill.
+func batches(n, size int) int {
+	return (n + size - 1) / size
+}
+
+// flush turns the buffered entries into batches and sends them, reopening
+// the stream first if it's broken and batches are outstanding. It runs only
+// on the background goroutine, and doesn't hold the lock while it sends.
+func (s *Sink) flush() error {
+	s.mu.Lock()
+	var (
+		todo []*Batch
+		err  error
+	)
+	for len(s.pending) > 0 {
+		n := s.opts.batchSize
+		if n > len(s.pending) {
+			n = len(s.pending)
+		}
+		s.seq++
+		b := &Batch{Sequence: s.seq, Entries: s.pending[:n:n]}
+		s.pending = s.pending[n:]
+		s.unacked = append(s.unacked, b)
+		todo = append(todo, b)
+	}
+	s.pending = nil
+	if n := len(s.unacked) - s.opts.maxUnacked; n > 0 {
+		s.unacked = append([]*Batch(nil), s.unacked[n:]...)
+		err = fmt.Errorf("logsink: dropped %d unacknowledged batches", n)
+		if len(todo) > len(s.unacked) {
+			todo = todo[len(todo)-len(s.unacked):]
+		}
+	}
+	reconnect := s.stream == nil && len(s.unacked) > 0
+	s.mu.Unlock()
+
+	if reconnect {
+		// Opening the stream sends every outstanding batch, including todo.
+		return multierr.Append(err, s.send(nil))
+	}
+	for _, b := range todo {
+		if serr := s.send(b); serr != nil {
+			// A failed send resets the stream, and the next flush resends
+			// everything that's outstanding.
+			return multierr.Append(err, serr)
+		}
+	}
+	return err
+}
+
+// send delivers a batch, (re)opening the stream if necessary. Since opening
+// a stream resends every unacknowledged batch (including b), a successful
+// reconnect is also a successful send. A nil batch just reopens the stream.
+func (s *Sink) send(b *Batch) error {
+	backoff := s.opts.backoff
+	for attempt := 0; ; attempt++ {
+		s.mu.Lock()
+		stream := s.stream
+		s.mu.Unlock()
+
+		var err error
+		if stream == nil {
+			err = s.connect()
+		} else if b != nil {
+			err = stream.SendMsg(b)
+		}
+		if err == nil {
+			return nil
+		}
+
+		s.mu.Lock()
+		if s.stream == stream {
+			s.resetLocked()
+		}
+		s.mu.Unlock()
+		if attempt >= s.opts.maxRetries {
+			what := "reconnecting"
+			if b != nil {
+				what = fmt.Sprintf("sending batch %d", b.Sequence)
+			}
+			return fmt.Errorf("logsink: %s failed after %d attempts: %v", what, attempt+1, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// connect opens a stream and sends every unacknowledged batch on it. Only the
+// background goroutine creates batches, so none can be added while it runs.
+func (s *Sink) connect() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.conn.NewStream(ctx, _pushStreamDesc, _pushMethod, grpc.ForceCodec(codec{}))
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	s.mu.Lock()
+	unacked := append([]*Batch(nil), s.unacked...)
+	s.mu.Unlock()
+	for _, b := range unacked {
+		if err := stream.SendMsg(b); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.stream, s.cancel = stream, cancel
+	s.mu.Unlock()
+	go s.receive(stream)
+	return nil
+}
+
+func (s *Sink) resetLocked() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stream, s.cancel = nil, nil
+}
+
+func (s *Sink) receive(stream grpc.ClientStream) {
+	for {
+		ack := &Ack{}
+		err := stream.RecvMsg(ack)
+
+		s.mu.Lock()
+		if err != nil {
+			if s.stream == stream {
+				s.resetLocked()
+			}
+			s.changed.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+		s.ackLocked(ack.Sequence)
+		s.changed.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+func (s *Sink) ackLocked(seq uint64) {
+	i := 0
+	for i < len(s.unacked) && s.unacked[i].Sequence <= seq {
+		i++
+	}
+	s.unacked = s.unacked[i:]
+}
+
+// Register registers sink factories for the "grpc" (plaintext) and "grpcs"
+// (TLS, verified against the system roots unless a CA bundle is supplied) URL
+// schemes with zap.RegisterTransportSink. The supplied options apply to every
+// sink opened through the registry.
+//
+// URLs take the form grpc://host:port, optionally with the query parameters
+// batchSize, flushInterval, maxRetries, syncTimeout, and syncDelivery, as well
+// as any of the parameters understood by zap.TransportConfig (caFile,
+// certFile, keyFile, serverName, insecureSkipVerify, proxy, and dialTimeout).
+// Durations use time.ParseDuration syntax. TLS parameters are only allowed
+// with grpcs URLs.
+func Register(opts ...Option) error {
+	return multierr.Combine(
+		zap.RegisterTransportSink("grpc", false, func(u *url.URL) (zap.Sink, error) {
+			return newURLSink(u, opts)
+		}),
+		zap.RegisterTransportSink("grpcs", true, func(u *url.URL) (zap.Sink, error) {
+			return newURLSink(u, opts)
+		}),
+	)
+}
+
+func newURLSink(u *url.URL, base []Option) (zap.Sink, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%s URLs must include a host: got %v", u.Scheme, u)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("paths not allowed with %s URLs: got %v", u.Scheme, u)
+	}
+
+	// Transport parameters in the URL override those passed to Register.
+	o := defaultOptions()
+	for _, opt := range base {
+		opt.apply(&o)
+	}
+	transport, query, err := o.transport.ApplyQuery(u.Query())
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "grpcs" {
+		if transport.ServerName == "" {
+			transport.ServerName = u.Hostname()
+		}
+	} else if transport.HasTLS() {
+		return nil, fmt.Errorf("TLS parameters require a grpcs URL: got %v", u)
+	}
+
+	opts := append([]Option(nil), base...)
+	opts = append(opts, WithTransport(transport))
+	for key, vals := range query {
+		val := vals[len(vals)-1]
+		switch key {
+		case "batchSize":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid batchSize %q: %v", val, err)
+			}
+			opts = append(opts, WithBatchSize(n))
+		case "maxRetries":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid maxRetries %q: %v", val, err)
+			}
+			opts = append(opts, optionFunc(func(o *options) { o.maxRetries = n }))
+		case "flushInterval":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flushInterval %q: %v", val, err)
+			}
+			opts = append(opts, WithFlushInterval(d))
+		case "syncTimeout":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid syncTimeout %q: %v", val, err)
+			}
+			opts = append(opts, WithSyncTimeout(d))
+		case "syncDelivery":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid syncDelivery %q: %v", val, err)
+			}
+			if b {
+				opts = append(opts, WithSyncDelivery())
+			}
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in %v", key, u)
+		}
+	}
+	return New(u.Host, opts...)
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package logsink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// memServer is a LogSink server which records every entry it receives. It
+// can be told to fail a number of streams before accepting any batches, and
+// to delay its acknowledgements.
+type memServer struct {
+	mu       sync.Mutex
+	entries  []string
+	batches  int
+	failures int
+	ackDelay time.Duration
+}
+
+func (m *memServer) Push(stream PushServer) error {
+	m.mu.Lock()
+	if m.failures > 0 {
+		m.failures--
+		m.mu.Unlock()
+		return errors.New("fail")
+	}
+	m.mu.Unlock()
+
+	for {
+		b, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.batches++
+		for _, e := range b.Entries {
+			m.entries = append(m.entries, string(e))
+		}
+		m.mu.Unlock()
+		time.Sleep(m.ackDelay)
+		if err := stream.Send(&Ack{Sequence: b.Sequence}); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *memServer) Entries() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.entries...)
+}
+
+func (m *memServer) Batches() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.batches
+}
+
+func withServer(t testing.TB, srv *memServer, f func(addr string)) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen.")
+
+	s := grpc.NewServer(ServerCodec())
+	RegisterServer(s, srv)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	f(lis.Addr().String())
+}
+
+func TestSinkDeliversOnSync(t *testing.T) {
+	srv := &memServer{}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(0))
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		for _, msg := range []string{"foo", "bar", "baz"} {
+			_, err := sink.Write([]byte(msg))
+			require.NoError(t, err, "Unexpected error writing to sink.")
+		}
+		assert.Empty(t, srv.Entries(), "Expected entries to be buffered until Sync.")
+
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, []string{"foo", "bar", "baz"}, srv.Entries(), "Unexpected entries delivered.")
+		assert.Equal(t, 1, srv.Batches(), "Expected a single batch.")
+	})
+}
+
+func TestSinkBatchSize(t *testing.T) {
+	srv := &memServer{}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(0), WithBatchSize(2))
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		for _, msg := range []string{"a", "b", "c"} {
+			sink.Write([]byte(msg))
+		}
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, []string{"a", "b", "c"}, srv.Entries(), "Unexpected entries delivered.")
+		assert.Equal(t, 2, srv.Batches(), "Expected a full batch and a partial batch.")
+	})
+}
+
+func TestSinkFlushInterval(t *testing.T) {
+	srv := &memServer{}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(time.Millisecond))
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		sink.Write([]byte("tick"))
+		assert.Eventually(t, func() bool {
+			return len(srv.Entries()) == 1
+		}, time.Second, time.Millisecond, "Expected periodic flush to deliver entry.")
+	})
+}
+
+func TestSinkRetriesFailedStreams(t *testing.T) {
+	srv := &memServer{failures: 2}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(0), WithRetry(5, time.Millisecond))
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		sink.Write([]byte("persistent"))
+		require.NoError(t, sink.Sync(), "Expected Sync to succeed after retries.")
+		assert.Equal(t, []string{"persistent"}, srv.Entries(), "Unexpected entries delivered.")
+	})
+}
+
+func TestSinkGivesUp(t *testing.T) {
+	sink, err := New("127.0.0.1:1", WithFlushInterval(0), WithRetry(1, time.Millisecond))
+	require.NoError(t, err, "Failed to create sink.")
+
+	sink.Write([]byte("lost"))
+	err = sink.Sync()
+	require.Error(t, err, "Expected Sync to fail without a server.")
+	assert.Contains(t, err.Error(), "after 2 attempts", "Unexpected error message.")
+
+	sink.Close()
+	_, err = sink.Write([]byte("closed"))
+	assert.Equal(t, errClosed, err, "Expected writes after Close to fail.")
+}
+
+func TestSinkWriteDoesNotWaitForServer(t *testing.T) {
+	sink, err := New("127.0.0.1:1", WithFlushInterval(0), WithRetry(2, 100*time.Millisecond), WithBatchSize(1))
+	require.NoError(t, err, "Failed to create sink.")
+	defer sink.Close()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		_, err := sink.Write([]byte("unreachable"))
+		require.NoError(t, err, "Unexpected error writing to sink.")
+	}
+	assert.True(t, time.Since(start) < 100*time.Millisecond, "Expected writes not to wait for retries.")
+}
+
+func TestSinkMaxUnacked(t *testing.T) {
+	sink, err := New("127.0.0.1:1", WithFlushInterval(0), WithRetry(0, 0), WithBatchSize(1), WithMaxUnacked(1))
+	require.NoError(t, err, "Failed to create sink.")
+	defer sink.Close()
+
+	sink.Write([]byte("first"))
+	require.Error(t, sink.Sync(), "Expected Sync to fail without a server.")
+	_, err = sink.Write([]byte("second"))
+	require.NoError(t, err, "Expected writes not to wait for delivery.")
+	err = sink.Sync()
+	require.Error(t, err, "Expected an error when dropping batches.")
+	assert.Contains(t, err.Error(), "dropped 1 unacknowledged batches", "Unexpected error message.")
+}
+
+func TestSinkSyncDelivery(t *testing.T) {
+	srv := &memServer{}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(0), WithSyncDelivery())
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		_, err = sink.Write([]byte("audit"))
+		require.NoError(t, err, "Unexpected error writing to sink.")
+		assert.Equal(t, []string{"audit"}, srv.Entries(), "Expected Write to wait for delivery.")
+	})
+}
+
+func TestSinkWriteContextDeadline(t *testing.T) {
+	srv := &memServer{ackDelay: time.Second}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(0), WithSyncDelivery(), WithSyncTimeout(time.Minute))
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err = sink.WriteContext(ctx, []byte("audit"))
+		require.Error(t, err, "Expected the write to time out.")
+		assert.Contains(t, err.Error(), "timed out waiting", "Unexpected error message.")
+		assert.True(t, time.Since(start) < 500*time.Millisecond, "Expected the context's deadline to bound the wait.")
+	})
+}
+
+func TestSinkWriteContextCanceled(t *testing.T) {
+	srv := &memServer{ackDelay: time.Second}
+	withServer(t, srv, func(addr string) {
+		sink, err := New(addr, WithFlushInterval(0), WithSyncDelivery(), WithSyncTimeout(time.Minute))
+		require.NoError(t, err, "Failed to create sink.")
+		defer sink.Close()
+
+		// Without a deadline, only cancellation can cut the wait short.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		time.AfterFunc(20*time.Millisecond, cancel)
+		start := time.Now()
+		_, err = sink.WriteContext(ctx, []byte("audit"))
+		require.Error(t, err, "Expected the write to give up once the context was canceled.")
+		assert.True(t, time.Since(start) < 500*time.Millisecond, "Expected cancellation to bound the wait.")
+	})
+}
+
+// The sink registry is global, so only register our schemes once.
+var _registerOnce sync.Once
+
+func TestRegister(t *testing.T) {
+	srv := &memServer{}
+	withServer(t, srv, func(addr string) {
+		_registerOnce.Do(func() {
+			require.NoError(t, Register(), "Failed to register sinks.")
+		})
+
+		ws, closeSinks, err := zap.Open("grpc://" + addr + "?batchSize=1&flushInterval=0s")
+		require.NoError(t, err, "Failed to open sink by URL.")
+		defer closeSinks()
+
+		ws.Write([]byte("by-url"))
+		assert.Eventually(t, func() bool {
+			return len(srv.Entries()) == 1
+		}, time.Second, time.Millisecond, "Expected full batch to be delivered.")
+	})
+}
+
+func TestNewURLSinkErrors(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"grpc:///no-host", "must include a host"},
+		{"grpc://localhost:1/path", "paths not allowed"},
+		{"grpc://localhost:1?batchSize=many", "invalid batchSize"},
+		{"grpc://localhost:1?maxRetries=many", "invalid maxRetries"},
+		{"grpc://localhost:1?flushInterval=soon", "invalid flushInterval"},
+		{"grpc://localhost:1?syncTimeout=later", "invalid syncTimeout"},
+		{"grpc://localhost:1?syncDelivery=maybe", "invalid syncDelivery"},
+		{"grpc://localhost:1?color=blue", "unknown query parameter"},
+		{"grpc://localhost:1?serverName=logs", "TLS parameters require a grpcs URL"},
+		{"grpcs://localhost:1?dialTimeout=never", "invalid dialTimeout"},
+		{"grpcs://localhost:1?caFile=/does/not/exist", "can't read CA bundle"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		require.NoError(t, err, "Failed to parse test URL.")
+		_, err = newURLSink(u, nil)
+		if assert.Error(t, err, "Expected an error for URL %q.", tt.url) {
+			assert.True(t, strings.Contains(err.Error(), tt.err), "Unexpected error for URL %q: %v.", tt.url, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapgrpcmw
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryClientInterceptor returns an interceptor that logs each unary call a
+// client makes.
+func UnaryClientInterceptor(logger *zap.Logger, opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(zapcore.DebugLevel, opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		p := &peer.Peer{}
+		err := invoker(ctx, method, req, reply, cc, append(callOpts, grpc.Peer(p))...)
+
+		fields := clientFields(method, p)
+		if o.payloads {
+			fields = append(fields, o.payload("request", req))
+			if err == nil {
+				fields = append(fields, o.payload("response", reply))
+			}
+		}
+		o.finish(logger, "gRPC call", err, time.Since(start), fields...)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that logs each streaming
+// call a client makes. The call is logged when it fails to start, when
+// RecvMsg first returns an error (io.EOF for streams that finish normally),
+// or, for client-streaming calls, when RecvMsg receives the single response
+// (as CloseAndRecv does). Callers of server-streaming calls must receive
+// until RecvMsg fails for the call to be logged.
+func StreamClientInterceptor(logger *zap.Logger, opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(zapcore.DebugLevel, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		p := &peer.Peer{}
+		cs, err := streamer(ctx, desc, cc, method, append(callOpts, grpc.Peer(p))...)
+		if err != nil {
+			o.finish(logger, "gRPC call", err, time.Since(start), clientFields(method, p)...)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream: cs,
+			log:          logger,
+			opts:         &o,
+			method:       method,
+			unary:        !desc.ServerStreams,
+			peer:         p,
+			start:        start,
+		}, nil
+	}
+}
+
+func clientFields(method string, p *peer.Peer) []zap.Field {
+	fields := []zap.Field{zap.String("method", method)}
+	if p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	return fields
+}
+
+// clientStream logs a stream's messages, and the call once it ends.
+type clientStream struct {
+	grpc.ClientStream
+	log    *zap.Logger
+	opts   *options
+	method string
+	unary  bool // the server sends a single response
+	peer   *peer.Peer
+	start  time.Time
+	once   sync.Once
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.opts.logMessage(s.log, "sent", m, zap.String("method", s.method))
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.opts.logMessage(s.log, "received", m, zap.String("method", s.method))
+		if !s.unary {
+			return nil
+		}
+	}
+	s.once.Do(func() {
+		s.opts.finish(s.log, "gRPC call", err, time.Since(s.start), clientFields(s.method, s.peer)...)
+	})
+	return err
+}
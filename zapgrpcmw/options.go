@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapgrpcmw
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zapproto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// _defaultMaxPayloadBytes is the default cap on the size of logged payloads.
+const _defaultMaxPayloadBytes = 4 << 10
+
+// An Option configures the interceptors.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+type options struct {
+	okLevel         zapcore.Level
+	codeToLevel     func(codes.Code) zapcore.Level
+	payloads        bool
+	maxPayloadBytes int
+}
+
+func newOptions(okLevel zapcore.Level, opts []Option) options {
+	o := options{
+		okLevel:         okLevel,
+		maxPayloadBytes: _defaultMaxPayloadBytes,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return o
+}
+
+// CodeToLevel sets the function that chooses the level of each call's
+// entry from its status code. By default, successful calls are logged at
+// InfoLevel by server interceptors and DebugLevel by client interceptors;
+// codes that usually mean the caller is at fault (such as InvalidArgument,
+// NotFound, and PermissionDenied) at WarnLevel; and the rest at ErrorLevel.
+func CodeToLevel(f func(codes.Code) zapcore.Level) Option {
+	return optionFunc(func(o *options) {
+		o.codeToLevel = f
+	})
+}
+
+// LogPayloads logs the messages of each call: unary calls add the request
+// and response to the call's entry, and streams write a DebugLevel entry for
+// every message sent or received. Payloads may hold sensitive data, so
+// they're off by default.
+func LogPayloads() Option {
+	return optionFunc(func(o *options) {
+		o.payloads = true
+	})
+}
+
+// MaxPayloadBytes caps the size of each logged payload's JSON mapping;
+// longer payloads are logged as a truncated string, as zapproto.MessageWithLimit
+// does. Payloads that aren't protocol buffer messages are mapped with
+// encoding/json. It defaults to 4KiB, and a non-positive limit disables truncation.
+func MaxPayloadBytes(n int) Option {
+	return optionFunc(func(o *options) {
+		o.maxPayloadBytes = n
+	})
+}
+
+func (o *options) level(code codes.Code) zapcore.Level {
+	if o.codeToLevel != nil {
+		return o.codeToLevel(code)
+	}
+	switch code {
+	case codes.OK:
+		return o.okLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.ResourceExhausted, codes.Aborted:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// payload constructs a field for a message, truncated to maxPayloadBytes.
+func (o *options) payload(key string, msg interface{}) zap.Field {
+	if m, ok := msg.(proto.Message); ok {
+		return zapproto.MessageWithLimit(key, m, o.maxPayloadBytes)
+	}
+	if o.maxPayloadBytes <= 0 {
+		return zap.Any(key, msg)
+	}
+	return zap.Reflect(key, limitedJSON{v: msg, maxBytes: o.maxPayloadBytes})
+}
+
+// limitedJSON marshals v with encoding/json, replacing output longer than
+// maxBytes with a truncated string like zapproto.MessageWithLimit.
+type limitedJSON struct {
+	v        interface{}
+	maxBytes int
+}
+
+func (l limitedJSON) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(l.v)
+	if err != nil || len(b) <= l.maxBytes {
+		return b, err
+	}
+	return json.Marshal(string(b[:l.maxBytes]) + "...(truncated from " + strconv.Itoa(len(b)) + " bytes)")
+}
+
+// finish writes the entry for a completed call.
+func (o *options) finish(log *zap.Logger, msg string, err error, latency time.Duration, fields ...zap.Field) {
+	code := codeOf(err)
+	ce := log.Check(o.level(code), msg)
+	if ce == nil {
+		return
+	}
+	fields = append(fields, zap.String("code", code.String()), zap.Duration("latency", latency))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+// logMessage writes the entry for a message sent or received on a stream.
+func (o *options) logMessage(log *zap.Logger, direction string, msg interface{}, fields ...zap.Field) {
+	if !o.payloads {
+		return
+	}
+	if ce := log.Check(zapcore.DebugLevel, "gRPC stream message"); ce != nil {
+		ce.Write(append(fields, zap.String("direction", direction), o.payload("payload", msg))...)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapgrpcmw provides gRPC interceptors that log every call with zap:
+//
+//	server := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(zapgrpcmw.UnaryServerInterceptor(logger)),
+//		grpc.ChainStreamInterceptor(zapgrpcmw.StreamServerInterceptor(logger)),
+//	)
+//
+//	conn, err := grpc.NewClient(target,
+//		grpc.WithChainUnaryInterceptor(zapgrpcmw.UnaryClientInterceptor(logger)),
+//		grpc.WithChainStreamInterceptor(zapgrpcmw.StreamClientInterceptor(logger)),
+//	)
+//
+// Each call's entry has the full method name, the peer's address, the
+// status code, and the latency, at a level chosen from the code (see
+// CodeToLevel). Server interceptors also give handlers a Logger carrying the
+// method and peer, which they can retrieve with zap.FromContext. Payloads
+// are only logged with LogPayloads.
+package zapgrpcmw // import "github.com/blastbao/zap/zapgrpcmw"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns an interceptor that logs each unary call a
+// server handles.
+func UnaryServerInterceptor(logger *zap.Logger, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(zapcore.InfoLevel, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		log := requestLogger(logger, ctx, info.FullMethod)
+		resp, err := handler(zap.IntoContext(ctx, log), req)
+
+		var fields []zap.Field
+		if o.payloads {
+			fields = append(fields, o.payload("request", req))
+			if err == nil {
+				fields = append(fields, o.payload("response", resp))
+			}
+		}
+		o.finish(log, "gRPC call served", err, time.Since(start), fields...)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that logs each streaming
+// call a server handles, once the handler returns.
+func StreamServerInterceptor(logger *zap.Logger, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(zapcore.InfoLevel, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		log := requestLogger(logger, ss.Context(), info.FullMethod)
+		err := handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          zap.IntoContext(ss.Context(), log),
+			log:          log,
+			opts:         &o,
+		})
+		o.finish(log, "gRPC call served", err, time.Since(start))
+		return err
+	}
+}
+
+// requestLogger returns a Logger for a call, with its method and peer.
+func requestLogger(logger *zap.Logger, ctx context.Context, method string) *zap.Logger {
+	fields := []zap.Field{zap.String("method", method)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	return logger.With(fields...)
+}
+
+// serverStream gives a stream's handler the call's Logger, and logs its
+// messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	log  *zap.Logger
+	opts *options
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.opts.logMessage(s.log, "sent", m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.opts.logMessage(s.log, "received", m)
+	}
+	return err
+}
+
+// codeOf returns the status code of a call's error, treating io.EOF, which
+// ends streams that finished normally, as OK.
+func codeOf(err error) codes.Code {
+	if errors.Is(err, io.EOF) {
+		return codes.OK
+	}
+	return status.Code(err)
+}
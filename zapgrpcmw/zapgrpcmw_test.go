@@ -0,0 +1,238 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapgrpcmw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// withHealthServer serves the gRPC health service over an in-memory
+// connection, with logging interceptors on both ends.
+func withHealthServer(t *testing.T, opts []Option, f func(healthpb.HealthClient, *observer.ObservedLogs, *observer.ObservedLogs)) {
+	serverCore, serverLogs := observer.New(zapcore.DebugLevel)
+	clientCore, clientLogs := observer.New(zapcore.DebugLevel)
+	serverLogger, clientLogger := zap.New(serverCore), zap.New(clientCore)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(serverLogger, opts...)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(serverLogger, opts...)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(clientLogger, opts...)),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(clientLogger, opts...)),
+	)
+	require.NoError(t, err, "Failed to dial the server.")
+	defer conn.Close()
+
+	f(healthpb.NewHealthClient(conn), serverLogs, clientLogs)
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	withHealthServer(t, nil, func(client healthpb.HealthClient, serverLogs, clientLogs *observer.ObservedLogs) {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "svc"})
+		require.NoError(t, err, "Unexpected error checking health.")
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+		require.Equal(t, codes.NotFound, status.Code(err), "Expected an unknown service not to be found.")
+
+		tests := []struct {
+			logs  *observer.ObservedLogs
+			msg   string
+			level zapcore.Level
+		}{
+			{serverLogs, "gRPC call served", zapcore.InfoLevel},
+			{clientLogs, "gRPC call", zapcore.DebugLevel},
+		}
+		for _, tt := range tests {
+			entries := tt.logs.AllUntimed()
+			require.Equal(t, 2, len(entries), "Expected an entry for each call.")
+			for i, code := range []codes.Code{codes.OK, codes.NotFound} {
+				fields := entries[i].ContextMap()
+				assert.Equal(t, tt.msg, entries[i].Message, "Unexpected message.")
+				assert.Equal(t, "/grpc.health.v1.Health/Check", fields["method"], "Unexpected method.")
+				assert.Equal(t, "bufconn", fields["peer"], "Unexpected peer.")
+				assert.Equal(t, code.String(), fields["code"], "Unexpected code.")
+				assert.Contains(t, fields, "latency", "Expected the latency.")
+				assert.NotContains(t, fields, "request", "Expected payloads to be off by default.")
+			}
+			assert.Equal(t, tt.level, entries[0].Level, "Unexpected level for a successful call.")
+			assert.Equal(t, zapcore.WarnLevel, entries[1].Level, "Unexpected level for a failed call.")
+			assert.Contains(t, entries[1].ContextMap(), "error", "Expected the error.")
+		}
+	})
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	withHealthServer(t, []Option{LogPayloads()}, func(client healthpb.HealthClient, serverLogs, clientLogs *observer.ObservedLogs) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "svc"})
+		require.NoError(t, err, "Unexpected error watching health.")
+		resp, err := stream.Recv()
+		require.NoError(t, err, "Unexpected error receiving.")
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "Unexpected status.")
+		cancel()
+		for err == nil {
+			_, err = stream.Recv()
+		}
+
+		msgs := clientLogs.FilterMessage("gRPC stream message").AllUntimed()
+		require.Equal(t, 2, len(msgs), "Expected the sent and received messages to be logged.")
+		assert.Equal(t, "sent", msgs[0].ContextMap()["direction"], "Unexpected direction.")
+		assert.Equal(t, "received", msgs[1].ContextMap()["direction"], "Unexpected direction.")
+		assert.Equal(t, "/grpc.health.v1.Health/Watch", msgs[1].ContextMap()["method"], "Expected the method.")
+
+		calls := clientLogs.FilterMessage("gRPC call").AllUntimed()
+		require.Equal(t, 1, len(calls), "Expected the call to be logged once.")
+		assert.Equal(t, codes.Canceled.String(), calls[0].ContextMap()["code"], "Unexpected code.")
+
+		require.Eventually(t, func() bool {
+			return serverLogs.FilterMessage("gRPC call served").Len() == 1
+		}, time.Second, time.Millisecond, "Expected the server to log the call once the handler returned.")
+		served := serverLogs.FilterMessage("gRPC call served").AllUntimed()[0].ContextMap()
+		assert.Equal(t, "/grpc.health.v1.Health/Watch", served["method"], "Unexpected method.")
+		assert.Equal(t, 2, serverLogs.FilterMessage("gRPC stream message").Len(), "Expected the server's messages to be logged.")
+	})
+}
+
+// fakeClientStream is a client-streaming call whose server responds as
+// soon as the client closes its side.
+type fakeClientStream struct {
+	grpc.ClientStream
+	closed bool
+}
+
+func (s *fakeClientStream) SendMsg(interface{}) error { return nil }
+func (s *fakeClientStream) CloseSend() error          { s.closed = true; return nil }
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if !s.closed {
+		return errors.New("receiving before CloseSend")
+	}
+	return nil
+}
+
+func TestStreamClientInterceptorClientStreaming(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	interceptor := StreamClientInterceptor(zap.New(core))
+	desc := &grpc.StreamDesc{StreamName: "Upload", ClientStreams: true}
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{}, nil
+	}
+
+	cs, err := interceptor(context.Background(), desc, nil, "/pkg.Service/Upload", streamer)
+	require.NoError(t, err, "Unexpected error starting the stream.")
+	require.NoError(t, cs.SendMsg("chunk"), "Unexpected error sending.")
+	require.NoError(t, cs.CloseSend(), "Unexpected error closing the stream.")
+	require.NoError(t, cs.RecvMsg(new(string)), "Unexpected error receiving the response.")
+
+	calls := logs.FilterMessage("gRPC call").AllUntimed()
+	require.Equal(t, 1, len(calls), "Expected the call to be logged after the response.")
+	assert.Equal(t, codes.OK.String(), calls[0].ContextMap()["code"], "Unexpected code.")
+	assert.Equal(t, "/pkg.Service/Upload", calls[0].ContextMap()["method"], "Unexpected method.")
+}
+
+func TestPayloadLimit(t *testing.T) {
+	payload := map[string]string{"service": "a-long-service-name"}
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, `{"service":"a-long-service-name"}`},
+		{[]Option{MaxPayloadBytes(8)}, `"{\"servic...(truncated from 33 bytes)"`},
+		{[]Option{MaxPayloadBytes(0)}, `{"service":"a-long-service-name"}`},
+	}
+	for _, tt := range tests {
+		o := newOptions(zapcore.InfoLevel, tt.opts)
+		enc := zapcore.NewMapObjectEncoder()
+		o.payload("request", payload).AddTo(enc)
+		got, err := json.Marshal(enc.Fields["request"])
+		require.NoError(t, err, "Unexpected error marshaling the payload.")
+		assert.Equal(t, tt.want, string(got), "Unexpected payload.")
+	}
+}
+
+func TestUnaryServerInterceptorContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	interceptor := UnaryServerInterceptor(zap.New(core), LogPayloads(), MaxPayloadBytes(8))
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+
+	_, err := interceptor(ctx, &healthpb.HealthCheckRequest{Service: "a-long-service-name"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		zap.FromContext(ctx).Info("handling")
+		return nil, errors.New("boom")
+	})
+	require.EqualError(t, err, "boom", "Expected the handler's error.")
+
+	entries := logs.AllUntimed()
+	require.Equal(t, 2, len(entries), "Expected the handler's entry and the call's entry.")
+	assert.Equal(t, "/pkg.Service/Method", entries[0].ContextMap()["method"], "Expected the handler's Logger to carry the method.")
+	assert.Equal(t, "10.0.0.1:5000", entries[0].ContextMap()["peer"], "Expected the handler's Logger to carry the peer.")
+
+	call := entries[1]
+	assert.Equal(t, zapcore.ErrorLevel, call.Level, "Expected unknown errors at ErrorLevel.")
+	assert.Equal(t, codes.Unknown.String(), call.ContextMap()["code"], "Unexpected code.")
+	assert.NotContains(t, call.ContextMap(), "response", "Expected no response payload for a failed call.")
+	req, err := json.Marshal(call.ContextMap()["request"])
+	require.NoError(t, err, "Unexpected error marshaling the request payload.")
+	assert.Equal(t, `"{\"servic...(truncated from 33 bytes)"`, string(req), "Expected a truncated request payload.")
+}
+
+func TestCodeToLevel(t *testing.T) {
+	o := newOptions(zapcore.InfoLevel, []Option{CodeToLevel(func(codes.Code) zapcore.Level {
+		return zapcore.DPanicLevel
+	})})
+	assert.Equal(t, zapcore.DPanicLevel, o.level(codes.OK), "Expected the custom mapping.")
+
+	o = newOptions(zapcore.InfoLevel, nil)
+	assert.Equal(t, zapcore.InfoLevel, o.level(codes.OK), "Unexpected level for OK.")
+	assert.Equal(t, zapcore.WarnLevel, o.level(codes.PermissionDenied), "Unexpected level for a client error.")
+	assert.Equal(t, zapcore.ErrorLevel, o.level(codes.Internal), "Unexpected level for a server error.")
+}
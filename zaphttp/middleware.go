@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zaphttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+)
+
+// DefaultRequestIDHeader is the header Middleware reads request IDs from
+// unless RequestIDHeader says otherwise.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDHeader sets the header Middleware reads each request's ID from.
+// An empty name turns request IDs off.
+func RequestIDHeader(name string) Option {
+	return optionFunc(func(c *config) {
+		c.requestIDHeader = name
+	})
+}
+
+// Middleware returns HTTP middleware that writes an access log entry for
+// every request the wrapped handler serves:
+//
+//	http.ListenAndServe(addr, zaphttp.Middleware(logger)(mux))
+//
+// Responses with statuses below 400 are logged at InfoLevel, 4xx statuses
+// at WarnLevel, and 5xx statuses at ErrorLevel. Each entry has the request's
+// method and path, the response status, the number of body bytes written,
+// and the latency.
+//
+// If the request has an ID in its RequestIDHeader, the ID is added to the
+// entry under the "requestID" key. The handler also finds a Logger with the
+// ID in the request's context, for use with zap.FromContext, so everything
+// it logs about the request can be correlated.
+//
+// Requests whose handler panics are still logged, with a 500 status unless
+// the handler already wrote one, before the panic continues to net/http.
+// Hijacked connections are logged with a 101 status unless the handler wrote
+// another.
+func Middleware(logger *zap.Logger, opts ...Option) func(http.Handler) http.Handler {
+	cfg := newConfig(zapcore.InfoLevel, opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			log := logger
+			if cfg.requestIDHeader != "" {
+				if id := r.Header.Get(cfg.requestIDHeader); id != "" {
+					log = log.With(zap.String("requestID", id))
+				}
+			}
+			r = r.WithContext(zap.IntoContext(r.Context(), log))
+			rec := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				status := rec.status
+				if status == 0 {
+					status = http.StatusOK
+					if p != nil {
+						status = http.StatusInternalServerError
+					}
+				}
+				if lvl, ok := cfg.level(status); ok {
+					if ce := log.Check(lvl, "HTTP request served"); ce != nil {
+						ce.Write(
+							zap.String("method", r.Method),
+							zap.String("path", r.URL.Path),
+							zap.Int("status", status),
+							zap.Int64("bytes", rec.bytes),
+							zap.Duration("latency", time.Since(start)),
+						)
+					}
+				}
+				if p != nil {
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// responseRecorder records the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, failing with http.ErrNotSupported if the
+// underlying ResponseWriter doesn't support it.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push implements http.Pusher, failing with http.ErrNotSupported if the
+// underlying ResponseWriter doesn't support it.
+func (r *responseRecorder) Push(target string, opts *http.PushOptions) error {
+	if p, ok := r.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zaphttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := Middleware(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zap.FromContext(r.Context()).Debug("handling")
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+
+	tests := []struct {
+		target string
+		id     string
+		level  zapcore.Level
+		path   string
+		status int64
+		bytes  int64
+	}{
+		{"/ok?secret=1", "req-1", zapcore.InfoLevel, "/ok", 200, 5},
+		{"/missing", "", zapcore.WarnLevel, "/missing", 404, 19},
+		{"/broken", "req-3", zapcore.ErrorLevel, "/broken", 502, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			logs.TakeAll()
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.id != "" {
+				req.Header.Set(DefaultRequestIDHeader, tt.id)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logs.AllUntimed()
+			require.Equal(t, 2, len(entries), "Expected the handler's entry and an access log entry.")
+			assert.Equal(t, "handling", entries[0].Message, "Expected the handler's entry first.")
+			access := entries[1]
+			assert.Equal(t, tt.level, access.Level, "Unexpected level.")
+			assert.Equal(t, "HTTP request served", access.Message, "Unexpected message.")
+			fields := access.ContextMap()
+			assert.Equal(t, "GET", fields["method"], "Unexpected method.")
+			assert.Equal(t, tt.path, fields["path"], "Expected the path without the query.")
+			assert.Equal(t, tt.status, fields["status"], "Unexpected status.")
+			assert.Equal(t, tt.bytes, fields["bytes"], "Unexpected size.")
+			assert.Contains(t, fields, "latency", "Expected the latency.")
+			if tt.id == "" {
+				assert.NotContains(t, fields, "requestID", "Expected no request ID.")
+				assert.NotContains(t, entries[0].ContextMap(), "requestID", "Expected no request ID.")
+			} else {
+				assert.Equal(t, tt.id, fields["requestID"], "Unexpected request ID.")
+				assert.Equal(t, tt.id, entries[0].ContextMap()["requestID"], "Expected the handler's Logger to carry the request ID.")
+			}
+		})
+	}
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := Middleware(zap.New(core), SuccessLevel(zapcore.DebugLevel), RequestIDHeader("X-Trace"))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Trace", "abc")
+	req.Header.Set(DefaultRequestIDHeader, "ignored")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Equal(t, 1, logs.Len(), "Expected an access log entry.")
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.DebugLevel, entry.Level, "Unexpected level.")
+	assert.Equal(t, "abc", entry.ContextMap()["requestID"], "Expected the configured request ID header.")
+	assert.Equal(t, int64(200), entry.ContextMap()["status"], "Expected an implicit 200 status.")
+}
+
+func TestMiddlewareSamplesSuccesses(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	status := http.StatusOK
+	handler := Middleware(zap.New(core), SuccessSampleRate(0))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 0, logs.Len(), "Expected successes to be sampled away.")
+	status = http.StatusInternalServerError
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 1, logs.Len(), "Expected failures to be logged regardless of sampling.")
+}
+
+func TestResponseRecorderFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &responseRecorder{ResponseWriter: w}
+	rec.Flush()
+	assert.True(t, w.Flushed, "Expected Flush to reach the underlying ResponseWriter.")
+	assert.Equal(t, w, rec.Unwrap(), "Unexpected underlying ResponseWriter.")
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := Middleware(zap.New(core))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}, "Expected the panic to reach net/http.")
+	require.Equal(t, 1, logs.Len(), "Expected an access log entry.")
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.ErrorLevel, entry.Level, "Unexpected level.")
+	assert.Equal(t, int64(http.StatusInternalServerError), entry.ContextMap()["status"], "Expected a 500 status.")
+}
+
+// hijackRecorder is a ResponseRecorder that supports hijacking.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseRecorderHijackAndPush(t *testing.T) {
+	rec := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+	_, _, err := rec.Hijack()
+	assert.Equal(t, http.ErrNotSupported, err, "Expected hijacking to fail without support.")
+	assert.Equal(t, http.ErrNotSupported, rec.Push("/style.css", nil), "Expected pushing to fail without support.")
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := Middleware(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err, "Unexpected error hijacking.")
+	}))
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ws", nil))
+	assert.True(t, w.hijacked, "Expected Hijack to reach the underlying ResponseWriter.")
+	require.Equal(t, 1, logs.Len(), "Expected an access log entry.")
+	assert.Equal(t, int64(http.StatusSwitchingProtocols), logs.All()[0].ContextMap()["status"], "Expected a 101 status.")
+}
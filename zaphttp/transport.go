@@ -0,0 +1,262 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zaphttp instruments HTTP clients and servers with zap. Its
+// Transport wraps an http.RoundTripper and logs every outbound request:
+//
+//	client := &http.Client{
+//		Transport: zaphttp.NewTransport(logger, zaphttp.SuccessSampleRate(0.1)),
+//	}
+//
+// Successful requests are logged at DebugLevel, responses with 4xx and 5xx
+// statuses at WarnLevel and ErrorLevel, and requests that fail outright at
+// ErrorLevel. Each entry has the request's method, its URL with the query
+// values redacted, the response status, and the latency.
+//
+// Middleware does the same for the requests a server handles, writing an
+// access log entry for each one; see its documentation for details. The
+// Options configure both.
+package zaphttp // import "github.com/blastbao/zap/zaphttp"
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/internal/randutil"
+	"github.com/blastbao/zap/zapcore"
+)
+
+// RedactedValue replaces redacted query values and passwords in logged URLs.
+const RedactedValue = "REDACTED"
+
+type retriesKey struct{}
+
+// WithRetries returns a copy of ctx recording that a request made with it
+// has already been attempted n times. Code that retries requests should set
+// it, so that the Transport can log how many retries each attempt took.
+func WithRetries(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, retriesKey{}, n)
+}
+
+// Retries returns the number of retries recorded in ctx by WithRetries.
+func Retries(ctx context.Context) int {
+	n, _ := ctx.Value(retriesKey{}).(int)
+	return n
+}
+
+// An Option configures a Transport or Middleware.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) {
+	f(c)
+}
+
+// config holds the settings shared by Transport and Middleware.
+type config struct {
+	base             http.RoundTripper
+	successLevel     zapcore.Level
+	clientErrorLevel zapcore.Level
+	serverErrorLevel zapcore.Level
+	failureLevel     zapcore.Level
+	sampleRate       float64
+	sample           func() float64
+	visibleParams    map[string]struct{}
+	requestIDHeader  string
+}
+
+func newConfig(successLevel zapcore.Level, opts []Option) config {
+	cfg := config{
+		successLevel:     successLevel,
+		clientErrorLevel: zapcore.WarnLevel,
+		serverErrorLevel: zapcore.ErrorLevel,
+		failureLevel:     zapcore.ErrorLevel,
+		sampleRate:       1,
+		sample:           randutil.NewFloat64(),
+		visibleParams:    make(map[string]struct{}),
+		requestIDHeader:  DefaultRequestIDHeader,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg
+}
+
+// level returns the level and whether to log an entry for a response with
+// the given status, sampling successes.
+func (c *config) level(status int) (zapcore.Level, bool) {
+	switch {
+	case status >= 500:
+		return c.serverErrorLevel, true
+	case status >= 400:
+		return c.clientErrorLevel, true
+	case c.sampleRate < 1 && c.sample() >= c.sampleRate:
+		return c.successLevel, false
+	default:
+		return c.successLevel, true
+	}
+}
+
+// Base sets the RoundTripper that makes a Transport's requests. By default,
+// it's http.DefaultTransport. Middleware ignores it.
+func Base(rt http.RoundTripper) Option {
+	return optionFunc(func(c *config) {
+		c.base = rt
+	})
+}
+
+// SuccessLevel sets the level of entries for responses with statuses below
+// 400. It defaults to DebugLevel for a Transport and InfoLevel for
+// Middleware.
+func SuccessLevel(lvl zapcore.Level) Option {
+	return optionFunc(func(c *config) {
+		c.successLevel = lvl
+	})
+}
+
+// ClientErrorLevel sets the level of entries for responses with 4xx
+// statuses.
+func ClientErrorLevel(lvl zapcore.Level) Option {
+	return optionFunc(func(c *config) {
+		c.clientErrorLevel = lvl
+	})
+}
+
+// ServerErrorLevel sets the level of entries for responses with 5xx
+// statuses.
+func ServerErrorLevel(lvl zapcore.Level) Option {
+	return optionFunc(func(c *config) {
+		c.serverErrorLevel = lvl
+	})
+}
+
+// FailureLevel sets the level of entries for requests that a Transport makes
+// that fail without a response, such as those that time out.
+func FailureLevel(lvl zapcore.Level) Option {
+	return optionFunc(func(c *config) {
+		c.failureLevel = lvl
+	})
+}
+
+// SuccessSampleRate logs only the given fraction of successful requests,
+// chosen at random. Failures are always logged.
+func SuccessSampleRate(rate float64) Option {
+	return optionFunc(func(c *config) {
+		c.sampleRate = rate
+	})
+}
+
+// VisibleQueryParams leaves the values of the named query parameters
+// unredacted in logged URLs.
+func VisibleQueryParams(names ...string) Option {
+	return optionFunc(func(c *config) {
+		for _, name := range names {
+			c.visibleParams[name] = struct{}{}
+		}
+	})
+}
+
+// A Transport is an http.RoundTripper that logs the requests it makes with
+// a zap Logger.
+type Transport struct {
+	config
+	logger *zap.Logger
+}
+
+// NewTransport creates a Transport that logs to logger.
+func NewTransport(logger *zap.Logger, opts ...Option) *Transport {
+	return &Transport{
+		config: newConfig(zapcore.DebugLevel, opts),
+		logger: logger,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	latency := time.Since(start)
+
+	lvl, msg := t.failureLevel, "HTTP request failed"
+	if err == nil {
+		var ok bool
+		if lvl, ok = t.level(resp.StatusCode); !ok {
+			return resp, err
+		}
+		msg = "HTTP request"
+	}
+	ce := t.logger.Check(lvl, msg)
+	if ce == nil {
+		return resp, err
+	}
+
+	fields := make([]zap.Field, 0, 6)
+	fields = append(fields,
+		zap.String("method", req.Method),
+		zap.String("url", t.redact(req.URL)),
+	)
+	if resp != nil {
+		fields = append(fields, zap.Int("status", resp.StatusCode))
+	}
+	fields = append(fields, zap.Duration("latency", latency))
+	if n := Retries(req.Context()); n > 0 {
+		fields = append(fields, zap.Int("retries", n))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+	return resp, err
+}
+
+// redact formats u with its password and the values of its query
+// parameters, other than the visible ones, replaced by RedactedValue.
+func (c *config) redact(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+	if _, ok := u.User.Password(); ok {
+		redacted.User = url.UserPassword(u.User.Username(), RedactedValue)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name, values := range query {
+			if _, ok := c.visibleParams[name]; ok {
+				continue
+			}
+			for i := range values {
+				values[i] = RedactedValue
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapnats
+
+import (
+	"time"
+
+	"github.com/blastbao/zap"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	_defaultSyncTimeout   = 5 * time.Second
+	_defaultDrainTimeout  = 30 * time.Second
+	_defaultReconnectWait = time.Second
+)
+
+// An Option configures a Sink.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+type options struct {
+	transport    zap.TransportConfig
+	natsOptions  []nats.Option
+	syncTimeout  time.Duration
+	drainTimeout time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		syncTimeout:  _defaultSyncTimeout,
+		drainTimeout: _defaultDrainTimeout,
+	}
+}
+
+// WithTransport configures how the sink connects to the NATS servers:
+// through an HTTP or SOCKS5 proxy, with a dial timeout, and, if cfg includes
+// any TLS settings, over TLS with the supplied CA bundle, client
+// certificate, and server name.
+func WithTransport(cfg zap.TransportConfig) Option {
+	return optionFunc(func(o *options) {
+		o.transport = cfg
+	})
+}
+
+// WithNATSOptions appends options used when connecting to NATS, such as
+// credentials or a custom reconnect policy. They're applied after the
+// Sink's own defaults, so they take precedence.
+func WithNATSOptions(opts ...nats.Option) Option {
+	return optionFunc(func(o *options) {
+		o.natsOptions = append(o.natsOptions, opts...)
+	})
+}
+
+// WithSyncTimeout bounds how long Sync waits for the server to confirm that
+// it has received everything published so far.
+func WithSyncTimeout(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.syncTimeout = d
+	})
+}
+
+// WithDrainTimeout bounds how long Close waits for buffered entries to be
+// delivered before closing the connection.
+func WithDrainTimeout(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.drainTimeout = d
+	})
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapnats publishes zap's encoded log output to a NATS subject, for
+// teams that already use a message bus to carry telemetry.
+//
+// Each entry is published as one message. The NATS client reconnects
+// automatically, buffering entries in memory while the server is
+// unreachable, so a restarting server doesn't interrupt logging. Sync waits
+// for the server to confirm it has received everything published so far,
+// and Close drains the connection, delivering buffered entries before
+// disconnecting.
+//
+// Sinks can be constructed directly with New, or referenced from
+// zap.Config.OutputPaths after calling Register:
+//
+//	zapnats.Register()
+//	cfg := zap.NewProductionConfig()
+//	cfg.OutputPaths = []string{"nats://nats.example.com:4222/logs.api"}
+//
+// The NATS client needs Go 1.20 or later.
+package zapnats // import "github.com/blastbao/zap/zapnats"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blastbao/zap"
+
+	"github.com/nats-io/nats.go"
+)
+
+var errClosed = errors.New("zapnats: write to closed sink")
+
+// Sink is a zap.Sink that publishes each entry to a NATS subject. It's safe
+// for concurrent use.
+type Sink struct {
+	opts    options
+	conn    *nats.Conn
+	subject string
+	closed  chan struct{} // closed once the connection is
+}
+
+// New connects to the NATS servers at servers (a comma-separated list of
+// server URLs, as accepted by nats.Connect) and returns a Sink that
+// publishes entries to subject. Unlike the NATS client's defaults, the Sink
+// tries to reconnect forever.
+func New(servers, subject string, opts ...Option) (*Sink, error) {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return nil, fmt.Errorf("zapnats: invalid subject %q", subject)
+	}
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+
+	s := &Sink{
+		opts:    o,
+		subject: subject,
+		closed:  make(chan struct{}),
+	}
+	natsOpts := []nats.Option{
+		nats.Name("zap"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(_defaultReconnectWait),
+		nats.DrainTimeout(o.drainTimeout),
+		nats.ClosedHandler(func(*nats.Conn) { close(s.closed) }),
+	}
+	if o.transport.HasTLS() {
+		cfg, err := o.transport.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		natsOpts = append(natsOpts, nats.Secure(cfg))
+	}
+	if o.transport.Proxy != "" || o.transport.DialTimeout != 0 {
+		dial, err := o.transport.DialContext()
+		if err != nil {
+			return nil, err
+		}
+		natsOpts = append(natsOpts, nats.SetCustomDialer(dialer(dial)))
+	}
+	natsOpts = append(natsOpts, o.natsOptions...)
+
+	conn, err := nats.Connect(servers, natsOpts...)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// dialer adapts TransportConfig.DialContext to nats.CustomDialer.
+type dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (d dialer) Dial(network, addr string) (net.Conn, error) {
+	return d(context.Background(), network, addr)
+}
+
+// Write publishes a copy of p, without its trailing newline, as one message.
+func (s *Sink) Write(p []byte) (int, error) {
+	if s.conn.IsClosed() || s.conn.IsDraining() {
+		return 0, errClosed
+	}
+	if err := s.conn.Publish(s.subject, bytes.TrimSuffix(p, []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sync flushes buffered messages and waits for the server to confirm it has
+// received them, for at most the sync timeout (see WithSyncTimeout). While
+// the connection is down, Sync waits for it to be restored, so it fails if
+// reconnecting takes longer than the timeout.
+func (s *Sink) Sync() error {
+	if s.conn.IsClosed() {
+		return nil
+	}
+	return s.conn.FlushTimeout(s.opts.syncTimeout)
+}
+
+// Close drains the connection, delivering buffered messages, and waits for
+// it to close, for at most the drain timeout (see WithDrainTimeout). If the
+// connection is down, it's closed at once, and messages buffered while
+// reconnecting are lost.
+func (s *Sink) Close() error {
+	if s.conn.IsClosed() {
+		return nil
+	}
+	if err := s.conn.Drain(); err != nil {
+		if err == nats.ErrConnectionClosed {
+			return nil
+		}
+		s.conn.Close()
+		return err
+	}
+	select {
+	case <-s.closed:
+		if err := s.conn.LastError(); err != nil && err != nats.ErrConnectionClosed {
+			return err
+		}
+		return nil
+	case <-time.After(s.opts.drainTimeout + time.Second):
+		s.conn.Close()
+		return errors.New("zapnats: timed out draining connection")
+	}
+}
+
+// Register registers a sink factory for the "nats" URL scheme with
+// zap.RegisterTransportSink. The supplied options apply to every sink opened
+// through the registry.
+//
+// URLs take the form nats://[user:password@]host[:port]/subject, optionally
+// with the query parameters syncTimeout and drainTimeout, as well as any of
+// the parameters understood by zap.TransportConfig (caFile, certFile,
+// keyFile, serverName, insecureSkipVerify, proxy, and dialTimeout); TLS
+// parameters switch the connection to TLS. Durations use time.ParseDuration
+// syntax.
+func Register(opts ...Option) error {
+	return zap.RegisterTransportSink("nats", true, func(u *url.URL) (zap.Sink, error) {
+		return newURLSink(u, opts)
+	})
+}
+
+func newURLSink(u *url.URL, base []Option) (zap.Sink, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("nats URLs must include a host: got %v", u)
+	}
+	subject := strings.TrimPrefix(u.Path, "/")
+	if subject == "" {
+		return nil, fmt.Errorf("nats URLs must include a subject as their path: got %v", u)
+	}
+
+	// Transport parameters in the URL override those passed to Register.
+	o := defaultOptions()
+	for _, opt := range base {
+		opt.apply(&o)
+	}
+	transport, query, err := o.transport.ApplyQuery(u.Query())
+	if err != nil {
+		return nil, err
+	}
+	if transport.HasTLS() && transport.ServerName == "" {
+		transport.ServerName = u.Hostname()
+	}
+
+	opts := append([]Option(nil), base...)
+	opts = append(opts, WithTransport(transport))
+	for key, vals := range query {
+		val := vals[len(vals)-1]
+		switch key {
+		case "syncTimeout":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid syncTimeout %q: %v", val, err)
+			}
+			opts = append(opts, WithSyncTimeout(d))
+		case "drainTimeout":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid drainTimeout %q: %v", val, err)
+			}
+			opts = append(opts, WithDrainTimeout(d))
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q in %v", key, u)
+		}
+	}
+
+	server := url.URL{Scheme: "nats", User: u.User, Host: u.Host}
+	return New(server.String(), subject, opts...)
+}
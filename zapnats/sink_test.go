@@ -0,0 +1,140 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapnats
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runServer(t testing.TB) *server.Server {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: server.RANDOM_PORT, NoLog: true, NoSigs: true})
+	require.NoError(t, err, "Failed to create NATS server.")
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(5*time.Second), "NATS server didn't start.")
+	return srv
+}
+
+func subscribe(t testing.TB, srv *server.Server, subject string) (*nats.Conn, chan *nats.Msg) {
+	conn, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err, "Failed to connect subscriber.")
+	msgs := make(chan *nats.Msg, 1024)
+	_, err = conn.ChanSubscribe(subject, msgs)
+	require.NoError(t, err, "Failed to subscribe.")
+	require.NoError(t, conn.Flush(), "Failed to flush subscription.")
+	return conn, msgs
+}
+
+func receive(t testing.TB, msgs <-chan *nats.Msg) string {
+	select {
+	case msg := <-msgs:
+		return string(msg.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a message.")
+		return ""
+	}
+}
+
+func TestSinkPublishes(t *testing.T) {
+	srv := runServer(t)
+	defer srv.Shutdown()
+	sub, msgs := subscribe(t, srv, "logs.>")
+	defer sub.Close()
+
+	sink, err := New(srv.ClientURL(), "logs.api")
+	require.NoError(t, err, "Failed to create sink.")
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), sink, zapcore.InfoLevel)
+	logger := zap.New(core)
+	logger.Info("hello", zap.Int("n", 1))
+	require.NoError(t, logger.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, `{"msg":"hello","n":1}`, receive(t, msgs), "Unexpected message.")
+
+	// Close drains everything published before it.
+	for i := 0; i < 100; i++ {
+		sink.Write([]byte(strconv.Itoa(i) + "\n"))
+	}
+	require.NoError(t, sink.Close(), "Unexpected error closing sink.")
+	for i := 0; i < 100; i++ {
+		require.Equal(t, strconv.Itoa(i), receive(t, msgs), "Unexpected message after draining.")
+	}
+
+	_, err = sink.Write([]byte("late\n"))
+	assert.Equal(t, errClosed, err, "Expected writes after Close to fail.")
+	assert.NoError(t, sink.Sync(), "Expected syncing a closed sink to succeed.")
+	assert.NoError(t, sink.Close(), "Expected closing twice to succeed.")
+}
+
+func TestSinkErrors(t *testing.T) {
+	_, err := New("nats://127.0.0.1:1", "logs.*")
+	assert.Error(t, err, "Expected an error for a wildcard subject.")
+
+	_, err = New("nats://127.0.0.1:1", "logs", WithNATSOptions(nats.MaxReconnects(0), nats.Timeout(100*time.Millisecond)))
+	assert.Error(t, err, "Expected an error connecting to a missing server.")
+
+	_, err = New("nats://127.0.0.1:1", "logs", WithTransport(zap.TransportConfig{CAFile: "/does/not/exist.pem"}))
+	assert.Error(t, err, "Expected an error for a missing CA bundle.")
+}
+
+// The sink registry is global, so only register our scheme once.
+var _registerOnce sync.Once
+
+func TestRegister(t *testing.T) {
+	srv := runServer(t)
+	defer srv.Shutdown()
+	sub, msgs := subscribe(t, srv, "logs.registered")
+	defer sub.Close()
+
+	_registerOnce.Do(func() {
+		require.NoError(t, Register(WithSyncTimeout(time.Second)), "Failed to register sink.")
+	})
+	u, err := url.Parse(srv.ClientURL())
+	require.NoError(t, err, "Failed to parse server URL.")
+
+	ws, closeSinks, err := zap.Open("nats://" + u.Host + "/logs.registered?drainTimeout=5s")
+	require.NoError(t, err, "Failed to open registered sink.")
+	ws.Write([]byte("registered\n"))
+	closeSinks()
+	assert.Equal(t, "registered", receive(t, msgs), "Unexpected message from registered sink.")
+
+	for _, bad := range []string{
+		"nats:///logs",
+		"nats://" + u.Host,
+		"nats://" + u.Host + "/logs?color=blue",
+		"nats://" + u.Host + "/logs?syncTimeout=soon",
+		"nats://" + u.Host + "/logs?drainTimeout=soon",
+		"nats://" + u.Host + "/logs?insecureSkipVerify=maybe",
+	} {
+		_, _, err := zap.Open(bad)
+		assert.Error(t, err, "Expected an error opening %q.", bad)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapproto logs protocol buffer messages using their canonical JSON
+// mapping. It's separate from the zap package so that programs that don't
+// use protocol buffers don't depend on them.
+package zapproto // import "github.com/blastbao/zap/zapproto"
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/blastbao/zap"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// _defaultMaxBytes is the default cap on the size of a message's JSON
+// mapping logged by Message.
+const _defaultMaxBytes = 16 << 10
+
+// Message constructs a field that carries a protocol buffer message, encoded
+// using its canonical JSON mapping (with the original field names) rather
+// than by reflecting over the generated struct, as zap.Any would, which
+// exposes internal bookkeeping such as state and sizeCache.
+//
+// Messages whose JSON mapping exceeds 16KiB are logged as a string holding
+// a truncated prefix of it; use MessageWithLimit to choose another limit.
+// Like other reflected values, the message is only marshaled if the entry
+// is written.
+func Message(key string, msg proto.Message) zap.Field {
+	return MessageWithLimit(key, msg, _defaultMaxBytes)
+}
+
+// MessageWithLimit is like Message, but truncates the message's JSON mapping
+// to maxBytes instead of the default. A non-positive limit disables
+// truncation.
+func MessageWithLimit(key string, msg proto.Message, maxBytes int) zap.Field {
+	return zap.Reflect(key, protoJSON{msg: msg, maxBytes: maxBytes})
+}
+
+// protoJSON adapts a proto.Message to the json.Marshaler interface, which
+// the JSON encoder uses for reflected values.
+type protoJSON struct {
+	msg      proto.Message
+	maxBytes int
+}
+
+func (p protoJSON) MarshalJSON() ([]byte, error) {
+	if p.msg == nil {
+		return []byte("null"), nil
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(p.msg)
+	if err != nil {
+		return nil, err
+	}
+	if p.maxBytes <= 0 || len(b) <= p.maxBytes {
+		return b, nil
+	}
+	// Keep the output valid JSON by logging the truncated prefix as a string.
+	s := string(b[:p.maxBytes]) + "...(truncated from " + strconv.Itoa(len(b)) + " bytes)"
+	return json.Marshal(s)
+}
+
+// String implements fmt.Stringer, for encoders that don't speak JSON.
+func (p protoJSON) String() string {
+	b, err := p.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
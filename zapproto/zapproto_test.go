@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapproto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func encodeField(t testing.TB, f zap.Field) string {
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+	buf, err := enc.EncodeEntry(zapcore.Entry{}, []zap.Field{f})
+	require.NoError(t, err, "Unexpected error encoding field.")
+	defer buf.Free()
+	return strings.TrimSpace(buf.String())
+}
+
+func TestMessage(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{"user": "phil", "admin": true})
+	require.NoError(t, err, "Failed to build test message.")
+
+	tests := []struct {
+		desc string
+		f    zap.Field
+		want string
+	}{
+		{"struct", Message("k", msg), `{"k":{"admin":true,"user":"phil"}}`},
+		{"wrapper", Message("k", wrapperspb.String("foo")), `{"k":"foo"}`},
+		{"nil", Message("k", nil), `{"k":null}`},
+		{"unlimited", MessageWithLimit("k", msg, 0), `{"k":{"admin":true,"user":"phil"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, encodeField(t, tt.f), "Unexpected encoded message.")
+		})
+	}
+}
+
+func TestMessageTruncation(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{"user": "phil", "admin": true})
+	require.NoError(t, err, "Failed to build test message.")
+
+	// protojson deliberately varies its whitespace, so only check the shape of
+	// the output.
+	out := encodeField(t, MessageWithLimit("k", msg, 10))
+	assert.True(t, strings.HasPrefix(out, `{"k":"{`), "Expected the truncated message as a string, got %s.", out)
+	assert.Contains(t, out, "...(truncated from ", "Expected a truncation marker.")
+}
+
+func TestMessageString(t *testing.T) {
+	f := Message("k", wrapperspb.Bool(true))
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	assert.Equal(t, "true", enc.Fields["k"].(interface{ String() string }).String(), "Unexpected string form.")
+}
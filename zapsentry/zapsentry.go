@@ -0,0 +1,218 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapsentry provides a Core that reports error-level entries to
+// Sentry or a similar crash-reporting service.
+//
+// The package doesn't depend on a Sentry SDK. Instead, it reports Events to
+// a Client, which is a few lines of glue for any SDK:
+//
+//	type sentryClient struct{ hub *sentry.Hub }
+//
+//	func (c sentryClient) Capture(e *zapsentry.Event) error {
+//		ev := sentry.NewEvent()
+//		ev.Level = sentry.Level(e.Severity())
+//		ev.Message = e.Message
+//		ev.Timestamp = e.Time
+//		ev.Logger = e.LoggerName
+//		ev.Extra = e.Fields
+//		c.hub.CaptureEvent(ev)
+//		return nil
+//	}
+//
+//	func (c sentryClient) Flush(timeout time.Duration) bool {
+//		return c.hub.Flush(timeout)
+//	}
+//
+// Tee the Core with the Logger's usual one to report errors as well as log
+// them:
+//
+//	logger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+//		return zapcore.NewTee(core, zapsentry.NewCore(sentryClient{sentry.CurrentHub()}))
+//	}))
+package zapsentry // import "github.com/blastbao/zap/zapsentry"
+
+import (
+	"time"
+
+	"github.com/blastbao/zap/internal/randutil"
+	"github.com/blastbao/zap/zapcore"
+)
+
+// _defaultFlushTimeout is how long a Core waits for its Client to deliver
+// events before the process panics or exits, if WithFlushTimeout isn't used.
+const _defaultFlushTimeout = 2 * time.Second
+
+// An Event is a log entry prepared for a crash-reporting service.
+type Event struct {
+	zapcore.Entry
+
+	// Fields holds the entry's fields, including those added with With,
+	// encoded as by zapcore.MapObjectEncoder.
+	Fields map[string]interface{}
+
+	// Error is the message of the entry's "error" field (as added by
+	// zap.Error), if it has one.
+	Error string
+}
+
+// Severity returns the Sentry level that corresponds to the entry's level:
+// "debug", "info", "warning", "error", or "fatal". DPanic, Panic, and Fatal
+// entries are all fatal.
+func (e *Event) Severity() string {
+	switch {
+	case e.Level < zapcore.InfoLevel:
+		return "debug"
+	case e.Level == zapcore.InfoLevel:
+		return "info"
+	case e.Level == zapcore.WarnLevel:
+		return "warning"
+	case e.Level == zapcore.ErrorLevel:
+		return "error"
+	default:
+		return "fatal"
+	}
+}
+
+// A Client delivers events to a crash-reporting service. Its methods must
+// be safe for concurrent use.
+type Client interface {
+	// Capture reports an event. It may deliver it asynchronously.
+	Capture(*Event) error
+	// Flush waits up to timeout for captured events to be delivered,
+	// reporting whether they all were.
+	Flush(timeout time.Duration) bool
+}
+
+// An Option configures a Core.
+type Option interface {
+	apply(*core)
+}
+
+type optionFunc func(*core)
+
+func (f optionFunc) apply(c *core) {
+	f(c)
+}
+
+// WithLevel sets the levels that are reported. By default, ErrorLevel and
+// above are.
+func WithLevel(enab zapcore.LevelEnabler) Option {
+	return optionFunc(func(c *core) {
+		c.LevelEnabler = enab
+	})
+}
+
+// WithSampleRate reports only the given fraction of ErrorLevel entries,
+// chosen at random, to keep noisy errors from exhausting a quota. Entries
+// above ErrorLevel are always reported. Rates of 1 or more, the default,
+// report every entry.
+func WithSampleRate(rate float64) Option {
+	return optionFunc(func(c *core) {
+		c.sampleRate = rate
+	})
+}
+
+// WithFlushTimeout sets how long the Core waits for the Client to deliver
+// events after a DPanic, Panic, or Fatal entry, which are likely to end the
+// process, and on Sync. It defaults to two seconds.
+func WithFlushTimeout(timeout time.Duration) Option {
+	return optionFunc(func(c *core) {
+		c.flushTimeout = timeout
+	})
+}
+
+// NewCore builds a Core that reports entries to client. Entries are
+// reported with their fields, stack trace, and caller, if the Logger adds
+// them (see zap.AddStacktrace and zap.AddCaller).
+func NewCore(client Client, opts ...Option) zapcore.Core {
+	c := &core{
+		LevelEnabler: zapcore.ErrorLevel,
+		client:       client,
+		sampleRate:   1,
+		flushTimeout: _defaultFlushTimeout,
+		sample:       randutil.NewFloat64(),
+		context:      zapcore.NewMapObjectEncoder(),
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	return c
+}
+
+type core struct {
+	zapcore.LevelEnabler
+	client       Client
+	sampleRate   float64
+	flushTimeout time.Duration
+	sample       func() float64
+	context      *zapcore.MapObjectEncoder
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.context = c.fields(fields)
+	return &clone
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if ent.Level == zapcore.ErrorLevel && c.sampleRate < 1 && c.sample() >= c.sampleRate {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := c.fields(fields)
+	ev := &Event{Entry: ent, Fields: enc.Fields}
+	if ev.Stack == "" {
+		ev.Stack = ent.Stacktrace()
+	}
+	if msg, ok := enc.Fields["error"].(string); ok {
+		ev.Error = msg
+	}
+	err := c.client.Capture(ev)
+	if ent.Level > zapcore.ErrorLevel {
+		// The process is likely about to panic or exit, taking any
+		// undelivered events with it.
+		c.client.Flush(c.flushTimeout)
+	}
+	return err
+}
+
+func (c *core) Sync() error {
+	c.client.Flush(c.flushTimeout)
+	return nil
+}
+
+// fields returns a new encoder holding the Core's context and fields.
+func (c *core) fields(fields []zapcore.Field) *zapcore.MapObjectEncoder {
+	enc := zapcore.NewMapObjectEncoder()
+	for k, v := range c.context.Fields {
+		enc.Fields[k] = v
+	}
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return enc
+}
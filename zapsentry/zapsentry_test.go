@@ -0,0 +1,141 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapsentry
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/internal/exit"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memClient records captured events and flushes.
+type memClient struct {
+	mu      sync.Mutex
+	events  []*Event
+	flushes []time.Duration
+	err     error
+}
+
+func (c *memClient) Capture(e *Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return c.err
+}
+
+func (c *memClient) Flush(timeout time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushes = append(c.flushes, timeout)
+	return true
+}
+
+func TestCore(t *testing.T) {
+	client := &memClient{}
+	logger := zap.New(NewCore(client), zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)).Named("db")
+
+	logger.Warn("not reported")
+	logger.With(zap.String("host", "db1")).Error("query failed", zap.Error(errors.New("timeout")), zap.Int("attempt", 3))
+
+	require.Equal(t, 1, len(client.events), "Expected only error-level entries to be reported.")
+	ev := client.events[0]
+	assert.Equal(t, "query failed", ev.Message, "Unexpected message.")
+	assert.Equal(t, "db", ev.LoggerName, "Unexpected logger name.")
+	assert.Equal(t, "error", ev.Severity(), "Unexpected severity.")
+	assert.Equal(t, "timeout", ev.Error, "Unexpected error.")
+	assert.Equal(t, map[string]interface{}{"host": "db1", "error": "timeout", "attempt": int64(3)}, ev.Fields, "Unexpected fields.")
+	assert.True(t, ev.Caller.Defined, "Expected the caller to be reported.")
+	assert.NotEmpty(t, ev.Stack, "Expected the stack trace to be reported.")
+	assert.Empty(t, client.flushes, "Expected error entries not to flush.")
+
+	require.NoError(t, logger.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, []time.Duration{_defaultFlushTimeout}, client.flushes, "Expected Sync to flush.")
+}
+
+func TestCoreFlushesOnFatal(t *testing.T) {
+	client := &memClient{}
+	logger := zap.New(NewCore(client, WithFlushTimeout(time.Second)))
+
+	stub := exit.WithStub(func() { logger.Fatal("bye") })
+	assert.True(t, stub.Exited, "Expected the logger to exit.")
+	assert.Panics(t, func() { logger.Panic("oops") }, "Expected the logger to panic.")
+
+	require.Equal(t, 2, len(client.events), "Unexpected number of events.")
+	assert.Equal(t, "fatal", client.events[0].Severity(), "Expected fatal entries to be fatal.")
+	assert.Equal(t, "fatal", client.events[1].Severity(), "Expected panic entries to be fatal.")
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, client.flushes, "Expected a flush before exiting or panicking.")
+}
+
+func TestCoreSampling(t *testing.T) {
+	client := &memClient{}
+	c := NewCore(client, WithSampleRate(0.5), WithLevel(zap.WarnLevel)).(*core)
+	samples := []float64{0.1, 0.9, 0.4, 0.6}
+	c.sample = func() float64 {
+		s := samples[0]
+		samples = samples[1:]
+		return s
+	}
+	logger := zap.New(c)
+
+	for i := 0; i < 4; i++ {
+		logger.Error("sampled")
+	}
+	logger.Warn("warn")
+	logger.DPanic("always")
+
+	var msgs []string
+	for _, ev := range client.events {
+		msgs = append(msgs, ev.Message)
+	}
+	assert.Equal(t, []string{"sampled", "sampled", "warn", "always"}, msgs, "Expected only error entries to be sampled.")
+}
+
+func TestCoreCaptureError(t *testing.T) {
+	client := &memClient{err: errors.New("quota exceeded")}
+	c := NewCore(client)
+	ce := c.Check(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "x"}, nil)
+	require.NotNil(t, ce, "Expected error entries to be enabled.")
+	assert.EqualError(t, c.Write(ce.Entry, nil), "quota exceeded", "Expected Capture errors to be returned.")
+}
+
+func TestEventSeverity(t *testing.T) {
+	tests := map[zapcore.Level]string{
+		zapcore.DebugLevel:  "debug",
+		zapcore.InfoLevel:   "info",
+		zapcore.WarnLevel:   "warning",
+		zapcore.ErrorLevel:  "error",
+		zapcore.DPanicLevel: "fatal",
+		zapcore.PanicLevel:  "fatal",
+		zapcore.FatalLevel:  "fatal",
+	}
+	for lvl, want := range tests {
+		ev := &Event{Entry: zapcore.Entry{Level: lvl}}
+		assert.Equal(t, want, ev.Severity(), "Unexpected severity for %v.", lvl)
+	}
+}
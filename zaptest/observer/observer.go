@@ -25,10 +25,15 @@
 package observer // import "github.com/blastbao/zap/zaptest/observer"
 
 import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/blastbao/zap/internal/ztest"
 	"github.com/blastbao/zap/zapcore"
 )
 
@@ -69,7 +74,7 @@ func (o *ObservedLogs) TakeAll() []LoggedEntry {
 
 // AllUntimed returns a copy of all the observed logs, but overwrites the
 // observed timestamps with time.Time's zero value. This is useful when making
-// assertions in tests.
+// assertions in tests. Use Sorted first for a deterministic order.
 func (o *ObservedLogs) AllUntimed() []LoggedEntry {
 	ret := o.All()
 	for i := range ret {
@@ -104,6 +109,97 @@ func (o *ObservedLogs) FilterField(field zapcore.Field) *ObservedLogs {
 	})
 }
 
+// FilterMessageRegexp filters entries to those whose message matches the
+// supplied regular expression.
+func (o *ObservedLogs) FilterMessageRegexp(re *regexp.Regexp) *ObservedLogs {
+	return o.filter(func(e LoggedEntry) bool {
+		return re.MatchString(e.Message)
+	})
+}
+
+// FilterFieldKeyValue filters entries to those that have a field with the
+// specified key whose encoded value equals value. Unlike FilterField, the
+// field's type doesn't need to match exactly: numeric values compare equal
+// if they represent the same number, so FilterFieldKeyValue("n", 1) matches
+// both zap.Int("n", 1) and zap.Uint8("n", 1).
+func (o *ObservedLogs) FilterFieldKeyValue(key string, value interface{}) *ObservedLogs {
+	return o.filter(func(e LoggedEntry) bool {
+		for _, ctxField := range e.Context {
+			if ctxField.Key != key {
+				continue
+			}
+			enc := zapcore.NewMapObjectEncoder()
+			ctxField.AddTo(enc)
+			if ztest.ValuesEqual(enc.Fields[key], value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FilterLevelRange filters entries to those logged at a level between min and
+// max, inclusive.
+func (o *ObservedLogs) FilterLevelRange(min, max zapcore.Level) *ObservedLogs {
+	return o.filter(func(e LoggedEntry) bool {
+		return e.Level >= min && e.Level <= max
+	})
+}
+
+// Sorted returns a copy of the collection ordered by level and then by
+// message. The sort is stable, so entries with the same level and message
+// keep the order in which they were observed. This makes assertions
+// deterministic when entries are logged concurrently.
+func (o *ObservedLogs) Sorted() *ObservedLogs {
+	logs := o.All()
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].Level != logs[j].Level {
+			return logs[i].Level < logs[j].Level
+		}
+		return logs[i].Message < logs[j].Message
+	})
+	return &ObservedLogs{logs: logs}
+}
+
+// Diff compares the observed logs against the expected entries and returns a
+// human-readable description of the differences, or an empty string if there
+// are none. Entries are compared by level, logger name, message, and the
+// encoded context (see LoggedEntry.ContextMap), so timestamps, callers, and
+// stacks are ignored.
+//
+//	if diff := logs.Diff(want); diff != "" {
+//		t.Errorf("Unexpected logs (-want +got):\n%s", diff)
+//	}
+func (o *ObservedLogs) Diff(expected []LoggedEntry) string {
+	actual := o.All()
+
+	var buf bytes.Buffer
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i >= len(actual):
+			fmt.Fprintf(&buf, "entry %d:\n- %s\n", i, describe(expected[i]))
+		case i >= len(expected):
+			fmt.Fprintf(&buf, "entry %d:\n+ %s\n", i, describe(actual[i]))
+		default:
+			want, got := describe(expected[i]), describe(actual[i])
+			if want != got {
+				fmt.Fprintf(&buf, "entry %d:\n- %s\n+ %s\n", i, want, got)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// describe renders the parts of an entry that Diff compares. fmt prints maps
+// with sorted keys, so the output is deterministic.
+func describe(e LoggedEntry) string {
+	name := e.LoggerName
+	if name != "" {
+		name += " "
+	}
+	return fmt.Sprintf("%v %s%q %v", e.Level, name, e.Message, e.ContextMap())
+}
+
 func (o *ObservedLogs) filter(match func(LoggedEntry) bool) *ObservedLogs {
 	o.mu.RLock()
 	defer o.mu.RUnlock()
@@ -158,7 +254,9 @@ func (co *contextObserver) Write(ent zapcore.Entry, fields []zapcore.Field) erro
 	all := make([]zapcore.Field, 0, len(fields)+len(co.context))
 	all = append(all, co.context...)
 	all = append(all, fields...)
-	co.logs.add(LoggedEntry{ent, all})
+	// Measure Since and Until fields from the entry's time, as encoders do,
+	// rather than whenever the test inspects them.
+	co.logs.add(LoggedEntry{ent, zapcore.ResolveTimeFields(all, ent.Time)})
 	return nil
 }
 
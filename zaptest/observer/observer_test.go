@@ -21,6 +21,7 @@
 package observer_test
 
 import (
+	"regexp"
 	"testing"
 	"time"
 
@@ -213,3 +214,125 @@ func TestFilters(t *testing.T) {
 		assert.Equal(t, tt.want, got, tt.msg)
 	}
 }
+
+func TestQueryFilters(t *testing.T) {
+	logs := []LoggedEntry{
+		{
+			Entry:   zapcore.Entry{Level: zap.DebugLevel, Message: "request 1 started"},
+			Context: []zapcore.Field{zap.Int("status", 200), zap.String("path", "/")},
+		},
+		{
+			Entry:   zapcore.Entry{Level: zap.WarnLevel, Message: "request 2 slow"},
+			Context: []zapcore.Field{zap.Uint16("status", 200), zap.Duration("took", time.Second)},
+		},
+		{
+			Entry:   zapcore.Entry{Level: zap.ErrorLevel, Message: "request 3 failed"},
+			Context: []zapcore.Field{zap.Int("status", 500), zap.Bool("retry", true)},
+		},
+	}
+
+	logger, sink := New(zap.DebugLevel)
+	for _, log := range logs {
+		logger.Write(log.Entry, log.Context)
+	}
+
+	tests := []struct {
+		msg      string
+		filtered *ObservedLogs
+		want     []LoggedEntry
+	}{
+		{
+			msg:      "filter by message regexp",
+			filtered: sink.FilterMessageRegexp(regexp.MustCompile(`^request \d (slow|failed)$`)),
+			want:     logs[1:3],
+		},
+		{
+			msg:      "filter by key and value across numeric types",
+			filtered: sink.FilterFieldKeyValue("status", 200),
+			want:     logs[0:2],
+		},
+		{
+			msg:      "filter by key and string value",
+			filtered: sink.FilterFieldKeyValue("path", "/"),
+			want:     logs[0:1],
+		},
+		{
+			msg:      "filter by key and duration value",
+			filtered: sink.FilterFieldKeyValue("took", time.Second),
+			want:     logs[1:2],
+		},
+		{
+			msg:      "filter by key and mismatched value",
+			filtered: sink.FilterFieldKeyValue("retry", "true"),
+			want:     []LoggedEntry{},
+		},
+		{
+			msg:      "filter by level range",
+			filtered: sink.FilterLevelRange(zap.InfoLevel, zap.ErrorLevel),
+			want:     logs[1:3],
+		},
+		{
+			msg:      "filter by level range and key value",
+			filtered: sink.FilterLevelRange(zap.DebugLevel, zap.WarnLevel).FilterFieldKeyValue("status", 200),
+			want:     logs[0:2],
+		},
+	}
+
+	for _, tt := range tests {
+		got := tt.filtered.AllUntimed()
+		assert.Equal(t, tt.want, got, tt.msg)
+	}
+}
+
+func TestSorted(t *testing.T) {
+	logger, logs := New(zap.DebugLevel)
+	for _, ent := range []zapcore.Entry{
+		{Level: zap.ErrorLevel, Message: "b"},
+		{Level: zap.InfoLevel, Message: "b"},
+		{Level: zap.ErrorLevel, Message: "a"},
+		{Level: zap.InfoLevel, Message: "b"},
+	} {
+		logger.Write(ent, []zapcore.Field{zap.Int("i", logs.Len())})
+	}
+
+	want := []LoggedEntry{
+		{Entry: zapcore.Entry{Level: zap.InfoLevel, Message: "b"}, Context: []zapcore.Field{zap.Int("i", 1)}},
+		{Entry: zapcore.Entry{Level: zap.InfoLevel, Message: "b"}, Context: []zapcore.Field{zap.Int("i", 3)}},
+		{Entry: zapcore.Entry{Level: zap.ErrorLevel, Message: "a"}, Context: []zapcore.Field{zap.Int("i", 2)}},
+		{Entry: zapcore.Entry{Level: zap.ErrorLevel, Message: "b"}, Context: []zapcore.Field{zap.Int("i", 0)}},
+	}
+	assert.Equal(t, want, logs.Sorted().AllUntimed(), "Unexpected sorted entries.")
+	assert.Equal(t, 4, logs.Len(), "Sorting shouldn't modify the original collection.")
+	assert.Equal(t, "b", logs.All()[0].Message, "Sorting shouldn't reorder the original collection.")
+}
+
+func TestDiff(t *testing.T) {
+	logger, logs := New(zap.DebugLevel)
+	zap.New(logger).Named("svc").Info("hello", zap.Int("n", 1))
+	zap.New(logger).Warn("bye")
+
+	matching := []LoggedEntry{
+		{
+			Entry:   zapcore.Entry{Level: zap.InfoLevel, LoggerName: "svc", Message: "hello"},
+			Context: []zapcore.Field{zap.Int64("n", 1)},
+		},
+		{Entry: zapcore.Entry{Level: zap.WarnLevel, Message: "bye"}},
+	}
+	assert.Equal(t, "", logs.Diff(matching), "Expected no diff for matching entries.")
+
+	mismatched := []LoggedEntry{
+		{
+			Entry:   zapcore.Entry{Level: zap.InfoLevel, LoggerName: "svc", Message: "hello"},
+			Context: []zapcore.Field{zap.Int("n", 2)},
+		},
+	}
+	want := "entry 0:\n" +
+		"- info svc \"hello\" map[n:2]\n" +
+		"+ info svc \"hello\" map[n:1]\n" +
+		"entry 1:\n" +
+		"+ warn \"bye\" map[]\n"
+	assert.Equal(t, want, logs.Diff(mismatched), "Unexpected diff.")
+
+	missing := append(matching, LoggedEntry{Entry: zapcore.Entry{Level: zap.ErrorLevel, Message: "boom"}})
+	assert.Equal(t, "entry 2:\n- error \"boom\" map[]\n", logs.Diff(missing), "Unexpected diff for missing entry.")
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapassert provides declarative assertions about log output, for
+// tests that verify what a program logs without depending on the details of
+// every entry.
+//
+// Assertions take a Source, such as the ObservedLogs returned by
+// observer.New or the captured output of a JSON-encoding logger, and a set
+// of Matchers that an entry must satisfy together. They return an error
+// describing the mismatch and the entries that were logged, so they combine
+// with any assertion library:
+//
+//	core, logs := observer.New(zap.InfoLevel)
+//	go serve(zap.New(core))
+//	require.NoError(t, zapassert.Eventually(logs, zapassert.MatchMessage("ready"), zapassert.MatchField("port", 8080)))
+package zapassert // import "github.com/blastbao/zap/zaptest/zapassert"
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapassert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+// A Matcher decides whether a logged entry is of interest.
+type Matcher interface {
+	// Match reports whether the entry matches.
+	Match(observer.LoggedEntry) bool
+	// String describes the matcher, for error messages.
+	String() string
+}
+
+type matcherFunc struct {
+	desc  string
+	match func(observer.LoggedEntry) bool
+}
+
+func (m matcherFunc) Match(e observer.LoggedEntry) bool { return m.match(e) }
+
+func (m matcherFunc) String() string { return m.desc }
+
+// MatchMessage matches entries with exactly the given message.
+func MatchMessage(msg string) Matcher {
+	return matcherFunc{fmt.Sprintf("message %q", msg), func(e observer.LoggedEntry) bool {
+		return e.Message == msg
+	}}
+}
+
+// MatchMessageContains matches entries whose message contains the given
+// substring.
+func MatchMessageContains(substr string) Matcher {
+	return matcherFunc{fmt.Sprintf("message containing %q", substr), func(e observer.LoggedEntry) bool {
+		return strings.Contains(e.Message, substr)
+	}}
+}
+
+// MatchMessageRegexp matches entries whose message matches the given
+// regular expression. It panics if the expression doesn't compile.
+func MatchMessageRegexp(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return matcherFunc{fmt.Sprintf("message matching %q", expr), func(e observer.LoggedEntry) bool {
+		return re.MatchString(e.Message)
+	}}
+}
+
+// MatchLevel matches entries logged at the given level.
+func MatchLevel(lvl zapcore.Level) Matcher {
+	return matcherFunc{fmt.Sprintf("level %v", lvl), func(e observer.LoggedEntry) bool {
+		return e.Level == lvl
+	}}
+}
+
+// MatchLoggerName matches entries logged by the Logger with the given name.
+func MatchLoggerName(name string) Matcher {
+	return matcherFunc{fmt.Sprintf("logger %q", name), func(e observer.LoggedEntry) bool {
+		return e.LoggerName == name
+	}}
+}
+
+// MatchFieldKey matches entries that have a field with the given key.
+func MatchFieldKey(key string) Matcher {
+	return matcherFunc{fmt.Sprintf("field %q", key), func(e observer.LoggedEntry) bool {
+		_, ok := e.ContextMap()[key]
+		return ok
+	}}
+}
+
+// MatchField matches entries that have a field with the given key whose
+// encoded value equals value. As with ObservedLogs.FilterFieldKeyValue,
+// numbers compare equal if they have the same value, whatever their types,
+// so MatchField("port", 8080) matches zap.Int("port", 8080),
+// zap.Uint16("port", 8080), and a port of 8080 parsed from JSON output.
+func MatchField(key string, value interface{}) Matcher {
+	return matcherFunc{fmt.Sprintf("field %q = %v", key, value), func(e observer.LoggedEntry) bool {
+		encoded, ok := e.ContextMap()[key]
+		return ok && ztest.ValuesEqual(encoded, value)
+	}}
+}
+
+// All matches entries that every one of the given matchers matches. It's
+// useful for building the steps passed to InOrder.
+func All(matchers ...Matcher) Matcher {
+	descs := make([]string, len(matchers))
+	for i, m := range matchers {
+		descs[i] = m.String()
+	}
+	return matcherFunc{strings.Join(descs, ", "), func(e observer.LoggedEntry) bool {
+		for _, m := range matchers {
+			if !m.Match(e) {
+				return false
+			}
+		}
+		return true
+	}}
+}
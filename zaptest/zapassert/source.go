@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapassert
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+// A Source provides the entries to make assertions about. *ObservedLogs, as
+// returned by observer.New, is a Source.
+type Source interface {
+	All() []observer.LoggedEntry
+}
+
+// JSONOutput adapts captured output from a Logger using the JSON encoder
+// with the production encoder config (as zap.NewProductionConfig uses) to a
+// Source. For example, out could be a *zaptest.Buffer the Logger writes to;
+// when used with Eventually while other goroutines log, out must be safe to
+// read concurrently with those writes.
+//
+// Each line is parsed as an entry: "level", "ts", "logger", "msg", "caller",
+// and "stacktrace" fill in the entry's metadata, and every other key becomes
+// a field. Lines that aren't JSON objects become entries with the whole
+// line as their message. out is read each time the Source is used, so
+// Eventually sees output written while it waits.
+func JSONOutput(out interface{ String() string }) Source {
+	return jsonOutput{out}
+}
+
+type jsonOutput struct {
+	out interface{ String() string }
+}
+
+func (s jsonOutput) All() []observer.LoggedEntry {
+	var entries []observer.LoggedEntry
+	for _, line := range strings.Split(s.out.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		entries = append(entries, parseJSONEntry(line))
+	}
+	return entries
+}
+
+func parseJSONEntry(line string) observer.LoggedEntry {
+	var obj map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return observer.LoggedEntry{Entry: zapcore.Entry{Message: line}, Context: []zapcore.Field{}}
+	}
+
+	var ent observer.LoggedEntry
+	ent.Context = []zapcore.Field{}
+	for k, v := range obj {
+		s, _ := v.(string)
+		switch k {
+		case "level":
+			ent.Level.UnmarshalText([]byte(s))
+		case "ts":
+			if n, ok := v.(json.Number); ok {
+				if f, err := n.Float64(); err == nil {
+					ent.Time = time.Unix(0, int64(f*float64(time.Second)))
+				}
+			}
+		case "logger":
+			ent.LoggerName = s
+		case "msg":
+			ent.Message = s
+		case "caller":
+		case "stacktrace":
+			ent.Stack = s
+		default:
+			ent.Context = append(ent.Context, jsonField(k, v))
+		}
+	}
+	return ent
+}
+
+// jsonField converts a decoded JSON value to a field, turning numbers into
+// integers where possible so that they compare naturally.
+func jsonField(key string, v interface{}) zapcore.Field {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return zapcore.Field{Key: key, Type: zapcore.Int64Type, Integer: i}
+		}
+		f, _ := n.Float64()
+		v = f
+	}
+	return zapcore.Field{Key: key, Type: zapcore.ReflectType, Interface: v}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapassert
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/blastbao/zap/zaptest/observer"
+)
+
+const (
+	// DefaultTimeout is how long Eventually waits for a matching entry.
+	DefaultTimeout = time.Second
+
+	_pollInterval = 10 * time.Millisecond
+)
+
+// Contains checks that at least one entry matches all the matchers.
+func Contains(src Source, matchers ...Matcher) error {
+	return Count(src, -1, matchers...)
+}
+
+// None checks that no entry matches all the matchers.
+func None(src Source, matchers ...Matcher) error {
+	return Count(src, 0, matchers...)
+}
+
+// Count checks that exactly n entries match all the matchers. A negative n
+// means at least one.
+func Count(src Source, n int, matchers ...Matcher) error {
+	m := All(matchers...)
+	entries := src.All()
+	got := 0
+	for _, e := range entries {
+		if m.Match(e) {
+			got++
+		}
+	}
+	if (n < 0 && got > 0) || got == n {
+		return nil
+	}
+	want := fmt.Sprint(n)
+	if n < 0 {
+		want = "at least 1"
+	}
+	return mismatch(entries, "expected %s entries with %v, found %d", want, m, got)
+}
+
+// Eventually waits up to DefaultTimeout for an entry that matches all the
+// matchers to be logged.
+func Eventually(src Source, matchers ...Matcher) error {
+	return EventuallyWithin(src, DefaultTimeout, matchers...)
+}
+
+// EventuallyWithin waits up to timeout for an entry that matches all the
+// matchers to be logged.
+func EventuallyWithin(src Source, timeout time.Duration, matchers ...Matcher) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := Contains(src, matchers...)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(_pollInterval)
+	}
+}
+
+// InOrder checks that entries matching each of the steps were logged in the
+// order given, though other entries may come in between. Use All to combine
+// several matchers into a step.
+//
+//	err := zapassert.InOrder(logs,
+//		zapassert.MatchMessage("starting"),
+//		zapassert.All(zapassert.MatchMessage("listening"), zapassert.MatchField("port", 8080)),
+//		zapassert.MatchMessage("ready"),
+//	)
+func InOrder(src Source, steps ...Matcher) error {
+	entries := src.All()
+	i := 0
+	for n, step := range steps {
+		for i < len(entries) && !step.Match(entries[i]) {
+			i++
+		}
+		if i == len(entries) {
+			return mismatch(entries, "expected step %d (%v) after the previous steps, found none", n+1, step)
+		}
+		i++
+	}
+	return nil
+}
+
+// mismatch builds an error that lists the observed entries.
+func mismatch(entries []observer.LoggedEntry, format string, args ...interface{}) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, format, args...)
+	fmt.Fprintf(&buf, "; observed %d entries:", len(entries))
+	for _, e := range entries {
+		name := e.LoggerName
+		if name != "" {
+			name += " "
+		}
+		fmt.Fprintf(&buf, "\n\t%v %s%q %v", e.Level, name, e.Message, e.ContextMap())
+	}
+	return fmt.Errorf("%s", buf.String())
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapassert
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blastbao/zap"
+	"github.com/blastbao/zap/zapcore"
+	"github.com/blastbao/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func observe() (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.DebugLevel)
+	return zap.New(core), logs
+}
+
+// lockedBuffer is a buffer that's safe to read while a Logger writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestMatchers(t *testing.T) {
+	logger, logs := observe()
+	logger.Named("server").Warn("listening on port", zap.Int("port", 8080), zap.String("host", "localhost"))
+	ent := logs.All()[0]
+
+	tests := []struct {
+		m    Matcher
+		want bool
+	}{
+		{MatchMessage("listening on port"), true},
+		{MatchMessage("listening"), false},
+		{MatchMessageContains("port"), true},
+		{MatchMessageContains("ready"), false},
+		{MatchMessageRegexp(`^listening on \w+$`), true},
+		{MatchMessageRegexp(`^ready`), false},
+		{MatchLevel(zap.WarnLevel), true},
+		{MatchLevel(zap.InfoLevel), false},
+		{MatchLoggerName("server"), true},
+		{MatchLoggerName("client"), false},
+		{MatchFieldKey("host"), true},
+		{MatchFieldKey("user"), false},
+		{MatchField("port", 8080), true},
+		{MatchField("port", uint16(8080)), true},
+		{MatchField("port", 8080.0), true},
+		{MatchField("port", 80), false},
+		{MatchField("port", "8080"), false},
+		{MatchField("host", "localhost"), true},
+		{MatchField("missing", 8080), false},
+		{All(MatchMessageContains("port"), MatchField("port", 8080)), true},
+		{All(MatchMessageContains("port"), MatchField("port", 80)), false},
+		{All(), true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.m.Match(ent), "Unexpected result from matcher %v.", tt.m)
+	}
+	assert.Equal(t, `message "ready", field "port" = 8080`, All(MatchMessage("ready"), MatchField("port", 8080)).String(), "Unexpected description.")
+}
+
+func TestCountAssertions(t *testing.T) {
+	logger, logs := observe()
+	logger.Info("request", zap.Int("status", 200))
+	logger.Info("request", zap.Int("status", 500))
+	logger.Error("request", zap.Int("status", 500))
+
+	assert.NoError(t, Contains(logs, MatchMessage("request")), "Expected a matching entry.")
+	assert.NoError(t, Count(logs, 2, MatchField("status", 500)), "Expected two matching entries.")
+	assert.NoError(t, Count(logs, 1, MatchField("status", 500), MatchLevel(zap.ErrorLevel)), "Expected one matching entry.")
+	assert.NoError(t, None(logs, MatchField("status", 404)), "Expected no matching entries.")
+
+	err := Contains(logs, MatchMessage("response"))
+	require.Error(t, err, "Expected an error with no matching entries.")
+	assert.Contains(t, err.Error(), `expected at least 1 entries with message "response", found 0`, "Unexpected error message.")
+	assert.Contains(t, err.Error(), `observed 3 entries:`, "Expected observed entries in error message.")
+	assert.Contains(t, err.Error(), `error "request" map[status:500]`, "Expected observed entries in error message.")
+
+	err = Count(logs, 1, MatchMessage("request"))
+	require.Error(t, err, "Expected an error with too many matching entries.")
+	assert.Contains(t, err.Error(), `expected 1 entries with message "request", found 3`, "Unexpected error message.")
+
+	assert.Error(t, None(logs, MatchField("status", 200)), "Expected an error with a matching entry.")
+}
+
+func TestInOrder(t *testing.T) {
+	logger, logs := observe()
+	logger.Info("starting")
+	logger.Debug("loading config")
+	logger.Info("listening", zap.Int("port", 8080))
+	logger.Info("ready")
+
+	assert.NoError(t, InOrder(logs), "Expected no steps to succeed.")
+	assert.NoError(t, InOrder(logs,
+		MatchMessage("starting"),
+		All(MatchMessage("listening"), MatchField("port", 8080)),
+		MatchMessage("ready"),
+	), "Expected steps to match in order.")
+
+	err := InOrder(logs, MatchMessage("ready"), MatchMessage("starting"))
+	require.Error(t, err, "Expected an error for steps out of order.")
+	assert.Contains(t, err.Error(), `expected step 2 (message "starting") after the previous steps`, "Unexpected error message.")
+
+	assert.Error(t, InOrder(logs, MatchMessage("ready"), MatchMessage("ready")), "Expected each step to consume an entry.")
+}
+
+func TestEventually(t *testing.T) {
+	logger, logs := observe()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		logger.Info("ready", zap.Int("port", 8080))
+	}()
+	assert.NoError(t, Eventually(logs, MatchMessage("ready"), MatchField("port", 8080)), "Expected entry to be logged eventually.")
+
+	start := time.Now()
+	err := EventuallyWithin(logs, 30*time.Millisecond, MatchMessage("stopped"))
+	assert.Error(t, err, "Expected an error when no entry is logged in time.")
+	assert.True(t, time.Since(start) >= 30*time.Millisecond, "Expected to wait for the full timeout.")
+}
+
+func TestJSONOutput(t *testing.T) {
+	buf := &lockedBuffer{}
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	logger := zap.New(zapcore.NewCore(enc, zapcore.AddSync(buf), zap.DebugLevel)).Named("server")
+
+	logger.Warn("listening", zap.Int("port", 8080), zap.Float64("load", 0.5), zap.Bool("tls", true), zap.Strings("hosts", []string{"a"}))
+	buf.Write([]byte("not json\n"))
+
+	src := JSONOutput(buf)
+	entries := src.All()
+	require.Equal(t, 2, len(entries), "Unexpected number of entries.")
+	assert.Equal(t, zap.WarnLevel, entries[0].Level, "Unexpected level.")
+	assert.Equal(t, "server", entries[0].LoggerName, "Unexpected logger name.")
+	assert.False(t, entries[0].Time.IsZero(), "Expected timestamp to be parsed.")
+	assert.Equal(t, map[string]interface{}{
+		"port":  int64(8080),
+		"load":  0.5,
+		"tls":   true,
+		"hosts": []interface{}{"a"},
+	}, entries[0].ContextMap(), "Unexpected fields.")
+	assert.Equal(t, "not json", entries[1].Message, "Expected unparseable lines to become messages.")
+
+	assert.NoError(t, Contains(src, MatchMessage("listening"), MatchLevel(zap.WarnLevel), MatchField("port", 8080)), "Expected a matching entry.")
+	assert.NoError(t, Contains(src, MatchMessage("not json")), "Expected a matching raw line.")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		logger.Info("ready")
+	}()
+	assert.NoError(t, Eventually(src, MatchMessage("ready")), "Expected output to be re-read while waiting.")
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapzstd adds Zstandard compression (zapcore.ZstdCodec) to
+// zapcore.NewCompressingWriteSyncer and to file sinks opened with
+// "compress=zstd". It's separate from zapcore so that programs that only
+// need gzip don't depend on a zstd implementation.
+//
+// Call Register once, before building loggers:
+//
+//	if err := zapzstd.Register(); err != nil {
+//		panic(err)
+//	}
+package zapzstd // import "github.com/blastbao/zap/zapzstd"
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Register registers zapcore.ZstdCodec with zapcore.RegisterCompressionCodec.
+// Levels run from 1 (fastest) to 22 (smallest), and are mapped onto the
+// encoder's speed settings the way the zstd command-line tool's are.
+func Register() error {
+	return zapcore.RegisterCompressionCodec(zapcore.ZstdCodec, newCompressor)
+}
+
+func newCompressor(w io.Writer, level int) (zapcore.Compressor, error) {
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if level != 0 {
+		if level < 1 || level > 22 {
+			return nil, fmt.Errorf("invalid zstd compression level %d: must be between 1 and 22", level)
+		}
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	return zstd.NewWriter(w, opts...)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapzstd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/blastbao/zap/internal/ztest"
+	"github.com/blastbao/zap/zapcore"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decompress(t testing.TB, data []byte) string {
+	dec, err := zstd.NewReader(bytes.NewReader(data))
+	require.NoError(t, err, "Failed to open zstd reader.")
+	defer dec.Close()
+	out, err := ioutil.ReadAll(dec)
+	require.NoError(t, err, "Failed to decompress zstd output.")
+	return string(out)
+}
+
+func TestRegister(t *testing.T) {
+	require.NoError(t, Register(), "Unexpected error registering zstd.")
+	assert.True(t, zapcore.ZstdCodec.Available(), "Expected zstd to be available.")
+	assert.Error(t, Register(), "Expected an error registering zstd twice.")
+
+	buf := &ztest.Buffer{}
+	for _, msg := range []string{"first\n", "second\n"} {
+		ws, err := zapcore.NewCompressingWriteSyncer(buf, zapcore.ZstdCodec, 1)
+		require.NoError(t, err, "Unexpected error creating WriteSyncer.")
+		ws.Write([]byte(msg))
+		require.NoError(t, ws.Sync(), "Unexpected error syncing.")
+		ws.Write(bytes.Repeat([]byte(msg), 100))
+		require.NoError(t, ws.Close(), "Unexpected error closing.")
+	}
+	want := "first\n" + string(bytes.Repeat([]byte("first\n"), 100)) + "second\n" + string(bytes.Repeat([]byte("second\n"), 100))
+	assert.Equal(t, want, decompress(t, buf.Bytes()), "Expected concatenated streams to decompress as a whole.")
+	assert.True(t, len(buf.Bytes()) < 200, "Expected repetitive output to compress well, got %d bytes.", len(buf.Bytes()))
+
+	_, err := zapcore.NewCompressingWriteSyncer(buf, zapcore.ZstdCodec, 23)
+	if assert.Error(t, err, "Expected an error for an invalid level.") {
+		assert.Contains(t, err.Error(), "invalid zstd compression level 23", "Unexpected error message.")
+	}
+}